@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// cannedBus is an rmb client that answers all calls with the same payload
+type cannedBus struct {
+	fn       string
+	data     interface{}
+	response string
+}
+
+func (b *cannedBus) Call(ctx context.Context, twin uint32, fn string, data interface{}, result interface{}) error {
+	b.fn = fn
+	b.data = data
+	return json.Unmarshal([]byte(b.response), result)
+}
+
+func TestProvisioningHealth(t *testing.T) {
+	bus := &cannedBus{response: `{
+		"twin_id": 10,
+		"contract_id": 20,
+		"workloads": [{
+			"workload_id": "10-20-vm",
+			"type": "zmachine",
+			"name": "vm",
+			"status": "unhealthy",
+			"checks": [
+				{"name": "vm.running", "ok": true},
+				{"name": "vm.network", "ok": false, "message": "namespace not found", "evidence": {"namespace": "n-abc"}}
+			]
+		}]
+	}`}
+
+	node := NewNodeClient(1, bus)
+	health, err := node.ProvisioningHealth(context.Background(), "10:20")
+	require.NoError(t, err)
+	require.Equal(t, "zos.debug.deployment.health", bus.fn)
+	require.Equal(t, args{"deployment": "10:20"}, bus.data)
+
+	require.Equal(t, ProvisioningHealthResponse{
+		TwinID:     10,
+		ContractID: 20,
+		Workloads: []WorkloadHealth{
+			{
+				WorkloadID: "10-20-vm",
+				Type:       "zmachine",
+				Name:       "vm",
+				Status:     HealthUnhealthy,
+				Checks: []HealthCheck{
+					{Name: "vm.running", OK: true},
+					{Name: "vm.network", Message: "namespace not found", Evidence: map[string]interface{}{"namespace": "n-abc"}},
+				},
+			},
+		},
+	}, health)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/threefoldtech/zosbase/pkg"
 	"github.com/threefoldtech/zosbase/pkg/capacity/dmi"
 	"github.com/threefoldtech/zosbase/pkg/diagnostics"
+	"github.com/threefoldtech/zosbase/pkg/environment"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes"
 )
 
@@ -44,7 +45,24 @@ type args map[string]interface{}
 // NewNodeClient creates a new node RMB client. This client then can be used to
 // communicate with the node over RMB.
 func NewNodeClient(nodeTwin uint32, bus rmb.Client) *NodeClient {
-	return &NodeClient{nodeTwin, bus}
+	return &NodeClient{nodeTwin: nodeTwin, bus: bus}
+}
+
+// NewNodeClientWithOptions creates a new node RMB client like NewNodeClient,
+// but calls that are made with a context without a deadline are timed out
+// after DefaultTimeout (or the WithTimeout value) so a dead node doesn't
+// block the caller forever.
+func NewNodeClientWithOptions(nodeTwin uint32, bus rmb.Client, opts ...Option) *NodeClient {
+	cfg := options{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.timeout > 0 {
+		bus = &timeoutClient{client: bus, timeout: cfg.timeout}
+	}
+
+	return &NodeClient{nodeTwin: nodeTwin, bus: bus}
 }
 
 // DeploymentDeploy sends the deployment to the node for processing.
@@ -60,6 +78,14 @@ func (n *NodeClient) DeploymentUpdate(ctx context.Context, dl gridtypes.Deployme
 	return n.bus.Call(ctx, n.nodeTwin, cmd, dl, nil)
 }
 
+// DeploymentValidate runs all the checks the node does on the deployment before
+// it's deployed (or updated if it already exists), including the contract hash,
+// without deploying anything.
+func (n *NodeClient) DeploymentValidate(ctx context.Context, dl gridtypes.Deployment) error {
+	const cmd = "zos.deployment.validate"
+	return n.bus.Call(ctx, n.nodeTwin, cmd, dl, nil)
+}
+
 // DeploymentGet gets a deployment via contract ID
 func (n *NodeClient) DeploymentGet(ctx context.Context, contractID uint64) (dl gridtypes.Deployment, err error) {
 	const cmd = "zos.deployment.get"
@@ -74,6 +100,57 @@ func (n *NodeClient) DeploymentGet(ctx context.Context, contractID uint64) (dl g
 	return dl, nil
 }
 
+// HealthStatus is the health status of a workload
+type HealthStatus string
+
+const (
+	HealthHealthy   HealthStatus = "healthy"
+	HealthDegraded  HealthStatus = "degraded"
+	HealthUnhealthy HealthStatus = "unhealthy"
+)
+
+// WorkloadHealth is the health of a workload with the checks that were run
+type WorkloadHealth struct {
+	WorkloadID string        `json:"workload_id"`
+	Type       string        `json:"type"`
+	Name       string        `json:"name"`
+	Status     HealthStatus  `json:"status"`
+	Checks     []HealthCheck `json:"checks"`
+}
+
+// ProvisioningHealthResponse is the health of the workloads of a deployment
+type ProvisioningHealthResponse struct {
+	TwinID     uint32           `json:"twin_id"`
+	ContractID uint64           `json:"contract_id"`
+	Workloads  []WorkloadHealth `json:"workloads"`
+}
+
+// ProvisioningHealth runs the node health checks on the workloads of the
+// deployment (in the format twin-id:contract-id). Workloads without checks
+// are not part of the response. Only the farmer twin is allowed to call this.
+func (n *NodeClient) ProvisioningHealth(ctx context.Context, deployment string) (health ProvisioningHealthResponse, err error) {
+	const cmd = "zos.debug.deployment.health"
+	in := args{
+		"deployment": deployment,
+	}
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, in, &health)
+	return
+}
+
+// DeploymentBatchGet gets the deployments with the given contract ids in one
+// call, contracts that are not found on the node (or not owned by the twin)
+// are left out. Up to 100 ids can be requested at once.
+func (n *NodeClient) DeploymentBatchGet(ctx context.Context, ids []uint64) (dls []gridtypes.Deployment, err error) {
+	const cmd = "zos.deployment.batch_get"
+	in := args{
+		"contract_ids": ids,
+	}
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, in, &dls)
+	return
+}
+
 // DeploymentList gets all deployments for a twin
 func (n *NodeClient) DeploymentList(ctx context.Context) (dls []gridtypes.Deployment, err error) {
 	const cmd = "zos.deployment.list"
@@ -96,8 +173,63 @@ func (n *NodeClient) DeploymentChanges(ctx context.Context, contractID uint64) (
 	return changes, nil
 }
 
-// DeploymentDelete deletes a deployment, the node will make sure to decomission all deployments
-// and set all workloads to deleted. A call to Get after delete is valid
+// DeploymentValidationState checks an already deployed deployment against its contract
+// (contract exists, node and hash match, twin verified) and returns which condition fails
+// if any. This explains why a deployment is not applied by the node.
+func (n *NodeClient) DeploymentValidationState(ctx context.Context, contractID uint64) (state pkg.ValidationState, err error) {
+	const cmd = "zos.deployment.validation_state"
+	in := args{
+		"contract_id": contractID,
+	}
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, in, &state)
+	return
+}
+
+// DeploymentSchemaVersions gets the workload data schema versions supported by the node
+// for each workload type. A workload with a schema version that is not in the list of
+// its type is rejected by the node.
+func (n *NodeClient) DeploymentSchemaVersions(ctx context.Context) (versions map[gridtypes.WorkloadType][]uint32, err error) {
+	const cmd = "zos.deployment.schema_versions"
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &versions)
+	return
+}
+
+// WorkloadHistory gets all the changes of a single workload (by name) in the
+// deployment with the given contract ID
+func (n *NodeClient) WorkloadHistory(ctx context.Context, contractID uint64, name string) (history []gridtypes.Workload, err error) {
+	const cmd = "zos.deployment.workload_history"
+	in := args{
+		"contract_id": contractID,
+		"name":        name,
+	}
+
+	if err = n.bus.Call(ctx, n.nodeTwin, cmd, in, &history); err != nil {
+		return history, err
+	}
+
+	return history, nil
+}
+
+// DeprovisionWorkload removes a single workload from the deployment with the given
+// contract ID. If other workloads depend on it (for example a vm using a network), the
+// call fails unless cascade is set, in which case the dependent workloads are removed too.
+func (n *NodeClient) DeprovisionWorkload(ctx context.Context, contractID uint64, name string, reason string, cascade bool) error {
+	const cmd = "zos.deployment.deprovision_workload"
+	in := args{
+		"contract_id": contractID,
+		"name":        name,
+		"reason":      reason,
+		"cascade":     cascade,
+	}
+
+	return n.bus.Call(ctx, n.nodeTwin, cmd, in, nil)
+}
+
+// DeploymentDelete asks the node to delete a deployment. Deletion over the api is
+// disabled on the node so this always fails, cancel the deployment contract
+// instead and the node will decommission the deployment on its own.
 func (n *NodeClient) DeploymentDelete(ctx context.Context, contractID uint64) error {
 	const cmd = "zos.deployment.delete"
 	in := args{
@@ -107,6 +239,102 @@ func (n *NodeClient) DeploymentDelete(ctx context.Context, contractID uint64) er
 	return n.bus.Call(ctx, n.nodeTwin, cmd, in, nil)
 }
 
+// VMLogs gets the latest logs of the vm with the given name in the deployment,
+// use VMLogsRange to read big logs in chunks
+func (n *NodeClient) VMLogs(ctx context.Context, contractID uint64, name string) (logs string, err error) {
+	const cmd = "zos.vm.logs"
+	in := args{
+		"contract_id": contractID,
+		"name":        name,
+	}
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, in, &logs)
+	return
+}
+
+// VMLogsRange gets a chunk of the logs of the vm with the given name in the
+// deployment. The returned chunk has the total logs size so the caller can
+// page over big logs by increasing the offset.
+func (n *NodeClient) VMLogsRange(ctx context.Context, contractID uint64, name string, offset, length int64) (chunk pkg.LogsChunk, err error) {
+	const cmd = "zos.vm.logs_range"
+	in := args{
+		"contract_id": contractID,
+		"name":        name,
+		"offset":      offset,
+		"length":      length,
+	}
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, in, &chunk)
+	return
+}
+
+// VMLogsChunk is a chunk of logs appended to a vm logs file
+type VMLogsChunk struct {
+	Data string `json:"data"`
+	// Offset where Data starts in the log file
+	Offset int64 `json:"offset"`
+	// Reset is set if the logs file was rotated or truncated, and Data
+	// is read from the start of the new file
+	Reset bool `json:"reset,omitempty"`
+}
+
+// VMLogsFollow follows the logs of the vm with the given name in the deployment
+// (in the format twin-id:contract-id) starting at offset, and calls emit with the
+// logs in order as they are written until the context is cancelled. Use the size
+// returned by VMLogsRange as offset to only get the new logs. Only the farmer twin
+// is allowed to call this.
+func (n *NodeClient) VMLogsFollow(ctx context.Context, deployment, name string, offset int64, emit func(VMLogsChunk)) error {
+	const cmd = "zos.debug.vm.logs_follow"
+
+	for {
+		in := args{
+			"deployment": deployment,
+			"workload":   name,
+			"offset":     offset,
+		}
+
+		var result struct {
+			Chunks []VMLogsChunk `json:"chunks"`
+			Next   int64         `json:"next"`
+		}
+
+		if err := n.bus.Call(ctx, n.nodeTwin, cmd, in, &result); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		for _, chunk := range result.Chunks {
+			emit(chunk)
+		}
+		offset = result.Next
+	}
+}
+
+// VMInfo is the config and live usage of a vm
+type VMInfo struct {
+	Info pkg.VMInfo `json:"info"`
+	// Counters are the live cpu, memory and devices usage of the vm, they
+	// are not set if the node can't get them, CountersError is set instead
+	Counters      *pkg.VMCounters `json:"counters,omitempty"`
+	CountersError string          `json:"counters_error,omitempty"`
+}
+
+// VMInfo gets the config and the live usage counters of the vm with the given
+// name in the deployment (in the format twin-id:contract-id). Only the farmer
+// twin is allowed to call this.
+func (n *NodeClient) VMInfo(ctx context.Context, deployment, name string) (info VMInfo, err error) {
+	const cmd = "zos.debug.vm.info"
+	in := args{
+		"deployment": deployment,
+		"workload":   name,
+	}
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, in, &info)
+	return
+}
+
 // Counters (statistics) of the node
 type Counters struct {
 	// Total system capacity
@@ -129,11 +357,35 @@ type UsersCounters struct {
 
 // GPU information
 type GPU struct {
-	ID       string `json:"id"`
-	Vendor   string `json:"vendor"`
-	Device   string `json:"device"`
-	Vram     uint64 `json:"vram"`
+	ID     string `json:"id"`
+	Vendor string `json:"vendor"`
+	Device string `json:"device"`
+	Vram   uint64 `json:"vram"`
+	// Contract using the gpu, 0 if the gpu is not allocated
 	Contract uint64 `json:"contract"`
+	// Status is either available or allocated
+	Status pkg.GPUStatus `json:"status"`
+	// Utilization is the gpu busy percent, only set if exposed by the node driver
+	Utilization *uint64 `json:"utilization,omitempty"`
+	// VramUsed is the used vram in bytes, only set if exposed by the node driver
+	VramUsed *uint64 `json:"vram_used,omitempty"`
+}
+
+// NodeHealth is the aggregated health of the node
+type NodeHealth struct {
+	// Status is one of healthy, degraded or unhealthy
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+	// Deployments is the number of deployments by their worst workload status
+	Deployments map[string]int `json:"deployments"`
+}
+
+// HealthCheck is the result of a single health check
+type HealthCheck struct {
+	Name     string                 `json:"name"`
+	OK       bool                   `json:"ok"`
+	Message  string                 `json:"message,omitempty"`
+	Evidence map[string]interface{} `json:"evidence,omitempty"`
 }
 
 // Counters returns some node statistics. Including total and available cpu, memory, storage, etc...
@@ -143,6 +395,36 @@ func (n *NodeClient) Counters(ctx context.Context) (counters Counters, err error
 	return
 }
 
+// PoolCapacity is the capacity of a storage pool
+type PoolCapacity struct {
+	Name string         `json:"name"`
+	Type pkg.DeviceType `json:"type"`
+	Size gridtypes.Unit `json:"size"`
+	Used gridtypes.Unit `json:"used"`
+	Free gridtypes.Unit `json:"free"`
+}
+
+// CapacitySummary is the node counters with the capacity that is still free
+// for new workloads
+type CapacitySummary struct {
+	Counters
+	// Pending is the capacity of the workloads accepted by the node but not
+	// provisioned yet
+	Pending gridtypes.Capacity `json:"pending"`
+	// Free is the total capacity minus the used and pending capacity
+	Free gridtypes.Capacity `json:"free"`
+	// Pools is the capacity of each storage pool
+	Pools []PoolCapacity `json:"pools"`
+}
+
+// CapacitySummary returns the node used and free capacity, the free capacity
+// already accounts for the workloads that are still being deployed
+func (n *NodeClient) CapacitySummary(ctx context.Context) (summary CapacitySummary, err error) {
+	const cmd = "zos.statistics.capacity"
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &summary)
+	return
+}
+
 // Pools returns statistics of separate pools
 func (n *NodeClient) Pools(ctx context.Context) (pools []pkg.PoolMetrics, err error) {
 	const cmd = "zos.storage.pools"
@@ -150,12 +432,51 @@ func (n *NodeClient) Pools(ctx context.Context) (pools []pkg.PoolMetrics, err er
 	return
 }
 
+// StorageReclaimOrphans deletes vm rootfs volumes that are not used by any active vm
+// and returns them. If dryRun is set the volumes are only listed. Only the farmer can
+// call this method
+func (n *NodeClient) StorageReclaimOrphans(ctx context.Context, dryRun bool) (report diagnostics.OrphanVolumes, err error) {
+	const cmd = "zos.storage.reclaim_orphans"
+	args := struct {
+		DryRun bool `json:"dry_run"`
+	}{
+		DryRun: dryRun,
+	}
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, args, &report)
+	return
+}
+
+// GPUs lists the node GPUs with their allocation status. Only GPUs with status
+// available can be used by new workloads.
 func (n *NodeClient) GPUs(ctx context.Context) (gpus []GPU, err error) {
 	const cmd = "zos.gpu.list"
 	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &gpus)
 	return
 }
 
+// GPUDrain marks the GPU unavailable for new workloads, workloads that already use it
+// are not affected. Only the farmer twin is allowed to call this.
+func (n *NodeClient) GPUDrain(ctx context.Context, id string, reason string) error {
+	const cmd = "zos.admin.gpu_drain"
+	in := args{
+		"id":     id,
+		"reason": reason,
+	}
+
+	return n.bus.Call(ctx, n.nodeTwin, cmd, in, nil)
+}
+
+// GPUUndrain makes a drained GPU available again for new workloads. Only the farmer
+// twin is allowed to call this.
+func (n *NodeClient) GPUUndrain(ctx context.Context, id string) error {
+	const cmd = "zos.admin.gpu_undrain"
+	in := args{
+		"id": id,
+	}
+
+	return n.bus.Call(ctx, n.nodeTwin, cmd, in, nil)
+}
+
 // NetworkListWGPorts return a list of all "taken" ports on the node. A new deployment
 // should be careful to use a free port for its network setup.
 func (n *NodeClient) NetworkListWGPorts(ctx context.Context) ([]uint16, error) {
@@ -213,6 +534,43 @@ func (n *NodeClient) NetworkGetPublicExitDevice(ctx context.Context) (exit ExitD
 	return
 }
 
+// NodeDeploymentsSummary gets a summary of all deployments on the node across all twins,
+// with workload counts by state and the total used capacity. Only the farmer twin
+// is allowed to call this.
+func (n *NodeClient) NodeDeploymentsSummary(ctx context.Context) (summary pkg.NodeSummary, err error) {
+	const cmd = "zos.admin.deployments_summary"
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &summary)
+	return
+}
+
+// NodeTwinsUsage gets the usage of each twin with active deployments on the node, and
+// the quota that applies to it. Only the farmer twin is allowed to call this.
+func (n *NodeClient) NodeTwinsUsage(ctx context.Context) (usage []pkg.TwinUsage, err error) {
+	const cmd = "zos.admin.twins_usage"
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &usage)
+	return
+}
+
+// NodeResyncPublicIPRules schedules re-applying the firewall rules of all active public
+// ip workloads on the node. Only the farmer twin is allowed to call this.
+func (n *NodeClient) NodeResyncPublicIPRules(ctx context.Context) error {
+	const cmd = "zos.admin.resync_public_ip_rules"
+
+	return n.bus.Call(ctx, n.nodeTwin, cmd, nil, nil)
+}
+
+// NodeHealth gets the aggregated health of the node: the result of the node wide
+// checks and the number of deployments by their worst workload status. Only the
+// farmer twin is allowed to call this.
+func (n *NodeClient) NodeHealth(ctx context.Context) (health NodeHealth, err error) {
+	const cmd = "zos.debug.node.health"
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &health)
+	return
+}
+
 // NetworkListPublicIPs list taken public IPs on the node
 func (n *NodeClient) NetworkListPublicIPs(ctx context.Context) ([]string, error) {
 	const cmd = "zos.network.list_public_ips"
@@ -240,6 +598,22 @@ func (n *NodeClient) NetworkListPrivateIPs(ctx context.Context, networkName stri
 	return result, nil
 }
 
+// NetworkWireguardStats returns the stats of the wireguard peers of one of the
+// twin networks on a light node
+func (n *NodeClient) NetworkWireguardStats(ctx context.Context, networkName string) ([]pkg.PeerStat, error) {
+	const cmd = "zos.network.wireguard_stats"
+	var result []pkg.PeerStat
+	in := args{
+		"network_name": networkName,
+	}
+
+	if err := n.bus.Call(ctx, n.nodeTwin, cmd, in, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
 // NetworkGetPublicConfig returns the current public node network configuration. A node with a
 // public config can be used as an access node for wireguard.
 func (n *NodeClient) NetworkGetPublicConfig(ctx context.Context) (cfg pkg.PublicConfig, err error) {
@@ -256,6 +630,25 @@ func (n *NodeClient) SystemGetNodeFeatures(ctx context.Context) (feat []pkg.Node
 	return
 }
 
+// SystemEffectiveConfig gets every environment setting in use by the node with
+// the source it comes from (default, kernel, zos-config or env-var). Only the
+// farmer twin is allowed to call this.
+func (n *NodeClient) SystemEffectiveConfig(ctx context.Context) (config map[string]environment.ConfigSource, err error) {
+	const cmd = "zos.admin.effective_config"
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &config)
+	return
+}
+
+// MonitorElections gets, for each farm-wide responsibility, which node the node
+// believes is currently responsible for it and why
+func (n *NodeClient) MonitorElections(ctx context.Context) (elections []pkg.Election, err error) {
+	const cmd = "zos.monitor.election"
+
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &elections)
+	return
+}
+
 func (n *NodeClient) SystemVersion(ctx context.Context) (ver Version, err error) {
 	const cmd = "zos.system.version"
 
@@ -291,3 +684,25 @@ func (n *NodeClient) SystemDiagnostics(ctx context.Context) (result diagnostics.
 	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &result)
 	return
 }
+
+// SystemClockSkew returns the difference between the node clock and the chain time
+func (n *NodeClient) SystemClockSkew(ctx context.Context) (result diagnostics.ClockSkew, err error) {
+	const cmd = "zos.system.clock_skew"
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &result)
+	return
+}
+
+// SystemUptimeStatus returns the result of the last uptime report of the node
+func (n *NodeClient) SystemUptimeStatus(ctx context.Context) (result pkg.UptimeStatus, err error) {
+	const cmd = "zos.system.uptime_status"
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &result)
+	return
+}
+
+// SystemSelfTest runs the node self test and returns a report with the result
+// of each check. A node is ready to accept workloads if all checks pass
+func (n *NodeClient) SystemSelfTest(ctx context.Context) (result diagnostics.SelfTest, err error) {
+	const cmd = "zos.system.selftest"
+	err = n.bus.Call(ctx, n.nodeTwin, cmd, nil, &result)
+	return
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/threefoldtech/tfgrid-sdk-go/rmb-sdk-go"
+)
+
+// DefaultTimeout is the default timeout of a node call if the call context
+// has no deadline
+const DefaultTimeout = 2 * time.Minute
+
+type options struct {
+	timeout time.Duration
+}
+
+// Option configures a node client created with NewNodeClientWithOptions
+type Option func(*options)
+
+// WithTimeout sets the timeout of the node calls that are made with a context
+// without a deadline. A zero timeout disables the default timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// timeoutClient is an rmb client that applies a timeout to the calls that
+// don't have a deadline
+type timeoutClient struct {
+	client  rmb.Client
+	timeout time.Duration
+}
+
+var _ rmb.Client = (*timeoutClient)(nil)
+
+func (c *timeoutClient) Call(ctx context.Context, twin uint32, fn string, data interface{}, result interface{}) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	return c.client.Call(ctx, twin, fn, data, result)
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// blockingBus is an rmb client of a node that never answers
+type blockingBus struct{}
+
+func (blockingBus) Call(ctx context.Context, twin uint32, fn string, data interface{}, result interface{}) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestNodeClientTimeout(t *testing.T) {
+	node := NewNodeClientWithOptions(10, blockingBus{}, WithTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	_, err := node.Counters(context.Background())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestNodeClientTimeoutDeadline(t *testing.T) {
+	node := NewNodeClientWithOptions(10, blockingBus{}, WithTimeout(time.Hour))
+
+	// the caller deadline is kept
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := node.Counters(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestNodeClientDefaultTimeout(t *testing.T) {
+	node := NewNodeClientWithOptions(10, blockingBus{})
+	bus, ok := node.bus.(*timeoutClient)
+	require.True(t, ok)
+	require.Equal(t, DefaultTimeout, bus.timeout)
+
+	// no timeout keeps the bus as is
+	node = NewNodeClientWithOptions(10, blockingBus{}, WithTimeout(0))
+	require.Equal(t, blockingBus{}, node.bus)
+}
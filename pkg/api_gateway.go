@@ -24,6 +24,8 @@ type SubstrateGateway interface {
 	GetNodes(farmID uint32) ([]uint32, error)
 	GetPowerTarget(nodeID uint32) (power substrate.NodePower, err error)
 	GetTwin(id uint32) (substrate.Twin, error)
+	// InvalidateTwin drops the twin from the GetTwin cache
+	InvalidateTwin(id uint32)
 	GetTwinByPubKey(pk []byte) (uint32, SubstrateError)
 	Report(consumptions []substrate.NruConsumption) (types.Hash, error)
 	SetContractConsumption(resources ...substrate.ContractResources) error
@@ -32,6 +34,25 @@ type SubstrateGateway interface {
 	UpdateNodeUptimeV2(uptime uint64, timestampHint uint64) (hash types.Hash, err error)
 	GetTime() (time.Time, error)
 	GetZosVersion() (string, error)
+	// UptimeStatus returns the result of the last uptime report
+	UptimeStatus() (UptimeStatus, error)
+	// Healthz checks if the chain is reachable right now
+	Healthz() error
+}
+
+// UptimeStatus is the state of the node uptime reporting
+type UptimeStatus struct {
+	// Uptime is the last successfully submitted uptime (in seconds)
+	Uptime uint64 `json:"uptime"`
+	// Submitted is when the last uptime was successfully submitted
+	Submitted time.Time `json:"submitted"`
+	// Hash is the transaction hash of the last submitted uptime
+	Hash string `json:"hash"`
+	// Attempted is when the last report was attempted
+	Attempted time.Time `json:"attempted"`
+	// Failed is true if the last attempt failed, Error is the reason
+	Failed bool   `json:"failed"`
+	Error  string `json:"error,omitempty"`
 }
 
 type SubstrateError struct {
@@ -65,4 +86,7 @@ const (
 	CodeAccountNotFound
 	CodeDepositFeeNotFound
 	CodeMintTransactionNotFound
+	// CodeCircuitOpen is returned without reaching the chain while
+	// the gateway circuit breaker is open
+	CodeCircuitOpen
 )
@@ -12,7 +12,8 @@ import (
 	"strings"
 )
 
-const (
+var (
+	// pciDir is a var so tests can point it to a fake sysfs tree
 	pciDir = "/sys/bus/pci/devices"
 )
 
@@ -219,6 +220,25 @@ func (p *PCI) Flag(name string) (uint64, error) {
 	return readUint64(filepath.Join(pciDir, p.Slot, name), 64)
 }
 
+// GPUUsage returns the gpu busy percent and the used vram in bytes if the
+// device driver exposes them (for example amdgpu). GPUs that are bound to
+// vfio for passthrough don't expose any, in that case nil is returned.
+func GPUUsage(p *PCI) (utilization *uint64, vramUsed *uint64) {
+	read := func(name string) *uint64 {
+		data, err := os.ReadFile(filepath.Join(pciDir, p.Slot, name))
+		if err != nil {
+			return nil
+		}
+		value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return nil
+		}
+		return &value
+	}
+
+	return read("gpu_busy_percent"), read("mem_info_vram_used")
+}
+
 func pciDeviceFromSlot(slot string) (PCI, error) {
 	const (
 		classFile           = "class"
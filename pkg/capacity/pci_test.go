@@ -2,6 +2,8 @@ package capacity
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -44,3 +46,35 @@ func TestListGPU(t *testing.T) {
 		fmt.Println(device)
 	}
 }
+
+func TestGPUUsage(t *testing.T) {
+	root := t.TempDir()
+	old := pciDir
+	pciDir = root
+	t.Cleanup(func() { pciDir = old })
+
+	// amdgpu exposes the usage
+	amd := filepath.Join(root, "0000:01:00.0")
+	require.NoError(t, os.MkdirAll(amd, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(amd, "gpu_busy_percent"), []byte("42\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(amd, "mem_info_vram_used"), []byte("1073741824\n"), 0644))
+
+	utilization, vram := GPUUsage(&PCI{Slot: "0000:01:00.0"})
+	require.NotNil(t, utilization)
+	require.NotNil(t, vram)
+	require.EqualValues(t, 42, *utilization)
+	require.EqualValues(t, 1073741824, *vram)
+
+	// vfio bound gpus don't
+	vfio := filepath.Join(root, "0000:02:00.0")
+	require.NoError(t, os.MkdirAll(vfio, 0755))
+
+	utilization, vram = GPUUsage(&PCI{Slot: "0000:02:00.0"})
+	require.Nil(t, utilization)
+	require.Nil(t, vram)
+
+	// garbage is ignored
+	require.NoError(t, os.WriteFile(filepath.Join(vfio, "gpu_busy_percent"), []byte("n/a"), 0644))
+	utilization, _ = GPUUsage(&PCI{Slot: "0000:02:00.0"})
+	require.Nil(t, utilization)
+}
@@ -2,7 +2,9 @@ package checks
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/threefoldtech/zosbase/pkg"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
 )
@@ -25,6 +27,8 @@ type CheckData struct {
 	Workload gridtypes.Workload
 	VM       func(ctx context.Context, id string) bool
 	Network  func(ctx context.Context, id zos.NetID) string
+	Disk     func(ctx context.Context, name string) (pkg.VDisk, error)
+	Volume   func(ctx context.Context, name string) (pkg.Volume, error)
 }
 
 func success(name, message string, evidence map[string]interface{}) HealthCheck {
@@ -41,6 +45,20 @@ func failure(name, message string, evidence map[string]interface{}) HealthCheck
 	return HealthCheck{Name: name, OK: false, Message: message, Evidence: evidence}
 }
 
+// runAll runs the checks in order, it stops early if the context is done
+// and reports the remaining checks as aborted.
+func runAll(ctx context.Context, checks ...func() HealthCheck) []HealthCheck {
+	results := make([]HealthCheck, 0, len(checks))
+	for _, check := range checks {
+		if err := ctx.Err(); err != nil {
+			results = append(results, failure("aborted", fmt.Sprintf("checks aborted: %v", err), nil))
+			break
+		}
+		results = append(results, check())
+	}
+	return results
+}
+
 func IsHealthy(checks []HealthCheck) bool {
 	for _, check := range checks {
 		if !check.OK {
@@ -56,6 +74,8 @@ func Run(ctx context.Context, workloadType gridtypes.WorkloadType, data *CheckDa
 		return NetworkCheckerInstance.Run(ctx, data)
 	case zos.ZMachineType, zos.ZMachineLightType:
 		return VMCheckerInstance.Run(ctx, data)
+	case zos.ZMountType, zos.VolumeType, zos.QuantumSafeFSType:
+		return StorageCheckerInstance.Run(ctx, data)
 	default:
 		return nil
 	}
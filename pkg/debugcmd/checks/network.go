@@ -39,13 +39,13 @@ func (nc *NetworkChecker) Run(ctx context.Context, data *CheckData) []HealthChec
 	nc.netCfgPath = filepath.Join(networkdVolatileDir, networksDir, netID.String())
 	nc.nrr = nr.New(pkg.Network{NetID: netID}, filepath.Join(networkdVolatileDir, myceliumKeyDir))
 
-	return []HealthCheck{
-		nc.checkConfig(),
-		nc.checkNamespace(),
-		nc.checkInterfaces(),
-		nc.checkBridge(),
-		nc.checkMycelium(),
-	}
+	return runAll(ctx,
+		nc.checkConfig,
+		nc.checkNamespace,
+		nc.checkInterfaces,
+		nc.checkBridge,
+		nc.checkMycelium,
+	)
 }
 
 func (nc *NetworkChecker) checkConfig() HealthCheck {
@@ -0,0 +1,215 @@
+package checks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+const zdbDialTimeout = 3 * time.Second
+
+// mountsFile is where the mounts of the system are listed
+var mountsFile = "/proc/mounts"
+
+// StorageChecker checks the storage workloads, it keeps no state so it's
+// safe to run concurrently
+type StorageChecker struct{}
+
+func (sc *StorageChecker) Name() string { return "storage" }
+
+func (sc *StorageChecker) Run(ctx context.Context, data *CheckData) []HealthCheck {
+	workloadID, err := gridtypes.NewWorkloadID(data.Twin, data.Contract, data.Workload.Name)
+	if err != nil {
+		return []HealthCheck{failure("storage.init", fmt.Sprintf("invalid workload ID: %v", err), nil)}
+	}
+
+	id := workloadID.String()
+	switch data.Workload.Type {
+	case zos.ZMountType:
+		return sc.runDisk(ctx, id, data)
+	case zos.VolumeType:
+		return sc.runVolume(ctx, id, data)
+	case zos.QuantumSafeFSType:
+		return sc.runQSFS(ctx, id, data)
+	}
+
+	return nil
+}
+
+func (sc *StorageChecker) runDisk(ctx context.Context, id string, data *CheckData) []HealthCheck {
+	var cfg zos.ZMount
+	if err := json.Unmarshal(data.Workload.Data, &cfg); err != nil {
+		return []HealthCheck{failure("storage.disk", fmt.Sprintf("invalid workload data: %v", err), nil)}
+	}
+
+	if data.Disk == nil {
+		return []HealthCheck{failure("storage.disk", "storage module not available", nil)}
+	}
+
+	disk, err := data.Disk(ctx, id)
+	if err != nil {
+		return []HealthCheck{failure("storage.disk", fmt.Sprintf("disk not found: %v", err), map[string]interface{}{"id": id})}
+	}
+
+	return runAll(ctx,
+		func() HealthCheck { return sc.checkFile(id, "storage.disk", disk.Path) },
+		func() HealthCheck { return sc.checkMounted("storage.disk.mount", disk.Path) },
+		func() HealthCheck {
+			return sc.checkSize(id, "storage.disk.size", cfg.Size, gridtypes.Unit(disk.Size))
+		},
+	)
+}
+
+func (sc *StorageChecker) runVolume(ctx context.Context, id string, data *CheckData) []HealthCheck {
+	var cfg zos.Volume
+	if err := json.Unmarshal(data.Workload.Data, &cfg); err != nil {
+		return []HealthCheck{failure("storage.volume", fmt.Sprintf("invalid workload data: %v", err), nil)}
+	}
+
+	if data.Volume == nil {
+		return []HealthCheck{failure("storage.volume", "storage module not available", nil)}
+	}
+
+	volume, err := data.Volume(ctx, id)
+	if err != nil {
+		return []HealthCheck{failure("storage.volume", fmt.Sprintf("volume not found: %v", err), map[string]interface{}{"id": id})}
+	}
+
+	return runAll(ctx,
+		func() HealthCheck { return sc.checkFile(id, "storage.volume", volume.Path) },
+		func() HealthCheck { return sc.checkMounted("storage.volume.mount", volume.Path) },
+		func() HealthCheck {
+			return sc.checkSize(id, "storage.volume.size", cfg.Size, volume.Usage.Size)
+		},
+	)
+}
+
+func (sc *StorageChecker) runQSFS(ctx context.Context, id string, data *CheckData) []HealthCheck {
+	var cfg zos.QuantumSafeFS
+	if err := json.Unmarshal(data.Workload.Data, &cfg); err != nil {
+		return []HealthCheck{failure("storage.qsfs", fmt.Sprintf("invalid workload data: %v", err), nil)}
+	}
+
+	var result zos.QuatumSafeFSResult
+	if err := data.Workload.Result.Unmarshal(&result); err != nil || result.Path == "" {
+		return []HealthCheck{failure("storage.qsfs", "workload has no mount path", map[string]interface{}{"id": id})}
+	}
+
+	return runAll(ctx,
+		func() HealthCheck { return sc.checkFuse(result.Path) },
+		func() HealthCheck { return sc.checkBackends(ctx, &cfg.Config) },
+	)
+}
+
+func (sc *StorageChecker) checkFile(id, name, path string) HealthCheck {
+	if _, err := os.Stat(path); err != nil {
+		return failure(name, fmt.Sprintf("path missing: %s", path), map[string]interface{}{"path": path, "id": id})
+	}
+	return success(name, "path exists", map[string]interface{}{"path": path, "id": id})
+}
+
+// checkMounted makes sure the pool holding the path is mounted, otherwise
+// the path is on the node root filesystem
+func (sc *StorageChecker) checkMounted(name, path string) HealthCheck {
+	mount, _, err := mountOf(path)
+	if err != nil {
+		return failure(name, fmt.Sprintf("failed to list mounts: %v", err), map[string]interface{}{"path": path})
+	}
+
+	if mount == "" || mount == "/" {
+		return failure(name, "storage pool is not mounted", map[string]interface{}{"path": path})
+	}
+
+	return success(name, "storage pool is mounted", map[string]interface{}{"path": path, "mountpoint": mount})
+}
+
+func (sc *StorageChecker) checkSize(id, name string, expected, actual gridtypes.Unit) HealthCheck {
+	evidence := map[string]interface{}{"expected": expected, "actual": actual, "id": id}
+	if expected != actual {
+		return failure(name, fmt.Sprintf("size mismatch: expected %d, got %d", expected, actual), evidence)
+	}
+	return success(name, "size matches", evidence)
+}
+
+func (sc *StorageChecker) checkFuse(path string) HealthCheck {
+	mount, fstype, err := mountOf(path)
+	if err != nil {
+		return failure("storage.qsfs.mount", fmt.Sprintf("failed to list mounts: %v", err), map[string]interface{}{"path": path})
+	}
+
+	evidence := map[string]interface{}{"path": path, "fstype": fstype}
+	if mount != filepath.Clean(path) || !strings.HasPrefix(fstype, "fuse") {
+		return failure("storage.qsfs.mount", "fuse mount is not live", evidence)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return failure("storage.qsfs.mount", fmt.Sprintf("fuse mount is not responding: %v", err), evidence)
+	}
+
+	return success("storage.qsfs.mount", "fuse mount is live", evidence)
+}
+
+func (sc *StorageChecker) checkBackends(ctx context.Context, cfg *zos.QuantumSafeFSConfig) HealthCheck {
+	backends := append([]zos.ZdbBackend{}, cfg.Meta.Config.Backends...)
+	for _, group := range cfg.Groups {
+		backends = append(backends, group.Backends...)
+	}
+
+	var unreachable []string
+	dialer := net.Dialer{Timeout: zdbDialTimeout}
+	for _, backend := range backends {
+		con, err := dialer.DialContext(ctx, "tcp", backend.Address)
+		if err != nil {
+			unreachable = append(unreachable, backend.Address)
+			continue
+		}
+		con.Close()
+	}
+
+	evidence := map[string]interface{}{"backends": len(backends), "unreachable": unreachable}
+	if len(unreachable) > 0 {
+		return failure("storage.qsfs.backends", fmt.Sprintf("%d of %d zdb backends unreachable", len(unreachable), len(backends)), evidence)
+	}
+
+	return success("storage.qsfs.backends", "all zdb backends reachable", evidence)
+}
+
+// mountOf returns the mountpoint that holds the path, and its filesystem type
+func mountOf(path string) (mountpoint string, fstype string, err error) {
+	file, err := os.Open(mountsFile)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	path = filepath.Clean(path)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		target := fields[1]
+		if target != "/" && target != path && !strings.HasPrefix(path, target+"/") {
+			continue
+		}
+
+		if len(target) >= len(mountpoint) {
+			mountpoint, fstype = target, fields[2]
+		}
+	}
+
+	return mountpoint, fstype, scanner.Err()
+}
+
+var StorageCheckerInstance = &StorageChecker{}
@@ -0,0 +1,146 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+func withMounts(t *testing.T, mounts ...string) {
+	path := filepath.Join(t.TempDir(), "mounts")
+	content := "rootfs / tmpfs rw 0 0\n"
+	for _, mount := range mounts {
+		content += mount + "\n"
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	old := mountsFile
+	mountsFile = path
+	t.Cleanup(func() { mountsFile = old })
+}
+
+func volumeData(t *testing.T, path string, size gridtypes.Unit) *CheckData {
+	return &CheckData{
+		Twin:     1,
+		Contract: 1,
+		Workload: gridtypes.Workload{
+			Name: "vol",
+			Type: zos.VolumeType,
+			Data: []byte(fmt.Sprintf(`{"size": %d}`, size)),
+		},
+		Volume: func(ctx context.Context, name string) (pkg.Volume, error) {
+			return pkg.Volume{Name: name, Path: path, Usage: pkg.Usage{Size: size}}, nil
+		},
+	}
+}
+
+func TestStorageCheckerVolume(t *testing.T) {
+	pool := t.TempDir()
+	path := filepath.Join(pool, "1-1-vol")
+	require.NoError(t, os.Mkdir(path, 0755))
+
+	withMounts(t, fmt.Sprintf("/dev/sda %s btrfs rw 0 0", pool))
+	results := StorageCheckerInstance.Run(context.Background(), volumeData(t, path, 10*gridtypes.Gigabyte))
+	require.Len(t, results, 3)
+	require.True(t, IsHealthy(results))
+}
+
+func TestStorageCheckerMissingMount(t *testing.T) {
+	pool := t.TempDir()
+	path := filepath.Join(pool, "1-1-vol")
+	require.NoError(t, os.Mkdir(path, 0755))
+
+	// the pool is not mounted, the volume is on the root filesystem
+	withMounts(t)
+	results := StorageCheckerInstance.Run(context.Background(), volumeData(t, path, 10*gridtypes.Gigabyte))
+	require.False(t, IsHealthy(results))
+	require.Equal(t, "storage.volume.mount", results[1].Name)
+	require.False(t, results[1].OK)
+}
+
+func TestStorageCheckerMissingDisk(t *testing.T) {
+	withMounts(t)
+	data := &CheckData{
+		Twin:     1,
+		Contract: 1,
+		Workload: gridtypes.Workload{
+			Name: "disk",
+			Type: zos.ZMountType,
+			Data: []byte(`{"size": 1024}`),
+		},
+		Disk: func(ctx context.Context, name string) (pkg.VDisk, error) {
+			return pkg.VDisk{}, fmt.Errorf("disk not found")
+		},
+	}
+
+	results := StorageCheckerInstance.Run(context.Background(), data)
+	require.Len(t, results, 1)
+	require.False(t, IsHealthy(results))
+}
+
+func TestStorageCheckerQSFSNotMounted(t *testing.T) {
+	path := t.TempDir()
+	withMounts(t)
+
+	data := &CheckData{
+		Twin:     1,
+		Contract: 1,
+		Workload: gridtypes.Workload{
+			Name: "qsfs",
+			Type: zos.QuantumSafeFSType,
+			Data: []byte(`{"cache": 1024}`),
+			Result: gridtypes.Result{
+				State: gridtypes.StateOk,
+				Data:  []byte(fmt.Sprintf(`{"path": %q}`, path)),
+			},
+		},
+	}
+
+	results := StorageCheckerInstance.Run(context.Background(), data)
+	require.False(t, IsHealthy(results))
+	require.Equal(t, "storage.qsfs.mount", results[0].Name)
+	require.False(t, results[0].OK)
+
+	withMounts(t, fmt.Sprintf("zstor %s fuse.zdbfs rw 0 0", path))
+	results = StorageCheckerInstance.Run(context.Background(), data)
+	require.True(t, IsHealthy(results))
+}
+
+func TestStorageCheckerConcurrent(t *testing.T) {
+	pool := t.TempDir()
+	withMounts(t, fmt.Sprintf("/dev/sda %s btrfs rw 0 0", pool))
+
+	const runs = 10
+	var (
+		wg  sync.WaitGroup
+		ids [runs]interface{}
+	)
+	for i := 0; i < runs; i++ {
+		path := filepath.Join(pool, fmt.Sprintf("vol%d", i))
+		require.NoError(t, os.Mkdir(path, 0755))
+
+		data := volumeData(t, path, 10*gridtypes.Gigabyte)
+		data.Twin, data.Contract = uint32(i+1), uint64(i+1)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results := StorageCheckerInstance.Run(context.Background(), data)
+			ids[i] = results[0].Evidence["id"]
+		}()
+	}
+	wg.Wait()
+
+	// each run reports its own workload
+	for i, id := range ids {
+		require.Equal(t, fmt.Sprintf("%d-%d-vol", i+1, i+1), id)
+	}
+}
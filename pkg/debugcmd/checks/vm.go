@@ -32,14 +32,16 @@ func (vc *VMChecker) Run(ctx context.Context, data *CheckData) []HealthCheck {
 	vc.vmID = workloadID.String()
 	vc.cfgPath = filepath.Join(vmdVolatileDir, workloadID.String())
 	vc.vmExists = data.VM
-
-	return []HealthCheck{
-		vc.checkConfig(),
-		vc.checkVMD(ctx),
-		vc.checkProcess(),
-		vc.checkDisks(),
-		vc.checkVirtioFS(),
-	}
+	vc.machine = nil
+
+	return runAll(ctx,
+		vc.checkConfig,
+		func() HealthCheck { return vc.checkVMD(ctx) },
+		vc.checkProcess,
+		vc.checkDisks,
+		vc.checkVirtioFS,
+		vc.checkVirtioFSD,
+	)
 }
 
 func (vc *VMChecker) loadMachine() (*vm.Machine, error) {
@@ -76,6 +78,11 @@ func (vc *VMChecker) checkProcess() HealthCheck {
 	if err != nil {
 		return failure("vm.process", fmt.Sprintf("process not found: %v", err), map[string]interface{}{"vm_id": vc.vmID})
 	}
+	// a paused vm keeps its process, it's healthy as long as the process
+	// is there
+	if machine, err := vc.loadMachine(); err == nil && machine.Paused {
+		return success("vm.process", "process running (paused)", map[string]interface{}{"vm_id": vc.vmID, "pid": ps.Pid, "paused": true})
+	}
 	return success("vm.process", "process running", map[string]interface{}{"vm_id": vc.vmID, "pid": ps.Pid})
 }
 
@@ -106,7 +113,7 @@ func (vc *VMChecker) checkVirtioFS() HealthCheck {
 	}
 
 	for i := range machine.FS {
-		sock := filepath.Join("/var/run", fmt.Sprintf("virtio-%s-%d.socket", vc.vmID, i))
+		sock := vm.FsSocketPath(vc.vmID, i)
 		if _, err := os.Stat(sock); err != nil {
 			return failure("vm.virtiofs", fmt.Sprintf("socket missing: %s", sock), map[string]interface{}{"socket": sock, "vm_id": vc.vmID})
 		}
@@ -115,6 +122,29 @@ func (vc *VMChecker) checkVirtioFS() HealthCheck {
 	return success("vm.virtiofs", "all virtiofs sockets present", map[string]interface{}{"vm_id": vc.vmID})
 }
 
+// checkVirtioFSD makes sure a virtiofsd process is serving each share, a
+// dead virtiofsd leaves a stale socket behind so the socket check is not
+// enough
+func (vc *VMChecker) checkVirtioFSD() HealthCheck {
+	machine, err := vc.loadMachine()
+	if err != nil {
+		return failure("vm.virtiofsd", fmt.Sprintf("config unavailable: %v", err), map[string]interface{}{"vm_id": vc.vmID})
+	}
+
+	shares := make([]map[string]interface{}, 0, len(machine.FS))
+	for i, fs := range machine.FS {
+		sock := vm.FsSocketPath(vc.vmID, i)
+		ps, err := vm.FindFs(sock)
+		if err != nil {
+			return failure("vm.virtiofsd", fmt.Sprintf("virtiofsd not running for share '%s'", fs.Tag), map[string]interface{}{"socket": sock, "tag": fs.Tag, "vm_id": vc.vmID})
+		}
+
+		shares = append(shares, map[string]interface{}{"tag": fs.Tag, "socket": sock, "pid": ps.Pid})
+	}
+
+	return success("vm.virtiofsd", "all virtiofsd processes running", map[string]interface{}{"vm_id": vc.vmID, "shares": shares})
+}
+
 // TODO: add cloud-console check
 
 var VMCheckerInstance = &VMChecker{}
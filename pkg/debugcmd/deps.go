@@ -17,25 +17,51 @@ type Provision interface {
 	List(ctx context.Context, twin uint32) ([]gridtypes.Deployment, error)
 	Get(ctx context.Context, twin uint32, contract uint64) (gridtypes.Deployment, error)
 	Changes(ctx context.Context, twin uint32, contract uint64) ([]gridtypes.Workload, error)
+	StartupOrder(ctx context.Context) ([]gridtypes.WorkloadType, error)
+	SetStartupOrder(ctx context.Context, types ...gridtypes.WorkloadType) error
+	BootReconcile(ctx context.Context) ([]pkg.WorkloadReconcile, error)
 }
 
 // VM is the subset of the vmd zbus interface used by debug commands.
 type VM interface {
 	Exists(ctx context.Context, id string) bool
 	Inspect(ctx context.Context, id string) (pkg.VMInfo, error)
+	Counters(ctx context.Context, id string) (pkg.VMCounters, error)
 	Logs(ctx context.Context, id string) (string, error)
 	LogsFull(ctx context.Context, id string) (string, error)
+	LogsRange(ctx context.Context, id string, offset, length int64) (pkg.LogsChunk, error)
+	LogsInfo(ctx context.Context, id string) (pkg.LogsInfo, error)
+	SetLogsRetention(ctx context.Context, id string, retention pkg.LogsRetention) error
 }
 
 // Network is the subset of the network zbus interface used by debug commands.
 type Network interface {
 	Namespace(ctx context.Context, id zos.NetID) string
+	GetPublicConfig(ctx context.Context) (pkg.PublicConfig, error)
+	GetPublicExitDevice(ctx context.Context) (pkg.ExitDevice, error)
+	GetPublicExitHistory(ctx context.Context) ([]pkg.ExitDecision, error)
+}
+
+// Storage is the subset of the storage zbus interface used by debug commands.
+type Storage interface {
+	BrokenPools(ctx context.Context) []pkg.BrokenPool
+	DiskLookup(ctx context.Context, name string) (pkg.VDisk, error)
+	VolumeLookup(ctx context.Context, name string) (pkg.Volume, error)
+}
+
+// Upgrader is the subset of the upgrader zbus interface used by debug commands.
+type Upgrader interface {
+	Hold(ctx context.Context, reason string) error
+	Release(ctx context.Context) error
+	HoldState(ctx context.Context) (pkg.UpgradeHold, error)
 }
 
 type Deps struct {
 	Provision Provision
 	VM        VM
 	Network   Network
+	Storage   Storage
+	Upgrader  Upgrader
 }
 
 // ParseDeploymentID parses a deployment identifier in the format "twin-id:contract-id"
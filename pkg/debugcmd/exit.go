@@ -0,0 +1,32 @@
+package debugcmd
+
+import (
+	"context"
+
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+type ExitHistoryResponse struct {
+	// Current is the current public exit, single or dual(<nic>)
+	Current   string             `json:"current"`
+	Decisions []pkg.ExitDecision `json:"decisions"`
+}
+
+// ExitHistory returns the current public exit of the node and the
+// history of decisions that wired it
+func ExitHistory(ctx context.Context, deps Deps) (ExitHistoryResponse, error) {
+	exit, err := deps.Network.GetPublicExitDevice(ctx)
+	if err != nil {
+		return ExitHistoryResponse{}, err
+	}
+
+	decisions, err := deps.Network.GetPublicExitHistory(ctx)
+	if err != nil {
+		return ExitHistoryResponse{}, err
+	}
+
+	return ExitHistoryResponse{
+		Current:   exit.String(),
+		Decisions: decisions,
+	}, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/threefoldtech/zosbase/pkg/debugcmd/checks"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
 )
 
 type HealthRequest struct {
@@ -18,6 +19,7 @@ type HealthStatus string
 
 const (
 	HealthHealthy   HealthStatus = "healthy"
+	HealthDegraded  HealthStatus = "degraded"
 	HealthUnhealthy HealthStatus = "unhealthy"
 )
 
@@ -41,66 +43,106 @@ func ParseHealthRequest(payload []byte) (HealthRequest, error) {
 }
 
 func Health(ctx context.Context, deps Deps, req HealthRequest) (HealthResponse, error) {
-	var twinID uint32
-	var contractID uint64
-	var err error
+	twinID, contractID, err := resolveHealthRequest(req)
+	if err != nil {
+		return HealthResponse{}, err
+	}
 
-	hasSystemProbe := req.Options != nil && req.Options["system_probe"] != nil
+	out := HealthResponse{TwinID: twinID, ContractID: contractID}
+	err = HealthStream(ctx, deps, req, func(health WorkloadHealth) {
+		out.Workloads = append(out.Workloads, health)
+	})
+	if err != nil {
+		return HealthResponse{}, err
+	}
 
-	if req.Deployment != "" {
-		twinID, contractID, err = ParseDeploymentID(req.Deployment)
-		if err != nil {
-			return HealthResponse{}, err
+	return out, nil
+}
+
+// HealthStream runs the same checks as Health, but calls emit with the
+// health of each workload as soon as it's computed instead of collecting
+// all of them first.
+func HealthStream(ctx context.Context, deps Deps, req HealthRequest, emit func(WorkloadHealth)) error {
+	twinID, contractID, err := resolveHealthRequest(req)
+	if err != nil {
+		return err
+	}
+
+	if probeCmd, ok := req.Options["system_probe"].(string); ok && probeCmd != "" {
+		checkData := &checks.CheckData{Twin: twinID, Contract: contractID}
+		allChecks := checks.NewSystemChecker(probeCmd).Run(ctx, checkData)
+		if len(allChecks) > 0 {
+			emit(newWorkloadHealth("system", "diagnostic", "system.probe", allChecks))
 		}
-	} else if !hasSystemProbe {
-		return HealthResponse{}, fmt.Errorf("deployment is required when system_probe is not specified")
 	}
 
-	out := HealthResponse{TwinID: twinID, ContractID: contractID}
+	if req.Deployment == "" {
+		return nil
+	}
 
-	if hasSystemProbe {
-		if probeCmd, ok := req.Options["system_probe"].(string); ok && probeCmd != "" {
-			checkData := &checks.CheckData{Twin: twinID, Contract: contractID}
-			allChecks := checks.NewSystemChecker(probeCmd).Run(ctx, checkData)
-			if len(allChecks) > 0 {
-				out.Workloads = append(out.Workloads, newWorkloadHealth("system", "diagnostic", "system.probe", allChecks))
-			}
-		}
+	deployment, err := deps.Provision.Get(ctx, twinID, contractID)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment: %w", err)
 	}
 
-	if req.Deployment != "" {
-		deployment, err := deps.Provision.Get(ctx, twinID, contractID)
-		if err != nil {
-			return HealthResponse{}, fmt.Errorf("failed to get deployment: %w", err)
+	for _, wl := range deployment.Workloads {
+		// the caller might have given up already, no need to check
+		// the rest of the workloads
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		for _, wl := range deployment.Workloads {
-			workloadID, err := gridtypes.NewWorkloadID(twinID, contractID, wl.Name)
-			if err != nil {
-				continue
-			}
-
-			checkData := &checks.CheckData{
-				Network:  deps.Network.Namespace,
-				VM:       deps.VM.Exists,
-				Twin:     twinID,
-				Contract: contractID,
-				Workload: wl,
-			}
-
-			allChecks := checks.Run(ctx, wl.Type, checkData)
-			if len(allChecks) > 0 {
-				out.Workloads = append(out.Workloads, newWorkloadHealth(
-					workloadID.String(),
-					string(wl.Type),
-					string(wl.Name),
-					allChecks,
-				))
-			}
+		if health, ok := workloadHealth(ctx, deps, twinID, contractID, wl); ok {
+			emit(health)
 		}
 	}
 
-	return out, nil
+	return nil
+}
+
+// workloadHealth runs the checks of the workload, ok is false if the workload
+// type has no checks
+func workloadHealth(ctx context.Context, deps Deps, twin uint32, contract uint64, wl gridtypes.Workload) (WorkloadHealth, bool) {
+	workloadID, err := gridtypes.NewWorkloadID(twin, contract, wl.Name)
+	if err != nil {
+		return WorkloadHealth{}, false
+	}
+
+	checkData := &checks.CheckData{
+		Network:  deps.Network.Namespace,
+		VM:       deps.VM.Exists,
+		Twin:     twin,
+		Contract: contract,
+		Workload: wl,
+	}
+	if deps.Storage != nil {
+		checkData.Disk = deps.Storage.DiskLookup
+		checkData.Volume = deps.Storage.VolumeLookup
+	}
+
+	allChecks := checks.Run(ctx, wl.Type, checkData)
+	if len(allChecks) == 0 {
+		return WorkloadHealth{}, false
+	}
+
+	return newWorkloadHealth(
+		workloadID.String(),
+		string(wl.Type),
+		string(wl.Name),
+		allChecks,
+	), true
+}
+
+func resolveHealthRequest(req HealthRequest) (twinID uint32, contractID uint64, err error) {
+	hasSystemProbe := req.Options != nil && req.Options["system_probe"] != nil
+
+	if req.Deployment != "" {
+		return ParseDeploymentID(req.Deployment)
+	} else if !hasSystemProbe {
+		return 0, 0, fmt.Errorf("deployment is required when system_probe is not specified")
+	}
+
+	return 0, 0, nil
 }
 
 func newWorkloadHealth(workloadID, workloadType, name string, allChecks []checks.HealthCheck) WorkloadHealth {
@@ -116,3 +158,36 @@ func newWorkloadHealth(workloadID, workloadType, name string, allChecks []checks
 		Checks:     allChecks,
 	}
 }
+
+// DriftDetector returns a function that reports a workload as drifted if any
+// of its health checks fails. It can be used as a provision.DriftDetectorFn
+// to enable automatic repair of drifted workloads.
+func DriftDetector(deps Deps) func(ctx context.Context, twin uint32, contract uint64, wl *gridtypes.Workload) bool {
+	return func(ctx context.Context, twin uint32, contract uint64, wl *gridtypes.Workload) bool {
+		if ctx.Err() != nil {
+			// we can't tell, better not to repair
+			return false
+		}
+
+		switch wl.Type {
+		case zos.ZMountType, zos.VolumeType, zos.QuantumSafeFSType:
+			// storage workloads are not repaired, provisioning them
+			// again can't fix a missing or unreachable storage
+			return false
+		}
+
+		checkData := &checks.CheckData{
+			Network:  deps.Network.Namespace,
+			VM:       deps.VM.Exists,
+			Twin:     twin,
+			Contract: contract,
+			Workload: *wl,
+		}
+
+		allChecks := checks.Run(ctx, wl.Type, checkData)
+		if ctx.Err() != nil {
+			return false
+		}
+		return len(allChecks) > 0 && !checks.IsHealthy(allChecks)
+	}
+}
@@ -0,0 +1,171 @@
+package debugcmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// healthSessionTimeout is the max time a health session can run
+	healthSessionTimeout = 10 * time.Minute
+	// healthSessionTTL is how long the results of a finished session are kept
+	healthSessionTTL = 5 * time.Minute
+	// healthSessionsMax is the max number of sessions running at the same time
+	healthSessionsMax = 8
+)
+
+type HealthStartResponse struct {
+	Session string `json:"session"`
+}
+
+type HealthPollRequest struct {
+	Session string `json:"session"`
+	// Offset is the number of workloads already received by the caller
+	Offset int `json:"offset"`
+}
+
+type HealthPollResponse struct {
+	TwinID     uint32 `json:"twin_id"`
+	ContractID uint64 `json:"contract_id"`
+	// Workloads computed since offset
+	Workloads []WorkloadHealth `json:"workloads"`
+	// Next is the offset to use on the next poll
+	Next int `json:"next"`
+	// Done is set once all checks are complete
+	Done  bool   `json:"done"`
+	Error string `json:"error,omitempty"`
+}
+
+func ParseHealthPollRequest(payload []byte) (HealthPollRequest, error) {
+	var req HealthPollRequest
+	return req, json.Unmarshal(payload, &req)
+}
+
+type healthSession struct {
+	twinID     uint32
+	contractID uint64
+	workloads  []WorkloadHealth
+	done       bool
+	err        error
+	finished   time.Time
+}
+
+// HealthSessions runs health checks in the background so the results can
+// be polled progressively while the (possibly slow) checks are running.
+type HealthSessions struct {
+	m        sync.Mutex
+	sessions map[string]*healthSession
+}
+
+func NewHealthSessions() *HealthSessions {
+	return &HealthSessions{
+		sessions: make(map[string]*healthSession),
+	}
+}
+
+// Start starts running the health checks of the request in the background
+// and returns the session to poll for results.
+func (s *HealthSessions) Start(deps Deps, req HealthRequest) (HealthStartResponse, error) {
+	twinID, contractID, err := resolveHealthRequest(req)
+	if err != nil {
+		return HealthStartResponse{}, err
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return HealthStartResponse{}, err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.prune(time.Now())
+	running := 0
+	for _, session := range s.sessions {
+		if !session.done {
+			running++
+		}
+	}
+	if running >= healthSessionsMax {
+		return HealthStartResponse{}, fmt.Errorf("too many running health sessions, try again later")
+	}
+
+	session := &healthSession{twinID: twinID, contractID: contractID}
+	s.sessions[id] = session
+
+	go s.run(session, deps, req)
+
+	return HealthStartResponse{Session: id}, nil
+}
+
+func (s *HealthSessions) run(session *healthSession, deps Deps, req HealthRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), healthSessionTimeout)
+	defer cancel()
+
+	err := HealthStream(ctx, deps, req, func(health WorkloadHealth) {
+		s.m.Lock()
+		defer s.m.Unlock()
+		session.workloads = append(session.workloads, health)
+	})
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	session.done = true
+	session.err = err
+	session.finished = time.Now()
+}
+
+// Poll returns the workloads health computed since the request offset
+func (s *HealthSessions) Poll(req HealthPollRequest) (HealthPollResponse, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.prune(time.Now())
+	session, ok := s.sessions[req.Session]
+	if !ok {
+		return HealthPollResponse{}, fmt.Errorf("health session '%s' not found or expired", req.Session)
+	}
+
+	offset := req.Offset
+	if offset < 0 || offset > len(session.workloads) {
+		offset = len(session.workloads)
+	}
+
+	out := HealthPollResponse{
+		TwinID:     session.twinID,
+		ContractID: session.contractID,
+		Workloads:  append([]WorkloadHealth{}, session.workloads[offset:]...),
+		Next:       len(session.workloads),
+		Done:       session.done,
+	}
+
+	if session.err != nil {
+		out.Error = session.err.Error()
+	}
+
+	return out, nil
+}
+
+// prune drops finished sessions older than the ttl, must be called
+// with the lock held
+func (s *HealthSessions) prune(now time.Time) {
+	for id, session := range s.sessions {
+		if session.done && now.Sub(session.finished) > healthSessionTTL {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func newSessionID() (string, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	return hex.EncodeToString(buf[:]), nil
+}
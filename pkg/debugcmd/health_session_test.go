@@ -0,0 +1,95 @@
+package debugcmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+func TestHealthSessions(t *testing.T) {
+	deps := Deps{
+		Provision: &provisionStub{
+			deployments: map[uint32][]gridtypes.Deployment{
+				1: {testDeployment(1, 1,
+					testWorkload("disk1", zos.ZMountType, gridtypes.StateOk),
+					testWorkload("disk2", zos.ZMountType, gridtypes.StateOk),
+				)},
+			},
+		},
+		VM:      &vmStub{},
+		Network: &networkStub{},
+	}
+
+	sessions := NewHealthSessions()
+
+	_, err := sessions.Start(deps, HealthRequest{})
+	require.Error(t, err)
+
+	started, err := sessions.Start(deps, HealthRequest{Deployment: "1:1"})
+	require.NoError(t, err)
+	require.NotEmpty(t, started.Session)
+
+	var poll HealthPollResponse
+	require.Eventually(t, func() bool {
+		poll, err = sessions.Poll(HealthPollRequest{Session: started.Session})
+		require.NoError(t, err)
+		return poll.Done
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, uint32(1), poll.TwinID)
+	require.Equal(t, uint64(1), poll.ContractID)
+	require.Empty(t, poll.Error)
+	require.Equal(t, 2, poll.Next)
+	require.Len(t, poll.Workloads, 2)
+	require.Equal(t, "disk1", poll.Workloads[0].Name)
+	require.Equal(t, "disk2", poll.Workloads[1].Name)
+
+	// only the workloads after the offset are returned
+	poll, err = sessions.Poll(HealthPollRequest{Session: started.Session, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, poll.Workloads, 1)
+	require.Equal(t, "disk2", poll.Workloads[0].Name)
+
+	// an offset out of range returns nothing new
+	poll, err = sessions.Poll(HealthPollRequest{Session: started.Session, Offset: 10})
+	require.NoError(t, err)
+	require.Empty(t, poll.Workloads)
+	require.Equal(t, 2, poll.Next)
+
+	_, err = sessions.Poll(HealthPollRequest{Session: "unknown"})
+	require.Error(t, err)
+
+	// finished sessions expire after the ttl
+	sessions.m.Lock()
+	sessions.sessions[started.Session].finished = time.Now().Add(-healthSessionTTL - time.Second)
+	sessions.m.Unlock()
+
+	_, err = sessions.Poll(HealthPollRequest{Session: started.Session})
+	require.Error(t, err)
+}
+
+func TestHealthSessionsMax(t *testing.T) {
+	deps := Deps{Provision: &provisionStub{}}
+	sessions := NewHealthSessions()
+
+	sessions.m.Lock()
+	for i := 0; i < healthSessionsMax; i++ {
+		sessions.sessions[string(rune('a'+i))] = &healthSession{}
+	}
+	sessions.m.Unlock()
+
+	_, err := sessions.Start(deps, HealthRequest{Deployment: "1:1"})
+	require.Error(t, err)
+
+	// a finished session doesn't count
+	sessions.m.Lock()
+	sessions.sessions["a"].done = true
+	sessions.sessions["a"].finished = time.Now()
+	sessions.m.Unlock()
+
+	_, err = sessions.Start(deps, HealthRequest{Deployment: "1:1"})
+	require.NoError(t, err)
+}
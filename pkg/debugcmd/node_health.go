@@ -0,0 +1,245 @@
+package debugcmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/threefoldtech/zosbase/pkg/debugcmd/checks"
+	"github.com/threefoldtech/zosbase/pkg/diagnostics"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/zinit"
+	"github.com/vishvananda/netlink"
+)
+
+// NodeHealthResponse is the aggregated health of the node
+type NodeHealthResponse struct {
+	// Status is unhealthy if one of the critical node checks failed, degraded
+	// if a non critical check failed or some deployments are not healthy,
+	// healthy otherwise.
+	Status HealthStatus         `json:"status"`
+	Checks []checks.HealthCheck `json:"checks"`
+	// Deployments is the number of deployments by their health status
+	Deployments map[HealthStatus]int `json:"deployments"`
+}
+
+type nodeCheck struct {
+	name string
+	// critical checks make the node unhealthy if they fail, other checks
+	// only degrade it
+	critical bool
+	run      func(ctx context.Context, deps Deps) checks.HealthCheck
+}
+
+// nodeChecks are the node wide checks run by NodeHealth
+var nodeChecks = []nodeCheck{
+	{name: "node.public_config", critical: false, run: checkPublicConfig},
+	{name: "node.default_gateway", critical: true, run: checkDefaultGateway},
+	{name: "node.services", critical: true, run: checkCoreServices},
+	{name: "node.pools", critical: true, run: checkPools},
+}
+
+// NodeHealth runs the node wide checks, and the health checks of all the
+// workloads of all deployments on the node.
+func NodeHealth(ctx context.Context, deps Deps) (NodeHealthResponse, error) {
+	out := NodeHealthResponse{
+		Status: HealthHealthy,
+		Deployments: map[HealthStatus]int{
+			HealthHealthy:   0,
+			HealthDegraded:  0,
+			HealthUnhealthy: 0,
+		},
+	}
+
+	for _, check := range nodeChecks {
+		result := runNodeCheck(ctx, deps, check)
+		out.Checks = append(out.Checks, result)
+		if result.OK {
+			continue
+		}
+
+		if check.critical {
+			out.Status = HealthUnhealthy
+		} else if out.Status == HealthHealthy {
+			out.Status = HealthDegraded
+		}
+	}
+
+	twins, err := deps.Provision.ListTwins(ctx)
+	if err != nil {
+		return NodeHealthResponse{}, fmt.Errorf("failed to list twins: %w", err)
+	}
+
+	for _, twin := range twins {
+		deployments, err := deps.Provision.List(ctx, twin)
+		if err != nil {
+			return NodeHealthResponse{}, fmt.Errorf("failed to list deployments of twin '%d': %w", twin, err)
+		}
+
+		for _, deployment := range deployments {
+			if err := ctx.Err(); err != nil {
+				return NodeHealthResponse{}, err
+			}
+
+			out.Deployments[deploymentHealth(ctx, deps, deployment)]++
+		}
+	}
+
+	failing := out.Deployments[HealthUnhealthy] + out.Deployments[HealthDegraded]
+	if failing > 0 && out.Status == HealthHealthy {
+		out.Status = HealthDegraded
+	}
+
+	return out, nil
+}
+
+// deploymentHealth is unhealthy if all the deployment workloads fail their
+// checks, degraded if only some of them do, and healthy otherwise. Workloads
+// without checks are considered healthy.
+func deploymentHealth(ctx context.Context, deps Deps, deployment gridtypes.Deployment) HealthStatus {
+	healthy, unhealthy := 0, 0
+	for _, wl := range deployment.Workloads {
+		if wl.Result.State.IsAny(gridtypes.StateDeleted) {
+			continue
+		}
+
+		health, ok := workloadHealth(ctx, deps, deployment.TwinID, deployment.ContractID, wl)
+		if ok && health.Status == HealthUnhealthy {
+			unhealthy++
+		} else {
+			healthy++
+		}
+	}
+
+	switch {
+	case unhealthy == 0:
+		return HealthHealthy
+	case healthy == 0:
+		return HealthUnhealthy
+	default:
+		return HealthDegraded
+	}
+}
+
+func runNodeCheck(ctx context.Context, deps Deps, check nodeCheck) (result checks.HealthCheck) {
+	// zbus stubs panic if the module is not reachable
+	defer func() {
+		if r := recover(); r != nil {
+			result = checks.HealthCheck{
+				Name:     check.name,
+				Message:  fmt.Sprintf("check failed: %v", r),
+				Evidence: map[string]interface{}{},
+			}
+		}
+	}()
+
+	result = check.run(ctx, deps)
+	result.Name = check.name
+	if result.Evidence == nil {
+		result.Evidence = map[string]interface{}{}
+	}
+	return result
+}
+
+func checkPublicConfig(ctx context.Context, deps Deps) checks.HealthCheck {
+	cfg, err := deps.Network.GetPublicConfig(ctx)
+	if err != nil {
+		return checks.HealthCheck{Message: fmt.Sprintf("no public config: %v", err)}
+	}
+
+	if cfg.IsEmpty() {
+		return checks.HealthCheck{Message: "public config is empty"}
+	}
+
+	return checks.HealthCheck{
+		OK:      true,
+		Message: "public config is set",
+		Evidence: map[string]interface{}{
+			"type":   cfg.Type,
+			"ipv4":   cfg.IPv4.String(),
+			"ipv6":   cfg.IPv6.String(),
+			"domain": cfg.Domain,
+		},
+	}
+}
+
+func checkDefaultGateway(ctx context.Context, deps Deps) checks.HealthCheck {
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return checks.HealthCheck{Message: fmt.Sprintf("failed to list routes: %v", err)}
+	}
+
+	for _, route := range routes {
+		if (route.Dst != nil && !route.Dst.IP.Equal(net.IPv4zero)) || route.Gw == nil {
+			continue
+		}
+
+		evidence := map[string]interface{}{"gateway": route.Gw.String()}
+		neighs, err := netlink.NeighList(route.LinkIndex, netlink.FAMILY_V4)
+		if err != nil {
+			return checks.HealthCheck{Message: fmt.Sprintf("failed to list neighbors: %v", err), Evidence: evidence}
+		}
+
+		for _, neigh := range neighs {
+			if !neigh.IP.Equal(route.Gw) {
+				continue
+			}
+
+			if neigh.State&(netlink.NUD_FAILED|netlink.NUD_INCOMPLETE) != 0 {
+				return checks.HealthCheck{Message: "default gateway is not reachable", Evidence: evidence}
+			}
+
+			return checks.HealthCheck{OK: true, Message: "default gateway is reachable", Evidence: evidence}
+		}
+
+		// no neighbor entry yet, the gateway was not used recently
+		return checks.HealthCheck{OK: true, Message: "default gateway is set", Evidence: evidence}
+	}
+
+	return checks.HealthCheck{Message: "no default gateway"}
+}
+
+func checkCoreServices(ctx context.Context, deps Deps) checks.HealthCheck {
+	cl := zinit.Default()
+	evidence := map[string]interface{}{}
+	ok := true
+	for _, service := range diagnostics.CoreServices {
+		status, err := cl.Status(service)
+		if err != nil {
+			evidence[service] = err.Error()
+			ok = false
+			continue
+		}
+
+		evidence[service] = status.State.String()
+		if !status.State.Is(zinit.ServiceStateRunning) {
+			ok = false
+		}
+	}
+
+	if !ok {
+		return checks.HealthCheck{Message: "some core services are not running", Evidence: evidence}
+	}
+
+	return checks.HealthCheck{OK: true, Message: "all core services are running", Evidence: evidence}
+}
+
+func checkPools(ctx context.Context, deps Deps) checks.HealthCheck {
+	if deps.Storage == nil {
+		return checks.HealthCheck{Message: "storage is not available"}
+	}
+
+	broken := deps.Storage.BrokenPools(ctx)
+	if len(broken) > 0 {
+		evidence := map[string]interface{}{}
+		for _, pool := range broken {
+			evidence[pool.Label] = fmt.Sprint(pool.Err)
+		}
+		return checks.HealthCheck{
+			Message:  fmt.Sprintf("%d pools are broken", len(broken)),
+			Evidence: evidence,
+		}
+	}
+
+	return checks.HealthCheck{OK: true, Message: "all pools are healthy"}
+}
@@ -0,0 +1,177 @@
+package debugcmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/debugcmd/checks"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+type provisionStub struct {
+	Provision
+	deployments map[uint32][]gridtypes.Deployment
+}
+
+func (p *provisionStub) ListTwins(ctx context.Context) ([]uint32, error) {
+	var twins []uint32
+	for twin := range p.deployments {
+		twins = append(twins, twin)
+	}
+	return twins, nil
+}
+
+func (p *provisionStub) List(ctx context.Context, twin uint32) ([]gridtypes.Deployment, error) {
+	return p.deployments[twin], nil
+}
+
+func (p *provisionStub) Get(ctx context.Context, twin uint32, contract uint64) (gridtypes.Deployment, error) {
+	for _, deployment := range p.deployments[twin] {
+		if deployment.ContractID == contract {
+			return deployment, nil
+		}
+	}
+	return gridtypes.Deployment{}, fmt.Errorf("deployment not found")
+}
+
+type vmStub struct {
+	VM
+}
+
+type networkStub struct {
+	Network
+}
+
+type storageStub struct {
+	Storage
+}
+
+func (s *storageStub) DiskLookup(ctx context.Context, name string) (pkg.VDisk, error) {
+	return pkg.VDisk{}, fmt.Errorf("disk '%s' not found", name)
+}
+
+func withNodeChecks(t *testing.T, ok ...bool) {
+	old := nodeChecks
+	nodeChecks = nil
+	for i, result := range ok {
+		result := result
+		nodeChecks = append(nodeChecks, nodeCheck{
+			name:     fmt.Sprintf("check.%d", i),
+			critical: i == 0,
+			run: func(ctx context.Context, deps Deps) checks.HealthCheck {
+				return checks.HealthCheck{OK: result}
+			},
+		})
+	}
+	t.Cleanup(func() { nodeChecks = old })
+}
+
+func testDeployment(twin uint32, contract uint64, workloads ...gridtypes.Workload) gridtypes.Deployment {
+	return gridtypes.Deployment{TwinID: twin, ContractID: contract, Workloads: workloads}
+}
+
+func testWorkload(name string, typ gridtypes.WorkloadType, state gridtypes.ResultState) gridtypes.Workload {
+	return gridtypes.Workload{
+		Name:   gridtypes.Name(name),
+		Type:   typ,
+		Data:   []byte(`{"size": 1024}`),
+		Result: gridtypes.Result{State: state},
+	}
+}
+
+func testHealthDeps() Deps {
+	return Deps{
+		Provision: &provisionStub{
+			deployments: map[uint32][]gridtypes.Deployment{
+				1: {
+					testDeployment(1, 1, testWorkload("db", zos.ZDBType, gridtypes.StateOk)),
+					testDeployment(1, 2,
+						testWorkload("db", zos.ZDBType, gridtypes.StateOk),
+						testWorkload("disk", zos.ZMountType, gridtypes.StateOk),
+					),
+				},
+				2: {
+					// deleted workloads are not checked
+					testDeployment(2, 3, testWorkload("disk", zos.ZMountType, gridtypes.StateDeleted)),
+				},
+			},
+		},
+		VM:      &vmStub{},
+		Network: &networkStub{},
+		Storage: &storageStub{},
+	}
+}
+
+func TestNodeHealth(t *testing.T) {
+	withNodeChecks(t, true, true)
+
+	health, err := NodeHealth(context.Background(), testHealthDeps())
+	require.NoError(t, err)
+	require.Len(t, health.Checks, 2)
+	require.Equal(t, "check.0", health.Checks[0].Name)
+	require.Equal(t, 2, health.Deployments[HealthHealthy])
+	require.Equal(t, 1, health.Deployments[HealthDegraded])
+	require.Equal(t, 0, health.Deployments[HealthUnhealthy])
+	require.Equal(t, HealthDegraded, health.Status)
+}
+
+func TestDeploymentHealth(t *testing.T) {
+	deps := testHealthDeps()
+	ok := testWorkload("db", zos.ZDBType, gridtypes.StateOk)
+	failing := testWorkload("disk", zos.ZMountType, gridtypes.StateOk)
+	deleted := testWorkload("old", zos.ZMountType, gridtypes.StateDeleted)
+
+	cases := []struct {
+		name      string
+		workloads []gridtypes.Workload
+		status    HealthStatus
+	}{
+		{name: "none failing", workloads: []gridtypes.Workload{ok, deleted}, status: HealthHealthy},
+		{name: "some failing", workloads: []gridtypes.Workload{ok, failing}, status: HealthDegraded},
+		{name: "all failing", workloads: []gridtypes.Workload{failing, deleted}, status: HealthUnhealthy},
+		{name: "empty", status: HealthHealthy},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			status := deploymentHealth(context.Background(), deps, testDeployment(1, 1, c.workloads...))
+			require.Equal(t, c.status, status)
+		})
+	}
+}
+
+func TestNodeHealthChecks(t *testing.T) {
+	deps := Deps{Provision: &provisionStub{}}
+
+	withNodeChecks(t, true, true)
+	health, err := NodeHealth(context.Background(), deps)
+	require.NoError(t, err)
+	require.Equal(t, HealthHealthy, health.Status)
+
+	withNodeChecks(t, true, false)
+	health, err = NodeHealth(context.Background(), deps)
+	require.NoError(t, err)
+	require.Equal(t, HealthDegraded, health.Status)
+
+	withNodeChecks(t, false, true)
+	health, err = NodeHealth(context.Background(), deps)
+	require.NoError(t, err)
+	require.Equal(t, HealthUnhealthy, health.Status)
+}
+
+func TestNodeHealthCheckPanic(t *testing.T) {
+	result := runNodeCheck(context.Background(), Deps{}, nodeCheck{
+		name: "node.panic",
+		run: func(ctx context.Context, deps Deps) checks.HealthCheck {
+			panic("module not reachable")
+		},
+	})
+
+	require.Equal(t, "node.panic", result.Name)
+	require.False(t, result.OK)
+	require.Contains(t, result.Message, "module not reachable")
+}
@@ -0,0 +1,55 @@
+package debugcmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+type SetOrderRequest struct {
+	Types []string `json:"types"` // types to install first, in order
+}
+
+type OrderResponse struct {
+	Order []string `json:"order"`
+}
+
+func ParseSetOrderRequest(payload []byte) (SetOrderRequest, error) {
+	var req SetOrderRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// Order returns the current engine startup order
+func Order(ctx context.Context, deps Deps) (OrderResponse, error) {
+	order, err := deps.Provision.StartupOrder(ctx)
+	if err != nil {
+		return OrderResponse{}, err
+	}
+
+	resp := OrderResponse{Order: make([]string, 0, len(order))}
+	for _, typ := range order {
+		resp.Order = append(resp.Order, typ.String())
+	}
+
+	return resp, nil
+}
+
+// SetOrder changes the engine startup order. The new order is only
+// applied by the engine starting from the next job.
+func SetOrder(ctx context.Context, deps Deps, req SetOrderRequest) error {
+	if len(req.Types) == 0 {
+		return fmt.Errorf("types is required")
+	}
+
+	types := make([]gridtypes.WorkloadType, 0, len(req.Types))
+	for _, typ := range req.Types {
+		types = append(types, gridtypes.WorkloadType(typ))
+	}
+
+	return deps.Provision.SetStartupOrder(ctx, types...)
+}
@@ -0,0 +1,31 @@
+package debugcmd
+
+import (
+	"context"
+
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+type ReconcileResponse struct {
+	// Summary is the number of workloads per outcome
+	Summary   map[pkg.ReconcileOutcome]int `json:"summary"`
+	Workloads []pkg.WorkloadReconcile      `json:"workloads"`
+}
+
+// Reconcile returns what the engine did with each stored workload on boot
+func Reconcile(ctx context.Context, deps Deps) (ReconcileResponse, error) {
+	workloads, err := deps.Provision.BootReconcile(ctx)
+	if err != nil {
+		return ReconcileResponse{}, err
+	}
+
+	resp := ReconcileResponse{
+		Summary:   make(map[pkg.ReconcileOutcome]int),
+		Workloads: workloads,
+	}
+	for _, wl := range workloads {
+		resp.Summary[wl.Outcome]++
+	}
+
+	return resp, nil
+}
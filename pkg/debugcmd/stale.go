@@ -0,0 +1,84 @@
+package debugcmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zosbase/pkg/network"
+)
+
+type StaleNetworksResponse struct {
+	// Stale network ids with a stored config but no active network workload
+	Stale []string `json:"stale"`
+	// Removed network ids, only set on cleanup
+	Removed []string `json:"removed,omitempty"`
+}
+
+// StaleNetworkConfigs lists the stored network config files that have no
+// corresponding active network workload on the node
+func StaleNetworkConfigs(ctx context.Context, deps Deps) (StaleNetworksResponse, error) {
+	stale, err := staleNetworks(ctx, deps)
+	if err != nil {
+		return StaleNetworksResponse{}, err
+	}
+
+	out := StaleNetworksResponse{Stale: []string{}}
+	for _, id := range stale {
+		out.Stale = append(out.Stale, id.String())
+	}
+
+	return out, nil
+}
+
+// CleanupStaleNetworkConfigs removes the stored network config files that
+// have no corresponding active network workload on the node
+func CleanupStaleNetworkConfigs(ctx context.Context, deps Deps) (StaleNetworksResponse, error) {
+	stale, err := staleNetworks(ctx, deps)
+	if err != nil {
+		return StaleNetworksResponse{}, err
+	}
+
+	out := StaleNetworksResponse{Stale: []string{}, Removed: []string{}}
+	for _, id := range stale {
+		out.Stale = append(out.Stale, id.String())
+		if err := network.RemoveNetworkConfig(id); err != nil {
+			return out, err
+		}
+		out.Removed = append(out.Removed, id.String())
+	}
+
+	return out, nil
+}
+
+func staleNetworks(ctx context.Context, deps Deps) ([]zos.NetID, error) {
+	twins, err := deps.Provision.ListTwins(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list twins: %w", err)
+	}
+
+	// any failure to list deployments aborts the check, otherwise configs
+	// of networks that are still in use are reported as stale
+	active := make(map[zos.NetID]struct{})
+	for _, twin := range twins {
+		deployments, err := deps.Provision.List(ctx, twin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list deployments of twin %d: %w", twin, err)
+		}
+
+		for _, deployment := range deployments {
+			for _, wl := range deployment.Workloads {
+				if wl.Type != zos.NetworkType && wl.Type != zos.NetworkLightType {
+					continue
+				}
+				if wl.Result.State == gridtypes.StateDeleted {
+					continue
+				}
+				active[zos.NetworkID(twin, wl.Name)] = struct{}{}
+			}
+		}
+	}
+
+	return network.StaleNetworkConfigs(active)
+}
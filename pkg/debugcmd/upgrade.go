@@ -0,0 +1,40 @@
+package debugcmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+type UpgradeHoldRequest struct {
+	Reason string `json:"reason"` // why upgrades are held
+}
+
+func ParseUpgradeHoldRequest(payload []byte) (UpgradeHoldRequest, error) {
+	var req UpgradeHoldRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// UpgradeHoldState returns the current upgrade hold
+func UpgradeHoldState(ctx context.Context, deps Deps) (pkg.UpgradeHold, error) {
+	return deps.Upgrader.HoldState(ctx)
+}
+
+// UpgradeHold stops the node from applying any update until released
+func UpgradeHold(ctx context.Context, deps Deps, req UpgradeHoldRequest) error {
+	if len(req.Reason) == 0 {
+		return fmt.Errorf("reason is required")
+	}
+
+	return deps.Upgrader.Hold(ctx, req.Reason)
+}
+
+// UpgradeRelease clears the upgrade hold
+func UpgradeRelease(ctx context.Context, deps Deps) error {
+	return deps.Upgrader.Release(ctx)
+}
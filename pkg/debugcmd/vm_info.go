@@ -0,0 +1,52 @@
+package debugcmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+type VMInfoRequest struct {
+	Deployment string `json:"deployment"` // Format: "twin-id:contract-id"
+	Workload   string `json:"workload"`   // Workload name
+}
+
+type VMInfoResponse struct {
+	Info pkg.VMInfo `json:"info"`
+	// Counters are the live usage of the vm, they are not set if the
+	// counters are not available
+	Counters *pkg.VMCounters `json:"counters,omitempty"`
+	// CountersError is why the counters are not available
+	CountersError string `json:"counters_error,omitempty"`
+}
+
+func ParseVMInfoRequest(payload []byte) (VMInfoRequest, error) {
+	var req VMInfoRequest
+	return req, json.Unmarshal(payload, &req)
+}
+
+// VMInfo returns the config and the live usage counters of a vm workload
+func VMInfo(ctx context.Context, deps Deps, req VMInfoRequest) (VMInfoResponse, error) {
+	vmID, err := resolveVM(ctx, deps, req.Deployment, req.Workload)
+	if err != nil {
+		return VMInfoResponse{}, err
+	}
+
+	info, err := deps.VM.Inspect(ctx, vmID)
+	if err != nil {
+		return VMInfoResponse{}, fmt.Errorf("failed to inspect vm: %w", err)
+	}
+
+	out := VMInfoResponse{Info: info}
+	// the vm is still useful to inspect without its counters
+	counters, err := deps.VM.Counters(ctx, vmID)
+	if err != nil {
+		out.CountersError = err.Error()
+	} else {
+		out.Counters = &counters
+	}
+
+	return out, nil
+}
@@ -0,0 +1,54 @@
+package debugcmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+type infoVMStub struct {
+	VM
+	counters error
+}
+
+func (v *infoVMStub) Inspect(ctx context.Context, id string) (pkg.VMInfo, error) {
+	return pkg.VMInfo{CPU: 2, Memory: 1024}, nil
+}
+
+func (v *infoVMStub) Counters(ctx context.Context, id string) (pkg.VMCounters, error) {
+	if v.counters != nil {
+		return pkg.VMCounters{}, v.counters
+	}
+	return pkg.VMCounters{CPUTime: 1000, MemoryRSS: 2048}, nil
+}
+
+func TestVMInfo(t *testing.T) {
+	vm := &infoVMStub{}
+	deps := Deps{
+		Provision: &provisionStub{
+			deployments: map[uint32][]gridtypes.Deployment{
+				1: {testDeployment(1, 1, testWorkload("vm", zos.ZMachineType, gridtypes.StateOk))},
+			},
+		},
+		VM: vm,
+	}
+	req := VMInfoRequest{Deployment: "1:1", Workload: "vm"}
+
+	info, err := VMInfo(context.Background(), deps, req)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), info.Info.CPU)
+	require.Equal(t, &pkg.VMCounters{CPUTime: 1000, MemoryRSS: 2048}, info.Counters)
+	require.Empty(t, info.CountersError)
+
+	// the info is still returned without the counters
+	vm.counters = fmt.Errorf("machine counters are not supported")
+	info, err = VMInfo(context.Background(), deps, req)
+	require.NoError(t, err)
+	require.Nil(t, info.Counters)
+	require.Equal(t, "machine counters are not supported", info.CountersError)
+}
@@ -0,0 +1,91 @@
+package debugcmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+type VMLogsRequest struct {
+	Deployment string `json:"deployment"` // Format: "twin-id:contract-id"
+	Workload   string `json:"workload"`   // Workload name
+}
+
+type VMLogsRetentionRequest struct {
+	Deployment string `json:"deployment"` // Format: "twin-id:contract-id"
+	Workload   string `json:"workload"`   // Workload name
+	// MaxSize and TailSize in bytes, both set to 0 restores the default retention
+	MaxSize  uint64 `json:"max_size"`
+	TailSize uint64 `json:"tail_size"`
+}
+
+func ParseVMLogsRequest(payload []byte) (VMLogsRequest, error) {
+	var req VMLogsRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+func ParseVMLogsRetentionRequest(payload []byte) (VMLogsRetentionRequest, error) {
+	var req VMLogsRetentionRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// VMLogsInfo returns the log files of a vm workload and their retention
+func VMLogsInfo(ctx context.Context, deps Deps, req VMLogsRequest) (pkg.LogsInfo, error) {
+	vmID, err := resolveVM(ctx, deps, req.Deployment, req.Workload)
+	if err != nil {
+		return pkg.LogsInfo{}, err
+	}
+
+	return deps.VM.LogsInfo(ctx, vmID)
+}
+
+// VMLogsRetentionSet sets the logs retention of a vm workload
+func VMLogsRetentionSet(ctx context.Context, deps Deps, req VMLogsRetentionRequest) error {
+	vmID, err := resolveVM(ctx, deps, req.Deployment, req.Workload)
+	if err != nil {
+		return err
+	}
+
+	return deps.VM.SetLogsRetention(ctx, vmID, pkg.LogsRetention{
+		MaxSize:  req.MaxSize,
+		TailSize: req.TailSize,
+	})
+}
+
+// resolveVM returns the vm id of a zmachine workload
+func resolveVM(ctx context.Context, deps Deps, deploymentID, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("workload name is required")
+	}
+
+	twinID, contractID, err := ParseDeploymentID(deploymentID)
+	if err != nil {
+		return "", err
+	}
+
+	deployment, err := deps.Provision.Get(ctx, twinID, contractID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	workload, err := deployment.Get(gridtypes.Name(name))
+	if err != nil {
+		return "", fmt.Errorf("workload '%s' not found in deployment", name)
+	}
+
+	if workload.Type != zos.ZMachineType && workload.Type != zos.ZMachineLightType {
+		return "", fmt.Errorf("workload '%s' is not a vm", name)
+	}
+
+	return workload.ID.String(), nil
+}
@@ -0,0 +1,172 @@
+package debugcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// logsFollowWait is the default time a follow call waits for new logs
+	logsFollowWait = 20 * time.Second
+	// logsFollowMaxWait is the max time a follow call can wait for new logs
+	logsFollowMaxWait = 60 * time.Second
+)
+
+// logsFollowInterval is how often the logs file is checked for new data
+var logsFollowInterval = time.Second
+
+type VMLogsFollowRequest struct {
+	Deployment string `json:"deployment"` // Format: "twin-id:contract-id"
+	Workload   string `json:"workload"`   // Workload name
+	// Offset in the logs file to follow from, use the Next of the
+	// previous response to continue following
+	Offset int64 `json:"offset"`
+	// Wait is the max number of seconds to wait for new logs
+	Wait uint `json:"wait"`
+}
+
+// VMLogsChunk is a sanitized chunk of logs appended to the vm logs file
+type VMLogsChunk struct {
+	Data string `json:"data"`
+	// Offset where Data starts in the log file
+	Offset int64 `json:"offset"`
+	// Reset is set if the logs file was rotated or truncated, and Data
+	// is read from the start of the new file
+	Reset bool `json:"reset,omitempty"`
+}
+
+type VMLogsFollowResponse struct {
+	Chunks []VMLogsChunk `json:"chunks"`
+	// Next is the offset to use on the next follow call
+	Next int64 `json:"next"`
+}
+
+func ParseVMLogsFollowRequest(payload []byte) (VMLogsFollowRequest, error) {
+	var req VMLogsFollowRequest
+	return req, json.Unmarshal(payload, &req)
+}
+
+// VMLogsFollow waits for logs to be appended to the vm logs file after the
+// request offset and returns them. It returns as soon as new logs are available,
+// or with no chunks once the wait time has passed, in which case the caller can
+// simply call again with the same offset.
+func VMLogsFollow(ctx context.Context, deps Deps, req VMLogsFollowRequest) (VMLogsFollowResponse, error) {
+	wait := logsFollowWait
+	if req.Wait > 0 {
+		wait = time.Duration(req.Wait) * time.Second
+	}
+	if wait > logsFollowMaxWait {
+		wait = logsFollowMaxWait
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	out := VMLogsFollowResponse{Next: req.Offset}
+	next, err := VMLogsStream(ctx, deps, req.Deployment, req.Workload, req.Offset, func(chunk VMLogsChunk) {
+		out.Chunks = append(out.Chunks, chunk)
+		// we got something, no need to wait for more
+		cancel()
+	})
+	out.Next = next
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return VMLogsFollowResponse{}, err
+	}
+
+	return out, nil
+}
+
+// VMLogsStream follows the logs of a vm workload starting at offset, and calls
+// emit with each sanitized chunk appended to the logs in order until the context
+// is cancelled. If the logs file is rotated or truncated the logs are followed from
+// the start of the new file. It returns the offset to continue following from.
+func VMLogsStream(ctx context.Context, deps Deps, deployment, workload string, offset int64, emit func(VMLogsChunk)) (int64, error) {
+	vmID, err := resolveVM(ctx, deps, deployment, workload)
+	if err != nil {
+		return offset, err
+	}
+
+	ticker := time.NewTicker(logsFollowInterval)
+	defer ticker.Stop()
+
+	reset := false
+	for {
+		// read all the available logs before waiting again
+		for {
+			if err := ctx.Err(); err != nil {
+				return offset, err
+			}
+
+			chunk, err := deps.VM.LogsRange(ctx, vmID, offset, 0)
+			if err != nil {
+				return offset, err
+			}
+
+			if chunk.Size < offset {
+				// the file was rotated (or truncated), we need to read
+				// the new file from the start
+				offset = 0
+				reset = true
+				continue
+			}
+
+			data := completeLogs(chunk.Data)
+			if len(data) == 0 {
+				break
+			}
+
+			emit(VMLogsChunk{
+				Data:   SanitizeLogs(data),
+				Offset: chunk.Offset,
+				Reset:  reset,
+			})
+			offset = chunk.Offset + int64(len(data))
+			reset = false
+		}
+
+		select {
+		case <-ctx.Done():
+			return offset, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// completeLogs drops a trailing incomplete utf8 sequence or a trailing \r from
+// the data so they are not split over 2 chunks, they will be part of the
+// next chunk once the rest of the data is written.
+func completeLogs(data string) string {
+	if strings.HasSuffix(data, "\r") {
+		data = data[:len(data)-1]
+	}
+
+	// a utf8 sequence is at most utf8.UTFMax bytes long
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		b := data[len(data)-i]
+		if b < utf8.RuneSelf {
+			// ascii, nothing is cut
+			break
+		}
+
+		if utf8.RuneStart(b) {
+			if !utf8.FullRuneInString(data[len(data)-i:]) {
+				data = data[:len(data)-i]
+			}
+			break
+		}
+	}
+
+	return data
+}
+
+// SanitizeLogs makes the logs safe to send as a json string. NUL bytes are
+// dropped, invalid utf8 is replaced and line endings are normalized.
+func SanitizeLogs(data string) string {
+	data = strings.ReplaceAll(data, "\x00", "")
+	data = strings.ToValidUTF8(data, string(utf8.RuneError))
+	return strings.ReplaceAll(data, "\r\n", "\n")
+}
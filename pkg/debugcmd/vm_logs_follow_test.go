@@ -0,0 +1,165 @@
+package debugcmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+// logsVMStub reads the logs of all vms from the same file
+type logsVMStub struct {
+	VM
+	path string
+}
+
+func (v *logsVMStub) LogsRange(ctx context.Context, id string, offset, length int64) (pkg.LogsChunk, error) {
+	data, err := os.ReadFile(v.path)
+	if os.IsNotExist(err) {
+		return pkg.LogsChunk{}, nil
+	} else if err != nil {
+		return pkg.LogsChunk{}, err
+	}
+
+	size := int64(len(data))
+	if offset >= size {
+		return pkg.LogsChunk{Offset: size, Size: size}, nil
+	}
+
+	return pkg.LogsChunk{Data: string(data[offset:]), Offset: offset, Size: size}, nil
+}
+
+func withLogsFollowInterval(t *testing.T) {
+	old := logsFollowInterval
+	logsFollowInterval = 10 * time.Millisecond
+	t.Cleanup(func() { logsFollowInterval = old })
+}
+
+func testLogsDeps(path string) Deps {
+	return Deps{
+		Provision: &provisionStub{
+			deployments: map[uint32][]gridtypes.Deployment{
+				1: {testDeployment(1, 1, testWorkload("vm", zos.ZMachineType, gridtypes.StateOk))},
+			},
+		},
+		VM: &logsVMStub{path: path},
+	}
+}
+
+func appendLogs(t *testing.T, path, data string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+}
+
+func TestVMLogsStream(t *testing.T) {
+	withLogsFollowInterval(t)
+	path := filepath.Join(t.TempDir(), "vm.log")
+	appendLogs(t, path, "booting\r\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lines := []string{"line 1\r\n", "line 2\x00\n", "line \xe2\x82", "\xac3\n"}
+	go func() {
+		for _, line := range lines {
+			time.Sleep(20 * time.Millisecond)
+			appendLogs(t, path, line)
+		}
+	}()
+
+	var chunks []VMLogsChunk
+	var logs strings.Builder
+	next, err := VMLogsStream(ctx, testLogsDeps(path), "1:1", "vm", 0, func(chunk VMLogsChunk) {
+		chunks = append(chunks, chunk)
+		logs.WriteString(chunk.Data)
+		if strings.HasSuffix(logs.String(), "line €3\n") {
+			cancel()
+		}
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, "booting\nline 1\nline 2\nline €3\n", logs.String())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, info.Size(), next)
+
+	// chunks are in order and contiguous
+	require.EqualValues(t, 0, chunks[0].Offset)
+	for i := 1; i < len(chunks); i++ {
+		require.Greater(t, chunks[i].Offset, chunks[i-1].Offset)
+		require.False(t, chunks[i].Reset)
+	}
+}
+
+func TestVMLogsStreamRotated(t *testing.T) {
+	withLogsFollowInterval(t)
+	path := filepath.Join(t.TempDir(), "vm.log")
+	appendLogs(t, path, "old logs that are rotated\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var chunks []VMLogsChunk
+	_, err := VMLogsStream(ctx, testLogsDeps(path), "1:1", "vm", 0, func(chunk VMLogsChunk) {
+		chunks = append(chunks, chunk)
+		if len(chunks) == 1 {
+			require.NoError(t, os.Remove(path))
+			appendLogs(t, path, "new\n")
+			return
+		}
+		cancel()
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Len(t, chunks, 2)
+	require.False(t, chunks[0].Reset)
+	require.True(t, chunks[1].Reset)
+	require.EqualValues(t, 0, chunks[1].Offset)
+	require.Equal(t, "new\n", chunks[1].Data)
+}
+
+func TestVMLogsFollow(t *testing.T) {
+	withLogsFollowInterval(t)
+	path := filepath.Join(t.TempDir(), "vm.log")
+	appendLogs(t, path, "hello\n")
+	deps := testLogsDeps(path)
+
+	resp, err := VMLogsFollow(context.Background(), deps, VMLogsFollowRequest{Deployment: "1:1", Workload: "vm"})
+	require.NoError(t, err)
+	require.Len(t, resp.Chunks, 1)
+	require.Equal(t, "hello\n", resp.Chunks[0].Data)
+	require.EqualValues(t, 6, resp.Next)
+
+	// nothing new, it returns once the wait is over
+	resp, err = VMLogsFollow(context.Background(), deps, VMLogsFollowRequest{Deployment: "1:1", Workload: "vm", Offset: resp.Next, Wait: 1})
+	require.NoError(t, err)
+	require.Empty(t, resp.Chunks)
+	require.EqualValues(t, 6, resp.Next)
+
+	_, err = VMLogsFollow(context.Background(), deps, VMLogsFollowRequest{Deployment: "1:1", Workload: "missing"})
+	require.Error(t, err)
+}
+
+func TestCompleteLogs(t *testing.T) {
+	require.Equal(t, "abc", completeLogs("abc"))
+	require.Equal(t, "abc", completeLogs("abc\r"))
+	require.Equal(t, "abc€", completeLogs("abc€"))
+	require.Equal(t, "abc", completeLogs("abc\xe2\x82"))
+	require.Equal(t, "abc", completeLogs("abc\xe2"))
+	require.Equal(t, "", completeLogs(""))
+}
+
+func TestSanitizeLogs(t *testing.T) {
+	require.Equal(t, "a\nb\n", SanitizeLogs("a\r\nb\x00\n"))
+	require.Equal(t, "a�b", SanitizeLogs("a\xffb"))
+}
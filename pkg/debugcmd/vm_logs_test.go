@@ -0,0 +1,62 @@
+package debugcmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+type retentionVMStub struct {
+	VM
+	retention map[string]pkg.LogsRetention
+}
+
+func (v *retentionVMStub) LogsInfo(ctx context.Context, id string) (pkg.LogsInfo, error) {
+	return pkg.LogsInfo{Path: id, Retention: v.retention[id]}, nil
+}
+
+func (v *retentionVMStub) SetLogsRetention(ctx context.Context, id string, retention pkg.LogsRetention) error {
+	v.retention[id] = retention
+	return nil
+}
+
+func TestVMLogsRetention(t *testing.T) {
+	vm := &retentionVMStub{retention: make(map[string]pkg.LogsRetention)}
+	deps := Deps{
+		Provision: &provisionStub{
+			deployments: map[uint32][]gridtypes.Deployment{
+				1: {testDeployment(1, 1,
+					testWorkload("vm", zos.ZMachineType, gridtypes.StateOk),
+					testWorkload("disk", zos.ZMountType, gridtypes.StateOk),
+				)},
+			},
+		},
+		VM: vm,
+	}
+
+	err := VMLogsRetentionSet(context.Background(), deps, VMLogsRetentionRequest{
+		Deployment: "1:1",
+		Workload:   "vm",
+		MaxSize:    16 << 20,
+		TailSize:   2 << 20,
+	})
+	require.NoError(t, err)
+
+	info, err := VMLogsInfo(context.Background(), deps, VMLogsRequest{Deployment: "1:1", Workload: "vm"})
+	require.NoError(t, err)
+	require.Equal(t, "1-1-vm", info.Path)
+	require.Equal(t, pkg.LogsRetention{MaxSize: 16 << 20, TailSize: 2 << 20}, info.Retention)
+
+	_, err = VMLogsInfo(context.Background(), deps, VMLogsRequest{Deployment: "1:1"})
+	require.Error(t, err)
+
+	_, err = VMLogsInfo(context.Background(), deps, VMLogsRequest{Deployment: "1:1", Workload: "missing"})
+	require.Error(t, err)
+
+	_, err = VMLogsInfo(context.Background(), deps, VMLogsRequest{Deployment: "1:1", Workload: "disk"})
+	require.ErrorContains(t, err, "is not a vm")
+}
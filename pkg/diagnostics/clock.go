@@ -0,0 +1,56 @@
+package diagnostics
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zosbase/pkg/stubs"
+)
+
+// MaxClockSkew is the maximum accepted difference between the node
+// clock and the chain time. A bigger skew usually means ntp is not
+// working and can cause failures in uptime reports and signatures
+// validation.
+const MaxClockSkew = 30 * time.Second
+
+// ClockSkew is the difference between the node clock and the chain time
+type ClockSkew struct {
+	// NodeTime is the local node time
+	NodeTime time.Time `json:"node_time"`
+	// ChainTime is the time as reported by the chain
+	ChainTime time.Time `json:"chain_time"`
+	// Skew is node time - chain time in seconds. A positive value means
+	// the node clock is ahead of the chain.
+	Skew float64 `json:"skew"`
+	// Threshold is the max accepted skew in seconds
+	Threshold float64 `json:"threshold"`
+	// Ok is set to false if skew exceeds the threshold
+	Ok bool `json:"ok"`
+}
+
+func newClockSkew(node, chain time.Time) ClockSkew {
+	skew := node.Sub(chain)
+	return ClockSkew{
+		NodeTime:  node,
+		ChainTime: chain,
+		Skew:      skew.Seconds(),
+		Threshold: MaxClockSkew.Seconds(),
+		Ok:        math.Abs(float64(skew)) <= float64(MaxClockSkew),
+	}
+}
+
+// GetClockSkew compares the node local clock to the chain time
+func (m *DiagnosticsManager) GetClockSkew(ctx context.Context) (ClockSkew, error) {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	gw := stubs.NewSubstrateGatewayStub(m.zbusClient)
+	chain, err := gw.GetTime(ctx)
+	if err != nil {
+		return ClockSkew{}, errors.Wrap(err, "failed to get chain time")
+	}
+
+	return newClockSkew(time.Now(), chain), nil
+}
@@ -3,13 +3,18 @@ package diagnostics
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/stretchr/testify/require"
 	"github.com/threefoldtech/zbus"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
 	"github.com/threefoldtech/zosbase/pkg/mocks"
+	"github.com/vmihailenco/msgpack"
 	"go.uber.org/mock/gomock"
 )
 
@@ -143,3 +148,100 @@ func TestIsHealthy(t *testing.T) {
 		})
 	}
 }
+
+func TestClockSkew(t *testing.T) {
+	chain := time.Now()
+
+	skew := newClockSkew(chain.Add(5*time.Second), chain)
+	require.True(t, skew.Ok)
+	require.Equal(t, float64(5), skew.Skew)
+
+	skew = newClockSkew(chain.Add(-2*time.Minute), chain)
+	require.False(t, skew.Ok)
+	require.Equal(t, float64(-120), skew.Skew)
+}
+
+func TestOrphanRootfsVolumes(t *testing.T) {
+	volumes := []pkg.Volume{
+		{Name: "rootfs:1-2-vm"},
+		{Name: "rootfs:1-3-vm"},
+		{Name: "1-2-disk"},
+	}
+	active := map[string]struct{}{"1-2-vm": {}}
+
+	orphans := orphanRootfsVolumes(volumes, active)
+	require.Len(t, orphans, 1)
+	require.Equal(t, "rootfs:1-3-vm", orphans[0].Name)
+}
+
+func TestCheckPublicSetup(t *testing.T) {
+	oldLight := isLight
+	isLight = func() bool { return false }
+	t.Cleanup(func() { isLight = oldLight })
+
+	_, ipv4, _ := net.ParseCIDR("185.69.166.10/24")
+	tests := []struct {
+		name   string
+		status pkg.ExitLinkStatus
+		config pkg.PublicConfig
+		err    string
+	}{
+		{
+			name:   "no public namespace",
+			status: pkg.ExitLinkStatus{Link: "eth0", Carrier: true},
+		},
+		{
+			name:   "no uplink",
+			status: pkg.ExitLinkStatus{},
+			err:    "public bridge has no uplink",
+		},
+		{
+			name:   "uplink down",
+			status: pkg.ExitLinkStatus{Link: "eth0"},
+			err:    "public exit link 'eth0' is down",
+		},
+		{
+			name:   "public namespace without ip",
+			status: pkg.ExitLinkStatus{Link: "eth0", Carrier: true, Public: true, GlobalIPv6: true},
+			err:    "public namespace has no public ip",
+		},
+		{
+			name:   "public namespace",
+			status: pkg.ExitLinkStatus{Link: "eth0", Carrier: true, Public: true, GlobalIPv6: true},
+			config: pkg.PublicConfig{IPv4: gridtypes.NewIPNet(*ipv4)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := mocks.NewMockClient(ctrl)
+			client.EXPECT().
+				RequestContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, module string, object zbus.ObjectID, method string, args ...interface{}) (*zbus.Response, error) {
+					var value interface{}
+					switch method {
+					case "GetPublicExitStatus":
+						value = tt.status
+					case "GetPublicConfig":
+						value = tt.config
+					default:
+						return nil, fmt.Errorf("unexpected call to %s", method)
+					}
+
+					data, err := msgpack.Marshal(value)
+					require.NoError(t, err)
+					return zbus.NewResponse("", zbus.Output{Data: data}, ""), nil
+				}).
+				AnyTimes()
+
+			manager := &DiagnosticsManager{zbusClient: client}
+			err := manager.checkPublicSetup(context.Background())
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package diagnostics
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zosbase/pkg/stubs"
+)
+
+const rootfsPrefix = "rootfs:"
+
+// OrphanVolume is a vm rootfs volume that has no active vm workload
+type OrphanVolume struct {
+	Name  string         `json:"name"`
+	Size  gridtypes.Unit `json:"size"`
+	Error string         `json:"error,omitempty"`
+}
+
+// OrphanVolumes is the report of reclaiming orphaned rootfs volumes
+type OrphanVolumes struct {
+	DryRun  bool           `json:"dry_run"`
+	Volumes []OrphanVolume `json:"volumes"`
+	// Reclaimed is the total size of the deleted volumes
+	Reclaimed gridtypes.Unit `json:"reclaimed"`
+}
+
+// ReclaimOrphanVolumes finds the vm rootfs volumes that have no corresponding
+// active vm workload, which can be left behind by failed deprovisions, and
+// deletes them. If dryRun is set the volumes are only listed.
+func (m *DiagnosticsManager) ReclaimOrphanVolumes(ctx context.Context, dryRun bool) (OrphanVolumes, error) {
+	storage := stubs.NewStorageModuleStub(m.zbusClient)
+	vmd := stubs.NewVMModuleStub(m.zbusClient)
+
+	active, err := m.activeMachines(ctx)
+	if err != nil {
+		return OrphanVolumes{}, errors.Wrap(err, "failed to list active vms")
+	}
+
+	volumes, err := storage.VolumeList(ctx)
+	if err != nil {
+		return OrphanVolumes{}, errors.Wrap(err, "failed to list volumes")
+	}
+
+	report := OrphanVolumes{DryRun: dryRun, Volumes: []OrphanVolume{}}
+	for _, volume := range orphanRootfsVolumes(volumes, active) {
+		id := strings.TrimPrefix(volume.Name, rootfsPrefix)
+		if vmd.Exists(ctx, id) {
+			// the vm is still running, better be safe
+			continue
+		}
+
+		orphan := OrphanVolume{Name: volume.Name, Size: volume.Usage.Size}
+		if !dryRun {
+			if err := storage.VolumeDelete(ctx, volume.Name); err != nil {
+				log.Error().Err(err).Str("name", volume.Name).Msg("failed to delete orphan rootfs volume")
+				orphan.Error = err.Error()
+			} else {
+				log.Info().Str("name", volume.Name).Msg("orphan rootfs volume deleted")
+				report.Reclaimed += volume.Usage.Size
+			}
+		}
+
+		report.Volumes = append(report.Volumes, orphan)
+	}
+
+	return report, nil
+}
+
+// activeMachines returns the ids of all the vm workloads that are not deleted
+func (m *DiagnosticsManager) activeMachines(ctx context.Context) (map[string]struct{}, error) {
+	provision := stubs.NewProvisionStub(m.zbusClient)
+
+	twins, err := provision.ListTwins(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make(map[string]struct{})
+	for _, twin := range twins {
+		deployments, err := provision.List(ctx, twin)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, deployment := range deployments {
+			for _, wl := range deployment.ByType(zos.ZMachineType, zos.ZMachineLightType) {
+				if wl.Result.State == gridtypes.StateDeleted {
+					continue
+				}
+				active[wl.ID.String()] = struct{}{}
+			}
+		}
+	}
+
+	return active, nil
+}
+
+// orphanRootfsVolumes returns the rootfs volumes that are not used by any of the active vms
+func orphanRootfsVolumes(volumes []pkg.Volume, active map[string]struct{}) []pkg.Volume {
+	var orphans []pkg.Volume
+	for _, volume := range volumes {
+		if !strings.HasPrefix(volume.Name, rootfsPrefix) {
+			continue
+		}
+
+		if _, ok := active[strings.TrimPrefix(volume.Name, rootfsPrefix)]; ok {
+			continue
+		}
+
+		orphans = append(orphans, volume)
+	}
+
+	return orphans
+}
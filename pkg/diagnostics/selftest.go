@@ -0,0 +1,179 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/threefoldtech/zosbase/pkg/kernel"
+	"github.com/threefoldtech/zosbase/pkg/stubs"
+	"github.com/threefoldtech/zosbase/pkg/zinit"
+)
+
+// CoreServices are the zinit services that must be running
+// before the node can accept workloads
+var CoreServices = []string{
+	"redis",
+	"storaged",
+	"networkd",
+	"flistd",
+	"identityd",
+	"noded",
+	"provisiond",
+	"vmd",
+}
+
+// isLight reports if the node runs zos light, it's a var so tests can
+// change it
+var isLight = func() bool {
+	return kernel.GetParams().IsLight()
+}
+
+// SelfTestCheck is the result of a single self test check
+type SelfTestCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// SelfTest is the full report of the node self test
+type SelfTest struct {
+	// OK is set to true only if all checks pass
+	OK     bool            `json:"ok"`
+	Checks []SelfTestCheck `json:"checks"`
+}
+
+// RunSelfTest runs all node self checks and returns a pass/fail report. A node
+// should not be considered ready for workloads unless all checks pass.
+func (m *DiagnosticsManager) RunSelfTest(ctx context.Context) SelfTest {
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) error
+	}{
+		{"storage.pools", m.checkStoragePools},
+		{"network.connectivity", m.checkNetwork},
+		{"network.public", m.checkPublicSetup},
+		{"substrate.reachable", m.checkSubstrate},
+		{"zinit.services", m.checkServices},
+		{"flist.responsive", m.checkFlist},
+	}
+
+	report := SelfTest{OK: true}
+	for _, c := range checks {
+		result := SelfTestCheck{Name: c.name, OK: true}
+		if err := safeCheck(ctx, c.check); err != nil {
+			result.OK = false
+			result.Message = err.Error()
+			report.OK = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report
+}
+
+// safeCheck runs check with a timeout and recovers zbus stubs panics
+func safeCheck(ctx context.Context, check func(ctx context.Context) error) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("check failed: %v", r)
+		}
+	}()
+
+	return check(ctx)
+}
+
+func (m *DiagnosticsManager) checkStoragePools(ctx context.Context) error {
+	storage := stubs.NewStorageModuleStub(m.zbusClient)
+	if broken := storage.BrokenPools(ctx); len(broken) > 0 {
+		return fmt.Errorf("found %d broken pools, first: %s", len(broken), broken[0].Label)
+	}
+
+	if _, err := storage.Metrics(ctx); err != nil {
+		return fmt.Errorf("failed to get pools metrics: %w", err)
+	}
+
+	return nil
+}
+
+func (m *DiagnosticsManager) checkNetwork(ctx context.Context) error {
+	if !m.isHealthy() {
+		return fmt.Errorf("network health check is failing")
+	}
+
+	return nil
+}
+
+// checkPublicSetup makes sure the public bridge has an uplink that is up, and
+// if the node has a public namespace that it has a public ip
+func (m *DiagnosticsManager) checkPublicSetup(ctx context.Context) error {
+	if isLight() {
+		// the light network module has no public exit to check
+		return nil
+	}
+
+	network := stubs.NewNetworkerStub(m.zbusClient)
+	status, err := network.GetPublicExitStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get public exit status: %w", err)
+	}
+
+	if status.Link == "" {
+		return fmt.Errorf("public bridge has no uplink")
+	}
+
+	if !status.Up() {
+		return fmt.Errorf("public exit link '%s' is down", status.Link)
+	}
+
+	if !status.Public {
+		return nil
+	}
+
+	cfg, err := network.GetPublicConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get public config: %w", err)
+	}
+
+	if cfg.IPv4.IP == nil && cfg.IPv6.IP == nil {
+		return fmt.Errorf("public namespace has no public ip")
+	}
+
+	return nil
+}
+
+func (m *DiagnosticsManager) checkSubstrate(ctx context.Context) error {
+	skew, err := m.GetClockSkew(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !skew.Ok {
+		return fmt.Errorf("node clock skew of %.0fs exceeds %.0fs", skew.Skew, skew.Threshold)
+	}
+
+	return nil
+}
+
+func (m *DiagnosticsManager) checkServices(ctx context.Context) error {
+	cl := zinit.Default()
+	for _, service := range CoreServices {
+		status, err := cl.Status(service)
+		if err != nil {
+			return fmt.Errorf("failed to get service '%s' status: %w", service, err)
+		}
+
+		if !status.State.Is(zinit.ServiceStateRunning) {
+			return fmt.Errorf("service '%s' is not running: %s", service, status.State)
+		}
+	}
+
+	return nil
+}
+
+func (m *DiagnosticsManager) checkFlist(ctx context.Context) error {
+	status := m.getModuleStatus(ctx, "flist")
+	return status.Err
+}
@@ -51,9 +51,11 @@ type Config struct {
 	ActivationURL []string `json:"activation_urls"`
 	GraphQL       []string `json:"graphql_urls"`
 	KycURL        string   `json:"kyc_url"`
+	KycURLs       []string `json:"kyc_urls"`
 	RegistrarURL  string   `json:"registrar_url"`
 	BinRepo       string   `json:"bin_repo"`
 	GeoipURLs     []string `json:"geoip_urls"`
+	StunURLs      []string `json:"stun_urls"`
 
 	HubURL   []string `json:"hub_urls"`
 	V4HubURL []string `json:"v4hub_urls"`
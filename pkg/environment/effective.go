@@ -0,0 +1,73 @@
+package environment
+
+import (
+	"reflect"
+
+	"github.com/threefoldtech/zosbase/pkg/kernel"
+)
+
+// Source is where the effective value of a setting comes from
+type Source string
+
+const (
+	// SourceDefault the value is the default of the run mode
+	SourceDefault Source = "default"
+	// SourceKernel the value is set by a kernel param
+	SourceKernel Source = "kernel"
+	// SourceZosConfig the value is set by the zos-config of the run mode
+	SourceZosConfig Source = "zos-config"
+	// SourceEnvVar the value is overridden by an environment variable
+	SourceEnvVar Source = "env-var"
+)
+
+// ConfigSource is the effective value of a setting and where it comes from
+type ConfigSource struct {
+	Value  interface{} `json:"value"`
+	Source Source      `json:"source"`
+}
+
+// redacted fields are never returned as is by EffectiveConfig
+var redacted = map[string]struct{}{
+	"FarmSecret": {},
+}
+
+// EffectiveConfig returns every setting of the running environment with
+// the value in use and the source that won (default, kernel param,
+// zos-config or environment variable).
+func EffectiveConfig() (map[string]ConfigSource, error) {
+	env, sources, err := resolveEnvironment(kernel.GetParams())
+	if err != nil {
+		return nil, err
+	}
+
+	return effectiveConfig(env, sources), nil
+}
+
+func effectiveConfig(env Environment, sources map[string]Source) map[string]ConfigSource {
+	config := make(map[string]ConfigSource)
+
+	value := reflect.ValueOf(env)
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Name
+		field := value.Field(i)
+
+		var v interface{}
+		if field.Kind() != reflect.Ptr || !field.IsNil() {
+			v = reflect.Indirect(field).Interface()
+		}
+
+		if _, ok := redacted[name]; ok && !field.IsZero() {
+			v = "<redacted>"
+		}
+
+		source, ok := sources[name]
+		if !ok {
+			source = SourceDefault
+		}
+
+		config[name] = ConfigSource{Value: v, Source: source}
+	}
+
+	return config
+}
@@ -2,10 +2,13 @@ package environment
 
 import (
 	"net/http"
+	"net/url"
 	"os"
 	"slices"
 	"strconv"
+	"strings"
 
+	"github.com/pion/stun"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
@@ -34,6 +37,16 @@ var defaultGeoipURLs = []string{
 	"https://03.geoip.grid.tf/",
 }
 
+// DefaultStunURLs are the servers used to find the real public ip of the node
+// if not set by the zos-config
+var DefaultStunURLs = []string{
+	"stun:stun1.l.google.com:19302",
+	"stun:stun2.l.google.com:19302",
+	"stun:stun3.l.google.com:19302",
+	"stun:stun4.l.google.com:19302",
+	"stun:stun.l.google.com:19302",
+}
+
 // PubMac specify how the mac address of the public nic
 // (in case of public-config) is calculated
 type PubMac string
@@ -72,8 +85,14 @@ type Environment struct {
 	ActivationURL []string
 	GraphQL       []string
 	GeoipURLs     []string
-	KycURL        string
-	RegistrarURL  string
+	// StunURLs are the servers used to find the real public ip of the node,
+	// either STUN servers (stun:host:port) or http ip echo services
+	StunURLs []string
+	// KycURL is the primary kyc service url, it is always
+	// the first of KycURLs
+	KycURL       string
+	KycURLs      []string
+	RegistrarURL string
 
 	// private vlan to join
 	// if set, zos will use this as its priv vlan
@@ -151,8 +170,10 @@ var (
 			"https://graphql.02.dev.grid.tf/graphql",
 		},
 		KycURL:       "https://kyc.dev.grid.tf",
+		KycURLs:      []string{"https://kyc.dev.grid.tf"},
 		RegistrarURL: "http://registrar.dev4.grid.tf",
 		GeoipURLs:    defaultGeoipURLs,
+		StunURLs:     DefaultStunURLs,
 	}
 
 	envTest = Environment{
@@ -178,8 +199,10 @@ var (
 			"https://graphql.02.test.grid.tf/graphql",
 		},
 		KycURL:       "https://kyc.test.grid.tf",
+		KycURLs:      []string{"https://kyc.test.grid.tf"},
 		RegistrarURL: "http://registrar.test4.grid.tf",
 		GeoipURLs:    defaultGeoipURLs,
+		StunURLs:     DefaultStunURLs,
 	}
 
 	envQA = Environment{
@@ -205,8 +228,10 @@ var (
 			"https://graphql.02.qa.grid.tf/graphql",
 		},
 		KycURL:       "https://kyc.qa.grid.tf",
+		KycURLs:      []string{"https://kyc.qa.grid.tf"},
 		RegistrarURL: "https://registrar.qa4.grid.tf",
 		GeoipURLs:    defaultGeoipURLs,
+		StunURLs:     DefaultStunURLs,
 	}
 
 	envProd = Environment{
@@ -239,8 +264,10 @@ var (
 			"https://graphql.grid.threefold.me/graphql",
 		},
 		KycURL:       "https://kyc.threefold.me",
+		KycURLs:      []string{"https://kyc.threefold.me"},
 		RegistrarURL: "https://registrar.prod4.threefold.me",
 		GeoipURLs:    defaultGeoipURLs,
+		StunURLs:     DefaultStunURLs,
 	}
 )
 
@@ -302,14 +329,28 @@ func GetSubstrate() (substrate.Manager, error) {
 }
 
 func getEnvironmentFromParams(params kernel.Params) (Environment, error) {
+	env, _, err := resolveEnvironment(params)
+	return env, err
+}
+
+// resolveEnvironment builds the environment from the kernel params, the
+// zos-config of the run mode and the environment variables. It also returns
+// the source of each field that is not set from the run mode defaults.
+func resolveEnvironment(params kernel.Params) (Environment, map[string]Source, error) {
 	var env Environment
+	sources := make(map[string]Source)
+	set := func(field string, source Source) {
+		sources[field] = source
+	}
+
 	runmode := ""
 	if modes, ok := params.Get("runmode"); ok {
 		if len(modes) >= 1 {
 			runmode = modes[0]
+			set("RunningMode", SourceKernel)
 		}
-	} else {
-		runmode = os.Getenv("ZOS_RUNMODE")
+	} else if runmode = os.Getenv("ZOS_RUNMODE"); len(runmode) > 0 {
+		set("RunningMode", SourceEnvVar)
 	}
 
 	if len(runmode) == 0 {
@@ -339,78 +380,110 @@ func getEnvironmentFromParams(params kernel.Params) (Environment, error) {
 
 	if substrate, ok := params.Get("substrate"); ok && len(substrate) > 0 {
 		env.SubstrateURL = substrate
+		set("SubstrateURL", SourceKernel)
 	} else if substrate := config.SubstrateURL; len(substrate) > 0 {
 		env.SubstrateURL = substrate
+		set("SubstrateURL", SourceZosConfig)
 	}
 
 	if relay, ok := params.Get("relay"); ok && len(relay) > 0 {
 		env.RelaysURLs = relay
+		set("RelaysURLs", SourceKernel)
 	} else if relay := config.RelaysURLs; len(relay) > 0 {
 		env.RelaysURLs = relay
+		set("RelaysURLs", SourceZosConfig)
 	}
 
 	if activation, ok := params.Get("activation"); ok && len(activation) > 0 {
 		env.ActivationURL = activation
+		set("ActivationURL", SourceKernel)
 	} else if activation := config.ActivationURL; len(activation) > 0 {
 		env.ActivationURL = activation
+		set("ActivationURL", SourceZosConfig)
 	}
 
 	if graphql := config.GraphQL; len(graphql) > 0 {
 		env.GraphQL = graphql
+		set("GraphQL", SourceZosConfig)
 	}
 
 	if bin := config.BinRepo; len(bin) > 0 {
 		env.BinRepo = bin
+		set("BinRepo", SourceZosConfig)
 	}
 
-	if kyc := config.KycURL; len(kyc) > 0 {
-		env.KycURL = kyc
+	if kyc := config.KycURLs; len(kyc) > 0 {
+		env.KycURLs = kyc
+		set("KycURLs", SourceZosConfig)
+	} else if kyc := config.KycURL; len(kyc) > 0 {
+		env.KycURLs = []string{kyc}
+		set("KycURLs", SourceZosConfig)
+	}
+	env.KycURL = env.KycURLs[0]
+	if source, ok := sources["KycURLs"]; ok {
+		set("KycURL", source)
 	}
 
 	if registrar := config.RegistrarURL; len(registrar) > 0 {
 		env.RegistrarURL = registrar
+		set("RegistrarURL", SourceZosConfig)
 	}
 
 	if geoip := config.GeoipURLs; len(geoip) > 0 {
 		env.GeoipURLs = geoip
+		set("GeoipURLs", SourceZosConfig)
+	}
+
+	if stun := ValidStunURLs(config.StunURLs); len(stun) > 0 {
+		env.StunURLs = stun
+		set("StunURLs", SourceZosConfig)
 	}
 
 	// flist url and hub storage urls shouldn't listen to changes in config as long as we can't change it at run time.
 	// it would cause breakage in vmd that needs a reboot to be recovered.
 	if flist := config.FlistURL; len(flist) > 0 {
 		env.FlistURL = flist
+		set("FlistURL", SourceZosConfig)
 	}
 
 	if storage := config.HubStorage; len(storage) > 0 {
 		env.HubStorage = storage
+		set("HubStorage", SourceZosConfig)
 	}
 
 	// maybe we should verify that we're using a working hub url
 	if hub := config.HubURL; len(hub) > 0 {
 		env.HubURL = hub[0]
+		set("HubURL", SourceZosConfig)
 	}
 
 	// some modules needs v3 hub url even if the node is of v4
 	if hub := config.V4HubURL; len(hub) > 0 {
 		env.V4HubURL = hub[0]
+		set("V4HubURL", SourceZosConfig)
 	}
 
 	// if the node running v4 chage urls to use v4 hub
 	if params.IsV4() {
 		env.FlistURL = defaultV4FlistURL
+		set("FlistURL", SourceDefault)
 		if flist := config.V4FlistURL; len(flist) > 0 {
 			env.FlistURL = flist
+			set("FlistURL", SourceZosConfig)
 		}
 
 		env.HubStorage = defaultV4HubStorage
+		set("HubStorage", SourceDefault)
 		if storage := config.V4HubStorage; len(storage) > 0 {
 			env.HubStorage = storage
+			set("HubStorage", SourceZosConfig)
 		}
 	}
 
 	if farmSecret, ok := params.Get("secret"); ok {
 		if len(farmSecret) > 0 {
 			env.FarmSecret = farmSecret[len(farmSecret)-1]
+			set("FarmSecret", SourceKernel)
 		}
 	}
 
@@ -432,19 +505,22 @@ func getEnvironmentFromParams(params kernel.Params) (Environment, error) {
 		env.Orphan = false
 		id, err := strconv.ParseUint(farmerID[0], 10, 32)
 		if err != nil {
-			return env, errors.Wrap(err, "wrong format for farm ID")
+			return env, sources, errors.Wrap(err, "wrong format for farm ID")
 		}
 		env.FarmID = pkg.FarmID(id)
+		set("FarmID", SourceKernel)
+		set("Orphan", SourceKernel)
 	}
 
 	if vlan, found := params.GetOne("vlan:priv"); found {
 		if !slices.Contains([]string{"none", "untagged", "un"}, vlan) {
 			tag, err := strconv.ParseUint(vlan, 10, 16)
 			if err != nil {
-				return env, errors.Wrap(err, "failed to parse priv vlan value")
+				return env, sources, errors.Wrap(err, "failed to parse priv vlan value")
 			}
 			tagU16 := uint16(tag)
 			env.PrivVlan = &tagU16
+			set("PrivVlan", SourceKernel)
 		}
 	}
 
@@ -452,10 +528,11 @@ func getEnvironmentFromParams(params kernel.Params) (Environment, error) {
 		if !slices.Contains([]string{"none", "untagged", "un"}, vlan) {
 			tag, err := strconv.ParseUint(vlan, 10, 16)
 			if err != nil {
-				return env, errors.Wrap(err, "failed to parse pub vlan value")
+				return env, sources, errors.Wrap(err, "failed to parse pub vlan value")
 			}
 			tagU16 := uint16(tag)
 			env.PubVlan = &tagU16
+			set("PubVlan", SourceKernel)
 		}
 	}
 
@@ -463,6 +540,7 @@ func getEnvironmentFromParams(params kernel.Params) (Environment, error) {
 		v := PubMac(mac)
 		if slices.Contains([]PubMac{PubMacRandom, PubMacSwap}, v) {
 			env.PubMac = v
+			set("PubMac", SourceKernel)
 		} else {
 			env.PubMac = PubMacRandom
 		}
@@ -475,15 +553,55 @@ func getEnvironmentFromParams(params kernel.Params) (Environment, error) {
 
 	if e := os.Getenv("ZOS_SUBSTRATE_URL"); e != "" {
 		env.SubstrateURL = []string{e}
+		set("SubstrateURL", SourceEnvVar)
 	}
 
 	if e := os.Getenv("ZOS_FLIST_URL"); e != "" {
 		env.FlistURL = e
+		set("FlistURL", SourceEnvVar)
 	}
 
 	if e := os.Getenv("ZOS_BIN_REPO"); e != "" {
 		env.BinRepo = e
+		set("BinRepo", SourceEnvVar)
 	}
 
-	return env, nil
+	if e := os.Getenv("ZOS_STUN_URLS"); e != "" {
+		if stun := ValidStunURLs(strings.Split(e, ",")); len(stun) > 0 {
+			env.StunURLs = stun
+			set("StunURLs", SourceEnvVar)
+		}
+	}
+
+	return env, sources, nil
+}
+
+// ValidStunURLs returns the valid urls to find the public ip with, STUN
+// uris (stun:host:port) and http(s) ip echo urls. Invalid urls are dropped.
+func ValidStunURLs(urls []string) []string {
+	var valid []string
+	for _, u := range urls {
+		u = strings.TrimSpace(u)
+		if len(u) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+			if parsed, err := url.Parse(u); err != nil || len(parsed.Host) == 0 {
+				log.Warn().Str("url", u).Msg("ignoring invalid ip echo url")
+				continue
+			}
+			valid = append(valid, u)
+			continue
+		}
+
+		parsed, err := stun.ParseURI(u)
+		if err != nil || parsed.Scheme != stun.SchemeTypeSTUN {
+			log.Warn().Str("url", u).Msg("ignoring invalid stun url")
+			continue
+		}
+		valid = append(valid, u)
+	}
+
+	return valid
 }
@@ -59,3 +59,51 @@ func TestEnvironmentOverride(t *testing.T) {
 
 	assert.Equal(t, []string{"localhost:1234"}, value.SubstrateURL)
 }
+
+func TestEffectiveConfig(t *testing.T) {
+	t.Setenv("ZOS_SUBSTRATE_URL", "")
+	t.Setenv("ZOS_FLIST_URL", "redis://localhost:9900")
+
+	params := kernel.Params{
+		"runmode":   {"dev"},
+		"substrate": {"wss://localhost:9944"},
+		"secret":    {"my-secret"},
+	}
+	env, sources, err := resolveEnvironment(params)
+	require.NoError(t, err)
+
+	config := effectiveConfig(env, sources)
+	assert.Equal(t, ConfigSource{Value: RunningDev, Source: SourceKernel}, config["RunningMode"])
+	assert.Equal(t, ConfigSource{Value: []string{"wss://localhost:9944"}, Source: SourceKernel}, config["SubstrateURL"])
+	assert.Equal(t, ConfigSource{Value: "redis://localhost:9900", Source: SourceEnvVar}, config["FlistURL"])
+	assert.Equal(t, ConfigSource{Value: "<redacted>", Source: SourceKernel}, config["FarmSecret"])
+	assert.Equal(t, ConfigSource{Value: nil, Source: SourceDefault}, config["PrivVlan"])
+	assert.Equal(t, SourceDefault, config["PubMac"].Source)
+}
+
+func TestValidStunURLs(t *testing.T) {
+	valid := ValidStunURLs([]string{
+		"stun:stun.example.com:3478",
+		" https://ip.example.com/ ",
+		"turn:turn.example.com:3478",
+		"http://",
+		"stun:",
+		"",
+	})
+	assert.Equal(t, []string{"stun:stun.example.com:3478", "https://ip.example.com/"}, valid)
+}
+
+func TestStunURLsOverride(t *testing.T) {
+	t.Setenv("ZOS_STUN_URLS", "stun:stun.example.com:3478,https://ip.example.com")
+
+	env, sources, err := resolveEnvironment(kernel.Params{"runmode": {"dev"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stun:stun.example.com:3478", "https://ip.example.com"}, env.StunURLs)
+	assert.Equal(t, SourceEnvVar, sources["StunURLs"])
+
+	// invalid urls keep the defaults
+	t.Setenv("ZOS_STUN_URLS", "turn:turn.example.com:3478")
+	env, _, err = resolveEnvironment(kernel.Params{"runmode": {"dev"}})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultStunURLs, env.StunURLs)
+}
@@ -1,6 +1,10 @@
 package pkg
 
-import "github.com/threefoldtech/zosbase/pkg/gridtypes"
+import (
+	"time"
+
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
 
 //go:generate mkdir -p stubs
 
@@ -35,6 +39,18 @@ type MountOptions struct {
 	PersistedVolume string
 }
 
+// CachedMount is a ro flist mount maintained by the flist module
+type CachedMount struct {
+	// Hash of the flist
+	Hash string `json:"hash"`
+	// Path where the flist is mounted
+	Path string `json:"path"`
+	// InUse is true if a workload is using the mount
+	InUse bool `json:"in_use"`
+	// LastUsed is the last time the mount was used by a workload
+	LastUsed time.Time `json:"last_used"`
+}
+
 // Flister is the interface for the flist module
 type Flister interface {
 	// Mount mounts an flist located at url using the 0-db located at storage
@@ -57,4 +73,8 @@ type Flister interface {
 	FlistHash(url string) (string, error)
 
 	Exists(name string) (bool, error)
+
+	// CachedMounts lists the ro flist mounts, with their last use time. Unused
+	// mounts are evicted according to the module eviction policy
+	CachedMounts() ([]CachedMount, error)
 }
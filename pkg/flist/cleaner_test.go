@@ -1,10 +1,12 @@
 package flist
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -61,3 +63,73 @@ func TestCacheCleaner(t *testing.T) {
 	assert.Equal(t, "file-00", files[0].Name())
 	assert.Equal(t, "file-49", files[49].Name())
 }
+
+func TestEvictable(t *testing.T) {
+	now := time.Now()
+
+	t.Run("no grace period", func(t *testing.T) {
+		flister := flistModule{}
+		evict := flister.evictable([]string{"a", "b"}, now)
+		assert.ElementsMatch(t, []string{"a", "b"}, evict)
+	})
+
+	t.Run("grace period", func(t *testing.T) {
+		flister := flistModule{
+			eviction: EvictionPolicy{GracePeriod: time.Hour},
+		}
+		flister.touch("old", now.Add(-2*time.Hour))
+		flister.touch("recent", now.Add(-time.Minute))
+
+		evict := flister.evictable([]string{"old", "recent", "new"}, now)
+		assert.Equal(t, []string{"old"}, evict)
+	})
+
+	t.Run("max unused", func(t *testing.T) {
+		flister := flistModule{
+			eviction: EvictionPolicy{GracePeriod: time.Hour, MaxUnused: 1},
+		}
+		flister.touch("a", now.Add(-30*time.Minute))
+		flister.touch("b", now.Add(-10*time.Minute))
+		flister.touch("c", now.Add(-20*time.Minute))
+
+		evict := flister.evictable([]string{"a", "b", "c"}, now)
+		assert.ElementsMatch(t, []string{"a", "c"}, evict)
+	})
+}
+
+// countingCommander counts the listing of the system mounts
+type countingCommander struct {
+	*testCommander
+	listed atomic.Int32
+}
+
+func (c *countingCommander) Command(name string, args ...string) *exec.Cmd {
+	if name == "findmnt" {
+		c.listed.Add(1)
+	}
+	return c.testCommander.Command(name, args...)
+}
+
+func TestMountsCleaner(t *testing.T) {
+	cmder := &countingCommander{testCommander: &testCommander{T: t}}
+	flister := newFlister(t.TempDir(), &StorageMock{}, cmder, &testSystem{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		flister.MountsCleaner(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	// the unused mounts are checked periodically
+	require.Eventually(t, func() bool {
+		return cmder.listed.Load() >= 2
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("mounts cleaner did not stop on cancel")
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"sort"
 	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
 	"github.com/threefoldtech/zosbase/pkg/app"
 )
 
@@ -18,6 +20,10 @@ type Cleaner interface {
 	// CacheCleaner runs the clean process, CacheCleaner should be
 	// blocking. Caller then can do `go CacheCleaner()` to run it in the background
 	CacheCleaner(ctx context.Context, every time.Duration, age time.Duration)
+	// MountsCleaner evicts the unused ro mounts every interval according to
+	// the eviction policy, until the context is cancelled. It's blocking
+	// like CacheCleaner.
+	MountsCleaner(ctx context.Context, every time.Duration)
 }
 
 var _ Cleaner = (*flistModule)(nil)
@@ -44,6 +50,33 @@ func (f *flistModule) CacheCleaner(ctx context.Context, every time.Duration, age
 	}
 }
 
+func (f *flistModule) MountsCleaner(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			log.Debug().Msg("running mounts cleaner job")
+			if err := f.sweepUnusedMounts(); err != nil {
+				log.Error().Err(err).Msg("failed to clean unused mounts")
+			}
+		}
+	}
+}
+
+// sweepUnusedMounts runs cleanUnusedMounts outside of a mount operation, so
+// unused mounts are evicted once their grace period is over even if no
+// other flist is mounted or unmounted
+func (f *flistModule) sweepUnusedMounts() error {
+	f.mountsM.Lock()
+	defer f.mountsM.Unlock()
+
+	return f.cleanUnusedMounts()
+}
+
 func (f *flistModule) cleanCache(now time.Time, age time.Duration) error {
 	return filepath.Walk(f.cache, func(path string, info os.FileInfo, err error) error {
 		if info.IsDir() {
@@ -72,14 +105,85 @@ func (f *flistModule) cleanCache(now time.Time, age time.Duration) error {
 	})
 }
 
-// cleanUnusedMounts need to be called ONLY inside the daemon worker
-// calling it in a timer can cause an issue if it decide to clean
-// ro mount that are still half through a mount operation.
-func (f *flistModule) cleanUnusedMounts() error {
+// EvictionPolicy controls when unused ro flist mounts are unmounted. A ro
+// mount is unused if no rw (or bind) mount of a workload is using it.
+type EvictionPolicy struct {
+	// GracePeriod is how long an unused mount is kept before it is evicted
+	// so re-deploying the same flist doesn't need to mount it again.
+	// A zero value evicts unused mounts immediately.
+	GracePeriod time.Duration
+	// MaxUnused is the max number of unused mounts kept during the grace period,
+	// the least recently used mounts are evicted first. 0 means no limit.
+	MaxUnused int
+}
+
+// touch marks the ro mount as used now
+func (f *flistModule) touch(target string, now time.Time) {
+	f.usageM.Lock()
+	defer f.usageM.Unlock()
+
+	if f.lastUsed == nil {
+		f.lastUsed = make(map[string]time.Time)
+	}
+	f.lastUsed[target] = now
+}
+
+// lastUse of ro mount, if never seen before it's considered used now
+func (f *flistModule) lastUse(target string, now time.Time) time.Time {
+	f.usageM.Lock()
+	defer f.usageM.Unlock()
+
+	if f.lastUsed == nil {
+		f.lastUsed = make(map[string]time.Time)
+	}
+
+	last, ok := f.lastUsed[target]
+	if !ok {
+		f.lastUsed[target] = now
+		return now
+	}
+
+	return last
+}
+
+func (f *flistModule) forget(target string) {
+	f.usageM.Lock()
+	defer f.usageM.Unlock()
+
+	delete(f.lastUsed, target)
+}
+
+// evictable returns the unused ro mount targets that should be evicted
+// according to the eviction policy
+func (f *flistModule) evictable(unused []string, now time.Time) []string {
+	var evict, keep []string
+	for _, target := range unused {
+		if now.Sub(f.lastUse(target, now)) >= f.eviction.GracePeriod {
+			evict = append(evict, target)
+		} else {
+			keep = append(keep, target)
+		}
+	}
+
+	if f.eviction.MaxUnused <= 0 || len(keep) <= f.eviction.MaxUnused {
+		return evict
+	}
+
+	// evict the least recently used mounts over the limit
+	sort.Slice(keep, func(i, j int) bool {
+		return f.lastUse(keep[i], now).Before(f.lastUse(keep[j], now))
+	})
+
+	return append(evict, keep[:len(keep)-f.eviction.MaxUnused]...)
+}
+
+// roMounts returns all ro mounts maintained by the flist daemon, and if
+// they are used by a rw (or bind) mount
+func (f *flistModule) roMounts() (used map[string]mountInfo, unused map[string]mountInfo, err error) {
 	// we list all mounts maintained by flist daemon
 	all, err := f.mounts(withUnderPath(f.root))
 	if err != nil {
-		return errors.Wrap(err, "failed to list flist mounts")
+		return nil, nil, errors.Wrap(err, "failed to list flist mounts")
 	}
 
 	roTargets := make(map[int64]mountInfo)
@@ -93,6 +197,7 @@ func (f *flistModule) cleanUnusedMounts() error {
 		roTargets[g8ufs.Pid] = mount
 	}
 
+	used = make(map[string]mountInfo)
 	for _, mount := range all.filter(withParentDir(f.mountpoint)) {
 		var info g8ufsInfo
 		switch mount.FSType {
@@ -108,19 +213,83 @@ func (f *flistModule) cleanUnusedMounts() error {
 			}
 		}
 
+		if ro, ok := roTargets[info.Pid]; ok {
+			used[ro.Target] = ro
+		}
 		delete(roTargets, info.Pid)
 	}
-	if len(roTargets) == 0 {
-		log.Info().Msg("no unused mounts detected")
+
+	unused = make(map[string]mountInfo)
+	for _, mount := range roTargets {
+		unused[mount.Target] = mount
+	}
+
+	return used, unused, nil
+}
+
+// CachedMounts lists all ro flist mounts with their usage
+func (f *flistModule) CachedMounts() ([]pkg.CachedMount, error) {
+	used, unused, err := f.roMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	cached := make([]pkg.CachedMount, 0, len(used)+len(unused))
+	for target := range used {
+		f.touch(target, now)
+		cached = append(cached, pkg.CachedMount{
+			Hash:     filepath.Base(target),
+			Path:     target,
+			InUse:    true,
+			LastUsed: now,
+		})
+	}
+
+	for target := range unused {
+		cached = append(cached, pkg.CachedMount{
+			Hash:     filepath.Base(target),
+			Path:     target,
+			LastUsed: f.lastUse(target, now),
+		})
+	}
+
+	return cached, nil
+}
+
+// cleanUnusedMounts need to be called ONLY with mountsM held (inside a
+// mount operation or by sweepUnusedMounts), otherwise it can clean a ro
+// mount that is still half through a mount operation.
+func (f *flistModule) cleanUnusedMounts() error {
+	used, unused, err := f.roMounts()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for target := range used {
+		f.touch(target, now)
+	}
+
+	targets := make([]string, 0, len(unused))
+	for target := range unused {
+		targets = append(targets, target)
+	}
+
+	evict := f.evictable(targets, now)
+	if len(evict) == 0 {
+		log.Info().Int("unused", len(unused)).Msg("no unused mounts to evict")
 	}
-	// cleaning up remaining un-used mounts
-	for pid, mount := range roTargets {
-		log.Info().Int64("source", pid).Msgf("cleaning up mount: %+v", mount)
+	// cleaning up evicted un-used mounts
+	for _, target := range evict {
+		mount := unused[target]
+		log.Info().Str("source", mount.Source).Msgf("cleaning up mount: %+v", mount)
 		if err := f.system.Unmount(mount.Target, 0); err != nil {
 			log.Error().Err(err).Str("target", mount.Target).Msg("failed to clean up mount")
 			continue
 		}
 
+		f.forget(mount.Target)
 		if err := os.RemoveAll(mount.Target); err != nil {
 			log.Error().Err(err).Str("target", mount.Target).Msg("failed to delete mountpoint")
 		}
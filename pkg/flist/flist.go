@@ -14,6 +14,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -132,6 +133,26 @@ type flistModule struct {
 	system    system
 
 	httpClient *retryablehttp.Client
+
+	eviction EvictionPolicy
+	// mountsM serializes mount operations with the periodic sweep of
+	// unused mounts, so a ro mount is not evicted half way through a mount
+	mountsM sync.Mutex
+	usageM  sync.Mutex
+	// lastUsed keeps track of last time a ro mount was used
+	lastUsed map[string]time.Time
+}
+
+// Option is a flist module option
+type Option func(f *flistModule)
+
+// WithEvictionPolicy sets the eviction policy of unused ro flist mounts.
+// By default unused mounts are evicted immediately. Eviction is checked
+// on each Mount and Unmount call, and periodically by MountsCleaner.
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(f *flistModule) {
+		f.eviction = policy
+	}
 }
 
 func newFlister(root string, storage volumeAllocator, commander commander, system system) *flistModule {
@@ -177,6 +198,7 @@ func newFlister(root string, storage volumeAllocator, commander commander, syste
 		system:    system,
 
 		httpClient: httpClient,
+		lastUsed:   make(map[string]time.Time),
 	}
 }
 
@@ -193,8 +215,13 @@ func (o options) Find(k string) int {
 }
 
 // New creates a new flistModule
-func New(root string, storage *stubs.StorageModuleStub) pkg.Flister {
-	return newFlister(root, storage, cmd(exec.Command), &defaultSystem{})
+func New(root string, storage *stubs.StorageModuleStub, opts ...Option) pkg.Flister {
+	f := newFlister(root, storage, cmd(exec.Command), &defaultSystem{})
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
 }
 
 // MountRO mounts an flist in read-only mode. This mount then can be shared between multiple rw mounts
@@ -216,6 +243,8 @@ func (f *flistModule) mountRO(url, storage, nsName string) (string, error) {
 		return "", err
 	}
 
+	f.touch(mountpoint, time.Now())
+
 	err = f.valid(mountpoint)
 	if err == ErrAlreadyMounted {
 		return mountpoint, nil
@@ -431,6 +460,9 @@ func (f *flistModule) mountInNamespace(name, url string, opt pkg.MountOptions, n
 	sublog := log.With().Str("name", name).Str("url", url).Str("storage", opt.Storage).Logger()
 	sublog.Info().Msgf("request to mount flist: %+v", opt)
 
+	f.mountsM.Lock()
+	defer f.mountsM.Unlock()
+
 	defer func() {
 		if err := f.cleanUnusedMounts(); err != nil {
 			log.Error().Err(err).Msg("failed to run clean up")
@@ -587,6 +619,9 @@ func (f *flistModule) HashFromRootPath(name string) (string, error) {
 }
 
 func (f *flistModule) Unmount(name string) error {
+	f.mountsM.Lock()
+	defer f.mountsM.Unlock()
+
 	defer func() {
 		if err := f.cleanUnusedMounts(); err != nil {
 			log.Error().Err(err).Msg("failed to run clean up")
@@ -155,3 +155,18 @@ func (i IPNet) String() string {
 func (i *IPNet) Nil() bool {
 	return i.IP == nil && i.Mask == nil
 }
+
+// ValidateNameservers checks that all nameservers are valid unicast addresses
+func ValidateNameservers(nameservers []net.IP) error {
+	for _, ns := range nameservers {
+		if len(ns) != net.IPv4len && len(ns) != net.IPv6len {
+			return fmt.Errorf("invalid nameserver '%s'", ns)
+		}
+
+		if ns.IsUnspecified() || ns.IsLoopback() || ns.IsMulticast() {
+			return fmt.Errorf("nameserver '%s' is not a unicast address", ns)
+		}
+	}
+
+	return nil
+}
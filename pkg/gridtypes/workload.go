@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -20,6 +22,19 @@ var (
 	// accessed from other deployments (as read only)
 	// but only modifiable from deployments that creates it.
 	sharableWorkloadTypes = map[WorkloadType]struct{}{}
+	// schemaVersions of workload types that support more than
+	// the default schema version
+	schemaVersions = map[WorkloadType][]uint32{}
+
+	// ErrUnsupportedSchemaVersion is returned if a workload schema version
+	// is not supported by this node
+	ErrUnsupportedSchemaVersion = fmt.Errorf("unsupported schema version")
+)
+
+const (
+	// DefaultSchemaVersion is the schema version supported by all types
+	// unless other versions are registered with RegisterSchemaVersions
+	DefaultSchemaVersion uint32 = 1
 )
 
 // RegisterType register a new workload type. This is used by zos to "declare"
@@ -56,6 +71,33 @@ func Types() []WorkloadType {
 	return types
 }
 
+// RegisterSchemaVersions sets the data schema versions supported by
+// the given (already registered) workload type.
+// Note: a user never need to call this, it's done by zos libraries.
+func RegisterSchemaVersions(t WorkloadType, versions ...uint32) {
+	if _, ok := workloadTypes[t]; !ok {
+		panic("type is not registered")
+	}
+	if len(versions) == 0 {
+		panic("at least one schema version is required")
+	}
+
+	sorted := append([]uint32(nil), versions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	schemaVersions[t] = sorted
+}
+
+// SchemaVersions returns the supported data schema versions of all
+// registered types
+func SchemaVersions() map[WorkloadType][]uint32 {
+	all := make(map[WorkloadType][]uint32, len(workloadTypes))
+	for typ := range workloadTypes {
+		all[typ] = typ.SchemaVersions()
+	}
+
+	return all
+}
+
 func IsSharable(typ WorkloadType) bool {
 	_, ok := sharableWorkloadTypes[typ]
 	return ok
@@ -70,6 +112,58 @@ func (t WorkloadType) Valid() error {
 	return nil
 }
 
+// SchemaVersions returns the data schema versions supported by this type
+func (t WorkloadType) SchemaVersions() []uint32 {
+	if versions, ok := schemaVersions[t]; ok {
+		return append([]uint32(nil), versions...)
+	}
+
+	return []uint32{DefaultSchemaVersion}
+}
+
+// ValidSchemaVersion checks if the data schema version is supported
+// by this type. Version 0 means the version is not set and is always
+// accepted for backward compatibility.
+func (t WorkloadType) ValidSchemaVersion(version uint32) error {
+	if version == 0 {
+		return nil
+	}
+
+	supported := t.SchemaVersions()
+	for _, v := range supported {
+		if v == version {
+			return nil
+		}
+	}
+
+	return SchemaVersionError{Type: t, Version: version, Supported: supported}
+}
+
+// SchemaVersionError is returned when a workload data schema version is
+// not supported by the node
+type SchemaVersionError struct {
+	Type      WorkloadType
+	Version   uint32
+	Supported []uint32
+}
+
+func (e SchemaVersionError) Error() string {
+	versions := make([]string, 0, len(e.Supported))
+	for _, v := range e.Supported {
+		versions = append(versions, fmt.Sprint(v))
+	}
+
+	return fmt.Sprintf(
+		"unsupported schema version %d for type '%s' (supported: %s)",
+		e.Version, e.Type, strings.Join(versions, ", "),
+	)
+}
+
+// Is makes SchemaVersionError match ErrUnsupportedSchemaVersion
+func (e SchemaVersionError) Is(target error) bool {
+	return target == ErrUnsupportedSchemaVersion
+}
+
 func (t WorkloadType) String() string {
 	return string(t)
 }
@@ -128,6 +222,10 @@ type Workload struct {
 	Name Name `json:"name"`
 	// Type of the reservation (container, zdb, vm, etc...)
 	Type WorkloadType `json:"type"`
+	// SchemaVersion is the version of the data schema of the type, if set
+	// it must be one of the versions supported by the node for that type.
+	// It's not part of the workload challenge.
+	SchemaVersion uint32 `json:"schema_version,omitempty"`
 	// Data is the reservation type arguments.
 	Data json.RawMessage `json:"data"`
 	// Metadata is user specific meta attached to deployment, can be used to link this
@@ -175,6 +273,10 @@ func (w *Workload) Valid(getter WorkloadGetter) error {
 		return err
 	}
 
+	if err := w.Type.ValidSchemaVersion(w.SchemaVersion); err != nil {
+		return errors.Wrapf(err, "invalid workload '%s'", w.Name)
+	}
+
 	data, err := w.WorkloadData()
 	if err != nil {
 		return err
@@ -275,6 +377,9 @@ type Result struct {
 	// Data is the information generated by the provisioning of the workload
 	// its type depend on the reservation type
 	Data json.RawMessage `json:"data"`
+	// Attempts is the number of provision attempts of a workload that
+	// failed after it was retried, it's not part of the signed result
+	Attempts int `json:"attempts,omitempty"`
 }
 
 func (r *Result) Valid() error {
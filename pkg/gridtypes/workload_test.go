@@ -2,6 +2,7 @@ package gridtypes
 
 import (
 	"encoding/json"
+	"io"
 	"testing"
 	"time"
 
@@ -26,3 +27,39 @@ func TestTimestamp(t *testing.T) {
 
 	require.Equal(Timestamp(n.Unix()), v)
 }
+
+type testData struct{}
+
+func (testData) Valid(getter WorkloadGetter) error { return nil }
+func (testData) Challenge(io.Writer) error         { return nil }
+func (testData) Capacity() (Capacity, error)       { return Capacity{}, nil }
+
+func TestSchemaVersion(t *testing.T) {
+	const typ WorkloadType = "schema-test"
+	RegisterType(typ, testData{})
+	defer func() {
+		delete(workloadTypes, typ)
+		delete(schemaVersions, typ)
+	}()
+
+	require.Equal(t, []uint32{DefaultSchemaVersion}, typ.SchemaVersions())
+	require.NoError(t, typ.ValidSchemaVersion(0))
+	require.NoError(t, typ.ValidSchemaVersion(1))
+
+	err := typ.ValidSchemaVersion(2)
+	require.ErrorIs(t, err, ErrUnsupportedSchemaVersion)
+	require.EqualError(t, err, "unsupported schema version 2 for type 'schema-test' (supported: 1)")
+
+	RegisterSchemaVersions(typ, 3, 2)
+	require.Equal(t, []uint32{2, 3}, SchemaVersions()[typ])
+	require.NoError(t, typ.ValidSchemaVersion(2))
+	require.EqualError(t, typ.ValidSchemaVersion(1), "unsupported schema version 1 for type 'schema-test' (supported: 2, 3)")
+
+	wl := Workload{
+		Name:          "test",
+		Type:          typ,
+		SchemaVersion: 4,
+		Data:          json.RawMessage(`{}`),
+	}
+	require.ErrorIs(t, wl.Valid(nil), ErrUnsupportedSchemaVersion)
+}
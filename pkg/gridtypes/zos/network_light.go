@@ -51,8 +51,23 @@ type NetworkLight struct {
 
 	// Peers is a list of other peers in this network
 	Peers []Peer `json:"peers"`
+
+	// MTU of the network wireguard interface and the vms private interfaces,
+	// optional. If not set the default mtu of each interface is used.
+	MTU uint16 `json:"mtu,omitempty"`
+
+	// BandwidthLimit is the max egress rate of the network in Mbps,
+	// optional. If not set the network egress is not limited.
+	BandwidthLimit uint32 `json:"bandwidth_limit,omitempty"`
 }
 
+const (
+	// MinNetworkMTU is the min mtu of a network, the min mtu required by ipv6
+	MinNetworkMTU = 1280
+	// MaxNetworkMTU is the max mtu of a network
+	MaxNetworkMTU = 9000
+)
+
 // Valid checks if the network resource is valid.
 func (n NetworkLight) Valid(getter gridtypes.WorkloadGetter) error {
 	if len(n.Subnet.IP) == 0 {
@@ -63,6 +78,10 @@ func (n NetworkLight) Valid(getter gridtypes.WorkloadGetter) error {
 		return err
 	}
 
+	if n.MTU != 0 && (n.MTU < MinNetworkMTU || n.MTU > MaxNetworkMTU) {
+		return fmt.Errorf("network mtu must be between %d and %d", MinNetworkMTU, MaxNetworkMTU)
+	}
+
 	return nil
 }
 
@@ -92,6 +111,20 @@ func (n NetworkLight) Challenge(b io.Writer) error {
 		return err
 	}
 
+	// the mtu and bandwidth limit are optional, so they are only part of the
+	// challenge if set. They are labelled so one can't be taken for the other
+	if n.MTU != 0 {
+		if _, err := fmt.Fprintf(b, "mtu:%d;", n.MTU); err != nil {
+			return err
+		}
+	}
+
+	if n.BandwidthLimit != 0 {
+		if _, err := fmt.Fprintf(b, "bandwidth_limit:%d;", n.BandwidthLimit); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
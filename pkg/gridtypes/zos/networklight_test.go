@@ -0,0 +1,64 @@
+package zos
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	gridtypes "github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+func TestNetworkLightMTU(t *testing.T) {
+	network := NetworkLight{
+		Subnet:   gridtypes.MustParseIPNet("10.1.2.0/24"),
+		Mycelium: Mycelium{Key: make([]byte, 32)},
+	}
+	require.NoError(t, network.Valid(nil))
+
+	var before bytes.Buffer
+	require.NoError(t, network.Challenge(&before))
+
+	network.MTU = 1400
+	require.NoError(t, network.Valid(nil))
+
+	var after bytes.Buffer
+	require.NoError(t, network.Challenge(&after))
+	require.NotEqual(t, before.String(), after.String())
+
+	network.MTU = 500
+	require.Error(t, network.Valid(nil))
+
+	network.MTU = 9001
+	require.Error(t, network.Valid(nil))
+}
+
+func TestNetworkLightBandwidthLimit(t *testing.T) {
+	network := NetworkLight{
+		Subnet:   gridtypes.MustParseIPNet("10.1.2.0/24"),
+		Mycelium: Mycelium{Key: make([]byte, 32)},
+	}
+
+	var before bytes.Buffer
+	require.NoError(t, network.Challenge(&before))
+
+	network.BandwidthLimit = 100
+	require.NoError(t, network.Valid(nil))
+
+	var after bytes.Buffer
+	require.NoError(t, network.Challenge(&after))
+	require.NotEqual(t, before.String(), after.String())
+}
+
+func TestNetworkLightChallengeFields(t *testing.T) {
+	challenge := func(network NetworkLight) string {
+		network.Subnet = gridtypes.MustParseIPNet("10.1.2.0/24")
+		var buf bytes.Buffer
+		require.NoError(t, network.Challenge(&buf))
+		return buf.String()
+	}
+
+	// the same value in another optional field must not give the same hash
+	require.NotEqual(t, challenge(NetworkLight{MTU: 1400}), challenge(NetworkLight{BandwidthLimit: 1400}))
+	require.NotEqual(t, challenge(NetworkLight{MTU: 1400}), challenge(NetworkLight{MTU: 1400, BandwidthLimit: 1}))
+	require.NotEqual(t, challenge(NetworkLight{MTU: 1401}), challenge(NetworkLight{MTU: 140, BandwidthLimit: 1}))
+}
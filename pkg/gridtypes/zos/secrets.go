@@ -0,0 +1,119 @@
+package zos
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+// RedactedSecret is the value of secret env variables returned by the node
+const RedactedSecret = "<redacted>"
+
+func validSecretEnv(env, secrets map[string]string) error {
+	for key, value := range secrets {
+		if _, ok := env[key]; ok {
+			return fmt.Errorf("env variable '%s' can't be both secret and public", key)
+		}
+
+		if _, err := hex.DecodeString(value); err != nil {
+			return fmt.Errorf("secret env variable '%s' must be hex encoded: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// challengeSecretEnv writes the secret env variables to the challenge. Keys
+// are prefixed so moving a variable between env and secret env changes the
+// challenge.
+func challengeSecretEnv(w io.Writer, secrets map[string]string) error {
+	keys := make([]string, 0, len(secrets))
+	for k := range secrets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "secret:%s=%s", k, secrets[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MachineEnv returns the machine env variables with the secret ones
+// decrypted using decrypt
+func MachineEnv(env, secrets map[string]string, decrypt func([]byte) ([]byte, error)) (map[string]string, error) {
+	if len(secrets) == 0 {
+		return env, nil
+	}
+
+	result := make(map[string]string, len(env)+len(secrets))
+	for key, value := range env {
+		result[key] = value
+	}
+
+	for key, value := range secrets {
+		encrypted, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid secret env variable '%s'", key)
+		}
+
+		decrypted, err := decrypt(encrypted)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt secret env variable '%s'", key)
+		}
+
+		result[key] = string(decrypted)
+	}
+
+	return result, nil
+}
+
+// RedactSecrets replaces the values of the secret env variables of a
+// machine workload, other workload types are not changed.
+func RedactSecrets(wl *gridtypes.Workload) error {
+	var (
+		data    interface{}
+		secrets map[string]string
+	)
+
+	switch wl.Type {
+	case ZMachineType:
+		var vm ZMachine
+		if err := json.Unmarshal(wl.Data, &vm); err != nil {
+			return err
+		}
+		data, secrets = &vm, vm.SecretEnv
+	case ZMachineLightType:
+		var vm ZMachineLight
+		if err := json.Unmarshal(wl.Data, &vm); err != nil {
+			return err
+		}
+		data, secrets = &vm, vm.SecretEnv
+	default:
+		return nil
+	}
+
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	for key := range secrets {
+		secrets[key] = RedactedSecret
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	wl.Data = raw
+	return nil
+}
@@ -76,6 +76,10 @@ type ZMachine struct {
 	Entrypoint string `json:"entrypoint"`
 	// Env variables available for a container
 	Env map[string]string `json:"env"`
+	// SecretEnv are env variables that are not returned by the node. Values
+	// must be hex encoded and encrypted with the node public key, they are
+	// only decrypted when the machine is started.
+	SecretEnv map[string]string `json:"secret_env,omitempty"`
 	// Corex works in container mode which forces replace the
 	// entrypoing of the container to use `corex`
 	Corex bool `json:"corex"`
@@ -86,6 +90,10 @@ type ZMachine struct {
 	// - Not used by other VMs
 	// - Only possible on `dedicated` nodes
 	GPU []GPU `json:"gpu,omitempty"`
+
+	// Watchdog attaches a virtio-watchdog device to the VM, the VM is reset
+	// if the guest watchdog daemon stops feeding it.
+	Watchdog bool `json:"watchdog,omitempty"`
 }
 
 func (m *ZMachine) MinRootSize() gridtypes.Unit {
@@ -126,6 +134,9 @@ func (v ZMachine) Valid(getter gridtypes.WorkloadGetter) error {
 	if v.ComputeCapacity.Memory < 250*gridtypes.Megabyte {
 		return fmt.Errorf("mem capacity can't be less that 250M")
 	}
+	if err := v.ComputeCapacity.valid(); err != nil {
+		return err
+	}
 	minRoot := v.MinRootSize()
 	if v.Size != 0 && v.Size < minRoot {
 		return fmt.Errorf("disk size can't be less that %d. Set to 0 for minimum", minRoot)
@@ -175,14 +186,14 @@ func (v ZMachine) Valid(getter gridtypes.WorkloadGetter) error {
 		}
 	}
 
-	return nil
+	return validSecretEnv(v.Env, v.SecretEnv)
 }
 
 // Capacity implementation
 func (v ZMachine) Capacity() (gridtypes.Capacity, error) {
 	return gridtypes.Capacity{
-		CRU: uint64(v.ComputeCapacity.CPU),
-		MRU: v.ComputeCapacity.Memory,
+		CRU: uint64(v.ComputeCapacity.MaxCPUs()),
+		MRU: v.ComputeCapacity.MaxMemorySize(),
 		SRU: v.RootSize(),
 	}, nil
 }
@@ -235,12 +246,24 @@ func (v ZMachine) Challenge(b io.Writer) error {
 		return err
 	}
 
+	if err := challengeSecretEnv(b, v.SecretEnv); err != nil {
+		return err
+	}
+
 	for _, gpu := range v.GPU {
 		if _, err := fmt.Fprintf(b, "%s", gpu); err != nil {
 			return err
 		}
 	}
 
+	// only written if set so the challenge of existing deployments
+	// doesn't change
+	if v.Watchdog {
+		if _, err := fmt.Fprintf(b, "watchdog"); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -3,6 +3,7 @@ package zos
 import (
 	"fmt"
 	"io"
+	"net"
 	"sort"
 
 	gridtypes "github.com/threefoldtech/zosbase/pkg/gridtypes"
@@ -16,6 +17,10 @@ type MachineNetworkLight struct {
 
 	// Interfaces list of user znets to join
 	Interfaces []MachineInterface `json:"interfaces"`
+
+	// Nameservers of the machine, the node default nameservers
+	// are used if not set
+	Nameservers []net.IP `json:"nameservers,omitempty"`
 }
 
 // Challenge builder
@@ -36,6 +41,12 @@ func (n *MachineNetworkLight) Challenge(w io.Writer) error {
 		}
 	}
 
+	for _, ns := range n.Nameservers {
+		if _, err := fmt.Fprintf(w, "%s", ns.String()); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -60,6 +71,10 @@ type ZMachineLight struct {
 	Entrypoint string `json:"entrypoint"`
 	// Env variables available for a container
 	Env map[string]string `json:"env"`
+	// SecretEnv are env variables that are not returned by the node. Values
+	// must be hex encoded and encrypted with the node public key, they are
+	// only decrypted when the machine is started.
+	SecretEnv map[string]string `json:"secret_env,omitempty"`
 	// Corex works in container mode which forces replace the
 	// entrypoing of the container to use `corex`
 	Corex bool `json:"corex"`
@@ -95,14 +110,20 @@ func (m *ZMachineLight) RootSize() gridtypes.Unit {
 
 // Valid implementation
 func (v ZMachineLight) Valid(getter gridtypes.WorkloadGetter) error {
-	if len(v.Network.Interfaces) != 1 {
-		return fmt.Errorf("only one network private network is supported at the moment")
+	if len(v.Network.Interfaces) == 0 {
+		return fmt.Errorf("at least one private network is required")
 	}
 
+	networks := make(map[gridtypes.Name]struct{})
 	for _, inf := range v.Network.Interfaces {
 		if inf.IP.To4() == nil && inf.IP.To16() == nil {
 			return fmt.Errorf("invalid IP")
 		}
+
+		if _, ok := networks[inf.Network]; ok {
+			return fmt.Errorf("network '%s' is used by more than one interface", inf.Network)
+		}
+		networks[inf.Network] = struct{}{}
 	}
 	if v.ComputeCapacity.CPU == 0 {
 		return fmt.Errorf("cpu capacity can't be 0")
@@ -110,6 +131,9 @@ func (v ZMachineLight) Valid(getter gridtypes.WorkloadGetter) error {
 	if v.ComputeCapacity.Memory < 250*gridtypes.Megabyte {
 		return fmt.Errorf("mem capacity can't be less that 250M")
 	}
+	if err := v.ComputeCapacity.valid(); err != nil {
+		return err
+	}
 	minRoot := v.MinRootSize()
 	if v.Size != 0 && v.Size < minRoot {
 		return fmt.Errorf("disk size can't be less that %d. Set to 0 for minimum", minRoot)
@@ -121,6 +145,10 @@ func (v ZMachineLight) Valid(getter gridtypes.WorkloadGetter) error {
 		}
 	}
 
+	if err := gridtypes.ValidateNameservers(v.Network.Nameservers); err != nil {
+		return err
+	}
+
 	mycelium := v.Network.Mycelium
 	if mycelium != nil {
 		if len(mycelium.Seed) != MyceliumIPSeedLen {
@@ -128,14 +156,14 @@ func (v ZMachineLight) Valid(getter gridtypes.WorkloadGetter) error {
 		}
 	}
 
-	return nil
+	return validSecretEnv(v.Env, v.SecretEnv)
 }
 
 // Capacity implementation
 func (v ZMachineLight) Capacity() (gridtypes.Capacity, error) {
 	return gridtypes.Capacity{
-		CRU: uint64(v.ComputeCapacity.CPU),
-		MRU: v.ComputeCapacity.Memory,
+		CRU: uint64(v.ComputeCapacity.MaxCPUs()),
+		MRU: v.ComputeCapacity.MaxMemorySize(),
 		SRU: v.RootSize(),
 	}, nil
 }
@@ -188,6 +216,10 @@ func (v ZMachineLight) Challenge(b io.Writer) error {
 		return err
 	}
 
+	if err := challengeSecretEnv(b, v.SecretEnv); err != nil {
+		return err
+	}
+
 	for _, gpu := range v.GPU {
 		if _, err := fmt.Fprintf(b, "%s", gpu); err != nil {
 			return err
@@ -1,7 +1,10 @@
 package zos
 
 import (
+	"bytes"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -116,3 +119,116 @@ func TestResultDeprecated(t *testing.T) {
 		ConsoleURL:  "10.20.2.0:20002",
 	}, result)
 }
+
+func TestSecretEnv(t *testing.T) {
+	require.NoError(t, validSecretEnv(map[string]string{"A": "a"}, map[string]string{"B": "abcd"}))
+	require.Error(t, validSecretEnv(map[string]string{"A": "a"}, map[string]string{"A": "abcd"}))
+	require.Error(t, validSecretEnv(nil, map[string]string{"B": "not hex"}))
+
+	data, err := json.Marshal(ZMachine{
+		Env:       map[string]string{"A": "a"},
+		SecretEnv: map[string]string{"B": "abcd"},
+	})
+	require.NoError(t, err)
+
+	wl := gridtypes.Workload{Type: ZMachineType, Data: data}
+	require.NoError(t, RedactSecrets(&wl))
+
+	var vm ZMachine
+	require.NoError(t, json.Unmarshal(wl.Data, &vm))
+	require.Equal(t, map[string]string{"A": "a"}, vm.Env)
+	require.Equal(t, map[string]string{"B": RedactedSecret}, vm.SecretEnv)
+}
+
+func TestZMachineMaxCapacity(t *testing.T) {
+	vm := ZMachine{
+		ComputeCapacity: MachineCapacity{
+			CPU:    1,
+			Memory: 1 * gridtypes.Gigabyte,
+		},
+	}
+
+	capacity, err := vm.Capacity()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, capacity.CRU)
+	require.Equal(t, 1*gridtypes.Gigabyte, capacity.MRU)
+
+	var before bytes.Buffer
+	require.NoError(t, vm.ComputeCapacity.Challenge(&before))
+	require.Equal(t, fmt.Sprintf("1%d", gridtypes.Gigabyte), before.String())
+
+	// the max capacity is reserved up front
+	vm.ComputeCapacity.MaxCPU = 4
+	vm.ComputeCapacity.MaxMemory = 4 * gridtypes.Gigabyte
+
+	capacity, err = vm.Capacity()
+	require.NoError(t, err)
+	require.EqualValues(t, 4, capacity.CRU)
+	require.Equal(t, 4*gridtypes.Gigabyte, capacity.MRU)
+
+	var after bytes.Buffer
+	require.NoError(t, vm.ComputeCapacity.Challenge(&after))
+	require.NotEqual(t, before.String(), after.String())
+
+	require.NoError(t, vm.ComputeCapacity.valid())
+	vm.ComputeCapacity.MaxMemory = 512 * gridtypes.Megabyte
+	require.Error(t, vm.ComputeCapacity.valid())
+}
+
+func TestMachineCapacityChallengeFields(t *testing.T) {
+	challenge := func(c MachineCapacity) string {
+		var buf bytes.Buffer
+		require.NoError(t, c.Challenge(&buf))
+		return buf.String()
+	}
+
+	// the same value in another optional field must not give the same hash
+	require.NotEqual(t, challenge(MachineCapacity{CPU: 1, Memory: 1, MaxCPU: 4}), challenge(MachineCapacity{CPU: 1, Memory: 1, MaxMemory: 4}))
+	require.NotEqual(t, challenge(MachineCapacity{CPU: 1, Memory: 1, MaxCPU: 41}), challenge(MachineCapacity{CPU: 1, Memory: 1, MaxCPU: 4, MaxMemory: 1}))
+	require.NotEqual(t, challenge(MachineCapacity{CPU: 1, Memory: 14}), challenge(MachineCapacity{CPU: 1, Memory: 1, MaxCPU: 4}))
+}
+
+func TestSecretEnvChallenge(t *testing.T) {
+	public := ZMachine{Env: map[string]string{"A": "abcd"}}
+	secret := ZMachine{SecretEnv: map[string]string{"A": "abcd"}}
+
+	var a, b bytes.Buffer
+	require.NoError(t, public.Challenge(&a))
+	require.NoError(t, secret.Challenge(&b))
+	require.NotEqual(t, a.String(), b.String())
+}
+
+func TestMachineEnv(t *testing.T) {
+	decrypt := func(data []byte) ([]byte, error) {
+		return append([]byte("plain-"), data...), nil
+	}
+
+	env, err := MachineEnv(map[string]string{"A": "a"}, map[string]string{"B": hex.EncodeToString([]byte("b"))}, decrypt)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"A": "a", "B": "plain-b"}, env)
+
+	_, err = MachineEnv(nil, map[string]string{"B": "not hex"}, decrypt)
+	require.Error(t, err)
+
+	_, err = MachineEnv(nil, map[string]string{"B": "abcd"}, func(data []byte) ([]byte, error) {
+		return nil, fmt.Errorf("wrong key")
+	})
+	require.Error(t, err)
+}
+
+func TestWatchdogChallenge(t *testing.T) {
+	challenge := func(vm ZMachine) string {
+		var buf bytes.Buffer
+		require.NoError(t, vm.Challenge(&buf))
+		return buf.String()
+	}
+
+	vm := ZMachine{FList: "https://hub.grid.tf/tf-official-apps/base:latest.flist"}
+	before := challenge(vm)
+
+	// the challenge of machines without a watchdog is not changed
+	require.NotContains(t, before, "watchdog")
+
+	vm.Watchdog = true
+	require.NotEqual(t, before, challenge(vm))
+}
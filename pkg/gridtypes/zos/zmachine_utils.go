@@ -51,6 +51,45 @@ func (c *MyceliumIP) Challenge(w io.Writer) error {
 type MachineCapacity struct {
 	CPU    uint8          `json:"cpu"`
 	Memory gridtypes.Unit `json:"memory"`
+	// MaxCPU is the max number of cpus the machine can be grown to while
+	// it's running. If not set the machine can't be grown.
+	MaxCPU uint8 `json:"max_cpu,omitempty"`
+	// MaxMemory is the max memory the machine can be grown to while it's
+	// running. If not set the machine can't be grown.
+	MaxMemory gridtypes.Unit `json:"max_memory,omitempty"`
+}
+
+// MaxCPUs is the max number of cpus of the machine, the node reserves
+// this capacity so the machine can always be grown to it
+func (c *MachineCapacity) MaxCPUs() uint8 {
+	if c.MaxCPU > c.CPU {
+		return c.MaxCPU
+	}
+
+	return c.CPU
+}
+
+// MaxMemorySize is the max memory of the machine, the node reserves
+// this capacity so the machine can always be grown to it
+func (c *MachineCapacity) MaxMemorySize() gridtypes.Unit {
+	if c.MaxMemory > c.Memory {
+		return c.MaxMemory
+	}
+
+	return c.Memory
+}
+
+// valid checks the max values of the capacity
+func (c *MachineCapacity) valid() error {
+	if c.MaxCPU != 0 && c.MaxCPU < c.CPU {
+		return fmt.Errorf("max cpu capacity can't be less than cpu capacity")
+	}
+
+	if c.MaxMemory != 0 && c.MaxMemory < c.Memory {
+		return fmt.Errorf("max mem capacity can't be less than mem capacity")
+	}
+
+	return nil
 }
 
 func (c *MachineCapacity) String() string {
@@ -67,6 +106,21 @@ func (c *MachineCapacity) Challenge(w io.Writer) error {
 		return err
 	}
 
+	// the max values are only part of the challenge when they are set so
+	// the signatures of older deployments stay valid. They are labelled so
+	// one can't be taken for the other
+	if c.MaxCPU != 0 {
+		if _, err := fmt.Fprintf(w, "max_cpu:%d;", c.MaxCPU); err != nil {
+			return err
+		}
+	}
+
+	if c.MaxMemory != 0 {
+		if _, err := fmt.Fprintf(w, "max_memory:%d;", c.MaxMemory); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
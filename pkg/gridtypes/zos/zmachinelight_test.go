@@ -1,6 +1,7 @@
 package zos
 
 import (
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -94,3 +95,47 @@ func TestZMachineLightSRU(t *testing.T) {
 		})
 	}
 }
+
+func TestZMachineLightValidInterfaces(t *testing.T) {
+	vm := ZMachineLight{
+		ComputeCapacity: MachineCapacity{
+			CPU:    1,
+			Memory: 1 * gridtypes.Gigabyte,
+		},
+	}
+
+	require.Error(t, vm.Valid(nil))
+
+	vm.Network.Interfaces = []MachineInterface{
+		{Network: "net1", IP: net.ParseIP("10.20.2.2")},
+		{Network: "net2", IP: net.ParseIP("10.30.2.2")},
+	}
+	require.NoError(t, vm.Valid(nil))
+
+	vm.Network.Interfaces = append(vm.Network.Interfaces, MachineInterface{
+		Network: "net1", IP: net.ParseIP("10.20.2.3"),
+	})
+	require.Error(t, vm.Valid(nil))
+}
+
+func TestZMachineLightValidNameservers(t *testing.T) {
+	vm := ZMachineLight{
+		ComputeCapacity: MachineCapacity{
+			CPU:    1,
+			Memory: 1 * gridtypes.Gigabyte,
+		},
+		Network: MachineNetworkLight{
+			Interfaces: []MachineInterface{
+				{Network: "net1", IP: net.ParseIP("10.20.2.2")},
+			},
+			Nameservers: []net.IP{net.ParseIP("10.20.0.53"), net.ParseIP("fd00::53")},
+		},
+	}
+	require.NoError(t, vm.Valid(nil))
+
+	vm.Network.Nameservers = []net.IP{net.ParseIP("0.0.0.0")}
+	require.Error(t, vm.Valid(nil))
+
+	vm.Network.Nameservers = []net.IP{{1, 2}}
+	require.Error(t, vm.Valid(nil))
+}
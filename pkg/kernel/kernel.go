@@ -24,6 +24,10 @@ const (
 
 	// Light means zos is running in light mode
 	Light = "light"
+
+	// ZosVersion pins the node to a zos version (hub tag) regardless
+	// of the version advertised by the chain
+	ZosVersion = "zos-version"
 )
 
 // Params represent the parameters passed to the kernel at boot
@@ -94,6 +98,11 @@ func (k Params) IsV3() bool {
 	return found && version == "v3"
 }
 
+// PinnedVersion returns the zos version the node is pinned to if zos-version is set
+func (k Params) PinnedVersion() (string, bool) {
+	return k.GetOne(ZosVersion)
+}
+
 // GPUDisabled checks if gpu is diabled
 func (k Params) IsGPUDisabled() bool {
 	return k.Exists(DisableGPU)
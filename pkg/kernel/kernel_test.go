@@ -61,3 +61,20 @@ func TestCmdParsing(t *testing.T) {
 		t.Error("`zerotier` is not set")
 	}
 }
+
+func TestPinnedVersion(t *testing.T) {
+	params := parseParams("zos-version=v3.10.2 runmode=prod")
+	if version, ok := params.PinnedVersion(); !ok || version != "v3.10.2" {
+		t.Errorf("expected pinned version v3.10.2, got '%s'", version)
+	}
+
+	params = parseParams("zos-version runmode=prod")
+	if _, ok := params.PinnedVersion(); ok {
+		t.Error("zos-version without a value must not pin the version")
+	}
+
+	params = parseParams("runmode=prod")
+	if _, ok := params.PinnedVersion(); ok {
+		t.Error("version must not be pinned")
+	}
+}
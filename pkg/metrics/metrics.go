@@ -0,0 +1,128 @@
+// Package metrics renders metrics in prometheus text exposition format so
+// they can be scraped without a prometheus client.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the content type of the prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4"
+
+const (
+	counter   = "counter"
+	gauge     = "gauge"
+	histogram = "histogram"
+)
+
+// Writer collects metric samples and writes them in prometheus text
+// exposition format. Families are written sorted by name, the samples of a
+// family in the order they were added.
+type Writer struct {
+	families map[string]*family
+}
+
+type family struct {
+	help    string
+	kind    string
+	samples []string
+}
+
+// NewWriter creates a new empty metrics writer
+func NewWriter() *Writer {
+	return &Writer{families: make(map[string]*family)}
+}
+
+// Gauge adds a gauge sample, labels are given as key, value pairs
+func (w *Writer) Gauge(name, help string, value float64, labels ...string) {
+	w.family(name, help, gauge).add(name, value, labels)
+}
+
+// Counter adds a counter sample, labels are given as key, value pairs
+func (w *Writer) Counter(name, help string, value float64, labels ...string) {
+	w.family(name, help, counter).add(name, value, labels)
+}
+
+// Histogram adds the samples of a histogram. buckets are the cumulative
+// counts of the observations less or equal to each of bounds, labels are
+// given as key, value pairs.
+func (w *Writer) Histogram(name, help string, bounds []float64, buckets []uint64, count uint64, sum float64, labels ...string) {
+	f := w.family(name, help, histogram)
+	for i, bound := range bounds {
+		f.add(name+"_bucket", float64(buckets[i]), append(labels[:len(labels):len(labels)], "le", formatFloat(bound)))
+	}
+	f.add(name+"_bucket", float64(count), append(labels[:len(labels):len(labels)], "le", "+Inf"))
+	f.add(name+"_sum", sum, labels)
+	f.add(name+"_count", float64(count), labels)
+}
+
+func (w *Writer) family(name, help, kind string) *family {
+	f, ok := w.families[name]
+	if !ok {
+		f = &family{help: help, kind: kind}
+		w.families[name] = f
+	}
+
+	return f
+}
+
+func (f *family) add(name string, value float64, labels []string) {
+	var buf strings.Builder
+	buf.WriteString(name)
+	if len(labels) > 0 {
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(&buf, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(value))
+
+	f.samples = append(f.samples, buf.String())
+}
+
+// WriteTo writes all metrics in prometheus text exposition format
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	names := make([]string, 0, len(w.families))
+	for name := range w.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf strings.Builder
+	for _, name := range names {
+		f := w.families[name]
+		fmt.Fprintf(&buf, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, f.kind)
+		for _, sample := range f.samples {
+			buf.WriteString(sample)
+			buf.WriteByte('\n')
+		}
+	}
+
+	n, err := io.WriteString(out, buf.String())
+	return int64(n), err
+}
+
+// Serve writes the metrics as the response of a prometheus scrape
+func Serve(w http.ResponseWriter, metrics io.WriterTo) {
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = metrics.WriteTo(w)
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
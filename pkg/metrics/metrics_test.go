@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterWriteTo(t *testing.T) {
+	metrics := NewWriter()
+	metrics.Gauge("b", "metric b", 2, "server", `a"b`)
+	metrics.Counter("a_total", "metric a", 1)
+	metrics.Gauge("b", "metric b", 3.5, "server", "c", "type", "tcp")
+	metrics.Histogram("c_seconds", "metric c", []float64{0.5, 1}, []uint64{1, 2}, 3, 2.75, "method", "get")
+	metrics.Counter("d_total", "metric d", 12345678)
+
+	var buf strings.Builder
+	_, err := metrics.WriteTo(&buf)
+	require.NoError(t, err)
+
+	expected := `# HELP a_total metric a
+# TYPE a_total counter
+a_total 1
+# HELP b metric b
+# TYPE b gauge
+b{server="a\"b"} 2
+b{server="c",type="tcp"} 3.5
+# HELP c_seconds metric c
+# TYPE c_seconds histogram
+c_seconds_bucket{method="get",le="0.5"} 1
+c_seconds_bucket{method="get",le="1"} 2
+c_seconds_bucket{method="get",le="+Inf"} 3
+c_seconds_sum{method="get"} 2.75
+c_seconds_count{method="get"} 3
+# HELP d_total metric d
+# TYPE d_total counter
+d_total 12345678
+`
+	assert.Equal(t, expected, buf.String())
+}
@@ -133,12 +133,8 @@ func RandomName(prefix string) (string, error) {
 	return fmt.Sprintf("%s%x", prefix, b), nil
 }
 
-// MakeVethPair creates a veth pair
-func MakeVethPair(name, master string, mtu int, peerPrefix string) (netlink.Link, error) {
-	masterLink, err := netlink.LinkByName(master)
-	if err != nil {
-		return nil, fmt.Errorf("master link: %s not found: %v", master, err)
-	}
+// VethPeerName returns the name of the peer of the veth created by MakeVethPair
+func VethPeerName(name, peerPrefix string) string {
 	peer := ""
 	if peerPrefix == "" {
 		peer = fmt.Sprintf("p-%s", name)
@@ -148,6 +144,17 @@ func MakeVethPair(name, master string, mtu int, peerPrefix string) (netlink.Link
 	if len(peer) > 15 {
 		peer = peer[0:15]
 	}
+
+	return peer
+}
+
+// MakeVethPair creates a veth pair
+func MakeVethPair(name, master string, mtu int, peerPrefix string) (netlink.Link, error) {
+	masterLink, err := netlink.LinkByName(master)
+	if err != nil {
+		return nil, fmt.Errorf("master link: %s not found: %v", master, err)
+	}
+	peer := VethPeerName(name, peerPrefix)
 	veth := &netlink.Veth{
 		LinkAttrs: netlink.LinkAttrs{
 			Name:  name,
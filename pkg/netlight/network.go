@@ -32,6 +32,7 @@ import (
 	"github.com/threefoldtech/zosbase/pkg/set"
 	"github.com/threefoldtech/zosbase/pkg/versioned"
 	"github.com/vishvananda/netlink"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
 const (
@@ -122,6 +123,12 @@ func (n *networker) Create(name string, wl gridtypes.WorkloadID, net zos.Network
 		return err
 	}
 
+	// set on every create so it's applied again after a reboot, or removed
+	// if the limit was dropped on update
+	if err = netr.SetBandwidthLimit(net); err != nil {
+		return errors.Wrap(err, "failed to set network bandwidth limit")
+	}
+
 	return n.setupWireguard(name, net, netr)
 }
 
@@ -148,11 +155,16 @@ func (n *networker) Delete(name string) error {
 }
 
 func (n *networker) AttachPrivate(name, id string, vmIp net.IP) (device pkg.TapDevice, err error) {
-	resource, err := resource.Get(name)
+	nr, err := n.networkOf(pkg.NetID(name))
+	if err != nil {
+		return device, errors.Wrapf(err, "couldn't load network (%s)", name)
+	}
+
+	netr, err := resource.Get(name)
 	if err != nil {
 		return
 	}
-	return resource.AttachPrivate(id, vmIp)
+	return netr.AttachPrivate(id, vmIp, resource.MTU(nr))
 }
 
 func (n *networker) AttachMycelium(name, id string, seed []byte) (device pkg.TapDevice, err error) {
@@ -212,7 +224,7 @@ func (n *networker) GetSubnet(networkID pkg.NetID) (net.IPNet, error) {
 	return localNR.Subnet.IPNet, nil
 }
 
-func (n *networker) networkOf(id pkg.NetID) (nr pkg.Network, err error) {
+func (n *networker) networkOf(id pkg.NetID) (nr zos.NetworkLight, err error) {
 	path := filepath.Join(n.networkDir, id.String())
 	file, err := os.OpenFile(path, os.O_RDWR, 0660)
 	if err != nil {
@@ -232,7 +244,7 @@ func (n *networker) networkOf(id pkg.NetID) (nr pkg.Network, err error) {
 		return nr, err
 	}
 
-	var net pkg.Network
+	var net zos.NetworkLight
 	dec := json.NewDecoder(reader)
 
 	version := reader.Version()
@@ -455,6 +467,7 @@ func (n *networker) SetPublicConfig(cfg pkg.PublicConfig) error {
 		return errors.Wrapf(err, "failed to load current public configuration")
 	}
 
+	cfg.KeepNameservers(current)
 	if current != nil && current.Equal(cfg) {
 		// nothing to do
 		return nil
@@ -523,6 +536,77 @@ func (n *networker) WireguardPorts() ([]uint, error) {
 	return n.portSet.List()
 }
 
+// wgDevice returns the wireguard device of a network resource
+var wgDevice = func(id pkg.NetID) (*wgtypes.Device, error) {
+	netr, err := resource.Get(string(id))
+	if err != nil {
+		return nil, err
+	}
+
+	nsName, err := netr.Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	wgName, err := netr.WGName()
+	if err != nil {
+		return nil, err
+	}
+
+	netNS, err := namespace.GetByName(nsName)
+	if err != nil {
+		return nil, err
+	}
+	defer netNS.Close()
+
+	var device *wgtypes.Device
+	err = netNS.Do(func(_ ns.NetNS) error {
+		wg, err := wireguard.GetByName(wgName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get wireguard interface %s", wgName)
+		}
+
+		device, err = wg.Device()
+		return err
+	})
+
+	return device, err
+}
+
+// WireguardStats returns the stats of the wireguard peers of a network
+func (n *networker) WireguardStats(id pkg.NetID) ([]pkg.PeerStat, error) {
+	device, err := wgDevice(id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't get wireguard device of network (%s)", id.String())
+	}
+
+	return peerStats(device), nil
+}
+
+func peerStats(device *wgtypes.Device) []pkg.PeerStat {
+	stats := make([]pkg.PeerStat, 0, len(device.Peers))
+	for _, peer := range device.Peers {
+		stat := pkg.PeerStat{
+			PublicKey:     peer.PublicKey.String(),
+			LastHandshake: peer.LastHandshakeTime,
+			RxBytes:       peer.ReceiveBytes,
+			TxBytes:       peer.TransmitBytes,
+		}
+
+		if peer.Endpoint != nil {
+			stat.Endpoint = peer.Endpoint.String()
+		}
+
+		for _, ip := range peer.AllowedIPs {
+			stat.AllowedIPs = append(stat.AllowedIPs, ip.String())
+		}
+
+		stats = append(stats, stat)
+	}
+
+	return stats
+}
+
 // GetNet of a network identified by the network ID
 func (n *networker) GetNet(id pkg.NetID) (net.IPNet, error) {
 	localNR, err := n.networkOf(id)
@@ -666,6 +750,13 @@ func (n networker) setupWireguard(name string, net zos.NetworkLight, netr *resou
 		}
 	}
 
+	// the mtu is set on every setup so it's applied again after a reboot
+	if net.MTU != 0 {
+		if err := netr.SetWGMTU(int(net.MTU)); err != nil {
+			return errors.Wrap(err, "failed to set wireguard interface mtu")
+		}
+	}
+
 	if len(net.WGPrivateKey) == 0 {
 		return n.releasePort(net.WGListenPort)
 	}
@@ -0,0 +1,126 @@
+package netlight
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zosbase/pkg/netlight/resource"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func testNetworker(t *testing.T) *networker {
+	dir := t.TempDir()
+	n := &networker{
+		networkDir:  dir,
+		linkDirPath: filepath.Join(dir, linkDir),
+	}
+	require.NoError(t, os.MkdirAll(n.linkDirPath, 0755))
+	return n
+}
+
+func TestNetworkMTUStored(t *testing.T) {
+	n := testNetworker(t)
+
+	network := zos.NetworkLight{
+		Subnet:         gridtypes.MustParseIPNet("10.1.2.0/24"),
+		NetworkIPRange: gridtypes.MustParseIPNet("10.1.0.0/16"),
+		WGListenPort:   6000,
+		MTU:            1400,
+	}
+
+	wl := gridtypes.NewUncheckedWorkloadID(1, 1, "net")
+	require.NoError(t, n.storeNetwork("net", wl, network))
+
+	// the network is loaded again from disk (on reboot) with the same mtu
+	// so it's applied again on the wireguard and tap devices
+	stored, err := n.networkOf(pkg.NetID("net"))
+	require.NoError(t, err)
+	require.EqualValues(t, 1400, stored.MTU)
+	require.Equal(t, 1400, resource.MTU(stored))
+	require.Equal(t, network.Subnet.String(), stored.Subnet.String())
+	require.EqualValues(t, 6000, stored.WGListenPort)
+}
+
+func TestNetworkMTUDefault(t *testing.T) {
+	n := testNetworker(t)
+
+	wl := gridtypes.NewUncheckedWorkloadID(1, 1, "net")
+	require.NoError(t, n.storeNetwork("net", wl, zos.NetworkLight{
+		Subnet: gridtypes.MustParseIPNet("10.1.2.0/24"),
+	}))
+
+	stored, err := n.networkOf(pkg.NetID("net"))
+	require.NoError(t, err)
+	require.Zero(t, stored.MTU)
+	require.Equal(t, 1500, resource.MTU(stored))
+}
+
+func withWGDevice(t *testing.T, device *wgtypes.Device) {
+	old := wgDevice
+	wgDevice = func(id pkg.NetID) (*wgtypes.Device, error) {
+		if device == nil {
+			return nil, fmt.Errorf("network '%s' not found", id)
+		}
+		return device, nil
+	}
+	t.Cleanup(func() { wgDevice = old })
+}
+
+func TestWireguardStats(t *testing.T) {
+	key1, err := wgtypes.GeneratePrivateKey()
+	require.NoError(t, err)
+	key2, err := wgtypes.GeneratePrivateKey()
+	require.NoError(t, err)
+
+	handshake := time.Now().Add(-time.Minute).UTC()
+	_, allowed, _ := net.ParseCIDR("10.1.3.0/24")
+	withWGDevice(t, &wgtypes.Device{
+		Name: "w-net",
+		Peers: []wgtypes.Peer{
+			{
+				PublicKey:         key1.PublicKey(),
+				Endpoint:          &net.UDPAddr{IP: net.ParseIP("185.69.166.10"), Port: 6000},
+				AllowedIPs:        []net.IPNet{*allowed},
+				LastHandshakeTime: handshake,
+				ReceiveBytes:      1024,
+				TransmitBytes:     2048,
+			},
+			{
+				// a peer that never connected
+				PublicKey: key2.PublicKey(),
+			},
+		},
+	})
+
+	n := testNetworker(t)
+	stats, err := n.WireguardStats(pkg.NetID("net"))
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+
+	require.Equal(t, key1.PublicKey().String(), stats[0].PublicKey)
+	require.Equal(t, "185.69.166.10:6000", stats[0].Endpoint)
+	require.Equal(t, []string{"10.1.3.0/24"}, stats[0].AllowedIPs)
+	require.Equal(t, handshake, stats[0].LastHandshake)
+	require.EqualValues(t, 1024, stats[0].RxBytes)
+	require.EqualValues(t, 2048, stats[0].TxBytes)
+
+	require.Equal(t, key2.PublicKey().String(), stats[1].PublicKey)
+	require.Empty(t, stats[1].Endpoint)
+	require.True(t, stats[1].LastHandshake.IsZero())
+}
+
+func TestWireguardStatsNotFound(t *testing.T) {
+	withWGDevice(t, nil)
+
+	n := testNetworker(t)
+	_, err := n.WireguardStats(pkg.NetID("net"))
+	require.Error(t, err)
+}
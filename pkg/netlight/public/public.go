@@ -269,8 +269,9 @@ func GetPublicSetup() (pkg.PublicConfig, error) {
 	if set, err := LoadPublicConfig(); err != nil {
 		return pkg.PublicConfig{}, errors.Wrap(err, "failed to load configuration")
 	} else {
-		// we only need the domain name from the config
+		// we only need the domain name and nameservers from the config
 		cfg.Domain = set.Domain
+		cfg.Nameservers = set.Nameservers
 	}
 	// everything else is loaded from the actual state of the node.
 	err = namespace.Do(func(_ ns.NetNS) error {
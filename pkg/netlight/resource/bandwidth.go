@@ -0,0 +1,118 @@
+package resource
+
+import (
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zosbase/pkg/netlight/namespace"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// minBurst is the min burst size of the bandwidth limit in bytes
+	minBurst = 16 * 1024
+	// bandwidthLatency is the max time in ms a packet waits in the
+	// bandwidth limit queue before it's dropped
+	bandwidthLatency = 50
+)
+
+// qdiscs is the subset of netlink used to manage the bandwidth limit
+type qdiscs interface {
+	LinkByName(name string) (netlink.Link, error)
+	QdiscList(link netlink.Link) ([]netlink.Qdisc, error)
+	QdiscReplace(qdisc netlink.Qdisc) error
+	QdiscDel(qdisc netlink.Qdisc) error
+}
+
+// tc is used to install the bandwidth limits, a zero handle works
+// on the namespace of the calling thread
+var tc qdiscs = &netlink.Handle{}
+
+// SetBandwidthLimit limits the egress rate of the network resource to the bandwidth
+// limit of the network. All traffic leaving the network resource (both public
+// and wireguard) goes out through the public interface of the resource namespace
+// so the limit is set there. If the network has no limit, any previously set limit
+// is removed.
+func (r *Resource) SetBandwidthLimit(nr zos.NetworkLight) error {
+	nsName, err := r.Namespace()
+	if err != nil {
+		return err
+	}
+
+	netNS, err := namespace.GetByName(nsName)
+	if err != nil {
+		return err
+	}
+	defer netNS.Close()
+
+	return netNS.Do(func(_ ns.NetNS) error {
+		if nr.BandwidthLimit == 0 {
+			return clearBandwidthLimit(infPublic)
+		}
+
+		return setBandwidthLimit(infPublic, nr.BandwidthLimit)
+	})
+}
+
+// setBandwidthLimit sets a tbf qdisc on the root of the link with a rate of mbps
+func setBandwidthLimit(name string, mbps uint32) error {
+	link, err := tc.LinkByName(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get link '%s'", name)
+	}
+
+	if err := tc.QdiscReplace(bandwidthQdisc(link, mbps)); err != nil {
+		return errors.Wrapf(err, "failed to set bandwidth limit on link '%s'", name)
+	}
+
+	return nil
+}
+
+// clearBandwidthLimit removes the tbf qdisc from the root of the link if set. It's
+// a no-op if the link has no bandwidth limit or does not exist.
+func clearBandwidthLimit(name string) error {
+	link, err := tc.LinkByName(name)
+	if errors.As(err, &netlink.LinkNotFoundError{}) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to get link '%s'", name)
+	}
+
+	qdiscs, err := tc.QdiscList(link)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list qdiscs of link '%s'", name)
+	}
+
+	for _, qdisc := range qdiscs {
+		if _, ok := qdisc.(*netlink.Tbf); !ok || qdisc.Attrs().Parent != netlink.HANDLE_ROOT {
+			continue
+		}
+
+		if err := tc.QdiscDel(qdisc); err != nil {
+			return errors.Wrapf(err, "failed to remove bandwidth limit of link '%s'", name)
+		}
+	}
+
+	return nil
+}
+
+func bandwidthQdisc(link netlink.Link, mbps uint32) *netlink.Tbf {
+	// rate in bytes per second
+	rate := uint64(mbps) * 1000 * 1000 / 8
+	// allow bursts of 10ms worth of traffic
+	burst := uint32(rate / 100)
+	if burst < minBurst {
+		burst = minBurst
+	}
+
+	return &netlink.Tbf{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Handle:    netlink.MakeHandle(1, 0),
+			Parent:    netlink.HANDLE_ROOT,
+		},
+		Rate:   rate,
+		Buffer: netlink.Xmittime(rate, burst),
+		Limit:  uint32(rate*bandwidthLatency/1000) + burst,
+	}
+}
@@ -0,0 +1,107 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+)
+
+// fakeTC records the qdiscs installed on the links
+type fakeTC struct {
+	links  map[string]netlink.Link
+	qdiscs map[int][]netlink.Qdisc
+}
+
+func (f *fakeTC) LinkByName(name string) (netlink.Link, error) {
+	link, ok := f.links[name]
+	if !ok {
+		return nil, netlink.LinkNotFoundError{}
+	}
+	return link, nil
+}
+
+func (f *fakeTC) QdiscList(link netlink.Link) ([]netlink.Qdisc, error) {
+	return f.qdiscs[link.Attrs().Index], nil
+}
+
+func (f *fakeTC) QdiscReplace(qdisc netlink.Qdisc) error {
+	index := qdisc.Attrs().LinkIndex
+	f.qdiscs[index] = []netlink.Qdisc{qdisc}
+	return nil
+}
+
+func (f *fakeTC) QdiscDel(qdisc netlink.Qdisc) error {
+	index := qdisc.Attrs().LinkIndex
+	var kept []netlink.Qdisc
+	for _, q := range f.qdiscs[index] {
+		if q.Attrs().Handle != qdisc.Attrs().Handle {
+			kept = append(kept, q)
+		}
+	}
+	f.qdiscs[index] = kept
+	return nil
+}
+
+func withTC(t *testing.T) *fakeTC {
+	fake := &fakeTC{
+		links: map[string]netlink.Link{
+			infPublic: &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: infPublic, Index: 10}},
+		},
+		qdiscs: map[int][]netlink.Qdisc{},
+	}
+
+	old := tc
+	tc = fake
+	t.Cleanup(func() { tc = old })
+	return fake
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	fake := withTC(t)
+
+	require.NoError(t, setBandwidthLimit(infPublic, 100))
+	require.Len(t, fake.qdiscs[10], 1)
+
+	tbf, ok := fake.qdiscs[10][0].(*netlink.Tbf)
+	require.True(t, ok)
+	require.Equal(t, 10, tbf.LinkIndex)
+	require.EqualValues(t, netlink.HANDLE_ROOT, tbf.Parent)
+	// 100 Mbps in bytes per second
+	require.EqualValues(t, 12500000, tbf.Rate)
+	require.NotZero(t, tbf.Buffer)
+	require.NotZero(t, tbf.Limit)
+
+	// changing the limit replaces the qdisc
+	require.NoError(t, setBandwidthLimit(infPublic, 10))
+	require.Len(t, fake.qdiscs[10], 1)
+	require.EqualValues(t, 1250000, fake.qdiscs[10][0].(*netlink.Tbf).Rate)
+
+	require.NoError(t, clearBandwidthLimit(infPublic))
+	require.Empty(t, fake.qdiscs[10])
+}
+
+func TestBandwidthLimitUnset(t *testing.T) {
+	fake := withTC(t)
+
+	// other qdiscs are left untouched
+	fq := &netlink.GenericQdisc{
+		QdiscAttrs: netlink.QdiscAttrs{LinkIndex: 10, Parent: netlink.HANDLE_ROOT},
+		QdiscType:  "fq_codel",
+	}
+	fake.qdiscs[10] = []netlink.Qdisc{fq}
+	require.NoError(t, clearBandwidthLimit(infPublic))
+	require.Equal(t, []netlink.Qdisc{fq}, fake.qdiscs[10])
+
+	// a missing link has no limit to clear
+	require.NoError(t, clearBandwidthLimit("missing"))
+	require.Error(t, setBandwidthLimit("missing", 10))
+}
+
+func TestBandwidthQdiscMinBurst(t *testing.T) {
+	link := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Index: 1}}
+
+	tbf := bandwidthQdisc(link, 1)
+	require.EqualValues(t, 125000, tbf.Rate)
+	require.Equal(t, netlink.Xmittime(tbf.Rate, minBurst), tbf.Buffer)
+}
@@ -30,6 +30,9 @@ const (
 	infPublic   = "public"
 	infPrivate  = "private"
 	infMycelium = "mycelium"
+
+	// defaultMTU of the resource links if the network has no mtu set
+	defaultMTU = 1500
 )
 
 //go:embed nft/rules.nft
@@ -90,7 +93,7 @@ func Create(name string, master *netlink.Bridge, ndmzIP *net.IPNet, ndmzGwIP *ne
 		}
 
 		if !ifaceutil.Exists(infPrivate, netNS) {
-			privateLink, err := ifaceutil.MakeVethPair(infPrivate, privateNetBr, 1500, peerPrefix)
+			privateLink, err := ifaceutil.MakeVethPair(infPrivate, privateNetBr, MTU(nr), peerPrefix)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create private link: %w", err)
 			}
@@ -99,6 +102,8 @@ func Create(name string, master *netlink.Bridge, ndmzIP *net.IPNet, ndmzGwIP *ne
 				return nil, fmt.Errorf("failed to move public link %s to namespace:%s : %w", infPublic, netNS.Path(), err)
 			}
 
+		} else if err := updatePrivateMTU(netNS, peerPrefix, MTU(nr)); err != nil {
+			return nil, fmt.Errorf("failed to update private link mtu: %w", err)
 		}
 	}
 
@@ -190,6 +195,12 @@ func Delete(name string) error {
 		errs = multierror.Append(errs, fmt.Errorf("failed to destroy mycelium: %w", err))
 	}
 
+	if err := netNS.Do(func(_ ns.NetNS) error {
+		return clearBandwidthLimit(infPublic)
+	}); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("failed to remove bandwidth limit: %w", err))
+	}
+
 	if err := namespace.Delete(netNS); err != nil {
 		errs = multierror.Append(errs, fmt.Errorf("failed to delete namespace: %w", err))
 	}
@@ -246,7 +257,59 @@ var defaultNet = net.IPNet{
 	Mask: net.IPv4Mask(0, 0, 0, 0),
 }
 
-func (r *Resource) AttachPrivate(id string, vmIp net.IP) (device localPkg.TapDevice, err error) {
+// mtuLinks are the netlink calls used to update the mtu of existing links
+type mtuLinks interface {
+	LinkByName(name string) (netlink.Link, error)
+	LinkSetMTU(link netlink.Link, mtu int) error
+}
+
+// links is used to update the mtu of existing links, a zero handle works
+// on the namespace of the calling thread
+var links mtuLinks = &netlink.Handle{}
+
+// updatePrivateMTU sets the mtu of an existing private veth, in case the mtu
+// of the network was changed. The private end is in the resource namespace,
+// its peer is on the private bridge.
+func updatePrivateMTU(netNS ns.NetNS, peerPrefix string, mtu int) error {
+	if err := setMTU(ifaceutil.VethPeerName(infPrivate, peerPrefix), mtu); err != nil {
+		return err
+	}
+
+	return netNS.Do(func(_ ns.NetNS) error {
+		return setMTU(infPrivate, mtu)
+	})
+}
+
+// setMTU sets the mtu of the link if it's different
+func setMTU(name string, mtu int) error {
+	link, err := links.LinkByName(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get link '%s'", name)
+	}
+
+	if link.Attrs().MTU == mtu {
+		return nil
+	}
+
+	if err := links.LinkSetMTU(link, mtu); err != nil {
+		return errors.Wrapf(err, "failed to set mtu of link '%s'", name)
+	}
+
+	return nil
+}
+
+// MTU returns the mtu of the network resource links
+func MTU(nr zos.NetworkLight) int {
+	if nr.MTU == 0 {
+		return defaultMTU
+	}
+
+	return int(nr.MTU)
+}
+
+// AttachPrivate creates a tap device with the given mtu on the private bridge of the resource.
+// If the tap already exists its mtu is updated.
+func (r *Resource) AttachPrivate(id string, vmIp net.IP, mtu int) (device localPkg.TapDevice, err error) {
 	nsName, err := r.Namespace()
 	if err != nil {
 		return
@@ -293,9 +356,9 @@ func (r *Resource) AttachPrivate(id string, vmIp net.IP) (device localPkg.TapDev
 		IP:   vmIp,
 		Mask: gw.Mask,
 	}
-	_, getLinkErr := netlink.LinkByName(tapName)
+	link, getLinkErr := netlink.LinkByName(tapName)
 	if getLinkErr != nil {
-		mtap, err := tuntap.CreateTap(tapName, privateNetBr)
+		mtap, err := tuntap.CreateTap(tapName, privateNetBr, mtu)
 		if err != nil {
 			return localPkg.TapDevice{}, err
 		}
@@ -305,6 +368,10 @@ func (r *Resource) AttachPrivate(id string, vmIp net.IP) (device localPkg.TapDev
 		}); err != nil {
 			return localPkg.TapDevice{}, err
 		}
+	} else if link.Attrs().MTU != mtu {
+		if err := netlink.LinkSetMTU(link, mtu); err != nil {
+			return localPkg.TapDevice{}, errors.Wrapf(err, "failed to set mtu of tap device '%s'", tapName)
+		}
 	}
 
 	routes := []localPkg.Route{
@@ -353,7 +420,7 @@ func (r *Resource) AttachMycelium(id string, seed []byte) (device localPkg.TapDe
 	myBr := fmt.Sprintf("m%s", r.name)
 	hw := ifaceutil.HardwareAddrFromInputBytes([]byte(tapName))
 
-	_, err = tuntap.CreateTap(tapName, myBr)
+	_, err = tuntap.CreateTap(tapName, myBr, defaultMTU)
 	if err != nil {
 		return
 	}
@@ -531,6 +598,38 @@ func (r *Resource) SetWireguard(wg *wireguard.Wireguard) error {
 	return netlink.LinkSetNsFd(wg, int(nrNetNS.Fd()))
 }
 
+// SetWGMTU sets the mtu of the wireguard interface of the network resource
+func (r *Resource) SetWGMTU(mtu int) error {
+	nsName, err := r.Namespace()
+	if err != nil {
+		return err
+	}
+
+	nrNetNS, err := namespace.GetByName(nsName)
+	if err != nil {
+		return err
+	}
+	defer nrNetNS.Close()
+
+	wgName, err := r.WGName()
+	if err != nil {
+		return err
+	}
+
+	return nrNetNS.Do(func(_ ns.NetNS) error {
+		wg, err := wireguard.GetByName(wgName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get wireguard interface %s", wgName)
+		}
+
+		if wg.Attrs().MTU == mtu {
+			return nil
+		}
+
+		return netlink.LinkSetMTU(wg, mtu)
+	})
+}
+
 // ConfigureWG sets the routes and IP addresses on the
 // wireguard interface of the network resources
 func (r *Resource) ConfigureWG(privateKey string) error {
@@ -0,0 +1,63 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+)
+
+// fakeLinks records the mtu set on the links
+type fakeLinks struct {
+	links map[string]netlink.Link
+	set   int
+}
+
+func (f *fakeLinks) LinkByName(name string) (netlink.Link, error) {
+	link, ok := f.links[name]
+	if !ok {
+		return nil, netlink.LinkNotFoundError{}
+	}
+	return link, nil
+}
+
+func (f *fakeLinks) LinkSetMTU(link netlink.Link, mtu int) error {
+	link.Attrs().MTU = mtu
+	f.set++
+	return nil
+}
+
+// fakeNS runs the namespace functions in the current namespace
+type fakeNS struct {
+	ns.NetNS
+}
+
+func (fakeNS) Do(fn func(ns.NetNS) error) error {
+	return fn(nil)
+}
+
+func TestUpdatePrivateMTU(t *testing.T) {
+	private := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: infPrivate, MTU: 1500}}
+	peer := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "abcd-private", MTU: 1500}}
+	fake := &fakeLinks{links: map[string]netlink.Link{
+		infPrivate:     private,
+		"abcd-private": peer,
+	}}
+
+	old := links
+	links = fake
+	t.Cleanup(func() { links = old })
+
+	// the network mtu was changed after the veth was created
+	require.NoError(t, updatePrivateMTU(fakeNS{}, "abcd", 1420))
+	require.Equal(t, 1420, private.MTU)
+	require.Equal(t, 1420, peer.MTU)
+	require.Equal(t, 2, fake.set)
+
+	// nothing is set if the mtu didn't change
+	require.NoError(t, updatePrivateMTU(fakeNS{}, "abcd", 1420))
+	require.Equal(t, 2, fake.set)
+
+	require.Error(t, updatePrivateMTU(fakeNS{}, "other", 1420))
+}
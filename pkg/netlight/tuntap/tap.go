@@ -8,8 +8,8 @@ import (
 	"github.com/vishvananda/netlink"
 )
 
-// CreateTap creates a new tap device with the given name, and sets the master interface
-func CreateTap(name string, master string) (*netlink.Tuntap, error) {
+// CreateTap creates a new tap device with the given name and mtu, and sets the master interface
+func CreateTap(name string, master string, mtu int) (*netlink.Tuntap, error) {
 	masterIface, err := netlink.LinkByName(master)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to look up tap master")
@@ -17,7 +17,7 @@ func CreateTap(name string, master string) (*netlink.Tuntap, error) {
 
 	tap := &netlink.Tuntap{
 		LinkAttrs: netlink.LinkAttrs{
-			MTU:         1500,
+			MTU:         mtu,
 			Name:        name,
 			ParentIndex: masterIface.Attrs().Index,
 		},
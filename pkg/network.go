@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"time"
 
 	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes"
@@ -57,6 +58,49 @@ func (e *ExitDevice) String() string {
 	return "unknown"
 }
 
+// ExitSource is what decided the public exit of the node
+type ExitSource string
+
+const (
+	// ExitSourceAuto the exit nic was auto detected
+	ExitSourceAuto ExitSource = "auto"
+	// ExitSourceFallback no exit nic was detected, so zos bridge is used
+	ExitSourceFallback ExitSource = "fallback"
+	// ExitSourceOperator the exit was set by the farmer
+	ExitSourceOperator ExitSource = "operator"
+	// ExitSourceKept the exit from the previous setup was kept
+	ExitSourceKept ExitSource = "kept"
+)
+
+// ExitDecision is a record of how the public exit (br-pub uplink) was chosen
+type ExitDecision struct {
+	Time   time.Time  `json:"time"`
+	Source ExitSource `json:"source"`
+	// Exit is the name of the exit link, zos in case of a single nic setup
+	Exit   string `json:"exit"`
+	Reason string `json:"reason"`
+}
+
+// ExitLinkStatus is the live state of the public exit (br-pub uplink)
+type ExitLinkStatus struct {
+	// Link is the name of the exit link, empty if br-pub has no uplink
+	Link    string `json:"link"`
+	Carrier bool   `json:"carrier"`
+	// Public is set if the node has a public namespace, GlobalIPv6
+	// is only tracked in that case
+	Public     bool `json:"public"`
+	GlobalIPv6 bool `json:"global_ipv6"`
+	// Flaps is the number of times the exit went down since it's watched
+	Flaps uint64 `json:"flaps"`
+	// Since is the time of the last change of the exit state
+	Since time.Time `json:"since"`
+}
+
+// Up returns true if the exit has carrier, and a global ipv6 if it's tracked
+func (s *ExitLinkStatus) Up() bool {
+	return s.Carrier && (!s.Public || s.GlobalIPv6)
+}
+
 type NetResourceMetrics map[string]NetMetric
 
 // Networker is the interface for the network module
@@ -150,6 +194,10 @@ type Networker interface {
 	// RemovePubIPFilter removes the filter setted up by SetupPubIPFilter
 	RemovePubIPFilter(filterName string) error
 
+	// ResyncPubIPFilter rebuilds the filter of this public ip atomically,
+	// then removes the stale rules of the filter
+	ResyncPubIPFilter(filterName string, iface string, ipv4 net.IP, ipv6 net.IP, mac string) error
+
 	// PubIPFilterExists checks if there is a filter installed with that name
 	PubIPFilterExists(filterName string) bool
 	// DisconnectPubTap disconnects the public tap from the network. The interface
@@ -206,6 +254,12 @@ type Networker interface {
 
 	SetPublicExitDevice(iface string) error
 
+	// GetPublicExitHistory returns the history of public exit decisions, oldest first
+	GetPublicExitHistory() ([]ExitDecision, error)
+
+	// GetPublicExitStatus returns the live state of the public exit link
+	GetPublicExitStatus() (ExitLinkStatus, error)
+
 	Metrics() (NetResourceMetrics, error)
 	// Monitoring methods
 
@@ -258,12 +312,89 @@ type PublicConfig struct {
 	// Domain is the node domain name like gent01.devnet.grid.tf
 	// or similar
 	Domain string `json:"domain"`
+
+	// Nameservers of the public namespace, the default public
+	// nameservers are used if not set
+	Nameservers []net.IP `json:"nameservers,omitempty"`
 }
 
 func (p *PublicConfig) IsEmpty() bool {
 	return p.IPv4.Nil() && p.IPv6.Nil()
 }
 
+// Validate checks that the public config is consistent. Each ip family
+// needs both an address and a gateway of that family, and the gateway must
+// be reachable from the address subnet (or link local for ipv6). Nameservers
+// must be unicast addresses.
+func (p *PublicConfig) Validate() error {
+	if p.IsEmpty() {
+		return fmt.Errorf("public config must have an ipv4 or an ipv6")
+	}
+
+	if err := validateFamily("ipv4", p.IPv4, p.GW4, false); err != nil {
+		return err
+	}
+
+	if err := validateFamily("ipv6", p.IPv6, p.GW6, true); err != nil {
+		return err
+	}
+
+	return gridtypes.ValidateNameservers(p.Nameservers)
+}
+
+func validateFamily(family string, ip gridtypes.IPNet, gw net.IP, v6 bool) error {
+	if ip.Nil() {
+		if gw != nil {
+			return fmt.Errorf("%s gateway '%s' is set without an %s", family, gw, family)
+		}
+		return nil
+	}
+
+	isFamily := func(ip net.IP) bool {
+		return ip != nil && (ip.To4() == nil) == v6
+	}
+
+	if !isFamily(ip.IP) {
+		return fmt.Errorf("'%s' is not a valid %s", ip.IP, family)
+	}
+
+	ones, bits := ip.Mask.Size()
+	if (v6 && bits != 128) || (!v6 && bits != 32) {
+		return fmt.Errorf("%s '%s' has an invalid subnet mask", family, ip.IP)
+	}
+
+	if gw == nil {
+		return fmt.Errorf("%s '%s' is set without a gateway", family, ip.String())
+	}
+
+	if !isFamily(gw) {
+		return fmt.Errorf("%s gateway '%s' is not a valid %s", family, gw, family)
+	}
+
+	if gw.Equal(ip.IP) {
+		return fmt.Errorf("%s gateway '%s' is the same as the node ip", family, gw)
+	}
+
+	subnet := net.IPNet{IP: ip.IP.Mask(ip.Mask), Mask: ip.Mask}
+	if !v6 && ones < 31 {
+		// on ipv4 subnets the network address can't be used for the node
+		if ip.IP.Equal(subnet.IP) {
+			return fmt.Errorf("%s '%s' is the network address of the subnet", family, ip.String())
+		}
+	}
+
+	if v6 && gw.IsLinkLocalUnicast() {
+		// link local gateways are always reachable
+		return nil
+	}
+
+	if !subnet.Contains(gw) {
+		return fmt.Errorf("%s gateway '%s' is not in the subnet '%s'", family, gw, subnet.String())
+	}
+
+	return nil
+}
+
 func PublicConfigFrom(cfg substrate.PublicConfig) (pub PublicConfig, err error) {
 	pub.Type = MacVlanIface
 	pub.IPv4, err = gridtypes.ParseIPNet(cfg.IP4.IP)
@@ -281,6 +412,18 @@ func PublicConfigFrom(cfg substrate.PublicConfig) (pub PublicConfig, err error)
 	return
 }
 
+// KeepNameservers sets the nameservers of the config to the ones of current
+// if it has none. The node public config on the chain has no nameservers, so
+// applying a config loaded from the chain must not drop the ones already set
+// on the node.
+func (p *PublicConfig) KeepNameservers(current *PublicConfig) {
+	if len(p.Nameservers) != 0 || current == nil {
+		return
+	}
+
+	p.Nameservers = current.Nameservers
+}
+
 func (p PublicConfig) Equal(cfg PublicConfig) bool {
 	return reflect.DeepEqual(p, cfg)
 }
@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
 	"github.com/vishvananda/netlink"
 )
 
+// networksCacheDir is where networkd stores the network configs
+const networksCacheDir = "/var/run/cache/networkd/networks/"
+
 func CleanupUnusedLinks() error {
 	links, err := netlink.LinkList()
 	if err != nil {
@@ -53,13 +58,11 @@ func isOrphanVMTap(interfaceName string) bool {
 // CleanupOrphanedNamespaces removes network namespaces that start with "n-"
 // but don't have corresponding files in /var/run/cache/networkd/networks/
 func CleanupOrphanedNamespaces() {
-	const networksDir = "/var/run/cache/networkd/networks/"
-
 	// Get list of files in the networks directory
 	validNetworkIDs := make(map[string]bool)
-	entries, err := os.ReadDir(networksDir)
+	entries, err := os.ReadDir(networksCacheDir)
 	if err != nil {
-		log.Warn().Str("networkDir", networksDir).Err(err).Msg("failed to read networks dir")
+		log.Warn().Str("networkDir", networksCacheDir).Err(err).Msg("failed to read networks dir")
 		return
 	}
 
@@ -112,3 +115,64 @@ func CleanupOrphanedNamespaces() {
 	}
 
 }
+
+// StaleNetworkConfigs returns the IDs of the stored network config files
+// in /var/run/cache/networkd/networks/ that are not in the active set
+func StaleNetworkConfigs(active map[zos.NetID]struct{}) ([]zos.NetID, error) {
+	return staleNetworkConfigs(networksCacheDir, active)
+}
+
+// RemoveNetworkConfig removes the stored config file of the network and
+// all workload links that point to it.
+func RemoveNetworkConfig(id zos.NetID) error {
+	return removeNetworkConfig(networksCacheDir, id)
+}
+
+func staleNetworkConfigs(dir string, active map[zos.NetID]struct{}) ([]zos.NetID, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read networks dir: %w", err)
+	}
+
+	var stale []zos.NetID
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		id := zos.NetID(entry.Name())
+		if _, ok := active[id]; !ok {
+			stale = append(stale, id)
+		}
+	}
+
+	return stale, nil
+}
+
+func removeNetworkConfig(dir string, id zos.NetID) error {
+	links := filepath.Join(dir, linkDir)
+	entries, err := os.ReadDir(links)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read network links dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(links, entry.Name())
+		target, err := os.Readlink(path)
+		if err != nil || filepath.Base(target) != id.String() {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove network link '%s': %w", path, err)
+		}
+	}
+
+	path := filepath.Join(dir, id.String())
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove network config '%s': %w", path, err)
+	}
+
+	log.Info().Str("network-id", id.String()).Msg("removed stale network config")
+	return nil
+}
@@ -0,0 +1,36 @@
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+func TestStaleNetworkConfigs(t *testing.T) {
+	dir := t.TempDir()
+	links := filepath.Join(dir, linkDir)
+	require.NoError(t, os.MkdirAll(links, 0755))
+
+	for _, id := range []string{"active", "stale"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, id), []byte("{}"), 0644))
+		require.NoError(t, os.Symlink(filepath.Join("../", id), filepath.Join(links, "1-1-"+id)))
+	}
+
+	active := map[zos.NetID]struct{}{"active": {}}
+	stale, err := staleNetworkConfigs(dir, active)
+	require.NoError(t, err)
+	require.Equal(t, []zos.NetID{"stale"}, stale)
+
+	require.NoError(t, removeNetworkConfig(dir, "stale"))
+	require.NoFileExists(t, filepath.Join(dir, "stale"))
+	require.NoFileExists(t, filepath.Join(links, "1-1-stale"))
+	require.FileExists(t, filepath.Join(dir, "active"))
+	require.FileExists(t, filepath.Join(links, "1-1-active"))
+
+	stale, err = staleNetworkConfigs(dir, active)
+	require.NoError(t, err)
+	require.Empty(t, stale)
+}
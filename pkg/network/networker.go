@@ -133,6 +133,9 @@ func NewNetworker(identity *stubs.IdentityManagerStub, ndmz ndmz.DMZ, ygg *yggdr
 		return nil, err
 	}
 
+	// the exit link is watched for the whole life of networkd
+	go public.KeepWatchingExitLink(context.Background())
+
 	return nw, nil
 }
 
@@ -633,32 +636,7 @@ func (n *networker) SetupPubIPFilter(filterName string, iface string, ipv4 net.I
 		return nil
 	}
 
-	ipv4 = ipv4.To4()
-	ipv6 = ipv6.To16()
-	// if no ipv4 or ipv6 provided, we make sure
-	// to use zero ip so the user can't just assign
-	// an ip to his vm to use.
-	if len(ipv4) == 0 {
-		ipv4 = net.IPv4zero
-	}
-
-	if len(ipv6) == 0 {
-		ipv6 = net.IPv6zero
-	}
-
-	data := struct {
-		Name  string
-		Iface string
-		Mac   string
-		IPv4  string
-		IPv6  string
-	}{
-		Name:  filterName,
-		Iface: iface,
-		Mac:   mac,
-		IPv4:  ipv4.String(),
-		IPv6:  ipv6.String(),
-	}
+	data := newPubIPFilter(filterName, iface, ipv4, ipv6, mac)
 
 	var buffer bytes.Buffer
 	if err := pubIpTemplateSetup.Execute(&buffer, data); err != nil {
@@ -1102,11 +1080,16 @@ func (n *networker) SetPublicConfig(cfg pkg.PublicConfig) error {
 		return fmt.Errorf("public config cannot be unset, only modified")
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return errors.Wrap(err, "invalid public config")
+	}
+
 	current, err := public.LoadPublicConfig()
 	if err != nil && err != public.ErrNoPublicConfig {
 		return errors.Wrapf(err, "failed to load current public configuration")
 	}
 
+	cfg.KeepNameservers(current)
 	if current != nil && current.Equal(cfg) {
 		// nothing to do
 		return nil
@@ -1183,6 +1166,14 @@ func (n *networker) GetPublicExitDevice() (pkg.ExitDevice, error) {
 	return pkg.ExitDevice{IsDual: true, AsDualInterface: exit.Attrs().Name}, nil
 }
 
+func (n *networker) GetPublicExitHistory() ([]pkg.ExitDecision, error) {
+	return public.LoadExitDecisions()
+}
+
+func (n *networker) GetPublicExitStatus() (pkg.ExitLinkStatus, error) {
+	return public.ExitLinkStatus()
+}
+
 // Get node public namespace config
 func (n *networker) GetPublicConfig() (pkg.PublicConfig, error) {
 	// TODO: instea of loading, this actually must get
@@ -27,6 +27,14 @@ func IPv6Disable(f bool) Option {
 	}
 }
 
+// AcceptDAD enables or disables duplicate address detection for ipv6
+func AcceptDAD(f bool) Option {
+	return &sysOption{
+		key: "net/ipv6/conf/%s/accept_dad",
+		val: flag(f),
+	}
+}
+
 // ProxyArp sets proxy arp on interface
 func ProxyArp(f bool) Option {
 	return &sysOption{
@@ -0,0 +1,186 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg/netbase/nft"
+)
+
+// pubIPTemplateResync rebuilds the chains of a public ip filter and the jumps
+// to them. It's applied with a single nft transaction so the public ip is
+// never left without filter rules.
+var pubIPTemplateResync = template.Must(template.New("filter-resync").Parse(
+	`add chain bridge filter {{.Name}}-pre
+add chain bridge filter {{.Name}}-post
+flush chain bridge filter {{.Name}}-pre
+flush chain bridge filter {{.Name}}-post
+
+add rule bridge filter prerouting iifname "{{.Iface}}" jump {{.Name}}-pre
+add rule bridge filter postrouting oifname "{{.Iface}}" jump {{.Name}}-post
+
+add rule bridge filter {{.Name}}-pre ip saddr . ether saddr != { {{.IPv4}} . {{.Mac}} } counter drop
+add rule bridge filter {{.Name}}-pre arp operation reply arp saddr ip != {{.IPv4}} counter drop
+add rule bridge filter {{.Name}}-pre arp operation request arp saddr ip != {{.IPv4}} counter drop
+
+add rule bridge filter {{.Name}}-post ip daddr . ether daddr != { {{.IPv4}} . {{.Mac}} } counter drop
+`))
+
+type pubIPFilter struct {
+	Name  string
+	Iface string
+	Mac   string
+	IPv4  string
+	IPv6  string
+}
+
+func newPubIPFilter(filterName string, iface string, ipv4 net.IP, ipv6 net.IP, mac string) pubIPFilter {
+	ipv4 = ipv4.To4()
+	ipv6 = ipv6.To16()
+	// if no ipv4 or ipv6 provided, we make sure
+	// to use zero ip so the user can't just assign
+	// an ip to his vm to use.
+	if len(ipv4) == 0 {
+		ipv4 = net.IPv4zero
+	}
+
+	if len(ipv6) == 0 {
+		ipv6 = net.IPv6zero
+	}
+
+	return pubIPFilter{
+		Name:  filterName,
+		Iface: iface,
+		Mac:   mac,
+		IPv4:  ipv4.String(),
+		IPv6:  ipv6.String(),
+	}
+}
+
+// nftRule is a rule of an nft chain found by its handle
+type nftRule struct {
+	table  string
+	chain  string
+	handle int
+}
+
+// nftListTable lists the rules of table with their handles, a missing table
+// is listed as empty
+func nftListTable(family, table string) string {
+	output, err := exec.Command("nft", "-a", "list", "table", family, table).Output()
+	if err != nil {
+		log.Debug().Err(err).Str("table", family+" "+table).Msg("failed to list nft table")
+		return ""
+	}
+
+	return string(output)
+}
+
+// nftJumps returns the rules of the listed table that jump to chain
+func nftJumps(table, listing, chain string) []nftRule {
+	var rules []nftRule
+	var current string
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "chain" {
+			current = fields[1]
+			continue
+		}
+
+		jump := false
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] == "jump" && fields[i+1] == chain {
+				jump = true
+				break
+			}
+		}
+
+		if !jump || len(fields) < 2 || fields[len(fields)-2] != "handle" {
+			continue
+		}
+
+		handle, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			continue
+		}
+
+		rules = append(rules, nftRule{table: table, chain: current, handle: handle})
+	}
+
+	return rules
+}
+
+// nftHasChain checks if the listed table has chain
+func nftHasChain(listing, chain string) bool {
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "chain" && fields[1] == chain {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pubIPFilterStale returns the nft commands that remove the jumps to the
+// filter chains listed before a resync, and the chains of the filter left by
+// older setups
+func pubIPFilterStale(bridge, arp, filterName string) string {
+	var buf strings.Builder
+	for _, chain := range []string{filterName + "-pre", filterName + "-post", filterName} {
+		for _, rule := range nftJumps("bridge filter", bridge, chain) {
+			fmt.Fprintf(&buf, "delete rule %s %s handle %d\n", rule.table, rule.chain, rule.handle)
+		}
+	}
+
+	for _, rule := range nftJumps("arp filter", arp, filterName) {
+		fmt.Fprintf(&buf, "delete rule %s %s handle %d\n", rule.table, rule.chain, rule.handle)
+	}
+
+	// the chain with the filter name (no -pre or -post) is only used
+	// by older setups
+	if nftHasChain(bridge, filterName) {
+		fmt.Fprintf(&buf, "flush chain bridge filter %s\ndelete chain bridge filter %s\n", filterName, filterName)
+	}
+
+	if nftHasChain(arp, filterName) {
+		fmt.Fprintf(&buf, "flush chain arp filter %s\ndelete chain arp filter %s\n", filterName, filterName)
+	}
+
+	return buf.String()
+}
+
+// ResyncPubIPFilter rebuilds the filter of the public ip. The filter chains
+// and the jumps to them are applied in one nft transaction, then the jumps that
+// were there before and the chains of older setups are removed.
+func (n *networker) ResyncPubIPFilter(filterName string, iface string, ipv4 net.IP, ipv6 net.IP, mac string) error {
+	bridge := nftListTable("bridge", "filter")
+	arp := nftListTable("arp", "filter")
+
+	var buffer bytes.Buffer
+	if err := pubIPTemplateResync.Execute(&buffer, newPubIPFilter(filterName, iface, ipv4, ipv6, mac)); err != nil {
+		return errors.Wrap(err, "failed to execute filter template")
+	}
+
+	if err := nft.Apply(&buffer, ""); err != nil {
+		return errors.Wrap(err, "could not resync firewall rules for public ip")
+	}
+
+	stale := pubIPFilterStale(bridge, arp, filterName)
+	if len(stale) == 0 {
+		return nil
+	}
+
+	if err := nft.Apply(strings.NewReader(stale), ""); err != nil {
+		return errors.Wrap(err, "could not remove stale firewall rules for public ip")
+	}
+
+	return nil
+}
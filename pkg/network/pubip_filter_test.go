@@ -0,0 +1,64 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const bridgeListing = `table bridge filter { # handle 3
+	chain prerouting { # handle 1
+		type filter hook prerouting priority filter; policy accept;
+		iifname "p-abc" jump r-abc-pre # handle 10
+		iifname "p-other" jump r-other-pre # handle 11
+	}
+
+	chain postrouting { # handle 2
+		type filter hook postrouting priority filter; policy accept;
+		oifname "p-abc" jump r-abc-post # handle 12
+	}
+
+	chain forward { # handle 4
+		type filter hook forward priority filter; policy accept;
+		jump r-abc # handle 13
+	}
+
+	chain r-abc { # handle 5
+		counter packets 0 bytes 0 drop # handle 14
+	}
+
+	chain r-abc-pre { # handle 6
+		ip saddr . ether saddr != { 185.0.0.1 . 00:11:22:33:44:55 } counter packets 0 bytes 0 drop # handle 15
+	}
+
+	chain r-abc-post { # handle 7
+	}
+}
+`
+
+func TestPubIPFilterResync(t *testing.T) {
+	var buffer bytes.Buffer
+	filter := newPubIPFilter("r-abc", "p-abc", net.ParseIP("185.0.0.1"), nil, "00:11:22:33:44:55")
+	require.NoError(t, pubIPTemplateResync.Execute(&buffer, filter))
+
+	script := buffer.String()
+	require.Contains(t, script, "flush chain bridge filter r-abc-pre\n")
+	require.Contains(t, script, "add rule bridge filter prerouting iifname \"p-abc\" jump r-abc-pre\n")
+	require.Contains(t, script, "add rule bridge filter r-abc-pre ip saddr . ether saddr != { 185.0.0.1 . 00:11:22:33:44:55 } counter drop\n")
+	require.Contains(t, script, "add rule bridge filter r-abc-post ip daddr . ether daddr != { 185.0.0.1 . 00:11:22:33:44:55 } counter drop\n")
+}
+
+func TestPubIPFilterStale(t *testing.T) {
+	stale := pubIPFilterStale(bridgeListing, "", "r-abc")
+	require.Equal(t, `delete rule bridge filter prerouting handle 10
+delete rule bridge filter postrouting handle 12
+delete rule bridge filter forward handle 13
+flush chain bridge filter r-abc
+delete chain bridge filter r-abc
+`, stale)
+
+	// nothing is left over from a filter that was never set up
+	require.Empty(t, pubIPFilterStale(bridgeListing, "", "r-new"))
+}
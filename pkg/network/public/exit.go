@@ -0,0 +1,330 @@
+package public
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/network/bridge"
+	"github.com/threefoldtech/zosbase/pkg/network/ifaceutil"
+	"github.com/threefoldtech/zosbase/pkg/network/namespace"
+	"github.com/threefoldtech/zosbase/pkg/network/types"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// ErrExitLinkNotWatched is returned by ExitLinkStatus if WatchExitLink is not running
+var ErrExitLinkNotWatched = errors.New("public exit link is not watched")
+
+// exitWatchRetry is how often the watcher is started again if it can't run,
+// and how often a watcher without public namespace checks if it was created
+const exitWatchRetry = 10 * time.Second
+
+var (
+	exitStatusLock sync.RWMutex
+	exitStatus     *pkg.ExitLinkStatus
+)
+
+// ExitLinkStatus returns the live state of the public exit link as
+// tracked by WatchExitLink
+func ExitLinkStatus() (pkg.ExitLinkStatus, error) {
+	exitStatusLock.RLock()
+	defer exitStatusLock.RUnlock()
+
+	if exitStatus == nil {
+		return pkg.ExitLinkStatus{}, ErrExitLinkNotWatched
+	}
+
+	return *exitStatus, nil
+}
+
+func setExitLinkStatus(status *pkg.ExitLinkStatus) {
+	exitStatusLock.Lock()
+	defer exitStatusLock.Unlock()
+
+	if status == nil {
+		exitStatus = nil
+		return
+	}
+
+	cp := *status
+	exitStatus = &cp
+}
+
+// exitWatcher tracks the state of the public exit from netlink updates. The exit
+// is the link attached to br-pub, so it's still tracked if the exit is rewired.
+type exitWatcher struct {
+	bridge int
+	exit   int
+	// public is the index of the public interface inside the public namespace,
+	// address updates are ignored until it's known
+	public int
+	ipv6   map[string]struct{}
+	status pkg.ExitLinkStatus
+
+	// findExit and findPublic look up the exit link, and the public interface
+	// index with its global ipv6 addresses. They are used when the watcher
+	// lost track of them.
+	findExit   func() (netlink.Link, error)
+	findPublic func() (int, []net.IPNet, error)
+}
+
+// KeepWatchingExitLink runs WatchExitLink until the context is cancelled.
+// The watcher is started again if it can't run, for example if the public
+// bridge is not created yet, or once the public namespace is created.
+func KeepWatchingExitLink(ctx context.Context) {
+	for {
+		if err := WatchExitLink(ctx); err != nil {
+			log.Debug().Err(err).Msg("public exit link can't be watched")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(exitWatchRetry):
+		}
+	}
+}
+
+// WatchExitLink watches the public exit link for loss of carrier, and the public
+// interface for loss of its global ipv6, until the context is cancelled. The state
+// is available with ExitLinkStatus while the watcher is running.
+func WatchExitLink(ctx context.Context) error {
+	br, err := bridge.Get(PublicBridge)
+	if err != nil {
+		return errors.Wrap(err, "no public bridge found")
+	}
+
+	w := &exitWatcher{
+		bridge:     br.Attrs().Index,
+		ipv6:       make(map[string]struct{}),
+		findExit:   GetCurrentPublicExitLink,
+		findPublic: publicIPv6,
+	}
+
+	links := make(chan netlink.LinkUpdate)
+	if err := netlink.LinkSubscribe(links, ctx.Done()); err != nil {
+		return errors.Wrap(err, "failed to subscribe to link updates")
+	}
+
+	// subscribe before reading the current state, so no change is missed
+	var addrs chan netlink.AddrUpdate
+	if HasPublicSetup() {
+		addrs, err = namespace.Monitor(ctx, PublicNamespace)
+		if err != nil {
+			return errors.Wrap(err, "failed to subscribe to public namespace address updates")
+		}
+	}
+
+	w.resolveExit()
+
+	// without public namespace the watcher is started again once it's
+	// created, so its addresses are watched too
+	var recheck <-chan time.Time
+	if addrs != nil {
+		w.status.Public = true
+		w.resolvePublic()
+	} else {
+		ticker := time.NewTicker(exitWatchRetry)
+		defer ticker.Stop()
+		recheck = ticker.C
+	}
+
+	w.status.Since = time.Now()
+	setExitLinkStatus(&w.status)
+	defer setExitLinkStatus(nil)
+
+	log.Info().
+		Str("link", w.status.Link).
+		Bool("up", w.status.Up()).
+		Msg("watching public exit link")
+
+	w.run(ctx, links, addrs, recheck)
+	return nil
+}
+
+// publicIPv6 returns the index of the public interface, and its global ipv6
+// addresses
+func publicIPv6() (index int, ips []net.IPNet, err error) {
+	pubNS, err := namespace.GetByName(PublicNamespace)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer pubNS.Close()
+
+	err = pubNS.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(types.PublicIface)
+		if err != nil {
+			return err
+		}
+
+		addrs, err := netlink.AddrList(link, netlink.FAMILY_V6)
+		if err != nil {
+			return err
+		}
+
+		index = link.Attrs().Index
+		for _, addr := range addrs {
+			if isGlobalIPv6(addr.IPNet) {
+				ips = append(ips, *addr.IPNet)
+			}
+		}
+		return nil
+	})
+
+	return index, ips, err
+}
+
+// resolveExit looks up the current exit link
+func (w *exitWatcher) resolveExit() {
+	w.exit = 0
+	w.status.Link = ""
+	w.status.Carrier = false
+
+	exit, err := w.findExit()
+	if err != nil {
+		log.Debug().Err(err).Msg("no public exit link found")
+		return
+	}
+
+	w.exit = exit.Attrs().Index
+	w.status.Link = exit.Attrs().Name
+	w.status.Carrier = hasCarrier(exit.Attrs().RawFlags)
+}
+
+// resolvePublic looks up the public interface and its global ipv6 addresses
+func (w *exitWatcher) resolvePublic() {
+	index, ips, err := w.findPublic()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get public interface addresses")
+		return
+	}
+
+	w.public = index
+	w.ipv6 = make(map[string]struct{})
+	for _, ip := range ips {
+		w.ipv6[ip.String()] = struct{}{}
+	}
+	w.status.GlobalIPv6 = len(w.ipv6) > 0
+}
+
+// run applies the updates to the watcher status until the context is
+// cancelled, or the public namespace is created if recheck is set
+func (w *exitWatcher) run(ctx context.Context, links <-chan netlink.LinkUpdate, addrs <-chan netlink.AddrUpdate, recheck <-chan time.Time) {
+	for {
+		before := w.status
+		select {
+		case <-ctx.Done():
+			return
+		case <-recheck:
+			if HasPublicSetup() {
+				return
+			}
+		case update, ok := <-links:
+			if !ok {
+				return
+			}
+			w.onLink(update)
+		case update, ok := <-addrs:
+			if !ok {
+				return
+			}
+			w.onAddr(update)
+		}
+
+		w.changed(before)
+	}
+}
+
+func (w *exitWatcher) onLink(update netlink.LinkUpdate) {
+	attrs := update.Attrs()
+	deleted := update.Header.Type == unix.RTM_DELLINK
+
+	if attrs.Index == w.exit && (deleted || attrs.MasterIndex != w.bridge) {
+		// the exit link is gone or was detached from br-pub, another link
+		// might have replaced it already
+		w.resolveExit()
+		if w.exit == attrs.Index {
+			// the lookup raced with the update
+			w.exit = 0
+			w.status.Link = ""
+			w.status.Carrier = false
+		}
+		return
+	}
+
+	if deleted || attrs.MasterIndex != w.bridge {
+		return
+	}
+
+	// the exit link is always the one attached to br-pub
+	w.exit = attrs.Index
+	w.status.Link = attrs.Name
+	w.status.Carrier = hasCarrier(attrs.RawFlags)
+}
+
+func (w *exitWatcher) onAddr(update netlink.AddrUpdate) {
+	if w.public == 0 {
+		// the public interface is not known yet, a fresh look up includes
+		// this update
+		w.resolvePublic()
+		return
+	}
+
+	if update.LinkIndex != w.public {
+		if update.NewAddr && isGlobalIPv6(&update.LinkAddress) {
+			// the public interface might have been created again
+			w.resolvePublic()
+		}
+		return
+	}
+
+	if !isGlobalIPv6(&update.LinkAddress) {
+		return
+	}
+
+	if update.NewAddr {
+		w.ipv6[update.LinkAddress.String()] = struct{}{}
+	} else {
+		delete(w.ipv6, update.LinkAddress.String())
+	}
+
+	w.status.GlobalIPv6 = len(w.ipv6) > 0
+}
+
+// changed publishes the status, and reports the exit going down or up
+func (w *exitWatcher) changed(before pkg.ExitLinkStatus) {
+	if before.Link == w.status.Link &&
+		before.Carrier == w.status.Carrier &&
+		before.GlobalIPv6 == w.status.GlobalIPv6 {
+		return
+	}
+
+	w.status.Since = time.Now()
+	if before.Up() && !w.status.Up() {
+		w.status.Flaps++
+		log.Warn().
+			Str("link", w.status.Link).
+			Bool("carrier", w.status.Carrier).
+			Bool("global-ipv6", w.status.GlobalIPv6).
+			Uint64("flaps", w.status.Flaps).
+			Msg("public exit link is down")
+	} else if !before.Up() && w.status.Up() {
+		log.Info().Str("link", w.status.Link).Msg("public exit link is up")
+	}
+
+	setExitLinkStatus(&w.status)
+}
+
+func hasCarrier(flags uint32) bool {
+	return flags&unix.IFF_LOWER_UP != 0
+}
+
+func isGlobalIPv6(ip *net.IPNet) bool {
+	return ip != nil && ip.IP.To4() == nil && ip.IP.IsGlobalUnicast() && !ifaceutil.IsULA(ip.IP)
+}
@@ -0,0 +1,208 @@
+package public
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+func linkUpdate(index, master int, name string, carrier bool) netlink.LinkUpdate {
+	update := netlink.LinkUpdate{
+		Header: unix.NlMsghdr{Type: unix.RTM_NEWLINK},
+		IfInfomsg: nl.IfInfomsg{
+			IfInfomsg: unix.IfInfomsg{Index: int32(index)},
+		},
+		Link: &netlink.Device{LinkAttrs: netlink.LinkAttrs{
+			Index:       index,
+			MasterIndex: master,
+			Name:        name,
+		}},
+	}
+	if carrier {
+		update.Link.Attrs().RawFlags = unix.IFF_UP | unix.IFF_LOWER_UP
+	}
+	return update
+}
+
+func addrUpdate(index int, ip string, added bool) netlink.AddrUpdate {
+	addr, ipNet, _ := net.ParseCIDR(ip)
+	ipNet.IP = addr
+	return netlink.AddrUpdate{LinkIndex: index, LinkAddress: *ipNet, NewAddr: added}
+}
+
+func TestExitWatcher(t *testing.T) {
+	const (
+		bridge = 10
+		exit   = 2
+		public = 5
+	)
+
+	w := &exitWatcher{
+		bridge: bridge,
+		exit:   exit,
+		public: public,
+		ipv6:   map[string]struct{}{"2a02:1802:5e::10/64": {}},
+		status: pkg.ExitLinkStatus{
+			Link:       "eth0",
+			Carrier:    true,
+			Public:     true,
+			GlobalIPv6: true,
+		},
+		findExit: func() (netlink.Link, error) {
+			return nil, fmt.Errorf("no exit link")
+		},
+		findPublic: func() (int, []net.IPNet, error) {
+			return 0, nil, fmt.Errorf("no public interface")
+		},
+	}
+	t.Cleanup(func() { setExitLinkStatus(nil) })
+
+	links := make(chan netlink.LinkUpdate)
+	addrs := make(chan netlink.AddrUpdate)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.run(ctx, links, addrs, nil)
+	}()
+
+	sync := func() {
+		// an unrelated event, once it's consumed the previous one was applied
+		links <- linkUpdate(99, 0, "other", true)
+	}
+	status := func() pkg.ExitLinkStatus {
+		sync()
+		status, err := ExitLinkStatus()
+		require.NoError(t, err)
+		return status
+	}
+
+	// carrier lost
+	links <- linkUpdate(exit, bridge, "eth0", false)
+	current := status()
+	require.False(t, current.Carrier)
+	require.False(t, current.Up())
+	require.EqualValues(t, 1, current.Flaps)
+
+	// carrier back
+	links <- linkUpdate(exit, bridge, "eth0", true)
+	current = status()
+	require.True(t, current.Up())
+
+	// global ipv6 lost, link local addresses and other links are ignored
+	addrs <- addrUpdate(public, "fe80::1/64", true)
+	addrs <- addrUpdate(7, "2a02:1802:5e::11/64", true)
+	addrs <- addrUpdate(public, "2a02:1802:5e::10/64", false)
+	current = status()
+	require.True(t, current.Carrier)
+	require.False(t, current.GlobalIPv6)
+	require.EqualValues(t, 2, current.Flaps)
+
+	addrs <- addrUpdate(public, "2a02:1802:5e::12/64", true)
+	current = status()
+	require.True(t, current.Up())
+
+	// exit rewired to another link
+	links <- linkUpdate(exit, 0, "eth0", true)
+	links <- linkUpdate(3, bridge, "eth1", true)
+	current = status()
+	require.Equal(t, "eth1", current.Link)
+	require.True(t, current.Up())
+	require.EqualValues(t, 3, current.Flaps)
+
+	cancel()
+	<-done
+}
+
+func TestExitWatcherResolve(t *testing.T) {
+	const (
+		bridge = 10
+		public = 5
+	)
+
+	var exit netlink.Link
+	w := &exitWatcher{
+		bridge: bridge,
+		ipv6:   make(map[string]struct{}),
+		status: pkg.ExitLinkStatus{Public: true},
+		findExit: func() (netlink.Link, error) {
+			if exit == nil {
+				return nil, fmt.Errorf("no exit link")
+			}
+			return exit, nil
+		},
+		findPublic: func() (int, []net.IPNet, error) {
+			return 0, nil, fmt.Errorf("no public interface")
+		},
+	}
+	t.Cleanup(func() { setExitLinkStatus(nil) })
+
+	links := make(chan netlink.LinkUpdate)
+	addrs := make(chan netlink.AddrUpdate)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.run(ctx, links, addrs, nil)
+	}()
+
+	status := func() pkg.ExitLinkStatus {
+		links <- linkUpdate(99, 0, "other", true)
+		status, err := ExitLinkStatus()
+		require.NoError(t, err)
+		return status
+	}
+
+	links <- linkUpdate(2, bridge, "eth0", true)
+	// the public interface is not known, its addresses are ignored
+	addrs <- addrUpdate(7, "2a02:1802:5e::10/64", true)
+	current := status()
+	require.Equal(t, "eth0", current.Link)
+	require.False(t, current.GlobalIPv6)
+
+	// once it's known the fresh look up is used
+	w.findPublic = func() (int, []net.IPNet, error) {
+		_, ip, _ := net.ParseCIDR("2a02:1802:5e::10/64")
+		return public, []net.IPNet{*ip}, nil
+	}
+	addrs <- addrUpdate(7, "2a02:1802:5e::10/64", true)
+	current = status()
+	require.True(t, current.GlobalIPv6)
+	require.True(t, current.Up())
+
+	// the exit link goes away, and was already replaced by another one
+	exit = linkUpdate(3, bridge, "eth1", true).Link
+	deleted := linkUpdate(2, bridge, "eth0", true)
+	deleted.Header.Type = unix.RTM_DELLINK
+	links <- deleted
+	current = status()
+	require.Equal(t, "eth1", current.Link)
+	require.True(t, current.Up())
+	require.EqualValues(t, 0, current.Flaps)
+
+	// gone without replacement
+	exit = nil
+	deleted = linkUpdate(3, bridge, "eth1", true)
+	deleted.Header.Type = unix.RTM_DELLINK
+	links <- deleted
+	current = status()
+	require.Empty(t, current.Link)
+	require.False(t, current.Up())
+	require.EqualValues(t, 1, current.Flaps)
+
+	cancel()
+	<-done
+}
+
+func TestExitLinkStatusNotWatched(t *testing.T) {
+	setExitLinkStatus(nil)
+	_, err := ExitLinkStatus()
+	require.ErrorIs(t, err, ErrExitLinkNotWatched)
+}
@@ -10,7 +10,11 @@ import (
 )
 
 const (
-	publicConfigFile = "public-config.json"
+	publicConfigFile  = "public-config.json"
+	exitDecisionsFile = "exit-decisions.json"
+
+	// maxExitDecisions is the number of exit decisions kept in history
+	maxExitDecisions = 50
 )
 
 var (
@@ -78,3 +82,42 @@ func SavePublicConfig(cfg pkg.PublicConfig) error {
 
 	return nil
 }
+
+// LoadExitDecisions loads the history of public exit decisions, oldest first
+func LoadExitDecisions() ([]pkg.ExitDecision, error) {
+	data, err := os.ReadFile(getPersistencePath(exitDecisionsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to load exit decisions file")
+	}
+
+	var decisions []pkg.ExitDecision
+	if err := json.Unmarshal(data, &decisions); err != nil {
+		return nil, errors.Wrap(err, "failed to decode exit decisions")
+	}
+
+	return decisions, nil
+}
+
+// saveExitDecision appends a decision to the exit decisions history, only
+// the last maxExitDecisions are kept.
+func saveExitDecision(decision pkg.ExitDecision) error {
+	decisions, err := LoadExitDecisions()
+	if err != nil {
+		// a corrupted history should not block recording new decisions
+		decisions = nil
+	}
+
+	decisions = append(decisions, decision)
+	if len(decisions) > maxExitDecisions {
+		decisions = decisions[len(decisions)-maxExitDecisions:]
+	}
+
+	data, err := json.Marshal(decisions)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode exit decisions")
+	}
+
+	return os.WriteFile(getPersistencePath(exitDecisionsFile), data, 0644)
+}
@@ -0,0 +1,37 @@
+package public
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+func TestExitDecisions(t *testing.T) {
+	SetPersistence(t.TempDir())
+
+	decisions, err := LoadExitDecisions()
+	require.NoError(t, err)
+	require.Empty(t, decisions)
+
+	recordExitDecision(pkg.ExitSourceFallback, "zos", "no nic")
+	recordExitDecision(pkg.ExitSourceOperator, "eth1", "set by farmer")
+
+	decisions, err = LoadExitDecisions()
+	require.NoError(t, err)
+	require.Len(t, decisions, 2)
+	require.Equal(t, pkg.ExitSourceFallback, decisions[0].Source)
+	require.Equal(t, "zos", decisions[0].Exit)
+	require.Equal(t, pkg.ExitSourceOperator, decisions[1].Source)
+	require.Equal(t, "eth1", decisions[1].Exit)
+
+	for i := 0; i < maxExitDecisions; i++ {
+		recordExitDecision(pkg.ExitSourceKept, fmt.Sprintf("eth%d", i), "already wired")
+	}
+
+	decisions, err = LoadExitDecisions()
+	require.NoError(t, err)
+	require.Len(t, decisions, maxExitDecisions)
+	require.Equal(t, "eth0", decisions[0].Exit)
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/pkg/errors"
@@ -112,7 +113,36 @@ func setupPublicBridge(br *netlink.Bridge, vlan *uint16) error {
 		return errors.Wrapf(err, "failed to get link '%s' by name", exit)
 	}
 
-	return attachPublicToExit(br, exitLink, vlan)
+	if err := attachPublicToExit(br, exitLink, vlan); err != nil {
+		return err
+	}
+
+	if exit == types.DefaultBridge {
+		recordExitDecision(pkg.ExitSourceFallback, exit, "no plugged physical nic with a public ipv6 was found")
+	} else {
+		recordExitDecision(pkg.ExitSourceAuto, exit, "plugged physical nic with a public ipv6")
+	}
+
+	return nil
+}
+
+// recordExitDecision adds the decision to the exit history, failing
+// to record is only logged since it must not block the network setup.
+func recordExitDecision(source pkg.ExitSource, exit, reason string) {
+	log.Info().
+		Str("source", string(source)).
+		Str("exit", exit).
+		Str("reason", reason).
+		Msg("public exit decision")
+
+	if err := saveExitDecision(pkg.ExitDecision{
+		Time:   time.Now(),
+		Source: source,
+		Exit:   exit,
+		Reason: reason,
+	}); err != nil {
+		log.Error().Err(err).Msg("failed to record public exit decision")
+	}
 }
 
 func attachPublicToExit(br *netlink.Bridge, exit netlink.Link, vlan *uint16) error {
@@ -247,7 +277,12 @@ func SetPublicExitLink(link netlink.Link) error {
 		}
 	}
 
-	return attachPublicToExit(br, link, environment.MustGet().PubVlan)
+	if err := attachPublicToExit(br, link, environment.MustGet().PubVlan); err != nil {
+		return err
+	}
+
+	recordExitDecision(pkg.ExitSourceOperator, link.Attrs().Name, "set by farmer")
+	return nil
 }
 
 func HasPublicSetup() bool {
@@ -255,7 +290,8 @@ func HasPublicSetup() bool {
 }
 
 // GetPublicSetup gets the public setup from reality
-// or error if node has no public setup
+// or error if node has no public setup. An ip family that is not
+// configured (like ipv4 on an ipv6 only setup) is left empty.
 func GetPublicSetup() (pkg.PublicConfig, error) {
 	if !namespace.Exists(PublicNamespace) {
 		return pkg.PublicConfig{}, ErrNoPublicConfig
@@ -269,8 +305,9 @@ func GetPublicSetup() (pkg.PublicConfig, error) {
 	if set, err := LoadPublicConfig(); err != nil {
 		return pkg.PublicConfig{}, errors.Wrap(err, "failed to load configuration")
 	} else {
-		// we only need the domain name from the config
+		// we only need the domain name and nameservers from the config
 		cfg.Domain = set.Domain
+		cfg.Nameservers = set.Nameservers
 	}
 	// everything else is loaded from the actual state of the node.
 	err = namespace.Do(func(_ ns.NetNS) error {
@@ -291,7 +328,7 @@ func GetPublicSetup() (pkg.PublicConfig, error) {
 			return errors.Wrap(err, "failed to get ipv4 default gateway")
 		}
 		for _, r := range routes {
-			if r.Dst == nil {
+			if isDefaultRoute(&r) {
 				cfg.GW4 = r.Gw
 				break
 			}
@@ -299,21 +336,22 @@ func GetPublicSetup() (pkg.PublicConfig, error) {
 
 		ips, err = netlink.AddrList(link, netlink.FAMILY_V6)
 		if err != nil {
-			return errors.Wrap(err, "failed to get public ipv4")
+			return errors.Wrap(err, "failed to get public ipv6")
 		}
 
 		for _, ip := range ips {
 			if ip.IP.IsGlobalUnicast() && !ifaceutil.IsULA(ip.IP) {
-				cfg.IPv6 = gridtypes.IPNet{IPNet: *ips[0].IPNet}
+				cfg.IPv6 = gridtypes.IPNet{IPNet: *ip.IPNet}
+				break
 			}
 		}
 
 		routes, err = netlink.RouteListFiltered(netlink.FAMILY_V6, nil, 0)
 		if err != nil {
-			return errors.Wrap(err, "failed to get ipv4 default gateway")
+			return errors.Wrap(err, "failed to get ipv6 default gateway")
 		}
 		for _, r := range routes {
-			if r.Dst == nil {
+			if isDefaultRoute(&r) {
 				cfg.GW6 = r.Gw
 				break
 			}
@@ -325,6 +363,20 @@ func GetPublicSetup() (pkg.PublicConfig, error) {
 	return cfg, err
 }
 
+// isDefaultRoute checks if the route is a default route with a gateway
+func isDefaultRoute(r *netlink.Route) bool {
+	if r.Gw == nil {
+		return false
+	}
+
+	if r.Dst == nil {
+		return true
+	}
+
+	ones, _ := r.Dst.Mask.Size()
+	return ones == 0 && r.Dst.IP.IsUnspecified()
+}
+
 // EnsurePublicSetup create the public setup, it's okay to have inf == nil.
 // this method need to be called at least once in the life of the node. to make bridges are created
 // and wired correctly, and initialize public name space if `inf` is found.
@@ -343,7 +395,7 @@ func EnsurePublicSetup(nodeID pkg.Identifier, vlan *uint16, inf *pkg.PublicConfi
 		return nil, err
 	}
 
-	_, err = GetCurrentPublicExitLink()
+	current, err := GetCurrentPublicExitLink()
 	if os.IsNotExist(err) {
 		// bridge is not initialized, wire it.
 		log.Debug().Msg("no public bridge uplink found, setting up...")
@@ -352,6 +404,12 @@ func EnsurePublicSetup(nodeID pkg.Identifier, vlan *uint16, inf *pkg.PublicConfi
 		}
 	} else if err != nil {
 		return nil, errors.Wrap(err, "failed to get current public bridge uplink")
+	} else {
+		exit := current.Attrs().Name
+		if ok, _ := bootstrap.VEthFilter(current); ok {
+			exit = types.DefaultBridge
+		}
+		recordExitDecision(pkg.ExitSourceKept, exit, "public bridge is already wired")
 	}
 
 	if err := ensureTestNamespace(br); err != nil {
@@ -376,7 +434,7 @@ func EnsurePublicSetup(nodeID pkg.Identifier, vlan *uint16, inf *pkg.PublicConfi
 			return nil, zi.Reboot()
 		}
 	} else {
-		if err := setupPublicNS(nodeID, inf); err != nil {
+		if err := setupPublicNS(nodeID, []VlanPublicConfig{{Config: *inf}}); err != nil {
 			return nil, errors.Wrap(err, "failed to ensure public namespace setup")
 		}
 
@@ -435,33 +493,105 @@ func detectExitNic() (string, error) {
 	return types.DefaultBridge, nil
 }
 
-func ensurePublicNamespace() (ns.NetNS, error) {
-	if !namespace.Exists(PublicNamespace) {
-		log.Info().Str("namespace", PublicNamespace).Msg("Create network namespace")
-		return namespace.Create(PublicNamespace)
+// VlanPublicConfig is a public config, and the vlan it's bound to on the
+// public uplink
+type VlanPublicConfig struct {
+	// Vlan of the config, nil for the default (untagged) public namespace
+	Vlan   *uint16
+	Config pkg.PublicConfig
+}
+
+// VlanPublicNamespace is the name of the public namespace of a vlan
+func VlanPublicNamespace(vlan uint16) string {
+	return fmt.Sprintf("%s-%d", PublicNamespace, vlan)
+}
+
+// namespace is the public namespace of the config
+func (c *VlanPublicConfig) namespace() string {
+	if c.Vlan == nil {
+		return PublicNamespace
+	}
+
+	return VlanPublicNamespace(*c.Vlan)
+}
+
+// master is the link the public macvlan of the config is created on
+func (c *VlanPublicConfig) master() string {
+	if c.Vlan == nil {
+		return PublicBridge
+	}
+
+	return fmt.Sprintf("%s.%d", PublicBridge, *c.Vlan)
+}
+
+// mac is the mac of the public interface of the config
+func (c *VlanPublicConfig) mac(nodeID pkg.Identifier) net.HardwareAddr {
+	suffix := publicNsMACDerivationSuffix
+	if c.Vlan != nil {
+		suffix = fmt.Sprintf("%s-%d", suffix, *c.Vlan)
+	}
+
+	return ifaceutil.HardwareAddrFromInputBytes([]byte(nodeID.Identity() + suffix))
+}
+
+func ensurePublicNamespace(name string) (ns.NetNS, error) {
+	if !namespace.Exists(name) {
+		log.Info().Str("namespace", name).Msg("Create network namespace")
+		return namespace.Create(name)
 	}
 
-	return namespace.GetByName(PublicNamespace)
+	return namespace.GetByName(name)
 }
 
-func ensurePublicMacvlan(iface *pkg.PublicConfig, pubNS ns.NetNS) (*netlink.Macvlan, error) {
+// ensureVlanLink makes sure the vlan link of the public bridge exists
+func ensureVlanLink(name string, vlan uint16) error {
+	if _, err := netlink.LinkByName(name); err == nil {
+		return nil
+	}
+
+	br, err := netlink.LinkByName(PublicBridge)
+	if err != nil {
+		return errors.Wrap(err, "failed to get public bridge")
+	}
+
+	link := &netlink.Vlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        name,
+			ParentIndex: br.Attrs().Index,
+		},
+		VlanId: int(vlan),
+	}
+
+	if err := netlink.LinkAdd(link); err != nil {
+		return errors.Wrapf(err, "failed to create vlan link '%s'", name)
+	}
+
+	return netlink.LinkSetUp(link)
+}
+
+func ensurePublicMacvlan(cfg *VlanPublicConfig, pubNS ns.NetNS) (*netlink.Macvlan, error) {
 	var (
 		pubIface *netlink.Macvlan
 		err      error
 	)
 
 	if !ifaceutil.Exists(types.PublicIface, pubNS) {
+		if cfg.Vlan != nil {
+			if err := ensureVlanLink(cfg.master(), *cfg.Vlan); err != nil {
+				return nil, err
+			}
+		}
 
-		switch iface.Type {
+		switch cfg.Config.Type {
 		case "":
 			fallthrough
 		case pkg.MacVlanIface:
-			pubIface, err = macvlan.Create(types.PublicIface, types.PublicBridge, pubNS)
+			pubIface, err = macvlan.Create(types.PublicIface, cfg.master(), pubNS)
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to create public mac vlan interface")
 			}
 		default:
-			return nil, fmt.Errorf("unsupported public interface type %s", iface.Type)
+			return nil, fmt.Errorf("unsupported public interface type %s", cfg.Config.Type)
 		}
 
 	} else {
@@ -477,6 +607,9 @@ func ensurePublicMacvlan(iface *pkg.PublicConfig, pubNS ns.NetNS) (*netlink.Macv
 	return pubIface, nil
 }
 
+// publicConfig returns the addresses and default routes of the public config.
+// Each ip family is configured only if both the address and the gateway are set,
+// so a config can be ipv4 only, ipv6 only or dual stack.
 func publicConfig(iface *pkg.PublicConfig) (ips []*net.IPNet, routes []*netlink.Route, err error) {
 	if !iface.IPv6.Nil() && iface.GW6 != nil {
 		routes = append(routes, &netlink.Route{
@@ -487,6 +620,8 @@ func publicConfig(iface *pkg.PublicConfig) (ips []*net.IPNet, routes []*netlink.
 			Gw: iface.GW6,
 		})
 		ips = append(ips, &iface.IPv6.IPNet)
+	} else if !iface.IPv6.Nil() || iface.GW6 != nil {
+		log.Warn().Msg("public ipv6 config is incomplete, ipv6 will not be configured")
 	}
 
 	if !iface.IPv4.Nil() && iface.GW4 != nil {
@@ -498,10 +633,12 @@ func publicConfig(iface *pkg.PublicConfig) (ips []*net.IPNet, routes []*netlink.
 			Gw: iface.GW4,
 		})
 		ips = append(ips, &iface.IPv4.IPNet)
+	} else if !iface.IPv4.Nil() || iface.GW4 != nil {
+		log.Warn().Msg("public ipv4 config is incomplete, ipv4 will not be configured")
 	}
 
-	if len(ips) <= 0 || len(routes) <= 0 {
-		err := fmt.Errorf("missing some information in the exit iface object")
+	if len(ips) == 0 {
+		err := fmt.Errorf("public config must have an ipv4 or an ipv6 with its gateway")
 		log.Error().Err(err).Msg("failed to configure public interface")
 		return nil, nil, err
 	}
@@ -509,51 +646,98 @@ func publicConfig(iface *pkg.PublicConfig) (ips []*net.IPNet, routes []*netlink.
 	return ips, routes, nil
 }
 
-func ensurePublicResolve() error {
-	path := filepath.Join("/etc", "netns", PublicNamespace)
+func ensurePublicResolve(name string, nameservers []net.IP) error {
+	path := filepath.Join("/etc", "netns", name)
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return errors.Wrap(err, "failed to create public netns directory")
 	}
 	path = filepath.Join(path, "resolv.conf")
-	return os.WriteFile(path, []byte(defaultPublicResolveConf), 0644)
+	return os.WriteFile(path, publicResolveConf(nameservers), 0644)
+}
+
+// publicResolveConf builds the resolv.conf of the public namespace from the
+// nameservers, or returns the default one if no valid nameservers are set
+func publicResolveConf(nameservers []net.IP) []byte {
+	var buf bytes.Buffer
+	seen := make(map[string]struct{})
+	for _, ns := range nameservers {
+		if ns == nil || ns.IsUnspecified() {
+			continue
+		}
+
+		ip := ns.String()
+		if _, ok := seen[ip]; ok {
+			continue
+		}
+		seen[ip] = struct{}{}
+
+		fmt.Fprintf(&buf, "nameserver %s\n", ip)
+	}
+
+	if buf.Len() == 0 {
+		return []byte(defaultPublicResolveConf)
+	}
+
+	return buf.Bytes()
+}
+
+// setupPublicNS creates a public namespace in a node for each of the configs
+func setupPublicNS(nodeID pkg.Identifier, configs []VlanPublicConfig) error {
+	seen := make(map[string]struct{})
+	for i := range configs {
+		cfg := &configs[i]
+		name := cfg.namespace()
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("multiple public configs for namespace '%s'", name)
+		}
+		seen[name] = struct{}{}
+
+		if err := setupVlanPublicNS(nodeID, cfg); err != nil {
+			return errors.Wrapf(err, "failed to setup public namespace '%s'", name)
+		}
+	}
+
+	return nil
 }
 
-// setupPublicNS creates a public namespace in a node
-func setupPublicNS(nodeID pkg.Identifier, iface *pkg.PublicConfig) error {
-	pubNS, err := ensurePublicNamespace()
+// setupVlanPublicNS creates the public namespace of a single config
+func setupVlanPublicNS(nodeID pkg.Identifier, cfg *VlanPublicConfig) error {
+	pubNS, err := ensurePublicNamespace(cfg.namespace())
 	if err != nil {
 		return err
 	}
 
 	defer pubNS.Close()
 
-	// todo: this need to come later from the node config on the grid
-	if err := ensurePublicResolve(); err != nil {
+	// the public config can override the default nameservers
+	if err := ensurePublicResolve(cfg.namespace(), cfg.Config.Nameservers); err != nil {
 		return errors.Wrap(err, "failed to configure public namespace resolv.conf")
 	}
 
-	pubIface, err := ensurePublicMacvlan(iface, pubNS)
+	pubIface, err := ensurePublicMacvlan(cfg, pubNS)
 	if err != nil {
 		return err
 	}
 
 	log.Info().
 		Str("pub iface", fmt.Sprintf("%+v", pubIface)).
+		Str("namespace", cfg.namespace()).
 		Msg("configure public interface inside public namespace")
 
-	ips, routes, err := publicConfig(iface)
+	ips, routes, err := publicConfig(&cfg.Config)
 	if err != nil {
 		return errors.Wrap(err, "failed configure public network interface")
 	}
 
-	mac := ifaceutil.HardwareAddrFromInputBytes([]byte(nodeID.Identity() + publicNsMACDerivationSuffix))
+	mac := cfg.mac(nodeID)
 
 	env, err := environment.Get()
 	if err != nil {
 		return errors.Wrap(err, "failed to get environment")
 	}
 
-	if env.PubMac == environment.PubMacSwap {
+	// the upstream nic mac can only be swapped with a single public interface
+	if env.PubMac == environment.PubMacSwap && cfg.Vlan == nil {
 		// this logic can be tricky. the idea is we need to
 		// swap the mac address of the uplink (where public traffic is eventually going out)
 		// with thee pubIface calculated above!
@@ -4,11 +4,13 @@ import (
 	"net"
 	"testing"
 
+	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/stretchr/testify/require"
 	"github.com/threefoldtech/zosbase/pkg"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes"
 	"github.com/threefoldtech/zosbase/pkg/network/namespace"
 	"github.com/threefoldtech/zosbase/pkg/network/types"
+	"github.com/vishvananda/netlink"
 )
 
 func TestCreatePublicNS(t *testing.T) {
@@ -27,6 +29,167 @@ func TestCreatePublicNS(t *testing.T) {
 		require.NoError(t, err)
 	}()
 
-	err := setupPublicNS(pkg.StrIdentifier(""), iface)
+	err := setupPublicNS(pkg.StrIdentifier(""), []VlanPublicConfig{{Config: *iface}})
 	require.NoError(t, err)
 }
+
+func TestCreateVlanPublicNS(t *testing.T) {
+	vlan := func(v uint16) *uint16 { return &v }
+	configs := []VlanPublicConfig{
+		{
+			Vlan: vlan(100),
+			Config: pkg.PublicConfig{
+				Type: pkg.MacVlanIface,
+				IPv4: gridtypes.MustParseIPNet("185.69.166.10/24"),
+				GW4:  net.ParseIP("185.69.166.1"),
+			},
+		},
+		{
+			Vlan: vlan(200),
+			Config: pkg.PublicConfig{
+				Type: pkg.MacVlanIface,
+				IPv4: gridtypes.MustParseIPNet("185.69.167.10/24"),
+				GW4:  net.ParseIP("185.69.167.1"),
+			},
+		},
+	}
+
+	_, err := ensurePublicBridge()
+	require.NoError(t, err)
+
+	defer func() {
+		for _, cfg := range configs {
+			pubNS, _ := namespace.GetByName(cfg.namespace())
+			err := namespace.Delete(pubNS)
+			require.NoError(t, err)
+		}
+	}()
+
+	err = setupPublicNS(pkg.StrIdentifier(""), configs)
+	require.NoError(t, err)
+
+	for _, cfg := range configs {
+		pubNS, err := namespace.GetByName(cfg.namespace())
+		require.NoError(t, err)
+
+		err = pubNS.Do(func(_ ns.NetNS) error {
+			link, err := netlink.LinkByName(types.PublicIface)
+			if err != nil {
+				return err
+			}
+			addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+			if err != nil {
+				return err
+			}
+			require.Len(t, addrs, 1)
+			require.Equal(t, cfg.Config.IPv4.String(), addrs[0].IPNet.String())
+			return nil
+		})
+		pubNS.Close()
+		require.NoError(t, err)
+	}
+}
+
+func TestVlanPublicConfig(t *testing.T) {
+	vlan := uint16(100)
+	untagged := VlanPublicConfig{}
+	tagged := VlanPublicConfig{Vlan: &vlan}
+
+	require.Equal(t, PublicNamespace, untagged.namespace())
+	require.Equal(t, "public-100", tagged.namespace())
+	require.Equal(t, PublicBridge, untagged.master())
+	require.Equal(t, "br-pub.100", tagged.master())
+
+	id := pkg.StrIdentifier("node")
+	require.NotEqual(t, untagged.mac(id), tagged.mac(id))
+	require.Equal(t, tagged.mac(id), tagged.mac(id))
+}
+
+func TestPublicConfig(t *testing.T) {
+	ipv4 := gridtypes.MustParseIPNet("185.69.166.10/24")
+	ipv6 := gridtypes.MustParseIPNet("2a02:1802:5e:ff02::100/64")
+
+	t.Run("ipv6 only", func(t *testing.T) {
+		ips, routes, err := publicConfig(&pkg.PublicConfig{
+			IPv6: ipv6,
+			GW6:  net.ParseIP("fe80::1"),
+		})
+		require.NoError(t, err)
+		require.Len(t, ips, 1)
+		require.Equal(t, ipv6.String(), ips[0].String())
+		require.Len(t, routes, 1)
+		require.Equal(t, "fe80::1", routes[0].Gw.String())
+	})
+
+	t.Run("ipv4 only", func(t *testing.T) {
+		ips, routes, err := publicConfig(&pkg.PublicConfig{
+			IPv4: ipv4,
+			GW4:  net.ParseIP("185.69.166.1"),
+		})
+		require.NoError(t, err)
+		require.Len(t, ips, 1)
+		require.Len(t, routes, 1)
+	})
+
+	t.Run("dual stack", func(t *testing.T) {
+		ips, routes, err := publicConfig(&pkg.PublicConfig{
+			IPv4: ipv4,
+			GW4:  net.ParseIP("185.69.166.1"),
+			IPv6: ipv6,
+			GW6:  net.ParseIP("fe80::1"),
+		})
+		require.NoError(t, err)
+		require.Len(t, ips, 2)
+		require.Len(t, routes, 2)
+	})
+
+	t.Run("ipv4 without gateway", func(t *testing.T) {
+		ips, routes, err := publicConfig(&pkg.PublicConfig{
+			IPv4: ipv4,
+			IPv6: ipv6,
+			GW6:  net.ParseIP("fe80::1"),
+		})
+		require.NoError(t, err)
+		require.Len(t, ips, 1)
+		require.Equal(t, ipv6.String(), ips[0].String())
+		require.Len(t, routes, 1)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, _, err := publicConfig(&pkg.PublicConfig{})
+		require.Error(t, err)
+
+		_, _, err = publicConfig(&pkg.PublicConfig{IPv6: ipv6})
+		require.Error(t, err)
+	})
+}
+
+func TestIsDefaultRoute(t *testing.T) {
+	gw := net.ParseIP("185.69.166.1")
+	require.True(t, isDefaultRoute(&netlink.Route{Gw: gw}))
+	require.True(t, isDefaultRoute(&netlink.Route{
+		Dst: &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+		Gw:  gw,
+	}))
+	require.True(t, isDefaultRoute(&netlink.Route{
+		Dst: &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)},
+		Gw:  net.ParseIP("fe80::1"),
+	}))
+	require.False(t, isDefaultRoute(&netlink.Route{
+		Dst: &net.IPNet{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(8, 32)},
+		Gw:  gw,
+	}))
+	require.False(t, isDefaultRoute(&netlink.Route{}))
+}
+
+func TestPublicResolveConf(t *testing.T) {
+	require.Equal(t, defaultPublicResolveConf, string(publicResolveConf(nil)))
+
+	conf := publicResolveConf([]net.IP{
+		net.ParseIP("10.10.0.1"),
+		net.ParseIP("2001:db8::53"),
+		net.ParseIP("10.10.0.1"),
+		nil,
+	})
+	require.Equal(t, "nameserver 10.10.0.1\nnameserver 2001:db8::53\n", string(conf))
+}
@@ -3,6 +3,7 @@ package pkg
 import (
 	"context"
 	"net"
+	"time"
 
 	"github.com/threefoldtech/zosbase/pkg/gridtypes"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
@@ -30,11 +31,24 @@ type NetworkerLight interface {
 	LoadPublicConfig() (PublicConfig, error)
 
 	WireguardPorts() ([]uint, error)
+	// WireguardStats returns the stats of the wireguard peers of a network
+	WireguardStats(id NetID) ([]PeerStat, error)
 	GetDefaultGwIP(id NetID) (net.IP, error)
 	GetNet(id NetID) (net.IPNet, error)
 	GetSubnet(id NetID) (net.IPNet, error)
 }
 
+// PeerStat is the state of a wireguard peer of a network
+type PeerStat struct {
+	PublicKey  string   `json:"public_key"`
+	Endpoint   string   `json:"endpoint"`
+	AllowedIPs []string `json:"allowed_ips"`
+	// LastHandshake is zero if no handshake happened yet
+	LastHandshake time.Time `json:"last_handshake"`
+	RxBytes       int64     `json:"rx_bytes"`
+	TxBytes       int64     `json:"tx_bytes"`
+}
+
 type TapDevice struct {
 	Name   string
 	Mac    net.HardwareAddr
@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+func TestPublicConfigValidate(t *testing.T) {
+	ipv4 := gridtypes.MustParseIPNet("185.69.166.10/24")
+	ipv6 := gridtypes.MustParseIPNet("2a02:1802:5e:ff02::100/64")
+
+	cases := []struct {
+		name  string
+		cfg   PublicConfig
+		valid bool
+	}{
+		{
+			name:  "dual stack",
+			cfg:   PublicConfig{IPv4: ipv4, GW4: net.ParseIP("185.69.166.1"), IPv6: ipv6, GW6: net.ParseIP("2a02:1802:5e:ff02::1")},
+			valid: true,
+		},
+		{
+			name:  "ipv4 only",
+			cfg:   PublicConfig{IPv4: ipv4, GW4: net.ParseIP("185.69.166.1")},
+			valid: true,
+		},
+		{
+			name:  "ipv6 only link local gateway",
+			cfg:   PublicConfig{IPv6: ipv6, GW6: net.ParseIP("fe80::1")},
+			valid: true,
+		},
+		{
+			name: "empty",
+			cfg:  PublicConfig{},
+		},
+		{
+			name: "ipv4 without gateway",
+			cfg:  PublicConfig{IPv4: ipv4},
+		},
+		{
+			name: "ipv4 gateway without ipv4",
+			cfg:  PublicConfig{IPv6: ipv6, GW6: net.ParseIP("fe80::1"), GW4: net.ParseIP("185.69.166.1")},
+		},
+		{
+			name: "ipv4 gateway outside subnet",
+			cfg:  PublicConfig{IPv4: ipv4, GW4: net.ParseIP("185.69.167.1")},
+		},
+		{
+			name: "ipv4 gateway is the node ip",
+			cfg:  PublicConfig{IPv4: ipv4, GW4: net.ParseIP("185.69.166.10")},
+		},
+		{
+			name: "ipv4 network address",
+			cfg:  PublicConfig{IPv4: gridtypes.MustParseIPNet("185.69.166.0/24"), GW4: net.ParseIP("185.69.166.1")},
+		},
+		{
+			name: "ipv6 gateway for ipv4",
+			cfg:  PublicConfig{IPv4: ipv4, GW4: net.ParseIP("2a02:1802:5e:ff02::1")},
+		},
+		{
+			name: "ipv4 in ipv6 field",
+			cfg:  PublicConfig{IPv6: ipv4, GW6: net.ParseIP("185.69.166.1")},
+		},
+		{
+			name:  "custom nameservers",
+			cfg:   PublicConfig{IPv6: ipv6, GW6: net.ParseIP("fe80::1"), Nameservers: []net.IP{net.ParseIP("10.10.0.1"), net.ParseIP("2001:db8::53")}},
+			valid: true,
+		},
+		{
+			name: "loopback nameserver",
+			cfg:  PublicConfig{IPv6: ipv6, GW6: net.ParseIP("fe80::1"), Nameservers: []net.IP{net.ParseIP("127.0.0.53")}},
+		},
+		{
+			name: "invalid nameserver",
+			cfg:  PublicConfig{IPv6: ipv6, GW6: net.ParseIP("fe80::1"), Nameservers: []net.IP{nil}},
+		},
+		{
+			name: "ipv6 gateway outside subnet",
+			cfg:  PublicConfig{IPv6: ipv6, GW6: net.ParseIP("2a02:1802:5e:ff03::1")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.cfg.Validate()
+			if c.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestPublicConfigKeepNameservers(t *testing.T) {
+	ns := []net.IP{net.ParseIP("10.0.0.53")}
+	current := &PublicConfig{Domain: "node.grid.tf", Nameservers: ns}
+
+	// configs from the chain have no nameservers
+	cfg := PublicConfig{Domain: "node.grid.tf"}
+	cfg.KeepNameservers(current)
+	require.Equal(t, ns, cfg.Nameservers)
+	require.True(t, cfg.Equal(*current))
+
+	other := []net.IP{net.ParseIP("10.0.1.53")}
+	cfg = PublicConfig{Nameservers: other}
+	cfg.KeepNameservers(current)
+	require.Equal(t, other, cfg.Nameservers)
+
+	cfg = PublicConfig{}
+	cfg.KeepNameservers(nil)
+	require.Empty(t, cfg.Nameservers)
+}
@@ -0,0 +1,106 @@
+package perf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+const (
+	// elections are stored outside of the results prefix so they are not
+	// listed as task results
+	electionPrefix = "perf-election"
+)
+
+func electionKey(responsibility string) string {
+	return fmt.Sprintf("%s.%s", electionPrefix, responsibility)
+}
+
+// ElectionRecorder keeps the last election of farm-wide tasks
+type ElectionRecorder interface {
+	RecordElection(election pkg.Election) error
+}
+
+type electionRecorderKey struct{}
+
+// WithElectionRecorder adds an ElectionRecorder to the provided context
+func WithElectionRecorder(ctx context.Context, recorder ElectionRecorder) context.Context {
+	return context.WithValue(ctx, electionRecorderKey{}, recorder)
+}
+
+// RecordElection records the election with the recorder of the context, it's
+// a no-op if the context has no recorder
+func RecordElection(ctx context.Context, election pkg.Election) {
+	recorder, ok := ctx.Value(electionRecorderKey{}).(ElectionRecorder)
+	if !ok {
+		return
+	}
+
+	if err := recorder.RecordElection(election); err != nil {
+		log.Error().Err(err).Str("responsibility", election.Responsibility).Msg("failed to record election")
+	}
+}
+
+// RecordElection stores the last election of a responsibility
+func (pm *PerformanceMonitor) RecordElection(election pkg.Election) error {
+	data, err := json.Marshal(election)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal election to JSON")
+	}
+
+	conn := pm.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("SET", electionKey(election.Responsibility), data)
+	return err
+}
+
+// Elections returns the last election of all farm-wide responsibilities
+func (pm *PerformanceMonitor) Elections() ([]pkg.Election, error) {
+	conn := pm.pool.Get()
+	defer conn.Close()
+
+	elections := []pkg.Election{}
+	cursor := 0
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", electionKey("*")))
+		if err != nil {
+			return nil, err
+		}
+
+		var keys []string
+		if _, err := redis.Scan(values, &cursor, &keys); err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			data, err := redis.Bytes(conn.Do("GET", key))
+			if err != nil {
+				continue
+			}
+
+			var election pkg.Election
+			if err := json.Unmarshal(data, &election); err != nil {
+				log.Error().Err(err).Str("key", key).Msg("invalid election data")
+				continue
+			}
+			elections = append(elections, election)
+		}
+
+		if cursor == 0 {
+			break
+		}
+	}
+
+	sort.Slice(elections, func(i, j int) bool {
+		return elections[i].Responsibility < elections[j].Responsibility
+	})
+
+	return elections, nil
+}
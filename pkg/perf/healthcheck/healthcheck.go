@@ -23,8 +23,9 @@ const (
 // NewTask returns a new health check task.
 func NewTask() perf.Task {
 	checks := map[string]checkFunc{
-		"cache":   cacheCheck,
-		"network": networkCheck,
+		"cache":    cacheCheck,
+		"network":  networkCheck,
+		"identity": identityCheck,
 	}
 	return &healthcheckTask{
 		checks: checks,
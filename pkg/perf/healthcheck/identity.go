@@ -0,0 +1,72 @@
+package healthcheck
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/perf"
+	"github.com/threefoldtech/zosbase/pkg/stubs"
+)
+
+// errChainUnreachable is reported by the identity check if the node identity
+// can't be checked against the chain, as opposed to a key mismatch
+var errChainUnreachable = errors.New("chain unreachable")
+
+// identityCheck makes sure the node identity key can still be used to sign
+// and verify, and that it's the same key of the twin registered for the node
+// returns errors as a report for perf healthcheck
+func identityCheck(ctx context.Context) []error {
+	cl := perf.MustGetZbusClient(ctx)
+	identity := stubs.NewIdentityManagerStub(cl)
+
+	message := make([]byte, 32)
+	if _, err := rand.Read(message); err != nil {
+		return []error{fmt.Errorf("failed to generate identity check message: %w", err)}
+	}
+
+	signature, err := identity.Sign(ctx, message)
+	if err != nil {
+		return []error{fmt.Errorf("node identity failed to sign: %w", err)}
+	}
+
+	if err := identity.Verify(ctx, message, signature); err != nil {
+		return []error{fmt.Errorf("node identity failed to verify its own signature: %w", err)}
+	}
+
+	address, err := identity.Address(ctx)
+	if err != nil {
+		return []error{fmt.Errorf("failed to get node identity address: %w", err)}
+	}
+
+	account, err := substrate.FromAddress(address.String())
+	if err != nil {
+		return []error{fmt.Errorf("invalid node identity address '%s': %w", address, err)}
+	}
+
+	registrar := stubs.NewRegistrarStub(cl)
+	twinID, err := registrar.TwinID(ctx)
+	if err != nil {
+		return []error{fmt.Errorf("failed to get node twin id: %w", err)}
+	}
+
+	gw := stubs.NewSubstrateGatewayStub(cl)
+	owner, subErr := gw.GetTwinByPubKey(ctx, account.PublicKey())
+	if subErr.IsCode(pkg.CodeNotFound) {
+		return []error{fmt.Errorf("node identity '%s' is not the account of any twin, expected twin %d", address, twinID)}
+	} else if subErr.IsError() {
+		// the key can't be checked, this is not an identity problem
+		return []error{fmt.Errorf("%w: failed to get the twin of node identity '%s': %v", errChainUnreachable, address, subErr.Err)}
+	}
+
+	if owner != twinID {
+		return []error{fmt.Errorf("node identity '%s' is the account of twin %d, not of the registered node twin %d", address, owner, twinID)}
+	}
+
+	log.Debug().Uint32("twin", twinID).Msg("node identity check passed")
+	return nil
+}
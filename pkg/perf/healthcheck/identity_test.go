@@ -0,0 +1,95 @@
+package healthcheck
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/zbus"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/mocks"
+	"github.com/threefoldtech/zosbase/pkg/perf"
+	"github.com/vmihailenco/msgpack"
+	"go.uber.org/mock/gomock"
+)
+
+// response builds a zbus response the same way the zbus server does
+func response(t *testing.T, values ...interface{}) *zbus.Response {
+	var data []byte
+	var err error
+	switch len(values) {
+	case 0:
+	case 1:
+		data, err = msgpack.Marshal(values[0])
+	default:
+		data, err = msgpack.Marshal(values)
+	}
+	require.NoError(t, err)
+
+	return zbus.NewResponse("", zbus.Output{Data: data}, "")
+}
+
+// identityContext fakes the node modules, the chain answers the twin of the
+// node identity with owner and subErr
+func identityContext(t *testing.T, owner uint32, subErr pkg.SubstrateError) context.Context {
+	_, sk, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	id, err := substrate.NewIdentityFromEd25519Key(sk)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	client := mocks.NewMockClient(ctrl)
+	client.EXPECT().
+		RequestContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, module string, object zbus.ObjectID, method string, args ...interface{}) (*zbus.Response, error) {
+			switch method {
+			case "Sign":
+				return response(t, []byte("signature")), nil
+			case "Verify":
+				return response(t), nil
+			case "Address":
+				return response(t, pkg.Address(id.Address())), nil
+			case "TwinID":
+				return response(t, uint32(10)), nil
+			case "GetTwinByPubKey":
+				require.Equal(t, []byte(id.PublicKey()), args[0])
+				return response(t, owner, subErr), nil
+			}
+			return nil, fmt.Errorf("unexpected call to %s", method)
+		}).
+		AnyTimes()
+
+	return perf.WithZbusClient(context.Background(), client)
+}
+
+func TestIdentityCheck(t *testing.T) {
+	t.Run("match", func(t *testing.T) {
+		errs := identityCheck(identityContext(t, 10, pkg.SubstrateError{}))
+		require.Empty(t, errs)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		errs := identityCheck(identityContext(t, 11, pkg.SubstrateError{}))
+		require.Len(t, errs, 1)
+		require.False(t, errors.Is(errs[0], errChainUnreachable))
+		require.Contains(t, errs[0].Error(), "twin 11")
+	})
+
+	t.Run("not registered", func(t *testing.T) {
+		errs := identityCheck(identityContext(t, 0, pkg.SubstrateError{Code: pkg.CodeNotFound}))
+		require.Len(t, errs, 1)
+		require.False(t, errors.Is(errs[0], errChainUnreachable))
+	})
+
+	t.Run("chain unreachable", func(t *testing.T) {
+		errs := identityCheck(identityContext(t, 0, pkg.SubstrateError{Code: pkg.CodeGenericError}))
+		require.Len(t, errs, 1)
+		require.True(t, errors.Is(errs[0], errChainUnreachable))
+		require.Contains(t, errs[0].Error(), "chain unreachable")
+	})
+}
@@ -33,7 +33,7 @@ func networkCheck(ctx context.Context) []error {
 		"relay":      env.RelaysURLs,
 		"graphql":    env.GraphQL,
 		"hub":        {env.FlistURL},
-		"kyc":        {env.KycURL},
+		"kyc":        env.KycURLs,
 	}
 
 	for service, instances := range services {
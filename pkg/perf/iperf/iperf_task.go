@@ -29,11 +29,137 @@ const (
 	maxElapsedTime  = 7 * time.Minute
 	iperfTimeout    = 90 * time.Second
 
+	// serverBusyMessage is reported by iperf3 if the server is already
+	// running a test for another client
+	serverBusyMessage = "the server is busy running a test"
+
 	iperf3ServersURL = "https://export.iperf3serverlist.net/listed_iperf3_servers.json"
+
+	// DefaultDuration is the default duration of each test
+	DefaultDuration = 10 * time.Second
+	// MinDuration and MaxDuration bound the duration of each test, the max
+	// leaves enough time for the test to finish within the iperf timeout
+	MinDuration = time.Second
+	MaxDuration = 60 * time.Second
+
+	// DefaultUDPBandwidth is the default target bandwidth of the udp test in Mbps
+	DefaultUDPBandwidth = 10
+	// MaxUDPBandwidth is the max target bandwidth of the udp test in Mbps
+	MaxUDPBandwidth = 10000
+)
+
+// Test types of the iperf results
+const (
+	TestTypeTCP = "tcp"
+	TestTypeUDP = "udp"
+	// TestTypeTCPBidir is a tcp test in both directions at the same time
+	TestTypeTCPBidir = "tcp-bidir"
+)
+
+var (
+	errServerBusy = errors.New("iperf3 server is busy")
+
+	// DefaultRetry is the retry config of failed iperf tests
+	DefaultRetry = RetryConfig{
+		MaxRetries:      maxRetries,
+		InitialInterval: initialInterval,
+		MaxInterval:     maxInterval,
+		MaxElapsedTime:  maxElapsedTime,
+	}
+
+	// DefaultBusyRetry is the retry config used if the iperf3 server is
+	// busy running a test for another client. Busy servers are usually
+	// free again after a test duration so it waits longer between retries.
+	DefaultBusyRetry = RetryConfig{
+		MaxRetries:      5,
+		InitialInterval: 30 * time.Second,
+		MaxInterval:     2 * time.Minute,
+		MaxElapsedTime:  10 * time.Minute,
+	}
 )
 
+// RetryConfig is the exponential backoff used to retry a failed iperf test
+type RetryConfig struct {
+	MaxRetries      uint64
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+func (c RetryConfig) backOff() backoff.BackOff {
+	bo := backoff.NewExponentialBackOff()
+	bo.InitialInterval = c.InitialInterval
+	bo.MaxInterval = c.MaxInterval
+	bo.MaxElapsedTime = c.MaxElapsedTime
+
+	b := backoff.WithMaxRetries(bo, c.MaxRetries)
+	b.Reset()
+	return b
+}
+
+// Option configures the iperf task
+type Option func(t *IperfTest)
+
+// WithRetry sets the retry config of failed tests
+func WithRetry(retry RetryConfig) Option {
+	return func(t *IperfTest) {
+		t.retry = retry
+	}
+}
+
+// WithBusyRetry sets the retry config used if the server is busy
+func WithBusyRetry(retry RetryConfig) Option {
+	return func(t *IperfTest) {
+		t.busyRetry = retry
+	}
+}
+
+// WithServers sets trusted iperf3 servers that are tried in order before the
+// public servers list, so results are comparable across runs. The public list
+// is only used if none of the servers is reachable.
+func WithServers(servers ...Iperf3Server) Option {
+	return func(t *IperfTest) {
+		t.servers = servers
+	}
+}
+
+// WithDuration sets the duration of each test, it's bounded
+// by MinDuration and MaxDuration
+func WithDuration(duration time.Duration) Option {
+	return func(t *IperfTest) {
+		t.duration = duration
+	}
+}
+
+// WithUDPBandwidth sets the target bandwidth of the udp test in Mbps, it's
+// bounded by MaxUDPBandwidth
+func WithUDPBandwidth(mbps uint) Option {
+	return func(t *IperfTest) {
+		t.udpBandwidth = mbps
+	}
+}
+
+// WithBidir enables an extra tcp test in both directions at the same
+// time, to measure the duplex performance
+func WithBidir(enabled bool) Option {
+	return func(t *IperfTest) {
+		t.bidir = enabled
+	}
+}
+
 // IperfTest for iperf tcp/udp tests
 type IperfTest struct {
+	retry     RetryConfig
+	busyRetry RetryConfig
+	// servers are the configured servers, tried before the public ones
+	servers []Iperf3Server
+	// duration of each test
+	duration time.Duration
+	// udpBandwidth is the target bandwidth of the udp test in Mbps
+	udpBandwidth uint
+	// bidir runs the extra bidirectional tcp test
+	bidir bool
+
 	// Optional dependencies for testing
 	execWrapper           execwrapper.ExecWrapper
 	httpClient            *http.Client
@@ -87,14 +213,22 @@ func (s *Iperf3Server) UnmarshalJSON(data []byte) error {
 }
 
 // NewTask creates a new iperf test
-func NewTask() perf.Task {
+func NewTask(opts ...Option) perf.Task {
 	// because go-iperf left tmp directories with perf binary in it each time
 	// the task had run
 	matches, _ := filepath.Glob("/tmp/goiperf*")
 	for _, match := range matches {
 		os.RemoveAll(match)
 	}
-	return &IperfTest{}
+	t := &IperfTest{
+		retry:     DefaultRetry,
+		busyRetry: DefaultBusyRetry,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
 }
 
 // ID returns the ID of the tcp task
@@ -133,8 +267,7 @@ func (t *IperfTest) Run(ctx context.Context) (interface{}, error) {
 		}
 	}
 
-	// Fetch a reachable public iperf3 server
-	server, err := t.fetchIperf3Server(ctx)
+	server, err := t.findServer(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to fetch public iperf3 server")
 	}
@@ -148,16 +281,38 @@ func (t *IperfTest) Run(ctx context.Context) (interface{}, error) {
 	var results []IperfResult
 
 	// Run TCP test
-	res := t.runIperfTest(ctx, *server, true)
+	res := t.runIperfTest(ctx, *server, TestTypeTCP)
 	results = append(results, res)
 
 	// Run UDP test
-	res = t.runIperfTest(ctx, *server, false)
+	res = t.runIperfTest(ctx, *server, TestTypeUDP)
 	results = append(results, res)
 
+	if t.bidir {
+		res = t.runIperfTest(ctx, *server, TestTypeTCPBidir)
+		results = append(results, res)
+	}
+
 	return results, nil
 }
 
+// findServer returns the first reachable configured server, otherwise it
+// falls back to a reachable public iperf3 server
+func (t *IperfTest) findServer(ctx context.Context) (*Iperf3Server, error) {
+	for _, server := range t.servers {
+		if t.skipReachabilityCheck || t.isServerReachable(ctx, server) {
+			return &server, nil
+		}
+		log.Debug().Str("host", server.Host).Int("port", server.Port).Msg("configured iperf3 server unreachable, trying next")
+	}
+
+	if len(t.servers) > 0 {
+		log.Warn().Msg("no configured iperf3 server is reachable, falling back to public servers")
+	}
+
+	return t.fetchIperf3Server(ctx)
+}
+
 // fetchIperf3Server fetches the list of public iperf3 servers and finds the first reachable one
 func (t *IperfTest) fetchIperf3Server(ctx context.Context) (*Iperf3Server, error) {
 	client := t.httpClient
@@ -261,20 +416,28 @@ func (t *IperfTest) isServerReachable(ctx context.Context, server Iperf3Server)
 	return true
 }
 
-func (t *IperfTest) runIperfTest(ctx context.Context, server Iperf3Server, tcp bool) IperfResult {
-	opts := make([]string, 0)
-
-	opts = append(opts,
+// args returns the iperf3 arguments of a test of testType against server
+func (t *IperfTest) args(server Iperf3Server, testType string) []string {
+	opts := []string{
 		"--client", server.Host,
 		"--port", fmt.Sprint(server.Port),
-		"--time", "10", // 10 second test duration
+		"--time", fmt.Sprint(int(t.testDuration().Seconds())),
 		"--json",
-	)
+	}
 
-	if !tcp {
-		opts = append(opts, "--udp", "--bandwidth", "10M") // 10 Mbps for UDP
+	switch testType {
+	case TestTypeUDP:
+		opts = append(opts, "--udp", "--bandwidth", fmt.Sprintf("%dM", t.testUDPBandwidth()))
+	case TestTypeTCPBidir:
+		opts = append(opts, "--bidir")
 	}
 
+	return opts
+}
+
+func (t *IperfTest) runIperfTest(ctx context.Context, server Iperf3Server, testType string) IperfResult {
+	opts := t.args(server, testType)
+
 	var execWrap execwrapper.ExecWrapper = &execwrapper.RealExecWrapper{}
 	if t.execWrapper != nil {
 		execWrap = t.execWrapper
@@ -286,7 +449,9 @@ func (t *IperfTest) runIperfTest(ctx context.Context, server Iperf3Server, tcp b
 		defer cancel()
 
 		res := runIperf3Command(timeoutCtx, opts, execWrap)
-		if res.Error != "" {
+		if strings.Contains(res.Error, serverBusyMessage) {
+			return errors.Wrap(errServerBusy, res.Error)
+		} else if res.Error != "" {
 			return errors.New(res.Error)
 		}
 
@@ -298,19 +463,9 @@ func (t *IperfTest) runIperfTest(ctx context.Context, server Iperf3Server, tcp b
 		log.Debug().Err(err).Stringer("retry-in", waitTime).Msg("retrying iperf3 test")
 	}
 
-	bo := backoff.NewExponentialBackOff()
-	bo.InitialInterval = initialInterval
-	bo.MaxInterval = maxInterval
-	bo.MaxElapsedTime = maxElapsedTime
-
-	b := backoff.WithMaxRetries(bo, maxRetries)
-	err := backoff.RetryNotify(operation, b, notify)
-
-	proto := "tcp"
-	if !tcp {
-		proto = "udp"
-	}
+	err := retry(ctx, operation, t.retryConfig(), t.busyRetryConfig(), notify)
 
+	proto := testType
 	iperfResult := IperfResult{
 		ServerHost: server.Host,
 		ServerIP:   server.Host,
@@ -327,9 +482,7 @@ func (t *IperfTest) runIperfTest(ctx context.Context, server Iperf3Server, tcp b
 	iperfResult.CpuReport = report.End.CPUUtilizationPercent
 	iperfResult.Error = report.Error
 
-	// Both TCP and UDP use sum_sent and sum_received in the end section
-	iperfResult.UploadSpeed = report.End.SumSent.BitsPerSecond
-	iperfResult.DownloadSpeed = report.End.SumReceived.BitsPerSecond
+	iperfResult.UploadSpeed, iperfResult.DownloadSpeed = speeds(report, testType)
 
 	// Log if there's an error in the report
 	if report.Error != "" {
@@ -341,6 +494,88 @@ func (t *IperfTest) runIperfTest(ctx context.Context, server Iperf3Server, tcp b
 	return iperfResult
 }
 
+// speeds returns the upload and download speeds of the report of a test
+func speeds(report iperfCommandOutput, testType string) (upload, download float64) {
+	if testType == TestTypeTCPBidir {
+		// both directions run at the same time, each one is measured
+		// on its receiving side
+		return report.End.SumReceived.BitsPerSecond, report.End.SumReceivedBidirReverse.BitsPerSecond
+	}
+
+	// Both TCP and UDP use sum_sent and sum_received in the end section
+	return report.End.SumSent.BitsPerSecond, report.End.SumReceived.BitsPerSecond
+}
+
+func (t *IperfTest) testDuration() time.Duration {
+	switch {
+	case t.duration == 0:
+		return DefaultDuration
+	case t.duration < MinDuration:
+		return MinDuration
+	case t.duration > MaxDuration:
+		return MaxDuration
+	}
+	return t.duration
+}
+
+func (t *IperfTest) testUDPBandwidth() uint {
+	switch {
+	case t.udpBandwidth == 0:
+		return DefaultUDPBandwidth
+	case t.udpBandwidth > MaxUDPBandwidth:
+		return MaxUDPBandwidth
+	}
+	return t.udpBandwidth
+}
+
+func (t *IperfTest) retryConfig() RetryConfig {
+	if t.retry == (RetryConfig{}) {
+		return DefaultRetry
+	}
+	return t.retry
+}
+
+func (t *IperfTest) busyRetryConfig() RetryConfig {
+	if t.busyRetry == (RetryConfig{}) {
+		return DefaultBusyRetry
+	}
+	return t.busyRetry
+}
+
+// retry runs the operation until it succeeds. Busy server errors are
+// retried with the busy backoff, all other errors with the generic one.
+func retry(ctx context.Context, operation backoff.Operation, generic, busy RetryConfig, notify backoff.Notify) error {
+	genericBackOff := generic.backOff()
+	busyBackOff := busy.backOff()
+
+	for {
+		err := operation()
+		if err == nil {
+			return nil
+		}
+
+		b := genericBackOff
+		if errors.Is(err, errServerBusy) {
+			b = busyBackOff
+		}
+
+		next := b.NextBackOff()
+		if next == backoff.Stop {
+			return err
+		}
+
+		if notify != nil {
+			notify(err, next)
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(next):
+		}
+	}
+}
+
 func runIperf3Command(ctx context.Context, opts []string, execWrap execwrapper.ExecWrapper) iperfCommandOutput {
 	output, err := execWrap.CommandContext(ctx, "iperf", opts...).CombinedOutput()
 	exitErr := &exec.ExitError{}
@@ -351,9 +586,16 @@ func runIperf3Command(ctx context.Context, opts []string, execWrap execwrapper.E
 		}
 		if !errors.As(err, &exitErr) {
 			log.Error().Err(err).Msg("failed to run iperf3")
+			return iperfCommandOutput{Error: err.Error()}
 		}
 
-		return iperfCommandOutput{}
+		// iperf3 still reports the reason of the failure in its json output
+		var report iperfCommandOutput
+		if err := json.Unmarshal(output, &report); err == nil && report.Error != "" {
+			return report
+		}
+
+		return iperfCommandOutput{Error: err.Error()}
 	}
 	var report iperfCommandOutput
 	if err := json.Unmarshal(output, &report); err != nil {
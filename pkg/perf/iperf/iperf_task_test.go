@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os/exec"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	execwrapper "github.com/threefoldtech/zosbase/pkg/perf/exec_wrapper"
@@ -163,12 +167,225 @@ func TestIperfTest_Run_NoServersAvailable(t *testing.T) {
 	assert.Nil(t, result)
 }
 
+func TestIperfTest_RunIperfTest_ServerBusy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockExec := execwrapper.NewMockExecWrapper(ctrl)
+	mockCmd := execwrapper.NewMockExecCmd(ctrl)
+
+	fast := RetryConfig{
+		MaxRetries:      1,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     time.Millisecond,
+		MaxElapsedTime:  time.Second,
+	}
+
+	task := &IperfTest{
+		execWrapper: mockExec,
+		retry:       fast,
+		busyRetry:   RetryConfig{MaxRetries: 3, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxElapsedTime: time.Second},
+	}
+
+	busyOutput := []byte(`{"error": "error - the server is busy running a test. try again later"}`)
+	okOutput, _ := json.Marshal(createMockIperfOutput(false, 1000000, 2000000))
+
+	mockExec.EXPECT().
+		CommandContext(gomock.Any(), "iperf", gomock.Any()).
+		Return(mockCmd).
+		Times(4)
+
+	// busy errors are retried with the busy backoff, more than the generic
+	// max retries
+	exitErr := &exec.ExitError{}
+	gomock.InOrder(
+		mockCmd.EXPECT().CombinedOutput().Return(busyOutput, exitErr),
+		mockCmd.EXPECT().CombinedOutput().Return(busyOutput, exitErr),
+		mockCmd.EXPECT().CombinedOutput().Return(busyOutput, exitErr),
+		mockCmd.EXPECT().CombinedOutput().Return(okOutput, nil),
+	)
+
+	result := task.runIperfTest(context.Background(), Iperf3Server{Host: "192.168.1.100", Port: 5201}, TestTypeTCP)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, float64(1000000), result.UploadSpeed)
+}
+
+func TestIperfTest_RunIperfTest_Failure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockExec := execwrapper.NewMockExecWrapper(ctrl)
+	mockCmd := execwrapper.NewMockExecCmd(ctrl)
+
+	task := &IperfTest{
+		execWrapper: mockExec,
+		retry:       RetryConfig{MaxRetries: 2, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxElapsedTime: time.Second},
+	}
+
+	failedOutput := []byte(`{"error": "unable to connect to server: Connection refused"}`)
+
+	mockExec.EXPECT().
+		CommandContext(gomock.Any(), "iperf", gomock.Any()).
+		Return(mockCmd).
+		Times(3)
+	mockCmd.EXPECT().CombinedOutput().Return(failedOutput, &exec.ExitError{}).Times(3)
+
+	result := task.runIperfTest(context.Background(), Iperf3Server{Host: "192.168.1.100", Port: 5201}, TestTypeUDP)
+	assert.Contains(t, result.Error, "Connection refused")
+	assert.Equal(t, "udp", result.TestType)
+}
+
 func TestNewTask(t *testing.T) {
 	task := NewTask()
 	assert.NotNil(t, task)
 	assert.Equal(t, "iperf", task.ID())
 }
 
+func TestIperfTest_FindServer_Configured(t *testing.T) {
+	// a reachable configured server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	reachable := listener.Addr().(*net.TCPAddr)
+
+	// and an unreachable one
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	unreachable := closed.Addr().(*net.TCPAddr)
+	closed.Close()
+
+	fetched := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		_, _ = w.Write([]byte(`[{"IP/HOST":"192.168.1.100","PORT":"5201"}]`))
+	}))
+	defer server.Close()
+
+	task := NewTask(WithServers(
+		Iperf3Server{Host: "127.0.0.1", Port: unreachable.Port},
+		Iperf3Server{Host: "127.0.0.1", Port: reachable.Port},
+	)).(*IperfTest)
+	task.httpClient = server.Client()
+	task.serversURL = server.URL
+
+	found, err := task.findServer(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, reachable.Port, found.Port)
+	// the public list is not fetched
+	assert.Equal(t, 0, fetched)
+}
+
+func TestIperfTest_FindServer_Fallback(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	reachable := listener.Addr().(*net.TCPAddr)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	unreachable := closed.Addr().(*net.TCPAddr)
+	closed.Close()
+
+	fetched := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		_, _ = fmt.Fprintf(w, `[{"IP/HOST":"127.0.0.1","PORT":"%d"}]`, reachable.Port)
+	}))
+	defer server.Close()
+
+	task := &IperfTest{
+		servers:    []Iperf3Server{{Host: "127.0.0.1", Port: unreachable.Port}},
+		httpClient: server.Client(),
+		serversURL: server.URL,
+	}
+
+	// none of the configured servers is reachable, so the public list is used
+	found, err := task.findServer(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, reachable.Port, found.Port)
+	assert.Equal(t, 1, fetched)
+}
+
+func TestIperfTest_Args(t *testing.T) {
+	server := Iperf3Server{Host: "192.168.1.100", Port: 5201}
+
+	task := NewTask().(*IperfTest)
+	assert.Equal(t, []string{
+		"--client", "192.168.1.100", "--port", "5201", "--time", "10", "--json",
+	}, task.args(server, TestTypeTCP))
+	assert.Equal(t, []string{
+		"--client", "192.168.1.100", "--port", "5201", "--time", "10", "--json",
+		"--udp", "--bandwidth", "10M",
+	}, task.args(server, TestTypeUDP))
+
+	task = NewTask(WithDuration(30*time.Second), WithUDPBandwidth(500)).(*IperfTest)
+	assert.Equal(t, []string{
+		"--client", "192.168.1.100", "--port", "5201", "--time", "30", "--json",
+		"--udp", "--bandwidth", "500M",
+	}, task.args(server, TestTypeUDP))
+}
+
+func TestIperfTest_ArgsBounds(t *testing.T) {
+	server := Iperf3Server{Host: "192.168.1.100", Port: 5201}
+
+	task := NewTask(WithDuration(time.Hour), WithUDPBandwidth(100000)).(*IperfTest)
+	args := task.args(server, TestTypeUDP)
+	assert.Equal(t, "60", args[5])
+	assert.Equal(t, "10000M", args[len(args)-1])
+
+	task = NewTask(WithDuration(time.Millisecond)).(*IperfTest)
+	assert.Equal(t, "1", task.args(server, TestTypeTCP)[5])
+}
+
+// bidirOutput is the end section of a bidirectional iperf3 test output
+const bidirOutput = `{
+	"start": {"test_start": {"protocol": "TCP", "num_streams": 1, "bidir": 1}},
+	"end": {
+		"streams": [
+			{"sender": {"socket": 5, "bits_per_second": 941000000, "sender": true}, "receiver": {"socket": 5, "bits_per_second": 938000000, "sender": true}},
+			{"sender": {"socket": 7, "bits_per_second": 412000000, "sender": false}, "receiver": {"socket": 7, "bits_per_second": 410000000, "sender": false}}
+		],
+		"sum_sent": {"start": 0, "end": 10, "seconds": 10, "bytes": 1176250000, "bits_per_second": 941000000, "retransmits": 12, "sender": true},
+		"sum_received": {"start": 0, "end": 10, "seconds": 10, "bytes": 1172500000, "bits_per_second": 938000000, "sender": true},
+		"sum_sent_bidir_reverse": {"start": 0, "end": 10, "seconds": 10, "bytes": 515000000, "bits_per_second": 412000000, "retransmits": 3, "sender": false},
+		"sum_received_bidir_reverse": {"start": 0, "end": 10, "seconds": 10, "bytes": 512500000, "bits_per_second": 410000000, "sender": false},
+		"cpu_utilization_percent": {"host_total": 12.5, "remote_total": 4.1}
+	}
+}`
+
+func TestIperfTest_Bidir(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockExec := execwrapper.NewMockExecWrapper(ctrl)
+	mockCmd := execwrapper.NewMockExecCmd(ctrl)
+
+	mockExec.EXPECT().
+		CommandContext(gomock.Any(), "iperf", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, name string, args ...string) execwrapper.ExecCmd {
+			assert.Contains(t, args, "--bidir")
+			return mockCmd
+		})
+	mockCmd.EXPECT().CombinedOutput().Return([]byte(bidirOutput), nil)
+
+	report := runIperf3Command(context.Background(), []string{"--client", "192.168.1.100", "--bidir"}, mockExec)
+	assert.Empty(t, report.Error)
+	assert.Equal(t, float64(410000000), report.End.SumReceivedBidirReverse.BitsPerSecond)
+
+	upload, download := speeds(report, TestTypeTCPBidir)
+	assert.Equal(t, float64(938000000), upload)
+	assert.Equal(t, float64(410000000), download)
+}
+
+func TestIperfTest_BidirArgs(t *testing.T) {
+	server := Iperf3Server{Host: "192.168.1.100", Port: 5201}
+
+	task := NewTask(WithBidir(true)).(*IperfTest)
+	assert.Equal(t, []string{
+		"--client", "192.168.1.100", "--port", "5201", "--time", "10", "--json", "--bidir",
+	}, task.args(server, TestTypeTCPBidir))
+}
+
 // Helper function to create mock iperf output
 func createMockIperfOutput(isUDP bool, uploadSpeed, downloadSpeed float64) iperfCommandOutput {
 	output := iperfCommandOutput{
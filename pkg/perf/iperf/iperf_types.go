@@ -8,12 +8,15 @@ type iperfCommandOutput struct {
 }
 
 type End struct {
-	Streams               []EndStream           `json:"streams"`
-	SumSent               Sum                   `json:"sum_sent"`
-	SumReceived           Sum                   `json:"sum_received"`
-	CPUUtilizationPercent CPUUtilizationPercent `json:"cpu_utilization_percent"`
-	SenderTCPCongestion   string                `json:"sender_tcp_congestion"`
-	ReceiverTCPCongestion string                `json:"receiver_tcp_congestion"`
+	Streams     []EndStream `json:"streams"`
+	SumSent     Sum         `json:"sum_sent"`
+	SumReceived Sum         `json:"sum_received"`
+	// the reverse direction (server to client) of a bidirectional test
+	SumSentBidirReverse     Sum                   `json:"sum_sent_bidir_reverse"`
+	SumReceivedBidirReverse Sum                   `json:"sum_received_bidir_reverse"`
+	CPUUtilizationPercent   CPUUtilizationPercent `json:"cpu_utilization_percent"`
+	SenderTCPCongestion     string                `json:"sender_tcp_congestion"`
+	ReceiverTCPCongestion   string                `json:"receiver_tcp_congestion"`
 }
 
 type CPUUtilizationPercent struct {
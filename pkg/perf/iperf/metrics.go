@@ -0,0 +1,37 @@
+package iperf
+
+import (
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/perf"
+)
+
+var _ perf.Collector = (*IperfTest)(nil)
+
+// Collect exposes upload and download speed per server and test type
+func (t *IperfTest) Collect(result pkg.TaskResult, metrics *perf.Metrics) error {
+	var results []IperfResult
+	if err := perf.DecodeResult(result, &results); err != nil {
+		return err
+	}
+
+	for _, res := range results {
+		if len(res.Error) != 0 {
+			continue
+		}
+
+		metrics.Gauge(
+			"iperf_upload_bits_per_second",
+			"Upload speed of the last iperf test",
+			res.UploadSpeed,
+			"server", res.ServerHost, "type", res.TestType,
+		)
+		metrics.Gauge(
+			"iperf_download_bits_per_second",
+			"Download speed of the last iperf test",
+			res.DownloadSpeed,
+			"server", res.ServerHost, "type", res.TestType,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package perf
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/metrics"
+)
+
+const metricsPrefix = "zos_perf_"
+
+// Collector can be implemented by tasks that want to expose their latest
+// result as prometheus metrics
+type Collector interface {
+	Collect(result pkg.TaskResult, metrics *Metrics) error
+}
+
+// Metrics collects the perf metric samples, all names are prefixed with
+// `zos_perf_`
+type Metrics struct {
+	*metrics.Writer
+}
+
+// NewMetrics creates a new empty metrics set
+func NewMetrics() *Metrics {
+	return &Metrics{Writer: metrics.NewWriter()}
+}
+
+// Gauge adds a gauge sample. name is prefixed with `zos_perf_`, labels
+// are given as key, value pairs.
+func (m *Metrics) Gauge(name, help string, value float64, labels ...string) {
+	m.Writer.Gauge(metricsPrefix+name, help, value, labels...)
+}
+
+// DecodeResult decodes the result of a task into v. Results read back
+// from the cache are generic json values so they need to be decoded
+// into the task result type.
+func DecodeResult(result pkg.TaskResult, v interface{}) error {
+	data, err := json.Marshal(result.Result)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// Metrics returns the latest results of all tasks in prometheus text
+// exposition format
+func (pm *PerformanceMonitor) Metrics() (string, error) {
+	metrics := NewMetrics()
+	for _, task := range pm.tasks {
+		result, err := pm.Get(task.ID())
+		if errors.Is(err, ErrResultNotFound) {
+			continue
+		} else if err != nil {
+			return "", errors.Wrapf(err, "failed to get result of task: %s", task.ID())
+		}
+
+		metrics.Gauge(
+			"last_run_timestamp_seconds",
+			"Unix time of the last run of a perf task",
+			float64(result.Timestamp),
+			"task", task.ID(),
+		)
+
+		collector, ok := task.(Collector)
+		if !ok {
+			continue
+		}
+
+		if err := collector.Collect(result, metrics); err != nil {
+			log.Warn().Err(err).Str("task", task.ID()).Msg("failed to collect task metrics")
+		}
+	}
+
+	var buf strings.Builder
+	if _, err := metrics.WriteTo(&buf); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// MetricsHandler returns an http handler that serves the perf metrics
+// so they can be scraped by prometheus
+func (pm *PerformanceMonitor) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := pm.Metrics()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", metrics.ContentType)
+		_, _ = io.WriteString(w, result)
+	})
+}
@@ -0,0 +1,43 @@
+package perf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+func TestMetricsWriteTo(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.Gauge("b", "metric b", 2, "server", `a"b`)
+	metrics.Gauge("a", "metric a", 1)
+	metrics.Gauge("b", "metric b", 3.5, "server", "c", "type", "tcp")
+
+	var buf strings.Builder
+	_, err := metrics.WriteTo(&buf)
+	require.NoError(t, err)
+
+	expected := `# HELP zos_perf_a metric a
+# TYPE zos_perf_a gauge
+zos_perf_a 1
+# HELP zos_perf_b metric b
+# TYPE zos_perf_b gauge
+zos_perf_b{server="a\"b"} 2
+zos_perf_b{server="c",type="tcp"} 3.5
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestDecodeResult(t *testing.T) {
+	result := pkg.TaskResult{
+		Result: map[string]interface{}{"state": "valid"},
+	}
+
+	var decoded struct {
+		State string `json:"state"`
+	}
+	require.NoError(t, DecodeResult(result, &decoded))
+	assert.Equal(t, "valid", decoded.State)
+}
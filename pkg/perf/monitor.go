@@ -79,6 +79,7 @@ func (pm *PerformanceMonitor) runTask(ctx context.Context, task Task) error {
 // Run adds the tasks to the cron queue and start the scheduler
 func (pm *PerformanceMonitor) Run(ctx context.Context) error {
 	ctx = WithZbusClient(ctx, pm.zbusClient)
+	ctx = WithElectionRecorder(ctx, pm)
 	for _, task := range pm.tasks {
 		task := task
 		if _, err := pm.scheduler.CronWithSeconds(task.Cron()).Do(func() error {
@@ -0,0 +1,48 @@
+package publicip
+
+import (
+	"sort"
+
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/perf"
+)
+
+var _ perf.Collector = (*publicIPValidationTask)(nil)
+
+// Collect exposes the number of farm public ips per validation state. Nothing
+// is reported if the validation was skipped on this node.
+func (p *publicIPValidationTask) Collect(result pkg.TaskResult, metrics *perf.Metrics) error {
+	var report map[string]IPReport
+	if err := perf.DecodeResult(result, &report); err != nil {
+		return err
+	}
+
+	if len(report) == 0 {
+		return nil
+	}
+
+	counts := map[string]int{
+		ValidState:   0,
+		InvalidState: 0,
+	}
+	for _, ip := range report {
+		counts[ip.State]++
+	}
+
+	states := make([]string, 0, len(counts))
+	for state := range counts {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+
+	for _, state := range states {
+		metrics.Gauge(
+			"public_ips",
+			"Number of farm public ips per validation state",
+			float64(counts[state]),
+			"state", state,
+		)
+	}
+
+	return nil
+}
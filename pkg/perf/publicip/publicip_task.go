@@ -4,8 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
 	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff/v3"
@@ -14,13 +20,17 @@ import (
 	"github.com/pion/stun"
 	"github.com/rs/zerolog/log"
 	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/zosbase/pkg"
 	"github.com/threefoldtech/zosbase/pkg/environment"
 	"github.com/threefoldtech/zosbase/pkg/network/macvlan"
 	"github.com/threefoldtech/zosbase/pkg/network/namespace"
+	"github.com/threefoldtech/zosbase/pkg/network/options"
+	"github.com/threefoldtech/zosbase/pkg/network/types"
 	"github.com/threefoldtech/zosbase/pkg/perf"
 	"github.com/threefoldtech/zosbase/pkg/perf/graphql"
 	"github.com/threefoldtech/zosbase/pkg/stubs"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 )
 
 const (
@@ -32,19 +42,33 @@ const (
 	PublicIPDataInvalid = "public ip or gateway data are not valid"
 	IPIsUsed            = "ip is already assigned to a contract"
 	FetchRealIPFailed   = "failed to get real public IP to the node"
+	NoIPv6Egress        = "node has no ipv6 egress"
 )
 
 var (
 	errPublicIPLookup    = errors.New("failed to reach public ip service")
+	errNoPublicIP        = errors.New("no public ip address found in response")
 	errSkippedValidating = errors.New("skipped, there is a node with less ID available")
 )
 
 const (
 	testMacvlan   = "pub"
 	testNamespace = "pubtestns"
+
+	// maxWorkers is the max number of ips validated at the same time
+	maxWorkers = 4
+	// workerTableBase is the routing table of the first worker
+	workerTableBase = 100
+
+	// httpEchoTimeout is the timeout of a request to an http ip echo service
+	httpEchoTimeout = 10 * time.Second
 )
 
-type publicIPValidationTask struct{}
+type publicIPValidationTask struct {
+	// servers are the STUN servers and http ip echo services used to find the
+	// real public ip, the environment StunURLs are used if not set
+	servers []string
+}
 
 type IPReport struct {
 	State  string `json:"state"`
@@ -53,8 +77,25 @@ type IPReport struct {
 
 var _ perf.Task = (*publicIPValidationTask)(nil)
 
-func NewTask() perf.Task {
-	return &publicIPValidationTask{}
+// Option configures the public ip validation task
+type Option func(t *publicIPValidationTask)
+
+// WithStunServers sets the servers used to find the real public ip of the
+// validated ips, it overrides the environment StunURLs. Servers are either STUN
+// uris (stun:host:port) or http(s) ip echo urls, invalid servers are ignored.
+func WithStunServers(servers ...string) Option {
+	return func(t *publicIPValidationTask) {
+		t.servers = environment.ValidStunURLs(servers)
+	}
+}
+
+func NewTask(opts ...Option) perf.Task {
+	t := &publicIPValidationTask{}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
 }
 
 func (p *publicIPValidationTask) ID() string {
@@ -82,11 +123,14 @@ func (p *publicIPValidationTask) Run(ctx context.Context) (interface{}, error) {
 	substrateGateway := stubs.NewSubstrateGatewayStub(cl)
 	farmID := environment.MustGet().FarmID
 
-	shouldRun, err := isLeastValidNode(ctx, uint32(farmID), substrateGateway)
+	election, err := electNode(ctx, uint32(farmID), substrateGateway)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check if the node should run public IP verification: %w", err)
 	}
-	if !shouldRun {
+	election.Responsibility = p.ID()
+	perf.RecordElection(ctx, election)
+
+	if !election.Responsible {
 		log.Warn().Msg(errSkippedValidating.Error())
 		return errSkippedValidating, nil
 	}
@@ -95,11 +139,11 @@ func (p *publicIPValidationTask) Run(ctx context.Context) (interface{}, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get farm with id %d: %w", farmID, err)
 	}
-	var report map[string]IPReport
-	err = netNS.Do(func(_ ns.NetNS) error {
-		report, err = p.validateIPs(farm.PublicIPs, nil)
-		return err
-	})
+	task := *p
+	if len(task.servers) == 0 {
+		task.servers = environment.MustGet().StunURLs
+	}
+	report, err := task.validateIPs(farm.PublicIPs, netNS, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to run public IP validation: %w", err)
 	}
@@ -110,33 +154,60 @@ func (p *publicIPValidationTask) Run(ctx context.Context) (interface{}, error) {
 type MacvlanInterface interface {
 	GetByName(name string) (*netlink.Macvlan, error)
 	Install(link *netlink.Macvlan, hw net.HardwareAddr, ips []*net.IPNet, routes []*netlink.Route, netns ns.NetNS) error
+	Create(name string, master string, netns ns.NetNS) (*netlink.Macvlan, error)
+	Delete(name string, netns ns.NetNS) error
+}
+
+// ipJob is a farm ip to be validated by one of the workers
+type ipJob struct {
+	publicIP string
+	ip       net.IP
+	ipNet    []*net.IPNet
+	routes   []*netlink.Route
 }
 
-func (p *publicIPValidationTask) validateIPs(publicIPs []substrate.PublicIP, macVlanMock MacvlanInterface) (map[string]IPReport, error) {
+var (
+	// lookupPublicIP returns the public ip of the traffic sent from the given local ip,
+	// using the given servers
+	lookupPublicIP = getRealPublicIPFrom
+	// hasIPv6Egress checks if the node can reach the internet over ipv6
+	hasIPv6Egress = ipv6Egress
+)
+
+// validateIPs validates the farm ips concurrently. Each worker owns a macvlan in the
+// test namespace and cycles through its assigned ips, the traffic of an ip is routed
+// through its worker macvlan with a source rule to the worker routing table. The
+// first worker uses the test macvlan, the others are short lived macvlans created for
+// this run only.
+func (p *publicIPValidationTask) validateIPs(publicIPs []substrate.PublicIP, netNS ns.NetNS, macVlanMock MacvlanInterface) (map[string]IPReport, error) {
 	report := make(map[string]IPReport)
 
 	var mv *netlink.Macvlan
-	var err error
-	if macVlanMock != nil {
-		mv, err = macVlanMock.GetByName(testMacvlan)
-	} else {
-		mv, err = macvlan.GetByName(testMacvlan)
-	}
+	err := inNamespace(netNS, func() (err error) {
+		if macVlanMock != nil {
+			mv, err = macVlanMock.GetByName(testMacvlan)
+		} else {
+			mv, err = macvlan.GetByName(testMacvlan)
+		}
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get macvlan %s in namespace %s: %w", testMacvlan, testNamespace, err)
 	}
 
 	if macVlanMock == nil {
-		err = deleteAllIPsAndRoutes(mv)
-		if err != nil {
-			log.Err(err).Send()
+		// workers of a previous run that was interrupted before its cleanup
+		if err := deleteStaleWorkers(netNS); err != nil {
+			log.Err(err).Msgf("failed to delete stale macvlans in namespace %s", testNamespace)
 		}
 	}
 
+	var (
+		jobs []ipJob
+		// v6Egress is only checked if the farm has ipv6 ips
+		v6Egress *bool
+	)
 	for _, publicIP := range publicIPs {
-		report[publicIP.IP] = IPReport{
-			State: ValidState,
-		}
 		if publicIP.ContractID != 0 {
 			report[publicIP.IP] = IPReport{
 				State:  SkippedState,
@@ -155,66 +226,265 @@ func (p *publicIPValidationTask) validateIPs(publicIPs []substrate.PublicIP, mac
 			continue
 		}
 
+		if ip.To4() == nil {
+			if v6Egress == nil {
+				egress := hasIPv6Egress()
+				v6Egress = &egress
+			}
+			if !*v6Egress {
+				report[publicIP.IP] = IPReport{
+					State:  SkippedState,
+					Reason: NoIPv6Egress,
+				}
+				continue
+			}
+		}
+
+		jobs = append(jobs, ipJob{publicIP: publicIP.IP, ip: ip, ipNet: ipNet, routes: routes})
+	}
+
+	workers := []*netlink.Macvlan{mv}
+	defer func() {
+		// the first worker is the test macvlan, it is kept
+		for _, worker := range workers[1:] {
+			if err := deleteWorker(worker.Attrs().Name, netNS, macVlanMock); err != nil {
+				log.Err(err).Msgf("failed to delete macvlan %s", worker.Attrs().Name)
+			}
+		}
+	}()
+
+	for i := 1; i < maxWorkers && i < len(jobs); i++ {
+		name := fmt.Sprintf("%s%d", testMacvlan, i)
+		var worker *netlink.Macvlan
 		if macVlanMock != nil {
-			err = macVlanMock.Install(mv, nil, ipNet, routes, nil)
+			worker, err = macVlanMock.Create(name, types.PublicBridge, netNS)
 		} else {
-			err = macvlan.Install(mv, nil, ipNet, routes, nil)
+			worker, err = macvlan.Create(name, types.PublicBridge, netNS)
 		}
 		if err != nil {
-			report[publicIP.IP] = IPReport{
-				State:  InvalidState,
-				Reason: PublicIPDataInvalid,
-			}
-			log.Err(err).Msgf("failed to install macvlan %s with ip %s to namespace %s", testMacvlan, ipNet, testNamespace)
+			// validate with the workers we have
+			log.Err(err).Msgf("failed to create macvlan %s in namespace %s", name, testNamespace)
 			continue
 		}
+		workers = append(workers, worker)
+	}
 
-		realIP, err := getRealPublicIP()
-		if errors.Is(err, errPublicIPLookup) {
-			report[publicIP.IP] = IPReport{
-				State:  InvalidState,
-				Reason: PublicIPDataInvalid,
+	queue := make(chan ipJob, len(jobs))
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for i, worker := range workers {
+		table := workerTableBase + i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := inNamespace(netNS, func() error {
+				if macVlanMock == nil {
+					if err := deleteAllIPsAndRoutes(worker); err != nil {
+						log.Err(err).Send()
+					}
+					// ipv6 ips can't be used until dad is done
+					if err := options.Set(worker.Attrs().Name, options.AcceptDAD(false)); err != nil {
+						log.Err(err).Msgf("failed to disable dad on macvlan %s", worker.Attrs().Name)
+					}
+				}
+
+				for job := range queue {
+					result := validateIP(worker, table, job, p.servers, macVlanMock)
+					mu.Lock()
+					report[job.publicIP] = result
+					mu.Unlock()
+				}
+				return nil
+			})
+			if err != nil {
+				log.Err(err).Msgf("failed to enter namespace %s", testNamespace)
 			}
-		} else if err != nil {
-			report[publicIP.IP] = IPReport{
+		}()
+	}
+	wg.Wait()
+
+	// ips are not left without a report if a worker failed
+	for _, job := range jobs {
+		if _, ok := report[job.publicIP]; !ok {
+			report[job.publicIP] = IPReport{
 				State:  SkippedState,
 				Reason: FetchRealIPFailed,
 			}
-		} else if !ip.Equal(realIP) {
-			report[publicIP.IP] = IPReport{
-				State:  InvalidState,
-				Reason: IPsNotMatching,
-			}
 		}
+	}
 
-		if macVlanMock == nil {
-			err = deleteAllIPsAndRoutes(mv)
-			if err != nil {
+	if macVlanMock == nil {
+		err = inNamespace(netNS, func() error {
+			return netlink.LinkSetDown(mv)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to set link down: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// validateIP installs the ip on the worker macvlan, and checks that the traffic
+// sent from that ip comes out with the same public ip.
+func validateIP(mv *netlink.Macvlan, table int, job ipJob, servers []string, macVlanMock MacvlanInterface) IPReport {
+	for _, route := range job.routes {
+		route.Table = table
+	}
+
+	var err error
+	if macVlanMock != nil {
+		err = macVlanMock.Install(mv, nil, job.ipNet, job.routes, nil)
+	} else {
+		err = macvlan.Install(mv, nil, job.ipNet, job.routes, nil)
+	}
+
+	if macVlanMock == nil {
+		defer func() {
+			if err := deleteAllIPsAndRoutes(mv); err != nil {
 				log.Err(err).Send()
 			}
+		}()
+	}
+
+	if err != nil {
+		log.Err(err).Msgf("failed to install macvlan %s with ip %s to namespace %s", mv.Attrs().Name, job.ipNet, testNamespace)
+		return IPReport{
+			State:  InvalidState,
+			Reason: PublicIPDataInvalid,
 		}
 	}
 
 	if macVlanMock == nil {
-		err = netlink.LinkSetDown(mv)
+		rule := sourceRule(job.ip, table)
+		if err := netlink.RuleAdd(rule); err != nil {
+			log.Err(err).Msgf("failed to add source rule for ip %s", job.ip)
+			return IPReport{
+				State:  SkippedState,
+				Reason: FetchRealIPFailed,
+			}
+		}
+		defer func() {
+			if err := netlink.RuleDel(rule); err != nil {
+				log.Err(err).Msgf("failed to delete source rule for ip %s", job.ip)
+			}
+		}()
+	}
+
+	realIP, err := lookupPublicIP(servers, job.ip)
+	if errors.Is(err, errPublicIPLookup) {
+		return IPReport{
+			State:  InvalidState,
+			Reason: PublicIPDataInvalid,
+		}
+	} else if err != nil {
+		return IPReport{
+			State:  SkippedState,
+			Reason: FetchRealIPFailed,
+		}
+	} else if !job.ip.Equal(realIP) {
+		return IPReport{
+			State:  InvalidState,
+			Reason: IPsNotMatching,
+		}
+	}
+
+	return IPReport{
+		State: ValidState,
+	}
+}
+
+// sourceRule routes the traffic from ip with the given table
+func sourceRule(ip net.IP, table int) *netlink.Rule {
+	rule := netlink.NewRule()
+	if ip4 := ip.To4(); ip4 != nil {
+		rule.Src = &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	} else {
+		rule.Src = &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+	}
+	rule.Table = table
+	return rule
+}
+
+func deleteWorker(name string, netNS ns.NetNS, macVlanMock MacvlanInterface) error {
+	if macVlanMock != nil {
+		return macVlanMock.Delete(name, netNS)
+	}
+
+	return inNamespace(netNS, func() error {
+		link, err := netlink.LinkByName(name)
 		if err != nil {
-			return nil, fmt.Errorf("failed to set link down: %w", err)
+			return err
 		}
+		return netlink.LinkDel(link)
+	})
+}
+
+// isWorkerName checks if name is the name of a short lived worker macvlan, the
+// test macvlan itself is not a worker
+func isWorkerName(name string) bool {
+	index, ok := strings.CutPrefix(name, testMacvlan)
+	if !ok || len(index) == 0 {
+		return false
 	}
 
-	return report, nil
+	_, err := strconv.ParseUint(index, 10, 32)
+	return err == nil
+}
+
+// deleteStaleWorkers deletes the worker macvlans left in the test namespace
+func deleteStaleWorkers(netNS ns.NetNS) error {
+	return inNamespace(netNS, func() error {
+		links, err := netlink.LinkList()
+		if err != nil {
+			return err
+		}
+
+		var errs error
+		for _, link := range links {
+			if !isWorkerName(link.Attrs().Name) {
+				continue
+			}
+			log.Info().Str("name", link.Attrs().Name).Msg("deleting stale macvlan")
+			if err := netlink.LinkDel(link); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+		return errs
+	})
 }
 
-func isLeastValidNode(ctx context.Context, farmID uint32, substrateGateway *stubs.SubstrateGatewayStub) (bool, error) {
+// inNamespace runs f inside netNS, or in the current namespace if netNS is nil
+func inNamespace(netNS ns.NetNS, f func() error) error {
+	if netNS == nil {
+		return f()
+	}
+
+	return netNS.Do(func(_ ns.NetNS) error {
+		return f()
+	})
+}
+
+// electNode elects the least id reachable node of the farm to run the
+// validation, and reports why it was elected.
+func electNode(ctx context.Context, farmID uint32, substrateGateway *stubs.SubstrateGatewayStub) (pkg.Election, error) {
+	var election pkg.Election
 	env := environment.MustGet()
 	gql, err := graphql.NewGraphQl(env.GraphQL...)
 	if err != nil {
-		return false, err
+		return election, err
 	}
 
 	nodes, err := gql.GetUpNodes(ctx, 0, farmID, 0, false, false)
 	if err != nil {
-		return false, fmt.Errorf("failed to get farm %d nodes: %w", farmID, err)
+		return election, fmt.Errorf("failed to get farm %d nodes: %w", farmID, err)
 	}
 	cl := perf.MustGetZbusClient(ctx)
 	registrar := stubs.NewRegistrarStub(cl)
@@ -228,30 +498,50 @@ func isLeastValidNode(ctx context.Context, farmID uint32, substrateGateway *stub
 		return nil
 	}, backoff.NewConstantBackOff(10*time.Second))
 	if err != nil {
-		return false, fmt.Errorf("failed to get node id: %w", err)
+		return election, fmt.Errorf("failed to get node id: %w", err)
 	}
 
+	election.NodeID = nodeID
+	election.Timestamp = uint64(time.Now().Unix())
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeID < nodes[j].NodeID
+	})
+
+	var unreachable []uint32
 	for _, node := range nodes {
 		if node.NodeID >= nodeID {
 			continue
 		}
 		n, err := substrateGateway.GetNode(ctx, node.NodeID)
 		if err != nil {
-			return false, fmt.Errorf("failed to get node %d: %w", node.NodeID, err)
+			return election, fmt.Errorf("failed to get node %d: %w", node.NodeID, err)
 		}
 		ip, err := getValidNodeIP(n)
 		if err != nil {
-			return false, err
+			return election, err
 		}
 		// stop at three and quiet output
 		err = exec.CommandContext(ctx, "ping", "-c", "3", "-q", ip).Run()
 		if err != nil {
 			log.Warn().Err(err).Msgf("failed to ping node %d", node.NodeID)
+			unreachable = append(unreachable, node.NodeID)
 			continue
 		}
-		return false, nil
+
+		election.Elected = node.NodeID
+		election.Reason = fmt.Sprintf("node %d has a lower id and is reachable", node.NodeID)
+		return election, nil
 	}
-	return true, nil
+
+	election.Elected = nodeID
+	election.Responsible = true
+	election.Reason = "this node has the lowest id of the up nodes in the farm"
+	if len(unreachable) > 0 {
+		election.Reason = fmt.Sprintf("this node has the lowest id of the reachable nodes in the farm (unreachable nodes with lower id: %v)", unreachable)
+	}
+
+	return election, nil
 }
 
 func getValidNodeIP(node substrate.Node) (string, error) {
@@ -277,50 +567,130 @@ func getIPWithRoute(publicIP substrate.PublicIP) (net.IP, []*net.IPNet, []*netli
 	if gateway == nil {
 		return nil, nil, nil, fmt.Errorf("failed to parse gateway %s: %w", publicIP.Gateway, err)
 	}
+	if (ip.To4() == nil) != (gateway.To4() == nil) {
+		return nil, nil, nil, fmt.Errorf("gateway %s is not of the same ip family as %s", publicIP.Gateway, publicIP.IP)
+	}
+
+	dst := &net.IPNet{
+		IP:   net.ParseIP("0.0.0.0"),
+		Mask: net.CIDRMask(0, 32),
+	}
+	if ip.To4() == nil {
+		dst = &net.IPNet{
+			IP:   net.IPv6zero,
+			Mask: net.CIDRMask(0, 128),
+		}
+	}
 	route := netlink.Route{
-		Dst: &net.IPNet{
-			IP:   net.ParseIP("0.0.0.0"),
-			Mask: net.CIDRMask(0, 32),
-		},
-		Gw: gateway,
+		Dst: dst,
+		Gw:  gateway,
 	}
 	return ip, []*net.IPNet{ipNet}, []*netlink.Route{&route}, nil
 }
 
 func getRealPublicIP() (net.IP, error) {
-	stunServers := []string{
-		"stun:stun1.l.google.com:19302",
-		"stun:stun2.l.google.com:19302",
-		"stun:stun3.l.google.com:19302",
-		"stun:stun4.l.google.com:19302",
-		"stun:stun.l.google.com:19302",
+	return getRealPublicIPFrom(nil, nil)
+}
+
+// getRealPublicIPFrom gets the public ip of the traffic sent from the local ip from
+// the first server that answers, any local ip is used if it's nil. The default
+// STUN servers are used if no servers are given.
+func getRealPublicIPFrom(servers []string, local net.IP) (net.IP, error) {
+	if len(servers) == 0 {
+		servers = environment.DefaultStunURLs
 	}
 
 	var errs error
-	for _, stunServer := range stunServers {
-		ip, err := getPublicIPFromSTUN(stunServer)
+	// reached is set if a server answered without an ip, so the ip
+	// is reachable but its public ip is unknown
+	reached := false
+	for _, server := range servers {
+		var ip net.IP
+		var err error
+		if strings.HasPrefix(server, "http://") || strings.HasPrefix(server, "https://") {
+			ip, err = getPublicIPFromHTTP(server, local)
+		} else {
+			ip, err = getPublicIPFromSTUN(server, local)
+		}
 		if err != nil {
 			errs = multierror.Append(errs, err)
-			log.Err(err).Msgf("failed to get public IP from STUN server %s", stunServer)
+			reached = reached || errors.Is(err, errNoPublicIP)
+			log.Err(err).Msgf("failed to get public IP from server %s", server)
 			continue
 		}
 		return ip, nil
 	}
 
-	if errs != nil {
-		return nil, errors.Join(errs, errPublicIPLookup)
+	if reached {
+		return nil, errs
 	}
-	return nil, errors.Join(fmt.Errorf("no STUN servers available"), errPublicIPLookup)
+	return nil, errors.Join(errs, errPublicIPLookup)
 }
 
-func getPublicIPFromSTUN(stunServer string) (net.IP, error) {
+// getPublicIPFromHTTP gets the public ip from an http ip echo service, that
+// answers with the ip of the caller as plain text
+func getPublicIPFromHTTP(echoURL string, local net.IP) (net.IP, error) {
+	dialer := net.Dialer{Timeout: httpEchoTimeout}
+	network := "tcp"
+	if local != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: local}
+		network = "tcp4"
+		if local.To4() == nil {
+			network = "tcp6"
+		}
+	}
+
+	client := http.Client{
+		Timeout: httpEchoTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			DisableKeepAlives: true,
+		},
+	}
+
+	response, err := client.Get(echoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ip echo service %s: %w", echoURL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ip echo service %s responded with status %s", echoURL, response.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ip echo service %s response: %w", echoURL, err)
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return nil, fmt.Errorf("ip echo service %s: %w", echoURL, errNoPublicIP)
+	}
+
+	return ip, nil
+}
+
+func getPublicIPFromSTUN(stunServer string, local net.IP) (net.IP, error) {
 	u, err := stun.ParseURI(stunServer)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse STUN server %s: %w", stunServer, err)
 	}
 
-	client, err := stun.DialURI(u, &stun.DialConfig{})
+	var dialer net.Dialer
+	if local != nil {
+		dialer.LocalAddr = &net.UDPAddr{IP: local}
+	}
+	conn, err := dialer.Dial(stunNetwork(local), net.JoinHostPort(u.Host, strconv.Itoa(u.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to STUN server %s: %w", stunServer, err)
+	}
+
+	client, err := stun.NewClient(conn)
 	if err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("failed to connect to STUN server %s: %w", stunServer, err)
 	}
 	defer client.Close()
@@ -341,16 +711,52 @@ func getPublicIPFromSTUN(stunServer string) (net.IP, error) {
 	}
 
 	if xorAddr.IP == nil {
-		return nil, fmt.Errorf("no public IP address found in STUN response")
+		return nil, fmt.Errorf("STUN server %s: %w", stunServer, errNoPublicIP)
 	}
 
 	return xorAddr.IP, nil
 }
 
+// stunNetwork is the network to reach the STUN servers with, it's of the
+// family of the local ip so the servers are queried over v6 for v6 ips
+func stunNetwork(local net.IP) string {
+	switch {
+	case local == nil:
+		return "udp"
+	case local.To4() != nil:
+		return "udp4"
+	default:
+		return "udp6"
+	}
+}
+
+// ipv6Egress checks if the node has an ipv6 default route
+func ipv6Egress() bool {
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_V6)
+	if err != nil {
+		log.Err(err).Msg("failed to list ipv6 routes")
+		return false
+	}
+
+	for _, route := range routes {
+		if route.Gw == nil {
+			continue
+		}
+		if route.Dst == nil {
+			return true
+		}
+		if ones, _ := route.Dst.Mask.Size(); ones == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
 func deleteAllIPsAndRoutes(macvlan netlink.Link) error {
 	addresses, err := netlink.AddrList(macvlan, netlink.FAMILY_ALL)
 	if err != nil {
-		return fmt.Errorf("failed to list addresses in macvlan %s: %w", testMacvlan, err)
+		return fmt.Errorf("failed to list addresses in macvlan %s: %w", macvlan.Attrs().Name, err)
 	}
 	for _, addr := range addresses {
 		err = netlink.AddrDel(macvlan, &addr)
@@ -358,9 +764,14 @@ func deleteAllIPsAndRoutes(macvlan netlink.Link) error {
 			log.Err(err).Msgf("failed to delete address %s", addr)
 		}
 	}
-	routes, err := netlink.RouteList(macvlan, netlink.FAMILY_ALL)
+	// the worker routes are not in the main table
+	routes, err := netlink.RouteListFiltered(
+		netlink.FAMILY_ALL,
+		&netlink.Route{LinkIndex: macvlan.Attrs().Index, Table: unix.RT_TABLE_UNSPEC},
+		netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to list routes in macvlan %s: %w", testMacvlan, err)
+		return fmt.Errorf("failed to list routes in macvlan %s: %w", macvlan.Attrs().Name, err)
 	}
 	for _, route := range routes {
 		err = netlink.RouteDel(&route)
@@ -1,8 +1,13 @@
 package publicip
 
 import (
+	"fmt"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/stretchr/testify/assert"
@@ -43,6 +48,19 @@ func (m *MockMacvlanInterface) Install(link *netlink.Macvlan, hw net.HardwareAdd
 	return args.Error(0)
 }
 
+func (m *MockMacvlanInterface) Create(name string, master string, netns ns.NetNS) (*netlink.Macvlan, error) {
+	args := m.Called(name, master, netns)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*netlink.Macvlan), args.Error(1)
+}
+
+func (m *MockMacvlanInterface) Delete(name string, netns ns.NetNS) error {
+	args := m.Called(name, netns)
+	return args.Error(0)
+}
+
 func TestGetRealPublicIP_NetworkAccess(t *testing.T) {
 	ip, err := getRealPublicIP()
 	assert.NoError(t, err)
@@ -75,7 +93,7 @@ func TestGetPublicIPFromSTUN(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ip, err := getPublicIPFromSTUN(tt.stunServer)
+			ip, err := getPublicIPFromSTUN(tt.stunServer, nil)
 
 			if tt.expectErr {
 				assert.Error(t, err)
@@ -88,9 +106,13 @@ func TestGetPublicIPFromSTUN(t *testing.T) {
 }
 
 func TestValidateIPs(t *testing.T) {
-	pubIp, err := getRealPublicIP()
-	assert.NoError(t, err)
-	pubIpStr := pubIp.String() + "/24"
+	old := lookupPublicIP
+	lookupPublicIP = func(_ []string, local net.IP) (net.IP, error) {
+		return local, nil
+	}
+	t.Cleanup(func() { lookupPublicIP = old })
+
+	pubIpStr := "185.69.166.10/24"
 	tests := []struct {
 		name           string
 		publicIPs      []substrate.PublicIP
@@ -103,7 +125,7 @@ func TestValidateIPs(t *testing.T) {
 			publicIPs: []substrate.PublicIP{
 				{
 					IP:         pubIpStr,
-					Gateway:    "192.168.1.1",
+					Gateway:    "185.69.166.1",
 					ContractID: 0,
 				},
 			},
@@ -155,7 +177,7 @@ func TestValidateIPs(t *testing.T) {
 			publicIPs: []substrate.PublicIP{
 				{
 					IP:         "invalid-ip",
-					Gateway:    "192.168.1.1",
+					Gateway:    "185.69.166.1",
 					ContractID: 0,
 				},
 			},
@@ -181,7 +203,7 @@ func TestValidateIPs(t *testing.T) {
 			publicIPs: []substrate.PublicIP{
 				{
 					IP:         pubIpStr,
-					Gateway:    "192.168.1.1",
+					Gateway:    "185.69.166.1",
 					ContractID: 0,
 				},
 			},
@@ -208,7 +230,7 @@ func TestValidateIPs(t *testing.T) {
 			publicIPs: []substrate.PublicIP{
 				{
 					IP:         pubIpStr,
-					Gateway:    "192.168.1.1",
+					Gateway:    "185.69.166.1",
 					ContractID: 0,
 				},
 			},
@@ -232,7 +254,7 @@ func TestValidateIPs(t *testing.T) {
 				},
 				{
 					IP:         "invalid-ip",
-					Gateway:    "192.168.1.1",
+					Gateway:    "185.69.166.1",
 					ContractID: 0,
 				},
 			},
@@ -269,7 +291,7 @@ func TestValidateIPs(t *testing.T) {
 			tt.mockSetup(mockMacvlan)
 
 			task := &publicIPValidationTask{}
-			report, err := task.validateIPs(tt.publicIPs, mockMacvlan)
+			report, err := task.validateIPs(tt.publicIPs, nil, mockMacvlan)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -282,3 +304,255 @@ func TestValidateIPs(t *testing.T) {
 		})
 	}
 }
+
+func testWorker(name string, index int) *netlink.Macvlan {
+	return &netlink.Macvlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:  name,
+			Index: index,
+		},
+	}
+}
+
+func TestValidateIPsConcurrently(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight int
+		maxSeen  int
+	)
+	old := lookupPublicIP
+	lookupPublicIP = func(_ []string, local net.IP) (net.IP, error) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxSeen {
+			maxSeen = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return local, nil
+	}
+	t.Cleanup(func() { lookupPublicIP = old })
+
+	var publicIPs []substrate.PublicIP
+	expected := make(map[string]IPReport)
+	for i := 0; i < 10; i++ {
+		ip := fmt.Sprintf("185.69.166.%d/24", i+10)
+		publicIPs = append(publicIPs, substrate.PublicIP{IP: ip, Gateway: "185.69.166.1"})
+		expected[ip] = IPReport{State: ValidState}
+	}
+
+	mockMacvlan := new(MockMacvlanInterface)
+	mockMacvlan.On("GetByName", testMacvlan).Return(testWorker(testMacvlan, 1), nil)
+	for i := 1; i < maxWorkers; i++ {
+		name := fmt.Sprintf("%s%d", testMacvlan, i)
+		mockMacvlan.On("Create", name, mock.Anything, mock.Anything).Return(testWorker(name, i+1), nil).Once()
+		mockMacvlan.On("Delete", name, mock.Anything).Return(nil).Once()
+	}
+	mockMacvlan.On("Install", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	task := &publicIPValidationTask{}
+	report, err := task.validateIPs(publicIPs, nil, mockMacvlan)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, report)
+	assert.Greater(t, maxSeen, 1)
+	assert.LessOrEqual(t, maxSeen, maxWorkers)
+	mockMacvlan.AssertExpectations(t)
+}
+
+func TestValidateIPsWorkerCreateFailed(t *testing.T) {
+	old := lookupPublicIP
+	lookupPublicIP = func(_ []string, local net.IP) (net.IP, error) {
+		return local, nil
+	}
+	t.Cleanup(func() { lookupPublicIP = old })
+
+	publicIPs := []substrate.PublicIP{
+		{IP: "185.69.166.10/24", Gateway: "185.69.166.1"},
+		{IP: "185.69.166.11/24", Gateway: "185.69.166.1"},
+		{IP: "185.69.166.12/24", Gateway: "185.69.166.1"},
+	}
+
+	mockMacvlan := new(MockMacvlanInterface)
+	mockMacvlan.On("GetByName", testMacvlan).Return(testWorker(testMacvlan, 1), nil)
+	mockMacvlan.On("Create", "pub1", mock.Anything, mock.Anything).Return(testWorker("pub1", 2), nil)
+	mockMacvlan.On("Create", "pub2", mock.Anything, mock.Anything).Return(nil, assert.AnError)
+	mockMacvlan.On("Install", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	// only the created worker is deleted
+	mockMacvlan.On("Delete", "pub1", mock.Anything).Return(nil).Once()
+
+	task := &publicIPValidationTask{}
+	report, err := task.validateIPs(publicIPs, nil, mockMacvlan)
+	assert.NoError(t, err)
+	assert.Len(t, report, len(publicIPs))
+	for _, publicIP := range publicIPs {
+		assert.Equal(t, IPReport{State: ValidState}, report[publicIP.IP])
+	}
+	mockMacvlan.AssertExpectations(t)
+}
+
+func TestIsWorkerName(t *testing.T) {
+	assert.True(t, isWorkerName("pub1"))
+	assert.True(t, isWorkerName("pub12"))
+	assert.False(t, isWorkerName(testMacvlan))
+	assert.False(t, isWorkerName("public"))
+	assert.False(t, isWorkerName("pub-1"))
+	assert.False(t, isWorkerName("eth0"))
+}
+
+func TestGetIPWithRouteIPv6(t *testing.T) {
+	ip, ipNet, routes, err := getIPWithRoute(substrate.PublicIP{
+		IP:      "2a10:b600:1::10/64",
+		Gateway: "2a10:b600:1::1",
+	})
+	assert.NoError(t, err)
+	assert.True(t, ip.Equal(net.ParseIP("2a10:b600:1::10")))
+	assert.Equal(t, "2a10:b600:1::10/64", ipNet[0].String())
+	assert.Len(t, routes, 1)
+	assert.Equal(t, "::/0", routes[0].Dst.String())
+	assert.True(t, routes[0].Gw.Equal(net.ParseIP("2a10:b600:1::1")))
+
+	_, _, routes, err = getIPWithRoute(substrate.PublicIP{
+		IP:      "185.69.166.10/24",
+		Gateway: "185.69.166.1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0.0.0/0", routes[0].Dst.String())
+
+	// the gateway must be of the same family
+	_, _, _, err = getIPWithRoute(substrate.PublicIP{
+		IP:      "2a10:b600:1::10/64",
+		Gateway: "185.69.166.1",
+	})
+	assert.Error(t, err)
+}
+
+func TestIPFamilySelection(t *testing.T) {
+	assert.Equal(t, "udp", stunNetwork(nil))
+	assert.Equal(t, "udp4", stunNetwork(net.ParseIP("185.69.166.10")))
+	assert.Equal(t, "udp6", stunNetwork(net.ParseIP("2a10:b600:1::10")))
+
+	assert.Equal(t, "185.69.166.10/32", sourceRule(net.ParseIP("185.69.166.10"), workerTableBase).Src.String())
+	assert.Equal(t, "2a10:b600:1::10/128", sourceRule(net.ParseIP("2a10:b600:1::10"), workerTableBase).Src.String())
+}
+
+func TestValidateIPsIPv6Egress(t *testing.T) {
+	old := lookupPublicIP
+	lookupPublicIP = func(_ []string, local net.IP) (net.IP, error) {
+		return local, nil
+	}
+	t.Cleanup(func() { lookupPublicIP = old })
+
+	publicIPs := []substrate.PublicIP{
+		{IP: "185.69.166.10/24", Gateway: "185.69.166.1"},
+		{IP: "2a10:b600:1::10/64", Gateway: "2a10:b600:1::1"},
+	}
+
+	for _, egress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("egress=%t", egress), func(t *testing.T) {
+			oldEgress := hasIPv6Egress
+			hasIPv6Egress = func() bool { return egress }
+			t.Cleanup(func() { hasIPv6Egress = oldEgress })
+
+			mockMacvlan := new(MockMacvlanInterface)
+			mockMacvlan.On("GetByName", testMacvlan).Return(testWorker(testMacvlan, 1), nil)
+			mockMacvlan.On("Create", "pub1", mock.Anything, mock.Anything).Return(testWorker("pub1", 2), nil).Maybe()
+			mockMacvlan.On("Delete", "pub1", mock.Anything).Return(nil).Maybe()
+			mockMacvlan.On("Install", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			task := &publicIPValidationTask{}
+			report, err := task.validateIPs(publicIPs, nil, mockMacvlan)
+			assert.NoError(t, err)
+			assert.Equal(t, IPReport{State: ValidState}, report["185.69.166.10/24"])
+
+			expected := IPReport{State: SkippedState, Reason: NoIPv6Egress}
+			if egress {
+				expected = IPReport{State: ValidState}
+			}
+			assert.Equal(t, expected, report["2a10:b600:1::10/64"])
+			mockMacvlan.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWithStunServers(t *testing.T) {
+	task := NewTask(WithStunServers(
+		"stun:stun.example.com:3478",
+		"https://ip.example.com",
+		"not a server",
+	)).(*publicIPValidationTask)
+	assert.Equal(t, []string{"stun:stun.example.com:3478", "https://ip.example.com"}, task.servers)
+
+	task = NewTask().(*publicIPValidationTask)
+	assert.Empty(t, task.servers)
+}
+
+func TestValidateIPsConfiguredServers(t *testing.T) {
+	// the farm ip is the loopback so the lookup can be bound to it
+	publicIPs := []substrate.PublicIP{
+		{IP: "127.0.0.1/8", Gateway: "127.0.0.2"},
+	}
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected IPReport
+	}{
+		{
+			name: "configured server answers with the ip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				host, _, _ := net.SplitHostPort(r.RemoteAddr)
+				fmt.Fprintln(w, host)
+			},
+			expected: IPReport{State: ValidState},
+		},
+		{
+			name: "configured server answers with another ip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, "185.69.166.10")
+			},
+			expected: IPReport{State: InvalidState, Reason: IPsNotMatching},
+		},
+		{
+			name: "configured server answers with no ip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, "")
+			},
+			expected: IPReport{State: SkippedState, Reason: FetchRealIPFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			mockMacvlan := new(MockMacvlanInterface)
+			mockMacvlan.On("GetByName", testMacvlan).Return(testWorker(testMacvlan, 1), nil)
+			mockMacvlan.On("Install", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			task := NewTask(WithStunServers(server.URL)).(*publicIPValidationTask)
+			report, err := task.validateIPs(publicIPs, nil, mockMacvlan)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, report["127.0.0.1/8"])
+		})
+	}
+}
+
+func TestValidateIPsServersUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	mockMacvlan := new(MockMacvlanInterface)
+	mockMacvlan.On("GetByName", testMacvlan).Return(testWorker(testMacvlan, 1), nil)
+	mockMacvlan.On("Install", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	task := NewTask(WithStunServers(server.URL)).(*publicIPValidationTask)
+	report, err := task.validateIPs([]substrate.PublicIP{{IP: "127.0.0.1/8", Gateway: "127.0.0.2"}}, nil, mockMacvlan)
+	assert.NoError(t, err)
+	assert.Equal(t, IPReport{State: InvalidState, Reason: PublicIPDataInvalid}, report["127.0.0.1/8"])
+}
@@ -5,6 +5,11 @@ package pkg
 type PerformanceMonitor interface {
 	Get(taskName string) (TaskResult, error)
 	GetAll() ([]TaskResult, error)
+	// Metrics returns the latest results in prometheus text exposition format
+	Metrics() (string, error)
+	// Elections returns, for each farm-wide responsibility, which node this
+	// node believes is currently responsible for it
+	Elections() ([]Election, error)
 }
 
 // TaskResult the result test schema
@@ -14,3 +19,19 @@ type TaskResult struct {
 	Timestamp   uint64      `json:"timestamp"`
 	Result      interface{} `json:"result"`
 }
+
+// Election is the last decision of a node about which node of the farm
+// runs a farm-wide responsibility
+type Election struct {
+	// Responsibility is the id of the task that runs farm-wide
+	Responsibility string `json:"responsibility"`
+	// NodeID is the id of this node
+	NodeID uint32 `json:"node_id"`
+	// Elected is the id of the node that is believed to be responsible
+	Elected uint32 `json:"elected"`
+	// Responsible is set if this node is the elected one
+	Responsible bool `json:"responsible"`
+	// Reason explains why the node was elected
+	Reason    string `json:"reason"`
+	Timestamp uint64 `json:"timestamp"`
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -17,9 +18,30 @@ import (
 	"github.com/threefoldtech/zosbase/pkg/zinit"
 )
 
+// Machines is the part of the vm module used to shut down the vms before
+// the node is powered off
+type Machines interface {
+	List(ctx context.Context) ([]string, error)
+	Shutdown(ctx context.Context, name string, timeout time.Duration) error
+}
+
+var _ Machines = (*stubs.VMModuleStub)(nil)
+
+// Option configures the power server
+type Option func(*PowerServer)
+
+// WithMachines makes the power server shut down all running vms with acpi
+// before the node is powered off, so the guests don't lose data
+func WithMachines(machines Machines) Option {
+	return func(p *PowerServer) {
+		p.machines = machines
+	}
+}
+
 type PowerServer struct {
 	consumer         *events.RedisConsumer
 	substrateGateway *stubs.SubstrateGatewayStub
+	machines         Machines
 
 	// enabled means the node can power off!
 	enabled bool
@@ -36,9 +58,10 @@ func NewPowerServer(
 	farm pkg.FarmID,
 	node uint32,
 	twin uint32,
-	ut *Uptime) (*PowerServer, error) {
+	ut *Uptime,
+	opts ...Option) (*PowerServer, error) {
 
-	return &PowerServer{
+	p := &PowerServer{
 		substrateGateway: substrateGateway,
 		consumer:         consumer,
 		enabled:          enabled,
@@ -46,12 +69,22 @@ func NewPowerServer(
 		node:             node,
 		twin:             twin,
 		ut:               ut,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p, nil
 }
 
 const (
 	DefaultWolBridge = "zos"
 	PowerServerPort  = 8039
+
+	// vmShutdownTimeout is how long the vms are given to power off before
+	// the node is shut down
+	vmShutdownTimeout = 30 * time.Second
 )
 
 func EnsureWakeOnLan(ctx context.Context) (bool, error) {
@@ -166,6 +199,8 @@ func (p *PowerServer) shutdown() error {
 		log.Error().Err(err).Msg("failed to send uptime before shutting down")
 	}
 
+	p.shutdownMachines(context.Background())
+
 	// is down!
 	init := zinit.Default()
 	err := init.Shutdown()
@@ -178,6 +213,52 @@ func (p *PowerServer) shutdown() error {
 	return err
 }
 
+// shutdownMachines shuts down all running vms in parallel, vms that don't
+// power off in time are killed with the node
+func (p *PowerServer) shutdownMachines(ctx context.Context) {
+	if p.machines == nil {
+		return
+	}
+
+	// zbus stubs panic if the vm module is not reachable, this must never
+	// block the node shutdown
+	safe := func(f func() error) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("vm module not reachable: %v", r)
+			}
+		}()
+		return f()
+	}
+
+	var names []string
+	err := safe(func() (err error) {
+		names, err = p.machines.List(ctx)
+		return err
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list vms to shutdown")
+		return
+	}
+
+	log.Info().Int("vms", len(names)).Msg("shutting down vms before the node")
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			err := safe(func() error {
+				return p.machines.Shutdown(ctx, name, vmShutdownTimeout)
+			})
+			if err != nil {
+				log.Warn().Err(err).Str("vm-id", name).Msg("vm did not shutdown gracefully")
+			}
+		}(name)
+	}
+
+	wg.Wait()
+}
+
 func (p *PowerServer) event(event *pkg.PowerTargetChangeEvent) error {
 	if event.FarmID != p.farm {
 		return nil
@@ -0,0 +1,62 @@
+package power
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMachines struct {
+	m        sync.Mutex
+	names    []string
+	down     []string
+	listErr  error
+	panicked bool
+}
+
+func (f *fakeMachines) List(ctx context.Context) ([]string, error) {
+	return f.names, f.listErr
+}
+
+func (f *fakeMachines) Shutdown(ctx context.Context, name string, timeout time.Duration) error {
+	if f.panicked {
+		panic("vmd is not running")
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.down = append(f.down, name)
+	if name == "stuck" {
+		return fmt.Errorf("timeout waiting for machine to shutdown")
+	}
+	return nil
+}
+
+func TestShutdownMachines(t *testing.T) {
+	machines := &fakeMachines{names: []string{"vm1", "stuck", "vm2"}}
+	p, err := NewPowerServer(nil, nil, true, 1, 1, 1, nil, WithMachines(machines))
+	require.NoError(t, err)
+
+	p.shutdownMachines(context.Background())
+	sort.Strings(machines.down)
+	require.Equal(t, []string{"stuck", "vm1", "vm2"}, machines.down)
+
+	// the node shutdown is never blocked by the vm module
+	machines = &fakeMachines{names: []string{"vm1"}, panicked: true}
+	p.machines = machines
+	p.shutdownMachines(context.Background())
+
+	machines = &fakeMachines{listErr: fmt.Errorf("failed to list")}
+	p.machines = machines
+	p.shutdownMachines(context.Background())
+	require.Empty(t, machines.down)
+
+	// nothing to do without the vm module
+	p.machines = nil
+	p.shutdownMachines(context.Background())
+}
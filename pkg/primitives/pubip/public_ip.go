@@ -19,7 +19,8 @@ import (
 )
 
 var (
-	_ provision.Manager = (*Manager)(nil)
+	_ provision.Manager  = (*Manager)(nil)
+	_ provision.Resyncer = (*Manager)(nil)
 )
 
 type Manager struct {
@@ -193,6 +194,27 @@ func (p *Manager) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID)
 	return network.DisconnectPubTap(ctx, tapName)
 }
 
+// Resync rebuilds the firewall rules of the public ip from the workload
+// result, it's used to recover the rules if they were changed from outside
+func (p *Manager) Resync(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	result, err := GetPubIPConfig(wl)
+	if err != nil {
+		return err
+	}
+
+	network := stubs.NewNetworkerStub(p.zbus)
+	tapName := wl.ID.Unique("pub")
+	fName := filterName(tapName)
+
+	mac := ifaceutil.HardwareAddrFromInputBytes([]byte(tapName))
+	ifName := fmt.Sprintf("p-%s", tapName)
+	if err := network.ResyncPubIPFilter(ctx, fName, ifName, result.IP.IP, result.IPv6.IP, mac.String()); err != nil {
+		return errors.Wrap(err, "failed to resync filter rules")
+	}
+
+	return nil
+}
+
 func filterName(reservationID string) string {
 	return fmt.Sprintf("r-%s", reservationID)
 }
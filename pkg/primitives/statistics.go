@@ -2,7 +2,6 @@ package primitives
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os/exec"
 	"strconv"
@@ -15,8 +14,8 @@ import (
 	"github.com/threefoldtech/zosbase/pkg"
 	"github.com/threefoldtech/zosbase/pkg/capacity"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes"
-	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
 	"github.com/threefoldtech/zosbase/pkg/kernel"
+	"github.com/threefoldtech/zosbase/pkg/primitives/vmgpu"
 	"github.com/threefoldtech/zosbase/pkg/provision"
 )
 
@@ -75,6 +74,8 @@ func NewStatistics(total gridtypes.Capacity, storage provision.Storage, reserved
 type activeCounters struct {
 	// used capacity from storage + reserved
 	cap gridtypes.Capacity
+	// pending capacity of the workloads not provisioned yet
+	pending gridtypes.Capacity
 	// Total deployments count
 	deployments int
 	// Total workloads count
@@ -97,6 +98,7 @@ func (s *Statistics) active(exclude ...provision.Exclude) (activeCounters, error
 
 	return activeCounters{
 		storageCap.Cap,
+		storageCap.Pending,
 		len(storageCap.Deployments),
 		storageCap.Workloads,
 		storageCap.LastDeploymentTimestamp,
@@ -249,6 +251,10 @@ func (s *statsStream) GetCounters() (pkg.Counters, error) {
 		return pkg.Counters{}, err
 	}
 
+	return s.counters(activeCounters)
+}
+
+func (s *statsStream) counters(activeCounters activeCounters) (pkg.Counters, error) {
 	reserved, err := s.stats.reserved()
 	if err != nil {
 		return pkg.Counters{}, err
@@ -271,34 +277,46 @@ func (s *statsStream) GetCounters() (pkg.Counters, error) {
 	}, nil
 }
 
-func (s *statsStream) ListGPUs() ([]pkg.GPUInfo, error) {
-	usedGpus := func() (map[string]uint64, error) {
-		gpus := make(map[string]uint64)
-		active, err := s.stats.storage.Capacity()
-		if err != nil {
-			return nil, err
-		}
-		type vmType struct {
-			GPU []zos.GPU `json:"gpu,omitempty"`
-		}
-		for _, dl := range active.Deployments {
-			for _, wl := range dl.Workloads {
-
-				if wl.Type == zos.ZMachineType || wl.Type == zos.ZMachineLightType {
-					var vm vmType
-					if err := json.Unmarshal(wl.Data, &vm); err != nil {
-						return nil, errors.Wrapf(err, "invalid workload data (%d.%s)", dl.ContractID, wl.Name)
-					}
-
-					for _, gpu := range vm.GPU {
-						gpus[string(gpu)] = dl.ContractID
-					}
-				}
+func (s *statsStream) GetCapacitySummary() (pkg.CapacitySummary, error) {
+	// the pending capacity is collected in the same storage pass as the
+	// used capacity
+	activeCounters, err := s.stats.active()
+	if err != nil {
+		return pkg.CapacitySummary{}, err
+	}
 
-			}
+	counters, err := s.counters(activeCounters)
+	if err != nil {
+		return pkg.CapacitySummary{}, err
+	}
+
+	return pkg.CapacitySummary{
+		Counters: counters,
+		Pending:  activeCounters.pending,
+		Free:     freeCapacity(counters.Total, counters.Used, activeCounters.pending),
+	}, nil
+}
+
+// freeCapacity is the total capacity minus the used and pending capacity,
+// a resource that is over used has no free capacity
+func freeCapacity(total, used, pending gridtypes.Capacity) gridtypes.Capacity {
+	sub := func(total, used, pending uint64) uint64 {
+		if used+pending >= total {
+			return 0
 		}
-		return gpus, nil
+		return total - used - pending
+	}
+
+	return gridtypes.Capacity{
+		CRU:   sub(total.CRU, used.CRU, pending.CRU),
+		SRU:   gridtypes.Unit(sub(uint64(total.SRU), uint64(used.SRU), uint64(pending.SRU))),
+		HRU:   gridtypes.Unit(sub(uint64(total.HRU), uint64(used.HRU), uint64(pending.HRU))),
+		MRU:   gridtypes.Unit(sub(uint64(total.MRU), uint64(used.MRU), uint64(pending.MRU))),
+		IPV4U: sub(total.IPV4U, used.IPV4U, pending.IPV4U),
 	}
+}
+
+func (s *statsStream) ListGPUs() ([]pkg.GPUInfo, error) {
 	var list []pkg.GPUInfo
 	if kernel.GetParams().IsGPUDisabled() {
 		return list, nil
@@ -308,11 +326,16 @@ func (s *statsStream) ListGPUs() ([]pkg.GPUInfo, error) {
 		return nil, errors.Wrap(err, "failed to list available devices")
 	}
 
-	used, err := usedGpus()
+	used, err := vmgpu.Allocated(s.stats.storage)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list used gpus")
 	}
 
+	drained, err := vmgpu.Drained()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list drained gpus")
+	}
+
 	for _, pciDevice := range devices {
 		id := pciDevice.ShortID()
 		gpu, _ := capacity.GetGpuDevice(&pciDevice)
@@ -321,9 +344,18 @@ func (s *statsStream) ListGPUs() ([]pkg.GPUInfo, error) {
 			Vendor:   "unknown",
 			Device:   "unknown",
 			Vram:     gpu.Vram,
-			Contract: used[id],
+			Contract: used[id].Contract,
+		}
+
+		if drain, ok := drained[id]; ok {
+			info.Drained = true
+			info.DrainReason = drain.Reason
 		}
 
+		info.Status = gpuStatus(info.Contract, info.Drained)
+
+		info.Utilization, info.VramUsed = capacity.GPUUsage(&pciDevice)
+
 		vendor, device, ok := pciDevice.GetDevice()
 		if ok {
 			info.Vendor = vendor.Name
@@ -341,6 +373,27 @@ func (s *statsStream) ListGPUs() ([]pkg.GPUInfo, error) {
 	return list, nil
 }
 
+// gpuStatus is the allocation status of a gpu, a drained gpu that is still
+// used by a workload is reported as allocated until the workload is gone
+func gpuStatus(contract uint64, drained bool) pkg.GPUStatus {
+	switch {
+	case contract != 0:
+		return pkg.GPUAllocated
+	case drained:
+		return pkg.GPUDrained
+	default:
+		return pkg.GPUAvailable
+	}
+}
+
+func (s *statsStream) DrainGPU(id string, reason string) error {
+	return vmgpu.Drain(id, reason)
+}
+
+func (s *statsStream) UndrainGPU(id string) error {
+	return vmgpu.Undrain(id)
+}
+
 func (s *statsStream) openConnectionsCount() (int, error) {
 	cmd := exec.Command("/bin/sh", "-c", "ss -tnH state established | wc -l")
 	out, err := cmd.Output()
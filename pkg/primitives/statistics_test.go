@@ -0,0 +1,79 @@
+package primitives
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zosbase/pkg/provision/storage"
+)
+
+func diskDeployment(t *testing.T, contract uint64, size gridtypes.Unit) gridtypes.Deployment {
+	data, err := json.Marshal(zos.ZMount{Size: size})
+	require.NoError(t, err)
+
+	return gridtypes.Deployment{
+		Version:    0,
+		TwinID:     1,
+		ContractID: contract,
+		Workloads: []gridtypes.Workload{
+			{Version: 0, Name: "disk", Type: zos.ZMountType, Data: data},
+		},
+	}
+}
+
+func TestStatisticsPending(t *testing.T) {
+	db, err := storage.New(filepath.Join(t.TempDir(), "storage.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	// provisioned deployment
+	ok := diskDeployment(t, 1, 10*gridtypes.Gigabyte)
+	require.NoError(t, db.Create(ok))
+	wl := ok.Workloads[0]
+	wl.Result = gridtypes.Result{State: gridtypes.StateOk, Created: gridtypes.Now()}
+	require.NoError(t, db.Transaction(1, 1, wl))
+
+	// accepted but not provisioned yet
+	require.NoError(t, db.Create(diskDeployment(t, 2, 20*gridtypes.Gigabyte)))
+
+	total := gridtypes.Capacity{CRU: 4, MRU: 8 * gridtypes.Gigabyte, SRU: 100 * gridtypes.Gigabyte}
+	stats := NewStatistics(total, db, nil, nil)
+
+	active, err := stats.active()
+	require.NoError(t, err)
+	require.Equal(t, 10*gridtypes.Gigabyte, active.cap.SRU)
+	require.Equal(t, 20*gridtypes.Gigabyte, active.pending.SRU)
+	require.Equal(t, 1, active.deployments)
+	require.Equal(t, 1, active.workloads)
+
+	summary, err := NewStatisticsStream(stats).GetCapacitySummary()
+	require.NoError(t, err)
+	require.Equal(t, 10*gridtypes.Gigabyte, summary.Used.SRU)
+	require.Equal(t, 20*gridtypes.Gigabyte, summary.Pending.SRU)
+	require.Equal(t, 70*gridtypes.Gigabyte, summary.Free.SRU)
+	require.Equal(t, 8*gridtypes.Gigabyte, summary.Free.MRU)
+}
+
+func TestFreeCapacity(t *testing.T) {
+	total := gridtypes.Capacity{CRU: 4, MRU: 8 * gridtypes.Gigabyte, SRU: 100 * gridtypes.Gigabyte, IPV4U: 2}
+	used := gridtypes.Capacity{CRU: 2, MRU: 6 * gridtypes.Gigabyte, SRU: 10 * gridtypes.Gigabyte}
+	pending := gridtypes.Capacity{CRU: 1, MRU: 4 * gridtypes.Gigabyte, SRU: 90 * gridtypes.Gigabyte, IPV4U: 1}
+
+	// memory is overcommitted and storage is fully used, both have no free
+	// capacity left
+	require.Equal(t, gridtypes.Capacity{CRU: 1, IPV4U: 1}, freeCapacity(total, used, pending))
+	require.Equal(t, total, freeCapacity(total, gridtypes.Capacity{}, gridtypes.Capacity{}))
+}
+
+func TestGPUStatus(t *testing.T) {
+	require.Equal(t, pkg.GPUAvailable, gpuStatus(0, false))
+	require.Equal(t, pkg.GPUAllocated, gpuStatus(10, false))
+	require.Equal(t, pkg.GPUDrained, gpuStatus(0, true))
+	// still in use, the drain only blocks new allocations
+	require.Equal(t, pkg.GPUAllocated, gpuStatus(10, true))
+}
@@ -172,6 +172,83 @@ func (p *Manager) prepContainer(
 	return nil
 }
 
+// validInterfaces checks the private interfaces of a vm, each interface must
+// join a different network since the tap devices are named after the network
+func validInterfaces(interfaces []zos.MachineInterface) error {
+	if len(interfaces) == 0 {
+		return fmt.Errorf("at least one private network is required")
+	}
+
+	seen := make(map[gridtypes.Name]struct{})
+	for _, inf := range interfaces {
+		if _, ok := seen[inf.Network]; ok {
+			return fmt.Errorf("network '%s' is used by more than one interface", inf.Network)
+		}
+		seen[inf.Network] = struct{}{}
+	}
+
+	return nil
+}
+
+// networkTaps returns the ids of the tap devices of the vm networks as used
+// to attach and detach them. The mycelium tap uses the id of its network, so it's
+// only added if it's not on one of the private networks.
+func networkTaps(wl *gridtypes.WorkloadWithID, config *ZMachine) []string {
+	var taps []string
+	seen := make(map[string]struct{})
+	add := func(network gridtypes.Name) {
+		tap := wl.ID.Unique(string(network))
+		if _, ok := seen[tap]; ok {
+			return
+		}
+		seen[tap] = struct{}{}
+		taps = append(taps, tap)
+	}
+
+	for _, inf := range config.Network.Interfaces {
+		add(inf.Network)
+	}
+
+	if config.Network.Mycelium != nil {
+		add(config.Network.Mycelium.Network)
+	}
+
+	return taps
+}
+
+// defaultNameservers of a vm if not set in its network config
+var defaultNameservers = []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("1.1.1.1"), net.ParseIP("2001:4860:4860::8888")}
+
+// attachNetworks attaches the vm to all its private networks, and mycelium if
+// set, in order
+func (p *Manager) attachNetworks(ctx context.Context, dl gridtypes.Deployment, wl *gridtypes.WorkloadWithID, config *ZMachine) (pkg.VMNetworkInfo, error) {
+	networkInfo := pkg.VMNetworkInfo{
+		Nameservers: defaultNameservers,
+	}
+
+	if len(config.Network.Nameservers) != 0 {
+		networkInfo.Nameservers = config.Network.Nameservers
+	}
+
+	for _, nic := range config.Network.Interfaces {
+		inf, err := p.newPrivNetworkInterface(ctx, dl, wl, nic)
+		if err != nil {
+			return networkInfo, errors.Wrapf(err, "failed to attach network '%s'", nic.Network)
+		}
+		networkInfo.Ifaces = append(networkInfo.Ifaces, inf)
+	}
+
+	if config.Network.Mycelium != nil {
+		inf, err := p.newMyceliumNetworkInterface(ctx, dl, wl, config.Network.Mycelium)
+		if err != nil {
+			return networkInfo, err
+		}
+		networkInfo.Ifaces = append(networkInfo.Ifaces, inf)
+	}
+
+	return networkInfo, nil
+}
+
 func (p *Manager) newMyceliumNetworkInterface(ctx context.Context, dl gridtypes.Deployment, wl *gridtypes.WorkloadWithID, config *zos.MyceliumIP) (pkg.VMIface, error) {
 	network := stubs.NewNetworkerLightStub(p.zbus)
 	netID := zos.NetworkID(dl.TwinID, config.Network)
@@ -5,10 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/cenkalti/backoff/v3"
+	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/threefoldtech/zbus"
@@ -23,6 +28,18 @@ import (
 
 const (
 	cloudContainerName = "cloud-container"
+
+	defaultCleanupAttempts = 5
+	defaultCleanupInterval = 2 * time.Second
+	// defaultJanitorInterval is how often the rootfs of the workloads that
+	// need cleanup is cleaned up again
+	defaultJanitorInterval = 10 * time.Minute
+	// defaultCleanupFile is where the workloads that need cleanup are persisted
+	defaultCleanupFile = "/var/cache/modules/provisiond/vm-light-cleanup.json"
+
+	// shutdownTimeout is how long the guest is given to power off on
+	// deprovision or restart before the vm is killed
+	shutdownTimeout = 30 * time.Second
 )
 
 // ZMachine type
@@ -31,20 +48,238 @@ type ZMachine = zos.ZMachineLight
 var (
 	_ provision.Manager     = (*Manager)(nil)
 	_ provision.Initializer = (*Manager)(nil)
+	_ provision.Restartable = (*Manager)(nil)
 )
 
 type Manager struct {
 	zbus zbus.Client
+
+	cleanupAttempts uint64
+	cleanupInterval time.Duration
+	janitorInterval time.Duration
+
+	// workloads that failed to clean up their rootfs mount or volume, they
+	// are persisted in cleanupFile
+	needsCleanup  map[string]struct{}
+	needsCleanupM sync.Mutex
+	cleanupFile   string
+}
+
+// Option is a vm-light manager option
+type Option func(*Manager)
+
+// WithCleanupRetry sets how many times, and how often, cleaning up the vm
+// rootfs mount and volume is retried on deprovision before giving up.
+func WithCleanupRetry(attempts uint64, interval time.Duration) Option {
+	return func(m *Manager) {
+		m.cleanupAttempts = attempts
+		m.cleanupInterval = interval
+	}
+}
+
+// WithJanitorInterval sets how often the rootfs cleanup of the workloads that
+// need cleanup is attempted again
+func WithJanitorInterval(interval time.Duration) Option {
+	return func(m *Manager) {
+		m.janitorInterval = interval
+	}
+}
+
+// WithCleanupFile sets where the workloads that need cleanup are persisted
+func WithCleanupFile(path string) Option {
+	return func(m *Manager) {
+		m.cleanupFile = path
+	}
+}
+
+func NewManager(zbus zbus.Client, opts ...Option) *Manager {
+	m := &Manager{
+		zbus:            zbus,
+		cleanupAttempts: defaultCleanupAttempts,
+		cleanupInterval: defaultCleanupInterval,
+		janitorInterval: defaultJanitorInterval,
+		needsCleanup:    make(map[string]struct{}),
+		cleanupFile:     defaultCleanupFile,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if err := m.loadCleanup(); err != nil {
+		log.Error().Err(err).Str("file", m.cleanupFile).Msg("failed to load vms that need cleanup")
+	}
+
+	return m
+}
+
+// NeedsCleanup returns the ids of the workloads that were deprovisioned but
+// their rootfs mount or volume could not be removed.
+func (m *Manager) NeedsCleanup() []string {
+	m.needsCleanupM.Lock()
+	defer m.needsCleanupM.Unlock()
+
+	ids := make([]string, 0, len(m.needsCleanup))
+	for id := range m.needsCleanup {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func (m *Manager) markCleanup(id string, needed bool) {
+	m.needsCleanupM.Lock()
+	defer m.needsCleanupM.Unlock()
+
+	_, marked := m.needsCleanup[id]
+	if needed == marked {
+		return
+	}
+
+	if needed {
+		m.needsCleanup[id] = struct{}{}
+	} else {
+		delete(m.needsCleanup, id)
+	}
+
+	if err := m.saveCleanup(); err != nil {
+		log.Error().Err(err).Str("file", m.cleanupFile).Msg("failed to save vms that need cleanup")
+	}
+}
+
+func (m *Manager) loadCleanup() error {
+	data, err := os.ReadFile(m.cleanupFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to read cleanup file")
+	}
+
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return errors.Wrap(err, "failed to decode cleanup file")
+	}
+
+	for _, id := range ids {
+		m.needsCleanup[id] = struct{}{}
+	}
+
+	return nil
+}
+
+func (m *Manager) saveCleanup() error {
+	ids := make([]string, 0, len(m.needsCleanup))
+	for id := range m.needsCleanup {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode cleanup file")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.cleanupFile), 0755); err != nil {
+		return errors.Wrap(err, "failed to create cleanup file directory")
+	}
+
+	tmp := m.cleanupFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to write cleanup file")
+	}
+
+	return errors.Wrap(os.Rename(tmp, m.cleanupFile), "failed to write cleanup file")
+}
+
+// retryCleanup retries a cleanup operation to survive transient errors of
+// the daemons, for example if they are busy
+func (m *Manager) retryCleanup(ctx context.Context, op func() error) error {
+	bo := backoff.WithContext(
+		backoff.WithMaxRetries(backoff.NewConstantBackOff(m.cleanupInterval), m.cleanupAttempts),
+		ctx,
+	)
+
+	// the stubs panic if the daemon can't be reached, which is one of the
+	// errors the retry is for
+	return backoff.Retry(func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%v", r)
+			}
+		}()
+
+		return op()
+	}, bo)
+}
+
+// cleanupRootfs unmounts the vm flist and deletes its rootfs volume, it
+// reports if any of them could not be removed
+func (m *Manager) cleanupRootfs(ctx context.Context, id string) (leaked bool) {
+	var (
+		flist   = stubs.NewFlisterStub(m.zbus)
+		storage = stubs.NewStorageModuleStub(m.zbus)
+	)
+
+	if err := m.retryCleanup(ctx, func() error {
+		return flist.Unmount(ctx, id)
+	}); err != nil {
+		log.Error().Err(err).Str("vm-id", id).Msg("failed to unmount machine flist")
+		leaked = true
+	}
+
+	volName := fmt.Sprintf("rootfs:%s", id)
+	if err := m.retryCleanup(ctx, func() error {
+		return storage.VolumeDelete(ctx, volName)
+	}); err != nil {
+		log.Error().Err(err).Str("name", volName).Msg("failed to delete rootfs volume")
+		leaked = true
+	}
+
+	return leaked
 }
 
-func NewManager(zbus zbus.Client) *Manager {
-	return &Manager{zbus}
+// drainCleanup cleans up the rootfs of the workloads that need cleanup, the
+// ones that are cleaned up are unmarked.
+func (m *Manager) drainCleanup(ctx context.Context) {
+	for _, id := range m.NeedsCleanup() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		m.markCleanup(id, m.cleanupRootfs(ctx, id))
+	}
+}
+
+// janitor drains the workloads that need cleanup every janitor interval
+// until the context is cancelled
+func (m *Manager) janitor(ctx context.Context) {
+	ticker := time.NewTicker(m.janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.drainCleanup(ctx)
+		}
+	}
 }
 
 func (m *Manager) Initialize(ctx context.Context) error {
+	go m.janitor(ctx)
+
 	return vmgpu.InitGPUs()
 }
 
+// Restart implements provision.Restartable. The machine is restarted in place
+// by vmd, its rootfs, disks and network are kept.
+func (m *Manager) Restart(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	vm := stubs.NewVMModuleStub(m.zbus)
+	return vm.Restart(ctx, wl.ID.String(), shutdownTimeout)
+}
+
 func (p *Manager) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (interface{}, error) {
 	return p.virtualMachineProvisionImpl(ctx, wl)
 }
@@ -144,10 +379,25 @@ func (p *Manager) virtualMachineProvisionImpl(ctx context.Context, wl *gridtypes
 		Name:       wl.ID.String(),
 		CPU:        config.ComputeCapacity.CPU,
 		Memory:     config.ComputeCapacity.Memory,
+		MaxCPU:     config.ComputeCapacity.MaxCPU,
+		MaxMemory:  config.ComputeCapacity.MaxMemory,
 		Entrypoint: config.Entrypoint,
 		KernelArgs: pkg.KernelArgs{},
 	}
 
+	// requested GPUs must exist and be free, and drained GPUs can't be used
+	// by new workloads, but workloads that already use them (re-installed
+	// on boot) are not affected.
+	if wl.Result.State != gridtypes.StateOk {
+		if err := vmgpu.CheckAvailable(ctx, wl, config.GPU); err != nil {
+			return result, err
+		}
+
+		if err := vmgpu.CheckDrained(config.GPU); err != nil {
+			return result, err
+		}
+	}
+
 	// expand GPUs
 	devices, err := vmgpu.ExpandGPUs(config.GPU)
 	if err != nil {
@@ -159,45 +409,37 @@ func (p *Manager) virtualMachineProvisionImpl(ctx context.Context, wl *gridtypes
 		machine.Devices = append(machine.Devices, gpuDevice)
 	}
 
-	// the config is validated by the engine. we now only support only one
-	// private network
-	if len(config.Network.Interfaces) != 1 {
-		return result, fmt.Errorf("only one private network is support")
+	// the config is validated by the engine, but the interfaces are checked
+	// again since their tap devices are named after the network
+	if err := validInterfaces(config.Network.Interfaces); err != nil {
+		return result, err
 	}
-	netConfig := config.Network.Interfaces[0]
 
 	result.ID = wl.ID.String()
-	result.IP = netConfig.IP.String()
+	// the result only holds the ip of the first private network
+	result.IP = config.Network.Interfaces[0].IP.String()
 
 	deployment, err := provision.GetDeployment(ctx)
 	if err != nil {
 		return result, errors.Wrap(err, "failed to get deployment")
 	}
-	networkInfo := pkg.VMNetworkInfo{
-		Nameservers: []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("1.1.1.1"), net.ParseIP("2001:4860:4860::8888")},
-	}
 
 	defer func() {
-		tapName := wl.ID.Unique(string(config.Network.Mycelium.Network))
 		if err != nil {
-			_ = network.Detach(ctx, tapName)
+			for _, tap := range networkTaps(wl, &config) {
+				_ = network.Detach(ctx, tap)
+			}
 		}
 	}()
 
-	for _, nic := range config.Network.Interfaces {
-		inf, err := p.newPrivNetworkInterface(ctx, deployment, wl, nic)
-		if err != nil {
-			return result, err
-		}
-		networkInfo.Ifaces = append(networkInfo.Ifaces, inf)
+	networkInfo, err := p.attachNetworks(ctx, deployment, wl, &config)
+	if err != nil {
+		return result, err
 	}
 
 	if config.Network.Mycelium != nil {
-		inf, err := p.newMyceliumNetworkInterface(ctx, deployment, wl, config.Network.Mycelium)
-		if err != nil {
-			return result, err
-		}
-		networkInfo.Ifaces = append(networkInfo.Ifaces, inf)
+		// the mycelium interface is always attached last
+		inf := networkInfo.Ifaces[len(networkInfo.Ifaces)-1]
 		result.MyceliumIP = inf.IPs[0].IP.String()
 	}
 	// - mount flist RO
@@ -235,6 +477,13 @@ func (p *Manager) virtualMachineProvisionImpl(ctx context.Context, wl *gridtypes
 		return result, errors.Wrap(err, "failed to mount cloud container base image")
 	}
 
+	machine.BaseImage = &pkg.BaseImage{
+		Name:  cloudContainerName,
+		Flist: cloudContainerFlist,
+		Hash:  hash,
+		Path:  cloudImage,
+	}
+
 	if imageInfo.IsContainer() {
 		if err = p.prepContainer(ctx, cloudImage, imageInfo, &machine, &config, &deployment, wl); err != nil {
 			return result, err
@@ -248,7 +497,13 @@ func (p *Manager) virtualMachineProvisionImpl(ctx context.Context, wl *gridtypes
 	// - Attach mounts
 	// - boot
 	machine.Network = networkInfo
-	machine.Environment = config.Env
+	identity := stubs.NewIdentityManagerStub(p.zbus)
+	machine.Environment, err = zos.MachineEnv(config.Env, config.SecretEnv, func(encrypted []byte) ([]byte, error) {
+		return identity.Decrypt(ctx, encrypted)
+	})
+	if err != nil {
+		return result, err
+	}
 	machine.Hostname = wl.Name.String()
 
 	machineInfo, err := vm.Run(ctx, machine)
@@ -281,10 +536,8 @@ func (p *Manager) copyFile(srcPath string, destPath string, permissions os.FileM
 
 func (p *Manager) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
 	var (
-		flist   = stubs.NewFlisterStub(p.zbus)
 		network = stubs.NewNetworkerLightStub(p.zbus)
 		vm      = stubs.NewVMModuleStub(p.zbus)
-		storage = stubs.NewStorageModuleStub(p.zbus)
 
 		cfg ZMachine
 	)
@@ -294,27 +547,31 @@ func (p *Manager) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID)
 	}
 
 	if _, err := vm.Inspect(ctx, wl.ID.String()); err == nil {
+		// give the guest a chance to shutdown cleanly before it's killed
+		// by the delete
+		if err := vm.Shutdown(ctx, wl.ID.String(), shutdownTimeout); err != nil {
+			log.Warn().Err(err).Str("vm-id", wl.ID.String()).Msg("vm did not shutdown gracefully, killing it")
+		}
+
 		if err := vm.Delete(ctx, wl.ID.String()); err != nil {
 			return errors.Wrapf(err, "failed to delete vm %s", wl.ID)
 		}
 	}
 
-	if err := flist.Unmount(ctx, wl.ID.String()); err != nil {
-		log.Error().Err(err).Msg("failed to unmount machine flist")
-	}
+	// the vm is gone at this point, so failing to clean up its rootfs must
+	// not fail the deprovision. the leak is tracked by the cleanup marker
+	// instead, and cleaned up later by the janitor.
+	p.markCleanup(wl.ID.String(), p.cleanupRootfs(ctx, wl.ID.String()))
 
-	volName := fmt.Sprintf("rootfs:%s", wl.ID.String())
-	if err := storage.VolumeDelete(ctx, volName); err != nil {
-		log.Error().Err(err).Str("name", volName).Msg("failed to delete rootfs volume")
-	}
-
-	if cfg.Network.Mycelium != nil {
-		tapName := wl.ID.Unique(string(cfg.Network.Mycelium.Network))
+	var cleanupErr error
 
-		if err := network.Detach(ctx, tapName); err != nil {
-			return errors.Wrap(err, "could not clean up tap device")
+	// detach deletes both the private and mycelium tap devices of a network
+	for _, tap := range networkTaps(wl, &cfg) {
+		if err := network.Detach(ctx, tap); err != nil {
+			log.Error().Err(err).Str("tap", tap).Msg("failed to clean up tap device")
+			cleanupErr = multierror.Append(cleanupErr, errors.Wrapf(err, "could not clean up tap device '%s'", tap))
 		}
 	}
 
-	return nil
+	return cleanupErr
 }
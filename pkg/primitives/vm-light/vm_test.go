@@ -0,0 +1,306 @@
+package vmlight
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zbus"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zosbase/pkg/mocks"
+	"github.com/vmihailenco/msgpack"
+	"go.uber.org/mock/gomock"
+)
+
+// response builds a zbus response the same way the zbus server does
+func response(t *testing.T, err error, values ...interface{}) *zbus.Response {
+	var out zbus.Output
+	if err != nil {
+		out.Error = &zbus.CallError{Message: err.Error()}
+	}
+
+	var data []byte
+	var encErr error
+	switch len(values) {
+	case 0:
+	case 1:
+		data, encErr = msgpack.Marshal(values[0])
+	default:
+		data, encErr = msgpack.Marshal(values)
+	}
+	require.NoError(t, encErr)
+
+	out.Data = data
+	return zbus.NewResponse("", out, "")
+}
+
+// fakeNetwork answers the networker and module calls of the vm-light manager
+type fakeNetwork struct {
+	t *testing.T
+
+	m        sync.Mutex
+	attached []string
+	detached []string
+	// fail attaching the private network with that name
+	fail string
+	// running makes the vm exist, so it's shut down and deleted
+	running bool
+	// vm are the vmd shutdown, restart and delete calls in order
+	vm []string
+	// volumes are the deleted volumes
+	volumes []string
+	// busy makes the flist daemon unreachable
+	busy bool
+}
+
+func (f *fakeNetwork) handle(ctx context.Context, module string, object zbus.ObjectID, method string, args ...interface{}) (*zbus.Response, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	switch method {
+	case "GetSubnet":
+		_, subnet, _ := net.ParseCIDR("10.20.2.0/24")
+		return response(f.t, nil, *subnet), nil
+	case "GetNet":
+		_, ipRange, _ := net.ParseCIDR("10.0.0.0/8")
+		return response(f.t, nil, *ipRange), nil
+	case "GetDefaultGwIP":
+		return response(f.t, nil, []byte(net.ParseIP("10.20.2.1").To4())), nil
+	case "AttachPrivate":
+		name, tap := args[0].(string), args[1].(string)
+		if name == f.fail {
+			return response(f.t, fmt.Errorf("failed to attach"), pkg.TapDevice{}), nil
+		}
+		f.attached = append(f.attached, tap)
+		return response(f.t, nil, pkg.TapDevice{
+			Name:   "b-" + tap,
+			Mac:    net.HardwareAddr{0x02, 0, 0, 0, 0, byte(len(f.attached))},
+			IP:     &net.IPNet{IP: net.ParseIP("fd00::2"), Mask: net.CIDRMask(64, 128)},
+			Routes: []pkg.Route{{Gateway: net.ParseIP("fd00::1")}},
+		}), nil
+	case "Detach":
+		f.detached = append(f.detached, args[0].(string))
+		return response(f.t, nil), nil
+	case "Inspect":
+		if f.running {
+			return response(f.t, nil, pkg.VMInfo{}), nil
+		}
+		return response(f.t, fmt.Errorf("vm not found"), pkg.VMInfo{}), nil
+	case "Shutdown", "Restart", "Delete":
+		f.vm = append(f.vm, method)
+		return response(f.t, nil), nil
+	case "Unmount":
+		if f.busy {
+			return nil, fmt.Errorf("flist daemon is busy")
+		}
+		return response(f.t, nil), nil
+	case "VolumeDelete":
+		f.volumes = append(f.volumes, args[0].(string))
+		return response(f.t, nil), nil
+	}
+
+	return nil, fmt.Errorf("unexpected call to %s.%s", module, method)
+}
+
+func testManager(t *testing.T, fake *fakeNetwork, opts ...Option) *Manager {
+	ctrl := gomock.NewController(t)
+	client := mocks.NewMockClient(ctrl)
+	client.EXPECT().
+		RequestContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, module string, object zbus.ObjectID, method string, args ...interface{}) (*zbus.Response, error) {
+			return fake.handle(ctx, module, object, method, args...)
+		}).
+		AnyTimes()
+
+	opts = append([]Option{
+		WithCleanupRetry(1, time.Millisecond),
+		WithCleanupFile(filepath.Join(t.TempDir(), "cleanup.json")),
+	}, opts...)
+
+	return NewManager(client, opts...)
+}
+
+func testMachine(t *testing.T, networks ...string) (*gridtypes.WorkloadWithID, ZMachine) {
+	var config ZMachine
+	for i, network := range networks {
+		config.Network.Interfaces = append(config.Network.Interfaces, zos.MachineInterface{
+			Network: gridtypes.Name(network),
+			IP:      net.ParseIP(fmt.Sprintf("10.20.2.%d", i+2)),
+		})
+	}
+
+	data, err := json.Marshal(config)
+	require.NoError(t, err)
+
+	wl := &gridtypes.WorkloadWithID{
+		Workload: &gridtypes.Workload{
+			Name: "vm",
+			Type: zos.ZMachineLightType,
+			Data: data,
+		},
+		ID: gridtypes.NewUncheckedWorkloadID(1, 1, "vm"),
+	}
+
+	return wl, config
+}
+
+func TestValidInterfaces(t *testing.T) {
+	_, config := testMachine(t, "net1", "net2")
+	require.NoError(t, validInterfaces(config.Network.Interfaces))
+
+	_, config = testMachine(t, "net1", "net1")
+	require.Error(t, validInterfaces(config.Network.Interfaces))
+
+	require.Error(t, validInterfaces(nil))
+}
+
+func TestNetworkTaps(t *testing.T) {
+	wl, config := testMachine(t, "net1", "net2")
+	require.Equal(t, []string{wl.ID.Unique("net1"), wl.ID.Unique("net2")}, networkTaps(wl, &config))
+
+	// mycelium on one of the private networks shares its tap id
+	config.Network.Mycelium = &zos.MyceliumIP{Network: "net2"}
+	require.Len(t, networkTaps(wl, &config), 2)
+
+	config.Network.Mycelium = &zos.MyceliumIP{Network: "net3"}
+	require.Equal(t, wl.ID.Unique("net3"), networkTaps(wl, &config)[2])
+}
+
+func TestAttachNetworks(t *testing.T) {
+	fake := &fakeNetwork{t: t}
+	manager := testManager(t, fake)
+	wl, config := testMachine(t, "net1", "net2")
+
+	info, err := manager.attachNetworks(context.Background(), gridtypes.Deployment{TwinID: 1}, wl, &config)
+	require.NoError(t, err)
+	require.Len(t, info.Ifaces, 2)
+	require.Equal(t, "b-"+wl.ID.Unique("net1"), info.Ifaces[0].Tap)
+	require.Equal(t, "b-"+wl.ID.Unique("net2"), info.Ifaces[1].Tap)
+	require.Equal(t, networkTaps(wl, &config), fake.attached)
+}
+
+func TestAttachNetworksFailed(t *testing.T) {
+	fake := &fakeNetwork{t: t, fail: string(zos.NetworkID(1, "net2"))}
+	manager := testManager(t, fake)
+	wl, config := testMachine(t, "net1", "net2")
+
+	_, err := manager.attachNetworks(context.Background(), gridtypes.Deployment{TwinID: 1}, wl, &config)
+	require.ErrorContains(t, err, "net2")
+	require.Equal(t, []string{wl.ID.Unique("net1")}, fake.attached)
+}
+
+func TestDeprovisionNetworks(t *testing.T) {
+	fake := &fakeNetwork{t: t}
+	manager := testManager(t, fake)
+	wl, config := testMachine(t, "net1", "net2")
+
+	require.NoError(t, manager.Deprovision(context.Background(), wl))
+	require.Equal(t, networkTaps(wl, &config), fake.detached)
+	require.Empty(t, manager.NeedsCleanup())
+	require.Empty(t, fake.vm)
+}
+
+func TestDeprovisionShutdown(t *testing.T) {
+	fake := &fakeNetwork{t: t, running: true}
+	manager := testManager(t, fake)
+	wl, _ := testMachine(t, "net1")
+
+	require.NoError(t, manager.Deprovision(context.Background(), wl))
+	require.Equal(t, []string{"Shutdown", "Delete"}, fake.vm)
+}
+
+func TestAttachNetworksNameservers(t *testing.T) {
+	fake := &fakeNetwork{t: t}
+	manager := testManager(t, fake)
+	wl, config := testMachine(t, "net1")
+
+	info, err := manager.attachNetworks(context.Background(), gridtypes.Deployment{TwinID: 1}, wl, &config)
+	require.NoError(t, err)
+	require.Equal(t, defaultNameservers, info.Nameservers)
+
+	config.Network.Nameservers = []net.IP{net.ParseIP("10.20.0.53"), net.ParseIP("fd00::53")}
+	info, err = manager.attachNetworks(context.Background(), gridtypes.Deployment{TwinID: 1}, wl, &config)
+	require.NoError(t, err)
+	require.Equal(t, config.Network.Nameservers, info.Nameservers)
+}
+
+func TestDeprovisionNeedsCleanup(t *testing.T) {
+	fake := &fakeNetwork{t: t, busy: true}
+	file := filepath.Join(t.TempDir(), "cleanup.json")
+	manager := testManager(t, fake, WithCleanupFile(file))
+	wl, config := testMachine(t, "net1")
+
+	// the rootfs leak is tracked, it doesn't fail the deprovision
+	require.NoError(t, manager.Deprovision(context.Background(), wl))
+	require.Equal(t, []string{wl.ID.String()}, manager.NeedsCleanup())
+	require.Equal(t, networkTaps(wl, &config), fake.detached)
+
+	// the marker survives a restart
+	manager = testManager(t, fake, WithCleanupFile(file))
+	require.Equal(t, []string{wl.ID.String()}, manager.NeedsCleanup())
+
+	fake.busy = false
+	require.NoError(t, manager.Deprovision(context.Background(), wl))
+	require.Empty(t, manager.NeedsCleanup())
+
+	manager = testManager(t, fake, WithCleanupFile(file))
+	require.Empty(t, manager.NeedsCleanup())
+}
+
+func TestJanitorDrainsCleanup(t *testing.T) {
+	fake := &fakeNetwork{t: t, busy: true}
+	manager := testManager(t, fake, WithJanitorInterval(time.Millisecond))
+	wl, _ := testMachine(t, "net1")
+
+	require.NoError(t, manager.Deprovision(context.Background(), wl))
+	require.Equal(t, []string{wl.ID.String()}, manager.NeedsCleanup())
+
+	// still busy, the marker is kept
+	manager.drainCleanup(context.Background())
+	require.Equal(t, []string{wl.ID.String()}, manager.NeedsCleanup())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		manager.janitor(ctx)
+		close(done)
+	}()
+
+	fake.m.Lock()
+	fake.busy = false
+	fake.m.Unlock()
+
+	require.Eventually(t, func() bool {
+		return len(manager.NeedsCleanup()) == 0
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not stop on cancel")
+	}
+}
+
+func TestRestartKeepsRootfs(t *testing.T) {
+	fake := &fakeNetwork{t: t, running: true}
+	manager := testManager(t, fake)
+	wl, _ := testMachine(t, "net1")
+
+	require.NoError(t, manager.Restart(context.Background(), wl))
+	require.Equal(t, []string{"Restart"}, fake.vm)
+	require.Empty(t, fake.volumes)
+	require.Empty(t, fake.detached)
+
+	// only a deprovision deletes the rootfs
+	require.NoError(t, manager.Deprovision(context.Background(), wl))
+	require.Equal(t, []string{"rootfs:" + wl.ID.String()}, fake.volumes)
+}
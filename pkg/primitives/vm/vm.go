@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -23,6 +24,10 @@ import (
 
 const (
 	cloudContainerName = "cloud-container"
+
+	// restartTimeout is how long the guest is given to power off on restart
+	// before the vm is killed
+	restartTimeout = 30 * time.Second
 )
 
 // ZMachine type
@@ -31,6 +36,7 @@ type ZMachine = zos.ZMachine
 var (
 	_ provision.Manager     = (*Manager)(nil)
 	_ provision.Initializer = (*Manager)(nil)
+	_ provision.Restartable = (*Manager)(nil)
 )
 
 type Manager struct {
@@ -45,6 +51,13 @@ func (m *Manager) Initialize(ctx context.Context) error {
 	return vmgpu.InitGPUs()
 }
 
+// Restart implements provision.Restartable. The machine is restarted in place
+// by vmd, its rootfs, disks and network are kept.
+func (m *Manager) Restart(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	vm := stubs.NewVMModuleStub(m.zbus)
+	return vm.Restart(ctx, wl.ID.String(), restartTimeout)
+}
+
 func (p *Manager) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (interface{}, error) {
 	return p.virtualMachineProvisionImpl(ctx, wl)
 }
@@ -144,8 +157,24 @@ func (p *Manager) virtualMachineProvisionImpl(ctx context.Context, wl *gridtypes
 		Name:       wl.ID.String(),
 		CPU:        config.ComputeCapacity.CPU,
 		Memory:     config.ComputeCapacity.Memory,
+		MaxCPU:     config.ComputeCapacity.MaxCPU,
+		MaxMemory:  config.ComputeCapacity.MaxMemory,
 		Entrypoint: config.Entrypoint,
 		KernelArgs: pkg.KernelArgs{},
+		Watchdog:   config.Watchdog,
+	}
+
+	// requested GPUs must exist and be free, and drained GPUs can't be used
+	// by new workloads, but workloads that already use them (re-installed
+	// on boot) are not affected.
+	if wl.Result.State != gridtypes.StateOk {
+		if err := vmgpu.CheckAvailable(ctx, wl, config.GPU); err != nil {
+			return result, err
+		}
+
+		if err := vmgpu.CheckDrained(config.GPU); err != nil {
+			return result, err
+		}
 	}
 
 	// expand GPUs
@@ -274,6 +303,13 @@ func (p *Manager) virtualMachineProvisionImpl(ctx context.Context, wl *gridtypes
 		return result, errors.Wrap(err, "failed to mount cloud container base image")
 	}
 
+	machine.BaseImage = &pkg.BaseImage{
+		Name:  cloudContainerName,
+		Flist: cloudContainerFlist,
+		Hash:  hash,
+		Path:  cloudImage,
+	}
+
 	if imageInfo.IsContainer() {
 		if err = p.prepContainer(ctx, cloudImage, imageInfo, &machine, &config, &deployment, wl); err != nil {
 			return result, err
@@ -287,7 +323,13 @@ func (p *Manager) virtualMachineProvisionImpl(ctx context.Context, wl *gridtypes
 	// - Attach mounts
 	// - boot
 	machine.Network = networkInfo
-	machine.Environment = config.Env
+	identity := stubs.NewIdentityManagerStub(p.zbus)
+	machine.Environment, err = zos.MachineEnv(config.Env, config.SecretEnv, func(encrypted []byte) ([]byte, error) {
+		return identity.Decrypt(ctx, encrypted)
+	})
+	if err != nil {
+		return result, err
+	}
 	machine.Hostname = wl.Name.String()
 
 	machineInfo, err := vm.Run(ctx, machine)
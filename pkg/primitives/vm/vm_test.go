@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zbus"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zosbase/pkg/mocks"
+	"github.com/vmihailenco/msgpack"
+	"go.uber.org/mock/gomock"
+)
+
+// testManager returns a manager over a zbus client that records the called
+// methods, and the deleted volumes
+func testManager(t *testing.T) (*Manager, *[]string, *[]string) {
+	var calls, volumes []string
+
+	ctrl := gomock.NewController(t)
+	client := mocks.NewMockClient(ctrl)
+	client.EXPECT().
+		RequestContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, module string, object zbus.ObjectID, method string, args ...interface{}) (*zbus.Response, error) {
+			calls = append(calls, method)
+
+			var data []byte
+			var err error
+			switch method {
+			case "Inspect":
+				data, err = msgpack.Marshal(pkg.VMInfo{})
+			case "VolumeDelete":
+				volumes = append(volumes, args[0].(string))
+			}
+			require.NoError(t, err)
+
+			return zbus.NewResponse("", zbus.Output{Data: data}, ""), nil
+		}).
+		AnyTimes()
+
+	return NewManager(client), &calls, &volumes
+}
+
+func TestRestartKeepsRootfs(t *testing.T) {
+	manager, calls, volumes := testManager(t)
+
+	data, err := json.Marshal(ZMachine{
+		Network: zos.MachineNetwork{
+			Interfaces: []zos.MachineInterface{{Network: "net1", IP: net.ParseIP("10.20.2.2")}},
+		},
+	})
+	require.NoError(t, err)
+
+	wl := &gridtypes.WorkloadWithID{
+		Workload: &gridtypes.Workload{Name: "vm", Type: zos.ZMachineType, Data: data},
+		ID:       gridtypes.NewUncheckedWorkloadID(1, 1, "vm"),
+	}
+
+	require.NoError(t, manager.Restart(context.Background(), wl))
+	require.Equal(t, []string{"Restart"}, *calls)
+	require.Empty(t, *volumes)
+
+	// only a deprovision deletes the rootfs
+	require.NoError(t, manager.Deprovision(context.Background(), wl))
+	require.Equal(t, []string{"rootfs:" + wl.ID.String()}, *volumes)
+}
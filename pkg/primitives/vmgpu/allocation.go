@@ -0,0 +1,109 @@
+package vmgpu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zosbase/pkg/kernel"
+	"github.com/threefoldtech/zosbase/pkg/provision"
+)
+
+// ErrGPUUnavailable is returned if a requested gpu does not exist on the
+// node or is already used by another workload
+var ErrGPUUnavailable = fmt.Errorf("gpu unavailable")
+
+// Allocation is the workload that is using a gpu
+type Allocation struct {
+	Contract uint64
+	Workload gridtypes.WorkloadID
+}
+
+// Allocated returns the allocation of each gpu that is used by an active
+// vm workload.
+func Allocated(storage provision.Storage) (map[string]Allocation, error) {
+	gpus := make(map[string]Allocation)
+	active, err := storage.Capacity()
+	if err != nil {
+		return nil, err
+	}
+
+	type vmType struct {
+		GPU []zos.GPU `json:"gpu,omitempty"`
+	}
+
+	for _, dl := range active.Deployments {
+		for _, wl := range dl.Workloads {
+			if wl.Type != zos.ZMachineType && wl.Type != zos.ZMachineLightType {
+				continue
+			}
+
+			var vm vmType
+			if err := json.Unmarshal(wl.Data, &vm); err != nil {
+				return nil, errors.Wrapf(err, "invalid workload data (%d.%s)", dl.ContractID, wl.Name)
+			}
+
+			for _, gpu := range vm.GPU {
+				gpus[string(gpu)] = Allocation{
+					Contract: dl.ContractID,
+					Workload: gridtypes.NewUncheckedWorkloadID(dl.TwinID, dl.ContractID, wl.Name),
+				}
+			}
+		}
+	}
+
+	return gpus, nil
+}
+
+// CheckAvailable makes sure all the gpus requested by the workload exist on
+// the node and are not used by any other workload. It must only be called
+// for new gpu allocations, before the vm is started.
+func CheckAvailable(ctx context.Context, wl *gridtypes.WorkloadWithID, gpus []zos.GPU) error {
+	if len(gpus) == 0 {
+		return nil
+	}
+
+	if kernel.GetParams().IsGPUDisabled() {
+		return fmt.Errorf("GPU is disabled on this node")
+	}
+
+	return checkAvailable(provision.GetEngine(ctx).Storage(), wl.ID, gpus)
+}
+
+func checkAvailable(storage provision.Storage, id gridtypes.WorkloadID, gpus []zos.GPU) error {
+	devices, err := listGPUs()
+	if err != nil {
+		return errors.Wrap(err, "failed to list available GPUs")
+	}
+
+	exists := make(map[string]struct{})
+	for _, device := range devices {
+		exists[device.ShortID()] = struct{}{}
+	}
+
+	allocated, err := Allocated(storage)
+	if err != nil {
+		return errors.Wrap(err, "failed to list allocated GPUs")
+	}
+
+	requested := make(map[zos.GPU]struct{})
+	for _, gpu := range gpus {
+		if _, ok := requested[gpu]; ok {
+			return errors.Wrapf(ErrGPUUnavailable, "gpu '%s' is requested more than once", gpu)
+		}
+		requested[gpu] = struct{}{}
+
+		if _, ok := exists[string(gpu)]; !ok {
+			return errors.Wrapf(ErrGPUUnavailable, "gpu '%s' does not exist on this node", gpu)
+		}
+
+		if allocation, ok := allocated[string(gpu)]; ok && allocation.Workload != id {
+			return errors.Wrapf(ErrGPUUnavailable, "gpu '%s' is in use by contract %d", gpu, allocation.Contract)
+		}
+	}
+
+	return nil
+}
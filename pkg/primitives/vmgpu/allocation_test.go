@@ -0,0 +1,76 @@
+package vmgpu
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/capacity"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zosbase/pkg/provision/storage"
+)
+
+// gpuDeployment creates a provisioned deployment with a vm that uses the
+// given gpus
+func gpuDeployment(t *testing.T, db *storage.BoltStorage, contract uint64, gpus ...zos.GPU) gridtypes.WorkloadID {
+	data, err := json.Marshal(zos.ZMachine{
+		ComputeCapacity: zos.MachineCapacity{CPU: 1, Memory: gridtypes.Gigabyte},
+		GPU:             gpus,
+	})
+	require.NoError(t, err)
+
+	dl := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: contract,
+		Workloads: []gridtypes.Workload{
+			{Name: "vm", Type: zos.ZMachineType, Data: data},
+		},
+	}
+	require.NoError(t, db.Create(dl))
+
+	wl := dl.Workloads[0]
+	wl.Result = gridtypes.Result{State: gridtypes.StateOk, Created: gridtypes.Now()}
+	require.NoError(t, db.Transaction(1, contract, wl))
+
+	return gridtypes.NewUncheckedWorkloadID(1, contract, "vm")
+}
+
+func TestCheckAvailable(t *testing.T) {
+	gpu1 := capacity.PCI{Slot: "0000:01:00.0", Vendor: 0x1002, Device: 0x731f}
+	gpu2 := capacity.PCI{Slot: "0000:02:00.0", Vendor: 0x1002, Device: 0x731f}
+	fakeGPUs(t, gpu1, gpu2)
+
+	db, err := storage.New(filepath.Join(t.TempDir(), "storage.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	used := zos.GPU(gpu1.ShortID())
+	free := zos.GPU(gpu2.ShortID())
+	owner := gpuDeployment(t, db, 1, used)
+	other := gridtypes.NewUncheckedWorkloadID(1, 2, "vm")
+
+	allocated, err := Allocated(db)
+	require.NoError(t, err)
+	require.Equal(t, map[string]Allocation{
+		string(used): {Contract: 1, Workload: owner},
+	}, allocated)
+
+	require.NoError(t, checkAvailable(db, other, []zos.GPU{free}))
+	// the owner can start again with the same gpu
+	require.NoError(t, checkAvailable(db, owner, []zos.GPU{used}))
+
+	err = checkAvailable(db, other, []zos.GPU{used})
+	require.True(t, errors.Is(err, ErrGPUUnavailable))
+	require.Contains(t, err.Error(), "in use by contract 1")
+
+	err = checkAvailable(db, other, []zos.GPU{"0000:03:00.0/1002/731f"})
+	require.True(t, errors.Is(err, ErrGPUUnavailable))
+	require.Contains(t, err.Error(), "does not exist")
+
+	err = checkAvailable(db, other, []zos.GPU{free, free})
+	require.True(t, errors.Is(err, ErrGPUUnavailable))
+	require.Contains(t, err.Error(), "more than once")
+}
@@ -0,0 +1,160 @@
+package vmgpu
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+var (
+	// DrainFile is where the drained GPUs are persisted
+	DrainFile = "/var/cache/modules/provisiond/gpu-drain.json"
+
+	// ErrGPUDrained is returned if a drained gpu is requested by a new workload
+	ErrGPUDrained = fmt.Errorf("gpu is drained")
+
+	drainLock sync.Mutex
+)
+
+// DrainInfo is why and since when a gpu is drained
+type DrainInfo struct {
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// Drained returns all drained gpus
+func Drained() (map[string]DrainInfo, error) {
+	drainLock.Lock()
+	defer drainLock.Unlock()
+
+	return loadDrained()
+}
+
+// Drain marks the gpu unavailable for new workloads. VMs that are already
+// using the gpu are not affected. The drain is persisted until Undrain
+// is called.
+func Drain(id string, reason string) error {
+	if err := gpuExists(id); err != nil {
+		return err
+	}
+
+	drainLock.Lock()
+	defer drainLock.Unlock()
+
+	drained, err := loadDrained()
+	if err != nil {
+		return err
+	}
+
+	drained[id] = DrainInfo{Reason: reason, Since: time.Now()}
+	if err := saveDrained(drained); err != nil {
+		return err
+	}
+
+	log.Warn().Str("gpu", id).Str("reason", reason).Msg("gpu drained")
+	return nil
+}
+
+// Undrain makes the gpu available again for new workloads
+func Undrain(id string) error {
+	drainLock.Lock()
+	defer drainLock.Unlock()
+
+	drained, err := loadDrained()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := drained[id]; !ok {
+		return nil
+	}
+
+	delete(drained, id)
+	if err := saveDrained(drained); err != nil {
+		return err
+	}
+
+	log.Info().Str("gpu", id).Msg("gpu undrained")
+	return nil
+}
+
+// CheckDrained returns an error if any of the requested gpus is drained, it
+// must only be called for new gpu allocations.
+func CheckDrained(gpus []zos.GPU) error {
+	if len(gpus) == 0 {
+		return nil
+	}
+
+	drained, err := Drained()
+	if err != nil {
+		return err
+	}
+
+	for _, gpu := range gpus {
+		if info, ok := drained[string(gpu)]; ok {
+			return errors.Wrapf(ErrGPUDrained, "gpu '%s' is unavailable for maintenance (%s)", gpu, info.Reason)
+		}
+	}
+
+	return nil
+}
+
+func gpuExists(id string) error {
+	gpus, err := listGPUs()
+	if err != nil {
+		return errors.Wrap(err, "failed to list system GPUs")
+	}
+
+	for _, gpu := range gpus {
+		if gpu.ShortID() == id {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown GPU id '%s'", id)
+}
+
+func loadDrained() (map[string]DrainInfo, error) {
+	drained := make(map[string]DrainInfo)
+	data, err := os.ReadFile(DrainFile)
+	if os.IsNotExist(err) {
+		return drained, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read drained gpus")
+	}
+
+	if err := json.Unmarshal(data, &drained); err != nil {
+		return nil, errors.Wrap(err, "failed to decode drained gpus")
+	}
+
+	return drained, nil
+}
+
+func saveDrained(drained map[string]DrainInfo) error {
+	data, err := json.Marshal(drained)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode drained gpus")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(DrainFile), 0755); err != nil {
+		return errors.Wrap(err, "failed to create drained gpus directory")
+	}
+
+	tmp := DrainFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to write drained gpus")
+	}
+
+	if err := os.Rename(tmp, DrainFile); err != nil {
+		return errors.Wrap(err, "failed to write drained gpus")
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package vmgpu
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/capacity"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+// fakeGPUs makes the node report the given gpus
+func fakeGPUs(t *testing.T, gpus ...capacity.PCI) {
+	old := listGPUs
+	listGPUs = func() ([]capacity.PCI, error) {
+		return gpus, nil
+	}
+	t.Cleanup(func() { listGPUs = old })
+}
+
+func testDrainFile(t *testing.T) {
+	old := DrainFile
+	DrainFile = filepath.Join(t.TempDir(), "provisiond", "gpu-drain.json")
+	t.Cleanup(func() { DrainFile = old })
+}
+
+func TestDrain(t *testing.T) {
+	testDrainFile(t)
+	gpu := capacity.PCI{Slot: "0000:01:00.0", Vendor: 0x1002, Device: 0x731f}
+	fakeGPUs(t, gpu)
+	id := gpu.ShortID()
+
+	drained, err := Drained()
+	require.NoError(t, err)
+	require.Empty(t, drained)
+
+	require.Error(t, Drain("0000:02:00.0/1002/731f", "unknown"))
+
+	require.NoError(t, Drain(id, "fan replacement"))
+	drained, err = Drained()
+	require.NoError(t, err)
+	require.Len(t, drained, 1)
+	require.Equal(t, "fan replacement", drained[id].Reason)
+	require.False(t, drained[id].Since.IsZero())
+
+	err = CheckDrained([]zos.GPU{zos.GPU(id)})
+	require.True(t, errors.Is(err, ErrGPUDrained))
+	require.NoError(t, CheckDrained(nil))
+	require.NoError(t, CheckDrained([]zos.GPU{"0000:02:00.0/1002/731f"}))
+
+	require.NoError(t, Undrain(id))
+	// undrain of a gpu that is not drained is a no-op
+	require.NoError(t, Undrain(id))
+
+	drained, err = Drained()
+	require.NoError(t, err)
+	require.Empty(t, drained)
+	require.NoError(t, CheckDrained([]zos.GPU{zos.GPU(id)}))
+}
@@ -21,6 +21,11 @@ const (
 
 var (
 	modules = []string{"vfio", "vfio-pci", "vfio_iommu_type1"}
+
+	// listGPUs lists the node gpus, it's a var so tests can fake the devices
+	listGPUs = func() ([]capacity.PCI, error) {
+		return capacity.ListPCI(capacity.GPU)
+	}
 )
 
 func InitGPUVfioModules() error {
@@ -5,6 +5,7 @@ package pkg
 
 import (
 	"context"
+	"time"
 
 	"github.com/threefoldtech/zosbase/pkg/gridtypes"
 )
@@ -12,6 +13,9 @@ import (
 // Provision interface
 type Provision interface {
 	DecommissionCached(id string, reason string) error
+	// DeprovisionWorkload removes a single workload from its deployment. If the
+	// workload is used by other workloads, cascade must be set to remove them too.
+	DeprovisionWorkload(id string, reason string, cascade bool) error
 	// GetWorkloadStatus: returns status, bool(true if workload exits otherwise it is false), error
 	GetWorkloadStatus(id string) (gridtypes.ResultState, bool, error)
 	CreateOrUpdate(twin uint32, deployment gridtypes.Deployment, update bool) error
@@ -21,6 +25,112 @@ type Provision interface {
 	ListTwins() ([]uint32, error)
 	ListPublicIPs() ([]string, error)
 	ListPrivateIPs(twin uint32, network gridtypes.Name) ([]string, error)
+	// StartupOrder returns the order of types used to install workloads
+	StartupOrder() ([]gridtypes.WorkloadType, error)
+	// SetStartupOrder changes the order of types used to install workloads,
+	// the new order is applied starting from the next job.
+	SetStartupOrder(types ...gridtypes.WorkloadType) error
+	// ValidateDeployment runs all the checks done before a deployment is
+	// deployed (or updated), without storing or applying anything.
+	ValidateDeployment(twin uint32, deployment gridtypes.Deployment) error
+	// ValidationState checks a stored deployment against its contract, the same
+	// way the engine does before applying it.
+	ValidationState(twin uint32, contractID uint64) (ValidationState, error)
+	// BootReconcile returns what the engine did with each stored workload
+	// when it was re-installed on the last boot.
+	BootReconcile() ([]WorkloadReconcile, error)
+	// NodeDeploymentsSummary aggregates all deployments on the node across
+	// all twins.
+	NodeDeploymentsSummary() (NodeSummary, error)
+	// ResyncPublicIPRules re-applies the firewall rules of all active
+	// public ip workloads from the state in storage
+	ResyncPublicIPRules() error
+	// TwinsUsage returns the usage of each twin with deployments on the
+	// node, and the quota that applies to it.
+	TwinsUsage() ([]TwinUsage, error)
+	// QueueStats returns the number of pending engine jobs, and when the
+	// oldest of them was queued
+	QueueStats() (pending int, oldest time.Time, err error)
+}
+
+// TwinQuota limits what a single twin can deploy on the node, a zero
+// value means no limit.
+type TwinQuota struct {
+	// MaxDeployments is the max number of active deployments
+	MaxDeployments int `json:"max_deployments"`
+	// MaxMemory is the max total memory of all active workloads
+	MaxMemory gridtypes.Unit `json:"max_memory"`
+	// MaxCPU is the max total number of cpus of all active workloads
+	MaxCPU uint64 `json:"max_cpu"`
+}
+
+// TwinUsage is what a twin is using on the node
+type TwinUsage struct {
+	TwinID uint32 `json:"twin_id"`
+	// Deployments is the number of active deployments
+	Deployments int `json:"deployments"`
+	// Capacity used by the active workloads of the twin
+	Capacity gridtypes.Capacity `json:"capacity"`
+	// Quota that applies to the twin
+	Quota TwinQuota `json:"quota"`
+	// Exempt is set if the twin is an admin and not subject to the quota
+	Exempt bool `json:"exempt"`
+}
+
+// DeploymentSummary is a short summary of a single deployment
+type DeploymentSummary struct {
+	TwinID     uint32 `json:"twin_id"`
+	ContractID uint64 `json:"contract_id"`
+	// Workloads is the number of workloads by state
+	Workloads map[gridtypes.ResultState]int `json:"workloads"`
+	// Capacity used by the active workloads of the deployment
+	Capacity gridtypes.Capacity `json:"capacity"`
+}
+
+// NodeSummary is a summary of all deployments on the node
+type NodeSummary struct {
+	Twins       int                 `json:"twins"`
+	Deployments []DeploymentSummary `json:"deployments"`
+	// Workloads is the total number of workloads by state
+	Workloads map[gridtypes.ResultState]int `json:"workloads"`
+	// Capacity is the total capacity used by active workloads
+	Capacity gridtypes.Capacity `json:"capacity"`
+}
+
+// ReconcileOutcome is what the engine did with a stored workload on boot
+type ReconcileOutcome string
+
+const (
+	// ReconcileReinstalled the workload was installed again (or was already running)
+	ReconcileReinstalled ReconcileOutcome = "reinstalled"
+	// ReconcileSkippedDeleted the workload was skipped because it was deleted
+	ReconcileSkippedDeleted ReconcileOutcome = "skipped-deleted"
+	// ReconcileSkippedError the workload was skipped because it was in error state
+	ReconcileSkippedError ReconcileOutcome = "skipped-error"
+	// ReconcileFailed the workload failed to be installed again
+	ReconcileFailed ReconcileOutcome = "failed"
+)
+
+// WorkloadReconcile is the boot reconcile outcome of a single workload
+type WorkloadReconcile struct {
+	ID      string           `json:"id"`
+	Type    string           `json:"type"`
+	Outcome ReconcileOutcome `json:"outcome"`
+	// Error is set if outcome is failed
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// ValidationState is the result of validating a deployment against its contract
+type ValidationState struct {
+	// Valid is true if all checks pass
+	Valid          bool `json:"valid"`
+	ContractExists bool `json:"contract_exists"`
+	NodeMatches    bool `json:"node_matches"`
+	HashMatches    bool `json:"hash_matches"`
+	TwinVerified   bool `json:"twin_verified"`
+	// Error is the first failing condition
+	Error string `json:"error,omitempty"`
 }
 
 type Statistics interface {
@@ -29,7 +139,15 @@ type Statistics interface {
 	Total() gridtypes.Capacity
 	Workloads() (int, error)
 	GetCounters() (Counters, error)
+	// GetCapacitySummary returns the counters with the capacity of the
+	// workloads accepted but not provisioned yet, and the free capacity
+	GetCapacitySummary() (CapacitySummary, error)
 	ListGPUs() ([]GPUInfo, error)
+	// DrainGPU marks the gpu unavailable for new workloads, workloads
+	// already using it are not affected
+	DrainGPU(id string, reason string) error
+	// UndrainGPU makes a drained gpu available again
+	UndrainGPU(id string) error
 }
 
 type Counters struct {
@@ -43,6 +161,30 @@ type Counters struct {
 	Users UsersCounters `json:"users"`
 	// OpenConnecions number of open connections in the node
 	OpenConnecions int `json:"open_connections"`
+	// Queue is the state of the provision engine queues, it's not set if
+	// the queues state could not be read
+	Queue *QueueCounters `json:"queue,omitempty"`
+}
+
+// CapacitySummary is the node capacity usage as needed to schedule new
+// workloads on the node
+type CapacitySummary struct {
+	Counters
+	// Pending is the capacity of the workloads that are accepted by the node
+	// but not provisioned yet, it's not part of Used
+	Pending gridtypes.Capacity `json:"pending"`
+	// Free is the capacity left for new workloads, that is the total minus
+	// the used (including the system) and pending capacity
+	Free gridtypes.Capacity `json:"free"`
+}
+
+// QueueCounters is the state of the provision engine job queues
+type QueueCounters struct {
+	// Pending jobs count, including the job being processed
+	Pending int `json:"pending"`
+	// Oldest is when the oldest pending job was queued, it's zero
+	// if there are no pending jobs
+	Oldest gridtypes.Timestamp `json:"oldest"`
 }
 
 // UsersCounters the expected counters for deployments and workloads
@@ -55,10 +197,32 @@ type UsersCounters struct {
 	LastDeploymentTimestamp gridtypes.Timestamp `json:"last_deployment_timestamp"`
 }
 
+// GPUStatus is the allocation status of a GPU
+type GPUStatus string
+
+const (
+	// GPUAvailable the gpu is free for new workloads
+	GPUAvailable GPUStatus = "available"
+	// GPUAllocated the gpu is used by a workload
+	GPUAllocated GPUStatus = "allocated"
+	// GPUDrained the gpu is free but drained for maintenance
+	GPUDrained GPUStatus = "drained"
+)
+
 type GPUInfo struct {
-	ID       string `json:"id"`
-	Vendor   string `json:"vendor"`
-	Device   string `json:"device"`
-	Vram     uint64 `json:"vram"`
-	Contract uint64 `json:"contract"`
+	ID     string `json:"id"`
+	Vendor string `json:"vendor"`
+	Device string `json:"device"`
+	Vram   uint64 `json:"vram"`
+	// Contract is the contract of the workload using the gpu, 0 if not allocated
+	Contract uint64    `json:"contract"`
+	Status   GPUStatus `json:"status"`
+	// Drained is set if the gpu is drained, an allocated gpu can be
+	// drained as well, it then becomes unavailable once freed.
+	Drained     bool   `json:"drained,omitempty"`
+	DrainReason string `json:"drain_reason,omitempty"`
+	// Utilization is the gpu busy percent, only set if the driver exposes it
+	Utilization *uint64 `json:"utilization,omitempty"`
+	// VramUsed is the used vram in bytes, only set if the driver exposes it
+	VramUsed *uint64 `json:"vram_used,omitempty"`
 }
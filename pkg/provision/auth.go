@@ -47,6 +47,13 @@ func (s *substrateTwins) GetKey(id uint32) ([]byte, error) {
 	return pk, nil
 }
 
+// Invalidate drops the cached key of the twin, and the twin cached by the
+// substrate gateway so the key is fetched again from the chain
+func (s *substrateTwins) Invalidate(id uint32) {
+	s.mem.Delete(fmt.Sprint(id))
+	s.substrateGateway.InvalidateTwin(context.Background(), id)
+}
+
 type substrateAdmins struct {
 	substrateGateway *stubs.SubstrateGatewayStub
 	twin             uint32
@@ -89,3 +96,13 @@ func (s *substrateAdmins) GetKey(id uint32) ([]byte, error) {
 	s.mem.Set(cacheKey, pk, cache.DefaultExpiration)
 	return pk, nil
 }
+
+// Invalidate drops the cached key of the admin twin
+func (s *substrateAdmins) Invalidate(id uint32) {
+	if id != s.twin {
+		return
+	}
+
+	s.mem.Delete(fmt.Sprint(id))
+	s.substrateGateway.InvalidateTwin(context.Background(), id)
+}
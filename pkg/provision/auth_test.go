@@ -0,0 +1,59 @@
+package provision
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+// rotatedTwins serves a stale key until it's invalidated
+type rotatedTwins struct {
+	stale       []byte
+	key         []byte
+	invalidated bool
+}
+
+func (r *rotatedTwins) GetKey(id uint32) ([]byte, error) {
+	if r.invalidated {
+		return r.key, nil
+	}
+	return r.stale, nil
+}
+
+func (r *rotatedTwins) Invalidate(id uint32) {
+	r.invalidated = true
+}
+
+func TestVerifyInvalidatesStaleKeys(t *testing.T) {
+	stale, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pk, sk, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	id, err := substrate.NewIdentityFromEd25519Key(sk)
+	require.NoError(t, err)
+
+	dl := gridtypes.Deployment{
+		TwinID: 1,
+		SignatureRequirement: gridtypes.SignatureRequirement{
+			Requests: []gridtypes.SignatureRequest{
+				{TwinID: 1, Required: true, Weight: 1},
+			},
+		},
+	}
+	require.NoError(t, dl.Sign(1, id))
+
+	twins := &rotatedTwins{stale: stale, key: pk}
+	e := &NativeEngine{twins: twins}
+	require.NoError(t, e.verify(&dl))
+	require.True(t, twins.invalidated)
+
+	// a wrong signature still fails after invalidation
+	twins = &rotatedTwins{stale: stale, key: stale}
+	e = &NativeEngine{twins: twins}
+	require.Error(t, e.verify(&dl))
+}
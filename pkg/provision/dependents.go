@@ -0,0 +1,82 @@
+package provision
+
+import (
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+// machineReferences returns the names of all workloads (networks, public ips, and mounts)
+// referenced by a vm workload. Returns nil if workload is not a vm
+func machineReferences(wl *gridtypes.Workload) ([]gridtypes.Name, error) {
+	if wl.Type != zos.ZMachineType && wl.Type != zos.ZMachineLightType {
+		return nil, nil
+	}
+
+	data, err := wl.WorkloadData()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []gridtypes.Name
+	switch vm := data.(type) {
+	case *zos.ZMachine:
+		for _, inf := range vm.Network.Interfaces {
+			refs = append(refs, inf.Network)
+		}
+		if len(vm.Network.PublicIP) != 0 {
+			refs = append(refs, vm.Network.PublicIP)
+		}
+		for _, mnt := range vm.Mounts {
+			refs = append(refs, mnt.Name)
+		}
+	case *zos.ZMachineLight:
+		for _, inf := range vm.Network.Interfaces {
+			refs = append(refs, inf.Network)
+		}
+		for _, mnt := range vm.Mounts {
+			refs = append(refs, mnt.Name)
+		}
+	}
+
+	return refs, nil
+}
+
+// dependents returns the names of all the active workloads in the deployment
+// that depend (directly or indirectly) on the workload with the given name.
+func dependents(dl *gridtypes.Deployment, name gridtypes.Name) ([]gridtypes.Name, error) {
+	// users maps a workload name to all workloads using it
+	users := make(map[gridtypes.Name][]gridtypes.Name)
+	for i := range dl.Workloads {
+		wl := &dl.Workloads[i]
+		if wl.Result.State.IsAny(gridtypes.StateDeleted, gridtypes.StateError) {
+			continue
+		}
+
+		refs, err := machineReferences(wl)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ref := range refs {
+			users[ref] = append(users[ref], wl.Name)
+		}
+	}
+
+	var result []gridtypes.Name
+	seen := map[gridtypes.Name]struct{}{name: {}}
+	queue := []gridtypes.Name{name}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, user := range users[current] {
+			if _, ok := seen[user]; ok {
+				continue
+			}
+			seen[user] = struct{}{}
+			result = append(result, user)
+			queue = append(queue, user)
+		}
+	}
+
+	return result, nil
+}
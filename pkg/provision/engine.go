@@ -7,14 +7,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff/v3"
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-retryablehttp"
-	"github.com/joncrlsn/dque"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
@@ -62,8 +61,53 @@ func WithRerunAll(t bool) EngineOption {
 	return &withRerunAll{t}
 }
 
+// WithDriftRepair enables automatic repair of workloads that are recorded
+// as ok in storage but are not actually running anymore (as reported by the
+// detector). The engine checks all active workloads every interval and
+// re-installs the drifted ones. A workload is never repaired more than once
+// per cooldown period.
+func WithDriftRepair(detector DriftDetector, interval, cooldown time.Duration) EngineOption {
+	return &withDriftRepair{detector, interval, cooldown}
+}
+
+// WithDeploymentLimits overrides the default limits on the size of a
+// deployment. Deployments that exceed the limits are rejected.
+func WithDeploymentLimits(limits DeploymentLimits) EngineOption {
+	return &withDeploymentLimits{limits}
+}
+
+// WithTwinQuotas sets the quotas of the twins on the node. Deployments that
+// would make a twin exceed its quota are rejected, admin twins are exempt.
+func WithTwinQuotas(quotas TwinQuotas) EngineOption {
+	return &withTwinQuotas{quotas}
+}
+
+// WithStorageCheck makes the engine check that the zmounts and volumes of a
+// new or updated deployment fit in the free storage of the node pools before
+// any of its workloads is installed.
+func WithStorageCheck(space StorageSpace) EngineOption {
+	return &withStorageCheck{space}
+}
+
 type Callback func(twin uint32, contract uint64, delete bool)
 
+// WithMaxConcurrency sets how many workloads of the same type are installed
+// concurrently when a deployment is installed. Types are still installed one
+// after the other in the engine order, and network workloads are always
+// installed one at a time since other workloads depend on them. Defaults to 1
+func WithMaxConcurrency(n int) EngineOption {
+	return &withMaxConcurrency{n}
+}
+
+// WithJobTimeout sets the deadline of each workload provision, update or
+// restart of a job. A workload that is not provisioned before its deadline
+// is set to error and the engine moves to the next workload, the other
+// workloads of the job are not affected. Defaults to DefaultJobTimeout, 0
+// disables the deadline.
+func WithJobTimeout(d time.Duration) EngineOption {
+	return &withJobTimeout{d}
+}
+
 // WithCallback sets a callback that is called when a deployment is being Created, Updated, Or Deleted
 // The handler then can use the id to get current "state" of the deployment from storage and
 // take proper action. A callback must not block otherwise the engine operation will get blocked
@@ -92,10 +136,29 @@ const (
 	opPause
 	// opResume resumes a deployment
 	opResume
+	// opDeprovisionWorkloads removes only the workloads in the
+	// target deployment, the deployment itself is kept
+	opDeprovisionWorkloads
+	// opResync re-applies the system configuration of the
+	// target workloads, nothing is changed in storage
+	opResync
+	// opRestart deprovisions then provisions again the
+	// target workloads
+	opRestart
 	// servers default timeout
 	defaultHttpTimeout = 10 * time.Second
 )
 
+const (
+	// DefaultJobTimeout is the default deadline of a workload provision, update
+	// or restart
+	DefaultJobTimeout = 5 * time.Minute
+)
+
+// ErrProvisionTimeout is the error of workloads that were not provisioned
+// before their job deadline
+var ErrProvisionTimeout = fmt.Errorf("provisioning timed out")
+
 // engineJob is a persisted job instance that is
 // stored in a queue. the queue uses a GOB encoder
 // so please make sure that edits to this struct is
@@ -106,6 +169,21 @@ type engineJob struct {
 	Target  gridtypes.Deployment
 	Source  *gridtypes.Deployment
 	Message string
+	// Boot is set on jobs queued by the boot reconcile
+	Boot bool
+	// Enqueued is when the job was pushed to the queue
+	Enqueued time.Time
+	// Attempts is the number of previous attempts of the job that
+	// had retryable failures
+	Attempts int
+	// NotBefore is when a retried job is due, the job is not processed
+	// before that time
+	NotBefore time.Time
+}
+
+// deployment returns the key of the job target deployment
+func (j *engineJob) deployment() deploymentValue {
+	return deploymentValue{j.Target.TwinID, j.Target.ContractID}
 }
 
 // NativeEngine is the core of this package
@@ -114,7 +192,10 @@ type NativeEngine struct {
 	storage     Storage
 	provisioner Provisioner
 
-	queue *dque.DQue
+	queues      *engineQueues
+	queueCfg    QueueConfig
+	namedQueues []QueueConfig
+	selector    QueueSelector
 
 	// options
 	// janitor Janitor
@@ -123,10 +204,50 @@ type NativeEngine struct {
 	order     []gridtypes.WorkloadType
 	typeIndex map[gridtypes.WorkloadType]int
 	rerunAll  bool
+
+	// orderM protects order changes at runtime, the order is only
+	// replaced by the engine loop between jobs.
+	orderM       sync.RWMutex
+	pendingOrder []gridtypes.WorkloadType
+	pendingIndex map[gridtypes.WorkloadType]int
 	// substrate specific attributes
 	nodeID           uint32
 	substrateGateway *stubs.SubstrateGatewayStub
 	callback         Callback
+
+	repair *driftRepair
+	limits DeploymentLimits
+	quotas TwinQuotas
+	space  StorageSpace
+
+	// concurrency is the max number of workloads of the same type
+	// installed concurrently
+	concurrency int
+	// txM serializes the storage writes of concurrent installations
+	txM sync.Mutex
+	// jobTimeout is the deadline of each workload provision, update or restart
+	jobTimeout time.Duration
+	// retryAttempts is the max number of attempts of a provision job with
+	// retryable failures, retryInterval is the delay of the first retry
+	retryAttempts int
+	retryInterval time.Duration
+
+	// updatesM protects updates, the target version of the update job
+	// queued for each deployment
+	updatesM sync.Mutex
+	updates  map[deploymentValue]uint32
+
+	// lateM protects late, the workloads of timed out provisions that
+	// are still running
+	lateM sync.Mutex
+	late  map[gridtypes.WorkloadID]struct{}
+
+	reconcile bootReconcile
+	publicIPs publicIPIndex
+	summary   summaryCache
+
+	eventsM sync.Mutex
+	events  chan DeploymentEvent
 }
 
 var (
@@ -165,26 +286,42 @@ type withStartupOrder struct {
 }
 
 func (w *withStartupOrder) apply(e *NativeEngine) {
+	order, index, err := orderTypes(e.order, w.o)
+	if err != nil {
+		panic(err)
+	}
+
+	e.order = order
+	e.typeIndex = index
+}
+
+// orderTypes builds a new types order with the given types first, followed
+// by the rest of the known types.
+func orderTypes(known, first []gridtypes.WorkloadType) ([]gridtypes.WorkloadType, map[gridtypes.WorkloadType]int, error) {
 	all := make(map[gridtypes.WorkloadType]struct{})
-	for _, typ := range e.order {
+	for _, typ := range known {
 		all[typ] = struct{}{}
 	}
 	ordered := make([]gridtypes.WorkloadType, 0, len(all))
-	for _, typ := range w.o {
+	index := make(map[gridtypes.WorkloadType]int)
+	for _, typ := range first {
 		if _, ok := all[typ]; !ok {
-			panic(fmt.Sprintf("type '%s' is not registered", typ))
+			return nil, nil, fmt.Errorf("type '%s' is not registered", typ)
 		}
 		delete(all, typ)
 		ordered = append(ordered, typ)
-		e.typeIndex[typ] = len(ordered)
+		index[typ] = len(ordered)
 	}
 	// now move everything else
-	for typ := range all {
+	for _, typ := range known {
+		if _, ok := all[typ]; !ok {
+			continue
+		}
 		ordered = append(ordered, typ)
-		e.typeIndex[typ] = len(ordered)
+		index[typ] = len(ordered)
 	}
 
-	e.order = ordered
+	return ordered, index, nil
 }
 
 type withRerunAll struct {
@@ -203,6 +340,56 @@ func (w *withCallback) apply(e *NativeEngine) {
 	e.callback = w.cb
 }
 
+type withDriftRepair struct {
+	detector DriftDetector
+	interval time.Duration
+	cooldown time.Duration
+}
+
+func (w *withDriftRepair) apply(e *NativeEngine) {
+	e.repair = newDriftRepair(w.detector, w.interval, w.cooldown)
+}
+
+type withDeploymentLimits struct {
+	limits DeploymentLimits
+}
+
+func (w *withDeploymentLimits) apply(e *NativeEngine) {
+	e.limits = w.limits
+}
+
+type withStorageCheck struct {
+	space StorageSpace
+}
+
+func (w *withStorageCheck) apply(e *NativeEngine) {
+	e.space = w.space
+}
+
+type withJobTimeout struct {
+	d time.Duration
+}
+
+func (w *withJobTimeout) apply(e *NativeEngine) {
+	e.jobTimeout = w.d
+}
+
+type withMaxConcurrency struct {
+	n int
+}
+
+func (w *withMaxConcurrency) apply(e *NativeEngine) {
+	e.concurrency = w.n
+}
+
+type withTwinQuotas struct {
+	quotas TwinQuotas
+}
+
+func (w *withTwinQuotas) apply(e *NativeEngine) {
+	e.quotas = w.quotas
+}
+
 type nullKeyGetter struct{}
 
 func (n *nullKeyGetter) GetKey(id uint32) ([]byte, error) {
@@ -308,28 +495,46 @@ func New(storage Storage, provisioner Provisioner, root string, opts ...EngineOp
 		admins:      &nullKeyGetter{},
 		order:       gridtypes.Types(),
 		typeIndex:   make(map[gridtypes.WorkloadType]int),
+		limits:      DefaultDeploymentLimits,
+		concurrency: 1,
+		jobTimeout:  DefaultJobTimeout,
+
+		retryAttempts: DefaultRetryAttempts,
+		retryInterval: DefaultRetryInterval,
+		queueCfg:      QueueConfig{Name: DefaultQueue, SegmentSize: DefaultQueueSegmentSize},
 	}
 
 	for _, opt := range opts {
 		opt.apply(e)
 	}
 
-	if e.rerunAll {
-		os.RemoveAll(filepath.Join(root, "jobs"))
-	}
-
-	queue, err := dque.NewOrOpen("jobs", root, 512, func() interface{} { return &engineJob{} })
+	// the queues are dropped on rerun all since all active
+	// deployments are queued again by boot anyway
+	queues, err := openQueues(root, e.rerunAll, append([]QueueConfig{e.queueCfg}, e.namedQueues...)...)
 	if err != nil {
-		// if this happens it means data types has been changed in that case we need
-		// to clean up the queue and start over. unfortunately any un applied changes
-		os.RemoveAll(filepath.Join(root, "jobs"))
-		return nil, errors.Wrap(err, "failed to create job queue")
+		return nil, err
 	}
 
-	e.queue = queue
+	e.queues = queues
 	return e, nil
 }
 
+// enqueue pushes the job to the queue selected for its deployment
+func (e *NativeEngine) enqueue(ctx context.Context, job *engineJob) error {
+	var name string
+	if e.selector != nil {
+		name = e.selector(ctx, job.Target.TwinID, job.Target.ContractID)
+	}
+
+	return e.queues.push(name, job)
+}
+
+// QueueStats returns the number of pending jobs in the engine queues, and
+// when the oldest of them was queued. The queues are not modified.
+func (e *NativeEngine) QueueStats() (pending int, oldest time.Time, err error) {
+	return e.queues.stats()
+}
+
 // Storage returns
 func (e *NativeEngine) Storage() Storage {
 	return e.storage
@@ -365,7 +570,7 @@ func (e *NativeEngine) Provision(ctx context.Context, deployment gridtypes.Deplo
 		Op:     opProvision,
 	}
 
-	return e.queue.Enqueue(&job)
+	return e.enqueue(ctx, &job)
 }
 
 // Pause deployment
@@ -385,7 +590,7 @@ func (e *NativeEngine) Pause(ctx context.Context, twin uint32, id uint64) error
 		Op:     opPause,
 	}
 
-	return e.queue.Enqueue(&job)
+	return e.enqueue(ctx, &job)
 }
 
 // Resume deployment
@@ -405,7 +610,7 @@ func (e *NativeEngine) Resume(ctx context.Context, twin uint32, id uint64) error
 		Op:     opResume,
 	}
 
-	return e.queue.Enqueue(&job)
+	return e.enqueue(ctx, &job)
 }
 
 // Deprovision workload
@@ -427,20 +632,15 @@ func (e *NativeEngine) Deprovision(ctx context.Context, twin uint32, id uint64,
 		Message: reason,
 	}
 
-	return e.queue.Enqueue(&job)
+	return e.enqueue(ctx, &job)
 }
 
-// Update workloads
-func (e *NativeEngine) Update(ctx context.Context, update gridtypes.Deployment) error {
-	deployment, err := e.storage.Get(update.TwinID, update.ContractID)
-	if err != nil {
-		return err
-	}
-
+// checkUpgrade makes sure the update can be applied to the current deployment
+func (e *NativeEngine) checkUpgrade(ctx context.Context, current, update *gridtypes.Deployment) error {
 	// this will just calculate the update
 	// steps we run it here as a sort of validation
 	// that this update is acceptable.
-	upgrades, err := deployment.Upgrade(&update)
+	upgrades, err := current.Upgrade(update)
 	if err != nil {
 		return errors.Wrap(ErrDeploymentUpgradeValidationError, err.Error())
 	}
@@ -456,9 +656,28 @@ func (e *NativeEngine) Update(ctx context.Context, update gridtypes.Deployment)
 		}
 	}
 
-	// fields to update in storage
+	return nil
+}
+
+// Update workloads
+func (e *NativeEngine) Update(ctx context.Context, update gridtypes.Deployment) error {
+	deployment, err := e.storage.Get(update.TwinID, update.ContractID)
+	if err != nil {
+		return err
+	}
+
+	if err := e.checkUpgrade(ctx, &deployment, &update); err != nil {
+		return err
+	}
+
+	key := deploymentValue{update.TwinID, update.ContractID}
+	if !e.startUpdate(key, update.Version) {
+		return errors.Wrapf(ErrInvalidVersion, "an update to version %d is already queued", update.Version)
+	}
+
+	// fields to update in storage. the version is only set by the
+	// engine once all the update operations are applied
 	fields := []Field{
-		VersionField{update.Version},
 		SignatureRequirementField{update.SignatureRequirement},
 	}
 
@@ -470,6 +689,7 @@ func (e *NativeEngine) Update(ctx context.Context, update gridtypes.Deployment)
 	}
 	// update deployment fields, workloads will then can get updated separately
 	if err := e.storage.Update(update.TwinID, update.ContractID, fields...); err != nil {
+		e.endUpdate(key, update.Version)
 		return errors.Wrap(err, "failed to update deployment data")
 	}
 	// all is okay we can push the job
@@ -479,12 +699,46 @@ func (e *NativeEngine) Update(ctx context.Context, update gridtypes.Deployment)
 		Source: &deployment,
 	}
 
-	return e.queue.Enqueue(&job)
+	if err := e.enqueue(ctx, &job); err != nil {
+		e.endUpdate(key, update.Version)
+		return err
+	}
+
+	return nil
+}
+
+// startUpdate records the version of a queued update of the deployment, it
+// returns false if an update to the same (or a newer) version is already
+// queued. The stored version is checked by the upgrade validation.
+func (e *NativeEngine) startUpdate(key deploymentValue, version uint32) bool {
+	e.updatesM.Lock()
+	defer e.updatesM.Unlock()
+
+	if e.updates == nil {
+		e.updates = make(map[deploymentValue]uint32)
+	}
+
+	if queued, ok := e.updates[key]; ok && queued >= version {
+		return false
+	}
+
+	e.updates[key] = version
+	return true
+}
+
+// endUpdate forgets the queued update of the deployment once it's processed
+func (e *NativeEngine) endUpdate(key deploymentValue, version uint32) {
+	e.updatesM.Lock()
+	defer e.updatesM.Unlock()
+
+	if e.updates[key] == version {
+		delete(e.updates, key)
+	}
 }
 
 // Run starts reader reservation from the Source and handle them
 func (e *NativeEngine) Run(root context.Context) error {
-	defer e.queue.Close()
+	defer e.queues.close()
 
 	root = context.WithValue(root, engineKey{}, e)
 
@@ -494,20 +748,36 @@ func (e *NativeEngine) Run(root context.Context) error {
 		}
 	}
 
+	if e.repair != nil {
+		go e.repairLoop(root)
+	}
+
 	for {
-		obj, err := e.queue.PeekBlock()
-		if err != nil {
+		queue, job, err := e.queues.peek(root)
+		if root.Err() != nil {
+			return nil
+		} else if err != nil {
 			log.Error().Err(err).Msg("failed to check job queue")
 			<-time.After(2 * time.Second)
 			continue
 		}
 
-		job := obj.(*engineJob)
+		e.applyPendingOrder()
+
 		ctx := withDeployment(root, job.Target.TwinID, job.Target.ContractID)
+		if job.Boot {
+			ctx = withBoot(ctx)
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		retry := e.jobRetry(job)
+		if retry != nil {
+			ctx = withRetry(ctx, retry)
+		}
 		l := log.With().
 			Uint32("twin", job.Target.TwinID).
 			Uint64("contract", job.Target.ContractID).
 			Logger()
+		states := e.workloadStates(job.Target.TwinID, job.Target.ContractID)
 
 		// contract validation
 		// this should ONLY be done on provosion and update operation
@@ -516,13 +786,24 @@ func (e *NativeEngine) Run(root context.Context) error {
 			job.Op == opProvisionNoValidation {
 			// otherwise, contract validation is needed
 			ctx, err = e.validate(ctx, &job.Target, job.Op == opProvisionNoValidation)
+			if err == nil && job.Op == opProvision {
+				// only new deployments, storage of re-installed
+				// deployments is already allocated
+				err = e.checkFreeStorage(ctx, &job.Target)
+			} else if err == nil && job.Op == opUpdate {
+				err = e.checkUpdateStorage(ctx, &job.Target)
+			}
 			if err != nil {
 				l.Error().Err(err).Msg("contact validation fails")
+				e.reconcileFailed(ctx, &job.Target, err)
 				// job.Target.SetError(err)
 				if err := e.storage.Error(job.Target.TwinID, job.Target.ContractID, err); err != nil {
 					l.Error().Err(err).Msg("failed to set deployment global error")
 				}
-				_, _ = e.queue.Dequeue()
+				_ = e.queues.dequeue(queue)
+				cancel()
+				e.indexPublicIPs(job.Target.TwinID, job.Target.ContractID)
+				e.emit(job, states)
 
 				continue
 			}
@@ -537,10 +818,16 @@ func (e *NativeEngine) Run(root context.Context) error {
 			e.installDeployment(ctx, &job.Target)
 		case opDeprovision:
 			e.uninstallDeployment(ctx, &job.Target, job.Message)
+		case opDeprovisionWorkloads:
+			e.uninstallWorkloads(ctx, &job.Target, job.Message)
 		case opPause:
 			e.lockDeployment(ctx, &job.Target)
 		case opResume:
 			e.unlockDeployment(ctx, &job.Target)
+		case opResync:
+			e.resyncWorkloads(ctx, &job.Target)
+		case opRestart:
+			e.restartWorkloads(ctx, &job.Target)
 		case opUpdate:
 			// update is tricky because we need to work against
 			// 2 versions of the object. Once that reflects the current state
@@ -554,23 +841,38 @@ func (e *NativeEngine) Run(root context.Context) error {
 			// - things that is not in any of the 3 lists are basically stay as is
 			// the call will also make sure the Result of those workload in both the (did not change)
 			// and update to reflect the current result on those workloads.
-			update, err := job.Source.Upgrade(&job.Target)
-			if err != nil {
-				l.Error().Err(err).Msg("failed to get update procedure")
-				break
-			}
-			e.updateDeployment(ctx, update)
+			// the procedure is computed against the current state, the
+			// stored deployment might have changed since the job was queued
+			e.runUpdate(ctx, job)
+			e.endUpdate(job.deployment(), job.Target.Version)
 		}
 
-		_, err = e.queue.Dequeue()
-		if err != nil {
+		cancel()
+		if retry.retried() {
+			e.requeue(queue.name, *job)
+		}
+
+		if err := e.queues.dequeue(queue); err != nil {
 			l.Error().Err(err).Msg("failed to dequeue job")
 		}
 
+		e.indexPublicIPs(job.Target.TwinID, job.Target.ContractID)
+		e.emit(job, states)
 		e.safeCallback(&job.Target, job.Op == opDeprovision)
 	}
 }
 
+// workloadContext returns the context of a single workload provision, update
+// or restart, it runs under the engine deadline so a slow workload never
+// affects the other workloads of the job
+func (e *NativeEngine) workloadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.jobTimeout > 0 {
+		return context.WithTimeout(ctx, e.jobTimeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
 func (e *NativeEngine) safeCallback(d *gridtypes.Deployment, delete bool) {
 	if e.callback == nil {
 		return
@@ -613,25 +915,109 @@ func (e *NativeEngine) validate(ctx context.Context, dl *gridtypes.Deployment, n
 		return ctx, nil
 	}
 
-	if uint32(contract.ContractType.NodeContract.Node) != e.nodeID {
-		return nil, fmt.Errorf("invalid node address in contract")
+	if err := e.validateNode(&contract.ContractType.NodeContract); err != nil {
+		return nil, err
+	}
+
+	if err := validateHash(dl, &contract.ContractType.NodeContract); err != nil {
+		return nil, err
 	}
 
+	return ctx, nil
+}
+
+// validateNode makes sure the contract is for this node
+func (e *NativeEngine) validateNode(contract *substrate.NodeContract) error {
+	if uint32(contract.Node) != e.nodeID {
+		return fmt.Errorf("invalid node address in contract")
+	}
+
+	return nil
+}
+
+// validateHash makes sure the contract hash matches the deployment
+func validateHash(dl *gridtypes.Deployment, contract *substrate.NodeContract) error {
 	hash, err := dl.ChallengeHash()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to compute deployment hash")
+		return errors.Wrap(err, "failed to compute deployment hash")
 	}
 
-	if contract.ContractType.NodeContract.DeploymentHash.String() != hex.EncodeToString(hash) {
-		return nil, fmt.Errorf("contract hash does not match deployment hash")
+	if contract.DeploymentHash.String() != hex.EncodeToString(hash) {
+		return fmt.Errorf("contract hash does not match deployment hash")
 	}
 
-	return ctx, nil
+	return nil
+}
+
+// ValidationState checks a stored deployment against its contract with the same
+// checks used by the engine before applying a deployment, and reports the first
+// failing condition. Nothing is changed on the deployment.
+func (e *NativeEngine) ValidationState(twin uint32, contractID uint64) (pkg.ValidationState, error) {
+	var state pkg.ValidationState
+
+	dl, err := e.storage.Get(twin, contractID)
+	if errors.Is(err, ErrDeploymentNotExists) {
+		return state, fmt.Errorf("deployment not found")
+	} else if err != nil {
+		return state, err
+	}
+
+	if e.substrateGateway == nil {
+		return state, fmt.Errorf("substrate is not configured in engine")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	fail := func(err error) {
+		if len(state.Error) == 0 {
+			state.Error = err.Error()
+		}
+	}
+
+	contract, subErr := e.substrateGateway.GetContract(ctx, dl.ContractID)
+	if subErr.IsError() {
+		fail(errors.Wrap(subErr.Err, "failed to get deployment contract"))
+	} else if !contract.ContractType.IsNodeContract {
+		fail(fmt.Errorf("invalid contract type, expecting node contract"))
+	} else {
+		state.ContractExists = true
+		nodeContract := &contract.ContractType.NodeContract
+
+		if err := e.validateNode(nodeContract); err != nil {
+			fail(err)
+		} else {
+			state.NodeMatches = true
+		}
+
+		if err := validateHash(&dl, nodeContract); err != nil {
+			fail(err)
+		} else {
+			state.HashMatches = true
+		}
+	}
+
+	if ok, err := isTwinVerified(twin); err != nil {
+		fail(err)
+	} else if !ok {
+		fail(fmt.Errorf("user with twin id %d is not verified", twin))
+	} else {
+		state.TwinVerified = true
+	}
+
+	state.Valid = len(state.Error) == 0
+	return state, nil
 }
 
 // boot will make sure to re-deploy all stored reservation
 // on boot.
 func (e *NativeEngine) boot(root context.Context) error {
+	e.reconcile.reset()
+
+	if err := e.rebuildPublicIPs(); err != nil {
+		log.Error().Err(err).Msg("failed to build public ips index")
+	}
+
 	storage := e.Storage()
 	twins, err := storage.Twins()
 	if err != nil {
@@ -660,9 +1046,10 @@ func (e *NativeEngine) boot(root context.Context) error {
 			job := engineJob{
 				Target: dl,
 				Op:     opProvisionNoValidation,
+				Boot:   true,
 			}
 
-			if err := e.queue.Enqueue(&job); err != nil {
+			if err := e.enqueue(root, &job); err != nil {
 				log.Error().
 					Err(err).
 					Uint32("twin", dl.TwinID).
@@ -727,19 +1114,29 @@ func (e *NativeEngine) installWorkload(ctx context.Context, wl *gridtypes.Worklo
 		// this can happen if installWorkload was called upon a deployment update operation
 		// so this is a totally new workload that was not part of the original deployment
 		// hence a call to Add is needed
-		if err := e.storage.Add(twin, deployment, *wl.Workload); err != nil {
-			return errors.Wrap(err, "failed to add workload to storage")
+		if err := e.add(twin, deployment, *wl.Workload); err != nil {
+			err = errors.Wrap(err, "failed to add workload to storage")
+			e.reconciled(ctx, wl, pkg.ReconcileFailed, err.Error())
+			return err
 		}
 	} else if err != nil {
 		// another error
-		return errors.Wrapf(err, "failed to get last transaction for '%s'", wl.ID.String())
+		err = errors.Wrapf(err, "failed to get last transaction for '%s'", wl.ID.String())
+		e.reconciled(ctx, wl, pkg.ReconcileFailed, err.Error())
+		return err
 	} else {
 		// workload exists, but we trying to re-install it so this might be
 		// after a reboot. hence we need to check last state.
 		// if it has been deleted,  error state, we do nothing.
 		// otherwise, we-reinstall it
-		if current.Result.State.IsAny(gridtypes.StateDeleted, gridtypes.StateError) {
+		switch current.Result.State {
+		case gridtypes.StateDeleted:
+			// nothing to do!
+			e.reconciled(ctx, wl, pkg.ReconcileSkippedDeleted, "")
+			return nil
+		case gridtypes.StateError:
 			// nothing to do!
+			e.reconciled(ctx, wl, pkg.ReconcileSkippedError, current.Result.Error)
 			return nil
 		}
 	}
@@ -752,26 +1149,153 @@ func (e *NativeEngine) installWorkload(ctx context.Context, wl *gridtypes.Worklo
 		Logger()
 
 	log.Debug().Msg("provisioning")
-	result, err := e.provisioner.Provision(ctx, wl)
+	result, err := e.provision(ctx, wl)
 	if errors.Is(err, ErrNoActionNeeded) {
 		// workload already exist, so no need to create a new transaction
+		e.reconciled(ctx, wl, pkg.ReconcileReinstalled, "")
+		return nil
+	} else if errors.Is(err, ErrRetryable) && getRetry(ctx).shouldRetry() {
+		// the workload is kept as is, the deployment is installed again later
+		log.Warn().Err(err).Int("attempt", getRetry(ctx).attempts()).Msg("workload failed with a retryable error")
 		return nil
 	} else if err != nil {
 		result.Created = gridtypes.Now()
 		result.State = gridtypes.StateError
 		result.Error = err.Error()
+		if errors.Is(err, ErrRetryable) && getRetry(ctx).attempts() > 1 {
+			result.Attempts = getRetry(ctx).attempts()
+		}
 	}
 
 	if result.State == gridtypes.StateError {
 		log.Error().Str("error", result.Error).Msg("failed to deploy workload")
+		e.reconciled(ctx, wl, pkg.ReconcileFailed, result.Error)
+	} else {
+		e.reconciled(ctx, wl, pkg.ReconcileReinstalled, "")
 	}
 
-	return e.storage.Transaction(
+	return e.transaction(
 		twin,
 		deployment,
 		wl.WithResults(result))
 }
 
+// provision calls the provisioner, but gives up once the workload deadline
+// is reached even if the provisioner does not honor the context, so a hung
+// workload can't block the engine forever. The workload of a timed out
+// provision is deprovisioned once the provisioner returns, and can't be
+// provisioned again until then.
+func (e *NativeEngine) provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return gridtypes.Result{}, provisionError(ctx, wl)
+	}
+
+	ctx, cancel := e.workloadContext(ctx)
+	defer cancel()
+
+	if e.isLate(wl.ID) {
+		return gridtypes.Result{}, errors.Wrap(ErrRetryable, "a timed out provision of the workload is still running")
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		return e.provisioner.Provision(ctx, wl)
+	}
+
+	type provisioned struct {
+		result gridtypes.Result
+		err    error
+	}
+
+	ch := make(chan provisioned, 1)
+	go func() {
+		result, err := e.provisioner.Provision(ctx, wl)
+		ch <- provisioned{result, err}
+	}()
+
+	select {
+	case out := <-ch:
+		return out.result, out.err
+	case <-ctx.Done():
+		e.setLate(wl.ID, true)
+		go func() {
+			defer e.setLate(wl.ID, false)
+			out := <-ch
+			e.dropLate(wl, out.result, out.err)
+		}()
+
+		return gridtypes.Result{}, provisionError(ctx, wl)
+	}
+}
+
+// provisionError is the error of a provision that gave up because the
+// context is done
+func provisionError(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Error().Stringer("id", wl.ID).Msg("workload provisioning timed out")
+		return ErrProvisionTimeout
+	}
+
+	return ctx.Err()
+}
+
+// isLate checks if a timed out provision of the workload is still running
+func (e *NativeEngine) isLate(id gridtypes.WorkloadID) bool {
+	e.lateM.Lock()
+	defer e.lateM.Unlock()
+
+	_, ok := e.late[id]
+	return ok
+}
+
+func (e *NativeEngine) setLate(id gridtypes.WorkloadID, late bool) {
+	e.lateM.Lock()
+	defer e.lateM.Unlock()
+
+	if !late {
+		delete(e.late, id)
+		return
+	}
+
+	if e.late == nil {
+		e.late = make(map[gridtypes.WorkloadID]struct{})
+	}
+	e.late[id] = struct{}{}
+}
+
+// dropLate releases the resources of a timed out provision that finished
+// after the workload was already set in error state. Nothing is released if
+// the workload already existed (ErrNoActionNeeded), since it was not
+// allocated by the timed out provision.
+func (e *NativeEngine) dropLate(wl *gridtypes.WorkloadWithID, result gridtypes.Result, err error) {
+	if err != nil || result.State == gridtypes.StateError {
+		// nothing was allocated
+		return
+	}
+
+	log.Warn().Stringer("id", wl.ID).Msg("timed out provision finished late, deprovisioning workload")
+	if err := e.provisioner.Deprovision(context.Background(), wl); err != nil {
+		log.Error().Err(err).Stringer("id", wl.ID).Msg("failed to deprovision late workload")
+	}
+}
+
+// add adds a workload to the deployment in storage, it's safe to call from
+// concurrent installations
+func (e *NativeEngine) add(twin uint32, deployment uint64, wl gridtypes.Workload) error {
+	e.txM.Lock()
+	defer e.txM.Unlock()
+
+	return e.storage.Add(twin, deployment, wl)
+}
+
+// transaction appends a workload transaction in storage, it's safe to call
+// from concurrent installations
+func (e *NativeEngine) transaction(twin uint32, deployment uint64, wl gridtypes.Workload) error {
+	e.txM.Lock()
+	defer e.txM.Unlock()
+
+	return e.storage.Transaction(twin, deployment, wl)
+}
+
 func (e *NativeEngine) updateWorkload(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
 	twin, deployment, name, _ := wl.ID.Parts()
 	log := log.With().
@@ -786,7 +1310,9 @@ func (e *NativeEngine) updateWorkload(ctx context.Context, wl *gridtypes.Workloa
 	var result gridtypes.Result
 	var err error
 	if e.provisioner.CanUpdate(ctx, wl.Type) {
-		result, err = e.provisioner.Update(ctx, wl)
+		updateCtx, cancel := e.workloadContext(ctx)
+		result, err = e.provisioner.Update(updateCtx, wl)
+		cancel()
 	} else {
 		// deprecated. We should never update resources by decommission and then provision
 		// the check in Update method should prevent this
@@ -880,6 +1406,20 @@ func (e *NativeEngine) uninstallDeployment(ctx context.Context, dl *gridtypes.De
 	}
 }
 
+// uninstallWorkloads uninstalls all workloads in the deployment in reverse type
+// order without deleting the deployment
+func (e *NativeEngine) uninstallWorkloads(ctx context.Context, dl *gridtypes.Deployment, reason string) {
+	for i := len(e.order) - 1; i >= 0; i-- {
+		typ := e.order[i]
+
+		for _, wl := range dl.ByType(typ) {
+			if err := e.uninstallWorkload(ctx, wl, reason); err != nil {
+				log.Error().Err(err).Stringer("id", wl.ID).Msg("failed to un-install workload")
+			}
+		}
+	}
+}
+
 func getMountSize(wl *gridtypes.Workload) (gridtypes.Unit, error) {
 	data, err := wl.WorkloadData()
 	if err != nil {
@@ -919,14 +1459,59 @@ func (e *NativeEngine) installDeployment(ctx context.Context, getter gridtypes.W
 			sortMountWorkloads(workloads)
 		}
 
-		for _, wl := range workloads {
-			if err := e.installWorkload(ctx, wl); err != nil {
-				log.Error().Err(err).Stringer("id", wl.ID).Msg("failed to install workload")
-			}
+		if err := e.installWorkloads(ctx, typ, workloads); err != nil {
+			log.Error().Err(err).Str("type", typ.String()).Msg("failed to install workloads")
 		}
 	}
 }
 
+// installWorkloads installs workloads of the same type, up to the engine
+// concurrency at a time. Workloads are started in the given order, and a
+// failing workload never stops the others. The errors of all failed
+// workloads are returned.
+func (e *NativeEngine) installWorkloads(ctx context.Context, typ gridtypes.WorkloadType, workloads []*gridtypes.WorkloadWithID) error {
+	workers := e.concurrency
+	if workers < 1 || typ == zos.NetworkType || typ == zos.NetworkLightType {
+		workers = 1
+	}
+
+	if workers > len(workloads) {
+		workers = len(workloads)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		m    sync.Mutex
+		errs error
+	)
+
+	ch := make(chan *gridtypes.WorkloadWithID)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for wl := range ch {
+				if err := e.installWorkload(ctx, wl); err != nil {
+					log.Error().Err(err).Stringer("id", wl.ID).Msg("failed to install workload")
+
+					m.Lock()
+					errs = multierror.Append(errs, errors.Wrapf(err, "workload '%s'", wl.ID))
+					m.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, wl := range workloads {
+		ch <- wl
+	}
+
+	close(ch)
+	wg.Wait()
+
+	return errs
+}
+
 func (e *NativeEngine) lockDeployment(ctx context.Context, getter gridtypes.WorkloadGetter) {
 	for i := len(e.order) - 1; i >= 0; i-- {
 		typ := e.order[i]
@@ -971,26 +1556,181 @@ func (e *NativeEngine) sortOperations(ops []gridtypes.UpgradeOp) {
 	})
 }
 
-func (e *NativeEngine) updateDeployment(ctx context.Context, ops []gridtypes.UpgradeOp) (changed bool) {
+// runUpdate computes the update procedure against the stored deployment and
+// applies it
+func (e *NativeEngine) runUpdate(ctx context.Context, job *engineJob) {
+	l := log.With().
+		Uint32("twin", job.Target.TwinID).
+		Uint64("contract", job.Target.ContractID).
+		Uint32("version", job.Target.Version).
+		Logger()
+
+	current, err := e.storage.Get(job.Target.TwinID, job.Target.ContractID)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get deployment")
+		return
+	}
+
+	update, err := current.Upgrade(&job.Target)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get update procedure")
+		return
+	}
+
+	e.retriedOps(update)
+	applied, failed := e.updateDeployment(ctx, update)
+	if err := e.finishUpdate(&job.Target, applied, failed); err != nil {
+		l.Error().Err(err).Msg("failed to set deployment version")
+	}
+}
+
+// retriedOps restores the operation type of workloads that were added by a
+// previous attempt of the same update. Such workloads are already stored with
+// the target version so the procedure sees them as updated, but they must be
+// installed again instead.
+func (e *NativeEngine) retriedOps(ops []gridtypes.UpgradeOp) {
+	var changes []gridtypes.Workload
+	for i := range ops {
+		op := &ops[i]
+		if op.Op != gridtypes.OpUpdate {
+			continue
+		}
+
+		twin, deployment, name, _ := op.WlID.ID.Parts()
+		if changes == nil {
+			var err error
+			changes, err = e.storage.Changes(twin, deployment)
+			if err != nil {
+				log.Error().Err(err).Uint32("twin", twin).Uint64("contract", deployment).Msg("failed to get deployment changes")
+				return
+			}
+		}
+
+		for _, change := range changes {
+			if change.Name != name {
+				continue
+			}
+
+			// the first transaction of the workload is the version it
+			// was added with
+			if change.Version == op.WlID.Version {
+				op.Op = gridtypes.OpAdd
+			}
+			break
+		}
+	}
+}
+
+// updateDeployment applies the update operations and returns the operations that
+// were applied and the ones that failed. Operations that were already applied by
+// a previous run of the same update (that partially failed) are skipped and
+// considered applied.
+func (e *NativeEngine) updateDeployment(ctx context.Context, ops []gridtypes.UpgradeOp) (applied, failed []gridtypes.UpgradeOp) {
 	e.sortOperations(ops)
 	for _, op := range ops {
+		if e.isApplied(op) {
+			log.Debug().Stringer("id", op.WlID.ID).Stringer("operation", op.Op).Msg("operation already applied")
+			applied = append(applied, op)
+			continue
+		}
+
 		var err error
 		switch op.Op {
 		case gridtypes.OpRemove:
 			err = e.uninstallWorkload(ctx, op.WlID, "deleted by an update")
 		case gridtypes.OpAdd:
-			err = e.installWorkload(ctx, op.WlID)
+			err = e.addWorkload(ctx, op.WlID)
 		case gridtypes.OpUpdate:
 			err = e.updateWorkload(ctx, op.WlID)
 		}
 
 		if err != nil {
 			log.Error().Err(err).Stringer("id", op.WlID.ID).Stringer("operation", op.Op).Msg("error while updating deployment")
+			failed = append(failed, op)
+		} else if e.isFailed(op) {
+			failed = append(failed, op)
+		} else {
+			applied = append(applied, op)
 		}
 	}
 	return
 }
 
+// isApplied checks if an update operation was already applied. This happens
+// when an update that partially failed is sent again, the workloads that were
+// updated or added successfully already have the target version.
+func (e *NativeEngine) isApplied(op gridtypes.UpgradeOp) bool {
+	if op.Op == gridtypes.OpRemove {
+		return false
+	}
+
+	twin, deployment, name, _ := op.WlID.ID.Parts()
+	current, err := e.storage.Current(twin, deployment, name)
+	if err != nil {
+		return false
+	}
+
+	return current.Version == op.WlID.Version &&
+		!current.Result.State.IsAny(gridtypes.StateError, gridtypes.StateDeleted)
+}
+
+// addWorkload installs a workload added by an update. A workload that failed
+// to be added by a previous attempt of the same update is installed again.
+func (e *NativeEngine) addWorkload(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	twin, deployment, name, _ := wl.ID.Parts()
+	current, err := e.storage.Current(twin, deployment, name)
+	if err == nil && current.Result.State == gridtypes.StateError {
+		// the error result would make the install skip the workload
+		result := gridtypes.Result{Created: gridtypes.Now(), State: gridtypes.StateInit}
+		if err := e.transaction(twin, deployment, wl.WithResults(result)); err != nil {
+			return errors.Wrap(err, "failed to reset failed workload")
+		}
+	}
+
+	return e.installWorkload(ctx, wl)
+}
+
+// isFailed checks the stored state of the workload after the operation is applied,
+// since provision errors are stored as workload results and not returned.
+func (e *NativeEngine) isFailed(op gridtypes.UpgradeOp) bool {
+	twin, deployment, name, _ := op.WlID.ID.Parts()
+	current, err := e.storage.Current(twin, deployment, name)
+	if errors.Is(err, ErrWorkloadNotExist) {
+		// a removed workload is not in storage anymore
+		return op.Op != gridtypes.OpRemove
+	} else if err != nil {
+		return true
+	}
+
+	return current.Result.State == gridtypes.StateError
+}
+
+// finishUpdate sets the deployment version to the target version only if all
+// update operations were applied. Otherwise the version is kept so the same
+// update can be sent again to apply the failed operations.
+func (e *NativeEngine) finishUpdate(target *gridtypes.Deployment, applied, failed []gridtypes.UpgradeOp) error {
+	if len(failed) != 0 {
+		log.Error().
+			Uint32("twin", target.TwinID).
+			Uint64("contract", target.ContractID).
+			Uint32("version", target.Version).
+			Strs("applied", upgradeOps(applied)).
+			Strs("failed", upgradeOps(failed)).
+			Msg("deployment update partially applied, keeping current deployment version")
+		return nil
+	}
+
+	return e.storage.Update(target.TwinID, target.ContractID, VersionField{target.Version})
+}
+
+func upgradeOps(ops []gridtypes.UpgradeOp) []string {
+	names := make([]string, 0, len(ops))
+	for _, op := range ops {
+		names = append(names, fmt.Sprintf("%s:%s", op.Op, op.WlID.Name))
+	}
+	return names
+}
+
 // DecommissionCached implements the zbus interface
 func (e *NativeEngine) DecommissionCached(id string, reason string) error {
 	log.Info().Str("workload-id", id).Str("reason", reason).Msg("decommissioning cached workload")
@@ -1025,30 +1765,129 @@ func (e *NativeEngine) DecommissionCached(id string, reason string) error {
 	return err
 }
 
-func (n *NativeEngine) CreateOrUpdate(twin uint32, deployment gridtypes.Deployment, update bool) error {
-	if err := deployment.Valid(); err != nil {
+// DeprovisionWorkload schedules a single workload for removal, the rest of the
+// deployment is kept as is. If other workloads in the deployment depend on this
+// workload (for example a vm using a network) the call fails unless cascade is
+// set, in that case the dependent workloads are removed as well.
+func (e *NativeEngine) DeprovisionWorkload(id string, reason string, cascade bool) error {
+	globalID := gridtypes.WorkloadID(id)
+	twin, dlID, name, err := globalID.Parts()
+	if err != nil {
 		return err
 	}
 
-	if deployment.TwinID != twin {
-		return fmt.Errorf("twin id mismatch (deployment: %d, message: %d)", deployment.TwinID, twin)
+	deployment, err := e.storage.Get(twin, dlID)
+	if errors.Is(err, ErrDeploymentNotExists) {
+		return fmt.Errorf("deployment not found")
+	} else if err != nil {
+		return err
 	}
 
-	// make sure the account used is verified
-	check := func() error {
-		if ok, err := isTwinVerified(twin); err != nil {
+	wl, err := deployment.Get(name)
+	if err != nil {
+		return err
+	}
+
+	if wl.Result.State == gridtypes.StateDeleted {
+		return fmt.Errorf("workload '%s' is already deleted", name)
+	}
+
+	users, err := dependents(&deployment, name)
+	if err != nil {
+		return errors.Wrap(err, "failed to find workload dependents")
+	}
+
+	if len(users) > 0 && !cascade {
+		return fmt.Errorf("workload '%s' is used by %v, set cascade to remove them as well", name, users)
+	}
+
+	if wl.Type == zos.NetworkType || wl.Type == zos.NetworkLightType {
+		// networks can also be used by vms in other deployments of the same twin
+		if err := e.networkInUse(twin, dlID, name); err != nil {
 			return err
-		} else if !ok {
-			return fmt.Errorf("user with twin id %d is not verified", twin)
 		}
+	}
+
+	remove := map[gridtypes.Name]struct{}{name: {}}
+	for _, user := range users {
+		remove[user] = struct{}{}
+	}
+
+	target := deployment
+	target.Workloads = nil
+	for _, wl := range deployment.Workloads {
+		if _, ok := remove[wl.Name]; ok {
+			target.Workloads = append(target.Workloads, wl)
+		}
+	}
+
+	log.Info().
+		Uint32("twin", twin).
+		Uint64("contract", dlID).
+		Stringer("name", name).
+		Bool("cascade", cascade).
+		Str("reason", reason).
+		Msg("schedule workload for deprovision")
+
+	job := engineJob{
+		Target:  target,
+		Op:      opDeprovisionWorkloads,
+		Message: reason,
+	}
+
+	return e.enqueue(context.Background(), &job)
+}
+
+// networkInUse returns an error if the network is used by vms in other twin deployments
+func (e *NativeEngine) networkInUse(twin uint32, exclude uint64, network gridtypes.Name) error {
+	deployments, err := e.List(twin)
+	if err != nil {
+		return err
+	}
+
+	for i := range deployments {
+		dl := &deployments[i]
+		if dl.ContractID == exclude {
+			continue
+		}
+
+		users, err := dependents(dl, network)
+		if err != nil {
+			return err
+		}
+
+		if len(users) > 0 {
+			return fmt.Errorf("network '%s' is used by %v in deployment %d", network, users, dl.ContractID)
+		}
+	}
+
+	return nil
+}
+
+// verify verifies the deployment signatures. If verification fails and the
+// twins source caches keys, the keys of the signers are dropped and the
+// deployment is verified again, in case a twin has changed its key.
+func (n *NativeEngine) verify(deployment *gridtypes.Deployment) error {
+	err := deployment.Verify(n.twins)
+	if err == nil {
 		return nil
 	}
 
-	if err := backoff.Retry(check, backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 5)); err != nil {
+	invalidator, ok := n.twins.(TwinsInvalidator)
+	if !ok {
 		return err
 	}
 
-	if err := deployment.Verify(n.twins); err != nil {
+	invalidator.Invalidate(deployment.TwinID)
+	for _, request := range deployment.SignatureRequirement.Requests {
+		invalidator.Invalidate(request.TwinID)
+	}
+
+	return deployment.Verify(n.twins)
+}
+
+func (n *NativeEngine) CreateOrUpdate(twin uint32, deployment gridtypes.Deployment, update bool) error {
+	if err := n.check(twin, &deployment); err != nil {
 		return err
 	}
 
@@ -1066,6 +1905,150 @@ func (n *NativeEngine) CreateOrUpdate(twin uint32, deployment gridtypes.Deployme
 	return action(ctx, deployment)
 }
 
+// Validate runs all the checks of a deployment sent by the twin, as if it
+// was deployed (or updated if it already exists), including the contract
+// hash check. Nothing is stored or queued.
+func (n *NativeEngine) Validate(ctx context.Context, twin uint32, deployment gridtypes.Deployment) error {
+	if err := n.check(twin, &deployment); err != nil {
+		return err
+	}
+
+	current, err := n.storage.Get(deployment.TwinID, deployment.ContractID)
+	if errors.Is(err, ErrDeploymentNotExists) {
+		if deployment.Version != 0 {
+			return errors.Wrap(ErrInvalidVersion, "expected version to be 0 on deployment creation")
+		}
+	} else if err != nil {
+		return err
+	} else if err := n.checkUpgrade(ctx, &current, &deployment); err != nil {
+		return err
+	}
+
+	_, err = n.validate(ctx, &deployment, false)
+	return err
+}
+
+// ValidateDeployment implements pkg.Provision, it's Validate with the same
+// deadline used to deploy.
+func (n *NativeEngine) ValidateDeployment(twin uint32, deployment gridtypes.Deployment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	return n.Validate(ctx, twin, deployment)
+}
+
+// check runs the checks of a deployment sent by the twin that don't need the
+// deployment contract
+func (n *NativeEngine) check(twin uint32, deployment *gridtypes.Deployment) error {
+	if err := n.limits.Check(deployment); err != nil {
+		return err
+	}
+
+	if err := deployment.Valid(); err != nil {
+		return err
+	}
+
+	if deployment.TwinID != twin {
+		return fmt.Errorf("twin id mismatch (deployment: %d, message: %d)", deployment.TwinID, twin)
+	}
+
+	if err := n.checkTwinQuota(deployment); err != nil {
+		return err
+	}
+
+	networks, err := n.twinNetworks(twin, deployment.ContractID)
+	if err != nil {
+		return errors.Wrap(err, "failed to list twin networks")
+	}
+
+	if err := validateNetworkReferences(deployment, networks); err != nil {
+		return err
+	}
+
+	// make sure the account used is verified. only failures to reach
+	// the kyc services are retried
+	check := func() error {
+		if ok, err := isTwinVerified(twin); err != nil {
+			return err
+		} else if !ok {
+			return backoff.Permanent(fmt.Errorf("user with twin id %d is not verified", twin))
+		}
+		return nil
+	}
+
+	if err := backoff.Retry(check, backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 5)); err != nil {
+		return err
+	}
+
+	return n.verify(deployment)
+}
+
+// twinNetworks returns the names of all the networks of the twin that are
+// deployed on the node, excluding the ones in the given deployment.
+func (n *NativeEngine) twinNetworks(twin uint32, exclude uint64) (map[gridtypes.Name]struct{}, error) {
+	networks := make(map[gridtypes.Name]struct{})
+	ids, err := n.storage.ByTwin(twin)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if id == exclude {
+			continue
+		}
+
+		deployment, err := n.storage.Get(twin, id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, wl := range deployment.ByType(zos.NetworkType, zos.NetworkLightType) {
+			if wl.Result.State.IsAny(gridtypes.StateDeleted, gridtypes.StateError) {
+				continue
+			}
+			networks[wl.Name] = struct{}{}
+		}
+	}
+
+	return networks, nil
+}
+
+// validateNetworkReferences makes sure all networks used by the deployment vms
+// are either part of the deployment or already exist on the node
+func validateNetworkReferences(dl *gridtypes.Deployment, existing map[gridtypes.Name]struct{}) error {
+	networks := make(map[gridtypes.Name]struct{})
+	for name := range existing {
+		networks[name] = struct{}{}
+	}
+
+	for _, wl := range dl.ByType(zos.NetworkType, zos.NetworkLightType) {
+		networks[wl.Name] = struct{}{}
+	}
+
+	for _, wl := range dl.ByType(zos.ZMachineType, zos.ZMachineLightType) {
+		data, err := wl.WorkloadData()
+		if err != nil {
+			return err
+		}
+
+		var interfaces []zos.MachineInterface
+		switch vm := data.(type) {
+		case *zos.ZMachine:
+			interfaces = vm.Network.Interfaces
+		case *zos.ZMachineLight:
+			interfaces = vm.Network.Interfaces
+		}
+
+		for _, inf := range interfaces {
+			if _, ok := networks[inf.Network]; !ok {
+				return fmt.Errorf("VM '%s' references unknown network '%s'", wl.Name, inf.Network)
+			}
+		}
+	}
+
+	return nil
+}
+
 func (n *NativeEngine) Get(twin uint32, contractID uint64) (gridtypes.Deployment, error) {
 	deployment, err := n.storage.Get(twin, contractID)
 	if errors.Is(err, ErrDeploymentNotExists) {
@@ -1110,46 +2093,17 @@ func (n *NativeEngine) ListTwins() ([]uint32, error) {
 	return n.storage.Twins()
 }
 
+// ListPublicIPs returns the public IPs allocated by public ip workloads
 func (n *NativeEngine) ListPublicIPs() ([]string, error) {
-	// for efficiency this method should just find out configured public Ips.
-	// but currently the only way to do this is by scanning the nft rules
-	// another less efficient but good for now solution is to scan all
-	// reservations and find the ones with public IPs.
-
-	twins, err := n.storage.Twins()
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to list twins")
+	if ips, ok := n.publicIPs.list(); ok {
+		return ips, nil
 	}
-	ips := make([]string, 0)
-	for _, twin := range twins {
-		deploymentsIDs, err := n.storage.ByTwin(twin)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to list twin deployment")
-		}
-		for _, id := range deploymentsIDs {
-			deployment, err := n.storage.Get(twin, id)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to load deployment")
-			}
-			workloads := deployment.ByType(zos.PublicIPv4Type, zos.PublicIPType)
-
-			for _, workload := range workloads {
-				if workload.Result.State != gridtypes.StateOk {
-					continue
-				}
 
-				var result zos.PublicIPResult
-				if err := workload.Result.Unmarshal(&result); err != nil {
-					return nil, err
-				}
-
-				if result.IP.IP != nil {
-					ips = append(ips, result.IP.String())
-				}
-			}
-		}
+	if err := n.rebuildPublicIPs(); err != nil {
+		return nil, err
 	}
 
+	ips, _ := n.publicIPs.list()
 	return ips, nil
 }
 
@@ -1225,12 +2179,38 @@ func (e *NativeEngine) GetWorkloadStatus(id string) (gridtypes.ResultState, bool
 	return wl.Result.State, true, nil
 }
 
-// isTwinVerified make sure the account used is verified
+// isTwinVerified make sure the account used is verified. All configured kyc
+// services are tried in order, an error is only returned if none of them
+// could be reached.
 func isTwinVerified(twinID uint32) (verified bool, err error) {
-	const verifiedStatus = "VERIFIED"
 	env := environment.MustGet()
 
-	verificationServiceURL, err := url.JoinPath(env.KycURL, "/api/v1/status")
+	urls := env.KycURLs
+	if len(urls) == 0 {
+		urls = []string{env.KycURL}
+	}
+
+	for _, u := range urls {
+		verified, err = verificationStatus(u, twinID)
+		if err == nil {
+			return verified, nil
+		}
+
+		log.Warn().Err(err).Str("url", u).Msg("failed to get twin verification status")
+	}
+
+	return false, fmt.Errorf("%w: %s", ErrKYCUnavailable, err)
+}
+
+// verificationStatus gets the twin verification status from a single kyc
+// service, it's a variable so tests can replace it
+var verificationStatus = twinVerificationStatus
+
+// twinVerificationStatus checks the twin verification status against a single kyc service
+func twinVerificationStatus(kycURL string, twinID uint32) (verified bool, err error) {
+	const verifiedStatus = "VERIFIED"
+
+	verificationServiceURL, err := url.JoinPath(kycURL, "/api/v1/status")
 	if err != nil {
 		return
 	}
@@ -1,12 +1,29 @@
 package provision
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/zbus"
+	"github.com/threefoldtech/zosbase/pkg"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zosbase/pkg/mocks"
+	"github.com/threefoldtech/zosbase/pkg/stubs"
+	"github.com/vmihailenco/msgpack"
+	"go.uber.org/mock/gomock"
 )
 
 // func TestEngine(t *testing.T) {
@@ -182,3 +199,587 @@ func TestSortMountWorkloads(t *testing.T) {
 		assert.Equal(t, expectedWorkloads, workloads)
 	})
 }
+
+func TestValidateNetworkReferences(t *testing.T) {
+	vm := gridtypes.Workload{
+		Name: "vm",
+		Type: zos.ZMachineLightType,
+		Data: json.RawMessage(`{"network": {"interfaces": [{"network": "net", "ip": "10.1.1.2"}]}}`),
+	}
+
+	t.Run("network in deployment", func(t *testing.T) {
+		dl := gridtypes.Deployment{
+			Workloads: []gridtypes.Workload{
+				vm,
+				{Name: "net", Type: zos.NetworkLightType},
+			},
+		}
+		assert.NoError(t, validateNetworkReferences(&dl, nil))
+	})
+	t.Run("network on node", func(t *testing.T) {
+		dl := gridtypes.Deployment{
+			Workloads: []gridtypes.Workload{vm},
+		}
+		existing := map[gridtypes.Name]struct{}{"net": {}}
+		assert.NoError(t, validateNetworkReferences(&dl, existing))
+	})
+	t.Run("unknown network", func(t *testing.T) {
+		dl := gridtypes.Deployment{
+			Workloads: []gridtypes.Workload{
+				vm,
+				{Name: "other", Type: zos.NetworkLightType},
+			},
+		}
+		assert.EqualError(t, validateNetworkReferences(&dl, nil), "VM 'vm' references unknown network 'net'")
+	})
+}
+
+func TestDependents(t *testing.T) {
+	dl := gridtypes.Deployment{
+		Workloads: []gridtypes.Workload{
+			{Name: "net", Type: zos.NetworkLightType},
+			{Name: "disk", Type: zos.VolumeType},
+			{
+				Name: "vm",
+				Type: zos.ZMachineLightType,
+				Data: json.RawMessage(`{"network": {"interfaces": [{"network": "net"}]}, "mounts": [{"name": "disk"}]}`),
+			},
+			{
+				Name:   "old",
+				Type:   zos.ZMachineLightType,
+				Data:   json.RawMessage(`{"network": {"interfaces": [{"network": "net"}]}}`),
+				Result: gridtypes.Result{State: gridtypes.StateDeleted},
+			},
+		},
+	}
+
+	users, err := dependents(&dl, "net")
+	assert.NoError(t, err)
+	assert.Equal(t, []gridtypes.Name{"vm"}, users)
+
+	users, err = dependents(&dl, "disk")
+	assert.NoError(t, err)
+	assert.Equal(t, []gridtypes.Name{"vm"}, users)
+
+	users, err = dependents(&dl, "vm")
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func TestSetStartupOrder(t *testing.T) {
+	e := &NativeEngine{
+		order:     []gridtypes.WorkloadType{zos.NetworkType, zos.ZMountType, zos.ZMachineType},
+		typeIndex: make(map[gridtypes.WorkloadType]int),
+	}
+
+	err := e.SetStartupOrder("unknown")
+	assert.Error(t, err)
+
+	err = e.SetStartupOrder(zos.ZMachineType)
+	assert.NoError(t, err)
+
+	// not applied until the engine picks the next job
+	order, _ := e.StartupOrder()
+	assert.Equal(t, []gridtypes.WorkloadType{zos.NetworkType, zos.ZMountType, zos.ZMachineType}, order)
+
+	e.applyPendingOrder()
+	order, _ = e.StartupOrder()
+	assert.Equal(t, []gridtypes.WorkloadType{zos.ZMachineType, zos.NetworkType, zos.ZMountType}, order)
+	assert.Equal(t, 1, e.typeIndex[zos.ZMachineType])
+	assert.Equal(t, 3, e.typeIndex[zos.ZMountType])
+}
+
+// installStorage records transactions of installed workloads, workloads
+// are always found in storage as created
+type installStorage struct {
+	Storage
+
+	m            sync.Mutex
+	transactions map[gridtypes.Name]gridtypes.Workload
+}
+
+func (s *installStorage) Current(twin uint32, deployment uint64, name gridtypes.Name) (gridtypes.Workload, error) {
+	return gridtypes.Workload{Name: name, Result: gridtypes.Result{State: gridtypes.StateInit}}, nil
+}
+
+func (s *installStorage) Transaction(twin uint32, deployment uint64, wl gridtypes.Workload) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.transactions[wl.Name] = wl
+	return nil
+}
+
+// slowProvisioner tracks the max number of concurrent provisions
+type slowProvisioner struct {
+	Provisioner
+
+	running int32
+	max     int32
+}
+
+func (p *slowProvisioner) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	running := atomic.AddInt32(&p.running, 1)
+	defer atomic.AddInt32(&p.running, -1)
+
+	for {
+		max := atomic.LoadInt32(&p.max)
+		if running <= max || atomic.CompareAndSwapInt32(&p.max, max, running) {
+			break
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if wl.Name == "disk0" {
+		return gridtypes.Result{}, fmt.Errorf("no space left")
+	}
+
+	return gridtypes.Result{State: gridtypes.StateOk}, nil
+}
+
+func TestInstallDeploymentConcurrency(t *testing.T) {
+	dl := gridtypes.Deployment{TwinID: 1, ContractID: 1}
+	for i := 0; i < 20; i++ {
+		dl.Workloads = append(dl.Workloads, gridtypes.Workload{
+			Name: gridtypes.Name(fmt.Sprintf("disk%d", i)),
+			Type: zos.ZMountType,
+			Data: gridtypes.MustMarshal(zos.ZMount{Size: gridtypes.Unit(i+1) * gridtypes.Gigabyte}),
+		})
+	}
+
+	for _, concurrency := range []int{1, 5} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			storage := &installStorage{transactions: make(map[gridtypes.Name]gridtypes.Workload)}
+			provisioner := &slowProvisioner{}
+			e := &NativeEngine{
+				storage:     storage,
+				provisioner: provisioner,
+				order:       []gridtypes.WorkloadType{zos.ZMountType},
+				concurrency: concurrency,
+			}
+
+			e.installDeployment(context.Background(), &dl)
+
+			require.EqualValues(t, concurrency, provisioner.max)
+			require.Len(t, storage.transactions, 20)
+			// the failed disk does not stop the others
+			require.Equal(t, gridtypes.StateError, storage.transactions["disk0"].Result.State)
+			require.Equal(t, gridtypes.StateOk, storage.transactions["disk19"].Result.State)
+		})
+	}
+}
+
+func TestInstallWorkloadsNetworkSequential(t *testing.T) {
+	dl := gridtypes.Deployment{TwinID: 1, ContractID: 1}
+	for i := 0; i < 5; i++ {
+		dl.Workloads = append(dl.Workloads, gridtypes.Workload{
+			Name: gridtypes.Name(fmt.Sprintf("net%d", i)),
+			Type: zos.NetworkType,
+		})
+	}
+
+	storage := &installStorage{transactions: make(map[gridtypes.Name]gridtypes.Workload)}
+	provisioner := &slowProvisioner{}
+	e := &NativeEngine{
+		storage:     storage,
+		provisioner: provisioner,
+		concurrency: 5,
+	}
+
+	err := e.installWorkloads(context.Background(), zos.NetworkType, dl.ByType(zos.NetworkType))
+	require.NoError(t, err)
+	require.EqualValues(t, 1, provisioner.max)
+	require.Len(t, storage.transactions, 5)
+}
+
+// hungProvisioner never finishes provisioning the "slow" workload, and
+// ignores the context. Other workloads already exist.
+type hungProvisioner struct {
+	Provisioner
+}
+
+func (p *hungProvisioner) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	if wl.Name != "slow" {
+		return gridtypes.Result{}, ErrNoActionNeeded
+	}
+
+	time.Sleep(time.Second)
+	return gridtypes.Result{State: gridtypes.StateOk}, nil
+}
+
+func (p *hungProvisioner) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	return nil
+}
+
+func TestJobTimeout(t *testing.T) {
+	dl := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 1,
+		Workloads: []gridtypes.Workload{
+			{Name: "slow", Type: zos.ZMachineType},
+			{Name: "healthy", Type: zos.ZMachineType},
+		},
+	}
+
+	storage := &installStorage{transactions: make(map[gridtypes.Name]gridtypes.Workload)}
+	e := &NativeEngine{
+		storage:     storage,
+		provisioner: &hungProvisioner{},
+		order:       []gridtypes.WorkloadType{zos.ZMachineType},
+		jobTimeout:  50 * time.Millisecond,
+	}
+
+	started := time.Now()
+	e.installDeployment(context.Background(), &dl)
+	require.Less(t, time.Since(started), 500*time.Millisecond)
+
+	// only the slow workload is set to error, the healthy one is kept as is
+	require.Len(t, storage.transactions, 1)
+	result := storage.transactions["slow"].Result
+	require.Equal(t, gridtypes.StateError, result.State)
+	require.Equal(t, ErrProvisionTimeout.Error(), result.Error)
+}
+
+// lateProvisioner finishes provisioning only once released, and ignores the
+// context
+type lateProvisioner struct {
+	Provisioner
+	release       chan struct{}
+	provisioned   atomic.Int32
+	deprovisioned chan gridtypes.WorkloadID
+	// err is returned by the late provision
+	err error
+}
+
+func (p *lateProvisioner) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	p.provisioned.Add(1)
+	<-p.release
+	if p.err != nil {
+		return gridtypes.Result{}, p.err
+	}
+	return gridtypes.Result{State: gridtypes.StateOk}, nil
+}
+
+func (p *lateProvisioner) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	p.deprovisioned <- wl.ID
+	return nil
+}
+
+func TestProvisionLate(t *testing.T) {
+	provisioner := &lateProvisioner{
+		release:       make(chan struct{}),
+		deprovisioned: make(chan gridtypes.WorkloadID, 1),
+	}
+	e := &NativeEngine{provisioner: provisioner}
+	wl := &gridtypes.WorkloadWithID{
+		Workload: &gridtypes.Workload{Name: "vm", Type: zos.ZMachineType},
+		ID:       gridtypes.WorkloadID("1-1-vm"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := e.provision(ctx, wl)
+	require.ErrorIs(t, err, ErrProvisionTimeout)
+	require.EqualValues(t, 1, provisioner.provisioned.Load())
+
+	// a done context never reaches the provisioner
+	_, err = e.provision(ctx, wl)
+	require.ErrorIs(t, err, ErrProvisionTimeout)
+
+	// the workload can't be provisioned again while the late provision runs
+	_, err = e.provision(context.Background(), wl)
+	require.ErrorIs(t, err, ErrRetryable)
+	require.EqualValues(t, 1, provisioner.provisioned.Load())
+
+	// the late result is deprovisioned once it's in
+	close(provisioner.release)
+	select {
+	case id := <-provisioner.deprovisioned:
+		require.Equal(t, wl.ID, id)
+	case <-time.After(time.Second):
+		require.Fail(t, "late workload was not deprovisioned")
+	}
+
+	require.Eventually(t, func() bool { return !e.isLate(wl.ID) }, time.Second, 5*time.Millisecond)
+	result, err := e.provision(context.Background(), wl)
+	require.NoError(t, err)
+	require.Equal(t, gridtypes.StateOk, result.State)
+	require.EqualValues(t, 2, provisioner.provisioned.Load())
+}
+
+func TestProvisionLateExisting(t *testing.T) {
+	provisioner := &lateProvisioner{
+		release:       make(chan struct{}),
+		deprovisioned: make(chan gridtypes.WorkloadID, 1),
+		err:           ErrNoActionNeeded,
+	}
+	e := &NativeEngine{provisioner: provisioner, jobTimeout: 20 * time.Millisecond}
+	wl := &gridtypes.WorkloadWithID{
+		Workload: &gridtypes.Workload{Name: "vm", Type: zos.ZMachineType},
+		ID:       gridtypes.WorkloadID("1-1-vm"),
+	}
+
+	_, err := e.provision(context.Background(), wl)
+	require.ErrorIs(t, err, ErrProvisionTimeout)
+
+	// the workload already existed, so it's never deprovisioned
+	close(provisioner.release)
+	require.Eventually(t, func() bool { return !e.isLate(wl.ID) }, time.Second, 5*time.Millisecond)
+	select {
+	case <-provisioner.deprovisioned:
+		require.Fail(t, "existing workload was deprovisioned")
+	default:
+	}
+}
+
+// flakyProvisioner fails with a retryable error, except for the "ok" workload
+type flakyProvisioner struct {
+	Provisioner
+}
+
+func (p *flakyProvisioner) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	switch wl.Name {
+	case "ok":
+		return gridtypes.Result{State: gridtypes.StateOk}, nil
+	case "broken":
+		return gridtypes.Result{}, fmt.Errorf("invalid config")
+	}
+
+	return gridtypes.Result{}, errors.Wrap(ErrRetryable, "daemon not ready")
+}
+
+func TestProvisionRetry(t *testing.T) {
+	dl := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 1,
+		Workloads: []gridtypes.Workload{
+			{Name: "ok", Type: zos.ZMachineType},
+			{Name: "flaky", Type: zos.ZMachineType},
+			{Name: "broken", Type: zos.ZMachineType},
+		},
+	}
+
+	e := &NativeEngine{
+		provisioner:   &flakyProvisioner{},
+		order:         []gridtypes.WorkloadType{zos.ZMachineType},
+		retryAttempts: 3,
+		retryInterval: time.Second,
+	}
+
+	t.Run("attempts left", func(t *testing.T) {
+		storage := &installStorage{transactions: make(map[gridtypes.Name]gridtypes.Workload)}
+		e.storage = storage
+
+		retry := e.jobRetry(&engineJob{Op: opProvision, Attempts: 1})
+		require.NotNil(t, retry)
+		e.installDeployment(withRetry(context.Background(), retry), &dl)
+
+		require.True(t, retry.retried())
+		require.Len(t, storage.transactions, 2)
+		require.Equal(t, gridtypes.StateOk, storage.transactions["ok"].Result.State)
+		require.Equal(t, gridtypes.StateError, storage.transactions["broken"].Result.State)
+		require.Equal(t, "invalid config", storage.transactions["broken"].Result.Error)
+	})
+
+	t.Run("last attempt", func(t *testing.T) {
+		storage := &installStorage{transactions: make(map[gridtypes.Name]gridtypes.Workload)}
+		e.storage = storage
+
+		retry := e.jobRetry(&engineJob{Op: opProvisionNoValidation, Attempts: 2})
+		require.NotNil(t, retry)
+		e.installDeployment(withRetry(context.Background(), retry), &dl)
+
+		require.False(t, retry.retried())
+		require.Len(t, storage.transactions, 3)
+		result := storage.transactions["flaky"].Result
+		require.Equal(t, gridtypes.StateError, result.State)
+		require.Equal(t, "daemon not ready: retryable error", result.Error)
+		require.Equal(t, 3, result.Attempts)
+	})
+
+	t.Run("no retry", func(t *testing.T) {
+		storage := &installStorage{transactions: make(map[gridtypes.Name]gridtypes.Workload)}
+		e.storage = storage
+
+		require.Nil(t, e.jobRetry(&engineJob{Op: opUpdate}))
+		e.installDeployment(context.Background(), &dl)
+
+		require.Len(t, storage.transactions, 3)
+		result := storage.transactions["flaky"].Result
+		require.Equal(t, gridtypes.StateError, result.State)
+		require.Equal(t, "daemon not ready: retryable error", result.Error)
+		require.Zero(t, result.Attempts)
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	e := &NativeEngine{retryInterval: 10 * time.Second}
+
+	require.Equal(t, 10*time.Second, e.retryDelay(1))
+	require.Equal(t, 20*time.Second, e.retryDelay(2))
+	require.Equal(t, 40*time.Second, e.retryDelay(3))
+	require.Equal(t, maxRetryInterval, e.retryDelay(10))
+}
+
+func TestRequeue(t *testing.T) {
+	storage := &deploymentsStorage{deployments: make(map[uint32]map[uint64]gridtypes.Deployment)}
+	current := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 1,
+		Version:    1,
+		Workloads:  []gridtypes.Workload{{Name: "vm", Type: zos.ZMachineType, Version: 1}},
+	}
+	storage.put(current)
+
+	e, err := New(storage, &flakyProvisioner{}, t.TempDir())
+	require.NoError(t, err)
+	defer e.queues.close()
+
+	stale := current
+	stale.Version = 0
+	stale.Workloads = []gridtypes.Workload{{Name: "vm", Type: zos.ZMachineType}}
+
+	// the deployment was updated since the job was queued
+	e.requeue(e.queueCfg.Name, engineJob{Op: opProvision, Target: stale})
+	pending, _, err := e.queues.stats()
+	require.NoError(t, err)
+	require.Zero(t, pending)
+
+	// the deployment is gone
+	gone := current
+	gone.ContractID = 2
+	e.requeue(e.queueCfg.Name, engineJob{Op: opProvision, Target: gone})
+	pending, _, err = e.queues.stats()
+	require.NoError(t, err)
+	require.Zero(t, pending)
+
+	// the retry installs the stored deployment
+	job := engineJob{Op: opProvision, Target: current}
+	job.Target.Workloads = nil
+	e.requeue(e.queueCfg.Name, job)
+	obj, err := e.queues.queues[0].Peek()
+	require.NoError(t, err)
+	retry := obj.(*engineJob)
+	require.Equal(t, opProvisionNoValidation, retry.Op)
+	require.Equal(t, 1, retry.Attempts)
+	require.Equal(t, current.Workloads, retry.Target.Workloads)
+	require.True(t, retry.NotBefore.After(time.Now()))
+}
+
+// zbusResponse builds a zbus response the same way the zbus server does
+func zbusResponse(t *testing.T, values ...interface{}) *zbus.Response {
+	data, err := msgpack.Marshal(values)
+	require.NoError(t, err)
+
+	return zbus.NewResponse("", zbus.Output{Data: data}, "")
+}
+
+// contractGateway is a substrate gateway that only knows about the given
+// node contract
+func contractGateway(t *testing.T, contract substrate.NodeContract) *stubs.SubstrateGatewayStub {
+	ctrl := gomock.NewController(t)
+	client := mocks.NewMockClient(ctrl)
+	client.EXPECT().
+		RequestContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, module string, object zbus.ObjectID, method string, args ...interface{}) (*zbus.Response, error) {
+			switch method {
+			case "GetContract":
+				return zbusResponse(t, substrate.Contract{
+					ContractType: substrate.ContractType{IsNodeContract: true, NodeContract: contract},
+				}, pkg.SubstrateError{}), nil
+			case "GetNodeRentContract":
+				return zbusResponse(t, uint64(0), pkg.SubstrateError{Code: pkg.CodeNotFound}), nil
+			}
+
+			return nil, fmt.Errorf("unexpected call to '%s'", method)
+		}).
+		AnyTimes()
+
+	return stubs.NewSubstrateGatewayStub(client)
+}
+
+// keyTwins returns the same key for all twins
+type keyTwins []byte
+
+func (k keyTwins) GetKey(id uint32) ([]byte, error) {
+	return k, nil
+}
+
+func TestValidateTampered(t *testing.T) {
+	status := verificationStatus
+	t.Cleanup(func() { verificationStatus = status })
+	verificationStatus = func(string, uint32) (bool, error) {
+		return true, nil
+	}
+
+	pk, sk, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	id, err := substrate.NewIdentityFromEd25519Key(sk)
+	require.NoError(t, err)
+
+	dl := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 1,
+		Workloads: []gridtypes.Workload{
+			{Name: "disk", Type: zos.ZMountType, Data: json.RawMessage(`{"size":1073741824}`)},
+		},
+		SignatureRequirement: gridtypes.SignatureRequirement{
+			Requests: []gridtypes.SignatureRequest{
+				{TwinID: 1, Required: true, Weight: 1},
+			},
+		},
+	}
+
+	hash, err := dl.ChallengeHash()
+	require.NoError(t, err)
+	require.NoError(t, dl.Sign(1, id))
+
+	e := &NativeEngine{
+		nodeID:           1,
+		twins:            keyTwins(pk),
+		storage:          &deploymentsStorage{deployments: make(map[uint32]map[uint64]gridtypes.Deployment)},
+		substrateGateway: contractGateway(t, substrate.NodeContract{Node: 1, DeploymentHash: substrate.NewHexHash(hex.EncodeToString(hash))}),
+	}
+
+	require.NoError(t, e.Validate(context.Background(), 1, dl))
+
+	// the twin signs a deployment that is not the one of the contract
+	tampered := dl
+	tampered.Workloads = []gridtypes.Workload{
+		{Name: "disk", Type: zos.ZMountType, Data: json.RawMessage(`{"size":2147483648}`)},
+	}
+	tampered.SignatureRequirement.Signatures = nil
+	require.NoError(t, tampered.Sign(1, id))
+
+	require.EqualError(t, e.Validate(context.Background(), 1, tampered), "contract hash does not match deployment hash")
+}
+
+func TestValidateNoStorage(t *testing.T) {
+	// any storage call panics, validation must fail before touching it
+	e := &NativeEngine{storage: &installStorage{}}
+
+	err := e.Validate(context.Background(), 2, gridtypes.Deployment{TwinID: 1, ContractID: 1})
+	require.EqualError(t, err, "twin id mismatch (deployment: 1, message: 2)")
+}
+
+func TestIsTwinVerifiedUnavailable(t *testing.T) {
+	status := verificationStatus
+	t.Cleanup(func() { verificationStatus = status })
+
+	verificationStatus = func(string, uint32) (bool, error) {
+		return false, fmt.Errorf("connection refused")
+	}
+
+	_, err := isTwinVerified(1)
+	require.ErrorIs(t, err, ErrKYCUnavailable)
+	require.EqualError(t, err, "kyc services unavailable: connection refused")
+
+	// any reachable service is enough
+	verificationStatus = func(string, uint32) (bool, error) {
+		return true, nil
+	}
+
+	verified, err := isTwinVerified(1)
+	require.NoError(t, err)
+	require.True(t, verified)
+}
@@ -0,0 +1,185 @@
+package provision
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+const (
+	// eventsBuffer is how many events are kept for a slow reader before
+	// new events are dropped
+	eventsBuffer = 128
+)
+
+// EventOperation is the operation of the job that emitted a deployment event
+type EventOperation string
+
+const (
+	EventProvision   EventOperation = "provision"
+	EventUpdate      EventOperation = "update"
+	EventPause       EventOperation = "pause"
+	EventResume      EventOperation = "resume"
+	EventDeprovision EventOperation = "deprovision"
+	EventResync      EventOperation = "resync"
+	EventRestart     EventOperation = "restart"
+)
+
+// WorkloadTransition is a change of the state of a workload
+type WorkloadTransition struct {
+	Name gridtypes.Name
+	Type gridtypes.WorkloadType
+	From gridtypes.ResultState
+	To   gridtypes.ResultState
+	// Error is the workload error if it ended in error state
+	Error string
+}
+
+// DeploymentEvent is emitted by the engine after each processed job
+type DeploymentEvent struct {
+	Twin      uint32
+	Contract  uint64
+	Operation EventOperation
+	// Workloads are the workloads that changed state during the job
+	Workloads []WorkloadTransition
+	Timestamp time.Time
+}
+
+// Events returns the channel of deployment events. Events are only emitted
+// once this is called, and are dropped if the reader is too slow.
+func (e *NativeEngine) Events() <-chan DeploymentEvent {
+	e.eventsM.Lock()
+	defer e.eventsM.Unlock()
+
+	if e.events == nil {
+		e.events = make(chan DeploymentEvent, eventsBuffer)
+	}
+
+	return e.events
+}
+
+func (e *NativeEngine) eventsChannel() chan DeploymentEvent {
+	e.eventsM.Lock()
+	defer e.eventsM.Unlock()
+
+	return e.events
+}
+
+func eventOperation(op jobOperation) EventOperation {
+	switch op {
+	case opProvision, opProvisionNoValidation:
+		return EventProvision
+	case opUpdate:
+		return EventUpdate
+	case opPause:
+		return EventPause
+	case opResume:
+		return EventResume
+	case opDeprovision, opDeprovisionWorkloads:
+		return EventDeprovision
+	case opResync:
+		return EventResync
+	case opRestart:
+		return EventRestart
+	}
+
+	return EventOperation("unknown")
+}
+
+// workloadStates returns the current workloads of the deployment, it's nil
+// if there are no events subscribers.
+func (e *NativeEngine) workloadStates(twin uint32, contract uint64) map[gridtypes.Name]gridtypes.Workload {
+	if e.eventsChannel() == nil {
+		return nil
+	}
+
+	states := make(map[gridtypes.Name]gridtypes.Workload)
+	dl, err := e.storage.Get(twin, contract)
+	if err != nil {
+		// the deployment is deleted, or does not exist yet
+		return states
+	}
+
+	for _, wl := range dl.Workloads {
+		states[wl.Name] = wl
+	}
+
+	return states
+}
+
+// emit sends the event of the job, before are the workloads states before
+// the job was processed. The event is dropped if it can't be delivered.
+func (e *NativeEngine) emit(job *engineJob, before map[gridtypes.Name]gridtypes.Workload) {
+	events := e.eventsChannel()
+	if events == nil {
+		return
+	}
+
+	twin, contract := job.Target.TwinID, job.Target.ContractID
+	after := e.workloadStates(twin, contract)
+
+	event := DeploymentEvent{
+		Twin:      twin,
+		Contract:  contract,
+		Operation: eventOperation(job.Op),
+		Timestamp: time.Now(),
+	}
+
+	state := func(states map[gridtypes.Name]gridtypes.Workload, name gridtypes.Name) (gridtypes.Workload, gridtypes.ResultState) {
+		wl, ok := states[name]
+		if !ok {
+			// workloads are removed from storage once deleted
+			return wl, gridtypes.StateDeleted
+		}
+		return wl, wl.Result.State
+	}
+
+	transition := func(name gridtypes.Name, typ gridtypes.WorkloadType) {
+		_, from := state(before, name)
+		wl, to := state(after, name)
+		if from == to {
+			return
+		}
+
+		event.Workloads = append(event.Workloads, WorkloadTransition{
+			Name:  name,
+			Type:  typ,
+			From:  from,
+			To:    to,
+			Error: wl.Result.Error,
+		})
+	}
+
+	targets := make(map[gridtypes.Name]struct{}, len(job.Target.Workloads))
+	for _, target := range job.Target.Workloads {
+		targets[target.Name] = struct{}{}
+		transition(target.Name, target.Type)
+	}
+
+	// workloads that are not part of the target anymore, for example
+	// removed by an update
+	var removed []gridtypes.Workload
+	for name, wl := range before {
+		if _, ok := targets[name]; !ok {
+			removed = append(removed, wl)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i].Name < removed[j].Name
+	})
+	for _, wl := range removed {
+		transition(wl.Name, wl.Type)
+	}
+
+	select {
+	case events <- event:
+	default:
+		log.Warn().
+			Uint32("twin", twin).
+			Uint64("contract", contract).
+			Str("operation", string(event.Operation)).
+			Msg("no reader for deployment events, event dropped")
+	}
+}
@@ -0,0 +1,173 @@
+package provision
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+// lifecycleStorage applies transactions to the deployments in memory
+type lifecycleStorage struct {
+	*deploymentsStorage
+}
+
+func (s *lifecycleStorage) Current(twin uint32, deployment uint64, name gridtypes.Name) (gridtypes.Workload, error) {
+	dl, err := s.Get(twin, deployment)
+	if err != nil {
+		return gridtypes.Workload{}, err
+	}
+
+	wl, err := dl.Get(name)
+	if err != nil {
+		return gridtypes.Workload{}, ErrWorkloadNotExist
+	}
+
+	return *wl.Workload, nil
+}
+
+func (s *lifecycleStorage) Transaction(twin uint32, deployment uint64, wl gridtypes.Workload) error {
+	dl := s.deployments[twin][deployment]
+	for i := range dl.Workloads {
+		if dl.Workloads[i].Name == wl.Name {
+			dl.Workloads[i] = wl
+		}
+	}
+
+	return nil
+}
+
+func (s *lifecycleStorage) Remove(twin uint32, deployment uint64, name gridtypes.Name) error {
+	dl := s.deployments[twin][deployment]
+	for i := range dl.Workloads {
+		if dl.Workloads[i].Name == name {
+			dl.Workloads = append(dl.Workloads[:i], dl.Workloads[i+1:]...)
+			break
+		}
+	}
+	s.put(dl)
+
+	return nil
+}
+
+func (s *lifecycleStorage) Delete(twin uint32, deployment uint64) error {
+	delete(s.deployments[twin], deployment)
+	return nil
+}
+
+func TestDeploymentEvents(t *testing.T) {
+	dl := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 1,
+		Workloads: []gridtypes.Workload{
+			{Name: "disk", Type: zos.ZMountType, Result: gridtypes.Result{State: gridtypes.StateInit}},
+			{Name: "vm", Type: zos.ZMachineType, Result: gridtypes.Result{State: gridtypes.StateInit}},
+		},
+	}
+
+	storage := &lifecycleStorage{
+		deploymentsStorage: &deploymentsStorage{deployments: make(map[uint32]map[uint64]gridtypes.Deployment)},
+	}
+	storage.put(dl)
+
+	e := &NativeEngine{
+		storage: storage,
+		provisioner: NewMapProvisioner(map[gridtypes.WorkloadType]Manager{
+			zos.ZMountType:   &fakeManager{},
+			zos.ZMachineType: &fakeManager{},
+		}),
+		order: []gridtypes.WorkloadType{zos.ZMountType, zos.ZMachineType},
+	}
+
+	// no subscribers, nothing is tracked
+	require.Nil(t, e.workloadStates(1, 1))
+
+	events := e.Events()
+	ctx := context.Background()
+
+	// same steps as the engine loop
+	provision := engineJob{Op: opProvision, Target: dl}
+	states := e.workloadStates(1, 1)
+	e.installDeployment(ctx, &provision.Target)
+	e.emit(&provision, states)
+
+	deprovision := engineJob{Op: opDeprovision, Target: dl}
+	states = e.workloadStates(1, 1)
+	e.uninstallDeployment(ctx, &deprovision.Target, "deleted")
+	e.emit(&deprovision, states)
+
+	require.Len(t, events, 2)
+
+	event := <-events
+	require.Equal(t, uint32(1), event.Twin)
+	require.Equal(t, uint64(1), event.Contract)
+	require.Equal(t, EventProvision, event.Operation)
+	require.False(t, event.Timestamp.IsZero())
+	require.Equal(t, []WorkloadTransition{
+		{Name: "disk", Type: zos.ZMountType, From: gridtypes.StateInit, To: gridtypes.StateOk},
+		{Name: "vm", Type: zos.ZMachineType, From: gridtypes.StateInit, To: gridtypes.StateOk},
+	}, event.Workloads)
+
+	event = <-events
+	require.Equal(t, EventDeprovision, event.Operation)
+	require.Equal(t, []WorkloadTransition{
+		{Name: "disk", Type: zos.ZMountType, From: gridtypes.StateOk, To: gridtypes.StateDeleted},
+		{Name: "vm", Type: zos.ZMachineType, From: gridtypes.StateOk, To: gridtypes.StateDeleted},
+	}, event.Workloads)
+}
+
+func TestDeploymentEventsRemovedWorkload(t *testing.T) {
+	dl := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 1,
+		Workloads: []gridtypes.Workload{
+			{Name: "disk", Type: zos.ZMountType, Result: gridtypes.Result{State: gridtypes.StateOk}},
+			{Name: "vm", Type: zos.ZMachineType, Result: gridtypes.Result{State: gridtypes.StateOk}},
+		},
+	}
+
+	storage := &lifecycleStorage{
+		deploymentsStorage: &deploymentsStorage{deployments: make(map[uint32]map[uint64]gridtypes.Deployment)},
+	}
+	storage.put(dl)
+
+	e := &NativeEngine{storage: storage}
+	events := e.Events()
+
+	// the update target does not have the vm anymore, it's removed while
+	// the job is processed
+	update := engineJob{Op: opUpdate, Target: gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 1,
+		Workloads:  dl.Workloads[:1],
+	}}
+	states := e.workloadStates(1, 1)
+	require.NoError(t, storage.Remove(1, 1, "vm"))
+	e.emit(&update, states)
+
+	require.Len(t, events, 1)
+	event := <-events
+	require.Equal(t, EventUpdate, event.Operation)
+	require.Equal(t, []WorkloadTransition{
+		{Name: "vm", Type: zos.ZMachineType, From: gridtypes.StateOk, To: gridtypes.StateDeleted},
+	}, event.Workloads)
+}
+
+func TestDeploymentEventsDropped(t *testing.T) {
+	storage := &lifecycleStorage{
+		deploymentsStorage: &deploymentsStorage{deployments: make(map[uint32]map[uint64]gridtypes.Deployment)},
+	}
+
+	e := &NativeEngine{storage: storage}
+	events := e.Events()
+
+	// emit never blocks if there is no reader
+	job := engineJob{Op: opPause, Target: gridtypes.Deployment{TwinID: 1, ContractID: 1}}
+	for i := 0; i < eventsBuffer+10; i++ {
+		e.emit(&job, nil)
+	}
+
+	require.Len(t, events, eventsBuffer)
+}
@@ -12,6 +12,13 @@ type Twins interface {
 	GetKey(id uint32) ([]byte, error)
 }
 
+// TwinsInvalidator is an optional interface implemented by Twins sources
+// that cache keys. Invalidate drops the cached key of the twin so the next
+// call to GetKey fetches it again.
+type TwinsInvalidator interface {
+	Invalidate(id uint32)
+}
+
 // Engine is engine interface
 type Engine interface {
 	// Provision pushes a workload to engine queue. on success
@@ -51,6 +58,48 @@ type Provisioner interface {
 	CanUpdate(ctx context.Context, typ gridtypes.WorkloadType) bool
 }
 
+// Restarter is an optional interface of the Provisioner. Workloads of the
+// types it can restart are restarted in place by the engine, everything the
+// workload allocated is kept.
+type Restarter interface {
+	// CanRestart checks if workloads of this type can be restarted
+	CanRestart(ctx context.Context, typ gridtypes.WorkloadType) bool
+	// Restart a workload
+	Restart(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error)
+}
+
+// DriftDetector is used by the engine to find out if a workload that is
+// recorded as ok in storage is actually still running on the node.
+type DriftDetector interface {
+	// Drifted returns true if the runtime state of the workload does not
+	// match the recorded state (for example the vm process is gone)
+	Drifted(ctx context.Context, twin uint32, contract uint64, wl *gridtypes.Workload) bool
+}
+
+// DriftDetectorFn is a function that implements the DriftDetector interface
+type DriftDetectorFn func(ctx context.Context, twin uint32, contract uint64, wl *gridtypes.Workload) bool
+
+// Drifted implements DriftDetector
+func (f DriftDetectorFn) Drifted(ctx context.Context, twin uint32, contract uint64, wl *gridtypes.Workload) bool {
+	return f(ctx, twin, contract, wl)
+}
+
+// StorageSpace is used by the engine to find out how much storage is free
+// on the node before installing a deployment
+type StorageSpace interface {
+	// Free returns the free space of each pool that can be used by zmounts
+	// and volumes
+	Free(ctx context.Context) ([]gridtypes.Unit, error)
+}
+
+// StorageSpaceFn is a function that implements the StorageSpace interface
+type StorageSpaceFn func(ctx context.Context) ([]gridtypes.Unit, error)
+
+// Free implements StorageSpace
+func (f StorageSpaceFn) Free(ctx context.Context) ([]gridtypes.Unit, error) {
+	return f(ctx)
+}
+
 // Filter is filtering function for Purge method
 
 var (
@@ -75,6 +124,16 @@ var (
 	ErrDeploymentUpgradeValidationError = fmt.Errorf("upgrade validation error")
 	// ErrInvalidVersion invalid version error
 	ErrInvalidVersion = fmt.Errorf("invalid version")
+	// ErrKYCUnavailable is returned if none of the kyc services could be reached
+	// to check the twin verification status, the operation can be retried later
+	ErrKYCUnavailable = fmt.Errorf("kyc services unavailable")
+	// ErrRetryable can be wrapped by provisioners to mark a failure as
+	// transient (for example a daemon that is not ready yet). The engine
+	// then installs the deployment again later instead of failing the
+	// workload right away.
+	ErrRetryable = fmt.Errorf("retryable error")
+	// ErrRestartNotSupported is returned if the workload type can't be restarted
+	ErrRestartNotSupported = fmt.Errorf("restart not supported")
 )
 
 // Field interface
@@ -98,6 +157,9 @@ type SignatureRequirementField struct {
 type StorageCapacity struct {
 	// Cap is total reserved capacity as per all active workloads
 	Cap gridtypes.Capacity
+	// Pending is the capacity of the workloads that are accepted but not
+	// provisioned yet, it's not part of Cap
+	Pending gridtypes.Capacity
 	// Deployments is a list with all deployments that are active
 	Deployments []gridtypes.Deployment
 	// Workloads the total number of all workloads
@@ -0,0 +1,82 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+var (
+	// ErrDeploymentTooLarge is returned (wrapped in a DeploymentLimitError) if
+	// a deployment exceeds one of the engine deployment limits
+	ErrDeploymentTooLarge = fmt.Errorf("deployment too large")
+
+	// DefaultDeploymentLimits are generous limits that no normal deployment
+	// should reach, they only protect the node from huge deployments.
+	DefaultDeploymentLimits = DeploymentLimits{
+		MaxWorkloads: 512,
+		MaxSize:      4 * 1024 * 1024,
+		MaxPerType: map[gridtypes.WorkloadType]int{
+			zos.ZMachineType:      128,
+			zos.ZMachineLightType: 128,
+		},
+	}
+)
+
+// DeploymentLimits are the limits on the size of a single deployment, a
+// zero value means no limit.
+type DeploymentLimits struct {
+	// MaxWorkloads is the max number of workloads in a deployment
+	MaxWorkloads int
+	// MaxSize is the max total size in bytes of the workloads data, and
+	// the metadata and description of the deployment and its workloads
+	MaxSize int
+	// MaxPerType is the max number of workloads of a single type
+	MaxPerType map[gridtypes.WorkloadType]int
+}
+
+// DeploymentLimitError is returned if a deployment exceeds a limit
+type DeploymentLimitError struct {
+	// Limit is the name of the exceeded limit
+	Limit string
+	Value int
+	Max   int
+}
+
+func (e *DeploymentLimitError) Error() string {
+	return fmt.Sprintf("%s: %s is %d, max allowed is %d", ErrDeploymentTooLarge, e.Limit, e.Value, e.Max)
+}
+
+// Is makes errors.Is(err, ErrDeploymentTooLarge) match
+func (e *DeploymentLimitError) Is(target error) bool {
+	return target == ErrDeploymentTooLarge
+}
+
+// Check makes sure deployment does not exceed the limits
+func (l *DeploymentLimits) Check(dl *gridtypes.Deployment) error {
+	if l.MaxWorkloads > 0 && len(dl.Workloads) > l.MaxWorkloads {
+		return &DeploymentLimitError{Limit: "workloads count", Value: len(dl.Workloads), Max: l.MaxWorkloads}
+	}
+
+	size := len(dl.Metadata) + len(dl.Description)
+	types := make(map[gridtypes.WorkloadType]int)
+	for i := range dl.Workloads {
+		wl := &dl.Workloads[i]
+		size += len(wl.Data) + len(wl.Metadata) + len(wl.Description)
+		types[wl.Type]++
+	}
+
+	if l.MaxSize > 0 && size > l.MaxSize {
+		return &DeploymentLimitError{Limit: "size", Value: size, Max: l.MaxSize}
+	}
+
+	for typ, count := range types {
+		max, ok := l.MaxPerType[typ]
+		if ok && max > 0 && count > max {
+			return &DeploymentLimitError{Limit: fmt.Sprintf("'%s' workloads count", typ), Value: count, Max: max}
+		}
+	}
+
+	return nil
+}
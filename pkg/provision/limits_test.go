@@ -0,0 +1,54 @@
+package provision
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+func TestDeploymentLimits(t *testing.T) {
+	limits := DeploymentLimits{
+		MaxWorkloads: 3,
+		MaxSize:      100,
+		MaxPerType: map[gridtypes.WorkloadType]int{
+			zos.ZMachineType: 1,
+		},
+	}
+
+	dl := gridtypes.Deployment{
+		Workloads: []gridtypes.Workload{
+			{Name: "net", Type: zos.NetworkType, Data: []byte(`{}`)},
+			{Name: "vm", Type: zos.ZMachineType, Data: []byte(`{}`)},
+		},
+	}
+	require.NoError(t, limits.Check(&dl))
+
+	dl.Workloads = append(dl.Workloads, gridtypes.Workload{Name: "vm2", Type: zos.ZMachineType})
+	err := limits.Check(&dl)
+	require.ErrorIs(t, err, ErrDeploymentTooLarge)
+	var limitErr *DeploymentLimitError
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, 2, limitErr.Value)
+	require.Equal(t, 1, limitErr.Max)
+
+	dl.Workloads = append(dl.Workloads, gridtypes.Workload{Name: "disk", Type: zos.ZMountType})
+	err = limits.Check(&dl)
+	require.ErrorIs(t, err, ErrDeploymentTooLarge)
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, "workloads count", limitErr.Limit)
+
+	dl.Workloads = dl.Workloads[:1]
+	dl.Metadata = strings.Repeat("a", 100)
+	err = limits.Check(&dl)
+	require.ErrorIs(t, err, ErrDeploymentTooLarge)
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, "size", limitErr.Limit)
+
+	// zero limits means no limits
+	var none DeploymentLimits
+	require.NoError(t, none.Check(&dl))
+}
@@ -0,0 +1,61 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+// StartupOrder returns the order of types the engine currently
+// uses to install workloads. Removal happens in reverse order.
+func (e *NativeEngine) StartupOrder() ([]gridtypes.WorkloadType, error) {
+	e.orderM.RLock()
+	defer e.orderM.RUnlock()
+
+	order := make([]gridtypes.WorkloadType, len(e.order))
+	copy(order, e.order)
+	return order, nil
+}
+
+// SetStartupOrder changes the order of types the engine uses to install
+// workloads. Types that are not listed are moved after the given types.
+// The new order is not applied to the job that is currently running, it
+// only takes effect starting from the next job.
+func (e *NativeEngine) SetStartupOrder(types ...gridtypes.WorkloadType) error {
+	e.orderM.Lock()
+	defer e.orderM.Unlock()
+
+	order, index, err := orderTypes(e.order, types)
+	if err != nil {
+		return err
+	}
+
+	log.Warn().
+		Str("current", fmt.Sprint(e.order)).
+		Str("new", fmt.Sprint(order)).
+		Msg("engine startup order changed, it will be applied starting from next job")
+
+	e.pendingOrder = order
+	e.pendingIndex = index
+	return nil
+}
+
+// applyPendingOrder replaces the engine order with the one set by
+// SetStartupOrder if any. It must only be called by the engine loop
+// between jobs.
+func (e *NativeEngine) applyPendingOrder() {
+	e.orderM.Lock()
+	defer e.orderM.Unlock()
+
+	if e.pendingOrder == nil {
+		return
+	}
+
+	e.order = e.pendingOrder
+	e.typeIndex = e.pendingIndex
+	e.pendingOrder = nil
+	e.pendingIndex = nil
+
+	log.Warn().Str("order", fmt.Sprint(e.order)).Msg("engine startup order applied")
+}
@@ -93,6 +93,20 @@ type Pauser interface {
 	Resume(ctx context.Context, wl *gridtypes.WorkloadWithID) error
 }
 
+// Resyncer defines the optional Resync method for type managers. Types are allowed
+// to implement resync to re-apply the system configuration of an already provisioned
+// workload (for example firewall rules) in case it was changed from outside.
+type Resyncer interface {
+	Resync(ctx context.Context, wl *gridtypes.WorkloadWithID) error
+}
+
+// Restartable defines the optional Restart method for type managers. Types are
+// allowed to implement restart to stop and start a workload again in place,
+// without releasing anything it allocated.
+type Restartable interface {
+	Restart(ctx context.Context, wl *gridtypes.WorkloadWithID) error
+}
+
 type mapProvisioner struct {
 	managers map[gridtypes.WorkloadType]Manager
 }
@@ -148,6 +162,62 @@ func (p *mapProvisioner) Deprovision(ctx context.Context, wl *gridtypes.Workload
 	return manager.Deprovision(ctx, wl)
 }
 
+// Resync a workload, it implements the Resyncer interface
+func (p *mapProvisioner) Resync(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	log.Info().Str("workload-id", string(wl.ID)).Str("workload-type", string(wl.Type)).Msg("resyncing workload")
+
+	if wl.Result.State != gridtypes.StateOk {
+		return fmt.Errorf("can only resync workloads in ok state")
+	}
+
+	manager, ok := p.managers[wl.Type]
+	if !ok {
+		return fmt.Errorf("unknown workload type '%s' for reservation id '%s'", wl.Type, wl.ID)
+	}
+
+	resyncer, ok := manager.(Resyncer)
+	if !ok {
+		return fmt.Errorf("workload type '%s' does not support resync", wl.Type)
+	}
+
+	return resyncer.Resync(ctx, wl)
+}
+
+// CanRestart implements the Restarter interface
+func (p *mapProvisioner) CanRestart(ctx context.Context, typ gridtypes.WorkloadType) bool {
+	manager, ok := p.managers[typ]
+	if !ok {
+		return false
+	}
+
+	_, ok = manager.(Restartable)
+	return ok
+}
+
+// Restart a workload, it implements the Restarter interface
+func (p *mapProvisioner) Restart(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	log.Info().Str("workload-id", string(wl.ID)).Str("workload-type", string(wl.Type)).Msg("restarting workload")
+
+	if wl.Result.State != gridtypes.StateOk {
+		return wl.Result, fmt.Errorf("can only restart workloads in ok state")
+	}
+
+	manager, ok := p.managers[wl.Type]
+	if !ok {
+		return wl.Result, fmt.Errorf("unknown workload type '%s' for reservation id '%s'", wl.Type, wl.ID)
+	}
+
+	restartable, ok := manager.(Restartable)
+	if !ok {
+		return wl.Result, fmt.Errorf("workload type '%s' does not support restart", wl.Type)
+	}
+
+	// the workload is restarted in place, so its data does not change
+	result := wl.Result
+	setState(&result, restartable.Restart(ctx, wl))
+	return result, nil
+}
+
 // Pause a workload
 func (p *mapProvisioner) Pause(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
 	log.Info().Str("workload-id", string(wl.ID)).Str("workload-type", string(wl.Type)).Msg("pausing workload")
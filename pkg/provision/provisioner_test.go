@@ -150,3 +150,46 @@ func TestProvision(t *testing.T) {
 	require.NoError(err)
 	require.Equal(gridtypes.StatePaused, result.State)
 }
+
+// fakeManager is a type manager that records its calls, it's shared by the
+// engine tests that go through a map provisioner
+type fakeManager struct {
+	data interface{}
+	err  error
+
+	calls []string
+}
+
+func (m *fakeManager) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (interface{}, error) {
+	m.calls = append(m.calls, "provision "+wl.Name.String())
+	return m.data, m.err
+}
+
+func (m *fakeManager) Deprovision(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	m.calls = append(m.calls, "deprovision "+wl.Name.String())
+	return nil
+}
+
+// restartableManager is a fakeManager that can be restarted
+type restartableManager struct {
+	fakeManager
+}
+
+func (m *restartableManager) Restart(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	m.calls = append(m.calls, "restart "+wl.Name.String())
+	return m.err
+}
+
+func TestCanRestart(t *testing.T) {
+	provisioner := NewMapProvisioner(map[gridtypes.WorkloadType]Manager{
+		"restartable":    &restartableManager{},
+		"unrestartable":  &fakeManager{},
+		testWorkloadType: &testManagerFull{},
+	}).(Restarter)
+
+	ctx := context.Background()
+	require.True(t, provisioner.CanRestart(ctx, "restartable"))
+	require.False(t, provisioner.CanRestart(ctx, "unrestartable"))
+	require.False(t, provisioner.CanRestart(ctx, testWorkloadType))
+	require.False(t, provisioner.CanRestart(ctx, "unknown"))
+}
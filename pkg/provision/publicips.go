@@ -0,0 +1,162 @@
+package provision
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+// publicIPIndex maps the public IPs allocated by ok public ip workloads to
+// their workload. It's built from storage on first use (or on boot) and
+// then kept up to date by the engine after each job.
+type publicIPIndex struct {
+	m     sync.RWMutex
+	ready bool
+	ips   map[string]gridtypes.WorkloadID
+}
+
+// list returns the indexed ips, ok is false if the index is not built yet
+func (i *publicIPIndex) list() (ips []string, ok bool) {
+	i.m.RLock()
+	defer i.m.RUnlock()
+
+	if !i.ready {
+		return nil, false
+	}
+
+	ips = make([]string, 0, len(i.ips))
+	for ip := range i.ips {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	return ips, true
+}
+
+// set replaces all the ips of the deployment with the given ones
+func (i *publicIPIndex) set(twin uint32, contract uint64, ips map[string]gridtypes.WorkloadID) {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	i.drop(twin, contract)
+	if i.ips == nil {
+		i.ips = make(map[string]gridtypes.WorkloadID)
+	}
+
+	for ip, id := range ips {
+		i.ips[ip] = id
+	}
+}
+
+// rebuild replaces the whole index with the ips returned by build. The lock
+// is held while building so a set of a deployment changed during the build
+// waits for it and is applied on top instead of being overwritten.
+func (i *publicIPIndex) rebuild(build func() (map[string]gridtypes.WorkloadID, error)) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	ips, err := build()
+	if err != nil {
+		return err
+	}
+
+	i.ips = ips
+	i.ready = true
+	return nil
+}
+
+func (i *publicIPIndex) drop(twin uint32, contract uint64) {
+	for ip, id := range i.ips {
+		t, c, _, _ := id.Parts()
+		if t == twin && c == contract {
+			delete(i.ips, ip)
+		}
+	}
+}
+
+// deploymentPublicIPs returns the ips allocated by the ok public ip workloads
+// of the deployment
+func deploymentPublicIPs(dl *gridtypes.Deployment) (map[string]gridtypes.WorkloadID, error) {
+	ips := make(map[string]gridtypes.WorkloadID)
+	for _, wl := range dl.ByType(zos.PublicIPv4Type, zos.PublicIPType) {
+		if wl.Result.State != gridtypes.StateOk {
+			continue
+		}
+
+		var result zos.PublicIPResult
+		if err := wl.Result.Unmarshal(&result); err != nil {
+			return nil, errors.Wrapf(err, "invalid result of workload '%s'", wl.ID)
+		}
+
+		if result.IP.IP != nil {
+			ips[result.IP.String()] = wl.ID
+		}
+	}
+
+	return ips, nil
+}
+
+// indexPublicIPs updates the public ips index with the current state of the
+// deployment
+func (e *NativeEngine) indexPublicIPs(twin uint32, contract uint64) {
+	dl, err := e.storage.Get(twin, contract)
+	if errors.Is(err, ErrDeploymentNotExists) {
+		e.publicIPs.set(twin, contract, nil)
+		return
+	} else if err != nil {
+		log.Error().Err(err).Uint32("twin", twin).Uint64("contract", contract).Msg("failed to load deployment for public ips index")
+		return
+	}
+
+	ips, err := deploymentPublicIPs(&dl)
+	if err != nil {
+		log.Error().Err(err).Uint32("twin", twin).Uint64("contract", contract).Msg("failed to index deployment public ips")
+		return
+	}
+
+	e.publicIPs.set(twin, contract, ips)
+}
+
+// rebuildPublicIPs builds the public ips index from all deployments
+func (e *NativeEngine) rebuildPublicIPs() error {
+	return e.publicIPs.rebuild(e.allPublicIPs)
+}
+
+// allPublicIPs returns the ips allocated by the ok public ip workloads of all
+// deployments
+func (e *NativeEngine) allPublicIPs() (map[string]gridtypes.WorkloadID, error) {
+	twins, err := e.storage.Twins()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list twins")
+	}
+
+	all := make(map[string]gridtypes.WorkloadID)
+	for _, twin := range twins {
+		ids, err := e.storage.ByTwin(twin)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list twin deployment")
+		}
+
+		for _, id := range ids {
+			dl, err := e.storage.Get(twin, id)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to load deployment")
+			}
+
+			ips, err := deploymentPublicIPs(&dl)
+			if err != nil {
+				return nil, err
+			}
+
+			for ip, wl := range ips {
+				all[ip] = wl
+			}
+		}
+	}
+
+	return all, nil
+}
@@ -0,0 +1,155 @@
+package provision
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+// deploymentsStorage keeps deployments in memory
+type deploymentsStorage struct {
+	Storage
+
+	deployments map[uint32]map[uint64]gridtypes.Deployment
+}
+
+func (s *deploymentsStorage) Twins() ([]uint32, error) {
+	var twins []uint32
+	for twin := range s.deployments {
+		twins = append(twins, twin)
+	}
+	return twins, nil
+}
+
+func (s *deploymentsStorage) ByTwin(twin uint32) ([]uint64, error) {
+	var ids []uint64
+	for id := range s.deployments[twin] {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s *deploymentsStorage) Get(twin uint32, contract uint64) (gridtypes.Deployment, error) {
+	dl, ok := s.deployments[twin][contract]
+	if !ok {
+		return dl, ErrDeploymentNotExists
+	}
+	return dl, nil
+}
+
+func (s *deploymentsStorage) put(dl gridtypes.Deployment) {
+	if s.deployments[dl.TwinID] == nil {
+		s.deployments[dl.TwinID] = make(map[uint64]gridtypes.Deployment)
+	}
+	s.deployments[dl.TwinID][dl.ContractID] = dl
+}
+
+func publicIPWorkload(t *testing.T, name gridtypes.Name, state gridtypes.ResultState, ip string) gridtypes.Workload {
+	data, err := json.Marshal(zos.PublicIPResult{IP: gridtypes.MustParseIPNet(ip)})
+	require.NoError(t, err)
+
+	return gridtypes.Workload{
+		Name:   name,
+		Type:   zos.PublicIPType,
+		Result: gridtypes.Result{State: state, Data: data},
+	}
+}
+
+func TestPublicIPsIndex(t *testing.T) {
+	storage := &deploymentsStorage{deployments: make(map[uint32]map[uint64]gridtypes.Deployment)}
+	storage.put(gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 1,
+		Workloads: []gridtypes.Workload{
+			publicIPWorkload(t, "ip", gridtypes.StateOk, "185.0.0.1/24"),
+			publicIPWorkload(t, "failed", gridtypes.StateError, "185.0.0.2/24"),
+		},
+	})
+
+	e := &NativeEngine{storage: storage}
+
+	// the index is built from storage on first use (or on boot)
+	ips, err := e.ListPublicIPs()
+	require.NoError(t, err)
+	require.Equal(t, []string{"185.0.0.1/24"}, ips)
+
+	// provision
+	storage.put(gridtypes.Deployment{
+		TwinID:     2,
+		ContractID: 2,
+		Workloads: []gridtypes.Workload{
+			publicIPWorkload(t, "ip", gridtypes.StateOk, "185.0.0.3/24"),
+			publicIPWorkload(t, "ip2", gridtypes.StateOk, "185.0.0.4/24"),
+		},
+	})
+	e.indexPublicIPs(2, 2)
+
+	ips, err = e.ListPublicIPs()
+	require.NoError(t, err)
+	require.Equal(t, []string{"185.0.0.1/24", "185.0.0.3/24", "185.0.0.4/24"}, ips)
+
+	// update, one ip is removed
+	storage.put(gridtypes.Deployment{
+		TwinID:     2,
+		ContractID: 2,
+		Workloads: []gridtypes.Workload{
+			publicIPWorkload(t, "ip", gridtypes.StateOk, "185.0.0.3/24"),
+			publicIPWorkload(t, "ip2", gridtypes.StateDeleted, "185.0.0.4/24"),
+		},
+	})
+	e.indexPublicIPs(2, 2)
+
+	ips, err = e.ListPublicIPs()
+	require.NoError(t, err)
+	require.Equal(t, []string{"185.0.0.1/24", "185.0.0.3/24"}, ips)
+
+	// deprovision
+	delete(storage.deployments[1], 1)
+	e.indexPublicIPs(1, 1)
+
+	ips, err = e.ListPublicIPs()
+	require.NoError(t, err)
+	require.Equal(t, []string{"185.0.0.3/24"}, ips)
+
+	// the index matches a full rebuild
+	require.NoError(t, e.rebuildPublicIPs())
+	rebuilt, err := e.ListPublicIPs()
+	require.NoError(t, err)
+	require.Equal(t, ips, rebuilt)
+}
+
+func TestPublicIPsIndexRebuildRace(t *testing.T) {
+	var index publicIPIndex
+
+	// a deployment is provisioned while the index is rebuilt from a storage
+	// state that doesn't have it yet
+	done := make(chan struct{})
+	err := index.rebuild(func() (map[string]gridtypes.WorkloadID, error) {
+		go func() {
+			defer close(done)
+			index.set(2, 2, map[string]gridtypes.WorkloadID{
+				"185.0.0.3/24": gridtypes.NewUncheckedWorkloadID(2, 2, "ip"),
+			})
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("set didn't wait for the rebuild")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		return map[string]gridtypes.WorkloadID{
+			"185.0.0.1/24": gridtypes.NewUncheckedWorkloadID(1, 1, "ip"),
+		}, nil
+	})
+	require.NoError(t, err)
+	<-done
+
+	ips, ok := index.list()
+	require.True(t, ok)
+	require.Equal(t, []string{"185.0.0.1/24", "185.0.0.3/24"}, ips)
+}
@@ -0,0 +1,476 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/joncrlsn/dque"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultQueue is the name of the default engine job queue
+	DefaultQueue = "jobs"
+	// DefaultQueueSegmentSize is the default number of jobs per queue segment
+	DefaultQueueSegmentSize = 512
+)
+
+// QueueConfig configures a named engine job queue
+type QueueConfig struct {
+	// Name of the queue, it's also the name of the queue directory under
+	// the engine root so it must be unique.
+	Name string
+	// SegmentSize is the number of jobs stored per segment file on disk,
+	// if not set DefaultQueueSegmentSize is used.
+	SegmentSize int
+}
+
+// QueueSelector returns the name of the queue where the job for the
+// given deployment is pushed. An empty or unknown name selects the
+// default queue. The context is the one passed to the engine operation,
+// jobs scheduled by the engine itself (boot, repair) get the engine
+// context.
+type QueueSelector func(ctx context.Context, twin uint32, contract uint64) string
+
+type queueKey struct{}
+
+// WithJobQueue returns a context that routes jobs scheduled with it to the
+// named queue. It's honored by the selector returned from ContextQueueSelector
+func WithJobQueue(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, queueKey{}, name)
+}
+
+// ContextQueueSelector selects the queue set on the context by WithJobQueue
+func ContextQueueSelector(ctx context.Context, _ uint32, _ uint64) string {
+	name, _ := ctx.Value(queueKey{}).(string)
+	return name
+}
+
+// WithQueue configures the name and segment size of the default job queue
+func WithQueue(name string, segmentSize int) EngineOption {
+	return &withQueue{QueueConfig{Name: name, SegmentSize: segmentSize}}
+}
+
+// WithNamedQueues adds more job queues to the engine. The engine processes
+// all queues in a round robin fashion, so a job in one queue never waits
+// for more than a single job of each other queue. The selector decides
+// which queue each job goes to.
+func WithNamedQueues(selector QueueSelector, queues ...QueueConfig) EngineOption {
+	return &withNamedQueues{selector, queues}
+}
+
+type withQueue struct {
+	cfg QueueConfig
+}
+
+func (o *withQueue) apply(e *NativeEngine) {
+	e.queueCfg = o.cfg
+}
+
+type withNamedQueues struct {
+	selector QueueSelector
+	queues   []QueueConfig
+}
+
+func (o *withNamedQueues) apply(e *NativeEngine) {
+	e.selector = o.selector
+	e.namedQueues = append(e.namedQueues, o.queues...)
+}
+
+type jobQueue struct {
+	name string
+	*dque.DQue
+
+	// retries is the number of queued jobs with a due time, and shuffled is
+	// set once the queue order of the jobs of a deployment does not match
+	// the order they were pushed in. While any is set, the next job is
+	// picked by scanning the whole queue.
+	retries  int
+	shuffled bool
+}
+
+// queuePin is the queue where all jobs of a deployment go while it has
+// jobs queued, so they are processed in the order they were pushed
+type queuePin struct {
+	queue *jobQueue
+	jobs  int
+}
+
+// engineQueues is a set of persisted job queues that are consumed
+// in a round robin fashion
+type engineQueues struct {
+	queues []*jobQueue
+	// next is the index of the queue to check first for jobs
+	next int
+	// wake is signaled on each push so a waiting consumer checks
+	// the queues again
+	wake chan struct{}
+
+	pins  map[deploymentValue]*queuePin
+	pinsM sync.Mutex
+}
+
+func openQueues(root string, clean bool, configs ...QueueConfig) (*engineQueues, error) {
+	q := &engineQueues{
+		wake: make(chan struct{}, 1),
+		pins: make(map[deploymentValue]*queuePin),
+	}
+
+	seen := make(map[string]struct{})
+	for _, cfg := range configs {
+		if len(cfg.Name) == 0 {
+			return nil, fmt.Errorf("queue name is required")
+		}
+		if _, ok := seen[cfg.Name]; ok {
+			return nil, fmt.Errorf("duplicate queue name '%s'", cfg.Name)
+		}
+		seen[cfg.Name] = struct{}{}
+
+		size := cfg.SegmentSize
+		if size <= 0 {
+			size = DefaultQueueSegmentSize
+		}
+
+		if clean {
+			os.RemoveAll(filepath.Join(root, cfg.Name))
+		}
+
+		queue, err := dque.NewOrOpen(cfg.Name, root, size, func() interface{} { return &engineJob{} })
+		if err != nil {
+			// if this happens it means data types has been changed in that case we need
+			// to clean up the queue and start over. unfortunately any un applied changes
+			os.RemoveAll(filepath.Join(root, cfg.Name))
+			q.close()
+			return nil, errors.Wrapf(err, "failed to create job queue '%s'", cfg.Name)
+		}
+
+		q.queues = append(q.queues, &jobQueue{name: cfg.Name, DQue: queue})
+	}
+
+	if len(q.queues) == 0 {
+		return nil, fmt.Errorf("at least one queue is required")
+	}
+
+	for _, queue := range q.queues {
+		if err := q.pinQueued(queue); err != nil {
+			q.close()
+			return nil, errors.Wrapf(err, "failed to load job queue '%s'", queue.name)
+		}
+	}
+
+	return q, nil
+}
+
+// pinQueued pins the deployments of the jobs already in the queue to it.
+func (q *engineQueues) pinQueued(queue *jobQueue) error {
+	jobs, err := queue.scan()
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		q.pin(queue, job)
+		if !job.NotBefore.IsZero() {
+			queue.retries++
+		}
+	}
+
+	queue.shuffled = !pushOrder(jobs)
+	return nil
+}
+
+// pin returns the queue the job deployment is pinned to, the deployment is
+// pinned to queue if it has no queued jobs
+func (q *engineQueues) pin(queue *jobQueue, job *engineJob) *jobQueue {
+	key := job.deployment()
+	pin, ok := q.pins[key]
+	if !ok {
+		pin = &queuePin{queue: queue}
+		q.pins[key] = pin
+	}
+
+	pin.jobs++
+	return pin.queue
+}
+
+// unpin releases a job of the deployment, the pin is dropped with the last
+// queued job of the deployment
+func (q *engineQueues) unpin(job *engineJob) {
+	key := job.deployment()
+	pin, ok := q.pins[key]
+	if !ok {
+		return
+	}
+
+	pin.jobs--
+	if pin.jobs <= 0 {
+		delete(q.pins, key)
+	}
+}
+
+// push adds the job to the named queue, or the default (first)
+// queue if no queue with that name exists. If the job deployment still
+// has jobs queued, the job goes to the same queue instead so the jobs of a
+// deployment never run out of order.
+func (q *engineQueues) push(name string, job *engineJob) error {
+	target := q.queues[0]
+	for _, queue := range q.queues {
+		if queue.name == name {
+			target = queue
+			break
+		}
+	}
+
+	q.pinsM.Lock()
+	defer q.pinsM.Unlock()
+
+	target = q.pin(target, job)
+	job.Enqueued = time.Now()
+	if err := target.Enqueue(job); err != nil {
+		q.unpin(job)
+		return err
+	}
+
+	if !job.NotBefore.IsZero() {
+		target.retries++
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// peek blocks until a job is due and returns it with the queue it was found
+// on. The job stays in the queue until it's dequeued from the returned queue.
+// Queues are checked in a round robin order, starting right after the queue
+// that served the previous job. Jobs that are not due yet (see
+// engineJob.NotBefore) don't block the jobs of other deployments behind them.
+func (q *engineQueues) peek(ctx context.Context) (*jobQueue, *engineJob, error) {
+	for {
+		// next is when the earliest job that is not due yet is due
+		var next time.Time
+		now := time.Now()
+		for i := range q.queues {
+			index := (q.next + i) % len(q.queues)
+			queue := q.queues[index]
+			// pushes must not land in the middle of a queue rotation
+			q.pinsM.Lock()
+			job, notBefore, err := queue.due(now)
+			q.pinsM.Unlock()
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to check job queue '%s'", queue.name)
+			}
+
+			if job != nil {
+				q.next = (index + 1) % len(q.queues)
+				return queue, job, nil
+			}
+
+			if !notBefore.IsZero() && (next.IsZero() || notBefore.Before(next)) {
+				next = notBefore
+			}
+		}
+
+		if err := q.wait(ctx, next); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// dequeue removes the job at the head of the queue, it must be called once
+// the job returned by peek is processed
+func (q *engineQueues) dequeue(queue *jobQueue) error {
+	q.pinsM.Lock()
+	defer q.pinsM.Unlock()
+
+	obj, err := queue.Dequeue()
+	if err != nil {
+		return err
+	}
+
+	job := obj.(*engineJob)
+	if !job.NotBefore.IsZero() {
+		queue.retries--
+	}
+
+	q.unpin(job)
+	return nil
+}
+
+// wait blocks until a job is pushed, or until next if it's set
+func (q *engineQueues) wait(ctx context.Context, next time.Time) error {
+	var due <-chan time.Time
+	if !next.IsZero() {
+		timer := time.NewTimer(time.Until(next))
+		defer timer.Stop()
+		due = timer.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-q.wake:
+	case <-due:
+	}
+
+	return nil
+}
+
+// due returns the next job of the queue that is due at now, the job is moved
+// to the head of the queue. Jobs are served in the order they were pushed,
+// except that a job that is not due yet is skipped by the jobs of other
+// deployments. The jobs of its own deployment that were pushed after it wait
+// for it, so the jobs of a deployment always run in order. If no job is due,
+// notBefore is when the earliest of them is due.
+func (q *jobQueue) due(now time.Time) (job *engineJob, notBefore time.Time, err error) {
+	if q.retries == 0 && !q.shuffled {
+		// all jobs are due and in order
+		obj, err := q.Peek()
+		if errors.Is(err, dque.ErrEmpty) {
+			return nil, notBefore, nil
+		} else if err != nil {
+			return nil, notBefore, err
+		}
+
+		return obj.(*engineJob), notBefore, nil
+	}
+
+	jobs, err := q.scan()
+	if err != nil {
+		return nil, notBefore, err
+	}
+
+	// held is when the earliest job of a deployment that is not due yet
+	// was pushed
+	held := make(map[deploymentValue]time.Time)
+	for _, job := range jobs {
+		if !job.NotBefore.After(now) {
+			continue
+		}
+
+		key := job.deployment()
+		if at, ok := held[key]; !ok || job.Enqueued.Before(at) {
+			held[key] = job.Enqueued
+		}
+
+		if notBefore.IsZero() || job.NotBefore.Before(notBefore) {
+			notBefore = job.NotBefore
+		}
+	}
+
+	next := -1
+	for i, job := range jobs {
+		if job.NotBefore.After(now) {
+			continue
+		}
+
+		if at, ok := held[job.deployment()]; ok && !job.Enqueued.Before(at) {
+			continue
+		}
+
+		if next < 0 || job.Enqueued.Before(jobs[next].Enqueued) {
+			next = i
+		}
+	}
+
+	if next < 0 {
+		return nil, notBefore, nil
+	}
+
+	// move the jobs in front of it to the back of the queue, they keep their
+	// enqueue time
+	for _, job := range jobs[:next] {
+		if err := q.rotate(job); err != nil {
+			return nil, notBefore, err
+		}
+	}
+
+	q.shuffled = !pushOrder(append(append([]*engineJob{}, jobs[next:]...), jobs[:next]...))
+	return jobs[next], time.Time{}, nil
+}
+
+// scan returns all the jobs of the queue, the queue is rotated once so the
+// jobs keep their order
+func (q *jobQueue) scan() ([]*engineJob, error) {
+	var jobs []*engineJob
+	for n := q.Size(); n > 0; n-- {
+		obj, err := q.Peek()
+		if err != nil {
+			return nil, err
+		}
+
+		job := obj.(*engineJob)
+		if err := q.rotate(job); err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// rotate moves the job at the head of the queue to its back
+func (q *jobQueue) rotate(job *engineJob) error {
+	if err := q.Enqueue(job); err != nil {
+		return err
+	}
+
+	_, err := q.Dequeue()
+	return err
+}
+
+// pushOrder checks that the jobs of each deployment are in the order they
+// were pushed
+func pushOrder(jobs []*engineJob) bool {
+	last := make(map[deploymentValue]time.Time)
+	for _, job := range jobs {
+		key := job.deployment()
+		if at, ok := last[key]; ok && job.Enqueued.Before(at) {
+			return false
+		}
+		last[key] = job.Enqueued
+	}
+
+	return true
+}
+
+// stats returns the number of jobs in all queues, and when the oldest job at
+// the head of a queue was pushed. Jobs queued before the push time was
+// recorded are not considered for the oldest time.
+func (q *engineQueues) stats() (pending int, oldest time.Time, err error) {
+	for _, queue := range q.queues {
+		pending += queue.Size()
+
+		obj, err := queue.Peek()
+		if errors.Is(err, dque.ErrEmpty) {
+			continue
+		} else if err != nil {
+			return 0, oldest, errors.Wrapf(err, "failed to check job queue '%s'", queue.name)
+		}
+
+		enqueued := obj.(*engineJob).Enqueued
+		if enqueued.IsZero() {
+			continue
+		}
+
+		if oldest.IsZero() || enqueued.Before(oldest) {
+			oldest = enqueued
+		}
+	}
+
+	return pending, oldest, nil
+}
+
+func (q *engineQueues) close() {
+	for _, queue := range q.queues {
+		_ = queue.Close()
+	}
+}
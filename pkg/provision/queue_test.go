@@ -0,0 +1,241 @@
+package provision
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+func TestEngineQueuesRoundRobin(t *testing.T) {
+	root := t.TempDir()
+
+	queues, err := openQueues(root, false,
+		QueueConfig{Name: DefaultQueue},
+		QueueConfig{Name: "admin", SegmentSize: 10},
+	)
+	require.NoError(t, err)
+	defer queues.close()
+
+	job := func(contract uint64) *engineJob {
+		return &engineJob{Target: gridtypes.Deployment{TwinID: 1, ContractID: contract}}
+	}
+
+	// a big backlog on the default queue
+	for i := uint64(1); i <= 5; i++ {
+		require.NoError(t, queues.push("", job(i)))
+	}
+	require.NoError(t, queues.push("admin", job(100)))
+	require.NoError(t, queues.push("admin", job(101)))
+	// unknown queues fall back to the default one
+	require.NoError(t, queues.push("unknown", job(6)))
+
+	ctx := context.Background()
+	var order []uint64
+	for i := 0; i < 8; i++ {
+		queue, job, err := queues.peek(ctx)
+		require.NoError(t, err)
+		order = append(order, job.Target.ContractID)
+		err = queues.dequeue(queue)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, []uint64{1, 100, 2, 101, 3, 4, 5, 6}, order)
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, _, err = queues.peek(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestEngineQueuesNotBefore(t *testing.T) {
+	root := t.TempDir()
+
+	queues, err := openQueues(root, false, QueueConfig{Name: DefaultQueue})
+	require.NoError(t, err)
+
+	// a retry that is not due yet doesn't block the jobs behind it
+	retry := &engineJob{
+		Target:    gridtypes.Deployment{TwinID: 1, ContractID: 1},
+		Attempts:  1,
+		NotBefore: time.Now().Add(200 * time.Millisecond),
+	}
+	require.NoError(t, queues.push("", retry))
+	require.NoError(t, queues.push("", &engineJob{Target: gridtypes.Deployment{TwinID: 1, ContractID: 2}}))
+
+	queue, job, err := queues.peek(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, 2, job.Target.ContractID)
+	err = queues.dequeue(queue)
+	require.NoError(t, err)
+
+	// the retry is persisted, it's still there after a restart
+	queues.close()
+	queues, err = openQueues(root, false, QueueConfig{Name: DefaultQueue})
+	require.NoError(t, err)
+	defer queues.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err = queues.peek(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// and it's served once due
+	_, job, err = queues.peek(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, 1, job.Target.ContractID)
+	require.Equal(t, 1, job.Attempts)
+	require.False(t, time.Now().Before(job.NotBefore))
+}
+
+func TestEngineQueuesNotBeforeOrder(t *testing.T) {
+	queues, err := openQueues(t.TempDir(), false, QueueConfig{Name: DefaultQueue})
+	require.NoError(t, err)
+	defer queues.close()
+
+	job := func(contract uint64, op jobOperation) *engineJob {
+		return &engineJob{Target: gridtypes.Deployment{TwinID: 1, ContractID: contract}, Op: op}
+	}
+
+	// the jobs of 1 wait for its retry, the create and update of 2 are
+	// served right away, in order
+	retry := job(1, opProvisionNoValidation)
+	retry.NotBefore = time.Now().Add(200 * time.Millisecond)
+	require.NoError(t, queues.push("", retry))
+	require.NoError(t, queues.push("", job(1, opUpdate)))
+	require.NoError(t, queues.push("", job(2, opProvision)))
+	require.NoError(t, queues.push("", job(2, opUpdate)))
+
+	type entry struct {
+		contract uint64
+		op       jobOperation
+	}
+
+	next := func() entry {
+		queue, job, err := queues.peek(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, queues.dequeue(queue))
+		return entry{job.Target.ContractID, job.Op}
+	}
+
+	require.Equal(t, entry{2, opProvision}, next())
+	require.Equal(t, entry{2, opUpdate}, next())
+
+	// a new job of 2 is not held by the retry either
+	require.NoError(t, queues.push("", job(2, opDeprovision)))
+	require.Equal(t, entry{2, opDeprovision}, next())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err = queues.peek(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.Equal(t, entry{1, opProvisionNoValidation}, next())
+	require.Equal(t, entry{1, opUpdate}, next())
+	require.Zero(t, queues.queues[0].Size())
+}
+
+func TestEngineQueuesInvalid(t *testing.T) {
+	root := t.TempDir()
+
+	_, err := openQueues(root, false, QueueConfig{Name: "a"}, QueueConfig{Name: "a"})
+	require.Error(t, err)
+
+	_, err = openQueues(root, false, QueueConfig{})
+	require.Error(t, err)
+}
+
+func TestContextQueueSelector(t *testing.T) {
+	ctx := context.Background()
+	require.Empty(t, ContextQueueSelector(ctx, 1, 1))
+	require.Equal(t, "admin", ContextQueueSelector(WithJobQueue(ctx, "admin"), 1, 1))
+}
+
+func TestEngineQueuesStats(t *testing.T) {
+	root := t.TempDir()
+
+	queues, err := openQueues(root, false,
+		QueueConfig{Name: DefaultQueue},
+		QueueConfig{Name: "admin"},
+	)
+	require.NoError(t, err)
+	defer queues.close()
+
+	pending, oldest, err := queues.stats()
+	require.NoError(t, err)
+	require.Equal(t, 0, pending)
+	require.True(t, oldest.IsZero())
+
+	before := time.Now()
+	for i := uint64(1); i <= 2; i++ {
+		require.NoError(t, queues.push("", &engineJob{Target: gridtypes.Deployment{TwinID: 1, ContractID: i}}))
+	}
+	require.NoError(t, queues.push("admin", &engineJob{Target: gridtypes.Deployment{TwinID: 1, ContractID: 3}}))
+
+	time.Sleep(10 * time.Millisecond)
+	pending, oldest, err = queues.stats()
+	require.NoError(t, err)
+	require.Equal(t, 3, pending)
+	require.False(t, oldest.IsZero())
+	require.False(t, oldest.Before(before))
+	require.Greater(t, time.Since(oldest), time.Duration(0))
+
+	// stats never dequeue jobs
+	pending, _, err = queues.stats()
+	require.NoError(t, err)
+	require.Equal(t, 3, pending)
+}
+
+func TestEngineQueuesPinned(t *testing.T) {
+	root := t.TempDir()
+	configs := []QueueConfig{{Name: DefaultQueue}, {Name: "admin"}}
+
+	queues, err := openQueues(root, false, configs...)
+	require.NoError(t, err)
+
+	job := func(contract uint64, op jobOperation) *engineJob {
+		return &engineJob{Target: gridtypes.Deployment{TwinID: 1, ContractID: contract}, Op: op}
+	}
+
+	// the deprovision of 1 is selected for the admin queue, but it must
+	// still run after the provision of 1
+	require.NoError(t, queues.push("", job(1, opProvision)))
+	require.NoError(t, queues.push("admin", job(2, opProvision)))
+	require.NoError(t, queues.push("admin", job(1, opDeprovision)))
+
+	type entry struct {
+		queue    string
+		contract uint64
+		op       jobOperation
+	}
+
+	ctx := context.Background()
+	next := func() entry {
+		queue, job, err := queues.peek(ctx)
+		require.NoError(t, err)
+		require.NoError(t, queues.dequeue(queue))
+		return entry{queue.name, job.Target.ContractID, job.Op}
+	}
+
+	require.Equal(t, entry{DefaultQueue, 1, opProvision}, next())
+	require.Equal(t, entry{"admin", 2, opProvision}, next())
+	require.Equal(t, entry{DefaultQueue, 1, opDeprovision}, next())
+
+	// no jobs are queued for 1 anymore, so it's not pinned
+	require.NoError(t, queues.push("admin", job(1, opPause)))
+	require.Equal(t, entry{"admin", 1, opPause}, next())
+
+	// pins survive a restart
+	require.NoError(t, queues.push("", job(3, opProvision)))
+	queues.close()
+
+	queues, err = openQueues(root, false, configs...)
+	require.NoError(t, err)
+	defer queues.close()
+
+	require.NoError(t, queues.push("admin", job(3, opDeprovision)))
+	require.Equal(t, entry{DefaultQueue, 3, opProvision}, next())
+	require.Equal(t, entry{DefaultQueue, 3, opDeprovision}, next())
+}
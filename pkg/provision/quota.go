@@ -0,0 +1,175 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+// ErrQuotaExceeded is returned (wrapped in a QuotaError) if a deployment
+// would make its twin exceed its quota
+var ErrQuotaExceeded = fmt.Errorf("twin quota exceeded")
+
+// TwinQuotas are the quotas of the twins on the node, admin twins are
+// never subject to a quota.
+type TwinQuotas struct {
+	// Default is the quota of all twins
+	Default pkg.TwinQuota
+	// Twins overrides the default quota of specific twins
+	Twins map[uint32]pkg.TwinQuota
+}
+
+// Get returns the quota of the twin
+func (q *TwinQuotas) Get(twin uint32) pkg.TwinQuota {
+	if quota, ok := q.Twins[twin]; ok {
+		return quota
+	}
+
+	return q.Default
+}
+
+// QuotaError is returned if a deployment exceeds the quota of its twin
+type QuotaError struct {
+	Twin uint32
+	// Limit is the name of the exceeded limit
+	Limit string
+	Value uint64
+	Max   uint64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: twin %d %s would be %d, max allowed is %d", ErrQuotaExceeded, e.Twin, e.Limit, e.Value, e.Max)
+}
+
+// Is makes errors.Is(err, ErrQuotaExceeded) match
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// checkQuota makes sure the twin usage plus the deployment capacity does not
+// exceed the quota. usage must not include the deployment itself.
+func checkQuota(quota pkg.TwinQuota, usage pkg.TwinUsage, capacity gridtypes.Capacity) error {
+	deployments := usage.Deployments + 1
+	if quota.MaxDeployments > 0 && deployments > quota.MaxDeployments {
+		return &QuotaError{Twin: usage.TwinID, Limit: "deployments count", Value: uint64(deployments), Max: uint64(quota.MaxDeployments)}
+	}
+
+	memory := usage.Capacity.MRU + capacity.MRU
+	if quota.MaxMemory > 0 && memory > quota.MaxMemory {
+		return &QuotaError{Twin: usage.TwinID, Limit: "memory", Value: uint64(memory), Max: uint64(quota.MaxMemory)}
+	}
+
+	cpu := usage.Capacity.CRU + capacity.CRU
+	if quota.MaxCPU > 0 && cpu > quota.MaxCPU {
+		return &QuotaError{Twin: usage.TwinID, Limit: "cpu", Value: cpu, Max: quota.MaxCPU}
+	}
+
+	return nil
+}
+
+// checkTwinQuota makes sure the deployment does not make its twin exceed
+// its quota. On update, the current version of the deployment is replaced
+// by the new one.
+func (e *NativeEngine) checkTwinQuota(deployment *gridtypes.Deployment) error {
+	quota := e.quotas.Get(deployment.TwinID)
+	if quota == (pkg.TwinQuota{}) || e.isAdmin(deployment.TwinID) {
+		return nil
+	}
+
+	usage, err := e.twinUsage(deployment.TwinID, deployment.ContractID)
+	if err != nil {
+		return err
+	}
+
+	var capacity gridtypes.Capacity
+	for i := range deployment.Workloads {
+		c, err := deployment.Workloads[i].Capacity()
+		if err != nil {
+			return err
+		}
+		capacity.Add(&c)
+	}
+
+	return checkQuota(quota, usage, capacity)
+}
+
+func (e *NativeEngine) isAdmin(twin uint32) bool {
+	if e.admins == nil {
+		return false
+	}
+
+	_, err := e.admins.GetKey(twin)
+	return err == nil
+}
+
+// twinUsage computes what the twin is using from storage, excluding the
+// given deployment. Deleted and failed workloads are not counted.
+func (e *NativeEngine) twinUsage(twin uint32, exclude uint64) (pkg.TwinUsage, error) {
+	usage := pkg.TwinUsage{TwinID: twin}
+	ids, err := e.storage.ByTwin(twin)
+	if err != nil {
+		return usage, err
+	}
+
+	for _, id := range ids {
+		if id == exclude {
+			continue
+		}
+
+		deployment, err := e.storage.Get(twin, id)
+		if err != nil {
+			return usage, err
+		}
+
+		active := false
+		for i := range deployment.Workloads {
+			wl := &deployment.Workloads[i]
+			if wl.Result.State.IsAny(gridtypes.StateDeleted, gridtypes.StateError) {
+				continue
+			}
+
+			active = true
+			c, err := wl.Capacity()
+			if err != nil {
+				log.Error().Err(err).Uint32("twin", twin).Uint64("contract", id).Str("name", wl.Name.String()).Msg("failed to compute workload capacity")
+				continue
+			}
+			usage.Capacity.Add(&c)
+		}
+
+		if active {
+			usage.Deployments++
+		}
+	}
+
+	return usage, nil
+}
+
+// TwinsUsage returns the usage and the quota of all twins that have
+// active deployments on the node
+func (e *NativeEngine) TwinsUsage() ([]pkg.TwinUsage, error) {
+	twins, err := e.storage.Twins()
+	if err != nil {
+		return nil, err
+	}
+
+	list := []pkg.TwinUsage{}
+	for _, twin := range twins {
+		usage, err := e.twinUsage(twin, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if usage.Deployments == 0 {
+			continue
+		}
+
+		usage.Quota = e.quotas.Get(twin)
+		usage.Exempt = e.isAdmin(twin)
+		list = append(list, usage)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,62 @@
+package provision
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+func TestTwinQuotas(t *testing.T) {
+	quotas := TwinQuotas{
+		Default: pkg.TwinQuota{MaxDeployments: 2},
+		Twins: map[uint32]pkg.TwinQuota{
+			10: {MaxDeployments: 5},
+		},
+	}
+
+	require.Equal(t, 2, quotas.Get(1).MaxDeployments)
+	require.Equal(t, 5, quotas.Get(10).MaxDeployments)
+
+	var empty TwinQuotas
+	require.Equal(t, pkg.TwinQuota{}, empty.Get(1))
+}
+
+func TestCheckQuota(t *testing.T) {
+	quota := pkg.TwinQuota{
+		MaxDeployments: 2,
+		MaxMemory:      4 * gridtypes.Gigabyte,
+		MaxCPU:         4,
+	}
+
+	usage := pkg.TwinUsage{
+		TwinID:      1,
+		Deployments: 1,
+		Capacity:    gridtypes.Capacity{CRU: 2, MRU: 2 * gridtypes.Gigabyte},
+	}
+
+	require.NoError(t, checkQuota(quota, usage, gridtypes.Capacity{CRU: 2, MRU: 2 * gridtypes.Gigabyte}))
+
+	err := checkQuota(quota, usage, gridtypes.Capacity{CRU: 1, MRU: 3 * gridtypes.Gigabyte})
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	var quotaErr *QuotaError
+	require.True(t, errors.As(err, &quotaErr))
+	require.Equal(t, "memory", quotaErr.Limit)
+	require.Equal(t, uint64(5*gridtypes.Gigabyte), quotaErr.Value)
+
+	err = checkQuota(quota, usage, gridtypes.Capacity{CRU: 3})
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	require.True(t, errors.As(err, &quotaErr))
+	require.Equal(t, "cpu", quotaErr.Limit)
+
+	usage.Deployments = 2
+	err = checkQuota(quota, usage, gridtypes.Capacity{})
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	require.True(t, errors.As(err, &quotaErr))
+	require.Equal(t, "deployments count", quotaErr.Limit)
+
+	// no limits
+	require.NoError(t, checkQuota(pkg.TwinQuota{}, usage, gridtypes.Capacity{CRU: 100}))
+}
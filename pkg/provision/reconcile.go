@@ -0,0 +1,87 @@
+package provision
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+type bootKey struct{}
+
+// withBoot marks the context as a boot reconcile of stored deployments
+func withBoot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bootKey{}, true)
+}
+
+func isBoot(ctx context.Context) bool {
+	boot, _ := ctx.Value(bootKey{}).(bool)
+	return boot
+}
+
+// bootReconcile records what the engine did with each stored
+// workload when re-installing deployments on boot.
+type bootReconcile struct {
+	m       sync.Mutex
+	results []pkg.WorkloadReconcile
+}
+
+func (r *bootReconcile) reset() {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.results = nil
+}
+
+func (r *bootReconcile) record(wl *gridtypes.WorkloadWithID, outcome pkg.ReconcileOutcome, reason string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.results = append(r.results, pkg.WorkloadReconcile{
+		ID:      wl.ID.String(),
+		Type:    wl.Type.String(),
+		Outcome: outcome,
+		Error:   reason,
+		Time:    time.Now(),
+	})
+}
+
+func (r *bootReconcile) list() []pkg.WorkloadReconcile {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	results := make([]pkg.WorkloadReconcile, len(r.results))
+	copy(results, r.results)
+	return results
+}
+
+// reconciled records the outcome of a workload installation if it
+// happens as part of the boot reconcile, otherwise it does nothing.
+func (e *NativeEngine) reconciled(ctx context.Context, wl *gridtypes.WorkloadWithID, outcome pkg.ReconcileOutcome, reason string) {
+	if !isBoot(ctx) {
+		return
+	}
+
+	e.reconcile.record(wl, outcome, reason)
+}
+
+// BootReconcile returns the outcome of each stored workload that the
+// engine re-installed on boot. Deployments that are still waiting in
+// the queue are not part of the result yet.
+func (e *NativeEngine) BootReconcile() ([]pkg.WorkloadReconcile, error) {
+	return e.reconcile.list(), nil
+}
+
+// reconcileFailed records all workloads of the deployment as failed, it's
+// used when the deployment itself can't be processed on boot.
+func (e *NativeEngine) reconcileFailed(ctx context.Context, dl *gridtypes.Deployment, err error) {
+	for i := range dl.Workloads {
+		wl := &dl.Workloads[i]
+		e.reconciled(ctx, &gridtypes.WorkloadWithID{
+			Workload: wl,
+			ID:       gridtypes.NewUncheckedWorkloadID(dl.TwinID, dl.ContractID, wl.Name),
+		}, pkg.ReconcileFailed, err.Error())
+	}
+}
@@ -0,0 +1,45 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+func TestBootReconcile(t *testing.T) {
+	e := &NativeEngine{}
+
+	dl := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 10,
+		Workloads: []gridtypes.Workload{
+			{Name: "vm", Type: zos.ZMachineType},
+			{Name: "net", Type: zos.NetworkType},
+		},
+	}
+
+	// jobs that are not part of the boot are not recorded
+	e.reconcileFailed(context.Background(), &dl, fmt.Errorf("no contract"))
+	results, err := e.BootReconcile()
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	e.reconcileFailed(withBoot(context.Background()), &dl, fmt.Errorf("no contract"))
+	results, err = e.BootReconcile()
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, "1-10-vm", results[0].ID)
+	require.Equal(t, zos.ZMachineType.String(), results[0].Type)
+	require.Equal(t, pkg.ReconcileFailed, results[0].Outcome)
+	require.Equal(t, "no contract", results[0].Error)
+
+	e.reconcile.reset()
+	results, err = e.BootReconcile()
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
@@ -0,0 +1,165 @@
+package provision
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+const (
+	defaultRepairInterval = 10 * time.Minute
+	defaultRepairCooldown = 30 * time.Minute
+)
+
+// driftRepair keeps track of the workloads that has been repaired
+// so the same workload is not re-installed in a tight loop if the
+// repair does not fix the drift.
+type driftRepair struct {
+	detector DriftDetector
+	interval time.Duration
+	cooldown time.Duration
+
+	m        sync.Mutex
+	repaired map[gridtypes.WorkloadID]time.Time
+}
+
+func newDriftRepair(detector DriftDetector, interval, cooldown time.Duration) *driftRepair {
+	if interval <= 0 {
+		interval = defaultRepairInterval
+	}
+	if cooldown <= 0 {
+		cooldown = defaultRepairCooldown
+	}
+
+	return &driftRepair{
+		detector: detector,
+		interval: interval,
+		cooldown: cooldown,
+		repaired: make(map[gridtypes.WorkloadID]time.Time),
+	}
+}
+
+// allow returns true if workload can be repaired now, and marks it
+// as repaired.
+func (r *driftRepair) allow(id gridtypes.WorkloadID, now time.Time) bool {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if last, ok := r.repaired[id]; ok && now.Sub(last) < r.cooldown {
+		return false
+	}
+
+	r.repaired[id] = now
+	// drop old entries so the map doesn't grow forever
+	for wid, last := range r.repaired {
+		if now.Sub(last) >= r.cooldown {
+			delete(r.repaired, wid)
+		}
+	}
+
+	return true
+}
+
+// drifted returns a copy of the deployment that only has the workloads
+// that are in ok state but drifted, and are allowed to be repaired.
+func (r *driftRepair) drifted(ctx context.Context, dl *gridtypes.Deployment, now time.Time) (gridtypes.Deployment, bool) {
+	target := *dl
+	target.Workloads = nil
+
+	for i := range dl.Workloads {
+		wl := &dl.Workloads[i]
+		if wl.Result.State != gridtypes.StateOk {
+			continue
+		}
+
+		if !r.detector.Drifted(ctx, dl.TwinID, dl.ContractID, wl) {
+			continue
+		}
+
+		id := gridtypes.NewUncheckedWorkloadID(dl.TwinID, dl.ContractID, wl.Name)
+		if !r.allow(id, now) {
+			log.Debug().Stringer("id", id).Msg("drifted workload was repaired recently, skipping")
+			continue
+		}
+
+		target.Workloads = append(target.Workloads, *wl)
+	}
+
+	return target, len(target.Workloads) > 0
+}
+
+func (e *NativeEngine) repairLoop(ctx context.Context) {
+	log.Info().Dur("interval", e.repair.interval).Msg("starting drifted workloads repair")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.repair.interval):
+		}
+
+		if err := e.repairDrifted(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to repair drifted workloads")
+		}
+	}
+}
+
+// repairDrifted finds all workloads that are ok in storage but not running
+// and schedule them for re-installation
+func (e *NativeEngine) repairDrifted(ctx context.Context) error {
+	twins, err := e.storage.Twins()
+	if err != nil {
+		return err
+	}
+
+	for _, twin := range twins {
+		ids, err := e.storage.ByTwin(twin)
+		if err != nil {
+			log.Error().Err(err).Uint32("twin", twin).Msg("failed to list deployments for twin")
+			continue
+		}
+
+		for _, id := range ids {
+			dl, err := e.storage.Get(twin, id)
+			if err != nil {
+				log.Error().Err(err).Uint32("twin", twin).Uint64("id", id).Msg("failed to load deployment")
+				continue
+			}
+
+			if !dl.IsActive() {
+				continue
+			}
+
+			target, ok := e.repair.drifted(ctx, &dl, time.Now())
+			if !ok {
+				continue
+			}
+
+			for _, wl := range target.Workloads {
+				log.Warn().
+					Uint32("twin", twin).
+					Uint64("contract", id).
+					Stringer("name", wl.Name).
+					Str("type", wl.Type.String()).
+					Msg("workload drifted from recorded state, scheduling repair")
+			}
+
+			job := engineJob{
+				Target: target,
+				Op:     opProvisionNoValidation,
+			}
+
+			if err := e.enqueue(ctx, &job); err != nil {
+				log.Error().
+					Err(err).
+					Uint32("twin", twin).
+					Uint64("contract", id).
+					Msg("failed to queue drifted workloads for repair")
+			}
+		}
+	}
+
+	return nil
+}
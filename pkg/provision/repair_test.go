@@ -0,0 +1,41 @@
+package provision
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+func TestDriftRepairCooldown(t *testing.T) {
+	detector := DriftDetectorFn(func(ctx context.Context, twin uint32, contract uint64, wl *gridtypes.Workload) bool {
+		return wl.Name == "vm"
+	})
+
+	repair := newDriftRepair(detector, time.Minute, 10*time.Minute)
+
+	dl := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 10,
+		Workloads: []gridtypes.Workload{
+			{Name: "vm", Result: gridtypes.Result{State: gridtypes.StateOk}},
+			{Name: "net", Result: gridtypes.Result{State: gridtypes.StateOk}},
+			{Name: "old", Result: gridtypes.Result{State: gridtypes.StateError}},
+		},
+	}
+
+	now := time.Now()
+	target, ok := repair.drifted(context.Background(), &dl, now)
+	require.True(t, ok)
+	require.Len(t, target.Workloads, 1)
+	require.Equal(t, gridtypes.Name("vm"), target.Workloads[0].Name)
+	require.Len(t, dl.Workloads, 3)
+
+	_, ok = repair.drifted(context.Background(), &dl, now.Add(5*time.Minute))
+	require.False(t, ok)
+
+	_, ok = repair.drifted(context.Background(), &dl, now.Add(11*time.Minute))
+	require.True(t, ok)
+}
@@ -0,0 +1,107 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+// Restart schedules a restart of a single workload of the deployment, the
+// workload is restarted in place by the engine loop, keeping everything it
+// allocated. Only
+// ok workloads of types supported by the provisioner Restarter can be
+// restarted, otherwise ErrRestartNotSupported is returned.
+func (e *NativeEngine) Restart(ctx context.Context, twin uint32, id uint64, name gridtypes.Name) error {
+	deployment, err := e.storage.Get(twin, id)
+	if err != nil {
+		return err
+	}
+
+	wl, err := deployment.Get(name)
+	if err != nil {
+		return err
+	}
+
+	restarter, ok := e.provisioner.(Restarter)
+	if !ok || !restarter.CanRestart(ctx, wl.Type) {
+		return errors.Wrapf(ErrRestartNotSupported, "workload type '%s'", wl.Type)
+	}
+
+	if wl.Result.State != gridtypes.StateOk {
+		return fmt.Errorf("can only restart workloads in ok state, workload is in '%s' state", wl.Result.State)
+	}
+
+	log.Info().
+		Uint32("twin", twin).
+		Uint64("contract", id).
+		Stringer("name", name).
+		Msg("schedule for restart")
+
+	target := deployment
+	target.Workloads = []gridtypes.Workload{*wl.Workload}
+
+	job := engineJob{
+		Target: target,
+		Op:     opRestart,
+	}
+
+	return e.enqueue(ctx, &job)
+}
+
+// restartWorkloads restarts the target workloads that are still ok
+func (e *NativeEngine) restartWorkloads(ctx context.Context, target *gridtypes.Deployment) {
+	for _, wl := range target.Workloads {
+		// the workload might have changed since the job was queued
+		current, err := e.storage.Current(target.TwinID, target.ContractID, wl.Name)
+		if err != nil {
+			log.Error().Err(err).Stringer("name", wl.Name).Msg("failed to get workload current state")
+			continue
+		}
+
+		if current.Result.State != gridtypes.StateOk {
+			log.Warn().Stringer("name", wl.Name).Str("state", string(current.Result.State)).Msg("skipping restart of workload that is not ok")
+			continue
+		}
+
+		id := gridtypes.NewUncheckedWorkloadID(target.TwinID, target.ContractID, wl.Name)
+		if err := e.restartWorkload(ctx, &gridtypes.WorkloadWithID{Workload: &current, ID: id}); err != nil {
+			log.Error().Err(err).Stringer("id", id).Msg("failed to restart workload")
+		}
+	}
+}
+
+func (e *NativeEngine) restartWorkload(ctx context.Context, wl *gridtypes.WorkloadWithID) error {
+	twin, deployment, _, _ := wl.ID.Parts()
+	log := log.With().
+		Uint32("twin", twin).
+		Uint64("deployment", deployment).
+		Stringer("name", wl.Name).
+		Str("type", wl.Type.String()).
+		Logger()
+
+	log.Info().Msg("restarting")
+
+	restarter, ok := e.provisioner.(Restarter)
+	if !ok {
+		return errors.Wrapf(ErrRestartNotSupported, "workload type '%s'", wl.Type)
+	}
+
+	ctx, cancel := e.workloadContext(ctx)
+	defer cancel()
+
+	result, err := restarter.Restart(ctx, wl)
+	if err != nil {
+		result.Created = gridtypes.Now()
+		result.State = gridtypes.StateError
+		result.Error = err.Error()
+	}
+
+	if result.State == gridtypes.StateError {
+		log.Error().Str("error", result.Error).Msg("failed to restart workload")
+	}
+
+	return e.transaction(twin, deployment, wl.WithResults(result))
+}
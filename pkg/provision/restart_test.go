@@ -0,0 +1,130 @@
+package provision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+// restartStorage records the transactions of restarted workloads
+type restartStorage struct {
+	*deploymentsStorage
+
+	transactions []gridtypes.Workload
+}
+
+func (s *restartStorage) Current(twin uint32, deployment uint64, name gridtypes.Name) (gridtypes.Workload, error) {
+	dl, err := s.Get(twin, deployment)
+	if err != nil {
+		return gridtypes.Workload{}, err
+	}
+
+	wl, err := dl.Get(name)
+	if err != nil {
+		return gridtypes.Workload{}, ErrWorkloadNotExist
+	}
+
+	return *wl.Workload, nil
+}
+
+func (s *restartStorage) Transaction(twin uint32, deployment uint64, wl gridtypes.Workload) error {
+	s.transactions = append(s.transactions, wl)
+	return nil
+}
+
+// restartProvisioner can restart vms only, the returned manager records the
+// vm calls
+func restartProvisioner(err error) (Provisioner, *restartableManager) {
+	vms := &restartableManager{fakeManager{err: err}}
+
+	return NewMapProvisioner(map[gridtypes.WorkloadType]Manager{
+		zos.ZMachineType: vms,
+		zos.ZMountType:   &fakeManager{},
+	}), vms
+}
+
+func restartDeployment() gridtypes.Deployment {
+	return gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 1,
+		Workloads: []gridtypes.Workload{
+			{Name: "vm", Type: zos.ZMachineType, Result: gridtypes.Result{State: gridtypes.StateOk, Data: json.RawMessage(`{"ip":"10.20.1.2"}`)}},
+			{Name: "paused", Type: zos.ZMachineType, Result: gridtypes.Result{State: gridtypes.StatePaused}},
+			{Name: "disk", Type: zos.ZMountType, Result: gridtypes.Result{State: gridtypes.StateOk}},
+		},
+	}
+}
+
+func TestRestart(t *testing.T) {
+	storage := &restartStorage{
+		deploymentsStorage: &deploymentsStorage{deployments: make(map[uint32]map[uint64]gridtypes.Deployment)},
+	}
+	storage.put(restartDeployment())
+
+	provisioner, vms := restartProvisioner(nil)
+	e, err := New(storage, provisioner, t.TempDir())
+	require.NoError(t, err)
+	defer e.queues.close()
+
+	ctx := context.Background()
+	err = e.Restart(ctx, 1, 1, "disk")
+	require.ErrorIs(t, err, ErrRestartNotSupported)
+
+	err = e.Restart(ctx, 1, 1, "paused")
+	require.Error(t, err)
+
+	err = e.Restart(ctx, 1, 1, "unknown")
+	require.Error(t, err)
+
+	require.NoError(t, e.Restart(ctx, 1, 1, "vm"))
+
+	_, job, err := e.queues.peek(ctx)
+	require.NoError(t, err)
+	require.Equal(t, opRestart, job.Op)
+	require.Len(t, job.Target.Workloads, 1)
+	require.Equal(t, gridtypes.Name("vm"), job.Target.Workloads[0].Name)
+
+	e.restartWorkloads(ctx, &job.Target)
+	// the vm is restarted in place, it keeps its data
+	require.Equal(t, []string{"restart vm"}, vms.calls)
+	require.Len(t, storage.transactions, 1)
+	require.Equal(t, gridtypes.Name("vm"), storage.transactions[0].Name)
+	require.Equal(t, gridtypes.StateOk, storage.transactions[0].Result.State)
+	require.JSONEq(t, `{"ip":"10.20.1.2"}`, string(storage.transactions[0].Result.Data))
+}
+
+func TestRestartFailure(t *testing.T) {
+	storage := &restartStorage{
+		deploymentsStorage: &deploymentsStorage{deployments: make(map[uint32]map[uint64]gridtypes.Deployment)},
+	}
+	storage.put(restartDeployment())
+
+	provisioner, vms := restartProvisioner(fmt.Errorf("failed to start vm"))
+	e := &NativeEngine{storage: storage, provisioner: provisioner}
+
+	dl := restartDeployment()
+	dl.Workloads = dl.Workloads[:2]
+	e.restartWorkloads(context.Background(), &dl)
+
+	// paused workloads are skipped, even if queued
+	require.Equal(t, []string{"restart vm"}, vms.calls)
+	require.Len(t, storage.transactions, 1)
+	require.Equal(t, gridtypes.StateError, storage.transactions[0].Result.State)
+	require.Equal(t, "failed to start vm", storage.transactions[0].Result.Error)
+}
+
+func TestRestartNotSupported(t *testing.T) {
+	storage := &restartStorage{
+		deploymentsStorage: &deploymentsStorage{deployments: make(map[uint32]map[uint64]gridtypes.Deployment)},
+	}
+	storage.put(restartDeployment())
+
+	e := &NativeEngine{storage: storage, provisioner: &hungProvisioner{}}
+	err := e.Restart(context.Background(), 1, 1, "vm")
+	require.ErrorIs(t, err, ErrRestartNotSupported)
+}
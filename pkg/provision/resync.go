@@ -0,0 +1,91 @@
+package provision
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+// ResyncPublicIPRules schedules re-applying the firewall rules of all active
+// public ip workloads on the node, from the state recorded in storage. It
+// recovers the rules if they were changed or flushed from outside. The rules
+// are re-applied by the engine loop, so this returns once the jobs are queued.
+func (e *NativeEngine) ResyncPublicIPRules() error {
+	twins, err := e.storage.Twins()
+	if err != nil {
+		return errors.Wrap(err, "failed to list twins")
+	}
+
+	for _, twin := range twins {
+		ids, err := e.storage.ByTwin(twin)
+		if err != nil {
+			return errors.Wrap(err, "failed to list twin deployment")
+		}
+
+		for _, id := range ids {
+			dl, err := e.storage.Get(twin, id)
+			if err != nil {
+				return errors.Wrap(err, "failed to load deployment")
+			}
+
+			target := dl
+			target.Workloads = nil
+			for _, wl := range dl.ByType(zos.PublicIPv4Type, zos.PublicIPType) {
+				if wl.Result.State == gridtypes.StateOk {
+					target.Workloads = append(target.Workloads, *wl.Workload)
+				}
+			}
+
+			if len(target.Workloads) == 0 {
+				continue
+			}
+
+			log.Info().
+				Uint32("twin", twin).
+				Uint64("contract", id).
+				Msg("schedule public ip rules resync")
+
+			job := engineJob{
+				Target: target,
+				Op:     opResync,
+			}
+
+			if err := e.enqueue(context.Background(), &job); err != nil {
+				return errors.Wrap(err, "failed to queue public ip rules resync")
+			}
+		}
+	}
+
+	return nil
+}
+
+// resyncWorkloads re-applies the system configuration of all the target
+// workloads that are still active
+func (e *NativeEngine) resyncWorkloads(ctx context.Context, target *gridtypes.Deployment) {
+	resyncer, ok := e.provisioner.(Resyncer)
+	if !ok {
+		log.Error().Msg("provisioner does not support resync")
+		return
+	}
+
+	for _, wl := range target.Workloads {
+		// the workload might have changed since the job was queued
+		current, err := e.storage.Current(target.TwinID, target.ContractID, wl.Name)
+		if err != nil {
+			log.Error().Err(err).Stringer("name", wl.Name).Msg("failed to get workload current state")
+			continue
+		}
+
+		if current.Result.State != gridtypes.StateOk {
+			continue
+		}
+
+		id := gridtypes.NewUncheckedWorkloadID(target.TwinID, target.ContractID, wl.Name)
+		if err := resyncer.Resync(ctx, &gridtypes.WorkloadWithID{Workload: &current, ID: id}); err != nil {
+			log.Error().Err(err).Stringer("id", id).Msg("failed to resync workload")
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package provision
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultRetryAttempts is the default max number of attempts to install
+	// a deployment with workloads that failed with a retryable error
+	DefaultRetryAttempts = 5
+	// DefaultRetryInterval is the default delay before the first retry, it's
+	// doubled on each retry up to maxRetryInterval
+	DefaultRetryInterval = 10 * time.Second
+
+	maxRetryInterval = 5 * time.Minute
+)
+
+// WithProvisionRetry sets how many times a deployment is installed if some
+// of its workloads fail with a retryable error (see ErrRetryable), and the
+// delay before the first retry. The delay is doubled on each retry.
+func WithProvisionRetry(attempts int, interval time.Duration) EngineOption {
+	return &withProvisionRetry{attempts: attempts, interval: interval}
+}
+
+type withProvisionRetry struct {
+	attempts int
+	interval time.Duration
+}
+
+func (w *withProvisionRetry) apply(e *NativeEngine) {
+	e.retryAttempts = w.attempts
+	e.retryInterval = w.interval
+}
+
+type retryKey struct{}
+
+// retryState is the retry state of the job being processed
+type retryState struct {
+	// attempt is the current attempt number, starting at 1
+	attempt int
+	// last is true if this is the last allowed attempt
+	last bool
+
+	m     sync.Mutex
+	retry bool
+}
+
+func withRetry(ctx context.Context, state *retryState) context.Context {
+	return context.WithValue(ctx, retryKey{}, state)
+}
+
+func getRetry(ctx context.Context) *retryState {
+	state, _ := ctx.Value(retryKey{}).(*retryState)
+	return state
+}
+
+// shouldRetry checks if the job can be retried later, if so it marks it for
+// a retry.
+func (s *retryState) shouldRetry() bool {
+	if s == nil || s.last {
+		return false
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.retry = true
+	return true
+}
+
+func (s *retryState) retried() bool {
+	if s == nil {
+		return false
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.retry
+}
+
+// attempts returns the attempts count of a failed workload
+func (s *retryState) attempts() int {
+	if s == nil {
+		return 1
+	}
+
+	return s.attempt
+}
+
+// jobRetry returns the retry state of the job. Only provision jobs are
+// retried, nil is returned for other jobs.
+func (e *NativeEngine) jobRetry(job *engineJob) *retryState {
+	if e.retryAttempts <= 1 {
+		return nil
+	}
+
+	switch job.Op {
+	case opProvision, opProvisionNoValidation:
+	default:
+		return nil
+	}
+
+	attempt := job.Attempts + 1
+	return &retryState{
+		attempt: attempt,
+		last:    attempt >= e.retryAttempts,
+	}
+}
+
+// retryDelay is the delay before the given retry (starting at 1)
+func (e *NativeEngine) retryDelay(retry int) time.Duration {
+	delay := e.retryInterval
+	for i := 1; i < retry && delay < maxRetryInterval; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryInterval {
+		delay = maxRetryInterval
+	}
+
+	return delay
+}
+
+// requeue pushes the job again to the queue, to be processed after the retry
+// delay. The due time is persisted with the job so the retry survives a
+// restart. The job is re-installed without validation since it was already
+// validated, and its storage is already allocated. The job target is reloaded
+// from storage, and the retry is dropped if the deployment is gone or was
+// updated since, so a stale target is never installed again.
+func (e *NativeEngine) requeue(queue string, job engineJob) {
+	current, err := e.storage.Get(job.Target.TwinID, job.Target.ContractID)
+	if err != nil {
+		log.Info().Err(err).
+			Uint32("twin", job.Target.TwinID).
+			Uint64("contract", job.Target.ContractID).
+			Msg("dropping retry of deployment that can't be loaded")
+		return
+	}
+
+	if current.Version != job.Target.Version {
+		log.Info().
+			Uint32("twin", job.Target.TwinID).
+			Uint64("contract", job.Target.ContractID).
+			Uint32("version", job.Target.Version).
+			Uint32("current", current.Version).
+			Msg("dropping retry of outdated deployment version")
+		return
+	}
+
+	job.Target = current
+	job.Attempts++
+	job.Op = opProvisionNoValidation
+	delay := e.retryDelay(job.Attempts)
+	job.NotBefore = time.Now().Add(delay)
+
+	log.Info().
+		Uint32("twin", job.Target.TwinID).
+		Uint64("contract", job.Target.ContractID).
+		Int("attempt", job.Attempts+1).
+		Dur("delay", delay).
+		Msg("deployment has retryable failures, retrying later")
+
+	if err := e.queues.push(queue, &job); err != nil {
+		log.Error().Err(err).
+			Uint32("twin", job.Target.TwinID).
+			Uint64("contract", job.Target.ContractID).
+			Msg("failed to queue deployment retry")
+	}
+}
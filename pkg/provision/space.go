@@ -0,0 +1,187 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+	"github.com/threefoldtech/zosbase/pkg/stubs"
+)
+
+// ErrInsufficientStorage is returned if the zmounts and volumes of a
+// deployment do not fit in the free storage of the node
+var ErrInsufficientStorage = fmt.Errorf("insufficient storage")
+
+// NewPoolsStorageSpace returns a StorageSpace that reports the free space
+// of each ssd pool of the node
+func NewPoolsStorageSpace(storage *stubs.StorageModuleStub) StorageSpace {
+	return StorageSpaceFn(func(ctx context.Context) ([]gridtypes.Unit, error) {
+		pools, err := storage.Metrics(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var free []gridtypes.Unit
+		for _, pool := range pools {
+			if pool.Type != zos.SSDDevice || pool.Used >= pool.Size {
+				continue
+			}
+			free = append(free, pool.Size-pool.Used)
+		}
+
+		return free, nil
+	})
+}
+
+// allocation is the storage a zmount or volume needs
+type allocation struct {
+	name gridtypes.Name
+	size gridtypes.Unit
+}
+
+// requiredStorage is the storage needed by each zmount and volume of the deployment
+func requiredStorage(getter gridtypes.WorkloadGetter) ([]allocation, error) {
+	var allocations []allocation
+	for _, wl := range getter.ByType(zos.ZMountType, zos.VolumeType) {
+		size, err := getMountSize(wl.Workload)
+		if err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, allocation{name: wl.Name, size: size})
+	}
+
+	return allocations, nil
+}
+
+// requiredUpdateStorage is the storage needed to update the current deployment
+// to the target. Added zmounts and volumes need their full size, and grown ones
+// only need the extra size. The space freed by removed or shrunk workloads is
+// not counted since it's not known which pool it's released from.
+func requiredUpdateStorage(current, target *gridtypes.Deployment) ([]allocation, error) {
+	existing := make(map[gridtypes.Name]gridtypes.Unit)
+	for _, wl := range current.ByType(zos.ZMountType, zos.VolumeType) {
+		if wl.Result.State.IsAny(gridtypes.StateDeleted) {
+			continue
+		}
+		size, err := getMountSize(wl.Workload)
+		if err != nil {
+			return nil, err
+		}
+		existing[wl.Name] = size
+	}
+
+	allocations, err := requiredStorage(target)
+	if err != nil {
+		return nil, err
+	}
+
+	needed := allocations[:0]
+	for _, alloc := range allocations {
+		size, ok := existing[alloc.name]
+		if !ok {
+			needed = append(needed, alloc)
+		} else if alloc.size > size {
+			needed = append(needed, allocation{name: alloc.name, size: alloc.size - size})
+		}
+	}
+
+	return needed, nil
+}
+
+// checkFreeStorage makes sure the deployment zmounts and volumes fit in the
+// free storage of the node pools, so a deployment is not left half installed
+// because of a predictable space shortfall.
+func (e *NativeEngine) checkFreeStorage(ctx context.Context, dl *gridtypes.Deployment) error {
+	if e.space == nil {
+		return nil
+	}
+
+	allocations, err := requiredStorage(dl)
+	if err != nil {
+		return err
+	}
+
+	return e.fitStorage(ctx, allocations)
+}
+
+// checkUpdateStorage is checkFreeStorage for an update of the stored deployment
+// to the target
+func (e *NativeEngine) checkUpdateStorage(ctx context.Context, target *gridtypes.Deployment) error {
+	if e.space == nil {
+		return nil
+	}
+
+	current, err := e.storage.Get(target.TwinID, target.ContractID)
+	if err != nil {
+		// the update fails on its own
+		return nil
+	}
+
+	allocations, err := requiredUpdateStorage(&current, target)
+	if err != nil {
+		return err
+	}
+
+	return e.fitStorage(ctx, allocations)
+}
+
+// fitStorage makes sure each allocation fits in a single pool, a disk can't
+// span pools even if the total free storage is enough. Allocations are placed
+// biggest first in the pool with the most free space.
+func (e *NativeEngine) fitStorage(ctx context.Context, allocations []allocation) error {
+	var need gridtypes.Unit
+	for _, alloc := range allocations {
+		need += alloc.size
+	}
+
+	if need == 0 {
+		return nil
+	}
+
+	pools, err := e.space.Free(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get free storage")
+	}
+
+	var have gridtypes.Unit
+	for _, free := range pools {
+		have += free
+	}
+
+	if need > have {
+		return fmt.Errorf("%w: need %s, have %s", ErrInsufficientStorage, formatSize(need), formatSize(have))
+	}
+
+	pools = append([]gridtypes.Unit(nil), pools...)
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].size > allocations[j].size
+	})
+
+	for _, alloc := range allocations {
+		best := -1
+		for i, free := range pools {
+			if free >= alloc.size && (best < 0 || free > pools[best]) {
+				best = i
+			}
+		}
+
+		if best < 0 {
+			var largest gridtypes.Unit
+			for _, free := range pools {
+				largest = max(largest, free)
+			}
+			return fmt.Errorf("%w: '%s' needs %s, the largest free pool has %s", ErrInsufficientStorage, alloc.name, formatSize(alloc.size), formatSize(largest))
+		}
+
+		pools[best] -= alloc.size
+	}
+
+	return nil
+}
+
+func formatSize(size gridtypes.Unit) string {
+	return fmt.Sprintf("%.2f GiB", float64(size)/float64(gridtypes.Gigabyte))
+}
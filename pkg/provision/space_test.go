@@ -0,0 +1,104 @@
+package provision
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+func TestCheckFreeStorage(t *testing.T) {
+	dl := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 10,
+		Workloads: []gridtypes.Workload{
+			{Name: "disk", Type: zos.ZMountType, Data: json.RawMessage(`{"size": 10737418240}`)},
+			{Name: "data", Type: zos.VolumeType, Data: json.RawMessage(`{"size": 5368709120}`)},
+			{Name: "net", Type: zos.NetworkType, Data: json.RawMessage(`{}`)},
+		},
+	}
+
+	allocations, err := requiredStorage(&dl)
+	require.NoError(t, err)
+	require.Equal(t, []allocation{
+		{name: "disk", size: 10 * gridtypes.Gigabyte},
+		{name: "data", size: 5 * gridtypes.Gigabyte},
+	}, allocations)
+
+	ctx := context.Background()
+
+	// no storage check configured
+	e := &NativeEngine{}
+	require.NoError(t, e.checkFreeStorage(ctx, &dl))
+
+	free := func(pools ...gridtypes.Unit) StorageSpace {
+		return StorageSpaceFn(func(ctx context.Context) ([]gridtypes.Unit, error) {
+			return pools, nil
+		})
+	}
+
+	e.space = free(20 * gridtypes.Gigabyte)
+	require.NoError(t, e.checkFreeStorage(ctx, &dl))
+
+	e.space = free(10*gridtypes.Gigabyte, 5*gridtypes.Gigabyte)
+	require.NoError(t, e.checkFreeStorage(ctx, &dl))
+
+	e.space = free(12 * gridtypes.Gigabyte)
+	err = e.checkFreeStorage(ctx, &dl)
+	require.ErrorIs(t, err, ErrInsufficientStorage)
+	require.EqualError(t, err, "insufficient storage: need 15.00 GiB, have 12.00 GiB")
+
+	// enough storage in total, but the disk does not fit in any pool
+	e.space = free(8*gridtypes.Gigabyte, 8*gridtypes.Gigabyte)
+	err = e.checkFreeStorage(ctx, &dl)
+	require.ErrorIs(t, err, ErrInsufficientStorage)
+	require.EqualError(t, err, "insufficient storage: 'disk' needs 10.00 GiB, the largest free pool has 8.00 GiB")
+}
+
+func TestCheckUpdateStorage(t *testing.T) {
+	current := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 10,
+		Workloads: []gridtypes.Workload{
+			{Name: "disk", Type: zos.ZMountType, Data: json.RawMessage(`{"size": 10737418240}`)},
+			{Name: "old", Type: zos.VolumeType, Data: json.RawMessage(`{"size": 5368709120}`)},
+		},
+	}
+
+	// the disk grows to 12 GiB, old is removed and data is added
+	target := gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 10,
+		Workloads: []gridtypes.Workload{
+			{Name: "disk", Type: zos.ZMountType, Data: json.RawMessage(`{"size": 12884901888}`)},
+			{Name: "data", Type: zos.VolumeType, Data: json.RawMessage(`{"size": 5368709120}`)},
+		},
+	}
+
+	allocations, err := requiredUpdateStorage(&current, &target)
+	require.NoError(t, err)
+	require.Equal(t, []allocation{
+		{name: "disk", size: 2 * gridtypes.Gigabyte},
+		{name: "data", size: 5 * gridtypes.Gigabyte},
+	}, allocations)
+
+	storage := &deploymentsStorage{deployments: make(map[uint32]map[uint64]gridtypes.Deployment)}
+	storage.put(current)
+
+	ctx := context.Background()
+	e := &NativeEngine{storage: storage}
+
+	e.space = StorageSpaceFn(func(ctx context.Context) ([]gridtypes.Unit, error) {
+		return []gridtypes.Unit{4 * gridtypes.Gigabyte, 4 * gridtypes.Gigabyte}, nil
+	})
+	err = e.checkUpdateStorage(ctx, &target)
+	require.ErrorIs(t, err, ErrInsufficientStorage)
+
+	e.space = StorageSpaceFn(func(ctx context.Context) ([]gridtypes.Unit, error) {
+		return []gridtypes.Unit{2 * gridtypes.Gigabyte, 5 * gridtypes.Gigabyte}, nil
+	})
+	require.NoError(t, e.checkUpdateStorage(ctx, &target))
+}
@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/provision"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// StateVersion is the version of the format written by ExportState
+	StateVersion = 1
+)
+
+var (
+	// ErrUnsupportedStateVersion is returned by ImportState if the state was
+	// exported in an unknown format
+	ErrUnsupportedStateVersion = fmt.Errorf("unsupported state version")
+)
+
+// exportedState is the serialized form of all deployments in the storage
+type exportedState struct {
+	Version     uint32               `json:"version"`
+	Deployments []exportedDeployment `json:"deployments"`
+}
+
+type exportedDeployment struct {
+	TwinID               uint32                                    `json:"twin_id"`
+	ContractID           uint64                                    `json:"contract_id"`
+	Version              uint32                                    `json:"version"`
+	Metadata             string                                    `json:"metadata"`
+	Description          string                                    `json:"description"`
+	SignatureRequirement json.RawMessage                           `json:"signature_requirement,omitempty"`
+	Workloads            map[gridtypes.Name]gridtypes.WorkloadType `json:"workloads"`
+	// Transactions is the full history of the deployment workloads, in order
+	Transactions []json.RawMessage `json:"transactions"`
+}
+
+// ExportState writes all deployments and their full history to w. The
+// output can be restored on another node (or after a reinstall) with
+// ImportState.
+func (b *BoltStorage) ExportState(w io.Writer) error {
+	state := exportedState{
+		Version:     StateVersion,
+		Deployments: []exportedDeployment{},
+	}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(k []byte, twin *bolt.Bucket) error {
+			if len(k) != 4 {
+				return nil
+			}
+
+			twinID := b.l32(k)
+			return twin.ForEach(func(k, v []byte) error {
+				if v != nil || len(k) != 8 {
+					return nil
+				}
+
+				deployment, err := b.exportDeployment(twinID, b.l64(k), twin.Bucket(k))
+				if err != nil {
+					return err
+				}
+
+				state.Deployments = append(state.Deployments, deployment)
+				return nil
+			})
+		})
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read deployments")
+	}
+
+	return json.NewEncoder(w).Encode(state)
+}
+
+func (b *BoltStorage) exportDeployment(twin uint32, contract uint64, bucket *bolt.Bucket) (exportedDeployment, error) {
+	dl := exportedDeployment{
+		TwinID:       twin,
+		ContractID:   contract,
+		Workloads:    make(map[gridtypes.Name]gridtypes.WorkloadType),
+		Transactions: []json.RawMessage{},
+	}
+
+	if value := bucket.Get([]byte(keyVersion)); value != nil {
+		dl.Version = b.l32(value)
+	}
+	dl.Metadata = string(bucket.Get([]byte(keyMetadata)))
+	dl.Description = string(bucket.Get([]byte(keyDescription)))
+	if value := bucket.Get([]byte(keySignatureRequirement)); value != nil {
+		dl.SignatureRequirement = append(json.RawMessage{}, value...)
+	}
+
+	if workloads := bucket.Bucket([]byte(keyWorkloads)); workloads != nil {
+		err := workloads.ForEach(func(k, v []byte) error {
+			dl.Workloads[gridtypes.Name(k)] = gridtypes.WorkloadType(v)
+			return nil
+		})
+		if err != nil {
+			return dl, err
+		}
+	}
+
+	if logs := bucket.Bucket([]byte(keyTransactions)); logs != nil {
+		err := logs.ForEach(func(k, v []byte) error {
+			if len(v) == 0 {
+				return nil
+			}
+			dl.Transactions = append(dl.Transactions, append(json.RawMessage{}, v...))
+			return nil
+		})
+		if err != nil {
+			return dl, err
+		}
+	}
+
+	return dl, nil
+}
+
+// ImportState restores deployments written by ExportState. The whole state
+// is validated before anything is written, and it is imported in a single
+// transaction. Existing deployments are never overwritten unless force is
+// set, in which case they are replaced by the imported ones with the same
+// twin and contract. Global workload names that belong to another deployment
+// are never taken, even with force.
+func (b *BoltStorage) ImportState(r io.Reader, force bool) error {
+	var state exportedState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return errors.Wrap(err, "failed to decode state")
+	}
+
+	if state.Version != StateVersion {
+		return errors.Wrapf(ErrUnsupportedStateVersion, "got version %d, expecting %d", state.Version, StateVersion)
+	}
+
+	seen := make(map[string]struct{})
+	for i := range state.Deployments {
+		dl := &state.Deployments[i]
+		key := fmt.Sprintf("%d.%d", dl.TwinID, dl.ContractID)
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("deployment %s is duplicated", key)
+		}
+		seen[key] = struct{}{}
+
+		if err := validateExportedDeployment(dl); err != nil {
+			return errors.Wrapf(err, "invalid deployment %s", key)
+		}
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for i := range state.Deployments {
+			dl := &state.Deployments[i]
+			if err := b.importDeployment(tx, dl, force); err != nil {
+				return errors.Wrapf(err, "failed to import deployment %d.%d", dl.TwinID, dl.ContractID)
+			}
+		}
+
+		log.Info().Int("deployments", len(state.Deployments)).Bool("force", force).Msg("deployments state imported")
+		return nil
+	})
+}
+
+func validateExportedDeployment(dl *exportedDeployment) error {
+	if dl.TwinID == 0 || dl.ContractID == 0 {
+		return fmt.Errorf("twin and contract ids are required")
+	}
+
+	if len(dl.SignatureRequirement) != 0 {
+		var requirement gridtypes.SignatureRequirement
+		if err := json.Unmarshal(dl.SignatureRequirement, &requirement); err != nil {
+			return errors.Wrap(err, "invalid signature requirement")
+		}
+	}
+
+	for name := range dl.Workloads {
+		if err := gridtypes.IsValidName(name); err != nil {
+			return errors.Wrapf(err, "invalid workload name '%s'", name)
+		}
+	}
+
+	// every active workload must have at least one transaction of the same type
+	found := make(map[gridtypes.Name]struct{})
+	for i, data := range dl.Transactions {
+		var wl gridtypes.Workload
+		if err := json.Unmarshal(data, &wl); err != nil {
+			return errors.Wrapf(err, "invalid transaction %d", i)
+		}
+
+		if err := wl.Result.Valid(); err != nil {
+			return errors.Wrapf(err, "invalid result of transaction %d", i)
+		}
+
+		if typ, ok := dl.Workloads[wl.Name]; ok {
+			if typ != wl.Type {
+				return errors.Wrapf(ErrInvalidWorkloadType, "workload '%s' is of type '%s' in transaction %d, expecting '%s'", wl.Name, wl.Type, i, typ)
+			}
+			found[wl.Name] = struct{}{}
+		}
+	}
+
+	for name := range dl.Workloads {
+		if _, ok := found[name]; !ok {
+			return fmt.Errorf("workload '%s' has no transactions", name)
+		}
+	}
+
+	return nil
+}
+
+func (b *BoltStorage) importDeployment(tx *bolt.Tx, dl *exportedDeployment, force bool) error {
+	twin, err := tx.CreateBucketIfNotExists(b.u32(dl.TwinID))
+	if err != nil {
+		return errors.Wrap(err, "failed to create twin")
+	}
+
+	if twin.Bucket(b.u64(dl.ContractID)) != nil {
+		if !force {
+			return provision.ErrDeploymentExists
+		}
+
+		if err := twin.DeleteBucket(b.u64(dl.ContractID)); err != nil {
+			return errors.Wrap(err, "failed to delete existing deployment")
+		}
+	}
+
+	shared, err := twin.CreateBucketIfNotExists([]byte(keyGlobal))
+	if err != nil {
+		return errors.Wrap(err, "failed to create twin global bucket")
+	}
+
+	// drop the global names of the replaced deployment
+	var stale [][]byte
+	err = shared.ForEach(func(k, v []byte) error {
+		if len(v) == 8 && b.l64(v) == dl.ContractID {
+			stale = append(stale, append([]byte{}, k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range stale {
+		if err := shared.Delete(name); err != nil {
+			return err
+		}
+	}
+
+	deployment, err := twin.CreateBucket(b.u64(dl.ContractID))
+	if err != nil {
+		return errors.Wrap(err, "failed to create deployment")
+	}
+
+	if err := deployment.Put([]byte(keyVersion), b.u32(dl.Version)); err != nil {
+		return err
+	}
+	if err := deployment.Put([]byte(keyMetadata), []byte(dl.Metadata)); err != nil {
+		return err
+	}
+	if err := deployment.Put([]byte(keyDescription), []byte(dl.Description)); err != nil {
+		return err
+	}
+	if len(dl.SignatureRequirement) != 0 {
+		if err := deployment.Put([]byte(keySignatureRequirement), dl.SignatureRequirement); err != nil {
+			return err
+		}
+	}
+
+	workloads, err := deployment.CreateBucket([]byte(keyWorkloads))
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare workloads storage")
+	}
+
+	for name, typ := range dl.Workloads {
+		if gridtypes.IsSharable(typ) {
+			// the names of the replaced deployment are already dropped, so the
+			// name belongs to another deployment even if force is set
+			if value := shared.Get([]byte(name)); value != nil {
+				return errors.Wrapf(
+					provision.ErrDeploymentConflict, "global workload with the same name '%s' exists", name)
+			}
+
+			if err := shared.Put([]byte(name), b.u64(dl.ContractID)); err != nil {
+				return err
+			}
+		}
+
+		if err := workloads.Put([]byte(name), []byte(typ.String())); err != nil {
+			return err
+		}
+	}
+
+	logs, err := deployment.CreateBucket([]byte(keyTransactions))
+	if err != nil {
+		return errors.Wrap(err, "failed to prepare deployment transaction logs")
+	}
+
+	for _, data := range dl.Transactions {
+		id, err := logs.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		if err := logs.Put(b.u64(id), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -702,7 +702,8 @@ func (b *BoltStorage) Capacity(exclude ...provision.Exclude) (storageCap provisi
 			isActive := false
 		next:
 			for _, wl := range deployment.Workloads {
-				if !wl.Result.State.IsOkay() {
+				pending := wl.Result.State == gridtypes.StateInit
+				if !pending && !wl.Result.State.IsOkay() {
 					continue
 				}
 				for _, exc := range exclude {
@@ -715,6 +716,11 @@ func (b *BoltStorage) Capacity(exclude ...provision.Exclude) (storageCap provisi
 					return provision.StorageCapacity{}, err
 				}
 
+				if pending {
+					storageCap.Pending.Add(&c)
+					continue
+				}
+
 				isActive = true
 				storageCap.Workloads += 1
 				storageCap.Cap.Add(&c)
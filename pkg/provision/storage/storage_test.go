@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -527,3 +529,141 @@ func TestDeleteDeploymentMultiple(t *testing.T) {
 	_, err = db.Get(1, 20)
 	require.NoError(err)
 }
+
+func TestExportImportState(t *testing.T) {
+	require := require.New(t)
+	path := filepath.Join(os.TempDir(), fmt.Sprint(rand.Int63()))
+	defer os.RemoveAll(path)
+
+	db, err := New(path)
+	require.NoError(err)
+
+	dl := gridtypes.Deployment{
+		Version:     1,
+		TwinID:      1,
+		ContractID:  10,
+		Description: "description",
+		Metadata:    "some metadata",
+		Workloads: []gridtypes.Workload{
+			{
+				Type: testType1,
+				Name: "vm1",
+			},
+			{
+				Type: testSharableType1,
+				Name: "net",
+			},
+		},
+	}
+
+	require.NoError(db.Create(dl))
+	require.NoError(db.Transaction(1, 10, dl.Workloads[0].WithResults(gridtypes.Result{
+		Created: gridtypes.Now(),
+		State:   gridtypes.StateOk,
+	})))
+
+	var buf bytes.Buffer
+	require.NoError(db.ExportState(&buf))
+	exported := buf.Bytes()
+
+	target := filepath.Join(os.TempDir(), fmt.Sprint(rand.Int63()))
+	defer os.RemoveAll(target)
+
+	restored, err := New(target)
+	require.NoError(err)
+
+	require.NoError(restored.ImportState(bytes.NewReader(exported), false))
+
+	loaded, err := restored.Get(1, 10)
+	require.NoError(err)
+	require.Equal(dl.Description, loaded.Description)
+	require.Equal(dl.Metadata, loaded.Metadata)
+	require.Len(loaded.Workloads, 2)
+
+	changes, err := restored.Changes(1, 10)
+	require.NoError(err)
+	require.Len(changes, 3)
+
+	current, err := restored.Current(1, 10, "vm1")
+	require.NoError(err)
+	require.Equal(gridtypes.StateOk, current.Result.State)
+
+	// existing deployments are not overwritten
+	err = restored.ImportState(bytes.NewReader(exported), false)
+	require.ErrorIs(err, provision.ErrDeploymentExists)
+
+	require.NoError(restored.ImportState(bytes.NewReader(exported), true))
+	changes, err = restored.Changes(1, 10)
+	require.NoError(err)
+	require.Len(changes, 3)
+
+	// the global name still points to the deployment
+	dl.ContractID = 20
+	dl.Workloads = dl.Workloads[1:]
+	err = restored.Create(dl)
+	require.ErrorIs(err, provision.ErrDeploymentConflict)
+}
+
+func TestImportStateForceConflict(t *testing.T) {
+	require := require.New(t)
+	path := filepath.Join(os.TempDir(), fmt.Sprint(rand.Int63()))
+	defer os.RemoveAll(path)
+
+	db, err := New(path)
+	require.NoError(err)
+
+	dl := gridtypes.Deployment{
+		Version:    1,
+		TwinID:     1,
+		ContractID: 10,
+		Workloads: []gridtypes.Workload{
+			{Type: testSharableType1, Name: "net"},
+		},
+	}
+	require.NoError(db.Create(dl))
+
+	var buf bytes.Buffer
+	require.NoError(db.ExportState(&buf))
+
+	// the same name in another contract of the twin
+	target := filepath.Join(os.TempDir(), fmt.Sprint(rand.Int63()))
+	defer os.RemoveAll(target)
+
+	restored, err := New(target)
+	require.NoError(err)
+
+	other := dl
+	other.ContractID = 20
+	require.NoError(restored.Create(other))
+
+	err = restored.ImportState(bytes.NewReader(buf.Bytes()), true)
+	require.ErrorIs(err, provision.ErrDeploymentConflict)
+
+	// nothing is imported, and the name still belongs to the other contract
+	_, err = restored.Get(1, 10)
+	require.ErrorIs(err, provision.ErrDeploymentNotExists)
+	require.NoError(restored.Delete(1, 20))
+	require.NoError(restored.ImportState(bytes.NewReader(buf.Bytes()), true))
+	_, err = restored.Get(1, 10)
+	require.NoError(err)
+}
+
+func TestImportStateInvalid(t *testing.T) {
+	require := require.New(t)
+	path := filepath.Join(os.TempDir(), fmt.Sprint(rand.Int63()))
+	defer os.RemoveAll(path)
+
+	db, err := New(path)
+	require.NoError(err)
+
+	err = db.ImportState(strings.NewReader(`{"version": 100, "deployments": []}`), false)
+	require.ErrorIs(err, ErrUnsupportedStateVersion)
+
+	// active workload without transactions
+	err = db.ImportState(strings.NewReader(`{"version": 1, "deployments": [{"twin_id": 1, "contract_id": 10, "workloads": {"vm1": "type1"}, "transactions": []}]}`), false)
+	require.Error(err)
+
+	twins, err := db.Twins()
+	require.NoError(err)
+	require.Empty(twins)
+}
@@ -0,0 +1,104 @@
+package provision
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+// summaryExpiration is how long a computed node summary is served before it's
+// computed again
+const summaryExpiration = 30 * time.Second
+
+// summaryCache keeps the last computed node summary, since computing it
+// reads all deployments from storage
+type summaryCache struct {
+	m       sync.Mutex
+	summary pkg.NodeSummary
+	at      time.Time
+}
+
+// NodeDeploymentsSummary aggregates all stored deployments across all twins.
+// It loads every deployment so the result is cached for summaryExpiration,
+// polling it more often returns the same summary.
+// Capacity only counts workloads in okay state, same as the node statistics.
+func (e *NativeEngine) NodeDeploymentsSummary() (pkg.NodeSummary, error) {
+	e.summary.m.Lock()
+	defer e.summary.m.Unlock()
+
+	if !e.summary.at.IsZero() && time.Since(e.summary.at) < summaryExpiration {
+		return e.summary.summary, nil
+	}
+
+	summary, err := e.nodeDeploymentsSummary()
+	if err != nil {
+		return summary, err
+	}
+
+	e.summary.summary = summary
+	e.summary.at = time.Now()
+	return summary, nil
+}
+
+func (e *NativeEngine) nodeDeploymentsSummary() (pkg.NodeSummary, error) {
+	summary := pkg.NodeSummary{
+		Deployments: []pkg.DeploymentSummary{},
+		Workloads:   make(map[gridtypes.ResultState]int),
+	}
+
+	twins, err := e.storage.Twins()
+	if err != nil {
+		return summary, err
+	}
+
+	for _, twin := range twins {
+		ids, err := e.storage.ByTwin(twin)
+		if err != nil {
+			log.Error().Err(err).Uint32("twin", twin).Msg("failed to get twin deployments")
+			continue
+		}
+
+		if len(ids) > 0 {
+			summary.Twins++
+		}
+
+		for _, id := range ids {
+			dl, err := e.storage.Get(twin, id)
+			if err != nil {
+				log.Error().Err(err).Uint32("twin", twin).Uint64("contract", id).Msg("failed to get deployment")
+				continue
+			}
+
+			dlSummary := pkg.DeploymentSummary{
+				TwinID:     twin,
+				ContractID: id,
+				Workloads:  make(map[gridtypes.ResultState]int),
+			}
+
+			for i := range dl.Workloads {
+				wl := &dl.Workloads[i]
+				dlSummary.Workloads[wl.Result.State]++
+				summary.Workloads[wl.Result.State]++
+
+				if !wl.Result.State.IsOkay() {
+					continue
+				}
+
+				c, err := wl.Capacity()
+				if err != nil {
+					log.Error().Err(err).Uint32("twin", twin).Uint64("contract", id).Str("name", wl.Name.String()).Msg("failed to compute workload capacity")
+					continue
+				}
+				dlSummary.Capacity.Add(&c)
+			}
+
+			summary.Capacity.Add(&dlSummary.Capacity)
+			summary.Deployments = append(summary.Deployments, dlSummary)
+		}
+	}
+
+	return summary, nil
+}
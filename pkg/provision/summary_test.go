@@ -0,0 +1,49 @@
+package provision
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+func TestNodeDeploymentsSummaryCached(t *testing.T) {
+	storage := &deploymentsStorage{deployments: make(map[uint32]map[uint64]gridtypes.Deployment)}
+	storage.put(gridtypes.Deployment{
+		TwinID:     1,
+		ContractID: 1,
+		Workloads: []gridtypes.Workload{
+			publicIPWorkload(t, "ip", gridtypes.StateOk, "185.0.0.1/24"),
+			publicIPWorkload(t, "failed", gridtypes.StateError, "185.0.0.2/24"),
+		},
+	})
+
+	e := &NativeEngine{storage: storage}
+
+	summary, err := e.NodeDeploymentsSummary()
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.Twins)
+	require.Len(t, summary.Deployments, 1)
+	require.Equal(t, 1, summary.Workloads[gridtypes.StateOk])
+	require.Equal(t, 1, summary.Workloads[gridtypes.StateError])
+
+	// the cached summary is served until it expires
+	storage.put(gridtypes.Deployment{
+		TwinID:     2,
+		ContractID: 2,
+		Workloads: []gridtypes.Workload{
+			publicIPWorkload(t, "ip", gridtypes.StateOk, "185.0.0.3/24"),
+		},
+	})
+
+	summary, err = e.NodeDeploymentsSummary()
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.Twins)
+
+	e.summary.at = time.Now().Add(-summaryExpiration)
+	summary, err = e.NodeDeploymentsSummary()
+	require.NoError(t, err)
+	require.Equal(t, 2, summary.Twins)
+	require.Len(t, summary.Deployments, 2)
+}
@@ -0,0 +1,198 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+// updateStorage is an in memory storage of a single deployment
+type updateStorage struct {
+	Storage
+	deployment gridtypes.Deployment
+	changes    []gridtypes.Workload
+}
+
+func (s *updateStorage) Get(twin uint32, deployment uint64) (gridtypes.Deployment, error) {
+	dl := s.deployment
+	dl.Workloads = append([]gridtypes.Workload(nil), s.deployment.Workloads...)
+	return dl, nil
+}
+
+func (s *updateStorage) Update(twin uint32, deployment uint64, fields ...Field) error {
+	for _, field := range fields {
+		if version, ok := field.(VersionField); ok {
+			s.deployment.Version = version.Version
+		}
+	}
+	return nil
+}
+
+func (s *updateStorage) Current(twin uint32, deployment uint64, name gridtypes.Name) (gridtypes.Workload, error) {
+	for _, wl := range s.deployment.Workloads {
+		if wl.Name == name {
+			return wl, nil
+		}
+	}
+	return gridtypes.Workload{}, ErrWorkloadNotExist
+}
+
+func (s *updateStorage) Changes(twin uint32, deployment uint64) ([]gridtypes.Workload, error) {
+	return s.changes, nil
+}
+
+func (s *updateStorage) Add(twin uint32, deployment uint64, wl gridtypes.Workload) error {
+	wl.Result = gridtypes.Result{State: gridtypes.StateInit}
+	s.deployment.Workloads = append(s.deployment.Workloads, wl)
+	s.changes = append(s.changes, wl)
+	return nil
+}
+
+func (s *updateStorage) Transaction(twin uint32, deployment uint64, wl gridtypes.Workload) error {
+	for i := range s.deployment.Workloads {
+		if s.deployment.Workloads[i].Name == wl.Name {
+			s.deployment.Workloads[i] = wl
+			s.changes = append(s.changes, wl)
+			return nil
+		}
+	}
+	return ErrWorkloadNotExist
+}
+
+// updateProvisioner fails to provision the workloads in fail
+type updateProvisioner struct {
+	Provisioner
+	fail        map[gridtypes.Name]bool
+	provisioned []gridtypes.Name
+	updated     []gridtypes.Name
+}
+
+func (p *updateProvisioner) CanUpdate(ctx context.Context, typ gridtypes.WorkloadType) bool {
+	return true
+}
+
+func (p *updateProvisioner) Provision(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	p.provisioned = append(p.provisioned, wl.Name)
+	if p.fail[wl.Name] {
+		return gridtypes.Result{}, fmt.Errorf("no space left")
+	}
+	return gridtypes.Result{State: gridtypes.StateOk}, nil
+}
+
+func (p *updateProvisioner) Update(ctx context.Context, wl *gridtypes.WorkloadWithID) (gridtypes.Result, error) {
+	p.updated = append(p.updated, wl.Name)
+	return gridtypes.Result{State: gridtypes.StateOk}, nil
+}
+
+func testUpdateEngine(t *testing.T) (*NativeEngine, *updateStorage, *updateProvisioner) {
+	disk := gridtypes.Workload{
+		Name:    "a",
+		Type:    zos.ZMountType,
+		Version: 0,
+		Data:    gridtypes.MustMarshal(zos.ZMount{Size: gridtypes.Gigabyte}),
+		Result:  gridtypes.Result{State: gridtypes.StateOk},
+	}
+
+	storage := &updateStorage{
+		deployment: gridtypes.Deployment{TwinID: 1, ContractID: 1, Workloads: []gridtypes.Workload{disk}},
+		changes:    []gridtypes.Workload{disk},
+	}
+	provisioner := &updateProvisioner{fail: map[gridtypes.Name]bool{"b": true}}
+
+	queues, err := openQueues(t.TempDir(), false, QueueConfig{Name: DefaultQueue})
+	require.NoError(t, err)
+	t.Cleanup(queues.close)
+
+	e := &NativeEngine{
+		storage:     storage,
+		provisioner: provisioner,
+		queues:      queues,
+		order:       []gridtypes.WorkloadType{zos.ZMountType},
+		typeIndex:   map[gridtypes.WorkloadType]int{zos.ZMountType: 0},
+	}
+
+	return e, storage, provisioner
+}
+
+// updateTarget updates workload a and adds workload b
+func updateTarget() gridtypes.Deployment {
+	return gridtypes.Deployment{
+		Version:    1,
+		TwinID:     1,
+		ContractID: 1,
+		Workloads: []gridtypes.Workload{
+			{Name: "a", Type: zos.ZMountType, Version: 1, Data: gridtypes.MustMarshal(zos.ZMount{Size: 2 * gridtypes.Gigabyte})},
+			{Name: "b", Type: zos.ZMountType, Version: 1, Data: gridtypes.MustMarshal(zos.ZMount{Size: gridtypes.Gigabyte})},
+		},
+	}
+}
+
+func TestUpdatePartialFailure(t *testing.T) {
+	e, storage, provisioner := testUpdateEngine(t)
+
+	target := updateTarget()
+	e.runUpdate(context.Background(), &engineJob{Op: opUpdate, Target: target})
+
+	// the added workload failed, the version is kept so the update can
+	// be sent again
+	require.EqualValues(t, 0, storage.deployment.Version)
+	a, err := storage.Current(1, 1, "a")
+	require.NoError(t, err)
+	require.Equal(t, gridtypes.StateOk, a.Result.State)
+	require.EqualValues(t, 1, a.Version)
+	b, err := storage.Current(1, 1, "b")
+	require.NoError(t, err)
+	require.Equal(t, gridtypes.StateError, b.Result.State)
+	require.Equal(t, []gridtypes.Name{"a"}, provisioner.updated)
+	require.Equal(t, []gridtypes.Name{"b"}, provisioner.provisioned)
+
+	// the retry only installs the failed workload again, as an add and not
+	// an update
+	delete(provisioner.fail, "b")
+	target = updateTarget()
+	e.runUpdate(context.Background(), &engineJob{Op: opUpdate, Target: target})
+
+	require.EqualValues(t, 1, storage.deployment.Version)
+	b, err = storage.Current(1, 1, "b")
+	require.NoError(t, err)
+	require.Equal(t, gridtypes.StateOk, b.Result.State)
+	require.Equal(t, []gridtypes.Name{"a"}, provisioner.updated)
+	require.Equal(t, []gridtypes.Name{"b", "b"}, provisioner.provisioned)
+}
+
+func TestUpdateRetriedOps(t *testing.T) {
+	e, storage, _ := testUpdateEngine(t)
+	e.runUpdate(context.Background(), &engineJob{Op: opUpdate, Target: updateTarget()})
+
+	current, err := storage.Get(1, 1)
+	require.NoError(t, err)
+	target := updateTarget()
+	ops, err := current.Upgrade(&target)
+	require.NoError(t, err)
+
+	e.retriedOps(ops)
+	types := make(map[gridtypes.Name]gridtypes.JobOperation)
+	for _, op := range ops {
+		types[op.WlID.Name] = op.Op
+	}
+	require.Equal(t, map[gridtypes.Name]gridtypes.JobOperation{
+		"a": gridtypes.OpUpdate,
+		"b": gridtypes.OpAdd,
+	}, types)
+}
+
+func TestUpdateQueuedVersion(t *testing.T) {
+	e, _, _ := testUpdateEngine(t)
+
+	require.NoError(t, e.Update(context.Background(), updateTarget()))
+	// the same version can't be queued twice
+	require.ErrorIs(t, e.Update(context.Background(), updateTarget()), ErrInvalidVersion)
+
+	// once the queued update is processed it can be sent again
+	e.endUpdate(deploymentValue{1, 1}, 1)
+	require.NoError(t, e.Update(context.Background(), updateTarget()))
+}
@@ -0,0 +1,123 @@
+// Package ratelimit implements token bucket rate limiting of the api callers,
+// each caller twin has its own bucket.
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned if the caller has no tokens left
+var ErrRateLimited = fmt.Errorf("rate limited, too many requests please retry later")
+
+// pruneInterval is how often buckets that are full again are dropped, a full
+// bucket is the same as a new one
+const pruneInterval = time.Minute
+
+// Rate of a bucket, Burst requests are allowed at once and the bucket is
+// refilled at PerSecond tokens per second
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket rate limiter keyed by twin id
+type Limiter struct {
+	rate Rate
+	now  func() time.Time
+
+	m       sync.Mutex
+	buckets map[uint32]*bucket
+	pruned  time.Time
+}
+
+// NewLimiter creates a limiter with the given rate for each twin
+func NewLimiter(rate Rate) *Limiter {
+	return newLimiter(rate, time.Now)
+}
+
+func newLimiter(rate Rate, now func() time.Time) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		now:     now,
+		buckets: make(map[uint32]*bucket),
+		pruned:  now(),
+	}
+}
+
+// Allow takes a token from the twin bucket, it returns ErrRateLimited if the
+// bucket is empty
+func (l *Limiter) Allow(twin uint32) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	b, ok := l.buckets[twin]
+	if !ok {
+		b = &bucket{tokens: float64(l.rate.Burst), last: now}
+		l.buckets[twin] = b
+	}
+
+	b.tokens = l.refill(b, now)
+	b.last = now
+
+	if b.tokens < 1 {
+		return ErrRateLimited
+	}
+
+	b.tokens--
+	return nil
+}
+
+// refill returns the tokens of the bucket at now
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*l.rate.PerSecond
+	if burst := float64(l.rate.Burst); tokens > burst {
+		return burst
+	}
+
+	return tokens
+}
+
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < pruneInterval {
+		return
+	}
+
+	for twin, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.rate.Burst) {
+			delete(l.buckets, twin)
+		}
+	}
+	l.pruned = now
+}
+
+// Limits has separate limiters for the read and write requests, so listing
+// doesn't use the tokens needed to deploy
+type Limits struct {
+	read  *Limiter
+	write *Limiter
+}
+
+// NewLimits creates limits with the given read and write rates
+func NewLimits(read, write Rate) *Limits {
+	return &Limits{read: NewLimiter(read), write: NewLimiter(write)}
+}
+
+// Allow takes a token from the twin read or write bucket. All requests take a
+// read token, the ones that change the node state also take a write token.
+func (l *Limits) Allow(twin uint32, write bool) error {
+	if write {
+		return l.write.Allow(twin)
+	}
+
+	return l.read.Allow(twin)
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestLimiterBurst(t *testing.T) {
+	c := &clock{now: time.Unix(1000, 0)}
+	limiter := newLimiter(Rate{PerSecond: 1, Burst: 3}, c.Now)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, limiter.Allow(1))
+	}
+	require.ErrorIs(t, limiter.Allow(1), ErrRateLimited)
+
+	// other twins have their own bucket
+	require.NoError(t, limiter.Allow(2))
+}
+
+func TestLimiterRefill(t *testing.T) {
+	c := &clock{now: time.Unix(1000, 0)}
+	limiter := newLimiter(Rate{PerSecond: 2, Burst: 2}, c.Now)
+
+	require.NoError(t, limiter.Allow(1))
+	require.NoError(t, limiter.Allow(1))
+	require.ErrorIs(t, limiter.Allow(1), ErrRateLimited)
+
+	c.now = c.now.Add(500 * time.Millisecond)
+	require.NoError(t, limiter.Allow(1))
+	require.ErrorIs(t, limiter.Allow(1), ErrRateLimited)
+
+	// the bucket never holds more than burst
+	c.now = c.now.Add(time.Hour)
+	require.NoError(t, limiter.Allow(1))
+	require.NoError(t, limiter.Allow(1))
+	require.ErrorIs(t, limiter.Allow(1), ErrRateLimited)
+}
+
+func TestLimiterPrune(t *testing.T) {
+	c := &clock{now: time.Unix(1000, 0)}
+	limiter := newLimiter(Rate{PerSecond: 1, Burst: 1}, c.Now)
+
+	require.NoError(t, limiter.Allow(1))
+	require.Len(t, limiter.buckets, 1)
+
+	c.now = c.now.Add(pruneInterval)
+	require.NoError(t, limiter.Allow(2))
+	require.Len(t, limiter.buckets, 1)
+}
+
+func TestLimits(t *testing.T) {
+	limits := NewLimits(Rate{PerSecond: 1, Burst: 2}, Rate{PerSecond: 1, Burst: 1})
+
+	require.NoError(t, limits.Allow(1, true))
+	require.ErrorIs(t, limits.Allow(1, true), ErrRateLimited)
+
+	// reads are not limited by the writes
+	require.NoError(t, limits.Allow(1, false))
+	require.NoError(t, limits.Allow(1, false))
+	require.ErrorIs(t, limits.Allow(1, false), ErrRateLimited)
+}
@@ -13,6 +13,12 @@ import (
 // names is given only named files will be rotated, other unknown
 // files will be deleted
 func (r *Rotator) RotateAll(dir string, names ...string) error {
+	return r.RotateAllWith(dir, nil, names...)
+}
+
+// RotateAllWith is like RotateAll, but files that have an entry in overrides
+// are rotated with their own rotator
+func (r *Rotator) RotateAllWith(dir string, overrides map[string]Rotator, names ...string) error {
 	files, err := os.ReadDir(dir)
 	if os.IsNotExist(err) {
 		return nil
@@ -41,8 +47,13 @@ func (r *Rotator) RotateAll(dir string, names ...string) error {
 			continue
 		}
 
+		rotator := r
+		if override, ok := overrides[name]; ok {
+			rotator = &override
+		}
+
 		log.Debug().Str("file", name).Msg("rotating file")
-		if err := r.Rotate(path); err != nil {
+		if err := rotator.Rotate(path); err != nil {
 			log.Error().Str("file", name).Err(err).Msg("error while rotating file")
 		}
 	}
@@ -102,6 +102,11 @@ func NewRotator(opt ...Option) Rotator {
 	return cfg
 }
 
+// TailPath returns the path of the tail chunk of file
+func (r *Rotator) TailPath(file string) string {
+	return file + r.suffix
+}
+
 func (r *Rotator) Rotate(file string) error {
 	fd, err := os.OpenFile(file, os.O_RDWR, 0644)
 	if os.IsNotExist(err) {
@@ -125,7 +130,7 @@ func (r *Rotator) Rotate(file string) error {
 		return fmt.Errorf("failed to seek to truncate position: %w", err)
 	}
 
-	tail := file + r.suffix
+	tail := r.TailPath(file)
 	tailFd, err := os.Create(tail)
 	if err != nil {
 		return fmt.Errorf("failed to create tail file '%s': %w", tail, err)
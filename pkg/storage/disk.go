@@ -155,6 +155,9 @@ func (s *Module) DiskWrite(name string, image string) error {
 
 // DiskCreate with given size, return path to virtual disk (size in MB)
 func (s *Module) DiskCreate(name string, size gridtypes.Unit) (disk pkg.VDisk, err error) {
+	s.allocate.Lock()
+	defer s.allocate.Unlock()
+
 	path, err := s.findDisk(name)
 	if err == nil {
 		return disk, errors.Wrapf(os.ErrExist, "disk with id '%s' already exists", name)
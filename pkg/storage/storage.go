@@ -57,6 +57,11 @@ type Module struct {
 
 	mu sync.RWMutex
 
+	// allocate serializes picking a pool for a new disk or volume and
+	// allocating its space, so concurrent creations don't pick the same free
+	// space
+	allocate sync.Mutex
+
 	// cache is a cache directory can be used with some files
 	// NOTED: this is deprecated, now type is stored on the device
 	// itself not in temp cache
@@ -693,6 +698,9 @@ func (s *Module) watchCache(ctx context.Context, cache filesystem.Volume) {
 // if the requested disk type does not have a storage pool with enough free size available, an error is returned
 // this methods does set a quota limit equal to size on the created volume
 func (s *Module) createSubvolWithQuota(size gridtypes.Unit, name string, policy Policy) (filesystem.Volume, error) {
+	s.allocate.Lock()
+	defer s.allocate.Unlock()
+
 	volume, err := s.createSubvol(size, name, policy)
 	if err != nil {
 		return nil, err
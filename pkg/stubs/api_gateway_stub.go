@@ -302,6 +302,35 @@ func (s *SubstrateGatewayStub) GetZosVersion(ctx context.Context) (ret0 string,
 	return
 }
 
+func (s *SubstrateGatewayStub) Healthz(ctx context.Context) (ret0 error) {
+	args := []interface{}{}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "Healthz", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *SubstrateGatewayStub) InvalidateTwin(ctx context.Context, arg0 uint32) {
+	args := []interface{}{arg0}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "InvalidateTwin", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *SubstrateGatewayStub) Report(ctx context.Context, arg0 []tfchainclientgo.NruConsumption) (ret0 types.Hash, ret1 error) {
 	args := []interface{}{arg0}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "Report", args...)
@@ -402,3 +431,20 @@ func (s *SubstrateGatewayStub) UpdateSubstrateGatewayConnection(ctx context.Cont
 	}
 	return
 }
+
+func (s *SubstrateGatewayStub) UptimeStatus(ctx context.Context) (ret0 pkg.UptimeStatus, ret1 error) {
+	args := []interface{}{}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "UptimeStatus", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
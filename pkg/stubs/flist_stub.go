@@ -28,6 +28,23 @@ func NewFlisterStub(client zbus.Client) *FlisterStub {
 	}
 }
 
+func (s *FlisterStub) CachedMounts(ctx context.Context) (ret0 []pkg.CachedMount, ret1 error) {
+	args := []interface{}{}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "CachedMounts", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *FlisterStub) Exists(ctx context.Context, arg0 string) (ret0 bool, ret1 error) {
 	args := []interface{}{arg0}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "Exists", args...)
@@ -289,6 +289,23 @@ func (s *NetworkerLightStub) WireguardPorts(ctx context.Context) (ret0 []uint, r
 	return
 }
 
+func (s *NetworkerLightStub) WireguardStats(ctx context.Context, arg0 zos.NetID) (ret0 []pkg.PeerStat, ret1 error) {
+	args := []interface{}{arg0}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "WireguardStats", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *NetworkerLightStub) ZDBIPs(ctx context.Context, arg0 string) (ret0 [][]uint8, ret1 error) {
 	args := []interface{}{arg0}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "ZDBIPs", args...)
@@ -222,6 +222,40 @@ func (s *NetworkerStub) GetPublicExitDevice(ctx context.Context) (ret0 pkg.ExitD
 	return
 }
 
+func (s *NetworkerStub) GetPublicExitHistory(ctx context.Context) (ret0 []pkg.ExitDecision, ret1 error) {
+	args := []interface{}{}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "GetPublicExitHistory", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *NetworkerStub) GetPublicExitStatus(ctx context.Context) (ret0 pkg.ExitLinkStatus, ret1 error) {
+	args := []interface{}{}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "GetPublicExitStatus", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *NetworkerStub) GetPublicIPV6Gateway(ctx context.Context) (ret0 []uint8, ret1 error) {
 	args := []interface{}{}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "GetPublicIPV6Gateway", args...)
@@ -537,6 +571,21 @@ func (s *NetworkerStub) RemoveTap(ctx context.Context, arg0 string) (ret0 error)
 	return
 }
 
+func (s *NetworkerStub) ResyncPubIPFilter(ctx context.Context, arg0 string, arg1 string, arg2 []uint8, arg3 []uint8, arg4 string) (ret0 error) {
+	args := []interface{}{arg0, arg1, arg2, arg3, arg4}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "ResyncPubIPFilter", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *NetworkerStub) SetPublicConfig(ctx context.Context, arg0 pkg.PublicConfig) (ret0 error) {
 	args := []interface{}{arg0}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "SetPublicConfig", args...)
@@ -27,6 +27,23 @@ func NewPerformanceMonitorStub(client zbus.Client) *PerformanceMonitorStub {
 	}
 }
 
+func (s *PerformanceMonitorStub) Elections(ctx context.Context) (ret0 []pkg.Election, ret1 error) {
+	args := []interface{}{}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "Elections", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *PerformanceMonitorStub) Get(ctx context.Context, arg0 string) (ret0 pkg.TaskResult, ret1 error) {
 	args := []interface{}{arg0}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "Get", args...)
@@ -60,3 +77,20 @@ func (s *PerformanceMonitorStub) GetAll(ctx context.Context) (ret0 []pkg.TaskRes
 	}
 	return
 }
+
+func (s *PerformanceMonitorStub) Metrics(ctx context.Context) (ret0 string, ret1 error) {
+	args := []interface{}{}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "Metrics", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
@@ -8,6 +8,8 @@ import (
 	"context"
 	zbus "github.com/threefoldtech/zbus"
 	pkg "github.com/threefoldtech/zosbase/pkg"
+	gridtypes "github.com/threefoldtech/zosbase/pkg/gridtypes"
+	time "time"
 )
 
 type VMModuleStub struct {
@@ -27,6 +29,23 @@ func NewVMModuleStub(client zbus.Client) *VMModuleStub {
 	}
 }
 
+func (s *VMModuleStub) Counters(ctx context.Context, arg0 string) (ret0 pkg.VMCounters, ret1 error) {
+	args := []interface{}{arg0}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "Counters", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *VMModuleStub) Delete(ctx context.Context, arg0 string) (ret0 error) {
 	args := []interface{}{arg0}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "Delete", args...)
@@ -58,6 +77,38 @@ func (s *VMModuleStub) Exists(ctx context.Context, arg0 string) (ret0 bool) {
 	return
 }
 
+func (s *VMModuleStub) Export(ctx context.Context, arg0 string, arg1 string) (ret0 error) {
+	args := []interface{}{arg0, arg1}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "Export", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *VMModuleStub) Import(ctx context.Context, arg0 string, arg1 string) (ret0 pkg.MachineInfo, ret1 error) {
+	args := []interface{}{arg0, arg1}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "Import", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *VMModuleStub) Inspect(ctx context.Context, arg0 string) (ret0 pkg.VMInfo, ret1 error) {
 	args := []interface{}{arg0}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "Inspect", args...)
@@ -141,6 +192,40 @@ func (s *VMModuleStub) LogsFull(ctx context.Context, arg0 string) (ret0 string,
 	return
 }
 
+func (s *VMModuleStub) LogsInfo(ctx context.Context, arg0 string) (ret0 pkg.LogsInfo, ret1 error) {
+	args := []interface{}{arg0}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "LogsInfo", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *VMModuleStub) LogsRange(ctx context.Context, arg0 string, arg1 int64, arg2 int64) (ret0 pkg.LogsChunk, ret1 error) {
+	args := []interface{}{arg0, arg1, arg2}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "LogsRange", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret1 = result.CallError()
+	loader := zbus.Loader{
+		&ret0,
+	}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *VMModuleStub) Metrics(ctx context.Context) (ret0 pkg.MachineMetrics, ret1 error) {
 	args := []interface{}{}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "Metrics", args...)
@@ -158,6 +243,36 @@ func (s *VMModuleStub) Metrics(ctx context.Context) (ret0 pkg.MachineMetrics, re
 	return
 }
 
+func (s *VMModuleStub) Resize(ctx context.Context, arg0 string, arg1 uint8, arg2 gridtypes.Unit) (ret0 error) {
+	args := []interface{}{arg0, arg1, arg2}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "Resize", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *VMModuleStub) Restart(ctx context.Context, arg0 string, arg1 time.Duration) (ret0 error) {
+	args := []interface{}{arg0, arg1}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "Restart", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *VMModuleStub) Run(ctx context.Context, arg0 pkg.VM) (ret0 pkg.MachineInfo, ret1 error) {
 	args := []interface{}{arg0}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "Run", args...)
@@ -175,6 +290,36 @@ func (s *VMModuleStub) Run(ctx context.Context, arg0 pkg.VM) (ret0 pkg.MachineIn
 	return
 }
 
+func (s *VMModuleStub) SetLogsRetention(ctx context.Context, arg0 string, arg1 pkg.LogsRetention) (ret0 error) {
+	args := []interface{}{arg0, arg1}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "SetLogsRetention", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *VMModuleStub) Shutdown(ctx context.Context, arg0 string, arg1 time.Duration) (ret0 error) {
+	args := []interface{}{arg0, arg1}
+	result, err := s.client.RequestContext(ctx, s.module, s.object, "Shutdown", args...)
+	if err != nil {
+		panic(err)
+	}
+	result.PanicOnError()
+	ret0 = result.CallError()
+	loader := zbus.Loader{}
+	if err := result.Unmarshal(&loader); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *VMModuleStub) StreamCreate(ctx context.Context, arg0 string, arg1 pkg.Stream) (ret0 error) {
 	args := []interface{}{arg0, arg1}
 	result, err := s.client.RequestContext(ctx, s.module, s.object, "StreamCreate", args...)
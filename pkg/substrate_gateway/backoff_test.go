@@ -0,0 +1,43 @@
+package substrategw
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffConfigured(t *testing.T) {
+	gw := &substrateGateway{
+		breaker: newBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
+	}
+	WithWriteBackoff(BackoffConfig{
+		InitialInterval: time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		MaxElapsedTime:  100 * time.Millisecond,
+	})(gw)
+
+	calls := 0
+	start := time.Now()
+	err := gw.write("Report", func() error {
+		calls++
+		return fmt.Errorf("connection refused")
+	})
+	elapsed := time.Since(start)
+
+	require.Error(t, err)
+	// retried for the configured budget, far less than the default 5s
+	require.Greater(t, calls, 1)
+	require.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+	require.Less(t, elapsed, time.Second)
+}
+
+func TestBackoffDefaults(t *testing.T) {
+	exp, ok := BackoffConfig{MaxElapsedTime: time.Second}.backoff().(*backoff.ExponentialBackOff)
+	require.True(t, ok)
+	require.Equal(t, DefaultBackoff.InitialInterval, exp.InitialInterval)
+	require.Equal(t, DefaultBackoff.MaxInterval, exp.MaxInterval)
+	require.Equal(t, time.Second, exp.MaxElapsedTime)
+}
@@ -0,0 +1,207 @@
+package substrategw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+	"github.com/rs/zerolog/log"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+)
+
+const (
+	// DefaultBreakerThreshold is the number of consecutive failed calls that
+	// open the circuit breaker
+	DefaultBreakerThreshold = 3
+	// DefaultBreakerCooldown is how long the circuit breaker stays open before
+	// a call is allowed through to probe the chain
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned by the gateway calls while the circuit breaker
+// is open, without trying to reach the chain
+var ErrCircuitOpen = errors.New("substrate circuit breaker is open, chain is unreachable")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// breaker is a circuit breaker shared by all the gateway calls. After threshold
+// consecutive failed calls it opens, and all calls fail fast with ErrCircuitOpen
+// for the cooldown period. Then it half-opens and lets a single call through to
+// probe the chain, the breaker is closed again if the probe succeeds, otherwise
+// it's open for another cooldown period.
+type breaker struct {
+	m         sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	state    breakerState
+	failures int
+	opened   time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow returns ErrCircuitOpen if the call is not allowed through
+func (b *breaker) allow() error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.opened) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		// this call is the probe, other calls still fail fast
+		// until the probe is done
+		b.state = breakerHalfOpen
+		log.Info().Msg("substrate circuit breaker is half-open, probing the chain")
+		return nil
+	case breakerHalfOpen:
+		return ErrCircuitOpen
+	}
+
+	return nil
+}
+
+// done records the result of a call that was allowed through
+func (b *breaker) done(err error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if !isUnreachable(err) {
+		if b.state != breakerClosed {
+			log.Info().Msg("substrate circuit breaker is closed, chain is reachable again")
+		}
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		if b.state == breakerClosed {
+			log.Error().Err(err).Int("failures", b.failures).Msg("substrate circuit breaker is open")
+		}
+		b.state = breakerOpen
+		b.opened = b.now()
+	}
+}
+
+// reset closes the breaker
+func (b *breaker) reset() {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+// transportErrors are the messages of connection errors that reach the
+// gateway flattened into strings by the rpc client
+var transportErrors = []string{
+	"connection refused",
+	"connection reset",
+	"broken pipe",
+	"i/o timeout",
+	"no such host",
+	"network is unreachable",
+	"use of closed network connection",
+	"websocket: close",
+}
+
+// isUnreachable checks if the call failed to reach the chain. Only transport
+// errors (dial, closed connection, timeouts) count, errors returned by the
+// chain itself (not found, usurped, failed extrinsics, ...) mean the chain is
+// reachable.
+func isUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// the activation service is not the chain, even if it can't be reached
+	var activation substrate.ActivationServiceError
+	if errors.As(err, &activation) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, substrate.ErrClosed) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, transport := range transportErrors {
+		if strings.Contains(msg, transport) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// read retries a call that only reads from the chain
+func (g *substrateGateway) read(method string, op backoff.Operation) error {
+	return g.retry(method, g.readBackoff, op)
+}
+
+// write retries a call that submits an extrinsic
+func (g *substrateGateway) write(method string, op backoff.Operation) error {
+	return g.retry(method, g.writeBackoff, op)
+}
+
+// retry runs op with retries through the circuit breaker, all substrate calls
+// must go through it so the breaker state is shared. The call is observed by
+// the gateway metrics hook.
+func (g *substrateGateway) retry(method string, cfg BackoffConfig, op backoff.Operation) (err error) {
+	if g.metrics != nil {
+		defer func(start time.Time) {
+			g.metrics.Observe(method, time.Since(start), err)
+		}(time.Now())
+	}
+
+	if err := g.breaker.allow(); err != nil {
+		return err
+	}
+
+	err = backoff.Retry(op, cfg.backoff())
+	g.breaker.done(err)
+	return err
+}
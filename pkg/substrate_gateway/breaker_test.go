@@ -0,0 +1,151 @@
+package substrategw
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+// fakeClock is a settable breaker clock
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func testBreakerGateway(threshold int, cooldown time.Duration) (*substrateGateway, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	gw := &substrateGateway{
+		// each call is a single attempt
+		readBackoff: BackoffConfig{MaxElapsedTime: time.Nanosecond},
+	}
+	WithBreaker(threshold, cooldown)(gw)
+	gw.breaker.now = clock.Now
+	return gw, clock
+}
+
+func TestBreakerTrips(t *testing.T) {
+	gw, _ := testBreakerGateway(3, time.Minute)
+
+	calls := 0
+	down := func() error {
+		calls++
+		return fmt.Errorf("connection refused")
+	}
+
+	for i := 0; i < 3; i++ {
+		require.EqualError(t, gw.read("GetNode", down), "connection refused")
+	}
+	require.Equal(t, 3, calls)
+
+	// the breaker is open, calls fail fast without reaching the chain
+	for i := 0; i < 5; i++ {
+		require.ErrorIs(t, gw.read("GetNode", down), ErrCircuitOpen)
+	}
+	require.Equal(t, 3, calls)
+
+	serr := buildSubstrateError(gw.read("GetNode", down))
+	require.True(t, serr.IsCode(pkg.CodeCircuitOpen))
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	gw, clock := testBreakerGateway(2, time.Minute)
+
+	chainErr := fmt.Errorf("connection refused")
+	calls := 0
+	call := func() error {
+		calls++
+		return chainErr
+	}
+
+	require.Error(t, gw.read("GetNode", call))
+	require.Error(t, gw.read("GetNode", call))
+	require.ErrorIs(t, gw.read("GetNode", call), ErrCircuitOpen)
+
+	// after the cooldown a single probe is let through, the chain is still
+	// down so the breaker is open for another cooldown
+	clock.now = clock.now.Add(time.Minute)
+	require.Equal(t, chainErr, gw.read("GetNode", call))
+	require.Equal(t, 3, calls)
+	require.ErrorIs(t, gw.read("GetNode", call), ErrCircuitOpen)
+
+	// the chain is back, the probe closes the breaker
+	clock.now = clock.now.Add(time.Minute)
+	chainErr = nil
+	require.NoError(t, gw.read("GetNode", call))
+	require.NoError(t, gw.read("GetNode", call))
+	require.Equal(t, 5, calls)
+	require.Equal(t, breakerClosed, gw.breaker.state)
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	gw, clock := testBreakerGateway(1, time.Minute)
+
+	require.Error(t, gw.read("GetNode", func() error { return fmt.Errorf("connection refused") }))
+	clock.now = clock.now.Add(time.Minute)
+
+	// while the probe is running other calls still fail fast
+	err := gw.read("GetNode", func() error {
+		require.ErrorIs(t, gw.read("GetNode", func() error { return nil }), ErrCircuitOpen)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, gw.read("GetNode", func() error { return nil }))
+}
+
+func TestBreakerChainErrors(t *testing.T) {
+	gw, _ := testBreakerGateway(2, time.Minute)
+
+	// errors returned by the chain mean it's reachable
+	for i := 0; i < 5; i++ {
+		require.ErrorIs(t, gw.read("GetNode", func() error { return substrate.ErrNotFound }), substrate.ErrNotFound)
+	}
+	require.Equal(t, breakerClosed, gw.breaker.state)
+
+	// a success resets the consecutive failures
+	require.Error(t, gw.read("GetNode", func() error { return fmt.Errorf("i/o timeout") }))
+	require.NoError(t, gw.read("GetNode", func() error { return nil }))
+	require.Error(t, gw.read("GetNode", func() error { return fmt.Errorf("i/o timeout") }))
+	require.Equal(t, breakerClosed, gw.breaker.state)
+}
+
+func TestBreakerModuleErrors(t *testing.T) {
+	gw, _ := testBreakerGateway(2, time.Minute)
+
+	// failed extrinsics and chain module errors don't open the breaker
+	for _, err := range []error{
+		fmt.Errorf("failed to report uptime: module error: TfgridModule.NodeNotExists"),
+		substrate.ActivationServiceError{Err: fmt.Errorf("dial tcp: lookup activation: no such host")},
+		fmt.Errorf("extrinsic timeout waiting for block"),
+	} {
+		for i := 0; i < 5; i++ {
+			require.Error(t, gw.read("Report", func() error { return err }))
+		}
+	}
+	require.Equal(t, breakerClosed, gw.breaker.state)
+	require.NoError(t, gw.read("GetTwin", func() error { return nil }))
+
+	// transport errors do
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	require.Error(t, gw.read("GetTwin", func() error { return dial }))
+	require.Error(t, gw.read("GetTwin", func() error { return substrate.ErrClosed }))
+	require.Equal(t, breakerOpen, gw.breaker.state)
+}
+
+func TestBreakerReset(t *testing.T) {
+	gw, _ := testBreakerGateway(1, time.Minute)
+
+	require.Error(t, gw.read("GetNode", func() error { return fmt.Errorf("connection refused") }))
+	require.ErrorIs(t, gw.read("GetNode", func() error { return nil }), ErrCircuitOpen)
+
+	gw.breaker.reset()
+	require.NoError(t, gw.read("GetNode", func() error { return nil }))
+}
@@ -0,0 +1,52 @@
+package substrategw
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// timeClient is a substrate client that only answers Time
+type timeClient struct {
+	client
+	calls int
+	err   error
+}
+
+func (c *timeClient) Time() (time.Time, error) {
+	c.calls++
+	return time.Now(), c.err
+}
+
+func TestHealthz(t *testing.T) {
+	sub := &timeClient{}
+	hook := &recordingHook{}
+	gw := &substrateGateway{sub: sub, metrics: hook}
+
+	require.NoError(t, gw.Healthz())
+	require.Equal(t, 1, sub.calls)
+	require.Equal(t, "Healthz", hook.calls[0].method)
+}
+
+func TestHealthzFailed(t *testing.T) {
+	sub := &timeClient{err: fmt.Errorf("connection refused")}
+	gw := &substrateGateway{
+		sub:         sub,
+		breaker:     newBreaker(1, time.Minute),
+		readBackoff: BackoffConfig{MaxElapsedTime: time.Nanosecond},
+	}
+
+	// the raw error is returned without retries
+	require.EqualError(t, gw.Healthz(), "connection refused")
+	require.Equal(t, 1, sub.calls)
+
+	// the breaker is open, but healthz still reaches the chain
+	require.ErrorIs(t, gw.read("GetTime", func() error { return sub.err }), sub.err)
+	require.ErrorIs(t, gw.read("GetTime", func() error { return nil }), ErrCircuitOpen)
+
+	sub.err = nil
+	require.NoError(t, gw.Healthz())
+	require.Equal(t, 2, sub.calls)
+}
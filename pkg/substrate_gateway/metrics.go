@@ -0,0 +1,152 @@
+package substrategw
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/metrics"
+)
+
+// MetricsHook observes every substrate call made by the gateway. dur is the
+// total time spent on the call including all retries, err is the final error
+// of the call if any.
+type MetricsHook interface {
+	Observe(method string, dur time.Duration, err error)
+}
+
+// WithMetrics sets the hook that observes the gateway calls
+func WithMetrics(hook MetricsHook) Option {
+	return func(g *substrateGateway) {
+		g.metrics = hook
+	}
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) Observe(string, time.Duration, error) {}
+
+// ErrorClass classifies the error of a substrate call, it's "ok" if the call
+// succeeded, otherwise the name of the substrate error code.
+func ErrorClass(err error) string {
+	if err == nil {
+		return "ok"
+	}
+
+	switch buildSubstrateError(err).Code {
+	case pkg.CodeNotFound:
+		return "not_found"
+	case pkg.CodeBurnTransactionNotFound:
+		return "burn_transaction_not_found"
+	case pkg.CodeRefundTransactionNotFound:
+		return "refund_transaction_not_found"
+	case pkg.CodeFailedToDecode:
+		return "failed_to_decode"
+	case pkg.CodeInvalidVersion:
+		return "invalid_version"
+	case pkg.CodeUnknownVersion:
+		return "unknown_version"
+	case pkg.CodeIsUsurped:
+		return "is_usurped"
+	case pkg.CodeAccountNotFound:
+		return "account_not_found"
+	case pkg.CodeDepositFeeNotFound:
+		return "deposit_fee_not_found"
+	case pkg.CodeMintTransactionNotFound:
+		return "mint_transaction_not_found"
+	case pkg.CodeCircuitOpen:
+		return "circuit_open"
+	default:
+		return "error"
+	}
+}
+
+const metricsPrefix = "zos_substrate_"
+
+// durationBuckets are the upper bounds (in seconds) of the call duration histogram
+var durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// PrometheusMetrics is a MetricsHook that aggregates the calls per method and
+// renders them in prometheus text exposition format. It serves the metrics over
+// http so it can be scraped directly.
+type PrometheusMetrics struct {
+	m       sync.Mutex
+	methods map[string]*methodMetrics
+}
+
+type methodMetrics struct {
+	// calls by error class
+	calls map[string]uint64
+	// buckets are the cumulative counts of durationBuckets
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// NewPrometheusMetrics creates an empty PrometheusMetrics
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{methods: make(map[string]*methodMetrics)}
+}
+
+// Observe implements MetricsHook
+func (p *PrometheusMetrics) Observe(method string, dur time.Duration, err error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	m, ok := p.methods[method]
+	if !ok {
+		m = &methodMetrics{
+			calls:   make(map[string]uint64),
+			buckets: make([]uint64, len(durationBuckets)),
+		}
+		p.methods[method] = m
+	}
+
+	m.calls[ErrorClass(err)]++
+
+	seconds := dur.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			m.buckets[i]++
+		}
+	}
+	m.count++
+	m.sum += seconds
+}
+
+// WriteTo writes the metrics in prometheus text exposition format
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	methods := make([]string, 0, len(p.methods))
+	for method := range p.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	out := metrics.NewWriter()
+	for _, method := range methods {
+		m := p.methods[method]
+		classes := make([]string, 0, len(m.calls))
+		for class := range m.calls {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+
+		for _, class := range classes {
+			out.Counter(metricsPrefix+"calls_total", "Number of substrate calls by method and result", float64(m.calls[class]), "method", method, "result", class)
+		}
+		out.Histogram(metricsPrefix+"call_duration_seconds", "Duration of substrate calls including retries", durationBuckets, m.buckets, m.count, m.sum, "method", method)
+	}
+
+	return out.WriteTo(w)
+}
+
+// ServeHTTP serves the metrics so they can be scraped by prometheus
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	metrics.Serve(w, p)
+}
@@ -0,0 +1,95 @@
+package substrategw
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+)
+
+// fakeClient is a substrate client that only knows about contract 1
+type fakeClient struct {
+	client
+}
+
+func (f *fakeClient) GetContract(id uint64) (*substrate.Contract, error) {
+	if id != 1 {
+		return nil, substrate.ErrNotFound
+	}
+	return &substrate.Contract{}, nil
+}
+
+type observation struct {
+	method string
+	dur    time.Duration
+	err    error
+}
+
+// recordingHook records all the observed calls
+type recordingHook struct {
+	m     sync.Mutex
+	calls []observation
+}
+
+func (r *recordingHook) Observe(method string, dur time.Duration, err error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.calls = append(r.calls, observation{method: method, dur: dur, err: err})
+}
+
+func TestMetricsObserveGetContract(t *testing.T) {
+	hook := &recordingHook{}
+	gw := &substrateGateway{
+		sub:         &fakeClient{},
+		breaker:     newBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
+		readBackoff: BackoffConfig{MaxElapsedTime: time.Nanosecond},
+	}
+	WithMetrics(hook)(gw)
+
+	_, serr := gw.GetContract(1)
+	require.False(t, serr.IsError())
+	require.Len(t, hook.calls, 1)
+	require.Equal(t, "GetContract", hook.calls[0].method)
+	require.NoError(t, hook.calls[0].err)
+	require.Equal(t, "ok", ErrorClass(hook.calls[0].err))
+
+	_, serr = gw.GetContract(2)
+	require.True(t, serr.IsError())
+	require.Len(t, hook.calls, 2)
+	require.Equal(t, "GetContract", hook.calls[1].method)
+	require.Equal(t, "not_found", ErrorClass(hook.calls[1].err))
+}
+
+func TestErrorClass(t *testing.T) {
+	require.Equal(t, "ok", ErrorClass(nil))
+	require.Equal(t, "not_found", ErrorClass(fmt.Errorf("contract: %w", substrate.ErrNotFound)))
+	require.Equal(t, "circuit_open", ErrorClass(ErrCircuitOpen))
+	require.Equal(t, "error", ErrorClass(fmt.Errorf("connection refused")))
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	metrics.Observe("GetContract", 200*time.Millisecond, nil)
+	metrics.Observe("GetContract", 3*time.Second, substrate.ErrNotFound)
+	metrics.Observe("Report", time.Second, fmt.Errorf("connection refused"))
+
+	var buf bytes.Buffer
+	_, err := metrics.WriteTo(&buf)
+	require.NoError(t, err)
+
+	out := buf.String()
+	require.Contains(t, out, "# TYPE zos_substrate_calls_total counter\n")
+	require.Contains(t, out, `zos_substrate_calls_total{method="GetContract",result="ok"} 1`)
+	require.Contains(t, out, `zos_substrate_calls_total{method="GetContract",result="not_found"} 1`)
+	require.Contains(t, out, `zos_substrate_calls_total{method="Report",result="error"} 1`)
+	require.Contains(t, out, `zos_substrate_call_duration_seconds_bucket{method="GetContract",le="0.25"} 1`)
+	require.Contains(t, out, `zos_substrate_call_duration_seconds_bucket{method="GetContract",le="5"} 2`)
+	require.Contains(t, out, `zos_substrate_call_duration_seconds_bucket{method="GetContract",le="+Inf"} 2`)
+	require.Contains(t, out, `zos_substrate_call_duration_seconds_sum{method="GetContract"} 3.2`)
+	require.Contains(t, out, `zos_substrate_call_duration_seconds_count{method="Report"} 1`)
+}
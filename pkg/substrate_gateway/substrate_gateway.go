@@ -3,23 +3,146 @@ package substrategw
 import (
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff/v3"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	cache "github.com/patrickmn/go-cache"
 	"github.com/rs/zerolog/log"
 	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
 	"github.com/threefoldtech/zosbase/pkg"
 )
 
+const (
+	// DefaultTwinTTL is how long a twin is cached by GetTwin
+	DefaultTwinTTL = 10 * time.Minute
+)
+
+// client is the subset of the substrate client used by the gateway
+type client interface {
+	Close()
+	CreateNode(identity substrate.Identity, node substrate.Node) (uint32, error)
+	CreateTwin(identity substrate.Identity, relay string, pk []byte) (uint32, error)
+	EnsureAccount(identity substrate.Identity, activationURL, termsAndConditionsLink, termsAndConditionsHash string) (substrate.AccountInfo, error)
+	GetContract(id uint64) (*substrate.Contract, error)
+	GetContractIDByNameRegistration(name string) (uint64, error)
+	GetFarm(id uint32) (*substrate.Farm, error)
+	GetNode(id uint32) (*substrate.Node, error)
+	GetNodeByTwinID(twin uint32) (uint32, error)
+	GetNodeContracts(node uint32) ([]types.U64, error)
+	GetNodeRentContract(node uint32) (uint64, error)
+	GetNodes(farmID uint32) ([]uint32, error)
+	GetPowerTarget(nodeID uint32) (substrate.NodePower, error)
+	GetTwin(id uint32) (*substrate.Twin, error)
+	GetTwinByPubKey(pk []byte) (uint32, error)
+	GetZosVersion() (string, error)
+	Report(identity substrate.Identity, consumptions []substrate.NruConsumption) (types.Hash, error)
+	SetContractConsumption(identity substrate.Identity, resources ...substrate.ContractResources) error
+	SetNodePowerState(identity substrate.Identity, up bool) (types.Hash, error)
+	Time() (time.Time, error)
+	UpdateNode(identity substrate.Identity, node substrate.Node) (uint32, error)
+	UpdateNodeUptimeV2(identity substrate.Identity, uptime, timestampHint uint64) (types.Hash, error)
+}
+
 type substrateGateway struct {
-	sub      *substrate.Substrate
+	sub      client
 	mu       sync.Mutex
 	identity substrate.Identity
+
+	uptimeMu sync.Mutex
+	uptime   pkg.UptimeStatus
+
+	// twins caches GetTwin results, it's nil if caching is disabled
+	twins *cache.Cache
+	// getTwin gets the twin from the chain
+	getTwin func(id uint32) (substrate.Twin, error)
+
+	breaker *breaker
+	// backoff of the calls that only read from the chain
+	readBackoff BackoffConfig
+	// backoff of the calls that submit extrinsics
+	writeBackoff BackoffConfig
+
+	metrics MetricsHook
+}
+
+// BackoffConfig is the exponential backoff used to retry a failed call
+type BackoffConfig struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// MaxElapsedTime is the max time spent on a call before giving up
+	MaxElapsedTime time.Duration
+}
+
+// DefaultBackoff is the default backoff of both read and write calls
+var DefaultBackoff = BackoffConfig{
+	InitialInterval: 500 * time.Millisecond,
+	MaxInterval:     2 * time.Second,
+	MaxElapsedTime:  5 * time.Second,
+}
+
+// backoff creates the backoff of a call, unset values are taken from
+// the DefaultBackoff
+func (c BackoffConfig) backoff() backoff.BackOff {
+	exp := backoff.NewExponentialBackOff()
+	exp.InitialInterval = DefaultBackoff.InitialInterval
+	if c.InitialInterval > 0 {
+		exp.InitialInterval = c.InitialInterval
+	}
+	exp.MaxInterval = DefaultBackoff.MaxInterval
+	if c.MaxInterval > 0 {
+		exp.MaxInterval = c.MaxInterval
+	}
+	exp.MaxElapsedTime = DefaultBackoff.MaxElapsedTime
+	if c.MaxElapsedTime > 0 {
+		exp.MaxElapsedTime = c.MaxElapsedTime
+	}
+	exp.Reset()
+	return exp
 }
 
-func NewSubstrateGateway(manager substrate.Manager, identity substrate.Identity) (pkg.SubstrateGateway, error) {
+// Option is a substrate gateway option
+type Option func(*substrateGateway)
+
+// WithTwinTTL sets how long a twin is cached by GetTwin. A ttl of
+// zero disables the cache.
+func WithTwinTTL(ttl time.Duration) Option {
+	return func(g *substrateGateway) {
+		if ttl <= 0 {
+			g.twins = nil
+			return
+		}
+		g.twins = cache.New(ttl, 2*ttl)
+	}
+}
+
+// WithReadBackoff sets the backoff of the calls that only read from the chain
+func WithReadBackoff(cfg BackoffConfig) Option {
+	return func(g *substrateGateway) {
+		g.readBackoff = cfg
+	}
+}
+
+// WithWriteBackoff sets the backoff of the calls that submit extrinsics, those
+// usually need a wider budget than reads since they wait for the extrinsic to be
+// included in a block
+func WithWriteBackoff(cfg BackoffConfig) Option {
+	return func(g *substrateGateway) {
+		g.writeBackoff = cfg
+	}
+}
+
+// WithBreaker sets the number of consecutive failed calls that open the circuit
+// breaker, and how long it stays open before the chain is probed again
+func WithBreaker(threshold int, cooldown time.Duration) Option {
+	return func(g *substrateGateway) {
+		g.breaker = newBreaker(threshold, cooldown)
+	}
+}
+
+func NewSubstrateGateway(manager substrate.Manager, identity substrate.Identity, opts ...Option) (pkg.SubstrateGateway, error) {
 	sub, err := manager.Substrate()
 	if err != nil {
 		return nil, err
@@ -28,6 +151,17 @@ func NewSubstrateGateway(manager substrate.Manager, identity substrate.Identity)
 		sub:      sub,
 		mu:       sync.Mutex{},
 		identity: identity,
+		twins:    cache.New(DefaultTwinTTL, 2*DefaultTwinTTL),
+
+		breaker:      newBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown),
+		readBackoff:  DefaultBackoff,
+		writeBackoff: DefaultBackoff,
+		metrics:      noopMetrics{},
+	}
+	gw.getTwin = gw.fetchTwin
+
+	for _, opt := range opts {
+		opt(gw)
 	}
 	return gw, nil
 }
@@ -43,23 +177,16 @@ func (g *substrateGateway) UpdateSubstrateGatewayConnection(manager substrate.Ma
 	g.sub.Close()
 
 	g.sub = sub
+	// give the new connection a chance
+	g.breaker.reset()
 	return nil
 }
 
-// createBackoff creates an exponential backoff configuration for substrate calls
-func createBackoff() backoff.BackOff {
-	exp := backoff.NewExponentialBackOff()
-	exp.MaxInterval = 2 * time.Second
-	exp.InitialInterval = 500 * time.Millisecond
-	exp.MaxElapsedTime = 5 * time.Second
-	return exp
-}
-
 func (g *substrateGateway) GetZosVersion() (string, error) {
 	log.Debug().Str("method", "GetZosVersion").Msg("method called")
 
 	var result string
-	err := backoff.Retry(func() error {
+	err := g.read("GetZosVersion", func() error {
 		version, err := g.sub.GetZosVersion()
 		if err != nil {
 			log.Debug().Err(err).Msg("GetZosVersion failed, retrying")
@@ -67,7 +194,7 @@ func (g *substrateGateway) GetZosVersion() (string, error) {
 		}
 		result = version
 		return nil
-	}, createBackoff())
+	})
 
 	return result, err
 }
@@ -82,7 +209,7 @@ func (g *substrateGateway) CreateNode(node substrate.Node) (uint32, error) {
 	var result uint32
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	err := backoff.Retry(func() error {
+	err := g.write("CreateNode", func() error {
 		nodeID, err := g.sub.CreateNode(g.identity, node)
 		if err != nil {
 			log.Debug().Err(err).Msg("CreateNode failed, retrying")
@@ -90,7 +217,7 @@ func (g *substrateGateway) CreateNode(node substrate.Node) (uint32, error) {
 		}
 		result = nodeID
 		return nil
-	}, createBackoff())
+	})
 
 	return result, err
 }
@@ -101,7 +228,7 @@ func (g *substrateGateway) CreateTwin(relay string, pk []byte) (uint32, error) {
 	var result uint32
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	err := backoff.Retry(func() error {
+	err := g.write("CreateTwin", func() error {
 		twinID, err := g.sub.CreateTwin(g.identity, relay, pk)
 		if err != nil {
 			log.Debug().Err(err).Msg("CreateTwin failed, retrying")
@@ -109,7 +236,7 @@ func (g *substrateGateway) CreateTwin(relay string, pk []byte) (uint32, error) {
 		}
 		result = twinID
 		return nil
-	}, createBackoff())
+	})
 
 	return result, err
 }
@@ -125,7 +252,7 @@ func (g *substrateGateway) EnsureAccount(activationURL []string, termsAndConditi
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	for _, url := range activationURL {
-		err = backoff.Retry(func() error {
+		err = g.write("EnsureAccount", func() error {
 			accountInfo, retryErr := g.sub.EnsureAccount(g.identity, url, termsAndConditionsLink, termsAndConditionsHash)
 			if retryErr != nil {
 				log.Debug().Str("activation url", url).Err(retryErr).Msg("EnsureAccount failed, retrying")
@@ -133,7 +260,7 @@ func (g *substrateGateway) EnsureAccount(activationURL []string, termsAndConditi
 			}
 			info = accountInfo
 			return nil
-		}, createBackoff())
+		})
 
 		// check other activationURL only if EnsureAccount failed with ActivationServiceError
 		if err == nil || !errors.As(err, &substrate.ActivationServiceError{}) {
@@ -147,7 +274,7 @@ func (g *substrateGateway) EnsureAccount(activationURL []string, termsAndConditi
 func (g *substrateGateway) GetContract(id uint64) (result substrate.Contract, serr pkg.SubstrateError) {
 	log.Trace().Str("method", "GetContract").Uint64("id", id).Msg("method called")
 
-	err := backoff.Retry(func() error {
+	err := g.read("GetContract", func() error {
 		contract, retryErr := g.sub.GetContract(id)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uint64("id", id).Msg("GetContract failed, retrying")
@@ -155,7 +282,7 @@ func (g *substrateGateway) GetContract(id uint64) (result substrate.Contract, se
 		}
 		result = *contract
 		return nil
-	}, createBackoff())
+	})
 
 	serr = buildSubstrateError(err)
 	return
@@ -164,7 +291,7 @@ func (g *substrateGateway) GetContract(id uint64) (result substrate.Contract, se
 func (g *substrateGateway) GetContractIDByNameRegistration(name string) (result uint64, serr pkg.SubstrateError) {
 	log.Trace().Str("method", "GetContractIDByNameRegistration").Str("name", name).Msg("method called")
 
-	err := backoff.Retry(func() error {
+	err := g.read("GetContractIDByNameRegistration", func() error {
 		contractID, retryErr := g.sub.GetContractIDByNameRegistration(name)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Str("name", name).Msg("GetContractIDByNameRegistration failed, retrying")
@@ -172,7 +299,7 @@ func (g *substrateGateway) GetContractIDByNameRegistration(name string) (result
 		}
 		result = contractID
 		return nil
-	}, createBackoff())
+	})
 
 	serr = buildSubstrateError(err)
 	return
@@ -181,7 +308,7 @@ func (g *substrateGateway) GetContractIDByNameRegistration(name string) (result
 func (g *substrateGateway) GetFarm(id uint32) (result substrate.Farm, err error) {
 	log.Trace().Str("method", "GetFarm").Uint32("id", id).Msg("method called")
 
-	err = backoff.Retry(func() error {
+	err = g.read("GetFarm", func() error {
 		farm, retryErr := g.sub.GetFarm(id)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uint32("id", id).Msg("GetFarm failed, retrying")
@@ -189,7 +316,7 @@ func (g *substrateGateway) GetFarm(id uint32) (result substrate.Farm, err error)
 		}
 		result = *farm
 		return nil
-	}, createBackoff())
+	})
 
 	return
 }
@@ -197,7 +324,7 @@ func (g *substrateGateway) GetFarm(id uint32) (result substrate.Farm, err error)
 func (g *substrateGateway) GetNode(id uint32) (result substrate.Node, err error) {
 	log.Trace().Str("method", "GetNode").Uint32("id", id).Msg("method called")
 
-	err = backoff.Retry(func() error {
+	err = g.read("GetNode", func() error {
 		node, retryErr := g.sub.GetNode(id)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uint32("id", id).Msg("GetNode failed, retrying")
@@ -205,7 +332,7 @@ func (g *substrateGateway) GetNode(id uint32) (result substrate.Node, err error)
 		}
 		result = *node
 		return nil
-	}, createBackoff())
+	})
 
 	return
 }
@@ -213,7 +340,7 @@ func (g *substrateGateway) GetNode(id uint32) (result substrate.Node, err error)
 func (g *substrateGateway) GetNodeByTwinID(twin uint32) (result uint32, serr pkg.SubstrateError) {
 	log.Trace().Str("method", "GetNodeByTwinID").Uint32("twin", twin).Msg("method called")
 
-	err := backoff.Retry(func() error {
+	err := g.read("GetNodeByTwinID", func() error {
 		nodeID, retryErr := g.sub.GetNodeByTwinID(twin)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uint32("twin", twin).Msg("GetNodeByTwinID failed, retrying")
@@ -221,7 +348,7 @@ func (g *substrateGateway) GetNodeByTwinID(twin uint32) (result uint32, serr pkg
 		}
 		result = nodeID
 		return nil
-	}, createBackoff())
+	})
 
 	serr = buildSubstrateError(err)
 	return
@@ -231,7 +358,7 @@ func (g *substrateGateway) GetNodeContracts(node uint32) ([]types.U64, error) {
 	log.Trace().Str("method", "GetNodeContracts").Uint32("node", node).Msg("method called")
 
 	var result []types.U64
-	err := backoff.Retry(func() error {
+	err := g.read("GetNodeContracts", func() error {
 		contracts, retryErr := g.sub.GetNodeContracts(node)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uint32("node", node).Msg("GetNodeContracts failed, retrying")
@@ -239,7 +366,7 @@ func (g *substrateGateway) GetNodeContracts(node uint32) ([]types.U64, error) {
 		}
 		result = contracts
 		return nil
-	}, createBackoff())
+	})
 
 	return result, err
 }
@@ -247,7 +374,7 @@ func (g *substrateGateway) GetNodeContracts(node uint32) ([]types.U64, error) {
 func (g *substrateGateway) GetNodeRentContract(node uint32) (result uint64, serr pkg.SubstrateError) {
 	log.Trace().Str("method", "GetNodeRentContract").Uint32("node", node).Msg("method called")
 
-	err := backoff.Retry(func() error {
+	err := g.read("GetNodeRentContract", func() error {
 		contractID, retryErr := g.sub.GetNodeRentContract(node)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uint32("node", node).Msg("GetNodeRentContract failed, retrying")
@@ -255,7 +382,7 @@ func (g *substrateGateway) GetNodeRentContract(node uint32) (result uint64, serr
 		}
 		result = contractID
 		return nil
-	}, createBackoff())
+	})
 
 	serr = buildSubstrateError(err)
 	return
@@ -265,7 +392,7 @@ func (g *substrateGateway) GetNodes(farmID uint32) ([]uint32, error) {
 	log.Trace().Str("method", "GetNodes").Uint32("farm id", farmID).Msg("method called")
 
 	var result []uint32
-	err := backoff.Retry(func() error {
+	err := g.read("GetNodes", func() error {
 		nodes, retryErr := g.sub.GetNodes(farmID)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uint32("farm id", farmID).Msg("GetNodes failed, retrying")
@@ -273,7 +400,7 @@ func (g *substrateGateway) GetNodes(farmID uint32) ([]uint32, error) {
 		}
 		result = nodes
 		return nil
-	}, createBackoff())
+	})
 
 	return result, err
 }
@@ -281,7 +408,7 @@ func (g *substrateGateway) GetNodes(farmID uint32) ([]uint32, error) {
 func (g *substrateGateway) GetPowerTarget(nodeID uint32) (power substrate.NodePower, err error) {
 	log.Trace().Str("method", "GetPowerTarget").Uint32("node id", nodeID).Msg("method called")
 
-	err = backoff.Retry(func() error {
+	err = g.read("GetPowerTarget", func() error {
 		nodePower, retryErr := g.sub.GetPowerTarget(nodeID)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uint32("node id", nodeID).Msg("GetPowerTarget failed, retrying")
@@ -289,15 +416,48 @@ func (g *substrateGateway) GetPowerTarget(nodeID uint32) (power substrate.NodePo
 		}
 		power = nodePower
 		return nil
-	}, createBackoff())
+	})
 
 	return
 }
 
-func (g *substrateGateway) GetTwin(id uint32) (result substrate.Twin, err error) {
+// GetTwin gets the twin from the cache if it was fetched within the twin ttl,
+// otherwise the twin is fetched from the chain
+func (g *substrateGateway) GetTwin(id uint32) (substrate.Twin, error) {
 	log.Trace().Str("method", "GetTwin").Uint32("id", id).Msg("method called")
 
-	err = backoff.Retry(func() error {
+	if g.twins == nil {
+		return g.getTwin(id)
+	}
+
+	key := fmt.Sprint(id)
+	if twin, ok := g.twins.Get(key); ok {
+		return twin.(substrate.Twin), nil
+	}
+
+	twin, err := g.getTwin(id)
+	if err != nil {
+		return twin, err
+	}
+
+	g.twins.Set(key, twin, cache.DefaultExpiration)
+	return twin, nil
+}
+
+// InvalidateTwin drops the cached twin, so the next GetTwin fetches
+// it again from the chain. Used if the twin is known to be stale, for
+// example after the twin has changed its key.
+func (g *substrateGateway) InvalidateTwin(id uint32) {
+	log.Debug().Str("method", "InvalidateTwin").Uint32("id", id).Msg("method called")
+
+	if g.twins == nil {
+		return
+	}
+	g.twins.Delete(fmt.Sprint(id))
+}
+
+func (g *substrateGateway) fetchTwin(id uint32) (result substrate.Twin, err error) {
+	err = g.read("GetTwin", func() error {
 		twin, retryErr := g.sub.GetTwin(id)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uint32("id", id).Msg("GetTwin failed, retrying")
@@ -305,7 +465,7 @@ func (g *substrateGateway) GetTwin(id uint32) (result substrate.Twin, err error)
 		}
 		result = *twin
 		return nil
-	}, createBackoff())
+	})
 
 	return
 }
@@ -313,7 +473,7 @@ func (g *substrateGateway) GetTwin(id uint32) (result substrate.Twin, err error)
 func (g *substrateGateway) GetTwinByPubKey(pk []byte) (result uint32, serr pkg.SubstrateError) {
 	log.Trace().Str("method", "GetTwinByPubKey").Str("pk", hex.EncodeToString(pk)).Msg("method called")
 
-	err := backoff.Retry(func() error {
+	err := g.read("GetTwinByPubKey", func() error {
 		twinID, retryErr := g.sub.GetTwinByPubKey(pk)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Str("pk", hex.EncodeToString(pk)).Msg("GetTwinByPubKey failed, retrying")
@@ -321,7 +481,7 @@ func (g *substrateGateway) GetTwinByPubKey(pk []byte) (result uint32, serr pkg.S
 		}
 		result = twinID
 		return nil
-	}, createBackoff())
+	})
 
 	serr = buildSubstrateError(err)
 	return
@@ -337,7 +497,7 @@ func (g *substrateGateway) Report(consumptions []substrate.NruConsumption) (type
 	var result types.Hash
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	err := backoff.Retry(func() error {
+	err := g.write("Report", func() error {
 		hash, retryErr := g.sub.Report(g.identity, consumptions)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uints64("contract ids", contractIDs).Msg("Report failed, retrying")
@@ -345,7 +505,7 @@ func (g *substrateGateway) Report(consumptions []substrate.NruConsumption) (type
 		}
 		result = hash
 		return nil
-	}, createBackoff())
+	})
 
 	return result, err
 }
@@ -359,14 +519,14 @@ func (g *substrateGateway) SetContractConsumption(resources ...substrate.Contrac
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	err := backoff.Retry(func() error {
+	err := g.write("SetContractConsumption", func() error {
 		retryErr := g.sub.SetContractConsumption(g.identity, resources...)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uints64("contract ids", contractIDs).Msg("SetContractConsumption failed, retrying")
 			return retryErr
 		}
 		return nil
-	}, createBackoff())
+	})
 
 	return err
 }
@@ -376,7 +536,7 @@ func (g *substrateGateway) SetNodePowerState(up bool) (hash types.Hash, err erro
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	err = backoff.Retry(func() error {
+	err = g.write("SetNodePowerState", func() error {
 		resultHash, retryErr := g.sub.SetNodePowerState(g.identity, up)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Bool("up", up).Msg("SetNodePowerState failed, retrying")
@@ -384,7 +544,7 @@ func (g *substrateGateway) SetNodePowerState(up bool) (hash types.Hash, err erro
 		}
 		hash = resultHash
 		return nil
-	}, createBackoff())
+	})
 
 	return
 }
@@ -395,7 +555,7 @@ func (g *substrateGateway) UpdateNode(node substrate.Node) (uint32, error) {
 	var result uint32
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	err := backoff.Retry(func() error {
+	err := g.write("UpdateNode", func() error {
 		nodeID, retryErr := g.sub.UpdateNode(g.identity, node)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Msg("UpdateNode failed, retrying")
@@ -403,7 +563,7 @@ func (g *substrateGateway) UpdateNode(node substrate.Node) (uint32, error) {
 		}
 		result = nodeID
 		return nil
-	}, createBackoff())
+	})
 
 	return result, err
 }
@@ -417,7 +577,7 @@ func (g *substrateGateway) UpdateNodeUptimeV2(uptime uint64, timestampHint uint6
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	err = backoff.Retry(func() error {
+	err = g.write("UpdateNodeUptimeV2", func() error {
 		resultHash, retryErr := g.sub.UpdateNodeUptimeV2(g.identity, uptime, timestampHint)
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Uint64("uptime", uptime).Uint64("timestamp hint", timestampHint).Msg("UpdateNodeUptimeV2 failed, retrying")
@@ -425,7 +585,12 @@ func (g *substrateGateway) UpdateNodeUptimeV2(uptime uint64, timestampHint uint6
 		}
 		hash = resultHash
 		return nil
-	}, createBackoff())
+	})
+
+	g.setUptimeStatus(uptime, hash, err)
+	if err != nil {
+		return
+	}
 
 	log.Debug().
 		Str("method", "UpdateNodeUptimeV2").
@@ -436,11 +601,39 @@ func (g *substrateGateway) UpdateNodeUptimeV2(uptime uint64, timestampHint uint6
 	return
 }
 
+func (g *substrateGateway) setUptimeStatus(uptime uint64, hash types.Hash, err error) {
+	g.uptimeMu.Lock()
+	defer g.uptimeMu.Unlock()
+
+	now := time.Now()
+	g.uptime.Attempted = now
+	if err != nil {
+		g.uptime.Failed = true
+		g.uptime.Error = err.Error()
+		return
+	}
+
+	g.uptime.Uptime = uptime
+	g.uptime.Submitted = now
+	g.uptime.Hash = hash.Hex()
+	g.uptime.Failed = false
+	g.uptime.Error = ""
+}
+
+// UptimeStatus returns the result of the last uptime report. It only
+// knows about reports since the gateway was started.
+func (g *substrateGateway) UptimeStatus() (pkg.UptimeStatus, error) {
+	g.uptimeMu.Lock()
+	defer g.uptimeMu.Unlock()
+
+	return g.uptime, nil
+}
+
 func (g *substrateGateway) GetTime() (time.Time, error) {
 	log.Trace().Str("method", "Time").Msg("method called")
 
 	var result time.Time
-	err := backoff.Retry(func() error {
+	err := g.read("GetTime", func() error {
 		timeResult, retryErr := g.sub.Time()
 		if retryErr != nil {
 			log.Debug().Err(retryErr).Msg("GetTime failed, retrying")
@@ -448,11 +641,27 @@ func (g *substrateGateway) GetTime() (time.Time, error) {
 		}
 		result = timeResult
 		return nil
-	}, createBackoff())
+	})
 
 	return result, err
 }
 
+// Healthz checks if the chain is reachable right now with a single lightweight
+// call. The call is not retried, not cached and does not go through the circuit
+// breaker, so the raw error of the chain is returned.
+func (g *substrateGateway) Healthz() (err error) {
+	log.Trace().Str("method", "Healthz").Msg("method called")
+
+	if g.metrics != nil {
+		defer func(start time.Time) {
+			g.metrics.Observe("Healthz", time.Since(start), err)
+		}(time.Now())
+	}
+
+	_, err = g.sub.Time()
+	return err
+}
+
 func buildSubstrateError(err error) (serr pkg.SubstrateError) {
 	if err == nil {
 		return
@@ -481,6 +690,8 @@ func buildSubstrateError(err error) (serr pkg.SubstrateError) {
 		serr.Code = pkg.CodeDepositFeeNotFound
 	} else if errors.Is(err, substrate.ErrMintTransactionNotFound) {
 		serr.Code = pkg.CodeMintTransactionNotFound
+	} else if errors.Is(err, ErrCircuitOpen) {
+		serr.Code = pkg.CodeCircuitOpen
 	}
 	return
 }
@@ -0,0 +1,131 @@
+package substrategw
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stretchr/testify/require"
+	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
+)
+
+// fakeChain counts the twins fetched from the chain
+type fakeChain struct {
+	m       sync.Mutex
+	fetched map[uint32]int
+	// key is the first byte of the twins account key
+	key byte
+}
+
+func (f *fakeChain) getTwin(id uint32) (substrate.Twin, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if id == 0 {
+		return substrate.Twin{}, fmt.Errorf("twin not found")
+	}
+
+	f.fetched[id]++
+	return substrate.Twin{ID: types.U32(id), Account: substrate.AccountID{f.key}}, nil
+}
+
+func testGateway(chain *fakeChain, opts ...Option) *substrateGateway {
+	gw := &substrateGateway{getTwin: chain.getTwin}
+	for _, opt := range append([]Option{WithTwinTTL(DefaultTwinTTL)}, opts...) {
+		opt(gw)
+	}
+	return gw
+}
+
+func TestGetTwinCached(t *testing.T) {
+	chain := &fakeChain{fetched: map[uint32]int{}, key: 1}
+	gw := testGateway(chain)
+
+	for i := 0; i < 3; i++ {
+		twin, err := gw.GetTwin(1)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, twin.Account[0])
+	}
+	require.Equal(t, 1, chain.fetched[1])
+
+	_, err := gw.GetTwin(2)
+	require.NoError(t, err)
+	require.Equal(t, 1, chain.fetched[2])
+}
+
+func TestGetTwinInvalidate(t *testing.T) {
+	chain := &fakeChain{fetched: map[uint32]int{}, key: 1}
+	gw := testGateway(chain)
+
+	_, err := gw.GetTwin(1)
+	require.NoError(t, err)
+
+	// the twin has rotated its key, it's only seen once invalidated
+	chain.key = 2
+	twin, err := gw.GetTwin(1)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, twin.Account[0])
+
+	gw.InvalidateTwin(1)
+	twin, err = gw.GetTwin(1)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, twin.Account[0])
+	require.Equal(t, 2, chain.fetched[1])
+}
+
+func TestGetTwinExpired(t *testing.T) {
+	chain := &fakeChain{fetched: map[uint32]int{}}
+	gw := testGateway(chain, WithTwinTTL(10*time.Millisecond))
+
+	_, err := gw.GetTwin(1)
+	require.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = gw.GetTwin(1)
+	require.NoError(t, err)
+	require.Equal(t, 2, chain.fetched[1])
+}
+
+func TestGetTwinNoCache(t *testing.T) {
+	chain := &fakeChain{fetched: map[uint32]int{}}
+	gw := testGateway(chain, WithTwinTTL(0))
+
+	_, err := gw.GetTwin(1)
+	require.NoError(t, err)
+	_, err = gw.GetTwin(1)
+	require.NoError(t, err)
+	require.Equal(t, 2, chain.fetched[1])
+
+	// no-op without a cache
+	gw.InvalidateTwin(1)
+}
+
+func TestGetTwinErrorNotCached(t *testing.T) {
+	chain := &fakeChain{fetched: map[uint32]int{}}
+	gw := testGateway(chain)
+
+	_, err := gw.GetTwin(0)
+	require.Error(t, err)
+
+	_, ok := gw.twins.Get("0")
+	require.False(t, ok)
+}
+
+func TestGetTwinConcurrent(t *testing.T) {
+	chain := &fakeChain{fetched: map[uint32]int{}}
+	gw := testGateway(chain)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id uint32) {
+			defer wg.Done()
+			_, err := gw.GetTwin(id)
+			require.NoError(t, err)
+			gw.InvalidateTwin(id)
+		}(uint32(i%3 + 1))
+	}
+	wg.Wait()
+}
@@ -26,6 +26,10 @@ const (
 
 var (
 	ErrNotBootstrapped = fmt.Errorf("node was not bootstrapped")
+
+	// tagFile is where the boot taglink is stored, it's TagFile
+	// except in tests
+	tagFile = TagFile
 )
 
 // BootMethod defines the node boot method
@@ -60,7 +64,7 @@ func (b Boot) DetectBootMethod() BootMethod {
 		return BootMethodBootstrap
 	}
 
-	if _, err := os.Stat(TagFile); err != nil {
+	if _, err := os.Stat(tagFile); err != nil {
 		return BootMethodOther
 	}
 
@@ -101,7 +105,7 @@ func (b *Boot) Version() semver.Version {
 
 // Current returns current flist information
 func (b *Boot) Current() (flist hub.TagLink, err error) {
-	f, err := os.Open(TagFile)
+	f, err := os.Open(tagFile)
 	if os.IsNotExist(err) {
 		return flist, ErrNotBootstrapped
 	} else if err != nil {
@@ -125,7 +129,7 @@ func (b *Boot) Current() (flist hub.TagLink, err error) {
 
 // Set updates the stored flist info
 func (b *Boot) Set(c hub.TagLink) error {
-	f, err := os.Create(TagFile)
+	f, err := os.Create(tagFile)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,88 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+var (
+	// ErrUpgradeHeld is returned by update if an update is available
+	// but upgrades are held by the operator
+	ErrUpgradeHeld = fmt.Errorf("upgrade held by operator")
+)
+
+const holdFile = "hold.json"
+
+func (u *Upgrader) holdPath() string {
+	return filepath.Join(u.root, holdFile)
+}
+
+// Hold stops the upgrader from applying any update until released.
+// The hold is persisted so it survives restarts of the upgrader.
+func (u *Upgrader) Hold(reason string) error {
+	u.holdLock.Lock()
+	defer u.holdLock.Unlock()
+
+	hold := pkg.UpgradeHold{
+		Held:   true,
+		Reason: reason,
+		Since:  time.Now(),
+	}
+
+	data, err := json.Marshal(hold)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode upgrade hold")
+	}
+
+	tmp := u.holdPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to write upgrade hold")
+	}
+
+	if err := os.Rename(tmp, u.holdPath()); err != nil {
+		return errors.Wrap(err, "failed to write upgrade hold")
+	}
+
+	log.Warn().Str("reason", reason).Msg("upgrades are now HELD by operator")
+	return nil
+}
+
+// Release clears the upgrade hold
+func (u *Upgrader) Release() error {
+	u.holdLock.Lock()
+	defer u.holdLock.Unlock()
+
+	if err := os.Remove(u.holdPath()); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to clear upgrade hold")
+	}
+
+	log.Info().Msg("upgrade hold released by operator")
+	return nil
+}
+
+// HoldState returns the current upgrade hold
+func (u *Upgrader) HoldState() (pkg.UpgradeHold, error) {
+	u.holdLock.Lock()
+	defer u.holdLock.Unlock()
+
+	var hold pkg.UpgradeHold
+	data, err := os.ReadFile(u.holdPath())
+	if os.IsNotExist(err) {
+		return hold, nil
+	} else if err != nil {
+		return hold, errors.Wrap(err, "failed to read upgrade hold")
+	}
+
+	if err := json.Unmarshal(data, &hold); err != nil {
+		return hold, errors.Wrap(err, "failed to decode upgrade hold")
+	}
+
+	return hold, nil
+}
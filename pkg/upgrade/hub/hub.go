@@ -1,6 +1,8 @@
 package hub
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -23,6 +25,13 @@ const (
 	defaultHubCallTimeout = 20 * time.Second
 )
 
+var (
+	// ErrHashMismatch is returned if an flist doesn't match its expected hash
+	ErrHashMismatch = fmt.Errorf("flist hash mismatch")
+	// ErrNotFound is returned if the hub has no such repo or tag
+	ErrNotFound = fmt.Errorf("not found")
+)
+
 type FListType string
 
 const (
@@ -63,6 +72,8 @@ func MatchType(typ FListType) FListFilter {
 // HubClient API for f-list
 type HubClient struct {
 	httpClient *retryablehttp.Client
+	// baseURL overrides the hub url from the environment
+	baseURL string
 }
 
 // NewHubClient create new hub client with the passed option for the http client
@@ -97,6 +108,10 @@ func (h *HubClient) StorageURL() string {
 
 // StorageURL return hub storage url
 func (h *HubClient) HubBaseURL() string {
+	if h.baseURL != "" {
+		return h.baseURL
+	}
+
 	env := environment.MustGet()
 	if kernel.GetParams().IsV4() {
 		return env.V4HubURL
@@ -202,7 +217,9 @@ func (h *HubClient) ListTag(repo, tag string) ([]Symlink, error) {
 		_, _ = io.ReadAll(response.Body)
 	}()
 
-	if response.StatusCode != http.StatusOK {
+	if response.StatusCode == http.StatusNotFound {
+		return nil, errors.Wrapf(ErrNotFound, "tag '%s' in '%s'", tag, repo)
+	} else if response.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("failed to get repository listing: %s", response.Status)
 	}
 
@@ -216,7 +233,8 @@ func (h *HubClient) ListTag(repo, tag string) ([]Symlink, error) {
 
 // Download downloads an flist  to cache and return the full
 // path to the extraced meta data directory. the returned path is in format
-// {cache}/{hash}/
+// {cache}/{hash}.d/. The flist must match its md5 hash on the hub, a cached
+// flist is checked again and downloaded again if it does not match.
 func (h *HubClient) Download(cache, repo, name string) (string, error) {
 	log := log.With().Str("cache", cache).Str("repo", repo).Str("name", name).Logger()
 
@@ -235,18 +253,25 @@ func (h *HubClient) Download(cache, repo, name string) (string, error) {
 		dbFileName = "flistdb.sqlite3"
 	)
 
-	// check if already downloaded
+	// the flist archive is kept next to its extracted meta data, so a cached
+	// flist can be verified again
 	downloaded := filepath.Join(cache, info.Hash)
 	extracted := fmt.Sprintf("%s.d", downloaded)
 
-	if stat, err := os.Stat(filepath.Join(extracted, dbFileName)); err == nil {
-		// already exists.
-		if stat.Size() > 0 {
+	if stat, err := os.Stat(filepath.Join(extracted, dbFileName)); err == nil && stat.Size() > 0 {
+		err := verifyFile(downloaded, repo, name, info.Hash)
+		if err == nil {
 			log.Info().Msg("already cached")
 			return extracted, nil
 		}
+
+		log.Warn().Err(err).Msg("cached flist is corrupted, downloading again")
 	}
 
+	// never keep a corrupted flist in the cache
+	_ = os.RemoveAll(extracted)
+	_ = os.Remove(downloaded)
+
 	u, err := url.Parse(h.HubBaseURL())
 	if err != nil {
 		panic("invalid base url")
@@ -265,7 +290,84 @@ func (h *HubClient) Download(cache, repo, name string) (string, error) {
 		return "", fmt.Errorf("failed to download flist: %s", response.Status)
 	}
 
-	return extracted, meta.Unpack(response.Body, extracted)
+	partial := downloaded + ".part"
+	defer os.Remove(partial)
+
+	if err := download(response.Body, partial, repo, name, info.Hash); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(partial, downloaded); err != nil {
+		return "", errors.Wrap(err, "failed to cache flist")
+	}
+
+	archive, err := os.Open(downloaded)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open flist")
+	}
+	defer archive.Close()
+
+	if err := meta.Unpack(archive, extracted); err != nil {
+		_ = os.RemoveAll(extracted)
+		_ = os.Remove(downloaded)
+		return "", err
+	}
+
+	return extracted, nil
+}
+
+// download writes the flist archive to path, and checks it has the expected
+// md5 hash
+func download(body io.Reader, path, repo, name, expected string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to create flist file")
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), body); err != nil {
+		return errors.Wrap(err, "failed to download flist")
+	}
+
+	return verifyHash(repo, name, expected, hex.EncodeToString(hash.Sum(nil)))
+}
+
+// verifyFile checks the cached flist archive at path has the expected md5
+// hash
+func verifyFile(path, repo, name, expected string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open cached flist")
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return errors.Wrap(err, "failed to read cached flist")
+	}
+
+	return verifyHash(repo, name, expected, hex.EncodeToString(hash.Sum(nil)))
+}
+
+// VerifyFlist makes sure the flist repo/name on the hub has the expected md5 hash.
+// The flist content is then checked against this hash by Download. This only
+// protects against corrupted downloads or cache, not against a tampered hub.
+func (h *HubClient) VerifyFlist(repo, name, expected string) error {
+	info, err := h.Info(repo, name)
+	if err != nil {
+		return err
+	}
+
+	return verifyHash(repo, name, expected, info.Hash)
+}
+
+func verifyHash(repo, name, expected, actual string) error {
+	if !strings.EqualFold(expected, actual) {
+		return errors.Wrapf(ErrHashMismatch, "flist '%s/%s' has hash '%s' expected '%s'", repo, name, actual, expected)
+	}
+
+	return nil
 }
 
 // FList is information of flist as returned by repo list operation
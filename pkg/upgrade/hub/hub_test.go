@@ -1,8 +1,19 @@
 package hub
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/hashicorp/go-retryablehttp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -38,3 +49,108 @@ func TestHub(t *testing.T) {
 	require.NoError(t, err)
 	require.NotEmpty(t, files)
 }
+
+func testArchive(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+
+	content := []byte("flist db")
+	require.NoError(t, tw.WriteHeader(&tar.Header{
+		Name: "flistdb.sqlite3",
+		Mode: 0644,
+		Size: int64(len(content)),
+	}))
+	_, err := tw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+// testHub serves a single flist repo/name.flist with the given archive, and
+// reports hash as its md5
+func testHub(t *testing.T, archive []byte, hash string) *HubClient {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/flist/repo/name.flist/light", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(FList{Name: "name.flist", Type: TypeRegular, Hash: hash})
+	})
+	mux.HandleFunc("/repo/name.flist", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return &HubClient{httpClient: retryablehttp.NewClient(), baseURL: server.URL}
+}
+
+func md5sum(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestVerifyFlist(t *testing.T) {
+	archive := testArchive(t)
+	hub := testHub(t, archive, md5sum(archive))
+
+	require.NoError(t, hub.VerifyFlist("repo", "name.flist", md5sum(archive)))
+
+	err := hub.VerifyFlist("repo", "name.flist", md5sum([]byte("other")))
+	require.ErrorIs(t, err, ErrHashMismatch)
+}
+
+func TestDownloadVerified(t *testing.T) {
+	archive := testArchive(t)
+	hub := testHub(t, archive, md5sum(archive))
+
+	cache := t.TempDir()
+	path, err := hub.Download(cache, "repo", "name.flist")
+	require.NoError(t, err)
+
+	db, err := os.ReadFile(filepath.Join(path, "flistdb.sqlite3"))
+	require.NoError(t, err)
+	require.Equal(t, "flist db", string(db))
+}
+
+func TestDownloadCorrupted(t *testing.T) {
+	archive := testArchive(t)
+	// the hub reports the hash of another flist
+	hub := testHub(t, archive, md5sum([]byte("other")))
+
+	cache := t.TempDir()
+	_, err := hub.Download(cache, "repo", "name.flist")
+	require.ErrorIs(t, err, ErrHashMismatch)
+
+	// nothing is kept in the cache
+	entries, err := os.ReadDir(cache)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestDownloadCachedCorrupted(t *testing.T) {
+	archive := testArchive(t)
+	hub := testHub(t, archive, md5sum(archive))
+
+	cache := t.TempDir()
+	path, err := hub.Download(cache, "repo", "name.flist")
+	require.NoError(t, err)
+
+	// the cached flist is verified again, and downloaded again once it's
+	// corrupted
+	downloaded := filepath.Join(cache, md5sum(archive))
+	require.NoError(t, os.WriteFile(downloaded, []byte("corrupted"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "flistdb.sqlite3"), []byte("corrupted"), 0644))
+
+	path, err = hub.Download(cache, "repo", "name.flist")
+	require.NoError(t, err)
+
+	db, err := os.ReadFile(filepath.Join(path, "flistdb.sqlite3"))
+	require.NoError(t, err)
+	require.Equal(t, "flist db", string(db))
+
+	cached, err := os.ReadFile(downloaded)
+	require.NoError(t, err)
+	require.Equal(t, archive, cached)
+}
@@ -0,0 +1,40 @@
+package upgrade
+
+import (
+	"time"
+
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+// GetUpgradeStatus returns the progress of the upgrader
+func (u *Upgrader) GetUpgradeStatus() pkg.UpgradeStatus {
+	u.statusLock.Lock()
+	defer u.statusLock.Unlock()
+
+	if u.status.Phase == "" {
+		return pkg.UpgradeStatus{Phase: pkg.UpgradePhaseIdle}
+	}
+
+	return u.status
+}
+
+func (u *Upgrader) setStatus(phase pkg.UpgradePhase, target, name string) {
+	u.statusLock.Lock()
+	defer u.statusLock.Unlock()
+
+	u.status = pkg.UpgradeStatus{
+		Phase:   phase,
+		Target:  target,
+		Package: name,
+		Since:   time.Now(),
+	}
+}
+
+// setPackage sets the package being installed, the target is kept
+func (u *Upgrader) setPackage(name string) {
+	u.statusLock.Lock()
+	target := u.status.Target
+	u.statusLock.Unlock()
+
+	u.setStatus(pkg.UpgradePhaseInstalling, target, name)
+}
@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -21,6 +22,7 @@ import (
 	"github.com/threefoldtech/0-fs/rofs"
 	"github.com/threefoldtech/0-fs/storage"
 	"github.com/threefoldtech/zbus"
+	"github.com/threefoldtech/zosbase/pkg"
 	"github.com/threefoldtech/zosbase/pkg/app"
 	"github.com/threefoldtech/zosbase/pkg/environment"
 	"github.com/threefoldtech/zosbase/pkg/kernel"
@@ -35,6 +37,13 @@ var (
 	// ErrRestartNeeded is returned if upgraded requires a restart
 	ErrRestartNeeded = fmt.Errorf("restart needed")
 
+	// ErrPinnedVersionNotFound is returned if the version the node is
+	// pinned to is not on the hub
+	ErrPinnedVersionNotFound = fmt.Errorf("pinned version not found")
+
+	// kernelParams returns the node kernel params
+	kernelParams = kernel.GetParams
+
 	// services that can't be uninstalled with normal procedure
 	protected = []string{"identityd", "redis"}
 )
@@ -48,6 +57,8 @@ const (
 	checkJitter         = 10 // minutes
 	defaultHubTimeout   = 20 * time.Second
 
+	defaultDownloadConcurrency = 4
+
 	ZosRepo    = "tf-zos"
 	ZosPackage = "zos.flist"
 )
@@ -83,6 +94,14 @@ func getRolloutConfig(ctx context.Context, gw *stubs.SubstrateGatewayStub) (Chai
 	return chainVersion, config.RolloutUpgrade.TestFarms, nil
 }
 
+// hubClient is the subset of the hub client used by the upgrader
+type hubClient interface {
+	Find(repo string, filter ...hub.FListFilter) ([]hub.FList, error)
+	ListTag(repo, tag string) ([]hub.Symlink, error)
+	Download(cache, repo, name string) (string, error)
+	VerifyFlist(repo, name, expected string) error
+}
+
 // Upgrader is the component that is responsible
 // to keep 0-OS up to date
 type Upgrader struct {
@@ -91,8 +110,17 @@ type Upgrader struct {
 	zcl          zbus.Client
 	root         string
 	noZosUpgrade bool
-	hub          *hub.HubClient
+	hub          hubClient
 	storage      storage.Storage
+	// installer installs a single package, defaults to install
+	installer func(repo, name, hash string) error
+	// downloadConcurrency is the max number of packages downloaded at the same time
+	downloadConcurrency int
+
+	holdLock sync.Mutex
+
+	statusLock sync.Mutex
+	status     pkg.UpgradeStatus
 }
 
 // UpgraderOption interface
@@ -109,6 +137,19 @@ func NoZosUpgrade(o bool) UpgraderOption {
 	}
 }
 
+// WithDownloadConcurrency option sets the max number of packages
+// downloaded at the same time during an update
+func WithDownloadConcurrency(n int) UpgraderOption {
+	return func(u *Upgrader) error {
+		if n <= 0 {
+			return fmt.Errorf("download concurrency must be positive")
+		}
+		u.downloadConcurrency = n
+
+		return nil
+	}
+}
+
 // ZbusClient option, adds a zbus client to the upgrader
 func ZbusClient(cl zbus.Client) UpgraderOption {
 	return func(u *Upgrader) error {
@@ -191,7 +232,11 @@ func (u *Upgrader) Run(ctx context.Context) error {
 		log.Info().Msg("system is not booted from the hub")
 		if app.IsFirstBoot(service) {
 			remote, err := u.remote()
-			if err != nil {
+			if errors.Is(err, ErrPinnedVersionNotFound) {
+				// remote is still the run mode taglink, the binaries
+				// are needed even if the pin can't be honored
+				log.Error().Err(err).Str("version", remote.Target).Msg("installing the run mode version instead")
+			} else if err != nil {
 				return errors.Wrap(err, "failed to get remote tag")
 			}
 
@@ -216,6 +261,8 @@ func (u *Upgrader) Run(ctx context.Context) error {
 		err := u.update(ctx)
 		if errors.Is(err, ErrRestartNeeded) {
 			return err
+		} else if errors.Is(err, ErrUpgradeHeld) {
+			// already logged by update, just wait for the next check
 		} else if err != nil {
 			log.Error().Err(err).Msg("failed while checking for updates")
 			<-time.After(10 * time.Second)
@@ -246,13 +293,51 @@ func (u *Upgrader) nextUpdate() time.Duration {
 }
 
 // remote finds the `tag link` associated with the node network (for example devnet)
+// or the version the node is pinned to with the zos-version kernel param
 func (u *Upgrader) remote() (remote hub.TagLink, err error) {
 	mode := u.boot.RunMode()
 	// find all taglinks that matches the same run mode (ex: development)
 	matchName := mode.String()
-	if kernel.GetParams().IsLight() {
-		matchName = fmt.Sprintf("%s-%s", mode.String(), kernel.GetParams().GetVersion())
+	if kernelParams().IsLight() {
+		matchName = fmt.Sprintf("%s-%s", mode.String(), kernelParams().GetVersion())
+	}
+
+	remote, err = u.taglink(matchName)
+	if err != nil {
+		return remote, err
+	}
+
+	if version, ok := kernelParams().PinnedVersion(); ok {
+		return u.pinned(remote, version)
+	}
+
+	return remote, nil
+}
+
+// pinned returns a taglink to the given version (tag) in the same
+// repo as the run mode taglink
+func (u *Upgrader) pinned(remote hub.TagLink, version string) (hub.TagLink, error) {
+	repo, _, err := remote.Destination()
+	if err != nil {
+		return remote, err
+	}
+
+	packages, err := u.hub.ListTag(repo, version)
+	if errors.Is(err, hub.ErrNotFound) {
+		return remote, errors.Wrapf(ErrPinnedVersionNotFound, "tag '%s' in '%s' does not exist", version, repo)
+	} else if err != nil {
+		// the hub is not reachable, the pin is checked again on retry
+		return remote, errors.Wrapf(err, "failed to list tag '%s' in '%s'", version, repo)
+	} else if len(packages) == 0 {
+		return remote, errors.Wrapf(ErrPinnedVersionNotFound, "tag '%s' in '%s' is empty", version, repo)
 	}
+
+	remote.Target = fmt.Sprintf("%s/tags/%s", repo, version)
+	return remote, nil
+}
+
+// taglink finds the taglink with the given name
+func (u *Upgrader) taglink(matchName string) (remote hub.TagLink, err error) {
 	matches, err := u.hub.Find(
 		ZosRepo,
 		hub.MatchName(matchName),
@@ -269,7 +354,16 @@ func (u *Upgrader) remote() (remote hub.TagLink, err error) {
 	return hub.NewTagLink(matches[0]), nil
 }
 
-func (u *Upgrader) update(ctx context.Context) error {
+func (u *Upgrader) update(ctx context.Context) (err error) {
+	u.setStatus(pkg.UpgradePhaseChecking, "", "")
+	defer func() {
+		if errors.Is(err, ErrRestartNeeded) {
+			u.setStatus(pkg.UpgradePhaseRestarting, u.GetUpgradeStatus().Target, "")
+		} else {
+			u.setStatus(pkg.UpgradePhaseIdle, "", "")
+		}
+	}()
+
 	// here we need to do a normal full update cycle
 	current, err := u.boot.Current()
 	if err != nil {
@@ -277,7 +371,11 @@ func (u *Upgrader) update(ctx context.Context) error {
 	}
 
 	remote, err := u.remote()
-	if err != nil {
+	if errors.Is(err, ErrPinnedVersionNotFound) {
+		// don't retry until the next check, the version won't show up soon
+		log.Error().Err(err).Str("running version", u.Version().String()).Msg("keeping current version")
+		return nil
+	} else if err != nil {
 		return errors.Wrap(err, "failed to get remote tag")
 	}
 
@@ -288,23 +386,72 @@ func (u *Upgrader) update(ctx context.Context) error {
 		return nil
 	}
 
+	_, pinned := kernelParams().PinnedVersion()
+	if !pinned {
+		// the chain version is only followed if the node is not pinned
+		ok, err := u.rolloutAllowed(ctx, remote)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return nil
+		}
+	}
+
+	hold, err := u.HoldState()
+	if err != nil {
+		return errors.Wrap(err, "failed to check upgrade hold")
+	}
+
+	if hold.Held {
+		log.Warn().
+			Str("running version", u.Version().String()).
+			Str("available version", filepath.Base(remote.Target)).
+			Str("reason", hold.Reason).
+			Time("since", hold.Since).
+			Msg("UPGRADE HELD BY OPERATOR, skipping update until hold is released")
+		return ErrUpgradeHeld
+	}
+
+	log.Info().Str("running version", u.Version().String()).Str("updating to version", filepath.Base(remote.Target)).Msg("updating system...")
+	if err := u.upgradeTo(remote, current); err != nil {
+		return err
+	}
+
+	if err := u.boot.Set(remote); err != nil {
+		return err
+	}
+
+	return ErrRestartNeeded
+}
+
+// rolloutAllowed checks if the node can update to remote, remote must match the
+// version set on the chain, and only nodes in the test farms can update until the
+// version is marked safe to upgrade
+func (u *Upgrader) rolloutAllowed(ctx context.Context, remote hub.TagLink) (bool, error) {
 	env := environment.MustGet()
 	gw := stubs.NewSubstrateGatewayStub(u.zcl)
+	// fail fast instead of spending the retries of the version lookup
+	if err := gw.Healthz(ctx); err != nil {
+		return false, errors.Wrap(err, "chain is not reachable")
+	}
+
 	chainVer, testFarms, err := getRolloutConfig(ctx, gw)
 	if err != nil {
-		return errors.Wrap(err, "failed to get rollout config and version")
+		return false, errors.Wrap(err, "failed to get rollout config and version")
 	}
 
 	remoteVer := remote.Target[strings.LastIndex(remote.Target, "/")+1:]
-	if kernel.GetParams().IsLight() {
+	if kernelParams().IsLight() {
 		if env.RunningMode != environment.RunningDev && (remoteVer != chainVer.VersionLight) {
 			// nothing to do! hub version is not the same as the chain
-			return nil
+			return false, nil
 		}
 	} else {
 		if env.RunningMode != environment.RunningDev && (remoteVer != chainVer.Version) {
 			// nothing to do! hub version is not the same as the chain
-			return nil
+			return false, nil
 		}
 	}
 
@@ -312,20 +459,37 @@ func (u *Upgrader) update(ctx context.Context) error {
 		if !slices.Contains(testFarms, uint32(env.FarmID)) {
 			// nothing to do! waiting for the flag `safe to upgrade to be enabled after A/B testing`
 			// node is not a part of A/B testing
-			return nil
+			return false, nil
 		}
 	}
 
-	log.Info().Str("running version", u.Version().String()).Str("updating to version", filepath.Base(remote.Target)).Msg("updating system...")
-	if err := u.updateTo(remote, &current); err != nil {
-		return errors.Wrapf(err, "failed to update to new tag '%s'", remote.Target)
+	return true, nil
+}
+
+// upgradeTo updates the system from current to link. If the update fails the
+// packages of current are installed again, so the node is not left with a mix
+// of both versions, and keeps booting current.
+func (u *Upgrader) upgradeTo(link hub.TagLink, current hub.TagLink) error {
+	err := u.updateTo(link, &current)
+	if err == nil {
+		return nil
 	}
 
-	if err := u.boot.Set(remote); err != nil {
+	err = errors.Wrapf(err, "failed to update to new tag '%s'", link.Target)
+	if current.Target == "" {
+		// we don't know what is running, nothing to roll back to
 		return err
 	}
 
-	return ErrRestartNeeded
+	log.Error().Err(err).Str("version", filepath.Base(current.Target)).Msg("update failed, rolling back")
+	// only the packages that were changed by the update are installed again
+	if rollbackErr := safe(func() error {
+		return u.updateTo(current, &link)
+	}); rollbackErr != nil {
+		return errors.Wrapf(err, "failed to roll back to tag '%s': %s", current.Target, rollbackErr)
+	}
+
+	return err
 }
 
 // updateTo updates flist packages to match "link"
@@ -361,7 +525,7 @@ func (u *Upgrader) updateTo(link hub.TagLink, current *hub.TagLink) error {
 		}
 	}
 
-	var later [][]string
+	var now, later [][]string
 	for _, pkg := range packages {
 		pkgRepo, name, err := pkg.Destination(repo)
 		// if the new pkg is the same as the current pkg no need to reinstall it
@@ -372,7 +536,7 @@ func (u *Upgrader) updateTo(link hub.TagLink, current *hub.TagLink) error {
 		if pkg.Name == ZosPackage {
 			// this is the last to do to make sure all dependencies are installed before updating zos
 			log.Debug().Str("repo", pkgRepo).Str("name", name).Msg("schedule package for later")
-			later = append(later, []string{pkgRepo, name})
+			later = append(later, []string{pkgRepo, name, pkg.Hash})
 			continue
 		}
 
@@ -380,9 +544,20 @@ func (u *Upgrader) updateTo(link hub.TagLink, current *hub.TagLink) error {
 			return errors.Wrapf(err, "failed to find target for package '%s'", pkg.Target)
 		}
 
-		// install package
-		if err := u.install(pkgRepo, name); err != nil {
-			return errors.Wrapf(err, "failed to install package %s/%s", pkgRepo, name)
+		now = append(now, []string{pkgRepo, name, pkg.Hash})
+	}
+
+	// download all packages first, so nothing is installed if
+	// one of them can't be downloaded
+	u.setStatus(pkg.UpgradePhaseDownloading, link.Target, "")
+	if err := u.download(now); err != nil {
+		return err
+	}
+
+	for _, pkg := range now {
+		repo, name, hash := pkg[0], pkg[1], pkg[2]
+		if err := u.installPackage(repo, name, hash); err != nil {
+			return errors.Wrapf(err, "failed to install package %s/%s", repo, name)
 		}
 	}
 
@@ -392,8 +567,8 @@ func (u *Upgrader) updateTo(link hub.TagLink, current *hub.TagLink) error {
 
 	// probably check flag for zos installation
 	for _, pkg := range later {
-		repo, name := pkg[0], pkg[1]
-		if err := u.install(repo, name); err != nil {
+		repo, name, hash := pkg[0], pkg[1], pkg[2]
+		if err := u.installPackage(repo, name, hash); err != nil {
 			return errors.Wrapf(err, "failed to install package %s/%s", repo, name)
 		}
 	}
@@ -401,6 +576,54 @@ func (u *Upgrader) updateTo(link hub.TagLink, current *hub.TagLink) error {
 	return nil
 }
 
+// download downloads the packages flists to the cache concurrently, so they
+// are not downloaded one by one by install. Packages that can't be written
+// to the cache are skipped, install downloads them in memory instead.
+func (u *Upgrader) download(packages [][]string) error {
+	concurrency := u.downloadConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultDownloadConcurrency
+	}
+
+	var (
+		wg   sync.WaitGroup
+		m    sync.Mutex
+		errs []error
+		pool = make(chan struct{}, concurrency)
+	)
+
+	for _, pkg := range packages {
+		repo, name, hash := pkg[0], pkg[1], pkg[2]
+
+		wg.Add(1)
+		pool <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-pool }()
+
+			_, err := u.downloadFlist(repo, name, hash, u)
+			if errors.Is(err, syscall.EROFS) ||
+				errors.Is(err, syscall.EPERM) ||
+				errors.Is(err, syscall.EIO) {
+				log.Warn().Err(err).Str("repo", repo).Str("name", name).Msg("can't download package to cache")
+				return
+			} else if err != nil {
+				m.Lock()
+				defer m.Unlock()
+				errs = append(errs, errors.Wrapf(err, "failed to download package %s/%s", repo, name))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
 func (u *Upgrader) flistCache() string {
 	return filepath.Join(u.root, "cache", "flist")
 }
@@ -409,9 +632,22 @@ func (u *Upgrader) fileCache() string {
 	return filepath.Join(u.root, "cache", "files")
 }
 
-// getFlist accepts fqdn of flist as `<repo>/<name>.flist`
-func (u *Upgrader) getFlist(repo, name string, cache cache) (meta.Walker, error) {
-	db, err := u.hub.Download(cache.flistCache(), repo, name)
+// downloadFlist downloads the flist to the cache, if hash is set the flist
+// must match it.
+func (u *Upgrader) downloadFlist(repo, name, hash string, cache cache) (string, error) {
+	if hash != "" {
+		if err := u.hub.VerifyFlist(repo, name, hash); err != nil {
+			return "", err
+		}
+	}
+
+	return u.hub.Download(cache.flistCache(), repo, name)
+}
+
+// getFlist accepts fqdn of flist as `<repo>/<name>.flist`, if hash is set
+// the flist must match it.
+func (u *Upgrader) getFlist(repo, name, hash string, cache cache) (meta.Walker, error) {
+	db, err := u.downloadFlist(repo, name, hash, cache)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to download flist")
 	}
@@ -466,11 +702,21 @@ func (c *inMemoryCache) clean() {
 	os.RemoveAll(c.root)
 }
 
-// install from a single flist.
-func (u *Upgrader) install(repo, name string) error {
+func (u *Upgrader) installPackage(repo, name, hash string) error {
+	u.setPackage(name)
+	if u.installer != nil {
+		return u.installer(repo, name, hash)
+	}
+
+	return u.install(repo, name, hash)
+}
+
+// install from a single flist. The flist is verified against hash
+// if it's set, before anything is installed.
+func (u *Upgrader) install(repo, name, hash string) error {
 	log.Info().Str("repo", repo).Str("name", name).Msg("start installing package")
 	var cache cache = u
-	store, err := u.getFlist(repo, name, cache)
+	store, err := u.getFlist(repo, name, hash, cache)
 
 	if errors.Is(err, syscall.EROFS) ||
 		errors.Is(err, syscall.EPERM) ||
@@ -484,7 +730,7 @@ func (u *Upgrader) install(repo, name string) error {
 		cache = inMemoryCache
 
 		log.Info().Msg("downloading in memory")
-		store, err = u.getFlist(repo, name, cache)
+		store, err = u.getFlist(repo, name, hash, cache)
 		if err != nil {
 			return errors.Wrapf(err, "failed to process flist: %s/%s", repo, name)
 		}
@@ -1,13 +1,21 @@
 package upgrade
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/threefoldtech/0-fs/meta"
+	"github.com/threefoldtech/zosbase/pkg"
 	"github.com/threefoldtech/zosbase/pkg/environment"
+	"github.com/threefoldtech/zosbase/pkg/kernel"
 	"github.com/threefoldtech/zosbase/pkg/upgrade/hub"
 )
 
@@ -27,7 +35,7 @@ func TestUpgraderDownload(t *testing.T) {
 	const repo = "azmy.3bot"
 	const flist = "test-flist.flist"
 
-	store, err := up.getFlist(repo, flist, up)
+	store, err := up.getFlist(repo, flist, "", up)
 	require.NoError(err)
 	tmp := t.TempDir()
 
@@ -56,3 +64,449 @@ func TestUpgraderDownload(t *testing.T) {
 
 	require.NoError(err)
 }
+
+func TestUpgraderHold(t *testing.T) {
+	require := require.New(t)
+
+	up := &Upgrader{
+		root: t.TempDir(),
+	}
+
+	hold, err := up.HoldState()
+	require.NoError(err)
+	require.False(hold.Held)
+
+	err = up.Hold("investigating release")
+	require.NoError(err)
+
+	hold, err = up.HoldState()
+	require.NoError(err)
+	require.True(hold.Held)
+	require.Equal("investigating release", hold.Reason)
+
+	// a new instance on the same root must see the hold
+	other := &Upgrader{root: up.root}
+	hold, err = other.HoldState()
+	require.NoError(err)
+	require.True(hold.Held)
+
+	err = up.Release()
+	require.NoError(err)
+
+	hold, err = up.HoldState()
+	require.NoError(err)
+	require.False(hold.Held)
+
+	// releasing again is not an error
+	require.NoError(up.Release())
+}
+
+type fakeHub struct {
+	hubClient
+	tags      map[string][]hub.Symlink
+	installed []string
+	fail      string
+	// links are the taglinks returned by Find
+	links []hub.FList
+	// listErr is returned by ListTag if set
+	listErr error
+
+	m          sync.Mutex
+	downloaded []string
+	downloadFn func(name string) error
+	running    int
+	maxRunning int
+}
+
+func (h *fakeHub) Download(cache, repo, name string) (string, error) {
+	h.m.Lock()
+	h.downloaded = append(h.downloaded, name)
+	h.running++
+	h.maxRunning = max(h.maxRunning, h.running)
+	h.m.Unlock()
+
+	// give the other downloads a chance to start
+	time.Sleep(10 * time.Millisecond)
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.running--
+
+	if h.downloadFn != nil {
+		if err := h.downloadFn(name); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(cache, name), nil
+}
+
+func (h *fakeHub) Find(repo string, filter ...hub.FListFilter) ([]hub.FList, error) {
+	return h.links, nil
+}
+
+func (h *fakeHub) ListTag(repo, tag string) ([]hub.Symlink, error) {
+	if h.listErr != nil {
+		return nil, h.listErr
+	}
+	packages, ok := h.tags[tag]
+	if !ok {
+		return nil, fmt.Errorf("tag '%s': %w", tag, hub.ErrNotFound)
+	}
+	return packages, nil
+}
+
+func (h *fakeHub) VerifyFlist(repo, name, expected string) error {
+	if expected != "good" {
+		return hub.ErrHashMismatch
+	}
+	return fmt.Errorf("flist '%s/%s' not found", repo, name)
+}
+
+func (h *fakeHub) install(repo, name, hash string) error {
+	h.installed = append(h.installed, name)
+	if name == h.fail {
+		return fmt.Errorf("failed to install '%s'", name)
+	}
+	return nil
+}
+
+func testTag(version string, extra ...string) []hub.Symlink {
+	var packages []hub.Symlink
+	for _, name := range append([]string{"zos", "network", "storage"}, extra...) {
+		packages = append(packages, hub.Symlink{FList: hub.FList{
+			Name:   fmt.Sprintf("%s.flist", name),
+			Target: fmt.Sprintf("%s-%s.flist", name, version),
+			Type:   hub.TypeSymlink,
+		}})
+	}
+	// the same package in both tags
+	packages = append(packages, hub.Symlink{FList: hub.FList{
+		Name:   "shared.flist",
+		Target: "shared.flist",
+		Type:   hub.TypeSymlink,
+	}})
+	return packages
+}
+
+func testTagLink(version string) hub.TagLink {
+	return hub.TagLink{FList: hub.FList{
+		Name:   "development",
+		Target: fmt.Sprintf("%s/tags/%s", ZosRepo, version),
+		Type:   hub.TypeTagLink,
+	}}
+}
+
+func TestUpgraderRollback(t *testing.T) {
+	require := require.New(t)
+
+	fake := &fakeHub{
+		tags: map[string][]hub.Symlink{
+			"v1": testTag("v1"),
+			"v2": testTag("v2"),
+		},
+		fail: "storage-v2.flist",
+	}
+	up := &Upgrader{hub: fake, installer: fake.install}
+
+	err := up.upgradeTo(testTagLink("v2"), testTagLink("v1"))
+	require.Error(err)
+	require.Contains(err.Error(), "storage-v2.flist")
+
+	require.Equal([]string{
+		"network-v2.flist",
+		"storage-v2.flist",
+		// rollback, zos is installed last
+		"network-v1.flist",
+		"storage-v1.flist",
+		"zos-v1.flist",
+	}, fake.installed)
+}
+
+func TestUpgraderRollbackZos(t *testing.T) {
+	require := require.New(t)
+
+	fake := &fakeHub{
+		tags: map[string][]hub.Symlink{
+			"v1": testTag("v1"),
+			"v2": testTag("v2"),
+		},
+		fail: "zos-v2.flist",
+	}
+	up := &Upgrader{hub: fake, installer: fake.install}
+
+	err := up.upgradeTo(testTagLink("v2"), testTagLink("v1"))
+	require.Error(err)
+	require.Contains(err.Error(), "zos-v2.flist")
+	require.Equal([]string{
+		"network-v2.flist",
+		"storage-v2.flist",
+		"zos-v2.flist",
+		"network-v1.flist",
+		"storage-v1.flist",
+		"zos-v1.flist",
+	}, fake.installed)
+}
+
+func TestUpgraderUpdate(t *testing.T) {
+	require := require.New(t)
+
+	fake := &fakeHub{
+		tags: map[string][]hub.Symlink{
+			"v1": testTag("v1"),
+			"v2": testTag("v2"),
+		},
+	}
+	up := &Upgrader{hub: fake, installer: fake.install}
+
+	err := up.upgradeTo(testTagLink("v2"), testTagLink("v1"))
+	require.NoError(err)
+	require.Equal([]string{
+		"network-v2.flist",
+		"storage-v2.flist",
+		"zos-v2.flist",
+	}, fake.installed)
+}
+
+func TestUpgraderVerifyFlist(t *testing.T) {
+	require := require.New(t)
+
+	up := &Upgrader{hub: &fakeHub{}}
+
+	// the flist is never downloaded if it doesn't match
+	_, err := up.getFlist("repo", "name.flist", "bad", up)
+	require.ErrorIs(err, hub.ErrHashMismatch)
+
+	_, err = up.getFlist("repo", "name.flist", "good", up)
+	require.ErrorContains(err, "not found")
+}
+
+func TestUpgraderDownloadConcurrency(t *testing.T) {
+	require := require.New(t)
+
+	fake := &fakeHub{
+		tags: map[string][]hub.Symlink{
+			"v1": testTag("v1"),
+			"v2": testTag("v2", "vmd", "flistd", "provisiond", "noded"),
+		},
+	}
+	up := &Upgrader{hub: fake, installer: fake.install}
+	require.NoError(WithDownloadConcurrency(2)(up))
+
+	err := up.upgradeTo(testTagLink("v2"), testTagLink("v1"))
+	require.NoError(err)
+
+	sort.Strings(fake.downloaded)
+	require.Equal([]string{
+		"flistd-v2.flist",
+		"network-v2.flist",
+		"noded-v2.flist",
+		"provisiond-v2.flist",
+		"storage-v2.flist",
+		"vmd-v2.flist",
+	}, fake.downloaded)
+	require.Equal(2, fake.maxRunning)
+
+	// packages are installed in order, and zos is last
+	require.Equal([]string{
+		"network-v2.flist",
+		"storage-v2.flist",
+		"vmd-v2.flist",
+		"flistd-v2.flist",
+		"provisiond-v2.flist",
+		"noded-v2.flist",
+		"zos-v2.flist",
+	}, fake.installed)
+}
+
+func TestUpgraderDownloadFailed(t *testing.T) {
+	require := require.New(t)
+
+	fake := &fakeHub{
+		tags: map[string][]hub.Symlink{
+			"v2": testTag("v2"),
+		},
+		downloadFn: func(name string) error {
+			if name == "storage-v2.flist" {
+				return fmt.Errorf("connection reset")
+			}
+			return nil
+		},
+	}
+	up := &Upgrader{hub: fake, installer: fake.install}
+
+	// nothing is installed if a package can't be downloaded
+	err := up.updateTo(testTagLink("v2"), nil)
+	require.ErrorContains(err, "connection reset")
+	require.Empty(fake.installed)
+}
+
+func TestUpgraderDownloadReadOnly(t *testing.T) {
+	require := require.New(t)
+
+	fake := &fakeHub{
+		tags: map[string][]hub.Symlink{
+			"v2": testTag("v2"),
+		},
+		downloadFn: func(name string) error {
+			return syscall.EROFS
+		},
+	}
+	up := &Upgrader{hub: fake, installer: fake.install}
+
+	// the cache is read only, install downloads the packages in memory
+	err := up.updateTo(testTagLink("v2"), nil)
+	require.NoError(err)
+	require.Equal([]string{
+		"network-v2.flist",
+		"storage-v2.flist",
+		"shared.flist",
+		"zos-v2.flist",
+	}, fake.installed)
+}
+
+func TestUpgraderPinned(t *testing.T) {
+	require := require.New(t)
+
+	fake := &fakeHub{
+		tags: map[string][]hub.Symlink{
+			"v1": testTag("v1"),
+			"v2": testTag("v2"),
+		},
+	}
+	up := &Upgrader{hub: fake}
+
+	remote, err := up.pinned(testTagLink("v2"), "v1")
+	require.NoError(err)
+	require.Equal(testTagLink("v1"), remote)
+
+	// the version is not on the hub
+	_, err = up.pinned(testTagLink("v2"), "v3")
+	require.ErrorIs(err, ErrPinnedVersionNotFound)
+
+	// transport errors are not a missing version
+	fake.listErr = fmt.Errorf("connection refused")
+	_, err = up.pinned(testTagLink("v2"), "v3")
+	require.Error(err)
+	require.NotErrorIs(err, ErrPinnedVersionNotFound)
+	fake.listErr = nil
+
+	fake.tags["v3"] = nil
+	_, err = up.pinned(testTagLink("v2"), "v3")
+	require.ErrorIs(err, ErrPinnedVersionNotFound)
+}
+
+// pinnedUpgrader returns an upgrader booted from v1, the run mode taglink
+// points to v2 and the node is pinned to version
+func pinnedUpgrader(t *testing.T, version string) (*Upgrader, *fakeHub) {
+	fake := &fakeHub{
+		tags: map[string][]hub.Symlink{
+			"v1": testTag("v1"),
+			"v2": testTag("v2"),
+			"v3": testTag("v3"),
+		},
+		links: []hub.FList{testTagLink("v2").FList},
+	}
+
+	oldTagFile := tagFile
+	tagFile = filepath.Join(t.TempDir(), "tag.info")
+	oldParams := kernelParams
+	kernelParams = func() kernel.Params {
+		return kernel.Params{kernel.ZosVersion: {version}}
+	}
+	t.Cleanup(func() {
+		tagFile = oldTagFile
+		kernelParams = oldParams
+	})
+
+	up := &Upgrader{hub: fake, installer: fake.install, root: t.TempDir()}
+	require.NoError(t, up.boot.Set(testTagLink("v1")))
+
+	return up, fake
+}
+
+func TestUpgraderUpdatePinned(t *testing.T) {
+	require := require.New(t)
+
+	// the chain version is not checked, the upgrader has no zbus client
+	up, fake := pinnedUpgrader(t, "v3")
+	err := up.update(context.Background())
+	require.ErrorIs(err, ErrRestartNeeded)
+	require.Equal([]string{
+		"network-v3.flist",
+		"storage-v3.flist",
+		"zos-v3.flist",
+	}, fake.installed)
+
+	current, err := up.boot.Current()
+	require.NoError(err)
+	require.Equal(testTagLink("v3").Target, current.Target)
+
+	// already on the pinned version
+	fake.installed = nil
+	require.NoError(up.update(context.Background()))
+	require.Empty(fake.installed)
+}
+
+func TestUpgraderUpdatePinnedMissing(t *testing.T) {
+	require := require.New(t)
+
+	// the current version is kept
+	up, fake := pinnedUpgrader(t, "v9")
+	require.NoError(up.update(context.Background()))
+	require.Empty(fake.installed)
+
+	current, err := up.boot.Current()
+	require.NoError(err)
+	require.Equal(testTagLink("v1").Target, current.Target)
+
+	// the hub is not reachable, the update is retried
+	fake.listErr = fmt.Errorf("connection refused")
+	err = up.update(context.Background())
+	require.Error(err)
+	require.NotErrorIs(err, ErrPinnedVersionNotFound)
+	require.Empty(fake.installed)
+}
+
+func TestUpgraderStatus(t *testing.T) {
+	require := require.New(t)
+
+	fake := &fakeHub{
+		tags: map[string][]hub.Symlink{
+			"v1": testTag("v1"),
+			"v2": testTag("v2"),
+		},
+	}
+	up := &Upgrader{hub: fake}
+	require.Equal(pkg.UpgradePhaseIdle, up.GetUpgradeStatus().Phase)
+
+	var statuses []pkg.UpgradeStatus
+	fake.downloadFn = func(name string) error {
+		statuses = append(statuses, up.GetUpgradeStatus())
+		return nil
+	}
+	up.installer = func(repo, name, hash string) error {
+		statuses = append(statuses, up.GetUpgradeStatus())
+		return nil
+	}
+
+	current := testTagLink("v1")
+	err := up.updateTo(testTagLink("v2"), &current)
+	require.NoError(err)
+
+	var phases []pkg.UpgradePhase
+	var packages []string
+	for _, status := range statuses {
+		require.Equal(testTagLink("v2").Target, status.Target)
+		phases = append(phases, status.Phase)
+		packages = append(packages, status.Package)
+	}
+
+	require.Equal([]pkg.UpgradePhase{
+		pkg.UpgradePhaseDownloading,
+		pkg.UpgradePhaseDownloading,
+		pkg.UpgradePhaseInstalling,
+		pkg.UpgradePhaseInstalling,
+		pkg.UpgradePhaseInstalling,
+	}, phases)
+	require.Equal([]string{"", "", "network-v2.flist", "storage-v2.flist", "zos-v2.flist"}, packages)
+}
@@ -0,0 +1,55 @@
+package pkg
+
+//go:generate zbusc -module identityd -version 0.0.1 -name upgrader -package stubs github.com/threefoldtech/zosbase/pkg+Upgrader stubs/upgrader_stub.go
+
+import "time"
+
+// UpgradeHold is the state of the operator upgrade hold
+type UpgradeHold struct {
+	// Held is true if upgrades are currently held
+	Held bool `json:"held"`
+	// Reason is the operator reason for holding upgrades
+	Reason string `json:"reason,omitempty"`
+	// Since is when the hold was set
+	Since time.Time `json:"since,omitempty"`
+}
+
+// UpgradePhase is the phase of the upgrader
+type UpgradePhase string
+
+const (
+	// UpgradePhaseIdle the upgrader is waiting for the next check
+	UpgradePhaseIdle UpgradePhase = "idle"
+	// UpgradePhaseChecking the upgrader is checking for a new version
+	UpgradePhaseChecking UpgradePhase = "checking"
+	// UpgradePhaseDownloading the packages of the target version are downloaded
+	UpgradePhaseDownloading UpgradePhase = "downloading"
+	// UpgradePhaseInstalling the packages of the target version are installed
+	UpgradePhaseInstalling UpgradePhase = "installing"
+	// UpgradePhaseRestarting the update is done and the node is restarting
+	UpgradePhaseRestarting UpgradePhase = "restarting"
+)
+
+// UpgradeStatus is the progress of the upgrader
+type UpgradeStatus struct {
+	Phase UpgradePhase `json:"phase"`
+	// Target is the tag being installed
+	Target string `json:"target,omitempty"`
+	// Package is the package being installed
+	Package string `json:"package,omitempty"`
+	// Since is when the upgrader entered this phase
+	Since time.Time `json:"since,omitempty"`
+}
+
+// Upgrader interface
+type Upgrader interface {
+	// Hold stops the upgrader from applying any update until released.
+	// The hold is persisted and survives a restart of the upgrader.
+	Hold(reason string) error
+	// Release clears the upgrade hold
+	Release() error
+	// HoldState returns the current upgrade hold
+	HoldState() (UpgradeHold, error)
+	// GetUpgradeStatus returns the progress of the upgrader
+	GetUpgradeStatus() UpgradeStatus
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"path/filepath"
+	"time"
 
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes"
@@ -79,6 +80,29 @@ const (
 	BootVirtioFS
 )
 
+// OOMPriority is a hint of how likely the VM process is to be killed by the
+// OOM killer under memory pressure
+type OOMPriority string
+
+const (
+	// OOMPriorityProtected VMs are killed after other processes (default)
+	OOMPriorityProtected OOMPriority = "protected"
+	// OOMPriorityNormal VMs are treated like any other process
+	OOMPriorityNormal OOMPriority = "normal"
+	// OOMPrioritySacrificial VMs are killed first, for best-effort workloads
+	OOMPrioritySacrificial OOMPriority = "sacrificial"
+)
+
+// Valid checks the oom priority, an empty priority is protected
+func (p OOMPriority) Valid() error {
+	switch p {
+	case "", OOMPriorityProtected, OOMPriorityNormal, OOMPrioritySacrificial:
+		return nil
+	default:
+		return fmt.Errorf("invalid oom priority '%s'", p)
+	}
+}
+
 // Boot structure
 type Boot struct {
 	Type BootType
@@ -119,6 +143,12 @@ type VM struct {
 	CPU uint8
 	// Memory size
 	Memory gridtypes.Unit
+	// MaxCPU is the max number of cores the VM can be grown to while it's
+	// running with VMModule.Resize, the cpu can't be grown if it's not set
+	MaxCPU uint8
+	// MaxMemory is the max memory size the VM can be grown to while it's
+	// running with VMModule.Resize, the memory can't be grown if it's not set
+	MaxMemory gridtypes.Unit
 	// Network is network info
 	Network VMNetworkInfo
 	// KernelImage path to uncompressed linux kernel ELF
@@ -152,6 +182,46 @@ type VM struct {
 	// has to be a valid pci bus ids
 	// in the format 0000:01:00.0
 	Devices []string
+
+	// Watchdog attaches a virtio-watchdog device to the VM. The guest
+	// must run a watchdog daemon that keeps feeding the device, otherwise
+	// the VM is reset by the hypervisor.
+	Watchdog bool
+
+	// BaseImage is the flist that provides the kernel, initrd and firmware
+	// of the VM. If set, the VM module refreshes the base image when it
+	// restarts the VM and the flist has changed.
+	BaseImage *BaseImage
+
+	// Vsock attaches a vsock device to the VM, so the host can talk to
+	// an agent running in the guest. No device is attached if not set.
+	Vsock *VsockConfig
+
+	// OOMPriority of the VM process, VMs are protected if not set
+	OOMPriority OOMPriority
+}
+
+// VsockConfig is the vsock device of a VM
+type VsockConfig struct {
+	// CID is the context id of the VM, it must be unique on the node and
+	// bigger than 2 (0 to 2 are reserved)
+	CID uint32
+	// Socket is the unix socket on the host side of the device, it's always
+	// named after the VM under the VM module run directory. If set, it must
+	// be that path.
+	Socket string
+}
+
+// BaseImage is a read-only flist mount used to boot a VM
+type BaseImage struct {
+	// Name of the mount, the mount is named `<name>:<hash>`
+	Name string
+	// Flist url of the base image
+	Flist string
+	// Hash of the flist the mount was created from
+	Hash string
+	// Path where the base image is mounted
+	Path string
 }
 
 // Validate vm data
@@ -195,6 +265,14 @@ func (vm *VM) Validate() error {
 			return fmt.Errorf("validating disk %s: mount target can't be /", disk.Target)
 		}
 	}
+
+	if vm.Vsock != nil && vm.Vsock.CID < 3 {
+		return fmt.Errorf("invalid vsock cid must be bigger than 2")
+	}
+
+	if err := vm.OOMPriority.Valid(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -212,6 +290,55 @@ type VMInfo struct {
 	CPU int64
 }
 
+// VMCounters are the live usage counters of a running VM
+type VMCounters struct {
+	// CPUTime is the cpu time (user and system) used by the VM since it
+	// started, in nanoseconds
+	CPUTime uint64 `json:"cpu_time"`
+	// Memory is the memory assigned to the VM in bytes
+	Memory uint64 `json:"memory"`
+	// MemoryRSS is the host memory used by the VM in bytes
+	MemoryRSS uint64 `json:"memory_rss"`
+	// Devices are the counters of the VM devices (disks and nics) by device
+	// id, for example read_bytes of a disk or rx_bytes of a nic
+	Devices map[string]map[string]uint64 `json:"devices"`
+}
+
+// LogsChunk is a range of a VM log file
+type LogsChunk struct {
+	// Data is the content of the logs in the requested range
+	Data string `json:"data"`
+	// Offset where Data starts in the log file
+	Offset int64 `json:"offset"`
+	// Size is the total size of the log file, can be used
+	// by the caller to page over the logs
+	Size int64 `json:"size"`
+}
+
+// LogsRetention is how much of a VM logs is kept by the logs rotation. The
+// zero value means the default retention.
+type LogsRetention struct {
+	// MaxSize is the size of the log file that triggers a rotation
+	MaxSize uint64 `json:"max_size"`
+	// TailSize is the size of the latest logs kept (in the tail file) when
+	// the log file is rotated
+	TailSize uint64 `json:"tail_size"`
+}
+
+// LogsInfo describes the log files of a VM
+type LogsInfo struct {
+	// Path of the current log file
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// TailPath is where the logs kept by the last rotation are
+	TailPath string `json:"tail_path"`
+	TailSize int64  `json:"tail_size"`
+	// Retention is the retention applied by the logs rotation
+	Retention LogsRetention `json:"retention"`
+	// Custom is true if the retention was set for this VM
+	Custom bool `json:"custom"`
+}
+
 // NetMetric aggregated metrics from a single network
 type NetMetric struct {
 	NetRxPackets uint64 `json:"net_rx_packets"`
@@ -273,14 +400,45 @@ func (s *Stream) Valid() error {
 type VMModule interface {
 	Run(vm VM) (MachineInfo, error)
 	Inspect(name string) (VMInfo, error)
+	// Counters returns the live cpu, memory and devices usage of a
+	// running machine
+	Counters(name string) (VMCounters, error)
+	// Shutdown presses the ACPI power button of the machine and waits up
+	// to timeout for the guest to power off. The machine is not killed if
+	// it's still running after the timeout, Delete does that.
+	Shutdown(name string, timeout time.Duration) error
+	// Restart shuts down a running machine, waiting up to timeout for the
+	// guest to power off before it's killed, then boots it again from its
+	// saved config. Unlike Delete, the machine config, rootfs and network
+	// are kept.
+	Restart(name string, timeout time.Duration) error
 	Delete(name string) error
 	Exists(name string) bool
 	Logs(name string) (string, error)
 	LogsFull(name string) (string, error)
+	// LogsRange returns up to length bytes of the machine logs starting
+	// at offset
+	LogsRange(name string, offset, length int64) (LogsChunk, error)
+	// LogsInfo returns the log files of the machine and their retention
+	LogsInfo(name string) (LogsInfo, error)
+	// SetLogsRetention sets the logs retention of the machine, the zero
+	// value restores the default retention
+	SetLogsRetention(name string, retention LogsRetention) error
 	List() ([]string, error)
 	Metrics() (MachineMetrics, error)
 	// Lock set lock on VM (pause,resume)
 	Lock(name string, lock bool) error
+	// Resize grows the cpu and memory of a running machine up to its max
+	// cpu and memory without a reboot, a zero value is left unchanged.
+	Resize(name string, cpu uint8, memory gridtypes.Unit) error
+	// Export pauses the machine and writes its state and config to dir so
+	// it can be restored by Import on another node. The machine is left
+	// paused, it must be deleted once it runs on the target node or resumed
+	// with Lock if the migration is aborted.
+	Export(name string, dir string) error
+	// Import restores and resumes a machine exported with Export. The machine
+	// disks and network must already be prepared on this node.
+	Import(name string, dir string) (MachineInfo, error)
 	// VM Log streams
 
 	// StreamCreate creates a stream for vm `name`
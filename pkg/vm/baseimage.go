@@ -0,0 +1,72 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/stubs"
+)
+
+// refreshBaseImage makes sure the machine boots from the latest version of
+// its base image flist. If the flist hash has changed since the machine was
+// created, the new flist is mounted and the machine boot paths are moved to
+// the new mount. This must only be called when the machine is NOT running,
+// so a running vm never sees its kernel or firmware replaced.
+func (m *Module) refreshBaseImage(ctx context.Context, machine *Machine) error {
+	image := machine.BaseImage
+	if image == nil {
+		// created before base images were tracked
+		return nil
+	}
+
+	flist := stubs.NewFlisterStub(m.client)
+	hash, err := flist.FlistHash(ctx, image.Flist)
+	if err != nil {
+		return errors.Wrap(err, "failed to get base image flist hash")
+	}
+
+	if hash == image.Hash {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s:%s", image.Name, hash)
+	path, err := flist.Mount(ctx, name, image.Flist, pkg.ReadOnlyMountOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to mount new base image")
+	}
+
+	log.Info().
+		Str("id", machine.ID).
+		Str("flist", image.Flist).
+		Str("old-hash", image.Hash).
+		Str("new-hash", hash).
+		Msg("refreshing vm base image on restart")
+
+	machine.Boot.Kernel = rebase(machine.Boot.Kernel, image.Path, path)
+	machine.Boot.Initrd = rebase(machine.Boot.Initrd, image.Path, path)
+	machine.BaseImage = &pkg.BaseImage{
+		Name:  image.Name,
+		Flist: image.Flist,
+		Hash:  hash,
+		Path:  path,
+	}
+
+	return machine.Save(m.configPath(machine.ID))
+}
+
+// rebase moves path from old root to new root, paths that
+// are not under the old root (for example a kernel that is
+// provided by the vm flist itself) are returned as is.
+func rebase(path, oldRoot, newRoot string) string {
+	rel, err := filepath.Rel(oldRoot, path)
+	if err != nil || len(path) == 0 || strings.HasPrefix(rel, "..") {
+		return path
+	}
+
+	return filepath.Join(newRoot, rel)
+}
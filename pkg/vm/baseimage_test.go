@@ -0,0 +1,114 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zbus"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/mocks"
+	"github.com/vmihailenco/msgpack"
+	"go.uber.org/mock/gomock"
+)
+
+// testFlister fakes the flist module, the base image flist has the given
+// hash and mounts are recorded
+func testFlister(t *testing.T, hash string, mounts *[]string) zbus.Client {
+	ctrl := gomock.NewController(t)
+	client := mocks.NewMockClient(ctrl)
+	client.EXPECT().
+		RequestContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, module string, object zbus.ObjectID, method string, args ...interface{}) (*zbus.Response, error) {
+			var value string
+			switch method {
+			case "FlistHash":
+				value = hash
+			case "Mount":
+				name := args[0].(string)
+				*mounts = append(*mounts, name)
+				value = filepath.Join("/mnt", name)
+			default:
+				return nil, fmt.Errorf("unexpected call to %s", method)
+			}
+
+			data, err := msgpack.Marshal(value)
+			require.NoError(t, err)
+			return zbus.NewResponse("", zbus.Output{Data: data}, ""), nil
+		}).
+		AnyTimes()
+
+	return client
+}
+
+func TestRefreshBaseImage(t *testing.T) {
+	image := &pkg.BaseImage{
+		Name:  "cloud-container",
+		Flist: "https://hub.grid.tf/tf-autobuilder/cloud-container.flist",
+		Hash:  "old",
+		Path:  "/mnt/cloud-container:old",
+	}
+	machine := func() *Machine {
+		return &Machine{
+			ID: "vm",
+			Boot: Boot{
+				Kernel: "/mnt/cloud-container:old/kernel",
+				Initrd: "/mnt/cloud-container:old/initrd",
+			},
+			BaseImage: image,
+		}
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		var mounts []string
+		m := &Module{cfg: t.TempDir(), client: testFlister(t, "old", &mounts)}
+
+		vm := machine()
+		require.NoError(t, m.refreshBaseImage(context.Background(), vm))
+		require.Empty(t, mounts)
+		require.Equal(t, machine(), vm)
+	})
+
+	t.Run("not tracked", func(t *testing.T) {
+		var mounts []string
+		m := &Module{cfg: t.TempDir(), client: testFlister(t, "new", &mounts)}
+
+		vm := machine()
+		vm.BaseImage = nil
+		require.NoError(t, m.refreshBaseImage(context.Background(), vm))
+		require.Empty(t, mounts)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		var mounts []string
+		m := &Module{cfg: t.TempDir(), client: testFlister(t, "new", &mounts)}
+
+		vm := machine()
+		require.NoError(t, m.refreshBaseImage(context.Background(), vm))
+		require.Equal(t, []string{"cloud-container:new"}, mounts)
+		require.Equal(t, "/mnt/cloud-container:new/kernel", vm.Boot.Kernel)
+		require.Equal(t, "/mnt/cloud-container:new/initrd", vm.Boot.Initrd)
+		require.Equal(t, &pkg.BaseImage{
+			Name:  image.Name,
+			Flist: image.Flist,
+			Hash:  "new",
+			Path:  "/mnt/cloud-container:new",
+		}, vm.BaseImage)
+
+		// the machine is saved with the new image
+		saved, err := MachineFromFile(m.configPath("vm"))
+		require.NoError(t, err)
+		require.Equal(t, vm.Boot, saved.Boot)
+		require.Equal(t, vm.BaseImage, saved.BaseImage)
+	})
+}
+
+func TestRebase(t *testing.T) {
+	require.Equal(t, "/new/kernel", rebase("/old/kernel", "/old", "/new"))
+	require.Equal(t, "/new/boot/initrd", rebase("/old/boot/initrd", "/old", "/new"))
+	// not provided by the base image
+	require.Equal(t, "/vm/kernel", rebase("/vm/kernel", "/old", "/new"))
+	require.Equal(t, "", rebase("", "/old", "/new"))
+}
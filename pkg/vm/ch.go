@@ -26,6 +26,14 @@ const (
 	cloudConsoleBin = "cloud-console"
 )
 
+var (
+	// ConsoleMyceliumRetries is how many times the mycelium of the network is
+	// inspected before giving up on starting the cloud console of a light vm
+	ConsoleMyceliumRetries uint64 = 5
+	// ConsoleMyceliumRetryInterval is the wait between mycelium inspect attempts
+	ConsoleMyceliumRetryInterval = 2 * time.Second
+)
+
 // startCloudConsole Starts the cloud console for the vm on it's private network ip
 func (m *Machine) startCloudConsole(ctx context.Context, namespace string, networkAddr net.IPNet, machineIP net.IPNet, ptyPath string, logs string) (string, error) {
 	ipv4 := machineIP.IP.To4()
@@ -65,14 +73,33 @@ func (m *Machine) startCloudConsole(ctx context.Context, namespace string, netwo
 
 // startCloudConsoleLight Starts the cloud console for the vm on it's private network ip
 func (m *Machine) startCloudConsoleLight(ctx context.Context, namespace string, machineIP net.IPNet, ptyPath string, logs string) (string, error) {
-	netSeed, err := os.ReadFile(filepath.Join(resource.MyceliumSeedDir, namespace))
-	if err != nil {
-		return "", err
+	// the mycelium of the network can be briefly not ready right after
+	// the network is created, so the seed read and inspect are retried
+	var inspect resource.MyceliumInspection
+	inspectMycelium := func() error {
+		netSeed, err := os.ReadFile(filepath.Join(resource.MyceliumSeedDir, namespace))
+		if err != nil {
+			return errors.Wrap(err, "failed to read network mycelium seed")
+		}
+
+		inspect, err = resource.InspectMycelium(netSeed)
+		if err != nil {
+			return errors.Wrap(err, "failed to inspect network mycelium")
+		}
+
+		return nil
 	}
 
-	inspect, err := resource.InspectMycelium(netSeed)
-	if err != nil {
-		return "", err
+	if err := backoff.RetryNotify(
+		inspectMycelium,
+		backoff.WithContext(
+			backoff.WithMaxRetries(backoff.NewConstantBackOff(ConsoleMyceliumRetryInterval), ConsoleMyceliumRetries),
+			ctx,
+		),
+		func(err error, d time.Duration) {
+			log.Debug().Err(err).Str("vm-id", m.ID).Str("namespace", namespace).Msg("network mycelium is not ready yet")
+		}); err != nil {
+		return "", errors.Wrapf(err, "network mycelium is not ready after %d retries", ConsoleMyceliumRetries)
 	}
 
 	mycIp := inspect.IP().String()
@@ -115,17 +142,15 @@ func (m *Machine) startCloudConsoleLight(ctx context.Context, namespace string,
 	return consoleURL, nil
 }
 
-// Run run the machine with cloud-hypervisor
-func (m *Machine) Run(ctx context.Context, socket, logs string) (pkg.MachineInfo, error) {
-	_ = os.Remove(socket)
-
-	// build command line
+// args builds the cloud-hypervisor arguments of the machine that don't need
+// any resources (like virtiofsd daemons or taps) to be prepared first
+func (m *Machine) args(socket string) map[string][]string {
 	args := map[string][]string{
 		"--kernel":  {m.Boot.Kernel},
 		"--cmdline": {m.Boot.Args},
 
-		"--cpus":   {m.Config.CPU.String()},
-		"--memory": {fmt.Sprintf("%s,shared=on", m.Config.Mem.String())},
+		"--cpus":   {m.Config.cpus()},
+		"--memory": {m.Config.memory()},
 
 		"--console":    {"off"},
 		"--serial":     {"pty"}, // we use pty here for the cloud console to be able to read the vm console, in case of debuging or we need stdout logging we use tty
@@ -141,6 +166,26 @@ func (m *Machine) Run(ctx context.Context, socket, logs string) (pkg.MachineInfo
 		args["--device"] = devices
 	}
 
+	if m.Watchdog {
+		// the guest is reset if its watchdog daemon stops feeding the device
+		args["--watchdog"] = nil
+	}
+
+	if m.Vsock != nil {
+		args["--vsock"] = []string{m.Vsock.String()}
+	}
+
+	return args
+}
+
+// Run run the machine with cloud-hypervisor
+func (m *Machine) Run(ctx context.Context, socket, logs string) (pkg.MachineInfo, error) {
+	_ = os.Remove(socket)
+	m.removeVsock()
+
+	// build command line
+	args := m.args(socket)
+
 	var err error
 	var pids []int
 	defer func() {
@@ -153,7 +198,7 @@ func (m *Machine) Run(ctx context.Context, socket, logs string) (pkg.MachineInfo
 
 	var filesystems []string
 	for i, fs := range m.FS {
-		socket := filepath.Join("/var", "run", fmt.Sprintf("virtio-%s-%d.socket", m.ID, i))
+		socket := FsSocketPath(m.ID, i)
 		var pid int
 		pid, err = m.startFs(socket, fs.Path)
 		if err != nil {
@@ -287,14 +332,21 @@ func (m *Machine) Run(ctx context.Context, socket, logs string) (pkg.MachineInfo
 	logEvent = logInterfaceDetails(logEvent, m.Interfaces, m.NetworkInfo)
 	logEvent.Msg("VM started with network interfaces and addresses")
 
+	return pkg.MachineInfo{ConsoleURL: m.startConsole(ctx, vmData.PTYPath, logs)}, nil
+}
+
+// startConsole starts the cloud console of the machine interfaces that have
+// one, and returns the console url
+func (m *Machine) startConsole(ctx context.Context, pty, logs string) string {
+	var err error
 	consoleURL := ""
 	for _, ifc := range m.Interfaces {
 		if ifc.Console != nil {
 			if kernel.GetParams().IsLight() {
-				consoleURL, err = m.startCloudConsoleLight(ctx, ifc.Console.Namespace, ifc.Console.VmAddress, vmData.PTYPath, logs)
+				consoleURL, err = m.startCloudConsoleLight(ctx, ifc.Console.Namespace, ifc.Console.VmAddress, pty, logs)
 			} else {
 
-				consoleURL, err = m.startCloudConsole(ctx, ifc.Console.Namespace, ifc.Console.ListenAddress, ifc.Console.VmAddress, vmData.PTYPath, logs)
+				consoleURL, err = m.startCloudConsole(ctx, ifc.Console.Namespace, ifc.Console.ListenAddress, ifc.Console.VmAddress, pty, logs)
 			}
 			if err != nil {
 				log.Error().Err(err).Str("vm", m.ID).Msg("failed to start cloud-console for vm")
@@ -302,7 +354,113 @@ func (m *Machine) Run(ctx context.Context, socket, logs string) (pkg.MachineInfo
 		}
 	}
 
-	return pkg.MachineInfo{ConsoleURL: consoleURL}, nil
+	return consoleURL
+}
+
+// the process helpers of a restore
+var (
+	// spawnCH starts cloud-hypervisor with args and returns its pid
+	spawnCH = func(ctx context.Context, m *Machine, logs string, args ...string) (int, error) {
+		logFd, err := os.OpenFile(logs, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return 0, err
+		}
+		defer logFd.Close()
+
+		cmd := exec.CommandContext(ctx, "busybox", append([]string{"setsid", chBin}, args...)...)
+		cmd.Stdout = logFd
+		cmd.Stderr = logFd
+
+		if err := cmd.Start(); err != nil {
+			return 0, errors.Wrap(err, "failed to start cloud-hypervisor")
+		}
+
+		pid := cmd.Process.Pid
+		return pid, m.release(cmd.Process)
+	}
+	// waitMachine waits for the machine api socket to be ready
+	waitMachine = func(ctx context.Context, m *Machine, socket string) error {
+		return m.waitAndAdjOom(ctx, m.ID, socket)
+	}
+	// checkTap checks that the tap device of the interface is ready
+	checkTap = func(nic *Interface) error {
+		typ, _, err := nic.getType()
+		if err != nil {
+			return err
+		}
+		if typ != InterfaceTAP {
+			return fmt.Errorf("unsupported tap device type '%s'", nic.Tap)
+		}
+		return nil
+	}
+	killProcess = func(pid int) {
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+	}
+)
+
+// Restore starts the machine from a snapshot in dir (as written by the
+// snapshot api) and resumes it. The tap devices of the machine must be
+// prepared by the network module before the restore, they are checked the
+// same way a machine is started. All the started processes are killed if the
+// restore fails.
+func (m *Machine) Restore(ctx context.Context, socket, logs, dir string) (info pkg.MachineInfo, err error) {
+	for i := range m.Interfaces {
+		nic := &m.Interfaces[i]
+		if err := checkTap(nic); err != nil {
+			return info, errors.Wrapf(err, "tap device '%s' is not ready", nic.Tap)
+		}
+	}
+
+	_ = os.Remove(socket)
+	m.removeVsock()
+
+	var pids []int
+	defer func() {
+		if err != nil {
+			for _, pid := range pids {
+				killProcess(pid)
+			}
+		}
+	}()
+
+	// virtiofsd daemons must listen on the same sockets the machine was
+	// started with since they are part of the snapshot config
+	for i, fs := range m.FS {
+		socket := FsSocketPath(m.ID, i)
+		var pid int
+		pid, err = startFs(m, socket, fs.Path)
+		if err != nil {
+			return info, err
+		}
+		pids = append(pids, pid)
+	}
+
+	log.Info().Str("vm-id", m.ID).Str("source", dir).Msg("restoring cloud-hypervisor VM")
+	pid, err := spawnCH(ctx, m, logs,
+		"--api-socket", socket,
+		"--restore", fmt.Sprintf("source_url=file://%s", dir),
+	)
+	if err != nil {
+		return info, err
+	}
+	pids = append(pids, pid)
+
+	if err = waitMachine(ctx, m, socket); err != nil {
+		return info, err
+	}
+
+	// the restored machine is paused
+	client := NewClient(socket)
+	if err = client.Resume(ctx); err != nil {
+		return info, errors.Wrapf(err, "failed to resume restored machine '%s'", m.ID)
+	}
+
+	data, err := client.Inspect(ctx)
+	if err != nil {
+		return info, errors.Wrapf(err, "failed to inspect restored machine '%s'", m.ID)
+	}
+
+	return pkg.MachineInfo{ConsoleURL: m.startConsole(ctx, data.PTYPath, logs)}, nil
 }
 
 func (m *Machine) waitAndAdjOom(ctx context.Context, name string, socket string) error {
@@ -341,13 +499,46 @@ func (m *Machine) waitAndAdjOom(ctx context.Context, name string, socket string)
 		return errors.Wrapf(err, "failed to find vm with id '%s'", name)
 	}
 
-	if err := os.WriteFile(filepath.Join("/proc/", fmt.Sprint(ps.Pid), "oom_score_adj"), []byte("-200"), 0644); err != nil {
+	if err := adjOom("/proc", ps.Pid, m.OOMPriority); err != nil {
 		return errors.Wrapf(err, "failed to update oom priority for machine '%s' (PID: %d)", name, ps.Pid)
 	}
 
 	return nil
 }
 
+// oomScoreAdj is the oom_score_adj of a machine process with the given priority
+func oomScoreAdj(priority pkg.OOMPriority) int {
+	switch priority {
+	case pkg.OOMPriorityNormal:
+		return 0
+	case pkg.OOMPrioritySacrificial:
+		return 500
+	default:
+		return -200
+	}
+}
+
+// adjOom writes the oom_score_adj of process pid under the proc root
+func adjOom(proc string, pid int, priority pkg.OOMPriority) error {
+	adj := fmt.Sprint(oomScoreAdj(priority))
+	return os.WriteFile(filepath.Join(proc, fmt.Sprint(pid), "oom_score_adj"), []byte(adj), 0644)
+}
+
+// removeVsock removes a stale vsock socket left by a previous run of the
+// machine, cloud-hypervisor fails to bind it otherwise. Only the socket
+// derived from the machine name is ever removed.
+func (m *Machine) removeVsock() {
+	if m.Vsock != nil && m.Vsock.Socket == vsockPath(m.ID) {
+		_ = os.Remove(m.Vsock.Socket)
+	}
+}
+
+// FsSocketPath returns the virtiofsd socket of the index-th filesystem
+// of the machine
+func FsSocketPath(id string, index int) string {
+	return filepath.Join("/var", "run", fmt.Sprintf("virtio-%s-%d.socket", id, index))
+}
+
 func (m *Machine) startFs(socket, path string) (int, error) {
 	cmd := exec.Command("busybox", "setsid",
 		"virtiofsd-rs",
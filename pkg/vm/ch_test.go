@@ -0,0 +1,89 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+func TestMachineArgsVsock(t *testing.T) {
+	machine := Machine{ID: "vm", Config: Config{CPU: 1, Mem: 512}}
+	args := machine.args("/var/run/cloud-hypervisor/vm")
+	require.NotContains(t, args, "--vsock")
+
+	machine.Vsock = &Vsock{CID: 3, Socket: "/var/run/cloud-hypervisor/vm.vsock"}
+	args = machine.args("/var/run/cloud-hypervisor/vm")
+	require.Equal(t, []string{"cid=3,socket=/var/run/cloud-hypervisor/vm.vsock"}, args["--vsock"])
+}
+
+func TestMakeVsock(t *testing.T) {
+	var m Module
+
+	vsock, err := m.makeVsock(&pkg.VM{Name: "vm"})
+	require.NoError(t, err)
+	require.Nil(t, vsock)
+
+	vsock, err = m.makeVsock(&pkg.VM{Name: "vm", Vsock: &pkg.VsockConfig{CID: 3}})
+	require.NoError(t, err)
+	require.Equal(t, &Vsock{CID: 3, Socket: "/var/run/cloud-hypervisor/vm.vsock"}, vsock)
+
+	vsock, err = m.makeVsock(&pkg.VM{Name: "vm", Vsock: &pkg.VsockConfig{CID: 3, Socket: "/var/run/cloud-hypervisor/vm.vsock"}})
+	require.NoError(t, err)
+	require.Equal(t, "/var/run/cloud-hypervisor/vm.vsock", vsock.Socket)
+
+	// only the socket named after the vm is allowed
+	_, err = m.makeVsock(&pkg.VM{Name: "vm", Vsock: &pkg.VsockConfig{CID: 3, Socket: "/var/run/cloud-hypervisor/agent.sock"}})
+	require.Error(t, err)
+
+	_, err = m.makeVsock(&pkg.VM{Name: "vm", Vsock: &pkg.VsockConfig{CID: 3, Socket: "/var/run/cloud-hypervisor/../agent.sock"}})
+	require.Error(t, err)
+
+	_, err = m.makeVsock(&pkg.VM{Name: "../vm", Vsock: &pkg.VsockConfig{CID: 3}})
+	require.Error(t, err)
+}
+
+func TestRemoveVsock(t *testing.T) {
+	// a socket that is not derived from the machine name is never removed
+	other := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(other, nil, 0644))
+
+	machine := Machine{ID: "vm", Vsock: &Vsock{CID: 3, Socket: other}}
+	machine.removeVsock()
+	require.FileExists(t, other)
+}
+
+func TestAdjOom(t *testing.T) {
+	proc := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(proc, "10"), 0755))
+
+	cases := map[pkg.OOMPriority]string{
+		"":                         "-200",
+		pkg.OOMPriorityProtected:   "-200",
+		pkg.OOMPriorityNormal:      "0",
+		pkg.OOMPrioritySacrificial: "500",
+	}
+
+	for priority, expected := range cases {
+		t.Run(string(priority), func(t *testing.T) {
+			require.NoError(t, adjOom(proc, 10, priority))
+			data, err := os.ReadFile(filepath.Join(proc, "10", "oom_score_adj"))
+			require.NoError(t, err)
+			require.Equal(t, expected, string(data))
+		})
+	}
+}
+
+func TestMachineArgsWatchdog(t *testing.T) {
+	machine := Machine{ID: "vm", Config: Config{CPU: 1, Mem: 512}}
+	args := machine.args("/var/run/cloud-hypervisor/vm")
+	require.NotContains(t, args, "--watchdog")
+
+	// with the device attached the hypervisor resets a stalled guest
+	machine.Watchdog = true
+	args = machine.args("/var/run/cloud-hypervisor/vm")
+	require.Contains(t, args, "--watchdog")
+	require.Empty(t, args["--watchdog"])
+}
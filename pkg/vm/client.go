@@ -1,46 +1,96 @@
 package vm
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
 )
 
+const (
+	// DefaultClientTimeout is the default timeout of a cloud-hypervisor api
+	// call (including retries), if the caller context has no deadline
+	DefaultClientTimeout = 30 * time.Second
+
+	clientDialTimeout = 2 * time.Second
+)
+
+// ErrCountersUnsupported is returned by Counters if the cloud-hypervisor
+// api of the machine doesn't provide the vm counters
+var ErrCountersUnsupported = errors.New("machine counters are not supported")
+
 // Client to a cloud hypervisor instance
 type Client struct {
-	client *retryablehttp.Client
+	client  *retryablehttp.Client
+	timeout time.Duration
 }
 
 type VMData struct {
-	CPU     CPU
+	CPU CPU
+	// MaxCPU is the max number of vcpus the machine can be resized to
+	MaxCPU CPU
+	// Memory is the boot memory plus the hot plugged memory
 	Memory  MemMib
 	PTYPath string
 }
 
-// NewClient creates a new instance of client
-func NewClient(unix string) *Client {
+// ClientOpt is an option of the cloud-hypervisor client
+type ClientOpt func(c *Client)
+
+// WithTimeout sets the timeout of calls made with a context that has no
+// deadline
+func WithTimeout(timeout time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// NewClient creates a new instance of client. All calls honor the caller
+// context, and fail after the client timeout if the context has no deadline
+// so an unresponsive api socket never blocks the caller forever.
+func NewClient(unix string, opts ...ClientOpt) *Client {
 	httpClient := retryablehttp.NewClient()
 	httpClient.RetryMax = 5
+	httpClient.RetryWaitMax = 2 * time.Second
 	httpClient.HTTPClient.Transport = &http.Transport{
-		Dial: func(network, _ string) (net.Conn, error) {
-			return net.Dial("unix", unix)
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			dialer := net.Dialer{Timeout: clientDialTimeout}
+			return dialer.DialContext(ctx, "unix", unix)
 		},
 	}
 	client := Client{
-		client: httpClient,
+		client:  httpClient,
+		timeout: DefaultClientTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(&client)
 	}
 
 	return &client
 }
 
+// withTimeout applies the client timeout to ctx if it has no deadline
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, c.timeout)
+}
+
 // Shutdown shuts the machine down
 func (c *Client) Shutdown(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	request, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://unix/api/v1/vm.shutdown", nil)
 	if err != nil {
 		return err
@@ -58,7 +108,34 @@ func (c *Client) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// PowerButton presses the machine ACPI power button, the guest is expected
+// to shut down cleanly
+func (c *Client) PowerButton(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://unix/api/v1/vm.power-button", nil)
+	if err != nil {
+		return err
+	}
+	response, err := c.client.StandardClient().Do(request)
+	if err != nil {
+		return errors.Wrap(err, "error calling machine power-button")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("got unexpected http code '%s' on machine power-button", response.Status)
+	}
+
+	return nil
+}
+
+// Pause pauses the machine
 func (c *Client) Pause(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	request, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://unix/api/v1/vm.pause", nil)
 	if err != nil {
 		return err
@@ -76,14 +153,18 @@ func (c *Client) Pause(ctx context.Context) error {
 	return nil
 }
 
+// Resume resumes a paused machine
 func (c *Client) Resume(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	request, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://unix/api/v1/vm.resume", nil)
 	if err != nil {
 		return err
 	}
 	response, err := c.client.StandardClient().Do(request)
 	if err != nil {
-		return errors.Wrap(err, "error calling machine pause")
+		return errors.Wrap(err, "error calling machine resume")
 	}
 	defer response.Body.Close()
 
@@ -94,8 +175,174 @@ func (c *Client) Resume(ctx context.Context) error {
 	return nil
 }
 
+// Snapshot writes the state of the machine to the destination url (for
+// example file:///path/to/dir), the machine must be paused
+func (c *Client) Snapshot(ctx context.Context, destination string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	body, err := json.Marshal(struct {
+		Destination string `json:"destination_url"`
+	}{destination})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://unix/api/v1/vm.snapshot", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Add("content-type", "application/json")
+
+	response, err := c.client.StandardClient().Do(request)
+	if err != nil {
+		return errors.Wrap(err, "error calling machine snapshot")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("got unexpected http code '%s' on machine snapshot, Response: %s", response.Status, string(body))
+	}
+
+	return nil
+}
+
+// FsDevice is a virtio-fs device of the machine
+type FsDevice struct {
+	ID     string `json:"id,omitempty"`
+	Tag    string `json:"tag"`
+	Socket string `json:"socket"`
+}
+
+// FsDevices returns the virtio-fs devices of the machine
+func (c *Client) FsDevices(ctx context.Context) ([]FsDevice, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://unix/api/v1/vm.info", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.client.StandardClient().Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "error calling machine info")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(response.Body)
+		return nil, fmt.Errorf("got unexpected http code '%s' on machine info, Response: %s", response.Status, string(body))
+	}
+
+	var data struct {
+		Config struct {
+			Fs []FsDevice `json:"fs"`
+		} `json:"config"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&data); err != nil {
+		return nil, errors.Wrap(err, "failed to parse machine information")
+	}
+
+	return data.Config.Fs, nil
+}
+
+// RemoveDevice hot unplugs the device with the given id from the machine
+func (c *Client) RemoveDevice(ctx context.Context, id string) error {
+	return c.put(ctx, "vm.remove-device", struct {
+		ID string `json:"id"`
+	}{id}, http.StatusNoContent)
+}
+
+// AddFs hot plugs a virtio-fs device to the machine
+func (c *Client) AddFs(ctx context.Context, device FsDevice) error {
+	// add-fs returns the device info on success
+	return c.put(ctx, "vm.add-fs", device, http.StatusOK, http.StatusNoContent)
+}
+
+// Counters returns the counters of the machine devices by device id
+func (c *Client) Counters(ctx context.Context) (map[string]map[string]uint64, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://unix/api/v1/vm.counters", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.client.StandardClient().Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "error calling machine counters")
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrCountersUnsupported
+	default:
+		body, _ := io.ReadAll(response.Body)
+		return nil, fmt.Errorf("got unexpected http code '%s' on machine counters, Response: %s", response.Status, string(body))
+	}
+
+	var counters map[string]map[string]uint64
+	if err := json.NewDecoder(response.Body).Decode(&counters); err != nil {
+		return nil, errors.Wrap(err, "failed to parse machine counters")
+	}
+
+	return counters, nil
+}
+
+// ResizeRequest is a vm.resize request, zero fields are left unchanged
+type ResizeRequest struct {
+	DesiredVCPUs uint8 `json:"desired_vcpus,omitempty"`
+	// DesiredRAM is the new memory size in bytes
+	DesiredRAM uint64 `json:"desired_ram,omitempty"`
+}
+
+// Resize hot plugs vcpus or memory to the machine
+func (c *Client) Resize(ctx context.Context, resize ResizeRequest) error {
+	return c.put(ctx, "vm.resize", resize, http.StatusNoContent)
+}
+
+func (c *Client) put(ctx context.Context, action string, input interface{}, expected ...int) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("http://unix/api/v1/%s", action), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Add("content-type", "application/json")
+
+	response, err := c.client.StandardClient().Do(request)
+	if err != nil {
+		return errors.Wrapf(err, "error calling machine %s", action)
+	}
+	defer response.Body.Close()
+
+	for _, code := range expected {
+		if response.StatusCode == code {
+			return nil
+		}
+	}
+
+	data, _ := io.ReadAll(response.Body)
+	return fmt.Errorf("got unexpected http code '%s' on machine %s, Response: %s", response.Status, action, string(data))
+}
+
 // Inspect return information about the vm
 func (c *Client) Inspect(ctx context.Context) (VMData, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://unix/api/v1/vm.info", nil)
 	if err != nil {
 		return VMData{}, err
@@ -121,9 +368,11 @@ func (c *Client) Inspect(ctx context.Context) (VMData, error) {
 		Config struct {
 			CPU struct {
 				Boot uint8 `json:"boot_vcpus"`
+				Max  uint8 `json:"max_vcpus"`
 			} `json:"cpus"`
 			Memory struct {
-				Size int64 `json:"size"`
+				Size       int64 `json:"size"`
+				Hotplugged int64 `json:"hotplugged_size"`
 			} `json:"memory"`
 			Serial struct {
 				PTYPath string `json:"file"`
@@ -136,7 +385,8 @@ func (c *Client) Inspect(ctx context.Context) (VMData, error) {
 	}
 	vmData := VMData{
 		CPU:     CPU(data.Config.CPU.Boot),
-		Memory:  MemMib(data.Config.Memory.Size / (1024 * 1024)),
+		MaxCPU:  CPU(data.Config.CPU.Max),
+		Memory:  MemMib((data.Config.Memory.Size + data.Config.Memory.Hotplugged) / (1024 * 1024)),
 		PTYPath: data.Config.Serial.PTYPath,
 	}
 	return vmData, nil
@@ -0,0 +1,65 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/shirou/gopsutil/process"
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+// Counters returns the counters of the running machine behind the api socket,
+// the cpu time and host memory usage are not set since they are only known
+// by the machine process. ErrCountersUnsupported is returned if the machine
+// api doesn't provide the counters.
+func (m *Machine) Counters(ctx context.Context, socket string) (pkg.VMCounters, error) {
+	client := NewClient(socket)
+	devices, err := client.Counters(ctx)
+	if err != nil {
+		return pkg.VMCounters{}, err
+	}
+
+	data, err := client.Inspect(ctx)
+	if err != nil {
+		return pkg.VMCounters{}, errors.Wrap(err, "failed to inspect machine")
+	}
+
+	return pkg.VMCounters{
+		Memory:  uint64(data.Memory) * 1024 * 1024,
+		Devices: devices,
+	}, nil
+}
+
+// Counters returns the live cpu, memory and devices usage of a running machine
+func (m *Module) Counters(name string) (pkg.VMCounters, error) {
+	ps, err := Find(name)
+	if err != nil {
+		return pkg.VMCounters{}, fmt.Errorf("machine '%s' is not running", name)
+	}
+
+	machine := Machine{ID: name}
+	counters, err := machine.Counters(context.Background(), m.socketPath(name))
+	if err != nil {
+		return pkg.VMCounters{}, err
+	}
+
+	proc, err := process.NewProcess(int32(ps.Pid))
+	if err != nil {
+		return pkg.VMCounters{}, errors.Wrapf(err, "failed to get machine '%s' process", name)
+	}
+
+	times, err := proc.Times()
+	if err != nil {
+		return pkg.VMCounters{}, errors.Wrapf(err, "failed to get machine '%s' cpu time", name)
+	}
+	counters.CPUTime = uint64((times.User + times.System) * 1e9)
+
+	memory, err := proc.MemoryInfo()
+	if err != nil {
+		return pkg.VMCounters{}, errors.Wrapf(err, "failed to get machine '%s' memory usage", name)
+	}
+	counters.MemoryRSS = memory.RSS
+
+	return counters, nil
+}
@@ -0,0 +1,43 @@
+package vm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+func TestMachineCounters(t *testing.T) {
+	fake := &fakeCH{
+		cpu:    2,
+		max:    2,
+		memory: int64(gridtypes.Gigabyte),
+		counters: `{
+			"_disk0": {"read_bytes": 4096, "write_bytes": 512, "read_ops": 2, "write_ops": 1},
+			"_net1": {"rx_bytes": 1024, "tx_bytes": 2048, "rx_frames": 3, "tx_frames": 4}
+		}`,
+	}
+	socket := testCH(t, fake)
+
+	machine := Machine{ID: "vm"}
+	counters, err := machine.Counters(context.Background(), socket)
+	require.NoError(t, err)
+	require.Equal(t, pkg.VMCounters{
+		Memory: uint64(gridtypes.Gigabyte),
+		Devices: map[string]map[string]uint64{
+			"_disk0": {"read_bytes": 4096, "write_bytes": 512, "read_ops": 2, "write_ops": 1},
+			"_net1":  {"rx_bytes": 1024, "tx_bytes": 2048, "rx_frames": 3, "tx_frames": 4},
+		},
+	}, counters)
+}
+
+func TestMachineCountersUnsupported(t *testing.T) {
+	fake := &fakeCH{cpu: 2, max: 2, memory: int64(gridtypes.Gigabyte)}
+	socket := testCH(t, fake)
+
+	machine := Machine{ID: "vm"}
+	_, err := machine.Counters(context.Background(), socket)
+	require.ErrorIs(t, err, ErrCountersUnsupported)
+}
@@ -47,11 +47,50 @@ func (p *Process) GetParam(arg string) ([]string, bool) {
 // FindAll finds all running cloud-hypervisor processes
 func FindAll() (map[string]Process, error) {
 	const (
-		proc   = "/proc"
 		search = "cloud-hypervisor"
 		idFlag = "--api-socket"
 	)
 
+	processes, err := findProcesses(search, idFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]Process)
+	for socket, ps := range processes {
+		found[filepath.Base(socket)] = ps
+	}
+
+	return found, nil
+}
+
+// FindFs finds the virtiofsd process serving the given socket
+func FindFs(socket string) (Process, error) {
+	const (
+		search     = "virtiofsd-rs"
+		socketFlag = "--socket-path"
+	)
+
+	processes, err := findProcesses(search, socketFlag)
+	if err != nil {
+		return Process{}, err
+	}
+
+	ps, ok := processes[socket]
+	if !ok {
+		return Process{}, fmt.Errorf("virtiofsd for socket '%s' not found", socket)
+	}
+
+	return ps, nil
+}
+
+// findProcesses scans /proc for processes of the search binary, they are
+// returned by the (first) value of the flag
+func findProcesses(search, flag string) (map[string]Process, error) {
+	const (
+		proc = "/proc"
+	)
+
 	found := make(map[string]Process)
 	err := filepath.Walk(proc, func(path string, info os.FileInfo, _ error) error {
 		if path == proc {
@@ -88,13 +127,12 @@ func FindAll() (map[string]Process, error) {
 		}
 
 		ps := Process{Pid: pid, Args: args}
-		values, ok := ps.GetParam(idFlag)
+		values, ok := ps.GetParam(flag)
 		if !ok || len(values) == 0 {
-			// could not find the --log-file flag!
+			// could not find the flag!
 			return nil
 		}
-		id := filepath.Base(values[0])
-		found[id] = ps
+		found[values[0]] = ps
 
 		return nil
 	})
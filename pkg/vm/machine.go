@@ -118,6 +118,11 @@ type Config struct {
 	CPU       CPU    `json:"vcpu_count"`
 	Mem       MemMib `json:"mem_size_mib"`
 	HTEnabled bool   `json:"ht_enabled"`
+	// MaxCPU and MaxMem are the max the machine can be resized to while
+	// running, no cpu or memory can be hot plugged if they are not bigger
+	// than CPU and Mem
+	MaxCPU CPU    `json:"max_vcpu_count,omitempty"`
+	MaxMem MemMib `json:"max_mem_size_mib,omitempty"`
 }
 
 // VirtioFS represents a virtiofs mount
@@ -126,6 +131,17 @@ type VirtioFS struct {
 	Path string
 }
 
+// Vsock is a vsock device of the machine
+type Vsock struct {
+	CID uint32 `json:"cid"`
+	// Socket is the unix socket the host connects to
+	Socket string `json:"socket"`
+}
+
+func (v Vsock) String() string {
+	return fmt.Sprintf("cid=%d,socket=%s", v.CID, v.Socket)
+}
+
 // Machine struct
 type Machine struct {
 	ID         string     `json:"id"`
@@ -139,6 +155,18 @@ type Machine struct {
 	// NoKeepAlive is not used by firecracker, but instead a marker
 	// for the vm  mananger to not restart the machine when it stops
 	NoKeepAlive bool `json:"no-keep-alive"`
+	// Watchdog enables the virtio-watchdog device, the guest needs to run
+	// a watchdog daemon to benefit from it. Off by default.
+	Watchdog bool `json:"watchdog,omitempty"`
+	// BaseImage is the flist mount that provides the kernel, initrd and firmware,
+	// it's refreshed when the machine is restarted and the flist has changed.
+	BaseImage *pkg.BaseImage `json:"base-image,omitempty"`
+	// Vsock device of the machine, if set
+	Vsock *Vsock `json:"vsock,omitempty"`
+	// OOMPriority of the machine process
+	OOMPriority pkg.OOMPriority `json:"oom-priority,omitempty"`
+	// Paused is set while the machine is paused with Lock
+	Paused bool `json:"paused,omitempty"`
 	// NetworkInfo holds the full network configuration with IPs (not serialized to config file)
 	NetworkInfo *pkg.VMNetworkInfo `json:"-"`
 }
@@ -34,6 +34,12 @@ const (
 
 	// cloud-init directory
 	cloudInitDir = "cloud-init"
+
+	// retentionDir is where the custom logs retention of vms are kept
+	retentionDir = "logs-retention"
+
+	// maxLogsChunk is the max size of logs returned by a single LogsRange call
+	maxLogsChunk = 512 * 1024 // 512K
 )
 
 var (
@@ -56,6 +62,10 @@ type Module struct {
 	lock     sync.Mutex
 	failures *cache.Cache
 
+	// fsRecoveries is the virtiofsd recovery state of the shares by socket
+	fsRecoveries map[string]*fsRecovery
+	fsLock       sync.Mutex
+
 	legacyMonitor LegacyMonitor
 }
 
@@ -69,6 +79,7 @@ func NewVMModule(cl zbus.Client, root, config string) (*Module, error) {
 		socketDir,
 		filepath.Join(root, logsDir),
 		filepath.Join(root, cloudInitDir),
+		filepath.Join(root, retentionDir),
 	} {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return nil, err
@@ -139,6 +150,36 @@ func (m *Module) logsPath(name string) string {
 	return filepath.Join(m.root, logsDir, name)
 }
 
+func (m *Module) retentionPath(name string) string {
+	return filepath.Join(m.root, retentionDir, name)
+}
+
+// vsockPath is the host socket of the vsock device of the machine, it's the
+// only path a vsock socket can have so a machine config can never be used to
+// remove another file
+func vsockPath(name string) string {
+	return filepath.Join(socketDir, fmt.Sprintf("%s.vsock", name))
+}
+
+// makeVsock creates the vsock device of the machine, the host socket is always
+// derived from the machine name
+func (m *Module) makeVsock(vm *pkg.VM) (*Vsock, error) {
+	if vm.Vsock == nil {
+		return nil, nil
+	}
+
+	socket := vsockPath(vm.Name)
+	if filepath.Dir(socket) != socketDir {
+		return nil, fmt.Errorf("invalid machine name '%s'", vm.Name)
+	}
+
+	if len(vm.Vsock.Socket) != 0 && vm.Vsock.Socket != socket {
+		return nil, fmt.Errorf("vsock socket must be '%s'", socket)
+	}
+
+	return &Vsock{CID: vm.Vsock.CID, Socket: socket}, nil
+}
+
 func (m *Module) cloudInitImage(name string) string {
 	return filepath.Join(m.root, cloudInitDir, name)
 }
@@ -517,6 +558,11 @@ func (m *Module) Run(vm pkg.VM) (pkg.MachineInfo, error) {
 		}
 	}
 
+	vsock, err := m.makeVsock(&vm)
+	if err != nil {
+		return pkg.MachineInfo{}, err
+	}
+
 	nics, err := m.makeNetwork(ctx, &vm, &cfg)
 	if err != nil {
 		return pkg.MachineInfo{}, err
@@ -545,12 +591,18 @@ func (m *Module) Run(vm pkg.VM) (pkg.MachineInfo, error) {
 			CPU:       CPU(vm.CPU),
 			Mem:       MemMib(vm.Memory / gridtypes.Megabyte),
 			HTEnabled: false,
+			MaxCPU:    CPU(vm.MaxCPU),
+			MaxMem:    MemMib(vm.MaxMemory / gridtypes.Megabyte),
 		},
 		FS:          fs,
 		Interfaces:  nics,
 		Disks:       disks,
 		Devices:     vm.Devices,
 		NoKeepAlive: vm.NoKeepAlive,
+		Watchdog:    vm.Watchdog,
+		BaseImage:   vm.BaseImage,
+		Vsock:       vsock,
+		OOMPriority: vm.OOMPriority,
 		NetworkInfo: &vm.Network,
 	}
 
@@ -623,6 +675,53 @@ func (m *Module) LogsFull(name string) (string, error) {
 	return string(b), nil
 }
 
+// LogsRange returns a chunk of the machine logs starting at offset. The length
+// is capped to maxLogsChunk so large logs have to be fetched in multiple calls.
+func (m *Module) LogsRange(name string, offset, length int64) (pkg.LogsChunk, error) {
+	if offset < 0 || length < 0 {
+		return pkg.LogsChunk{}, fmt.Errorf("offset and length must be positive")
+	}
+
+	if length == 0 || length > maxLogsChunk {
+		length = maxLogsChunk
+	}
+
+	path := m.logsPath(name)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return pkg.LogsChunk{}, nil
+	} else if err != nil {
+		return pkg.LogsChunk{}, errors.Wrapf(err, "failed to open logs file: %s", path)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return pkg.LogsChunk{}, errors.Wrapf(err, "fail to stat %s", f.Name())
+	}
+
+	size := info.Size()
+	if offset >= size {
+		return pkg.LogsChunk{Offset: size, Size: size}, nil
+	}
+
+	if offset+length > size {
+		length = size - offset
+	}
+
+	buf := make([]byte, length)
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return pkg.LogsChunk{}, errors.Wrapf(err, "failed to read logs from: %s", path)
+	}
+
+	return pkg.LogsChunk{
+		Data:   string(buf[:n]),
+		Offset: offset,
+		Size:   size,
+	}, nil
+}
+
 // Inspect a machine by name
 func (m *Module) Inspect(name string) (pkg.VMInfo, error) {
 	if !m.Exists(name) {
@@ -646,11 +745,18 @@ func (m *Module) removeConfig(name string) {
 		return
 	}
 
+	if machine, err := MachineFromFile(m.configPath(name)); err == nil {
+		machine.removeVsock()
+		m.forgetFs(machine)
+	}
+
 	_ = os.Remove(m.configPath(name))
 
 	_ = os.Remove(m.cloudInitImage(name))
 
 	_ = os.Remove(m.logsPath(name))
+
+	_ = os.Remove(m.retentionPath(name))
 }
 
 // Delete deletes a machine by name (id)
@@ -717,18 +823,31 @@ func (m *Module) Delete(name string) error {
 	return nil
 }
 
+// Lock pauses (or resumes) a running machine, a paused machine keeps its
+// memory and resumes where it stopped. The paused state is saved with the
+// machine config.
 func (m *Module) Lock(name string, lock bool) error {
 	// todo: should we do locking here?
 	if !m.Exists(name) {
 		return fmt.Errorf("machine '%s' does not exist", name)
 	}
 
-	ctx := context.Background()
+	machine, err := MachineFromFile(m.configPath(name))
+	if err != nil {
+		return err
+	}
 
-	client := NewClient(m.socketPath(name))
+	ctx := context.Background()
+	socket := m.socketPath(name)
 	if lock {
-		return client.Pause(ctx)
+		err = machine.Pause(ctx, socket)
 	} else {
-		return client.Resume(ctx)
+		err = machine.Resume(ctx, socket)
+	}
+
+	if err != nil {
+		return err
 	}
+
+	return machine.Save(m.configPath(name))
 }
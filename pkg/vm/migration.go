@@ -0,0 +1,111 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+const (
+	// migrationConfig is the machine config file inside an export directory
+	migrationConfig = "machine.json"
+	// snapshotTimeout is how long writing the machine state can take, it
+	// grows with the machine memory
+	snapshotTimeout = 10 * time.Minute
+)
+
+// Export pauses the machine and writes a snapshot of its state, together
+// with the machine config, to dir. Disks are not part of the export, they
+// need to be copied to the target node by the caller.
+//
+// On success the machine is left paused so its state does not diverge from
+// the snapshot. It must be deleted once it runs on the target node, or
+// resumed (with Lock) if the migration is aborted.
+func (m *Module) Export(name string, dir string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.Exists(name) {
+		return fmt.Errorf("machine '%s' does not exist", name)
+	}
+
+	machine, err := MachineFromFile(m.configPath(name))
+	if err != nil {
+		return errors.Wrapf(err, "failed to load machine '%s' config", name)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create export directory")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+
+	client := NewClient(m.socketPath(name))
+	if err := client.Pause(ctx); err != nil {
+		return errors.Wrapf(err, "failed to pause machine '%s'", name)
+	}
+
+	err = client.Snapshot(ctx, fmt.Sprintf("file://%s", dir))
+	if err == nil {
+		err = machine.Save(filepath.Join(dir, migrationConfig))
+	}
+
+	if err != nil {
+		if err := client.Resume(context.Background()); err != nil {
+			log.Error().Err(err).Str("vm-id", name).Msg("failed to resume machine after failed export")
+		}
+
+		return errors.Wrapf(err, "failed to export machine '%s'", name)
+	}
+
+	log.Info().Str("vm-id", name).Str("dir", dir).Msg("machine exported")
+	return nil
+}
+
+// Import restores a machine exported with Export from dir and resumes it.
+// The machine disks must already exist at the same paths, and the network
+// (tap devices) must be prepared on this node by the network module before
+// the import. Nothing is left running if the import fails.
+func (m *Module) Import(name string, dir string) (pkg.MachineInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.Exists(name) {
+		return pkg.MachineInfo{}, fmt.Errorf("a vm with same name already exists")
+	}
+
+	machine, err := MachineFromFile(filepath.Join(dir, migrationConfig))
+	if err != nil {
+		return pkg.MachineInfo{}, errors.Wrap(err, "failed to load exported machine config")
+	}
+
+	if machine.ID != name {
+		return pkg.MachineInfo{}, fmt.Errorf("exported machine is '%s' not '%s'", machine.ID, name)
+	}
+
+	for _, disk := range machine.Disks {
+		if _, err := os.Stat(disk.Path); err != nil {
+			return pkg.MachineInfo{}, errors.Wrapf(err, "disk '%s' is not available", disk.Path)
+		}
+	}
+
+	if err := machine.Save(m.configPath(name)); err != nil {
+		return pkg.MachineInfo{}, err
+	}
+
+	info, err := machine.Restore(context.Background(), m.socketPath(name), m.logsPath(name), dir)
+	if err != nil {
+		m.removeConfig(name)
+		return pkg.MachineInfo{}, m.withLogs(m.logsPath(name), err)
+	}
+
+	log.Info().Str("vm-id", name).Str("dir", dir).Msg("machine imported")
+	return info, nil
+}
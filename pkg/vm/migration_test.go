@@ -0,0 +1,87 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+// fakeRestore replaces the restore process helpers, the cloud-hypervisor
+// process serves fake on its api socket. It returns the killed pids.
+func fakeRestore(t *testing.T, fake *fakeCH, tapErr error) *[]int {
+	var killed []int
+	spawn, wait, check, kill, fs := spawnCH, waitMachine, checkTap, killProcess, startFs
+	spawnCH = func(ctx context.Context, m *Machine, logs string, args ...string) (int, error) {
+		serveCH(t, fake, args[1])
+		return 1000, nil
+	}
+	waitMachine = func(ctx context.Context, m *Machine, socket string) error {
+		return nil
+	}
+	checkTap = func(nic *Interface) error {
+		return tapErr
+	}
+	killProcess = func(pid int) {
+		killed = append(killed, pid)
+	}
+	startFs = func(machine *Machine, socket, path string) (int, error) {
+		return 100, nil
+	}
+	t.Cleanup(func() {
+		spawnCH, waitMachine, checkTap, killProcess, startFs = spawn, wait, check, kill, fs
+	})
+
+	return &killed
+}
+
+func testRestoreMachine() Machine {
+	return Machine{
+		ID:         "vm",
+		FS:         []VirtioFS{{Tag: "data", Path: "/data"}},
+		Interfaces: []Interface{{ID: "eth0", Tap: "t-vm"}},
+	}
+}
+
+func TestRestore(t *testing.T) {
+	fake := &fakeCH{cpu: 1, max: 1, memory: 1024}
+	killed := fakeRestore(t, fake, nil)
+
+	machine := testRestoreMachine()
+	dir := t.TempDir()
+	info, err := machine.Restore(context.Background(), filepath.Join(dir, "ch.sock"), filepath.Join(dir, "logs"), dir)
+	require.NoError(t, err)
+	require.Equal(t, pkg.MachineInfo{}, info)
+	require.Equal(t, []string{"vm.resume"}, fake.actions)
+	require.Empty(t, *killed)
+}
+
+func TestRestoreResumeFailed(t *testing.T) {
+	fake := &fakeCH{failResume: true}
+	killed := fakeRestore(t, fake, nil)
+
+	machine := testRestoreMachine()
+	dir := t.TempDir()
+	_, err := machine.Restore(context.Background(), filepath.Join(dir, "ch.sock"), filepath.Join(dir, "logs"), dir)
+	require.Error(t, err)
+
+	// the virtiofsd and cloud-hypervisor processes are killed
+	require.Equal(t, []int{100, 1000}, *killed)
+}
+
+func TestRestoreTapNotReady(t *testing.T) {
+	fake := &fakeCH{}
+	killed := fakeRestore(t, fake, fmt.Errorf("link not found"))
+
+	machine := testRestoreMachine()
+	dir := t.TempDir()
+	_, err := machine.Restore(context.Background(), filepath.Join(dir, "ch.sock"), filepath.Join(dir, "logs"), dir)
+	require.ErrorContains(t, err, "t-vm")
+
+	// nothing is started
+	require.Empty(t, *killed)
+	require.Empty(t, fake.actions)
+}
@@ -31,8 +31,8 @@ var (
 	permanent = struct{}{}
 
 	rotator = rotate.NewRotator(
-		rotate.MaxSize(8*rotate.Megabytes),
-		rotate.TailSize(4*rotate.Megabytes),
+		rotate.MaxSize(defaultLogsMaxSize),
+		rotate.TailSize(defaultLogsTailSize),
 	)
 )
 
@@ -48,7 +48,12 @@ func (m *Module) logrotate(ctx context.Context) error {
 		names = append(names, name)
 	}
 
-	return rotator.RotateAll(filepath.Join(m.root, logsDir), names...)
+	overrides, err := m.retentionOverrides(names)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load vms logs retention, using default")
+	}
+
+	return rotator.RotateAllWith(filepath.Join(m.root, logsDir), overrides, names...)
 }
 
 // Monitor start vms  monitoring
@@ -172,7 +177,10 @@ func (m *Module) monitorID(ctx context.Context, running map[string]Process, id s
 			log.Debug().Str("name", id).Msg("deleting running vm with no active workload")
 			m.removeConfig(id)
 			_ = syscall.Kill(ps.Pid, syscall.SIGKILL)
+			return nil
 		}
+
+		m.recoverFs(ctx, id)
 		return nil
 	}
 
@@ -216,9 +224,20 @@ func (m *Module) monitorID(ctx context.Context, running map[string]Process, id s
 			return nil
 		}
 
+		// the vm is down, this is the only safe point to move it to
+		// an updated base image.
+		if err := m.refreshBaseImage(ctx, vm); err != nil {
+			log.Error().Err(err).Msg("failed to refresh vm base image, restarting with current image")
+		}
+
 		log.Debug().Str("name", id).Msg("trying to restart the vm")
 		if _, err = vm.Run(ctx, m.socketPath(id), m.logsPath(id)); err != nil {
 			reason = m.withLogs(m.logsPath(id), err)
+		} else if vm.Paused {
+			// the workload is still paused, the restarted vm must be too
+			if err := vm.Pause(ctx, m.socketPath(id)); err != nil {
+				log.Error().Err(err).Msg("failed to pause restarted vm")
+			}
 		}
 	} else {
 		reason = fmt.Errorf("deleting vm due to so many crashes")
@@ -0,0 +1,28 @@
+package vm
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Pause pauses the running machine behind the api socket, the machine keeps
+// its memory and can be resumed instantly
+func (m *Machine) Pause(ctx context.Context, socket string) error {
+	if err := NewClient(socket).Pause(ctx); err != nil {
+		return errors.Wrapf(err, "failed to pause machine '%s'", m.ID)
+	}
+
+	m.Paused = true
+	return nil
+}
+
+// Resume resumes the paused machine behind the api socket
+func (m *Machine) Resume(ctx context.Context, socket string) error {
+	if err := NewClient(socket).Resume(ctx); err != nil {
+		return errors.Wrapf(err, "failed to resume machine '%s'", m.ID)
+	}
+
+	m.Paused = false
+	return nil
+}
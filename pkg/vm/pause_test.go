@@ -0,0 +1,33 @@
+package vm
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseResume(t *testing.T) {
+	fake := &fakeCH{}
+	socket := testCH(t, fake)
+
+	machine := Machine{ID: "vm"}
+	require.NoError(t, machine.Pause(context.Background(), socket))
+	require.True(t, machine.Paused)
+
+	require.NoError(t, machine.Resume(context.Background(), socket))
+	require.False(t, machine.Paused)
+
+	require.Equal(t, []string{"vm.pause", "vm.resume"}, fake.actions)
+}
+
+func TestPauseFailed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	machine := Machine{ID: "vm"}
+	err := machine.Pause(ctx, filepath.Join(t.TempDir(), "missing.sock"))
+	require.Error(t, err)
+	require.False(t, machine.Paused)
+}
@@ -0,0 +1,149 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+// cpus is the cloud-hypervisor --cpus value of the machine
+func (c *Config) cpus() string {
+	if c.MaxCPU > c.CPU {
+		return fmt.Sprintf("%s,max=%d", c.CPU, c.MaxCPU)
+	}
+
+	return c.CPU.String()
+}
+
+// memory is the cloud-hypervisor --memory value of the machine
+func (c *Config) memory() string {
+	memory := fmt.Sprintf("%s,shared=on", c.Mem)
+	if c.MaxMem > c.Mem {
+		memory += fmt.Sprintf(",hotplug_size=%dM", c.MaxMem-c.Mem)
+	}
+
+	return memory
+}
+
+// maxCPU is the max number of vcpus the machine can be grown to
+func (c *Config) maxCPU() CPU {
+	if c.MaxCPU > c.CPU {
+		return c.MaxCPU
+	}
+
+	return c.CPU
+}
+
+// maxMem is the max memory the machine can be grown to
+func (c *Config) maxMem() MemMib {
+	if c.MaxMem > c.Mem {
+		return c.MaxMem
+	}
+
+	return c.Mem
+}
+
+// ResizeCPU hot plugs vcpus to the running machine behind the api socket. The
+// machine can only grow up to its configured max cpu.
+func (m *Machine) ResizeCPU(ctx context.Context, socket string, vcpus uint8) error {
+	client := NewClient(socket)
+	data, err := client.Inspect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to inspect machine")
+	}
+
+	if CPU(vcpus) < data.CPU {
+		return fmt.Errorf("can't shrink machine cpu from %d to %d", data.CPU, vcpus)
+	}
+
+	if max := m.Config.maxCPU(); CPU(vcpus) > max {
+		return fmt.Errorf("machine cpu can't be grown to %d, max is %d", vcpus, max)
+	}
+
+	if CPU(vcpus) == data.CPU {
+		return nil
+	}
+
+	if err := client.Resize(ctx, ResizeRequest{DesiredVCPUs: vcpus}); err != nil {
+		return errors.Wrap(err, "failed to resize machine cpu")
+	}
+
+	m.Config.CPU = CPU(vcpus)
+	return nil
+}
+
+// ResizeMemory hot plugs memory to the running machine behind the api socket so
+// it has the given size in bytes. The machine can only grow up to its configured
+// max memory.
+func (m *Machine) ResizeMemory(ctx context.Context, socket string, bytes uint64) error {
+	if bytes%uint64(gridtypes.Megabyte) != 0 {
+		return fmt.Errorf("machine memory must be a multiple of 1MiB")
+	}
+
+	client := NewClient(socket)
+	data, err := client.Inspect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to inspect machine")
+	}
+
+	memory := MemMib(bytes / uint64(gridtypes.Megabyte))
+	if memory < data.Memory {
+		return fmt.Errorf("can't shrink machine memory from %dM to %dM", data.Memory, memory)
+	}
+
+	if max := m.Config.maxMem(); memory > max {
+		return fmt.Errorf("machine memory can't be grown to %dM, max is %dM", memory, max)
+	}
+
+	if memory == data.Memory {
+		return nil
+	}
+
+	if err := client.Resize(ctx, ResizeRequest{DesiredRAM: bytes}); err != nil {
+		return errors.Wrap(err, "failed to resize machine memory")
+	}
+
+	m.Config.Mem = memory
+	return nil
+}
+
+// Resize grows the cpu and memory of a running machine, the new size is
+// saved so the machine keeps it if it's restarted.
+func (m *Module) Resize(name string, cpu uint8, memory gridtypes.Unit) error {
+	if !m.Exists(name) {
+		return fmt.Errorf("machine '%s' does not exist", name)
+	}
+
+	machine, err := MachineFromFile(m.configPath(name))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	socket := m.socketPath(name)
+
+	// the cpu is kept even if the memory resize fails
+	defer func() {
+		if err := machine.Save(m.configPath(name)); err != nil {
+			log.Error().Err(err).Str("vm-id", name).Msg("failed to save resized machine config")
+		}
+	}()
+
+	if cpu != 0 {
+		if err := machine.ResizeCPU(ctx, socket, cpu); err != nil {
+			return err
+		}
+	}
+
+	if memory != 0 {
+		if err := machine.ResizeMemory(ctx, socket, uint64(memory)); err != nil {
+			return err
+		}
+	}
+
+	log.Info().Str("vm-id", name).Uint8("cpu", uint8(machine.Config.CPU)).Uint64("memory", uint64(machine.Config.Mem)).Msg("machine resized")
+	return nil
+}
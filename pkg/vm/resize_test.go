@@ -0,0 +1,182 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+// fakeCH is a cloud-hypervisor api serving vm.info and vm.counters, and
+// recording the vm.resize, vm.pause, vm.resume, vm.power-button, vm.add-fs
+// and vm.remove-device requests
+type fakeCH struct {
+	m       sync.Mutex
+	cpu     uint8
+	max     uint8
+	memory  int64
+	resizes []string
+	// actions are the pause, resume and power-button calls in order
+	actions []string
+	// powerButton is called when the power button is pressed
+	powerButton func()
+	// counters is the vm.counters response, the endpoint is not found if
+	// it's not set
+	counters string
+	// fs are the plugged virtio-fs devices
+	fs []FsDevice
+	// failAddFs is the number of vm.add-fs requests to fail
+	failAddFs int
+	// failResume fails the vm.resume requests
+	failResume bool
+}
+
+func (f *fakeCH) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	switch r.URL.Path {
+	case "/api/v1/vm.info":
+		fs, _ := json.Marshal(f.fs)
+		fmt.Fprintf(w, `{"config": {"cpus": {"boot_vcpus": %d, "max_vcpus": %d}, "memory": {"size": %d}, "fs": %s}}`, f.cpu, f.max, f.memory, fs)
+	case "/api/v1/vm.counters":
+		if len(f.counters) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, f.counters)
+	case "/api/v1/vm.pause", "/api/v1/vm.resume":
+		f.actions = append(f.actions, strings.TrimPrefix(r.URL.Path, "/api/v1/"))
+		if f.failResume && r.URL.Path == "/api/v1/vm.resume" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "/api/v1/vm.power-button":
+		f.actions = append(f.actions, "vm.power-button")
+		if f.powerButton != nil {
+			f.powerButton()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "/api/v1/vm.add-fs":
+		f.actions = append(f.actions, "vm.add-fs")
+		if f.failAddFs > 0 {
+			f.failAddFs--
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var device FsDevice
+		_ = json.NewDecoder(r.Body).Decode(&device)
+		if len(device.ID) == 0 {
+			device.ID = fmt.Sprintf("_fs%d", len(f.actions))
+		}
+		f.fs = append(f.fs, device)
+		w.WriteHeader(http.StatusNoContent)
+	case "/api/v1/vm.remove-device":
+		f.actions = append(f.actions, "vm.remove-device")
+		var device struct {
+			ID string `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&device)
+		for i := range f.fs {
+			if f.fs[i].ID == device.ID {
+				f.fs = append(f.fs[:i], f.fs[i+1:]...)
+				break
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "/api/v1/vm.resize":
+		body, _ := io.ReadAll(r.Body)
+		f.resizes = append(f.resizes, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func testCH(t *testing.T, fake *fakeCH) string {
+	socket := filepath.Join(t.TempDir(), "ch.sock")
+	serveCH(t, fake, socket)
+	return socket
+}
+
+// serveCH serves the fake api on the socket
+func serveCH(t *testing.T, fake *fakeCH, socket string) {
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(fake)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+}
+
+func TestConfigHotplugArgs(t *testing.T) {
+	config := Config{CPU: 2, Mem: 1024}
+	require.Equal(t, "boot=2", config.cpus())
+	require.Equal(t, "size=1024M,shared=on", config.memory())
+
+	config = Config{CPU: 2, Mem: 1024, MaxCPU: 4, MaxMem: 4096}
+	require.Equal(t, "boot=2,max=4", config.cpus())
+	require.Equal(t, "size=1024M,shared=on,hotplug_size=3072M", config.memory())
+}
+
+func TestResizeCPU(t *testing.T) {
+	fake := &fakeCH{cpu: 2, max: 4, memory: int64(gridtypes.Gigabyte)}
+	socket := testCH(t, fake)
+
+	machine := Machine{Config: Config{CPU: 2, MaxCPU: 4, Mem: 1024}}
+	require.NoError(t, machine.ResizeCPU(context.Background(), socket, 4))
+	require.Equal(t, CPU(4), machine.Config.CPU)
+	require.Len(t, fake.resizes, 1)
+	require.JSONEq(t, `{"desired_vcpus": 4}`, fake.resizes[0])
+
+	// can't grow over the max or shrink
+	require.Error(t, machine.ResizeCPU(context.Background(), socket, 5))
+	require.Error(t, machine.ResizeCPU(context.Background(), socket, 1))
+	// same size is a noop
+	require.NoError(t, machine.ResizeCPU(context.Background(), socket, 2))
+	require.Len(t, fake.resizes, 1)
+}
+
+func TestResizeMemory(t *testing.T) {
+	fake := &fakeCH{cpu: 2, max: 2, memory: int64(gridtypes.Gigabyte)}
+	socket := testCH(t, fake)
+
+	machine := Machine{Config: Config{CPU: 2, Mem: 1024, MaxMem: 4096}}
+	size := uint64(2 * gridtypes.Gigabyte)
+	require.NoError(t, machine.ResizeMemory(context.Background(), socket, size))
+	require.Equal(t, MemMib(2048), machine.Config.Mem)
+	require.Len(t, fake.resizes, 1)
+
+	var request ResizeRequest
+	require.NoError(t, json.Unmarshal([]byte(fake.resizes[0]), &request))
+	require.Equal(t, ResizeRequest{DesiredRAM: size}, request)
+	require.JSONEq(t, fmt.Sprintf(`{"desired_ram": %d}`, size), fake.resizes[0])
+
+	require.Error(t, machine.ResizeMemory(context.Background(), socket, uint64(8*gridtypes.Gigabyte)))
+	require.Error(t, machine.ResizeMemory(context.Background(), socket, uint64(512*gridtypes.Megabyte)))
+	require.Error(t, machine.ResizeMemory(context.Background(), socket, size+1))
+	require.Len(t, fake.resizes, 1)
+}
+
+func TestResizeNoMax(t *testing.T) {
+	fake := &fakeCH{cpu: 2, max: 2, memory: int64(gridtypes.Gigabyte)}
+	socket := testCH(t, fake)
+
+	// machines without a max can't grow
+	machine := Machine{Config: Config{CPU: 2, Mem: 1024}}
+	require.Error(t, machine.ResizeCPU(context.Background(), socket, 3))
+	require.Error(t, machine.ResizeMemory(context.Background(), socket, uint64(2*gridtypes.Gigabyte)))
+	require.Empty(t, fake.resizes)
+}
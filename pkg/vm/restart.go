@@ -0,0 +1,75 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+// killWait is how long to wait for a killed machine process to exit
+const killWait = 10 * time.Second
+
+// startMachine boots the machine behind the api socket
+var startMachine = func(ctx context.Context, m *Machine, socket, logs string) (pkg.MachineInfo, error) {
+	return m.Run(ctx, socket, logs)
+}
+
+// killMachine kills the machine process if it's still running
+var killMachine = func(name string) {
+	if ps, err := Find(name); err == nil {
+		_ = syscall.Kill(ps.Pid, syscall.SIGKILL)
+	}
+}
+
+// Restart shuts down the machine behind the api socket and boots it again,
+// the machine is killed if it doesn't power off in time. A paused machine is
+// paused again once it's up.
+func (m *Machine) Restart(ctx context.Context, socket, logs string, timeout time.Duration) error {
+	if err := m.Shutdown(ctx, socket, timeout); err != nil {
+		log.Warn().Err(err).Str("vm-id", m.ID).Msg("machine did not shutdown, killing it")
+		killMachine(m.ID)
+	}
+
+	deadline := time.Now().Add(killWait)
+	for machineRunning(m.ID) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("machine '%s' is still running", m.ID)
+		}
+		<-time.After(shutdownPollInterval)
+	}
+
+	if _, err := startMachine(ctx, m, socket, logs); err != nil {
+		return err
+	}
+
+	if m.Paused {
+		return m.Pause(ctx, socket)
+	}
+
+	return nil
+}
+
+// Restart restarts a running machine from its saved config, the machine
+// disks, rootfs and network are kept as is.
+func (m *Module) Restart(name string, timeout time.Duration) error {
+	// the monitor must not revive (or delete) the machine while it's down
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !machineRunning(name) {
+		return fmt.Errorf("machine '%s' does not exist", name)
+	}
+
+	machine, err := MachineFromFile(m.configPath(name))
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("vm-id", name).Msg("restarting vm")
+	err = machine.Restart(context.Background(), m.socketPath(name), m.logsPath(name), timeout)
+	return m.withLogs(m.logsPath(name), err)
+}
@@ -0,0 +1,64 @@
+package vm
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+)
+
+// testRestart fakes the machine process, the returned slice records the
+// kill and start calls
+func testRestart(t *testing.T, running *atomic.Bool) *[]string {
+	testMachineRunning(t, running)
+
+	var calls []string
+	oldStart, oldKill := startMachine, killMachine
+	startMachine = func(ctx context.Context, m *Machine, socket, logs string) (pkg.MachineInfo, error) {
+		calls = append(calls, "start "+m.ID)
+		running.Store(true)
+		return pkg.MachineInfo{}, nil
+	}
+	killMachine = func(name string) {
+		calls = append(calls, "kill "+name)
+		running.Store(false)
+	}
+	t.Cleanup(func() {
+		startMachine, killMachine = oldStart, oldKill
+	})
+
+	return &calls
+}
+
+func TestRestartMachine(t *testing.T) {
+	var running atomic.Bool
+	running.Store(true)
+	calls := testRestart(t, &running)
+
+	fake := &fakeCH{powerButton: func() { running.Store(false) }}
+	socket := testCH(t, fake)
+
+	machine := Machine{ID: "vm"}
+	require.NoError(t, machine.Restart(context.Background(), socket, "", time.Second))
+	require.Equal(t, []string{"vm.power-button"}, fake.actions)
+	require.Equal(t, []string{"start vm"}, *calls)
+	require.True(t, running.Load())
+}
+
+func TestRestartMachineKilled(t *testing.T) {
+	// the guest ignores the power button, and stays paused once restarted
+	var running atomic.Bool
+	running.Store(true)
+	calls := testRestart(t, &running)
+
+	fake := &fakeCH{}
+	socket := testCH(t, fake)
+
+	machine := Machine{ID: "vm", Paused: true}
+	require.NoError(t, machine.Restart(context.Background(), socket, "", time.Second))
+	require.Equal(t, []string{"vm.power-button", "vm.pause"}, fake.actions)
+	require.Equal(t, []string{"kill vm", "start vm"}, *calls)
+}
@@ -0,0 +1,151 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/rotate"
+)
+
+const (
+	defaultLogsMaxSize  = 8 * rotate.Megabytes
+	defaultLogsTailSize = 4 * rotate.Megabytes
+
+	// minLogsMaxSize is the smallest max size a vm logs retention can have
+	minLogsMaxSize = 1 * rotate.Megabytes
+	// maxLogsMaxSize is the biggest max size a vm logs retention can have
+	maxLogsMaxSize = 1 * rotate.Gigabyte
+)
+
+func defaultRetention() pkg.LogsRetention {
+	return pkg.LogsRetention{
+		MaxSize:  uint64(defaultLogsMaxSize),
+		TailSize: uint64(defaultLogsTailSize),
+	}
+}
+
+func validateRetention(retention pkg.LogsRetention) error {
+	if retention.MaxSize < uint64(minLogsMaxSize) || retention.MaxSize > uint64(maxLogsMaxSize) {
+		return fmt.Errorf("logs max size must be between %d and %d bytes", minLogsMaxSize, maxLogsMaxSize)
+	}
+
+	if retention.TailSize > retention.MaxSize {
+		return fmt.Errorf("logs tail size can't be bigger than max size")
+	}
+
+	return nil
+}
+
+// loadRetention returns the custom logs retention of the vm, ok is false
+// if the vm uses the default retention
+func (m *Module) loadRetention(name string) (retention pkg.LogsRetention, ok bool, err error) {
+	data, err := os.ReadFile(m.retentionPath(name))
+	if os.IsNotExist(err) {
+		return defaultRetention(), false, nil
+	} else if err != nil {
+		return retention, false, errors.Wrapf(err, "failed to read logs retention of '%s'", name)
+	}
+
+	if err := json.Unmarshal(data, &retention); err != nil {
+		return retention, false, errors.Wrapf(err, "failed to decode logs retention of '%s'", name)
+	}
+
+	return retention, true, nil
+}
+
+// retentionOverrides returns a rotator for each of the named vms that has
+// a custom logs retention
+func (m *Module) retentionOverrides(names []string) (map[string]rotate.Rotator, error) {
+	overrides := make(map[string]rotate.Rotator)
+	for _, name := range names {
+		retention, ok, err := m.loadRetention(name)
+		if err != nil {
+			return overrides, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		overrides[name] = rotate.NewRotator(
+			rotate.MaxSize(rotate.Size(retention.MaxSize)),
+			rotate.TailSize(rotate.Size(retention.TailSize)),
+		)
+	}
+
+	return overrides, nil
+}
+
+// LogsInfo returns the log files of the machine and their retention
+func (m *Module) LogsInfo(name string) (pkg.LogsInfo, error) {
+	if !m.Exists(name) {
+		return pkg.LogsInfo{}, fmt.Errorf("machine '%s' does not exist", name)
+	}
+
+	return m.logsInfo(name)
+}
+
+func (m *Module) logsInfo(name string) (pkg.LogsInfo, error) {
+	retention, custom, err := m.loadRetention(name)
+	if err != nil {
+		return pkg.LogsInfo{}, err
+	}
+
+	info := pkg.LogsInfo{
+		Path:      m.logsPath(name),
+		TailPath:  rotator.TailPath(m.logsPath(name)),
+		Retention: retention,
+		Custom:    custom,
+	}
+
+	if stat, err := os.Stat(info.Path); err == nil {
+		info.Size = stat.Size()
+	}
+
+	if stat, err := os.Stat(info.TailPath); err == nil {
+		info.TailSize = stat.Size()
+	}
+
+	return info, nil
+}
+
+// SetLogsRetention sets the logs retention of the machine. The zero value
+// restores the default retention. It's applied on the next logs rotation.
+func (m *Module) SetLogsRetention(name string, retention pkg.LogsRetention) error {
+	if !m.Exists(name) {
+		return fmt.Errorf("machine '%s' does not exist", name)
+	}
+
+	return m.setLogsRetention(name, retention)
+}
+
+func (m *Module) setLogsRetention(name string, retention pkg.LogsRetention) error {
+	if retention == (pkg.LogsRetention{}) {
+		if err := os.Remove(m.retentionPath(name)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to reset logs retention of '%s'", name)
+		}
+
+		log.Info().Str("vm-id", name).Msg("logs retention reset to default")
+		return nil
+	}
+
+	if err := validateRetention(retention); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(retention)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(m.retentionPath(name), data, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write logs retention of '%s'", name)
+	}
+
+	log.Info().Str("vm-id", name).Uint64("max-size", retention.MaxSize).Uint64("tail-size", retention.TailSize).Msg("logs retention updated")
+	return nil
+}
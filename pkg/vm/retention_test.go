@@ -0,0 +1,75 @@
+package vm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/rotate"
+)
+
+func testRetentionModule(t *testing.T) *Module {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, logsDir), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, retentionDir), 0755))
+
+	return &Module{root: root}
+}
+
+func TestValidateRetention(t *testing.T) {
+	require.NoError(t, validateRetention(defaultRetention()))
+	require.NoError(t, validateRetention(pkg.LogsRetention{MaxSize: uint64(minLogsMaxSize)}))
+
+	require.Error(t, validateRetention(pkg.LogsRetention{MaxSize: uint64(minLogsMaxSize) - 1}))
+	require.Error(t, validateRetention(pkg.LogsRetention{MaxSize: uint64(maxLogsMaxSize) + 1}))
+	require.Error(t, validateRetention(pkg.LogsRetention{
+		MaxSize:  uint64(2 * rotate.Megabytes),
+		TailSize: uint64(4 * rotate.Megabytes),
+	}))
+}
+
+func TestLogsRetention(t *testing.T) {
+	m := testRetentionModule(t)
+
+	info, err := m.logsInfo("vm")
+	require.NoError(t, err)
+	require.Equal(t, defaultRetention(), info.Retention)
+	require.False(t, info.Custom)
+	require.Equal(t, m.logsPath("vm"), info.Path)
+	require.Zero(t, info.Size)
+
+	overrides, err := m.retentionOverrides([]string{"vm"})
+	require.NoError(t, err)
+	require.Empty(t, overrides)
+
+	custom := pkg.LogsRetention{
+		MaxSize:  uint64(16 * rotate.Megabytes),
+		TailSize: uint64(2 * rotate.Megabytes),
+	}
+	require.NoError(t, m.setLogsRetention("vm", custom))
+	require.Error(t, m.setLogsRetention("vm", pkg.LogsRetention{MaxSize: 1}))
+
+	require.NoError(t, os.WriteFile(m.logsPath("vm"), []byte("booting"), 0644))
+
+	info, err = m.logsInfo("vm")
+	require.NoError(t, err)
+	require.Equal(t, custom, info.Retention)
+	require.True(t, info.Custom)
+	require.EqualValues(t, len("booting"), info.Size)
+
+	overrides, err = m.retentionOverrides([]string{"vm", "other"})
+	require.NoError(t, err)
+	require.Len(t, overrides, 1)
+	require.Contains(t, overrides, "vm")
+
+	// the zero value restores the default
+	require.NoError(t, m.setLogsRetention("vm", pkg.LogsRetention{}))
+	require.NoError(t, m.setLogsRetention("vm", pkg.LogsRetention{}))
+
+	info, err = m.logsInfo("vm")
+	require.NoError(t, err)
+	require.Equal(t, defaultRetention(), info.Retention)
+	require.False(t, info.Custom)
+}
@@ -0,0 +1,61 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const shutdownPollInterval = 500 * time.Millisecond
+
+// ErrShutdownTimeout is returned if the guest didn't power off in time
+var ErrShutdownTimeout = fmt.Errorf("timeout waiting for machine to shutdown")
+
+// machineRunning checks if the machine process is still alive
+var machineRunning = func(name string) bool {
+	_, err := Find(name)
+	return err == nil
+}
+
+// Shutdown presses the ACPI power button of the machine behind the api socket
+// and waits up to timeout for the machine process to exit, so the guest can
+// sync and unmount its filesystems. ErrShutdownTimeout is returned if the
+// machine is still running after the timeout, it's up to the caller to kill it.
+func (m *Machine) Shutdown(ctx context.Context, socket string, timeout time.Duration) error {
+	if err := NewClient(socket).PowerButton(ctx); err != nil {
+		return errors.Wrapf(err, "failed to shutdown machine '%s'", m.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for machineRunning(m.ID) {
+		select {
+		case <-ctx.Done():
+			return ErrShutdownTimeout
+		case <-time.After(shutdownPollInterval):
+		}
+	}
+
+	return nil
+}
+
+// Shutdown gracefully shuts down a running machine, the machine config is
+// kept so it must still be deleted with Delete, which also kills the machine
+// if it didn't shutdown in time.
+func (m *Module) Shutdown(name string, timeout time.Duration) error {
+	if !m.Exists(name) {
+		return fmt.Errorf("machine '%s' does not exist", name)
+	}
+
+	// the machine must not be restarted by the monitor once it's down
+	m.failures.Set(name, permanent, cache.NoExpiration)
+
+	log.Info().Str("vm-id", name).Dur("timeout", timeout).Msg("shutting vm down [acpi]")
+	machine := Machine{ID: name}
+	return machine.Shutdown(context.Background(), m.socketPath(name), timeout)
+}
@@ -0,0 +1,54 @@
+package vm
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testMachineRunning(t *testing.T, running *atomic.Bool) {
+	old := machineRunning
+	machineRunning = func(name string) bool {
+		return running.Load()
+	}
+	t.Cleanup(func() {
+		machineRunning = old
+	})
+}
+
+func TestShutdown(t *testing.T) {
+	var running atomic.Bool
+	running.Store(true)
+	testMachineRunning(t, &running)
+
+	// the guest powers off once the button is pressed
+	fake := &fakeCH{powerButton: func() {
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			running.Store(false)
+		}()
+	}}
+	socket := testCH(t, fake)
+
+	machine := Machine{ID: "vm"}
+	require.NoError(t, machine.Shutdown(context.Background(), socket, 5*time.Second))
+	require.Equal(t, []string{"vm.power-button"}, fake.actions)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	// the guest ignores the power button and must be killed
+	var running atomic.Bool
+	running.Store(true)
+	testMachineRunning(t, &running)
+
+	fake := &fakeCH{}
+	socket := testCH(t, fake)
+
+	machine := Machine{ID: "vm"}
+	err := machine.Shutdown(context.Background(), socket, time.Second)
+	require.ErrorIs(t, err, ErrShutdownTimeout)
+	require.Equal(t, []string{"vm.power-button"}, fake.actions)
+}
@@ -0,0 +1,165 @@
+package vm
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// fsMissingTicks is the number of monitor ticks a virtiofsd must be
+	// missing before it's restarted, a single scan can miss a daemon
+	fsMissingTicks = 3
+	// fsMaxRestarts is the max number of times the daemon of a share is
+	// restarted, after that the share is left alone
+	fsMaxRestarts = 5
+)
+
+// findFs and startFs are the virtiofsd process helpers
+var (
+	findFs = func(socket string) bool {
+		_, err := FindFs(socket)
+		return err == nil
+	}
+	startFs = func(machine *Machine, socket, path string) (int, error) {
+		return machine.startFs(socket, path)
+	}
+)
+
+// fsRecovery is the recovery state of a share daemon
+type fsRecovery struct {
+	// missing is the number of consecutive ticks the daemon was not found
+	missing int
+	// restarts is the number of times the daemon was restarted
+	restarts int
+}
+
+// due checks if the daemon was missing long enough to be restarted, the
+// wait doubles after each restart
+func (r *fsRecovery) due() bool {
+	return r.restarts < fsMaxRestarts && r.missing >= fsMissingTicks<<r.restarts
+}
+
+// recoverFs relaunches the virtiofsd of the machine shares that are
+// confirmed dead, so the shares work again without a reboot of the vm.
+func (m *Module) recoverFs(ctx context.Context, id string) {
+	machine, err := MachineFromFile(m.configPath(id))
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to load machine config")
+		return
+	}
+
+	for i, fs := range machine.FS {
+		state := m.fsRecovery(FsSocketPath(id, i))
+		if err := machine.restartFs(ctx, m.socketPath(id), i, state); err != nil {
+			log.Error().Err(err).Str("id", id).Str("tag", fs.Tag).Msg("failed to recover virtiofsd")
+		}
+	}
+}
+
+// fsRecovery returns the recovery state of the share socket
+func (m *Module) fsRecovery(socket string) *fsRecovery {
+	m.fsLock.Lock()
+	defer m.fsLock.Unlock()
+
+	if m.fsRecoveries == nil {
+		m.fsRecoveries = make(map[string]*fsRecovery)
+	}
+
+	state, ok := m.fsRecoveries[socket]
+	if !ok {
+		state = &fsRecovery{}
+		m.fsRecoveries[socket] = state
+	}
+
+	return state
+}
+
+// forgetFs drops the recovery state of the machine shares
+func (m *Module) forgetFs(machine *Machine) {
+	m.fsLock.Lock()
+	defer m.fsLock.Unlock()
+
+	for i := range machine.FS {
+		delete(m.fsRecoveries, FsSocketPath(machine.ID, i))
+	}
+}
+
+// restartFs relaunches the virtiofsd of the index-th share of the machine
+// and reconnects it to the machine behind the api socket. Nothing is done if
+// a virtiofsd is still serving the share socket, or it was not missing for
+// long enough.
+func (m *Machine) restartFs(ctx context.Context, api string, index int, state *fsRecovery) error {
+	fs := m.FS[index]
+	socket := FsSocketPath(m.ID, index)
+
+	// the rootfs of a container can't be unplugged from the running
+	// machine, the vm has to be restarted instead
+	if fs.Tag == virtioRootFsTag {
+		return nil
+	}
+
+	if findFs(socket) {
+		state.missing = 0
+		return nil
+	}
+
+	state.missing++
+	if !state.due() {
+		return nil
+	}
+
+	state.missing = 0
+	state.restarts++
+
+	log.Warn().Str("id", m.ID).Str("tag", fs.Tag).Str("socket", socket).Int("attempt", state.restarts).Msg("virtiofsd is dead, restarting")
+
+	// the stale socket of the dead daemon must go before virtiofsd can
+	// listen again
+	_ = os.Remove(socket)
+	if _, err := startFs(m, socket, fs.Path); err != nil {
+		return err
+	}
+
+	// the machine is still attached to the old (dead) backend. the device
+	// is plugged again so it connects to the new daemon.
+	client := NewClient(api)
+	devices, err := client.FsDevices(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list machine filesystems")
+	}
+
+	var old *FsDevice
+	for i := range devices {
+		if devices[i].Socket == socket {
+			old = &devices[i]
+			break
+		}
+	}
+
+	if old != nil {
+		if err := client.RemoveDevice(ctx, old.ID); err != nil {
+			return errors.Wrapf(err, "failed to unplug share '%s'", fs.Tag)
+		}
+	}
+
+	if err := client.AddFs(ctx, FsDevice{Tag: fs.Tag, Socket: socket}); err != nil {
+		err = errors.Wrapf(err, "failed to plug share '%s'", fs.Tag)
+		if old == nil {
+			return err
+		}
+
+		// the share must not be lost, the old device is plugged back so
+		// the next attempt can replace it again
+		if rerr := client.AddFs(ctx, *old); rerr != nil {
+			log.Error().Err(rerr).Str("id", m.ID).Str("tag", fs.Tag).Msg("failed to restore share device")
+		}
+
+		return err
+	}
+
+	log.Info().Str("id", m.ID).Str("tag", fs.Tag).Msg("virtiofsd restarted and share reconnected")
+	return nil
+}
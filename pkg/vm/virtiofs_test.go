@@ -0,0 +1,112 @@
+package vm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFs replaces the virtiofsd process helpers, the daemons in alive are
+// found running and started daemons become alive
+func fakeFs(t *testing.T, alive map[string]bool) *[]string {
+	var started []string
+	find, start := findFs, startFs
+	findFs = func(socket string) bool {
+		return alive[socket]
+	}
+	startFs = func(machine *Machine, socket, path string) (int, error) {
+		started = append(started, socket)
+		alive[socket] = true
+		return len(started), nil
+	}
+	t.Cleanup(func() {
+		findFs, startFs = find, start
+	})
+
+	return &started
+}
+
+func TestRestartFs(t *testing.T) {
+	machine := Machine{ID: "vm", FS: []VirtioFS{{Tag: "data", Path: "/data"}}}
+	socket := FsSocketPath("vm", 0)
+	fake := &fakeCH{fs: []FsDevice{{ID: "_fs0", Tag: "data", Socket: socket}}}
+	api := testCH(t, fake)
+
+	alive := map[string]bool{}
+	started := fakeFs(t, alive)
+	state := &fsRecovery{}
+
+	// the daemon must be missing for a few ticks before it's restarted
+	for i := 0; i < fsMissingTicks-1; i++ {
+		require.NoError(t, machine.restartFs(context.Background(), api, 0, state))
+	}
+	require.Empty(t, *started)
+	require.Empty(t, fake.actions)
+
+	require.NoError(t, machine.restartFs(context.Background(), api, 0, state))
+	require.Equal(t, []string{socket}, *started)
+	require.Equal(t, []string{"vm.remove-device", "vm.add-fs"}, fake.actions)
+	require.Len(t, fake.fs, 1)
+	require.Equal(t, socket, fake.fs[0].Socket)
+
+	// an alive daemon is left alone
+	require.NoError(t, machine.restartFs(context.Background(), api, 0, state))
+	require.Len(t, *started, 1)
+}
+
+func TestRestartFsRootfs(t *testing.T) {
+	machine := Machine{ID: "vm", FS: []VirtioFS{{Tag: virtioRootFsTag, Path: "/root"}}}
+	fake := &fakeCH{fs: []FsDevice{{ID: "_fs0", Tag: virtioRootFsTag, Socket: FsSocketPath("vm", 0)}}}
+	api := testCH(t, fake)
+
+	started := fakeFs(t, map[string]bool{})
+	state := &fsRecovery{}
+	for i := 0; i < 10*fsMissingTicks; i++ {
+		require.NoError(t, machine.restartFs(context.Background(), api, 0, state))
+	}
+
+	require.Empty(t, *started)
+	require.Empty(t, fake.actions)
+}
+
+func TestRestartFsRestoreDevice(t *testing.T) {
+	machine := Machine{ID: "vm", FS: []VirtioFS{{Tag: "data", Path: "/data"}}}
+	socket := FsSocketPath("vm", 0)
+	old := FsDevice{ID: "_fs0", Tag: "data", Socket: socket}
+	fake := &fakeCH{fs: []FsDevice{old}, failAddFs: 1}
+	api := testCH(t, fake)
+
+	fakeFs(t, map[string]bool{})
+	state := &fsRecovery{missing: fsMissingTicks - 1}
+
+	require.Error(t, machine.restartFs(context.Background(), api, 0, state))
+	require.Equal(t, []string{"vm.remove-device", "vm.add-fs", "vm.add-fs"}, fake.actions)
+	// the old device is plugged back
+	require.Equal(t, []FsDevice{old}, fake.fs)
+}
+
+func TestRestartFsAttempts(t *testing.T) {
+	machine := Machine{ID: "vm", FS: []VirtioFS{{Tag: "data", Path: "/data"}}}
+	socket := FsSocketPath("vm", 0)
+	fake := &fakeCH{fs: []FsDevice{{ID: "_fs0", Tag: "data", Socket: socket}}}
+	api := testCH(t, fake)
+
+	alive := map[string]bool{}
+	started := fakeFs(t, alive)
+	state := &fsRecovery{}
+
+	// the daemon keeps dying, the wait doubles after each restart
+	// and restarts stop after the max attempts
+	ticks := 0
+	for i := 0; i < 1000; i++ {
+		delete(alive, socket)
+		require.NoError(t, machine.restartFs(context.Background(), api, 0, state))
+		if len(*started) == 2 && ticks == 0 {
+			ticks = i + 1
+		}
+	}
+
+	require.Len(t, *started, fsMaxRestarts)
+	require.Equal(t, fsMissingTicks+2*fsMissingTicks, ticks)
+}
@@ -19,7 +19,16 @@ func (g *ZosAPI) debugDeploymentGetHandler(ctx context.Context, payload []byte)
 	if err != nil {
 		return nil, err
 	}
-	return debugcmd.Get(ctx, g.debugDeps(), req)
+	resp, err := debugcmd.Get(ctx, g.debugDeps(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := redactWorkloads(resp.Deployment.Workloads); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
 }
 
 func (g *ZosAPI) debugDeploymentHistoryHandler(ctx context.Context, payload []byte) (interface{}, error) {
@@ -46,10 +55,108 @@ func (g *ZosAPI) debugDeploymentHealthHandler(ctx context.Context, payload []byt
 	return debugcmd.Health(ctx, g.debugDeps(), req)
 }
 
+func (g *ZosAPI) debugNodeHealthHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return debugcmd.NodeHealth(ctx, g.debugDeps())
+}
+
+func (g *ZosAPI) debugDeploymentHealthStartHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	req, err := debugcmd.ParseHealthRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	return g.healthSessions.Start(g.debugDeps(), req)
+}
+
+func (g *ZosAPI) debugDeploymentHealthPollHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	req, err := debugcmd.ParseHealthPollRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	return g.healthSessions.Poll(req)
+}
+
+func (g *ZosAPI) debugNetworkStaleConfigsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return debugcmd.StaleNetworkConfigs(ctx, g.debugDeps())
+}
+
+func (g *ZosAPI) debugNetworkStaleConfigsCleanupHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return debugcmd.CleanupStaleNetworkConfigs(ctx, g.debugDeps())
+}
+
+func (g *ZosAPI) debugEngineOrderGetHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return debugcmd.Order(ctx, g.debugDeps())
+}
+
+func (g *ZosAPI) debugEngineOrderSetHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	req, err := debugcmd.ParseSetOrderRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	return nil, debugcmd.SetOrder(ctx, g.debugDeps(), req)
+}
+
+func (g *ZosAPI) debugEngineReconcileHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return debugcmd.Reconcile(ctx, g.debugDeps())
+}
+
+func (g *ZosAPI) debugNetworkExitHistoryHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return debugcmd.ExitHistory(ctx, g.debugDeps())
+}
+
+func (g *ZosAPI) debugUpgradeHoldGetHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return debugcmd.UpgradeHoldState(ctx, g.debugDeps())
+}
+
+func (g *ZosAPI) debugUpgradeHoldSetHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	req, err := debugcmd.ParseUpgradeHoldRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	return nil, debugcmd.UpgradeHold(ctx, g.debugDeps(), req)
+}
+
+func (g *ZosAPI) debugUpgradeHoldReleaseHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return nil, debugcmd.UpgradeRelease(ctx, g.debugDeps())
+}
+
+func (g *ZosAPI) debugVMInfoHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	req, err := debugcmd.ParseVMInfoRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	return debugcmd.VMInfo(ctx, g.debugDeps(), req)
+}
+
+func (g *ZosAPI) debugVMLogsInfoHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	req, err := debugcmd.ParseVMLogsRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	return debugcmd.VMLogsInfo(ctx, g.debugDeps(), req)
+}
+
+func (g *ZosAPI) debugVMLogsFollowHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	req, err := debugcmd.ParseVMLogsFollowRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	return debugcmd.VMLogsFollow(ctx, g.debugDeps(), req)
+}
+
+func (g *ZosAPI) debugVMLogsRetentionSetHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	req, err := debugcmd.ParseVMLogsRetentionRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+	return nil, debugcmd.VMLogsRetentionSet(ctx, g.debugDeps(), req)
+}
+
 func (g *ZosAPI) debugDeps() debugcmd.Deps {
 	return debugcmd.Deps{
 		Provision: g.provisionStub,
 		VM:        g.vmStub,
 		Network:   g.networkerStub,
+		Storage:   g.storageStub,
+		Upgrader:  g.upgraderStub,
 	}
 }
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/pkg/errors"
 	"github.com/threefoldtech/tfgrid-sdk-go/rmb-sdk-go/peer"
 	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
 )
 
 func (g *ZosAPI) deploymentDeployHandler(ctx context.Context, payload []byte) (interface{}, error) {
@@ -27,6 +29,15 @@ func (g *ZosAPI) deploymentUpdateHandler(ctx context.Context, payload []byte) (i
 	return nil, err
 }
 
+func (g *ZosAPI) deploymentValidateHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var deployment gridtypes.Deployment
+	if err := json.Unmarshal(payload, &deployment); err != nil {
+		return nil, err
+	}
+	err := g.provisionStub.ValidateDeployment(ctx, peer.GetTwinID(ctx), deployment)
+	return nil, err
+}
+
 func (g *ZosAPI) deploymentDeleteHandler(ctx context.Context, payload []byte) (interface{}, error) {
 	return nil, fmt.Errorf("deletion over the api is disabled, please cancel your contract instead")
 }
@@ -40,12 +51,81 @@ func (g *ZosAPI) deploymentGetHandler(ctx context.Context, payload []byte) (inte
 		return nil, err
 	}
 
-	return g.provisionStub.Get(ctx, peer.GetTwinID(ctx), args.ContractID)
+	deployment, err := g.provisionStub.Get(ctx, peer.GetTwinID(ctx), args.ContractID)
+	if err != nil {
+		return nil, err
+	}
 
+	if err := redactWorkloads(deployment.Workloads); err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+
+}
+
+// maxBatchGet is the max number of deployments that can be requested at once
+const maxBatchGet = 100
+
+// deploymentGetter is the part of the provision module used to get deployments
+type deploymentGetter interface {
+	Get(ctx context.Context, twin uint32, contractID uint64) (gridtypes.Deployment, error)
+}
+
+func (g *ZosAPI) deploymentBatchGetHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return deploymentBatchGet(ctx, g.provisionStub, peer.GetTwinID(ctx), payload)
+}
+
+// deploymentBatchGet returns the twin deployments with the given contract ids,
+// the ones that can't be found for the twin are left out
+func deploymentBatchGet(ctx context.Context, provision deploymentGetter, twin uint32, payload []byte) ([]gridtypes.Deployment, error) {
+	var args struct {
+		ContractIDs []uint64 `json:"contract_ids"`
+	}
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return nil, err
+	}
+
+	if len(args.ContractIDs) > maxBatchGet {
+		return nil, fmt.Errorf("too many contract ids, max is %d", maxBatchGet)
+	}
+
+	deployments := make([]gridtypes.Deployment, 0, len(args.ContractIDs))
+	seen := make(map[uint64]struct{})
+	for _, id := range args.ContractIDs {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		deployment, err := provision.Get(ctx, twin, id)
+		if err != nil {
+			// not owned by the twin or does not exist
+			continue
+		}
+
+		if err := redactWorkloads(deployment.Workloads); err != nil {
+			return nil, err
+		}
+		deployments = append(deployments, deployment)
+	}
+
+	return deployments, nil
 }
 
 func (g *ZosAPI) deploymentListHandler(ctx context.Context, payload []byte) (interface{}, error) {
-	return g.provisionStub.List(ctx, peer.GetTwinID(ctx))
+	deployments, err := g.provisionStub.List(ctx, peer.GetTwinID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range deployments {
+		if err := redactWorkloads(deployments[i].Workloads); err != nil {
+			return nil, err
+		}
+	}
+
+	return deployments, nil
 }
 
 func (g *ZosAPI) deploymentChangesHandler(ctx context.Context, payload []byte) (interface{}, error) {
@@ -56,5 +136,89 @@ func (g *ZosAPI) deploymentChangesHandler(ctx context.Context, payload []byte) (
 	if err != nil {
 		return nil, err
 	}
-	return g.provisionStub.Changes(ctx, peer.GetTwinID(ctx), args.ContractID)
+	changes, err := g.provisionStub.Changes(ctx, peer.GetTwinID(ctx), args.ContractID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := redactWorkloads(changes); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func (g *ZosAPI) deploymentWorkloadHistoryHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args struct {
+		ContractID uint64         `json:"contract_id"`
+		Name       gridtypes.Name `json:"name"`
+	}
+	err := json.Unmarshal(payload, &args)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := g.provisionStub.Changes(ctx, peer.GetTwinID(ctx), args.ContractID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]gridtypes.Workload, 0)
+	for _, wl := range changes {
+		if wl.Name == args.Name {
+			history = append(history, wl)
+		}
+	}
+
+	if err := redactWorkloads(history); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// redactWorkloads hides the values of the machines secret env variables,
+// they must never be returned to the users
+func redactWorkloads(workloads []gridtypes.Workload) error {
+	for i := range workloads {
+		if err := zos.RedactSecrets(&workloads[i]); err != nil {
+			return errors.Wrapf(err, "failed to redact workload '%s'", workloads[i].Name)
+		}
+	}
+
+	return nil
+}
+
+func (g *ZosAPI) deploymentDeprovisionWorkloadHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args struct {
+		ContractID uint64         `json:"contract_id"`
+		Name       gridtypes.Name `json:"name"`
+		Reason     string         `json:"reason"`
+		Cascade    bool           `json:"cascade"`
+	}
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return nil, err
+	}
+
+	id, err := gridtypes.NewWorkloadID(peer.GetTwinID(ctx), args.ContractID, args.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, g.provisionStub.DeprovisionWorkload(ctx, id.String(), args.Reason, args.Cascade)
+}
+
+func (g *ZosAPI) deploymentValidationStateHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args struct {
+		ContractID uint64 `json:"contract_id"`
+	}
+	err := json.Unmarshal(payload, &args)
+	if err != nil {
+		return nil, err
+	}
+	return g.provisionStub.ValidationState(ctx, peer.GetTwinID(ctx), args.ContractID)
+}
+
+func (g *ZosAPI) deploymentSchemaVersionsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return gridtypes.SchemaVersions(), nil
 }
@@ -43,3 +43,41 @@ func (g *ZosAPI) log(ctx context.Context, _ []byte) (context.Context, error) {
 	}
 	return ctx, nil
 }
+
+// rateLimit rejects the request if the caller twin sent too many requests
+func (g *ZosAPI) rateLimit(ctx context.Context, _ []byte) (context.Context, error) {
+	if err := g.limit(ctx, false); err != nil {
+		return nil, err
+	}
+
+	return ctx, nil
+}
+
+// write marks a handler that changes the node state, on top of the limits of
+// all requests the caller twin is also limited by the write rate
+func (g *ZosAPI) write(handler peer.HandlerFunc) peer.HandlerFunc {
+	return func(ctx context.Context, payload []byte) (interface{}, error) {
+		if err := g.limit(ctx, true); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, payload)
+	}
+}
+
+// limit takes a read or write token of the caller twin, the farmer is never
+// rate limited
+func (g *ZosAPI) limit(ctx context.Context, write bool) error {
+	twin := peer.GetTwinID(ctx)
+	if g.limits == nil || twin == g.farmerID {
+		return nil
+	}
+
+	if err := g.limits.Allow(twin, write); err != nil {
+		command := peer.GetEnvelope(ctx).GetRequest().GetCommand()
+		log.Warn().Uint32("twin", twin).Str("command", command).Msg("rate limited rmb request")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package zosapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfgrid-sdk-go/rmb-sdk-go"
+	"github.com/threefoldtech/tfgrid-sdk-go/rmb-sdk-go/peer"
+	"github.com/threefoldtech/tfgrid-sdk-go/rmb-sdk-go/peer/types"
+	"github.com/threefoldtech/zosbase/pkg/ratelimit"
+)
+
+// envelopeContext gets the context the router passes to the middlewares and
+// handlers for a request of twin. The envelope has no ttl so the router never
+// sends a response.
+func envelopeContext(t *testing.T, twin uint32, command string) context.Context {
+	t.Helper()
+
+	contexts := make(chan context.Context, 1)
+	router := peer.NewRouter()
+	router.SubRoute("zos").Use(func(ctx context.Context, _ []byte) (context.Context, error) {
+		contexts <- ctx
+		return nil, fmt.Errorf("captured")
+	})
+
+	schema := rmb.DefaultSchema
+	router.Serve(context.Background(), nil, &types.Envelope{
+		Uid:       "test",
+		Timestamp: uint64(time.Now().Unix()),
+		Source:    &types.Address{Twin: twin},
+		Schema:    &schema,
+		Message:   &types.Envelope_Request{Request: &types.Request{Command: command}},
+		Payload:   &types.Envelope_Plain{Plain: []byte("{}")},
+	}, nil)
+
+	select {
+	case ctx := <-contexts:
+		return ctx
+	case <-time.After(5 * time.Second):
+		t.Fatal("router didn't call the middleware")
+		return nil
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	api := ZosAPI{farmerID: 1}
+	WithRateLimit(ratelimit.Rate{PerSecond: 0, Burst: 3}, ratelimit.Rate{PerSecond: 0, Burst: 1})(&api)
+
+	var called int
+	deploy := api.write(func(ctx context.Context, payload []byte) (interface{}, error) {
+		called++
+		return nil, nil
+	})
+
+	ctx := envelopeContext(t, 2, "zos.deployment.deploy")
+	_, err := api.rateLimit(ctx, nil)
+	require.NoError(t, err)
+	_, err = deploy(ctx, nil)
+	require.NoError(t, err)
+
+	// the write tokens are used up but the twin can still read
+	_, err = api.rateLimit(ctx, nil)
+	require.NoError(t, err)
+	_, err = deploy(ctx, nil)
+	require.ErrorIs(t, err, ratelimit.ErrRateLimited)
+	require.Equal(t, 1, called)
+
+	ctx = envelopeContext(t, 2, "zos.deployment.list")
+	_, err = api.rateLimit(ctx, nil)
+	require.NoError(t, err)
+	_, err = api.rateLimit(ctx, nil)
+	require.ErrorIs(t, err, ratelimit.ErrRateLimited)
+
+	// other twins have their own tokens
+	ctx = envelopeContext(t, 3, "zos.deployment.deploy")
+	_, err = deploy(ctx, nil)
+	require.NoError(t, err)
+
+	// the farmer is never limited
+	ctx = envelopeContext(t, 1, "zos.deployment.deploy")
+	for i := 0; i < 5; i++ {
+		_, err = api.rateLimit(ctx, nil)
+		require.NoError(t, err)
+		_, err = deploy(ctx, nil)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 7, called)
+}
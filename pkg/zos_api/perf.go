@@ -21,3 +21,11 @@ func (g *ZosAPI) perfGetHandler(ctx context.Context, payload []byte) (interface{
 func (g *ZosAPI) perfGetAllHandler(ctx context.Context, payload []byte) (interface{}, error) {
 	return g.performanceMonitorStub.GetAll(ctx)
 }
+
+func (g *ZosAPI) perfMetricsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.performanceMonitorStub.Metrics(ctx)
+}
+
+func (g *ZosAPI) monitorElectionHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.performanceMonitorStub.Elections(ctx)
+}
@@ -7,12 +7,16 @@ import (
 func (g *ZosAPI) SetupRoutes(router *peer.Router) {
 	root := router.SubRoute("zos")
 	root.Use(g.log)
+	root.Use(g.rateLimit)
 	system := root.SubRoute("system")
 	system.WithHandler("version", g.systemVersionHandler)
 	system.WithHandler("dmi", g.systemDMIHandler)
 	system.WithHandler("hypervisor", g.systemHypervisorHandler)
 	system.WithHandler("diagnostics", g.systemDiagnosticsHandler)
+	system.WithHandler("clock_skew", g.systemClockSkewHandler)
+	system.WithHandler("selftest", g.systemSelfTestHandler)
 	system.WithHandler("node_features_get", g.systemNodeFeaturesHandler)
+	system.WithHandler("uptime_status", g.systemUptimeStatusHandler)
 
 	debug := root.SubRoute("debug")
 	debug.Use(g.adminAuthorized)
@@ -22,16 +26,42 @@ func (g *ZosAPI) SetupRoutes(router *peer.Router) {
 	debugDeployment.WithHandler("history", g.debugDeploymentHistoryHandler)
 	debugDeployment.WithHandler("info", g.debugDeploymentInfoHandler)
 	debugDeployment.WithHandler("health", g.debugDeploymentHealthHandler)
+	debugDeployment.WithHandler("health_start", g.debugDeploymentHealthStartHandler)
+	debugDeployment.WithHandler("health_poll", g.debugDeploymentHealthPollHandler)
+	debugEngine := debug.SubRoute("engine")
+	debugEngine.WithHandler("order_get", g.debugEngineOrderGetHandler)
+	debugEngine.WithHandler("order_set", g.write(g.debugEngineOrderSetHandler))
+	debugEngine.WithHandler("reconcile", g.debugEngineReconcileHandler)
+	debugNetwork := debug.SubRoute("network")
+	debugNetwork.WithHandler("exit_history", g.debugNetworkExitHistoryHandler)
+	debugNetwork.WithHandler("stale_configs", g.debugNetworkStaleConfigsHandler)
+	debugNetwork.WithHandler("stale_configs_cleanup", g.write(g.debugNetworkStaleConfigsCleanupHandler))
+	debugUpgrade := debug.SubRoute("upgrade")
+	debugUpgrade.WithHandler("hold_get", g.debugUpgradeHoldGetHandler)
+	debugUpgrade.WithHandler("hold_set", g.write(g.debugUpgradeHoldSetHandler))
+	debugUpgrade.WithHandler("hold_release", g.write(g.debugUpgradeHoldReleaseHandler))
+	debugNode := debug.SubRoute("node")
+	debugNode.WithHandler("health", g.debugNodeHealthHandler)
+	debugVM := debug.SubRoute("vm")
+	debugVM.WithHandler("info", g.debugVMInfoHandler)
+	debugVM.WithHandler("logs_info", g.debugVMLogsInfoHandler)
+	debugVM.WithHandler("logs_retention_set", g.write(g.debugVMLogsRetentionSetHandler))
+	debugVM.WithHandler("logs_follow", g.debugVMLogsFollowHandler)
 
 	perf := root.SubRoute("perf")
 	perf.WithHandler("get", g.perfGetHandler)
 	perf.WithHandler("get_all", g.perfGetAllHandler)
+	perf.WithHandler("metrics", g.perfMetricsHandler)
+
+	monitor := root.SubRoute("monitor")
+	monitor.WithHandler("election", g.monitorElectionHandler)
 
 	gpu := root.SubRoute("gpu")
 	gpu.WithHandler("list", g.gpuListHandler)
 
 	storage := root.SubRoute("storage")
 	storage.WithHandler("pools", g.storagePoolsHandler)
+	storage.WithHandler("reclaim_orphans", g.write(g.storageReclaimOrphansHandler))
 
 	network := root.SubRoute("network")
 	network.WithHandler("list_wg_ports", g.networkListWGPortsHandler)
@@ -43,20 +73,37 @@ func (g *ZosAPI) SetupRoutes(router *peer.Router) {
 
 	statistics := root.SubRoute("statistics")
 	statistics.WithHandler("get", g.statisticsGetHandler)
+	statistics.WithHandler("capacity", g.statisticsCapacityHandler)
 
 	deployment := root.SubRoute("deployment")
-	deployment.WithHandler("deploy", g.deploymentDeployHandler)
-	deployment.WithHandler("update", g.deploymentUpdateHandler)
+	deployment.WithHandler("deploy", g.write(g.deploymentDeployHandler))
+	deployment.WithHandler("update", g.write(g.deploymentUpdateHandler))
+	deployment.WithHandler("validate", g.deploymentValidateHandler)
 	deployment.WithHandler("delete", g.deploymentDeleteHandler)
 	deployment.WithHandler("get", g.deploymentGetHandler)
+	deployment.WithHandler("batch_get", g.deploymentBatchGetHandler)
 	deployment.WithHandler("list", g.deploymentListHandler)
 	deployment.WithHandler("changes", g.deploymentChangesHandler)
+	deployment.WithHandler("workload_history", g.deploymentWorkloadHistoryHandler)
+	deployment.WithHandler("deprovision_workload", g.write(g.deploymentDeprovisionWorkloadHandler))
+	deployment.WithHandler("validation_state", g.deploymentValidationStateHandler)
+	deployment.WithHandler("schema_versions", g.deploymentSchemaVersionsHandler)
+
+	vm := root.SubRoute("vm")
+	vm.WithHandler("logs", g.vmLogsHandler)
+	vm.WithHandler("logs_range", g.vmLogsRangeHandler)
 
 	admin := root.SubRoute("admin")
 	admin.Use(g.authorized)
 	admin.WithHandler("interfaces", g.adminInterfacesHandler)
-	admin.WithHandler("set_public_nic", g.adminSetPublicNICHandler)
+	admin.WithHandler("set_public_nic", g.write(g.adminSetPublicNICHandler))
 	admin.WithHandler("get_public_nic", g.adminGetPublicNICHandler)
+	admin.WithHandler("deployments_summary", g.adminDeploymentsSummaryHandler)
+	admin.WithHandler("twins_usage", g.adminTwinsUsageHandler)
+	admin.WithHandler("resync_public_ip_rules", g.write(g.adminResyncPublicIPRulesHandler))
+	admin.WithHandler("effective_config", g.adminEffectiveConfigHandler)
+	admin.WithHandler("gpu_drain", g.write(g.adminGPUDrainHandler))
+	admin.WithHandler("gpu_undrain", g.write(g.adminGPUUndrainHandler))
 
 	location := root.SubRoute("location")
 	location.WithHandler("get", g.locationGet)
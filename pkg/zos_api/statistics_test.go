@@ -0,0 +1,86 @@
+package zosapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
+)
+
+type fakeCapacity struct {
+	summary pkg.CapacitySummary
+	pools   []pkg.PoolMetrics
+}
+
+func (f *fakeCapacity) GetCapacitySummary(ctx context.Context) (pkg.CapacitySummary, error) {
+	return f.summary, nil
+}
+
+func (f *fakeCapacity) Metrics(ctx context.Context) ([]pkg.PoolMetrics, error) {
+	return f.pools, nil
+}
+
+func TestCapacitySummary(t *testing.T) {
+	fake := &fakeCapacity{
+		summary: pkg.CapacitySummary{
+			Counters: pkg.Counters{
+				Total: gridtypes.Capacity{CRU: 8, MRU: 16 * gridtypes.Gigabyte},
+				Used:  gridtypes.Capacity{CRU: 2, MRU: 4 * gridtypes.Gigabyte},
+			},
+			Pending: gridtypes.Capacity{CRU: 1, MRU: 2 * gridtypes.Gigabyte},
+			Free:    gridtypes.Capacity{CRU: 5, MRU: 10 * gridtypes.Gigabyte},
+		},
+		pools: []pkg.PoolMetrics{
+			{Name: "ssd", Type: zos.SSDDevice, Size: 100 * gridtypes.Gigabyte, Used: 40 * gridtypes.Gigabyte},
+			{Name: "full", Type: zos.HDDDevice, Size: 100 * gridtypes.Gigabyte, Used: 120 * gridtypes.Gigabyte},
+		},
+	}
+
+	summary, err := capacitySummary(context.Background(), fake, fake)
+	require.NoError(t, err)
+	require.Equal(t, fake.summary, summary.CapacitySummary)
+	require.Equal(t, []PoolCapacity{
+		{Name: "ssd", Type: zos.SSDDevice, Size: 100 * gridtypes.Gigabyte, Used: 40 * gridtypes.Gigabyte, Free: 60 * gridtypes.Gigabyte},
+		{Name: "full", Type: zos.HDDDevice, Size: 100 * gridtypes.Gigabyte, Used: 120 * gridtypes.Gigabyte},
+	}, summary.Pools)
+}
+
+type fakeStatistics struct {
+	counters pkg.Counters
+	pending  int
+	oldest   time.Time
+	err      error
+}
+
+func (f *fakeStatistics) GetCounters(ctx context.Context) (pkg.Counters, error) {
+	return f.counters, nil
+}
+
+func (f *fakeStatistics) QueueStats(ctx context.Context) (int, time.Time, error) {
+	return f.pending, f.oldest, f.err
+}
+
+func TestStatistics(t *testing.T) {
+	fake := &fakeStatistics{
+		counters: pkg.Counters{Total: gridtypes.Capacity{CRU: 8}},
+		pending:  3,
+		oldest:   time.Unix(1000, 0),
+	}
+
+	counters, err := statistics(context.Background(), fake, fake)
+	require.NoError(t, err)
+	require.Equal(t, fake.counters.Total, counters.Total)
+	require.Equal(t, &pkg.QueueCounters{Pending: 3, Oldest: 1000}, counters.Queue)
+
+	// the queue state is omitted if it can't be read
+	fake.err = fmt.Errorf("provision is not reachable")
+	counters, err = statistics(context.Background(), fake, fake)
+	require.NoError(t, err)
+	require.Equal(t, fake.counters.Total, counters.Total)
+	require.Nil(t, counters.Queue)
+}
@@ -41,3 +41,15 @@ func (g *ZosAPI) systemDiagnosticsHandler(ctx context.Context, payload []byte) (
 func (g *ZosAPI) systemNodeFeaturesHandler(ctx context.Context, payload []byte) (interface{}, error) {
 	return g.systemMonitorStub.GetNodeFeatures(ctx), nil
 }
+
+func (g *ZosAPI) systemClockSkewHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.diagnosticsManager.GetClockSkew(ctx)
+}
+
+func (g *ZosAPI) systemSelfTestHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.diagnosticsManager.RunSelfTest(ctx), nil
+}
+
+func (g *ZosAPI) systemUptimeStatusHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.substrateGatewayStub.UptimeStatus(ctx)
+}
@@ -0,0 +1,63 @@
+package zosapi
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/threefoldtech/tfgrid-sdk-go/rmb-sdk-go/peer"
+	"github.com/threefoldtech/zosbase/pkg/debugcmd"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+// vmLogger is the part of the vm module used to read vms logs
+type vmLogger interface {
+	Logs(ctx context.Context, id string) (string, error)
+}
+
+func (g *ZosAPI) vmLogsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return vmLogs(ctx, g.vmStub, peer.GetTwinID(ctx), payload)
+}
+
+// vmLogs returns the sanitized logs of a vm of the twin
+func vmLogs(ctx context.Context, vm vmLogger, twin uint32, payload []byte) (string, error) {
+	var args struct {
+		ContractID uint64         `json:"contract_id"`
+		Name       gridtypes.Name `json:"name"`
+	}
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return "", err
+	}
+
+	// the vm id is built from the caller twin so users can only read their own vms logs
+	id, err := gridtypes.NewWorkloadID(twin, args.ContractID, args.Name)
+	if err != nil {
+		return "", err
+	}
+
+	logs, err := vm.Logs(ctx, id.String())
+	if err != nil {
+		return "", err
+	}
+
+	return debugcmd.SanitizeLogs(logs), nil
+}
+
+func (g *ZosAPI) vmLogsRangeHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args struct {
+		ContractID uint64         `json:"contract_id"`
+		Name       gridtypes.Name `json:"name"`
+		Offset     int64          `json:"offset"`
+		Length     int64          `json:"length"`
+	}
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return nil, err
+	}
+
+	// the vm id is built from the caller twin so users can only read their own vms logs
+	id, err := gridtypes.NewWorkloadID(peer.GetTwinID(ctx), args.ContractID, args.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.vmStub.LogsRange(ctx, id.String(), args.Offset, args.Length)
+}
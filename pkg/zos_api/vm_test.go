@@ -0,0 +1,39 @@
+package zosapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
+)
+
+// fakeVMLogger has the raw logs of the vms by id
+type fakeVMLogger map[string]string
+
+func (f fakeVMLogger) Logs(ctx context.Context, id string) (string, error) {
+	logs, ok := f[id]
+	if !ok {
+		return "", fmt.Errorf("vm '%s' not found", id)
+	}
+	return logs, nil
+}
+
+func TestVMLogs(t *testing.T) {
+	id := gridtypes.NewUncheckedWorkloadID(10, 20, "vm")
+	fake := fakeVMLogger{id.String(): "boot\r\nready\x00\n\xffdone"}
+
+	logs, err := vmLogs(context.Background(), fake, 10, []byte(`{"contract_id": 20, "name": "vm"}`))
+	require.NoError(t, err)
+	require.Equal(t, "boot\nready\n�done", logs)
+}
+
+func TestVMLogsOtherTwin(t *testing.T) {
+	id := gridtypes.NewUncheckedWorkloadID(10, 20, "vm")
+	fake := fakeVMLogger{id.String(): "secret"}
+
+	// the same contract and name from another twin is a different vm
+	_, err := vmLogs(context.Background(), fake, 11, []byte(`{"contract_id": 20, "name": "vm"}`))
+	require.Error(t, err)
+}
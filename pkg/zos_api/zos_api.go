@@ -10,8 +10,10 @@ import (
 	substrate "github.com/threefoldtech/tfchain/clients/tfchain-client-go"
 	"github.com/threefoldtech/zbus"
 	"github.com/threefoldtech/zosbase/pkg/capacity"
+	"github.com/threefoldtech/zosbase/pkg/debugcmd"
 	"github.com/threefoldtech/zosbase/pkg/diagnostics"
 	"github.com/threefoldtech/zosbase/pkg/environment"
+	"github.com/threefoldtech/zosbase/pkg/ratelimit"
 	"github.com/threefoldtech/zosbase/pkg/stubs"
 )
 
@@ -20,6 +22,11 @@ const (
 	cacheDefaultCleanup    = 24 * time.Hour
 )
 
+var (
+	defaultReadRate  = ratelimit.Rate{PerSecond: 10, Burst: 50}
+	defaultWriteRate = ratelimit.Rate{PerSecond: 1, Burst: 10}
+)
+
 type ZosAPI struct {
 	oracle                 *capacity.ResourceOracle
 	versionMonitorStub     *stubs.VersionMonitorStub
@@ -30,12 +37,27 @@ type ZosAPI struct {
 	statisticsStub         *stubs.StatisticsStub
 	storageStub            *stubs.StorageModuleStub
 	performanceMonitorStub *stubs.PerformanceMonitorStub
+	upgraderStub           *stubs.UpgraderStub
+	substrateGatewayStub   *stubs.SubstrateGatewayStub
 	diagnosticsManager     *diagnostics.DiagnosticsManager
+	healthSessions         *debugcmd.HealthSessions
 	farmerID               uint32
 	inMemCache             *cache.Cache
+	limits                 *ratelimit.Limits
 }
 
-func NewZosAPI(manager substrate.Manager, client zbus.Client, msgBrokerCon string) (ZosAPI, error) {
+// Option is a zos api option
+type Option func(*ZosAPI)
+
+// WithRateLimit sets the rate of the read and write requests allowed for
+// each caller twin
+func WithRateLimit(read, write ratelimit.Rate) Option {
+	return func(g *ZosAPI) {
+		g.limits = ratelimit.NewLimits(read, write)
+	}
+}
+
+func NewZosAPI(manager substrate.Manager, client zbus.Client, msgBrokerCon string, opts ...Option) (ZosAPI, error) {
 	sub, err := manager.Substrate()
 	if err != nil {
 		return ZosAPI{}, err
@@ -56,7 +78,10 @@ func NewZosAPI(manager substrate.Manager, client zbus.Client, msgBrokerCon strin
 		statisticsStub:         stubs.NewStatisticsStub(client),
 		storageStub:            storageModuleStub,
 		performanceMonitorStub: stubs.NewPerformanceMonitorStub(client),
+		upgraderStub:           stubs.NewUpgraderStub(client),
+		substrateGatewayStub:   stubs.NewSubstrateGatewayStub(client),
 		diagnosticsManager:     diagnosticsManager,
+		healthSessions:         debugcmd.NewHealthSessions(),
 	}
 	exp := backoff.NewExponentialBackOff()
 	exp.MaxInterval = 2 * time.Second
@@ -94,5 +119,9 @@ func NewZosAPI(manager substrate.Manager, client zbus.Client, msgBrokerCon strin
 
 	api.farmerID = uint32(farmer.ID)
 	api.inMemCache = cache.New(cacheDefaultExpiration, cacheDefaultCleanup)
+	api.limits = ratelimit.NewLimits(defaultReadRate, defaultWriteRate)
+	for _, opt := range opts {
+		opt(&api)
+	}
 	return api, nil
 }
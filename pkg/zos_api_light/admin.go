@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+
+	"github.com/threefoldtech/zosbase/pkg/environment"
 )
 
 func (g *ZosAPI) adminInterfacesHandler(ctx context.Context, payload []byte) (interface{}, error) {
@@ -46,3 +48,40 @@ func (g *ZosAPI) adminSetPublicNICHandler(ctx context.Context, payload []byte) (
 	return nil, fmt.Errorf("not supported")
 
 }
+
+func (g *ZosAPI) adminDeploymentsSummaryHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.provisionStub.NodeDeploymentsSummary(ctx)
+}
+
+func (g *ZosAPI) adminTwinsUsageHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return g.provisionStub.TwinsUsage(ctx)
+}
+
+func (g *ZosAPI) adminResyncPublicIPRulesHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return nil, g.provisionStub.ResyncPublicIPRules(ctx)
+}
+
+func (g *ZosAPI) adminEffectiveConfigHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return environment.EffectiveConfig()
+}
+
+func (g *ZosAPI) adminGPUDrainHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args struct {
+		ID     string `json:"id"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return nil, fmt.Errorf("failed to decode input, expecting {id, reason}: %w", err)
+	}
+	return nil, g.statisticsStub.DrainGPU(ctx, args.ID, args.Reason)
+}
+
+func (g *ZosAPI) adminGPUUndrainHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return nil, fmt.Errorf("failed to decode input, expecting {id}: %w", err)
+	}
+	return nil, g.statisticsStub.UndrainGPU(ctx, args.ID)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/threefoldtech/tfgrid-sdk-go/rmb-sdk-go/peer"
 	gridtypes "github.com/threefoldtech/zosbase/pkg/gridtypes"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes/zos"
 )
 
 func (g *ZosAPI) networkInterfacesHandler(ctx context.Context, payload []byte) (interface{}, error) {
@@ -41,6 +42,18 @@ func (g *ZosAPI) networkListPrivateIPsHandler(ctx context.Context, payload []byt
 	return g.provisionStub.ListPrivateIPs(ctx, twin, args.NetworkName)
 }
 
+func (g *ZosAPI) networkWireguardStatsHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	var args struct {
+		NetworkName gridtypes.Name `json:"network_name"`
+	}
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return nil, err
+	}
+	// only networks of the calling twin are reachable
+	twin := peer.GetTwinID(ctx)
+	return g.networkerLightStub.WireguardStats(ctx, zos.NetworkID(twin, args.NetworkName))
+}
+
 func (g *ZosAPI) networkListWGPortsHandler(ctx context.Context, payload []byte) (interface{}, error) {
 	return g.networkerLightStub.WireguardPorts(ctx)
 }
@@ -7,22 +7,31 @@ import (
 func (g *ZosAPI) SetupRoutes(router *peer.Router) {
 	root := router.SubRoute("zos")
 	root.Use(g.log)
+	root.Use(g.rateLimit)
 	system := root.SubRoute("system")
 	system.WithHandler("version", g.systemVersionHandler)
 	system.WithHandler("dmi", g.systemDMIHandler)
 	system.WithHandler("hypervisor", g.systemHypervisorHandler)
 	system.WithHandler("diagnostics", g.systemDiagnosticsHandler)
+	system.WithHandler("clock_skew", g.systemClockSkewHandler)
+	system.WithHandler("selftest", g.systemSelfTestHandler)
 	system.WithHandler("node_features_get", g.systemNodeFeaturesHandler)
+	system.WithHandler("uptime_status", g.systemUptimeStatusHandler)
 
 	perf := root.SubRoute("perf")
 	perf.WithHandler("get", g.perfGetHandler)
 	perf.WithHandler("get_all", g.perfGetAllHandler)
+	perf.WithHandler("metrics", g.perfMetricsHandler)
+
+	monitor := root.SubRoute("monitor")
+	monitor.WithHandler("election", g.monitorElectionHandler)
 
 	gpu := root.SubRoute("gpu")
 	gpu.WithHandler("list", g.gpuListHandler)
 
 	storage := root.SubRoute("storage")
 	storage.WithHandler("pools", g.storagePoolsHandler)
+	storage.WithHandler("reclaim_orphans", g.write(g.storageReclaimOrphansHandler))
 
 	network := root.SubRoute("network")
 	network.WithHandler("list_wg_ports", g.networkListWGPortsHandler)
@@ -31,23 +40,41 @@ func (g *ZosAPI) SetupRoutes(router *peer.Router) {
 	network.WithHandler("has_ipv6", g.networkHasIPv6Handler)
 	// network.WithHandler("list_public_ips", g.networkListPublicIPsHandler)
 	network.WithHandler("list_private_ips", g.networkListPrivateIPsHandler)
+	network.WithHandler("wireguard_stats", g.networkWireguardStatsHandler)
 
 	statistics := root.SubRoute("statistics")
 	statistics.WithHandler("get", g.statisticsGetHandler)
+	statistics.WithHandler("capacity", g.statisticsCapacityHandler)
 
 	deployment := root.SubRoute("deployment")
-	deployment.WithHandler("deploy", g.deploymentDeployHandler)
-	deployment.WithHandler("update", g.deploymentUpdateHandler)
+	deployment.WithHandler("deploy", g.write(g.deploymentDeployHandler))
+	deployment.WithHandler("update", g.write(g.deploymentUpdateHandler))
+	deployment.WithHandler("validate", g.deploymentValidateHandler)
 	deployment.WithHandler("delete", g.deploymentDeleteHandler)
 	deployment.WithHandler("get", g.deploymentGetHandler)
+	deployment.WithHandler("batch_get", g.deploymentBatchGetHandler)
 	deployment.WithHandler("list", g.deploymentListHandler)
 	deployment.WithHandler("changes", g.deploymentChangesHandler)
+	deployment.WithHandler("workload_history", g.deploymentWorkloadHistoryHandler)
+	deployment.WithHandler("deprovision_workload", g.write(g.deploymentDeprovisionWorkloadHandler))
+	deployment.WithHandler("validation_state", g.deploymentValidationStateHandler)
+	deployment.WithHandler("schema_versions", g.deploymentSchemaVersionsHandler)
+
+	vm := root.SubRoute("vm")
+	vm.WithHandler("logs", g.vmLogsHandler)
+	vm.WithHandler("logs_range", g.vmLogsRangeHandler)
 
 	admin := root.SubRoute("admin")
 	admin.Use(g.authorized)
 	admin.WithHandler("interfaces", g.adminInterfacesHandler)
-	admin.WithHandler("set_public_nic", g.adminSetPublicNICHandler)
+	admin.WithHandler("set_public_nic", g.write(g.adminSetPublicNICHandler))
 	admin.WithHandler("get_public_nic", g.adminGetPublicNICHandler)
+	admin.WithHandler("deployments_summary", g.adminDeploymentsSummaryHandler)
+	admin.WithHandler("twins_usage", g.adminTwinsUsageHandler)
+	admin.WithHandler("resync_public_ip_rules", g.write(g.adminResyncPublicIPRulesHandler))
+	admin.WithHandler("effective_config", g.adminEffectiveConfigHandler)
+	admin.WithHandler("gpu_drain", g.write(g.adminGPUDrainHandler))
+	admin.WithHandler("gpu_undrain", g.write(g.adminGPUUndrainHandler))
 
 	location := root.SubRoute("location")
 	location.WithHandler("get", g.locationGet)
@@ -2,8 +2,110 @@ package zosapi
 
 import (
 	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zosbase/pkg"
+	"github.com/threefoldtech/zosbase/pkg/gridtypes"
 )
 
+// countersSource is the part of the statistics module used to build the
+// node statistics
+type countersSource interface {
+	GetCounters(ctx context.Context) (pkg.Counters, error)
+}
+
+// queueSource is the part of the provision module used to build the node
+// statistics
+type queueSource interface {
+	QueueStats(ctx context.Context) (int, time.Time, error)
+}
+
 func (g *ZosAPI) statisticsGetHandler(ctx context.Context, payload []byte) (interface{}, error) {
-	return g.statisticsStub.GetCounters(ctx)
+	return statistics(ctx, g.statisticsStub, g.provisionStub)
+}
+
+// statistics returns the node counters, the queue state is omitted if it
+// can't be read so the counters are still served
+func statistics(ctx context.Context, stats countersSource, provision queueSource) (pkg.Counters, error) {
+	counters, err := stats.GetCounters(ctx)
+	if err != nil {
+		return pkg.Counters{}, err
+	}
+
+	pending, oldest, err := provision.QueueStats(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get provision queue stats")
+		return counters, nil
+	}
+
+	counters.Queue = &pkg.QueueCounters{Pending: pending}
+	if !oldest.IsZero() {
+		counters.Queue.Oldest = gridtypes.Timestamp(oldest.Unix())
+	}
+
+	return counters, nil
+}
+
+// PoolCapacity is the capacity of a storage pool
+type PoolCapacity struct {
+	Name string         `json:"name"`
+	Type pkg.DeviceType `json:"type"`
+	Size gridtypes.Unit `json:"size"`
+	Used gridtypes.Unit `json:"used"`
+	Free gridtypes.Unit `json:"free"`
+}
+
+// CapacitySummary is the node capacity with the free capacity of each pool
+type CapacitySummary struct {
+	pkg.CapacitySummary
+	Pools []PoolCapacity `json:"pools"`
+}
+
+// capacitySource is the part of the statistics module used to build the
+// capacity summary
+type capacitySource interface {
+	GetCapacitySummary(ctx context.Context) (pkg.CapacitySummary, error)
+}
+
+// poolsSource is the part of the storage module used to build the capacity
+// summary
+type poolsSource interface {
+	Metrics(ctx context.Context) ([]pkg.PoolMetrics, error)
+}
+
+func (g *ZosAPI) statisticsCapacityHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	return capacitySummary(ctx, g.statisticsStub, g.storageStub)
+}
+
+func capacitySummary(ctx context.Context, stats capacitySource, storage poolsSource) (CapacitySummary, error) {
+	summary, err := stats.GetCapacitySummary(ctx)
+	if err != nil {
+		return CapacitySummary{}, err
+	}
+
+	pools, err := storage.Metrics(ctx)
+	if err != nil {
+		return CapacitySummary{}, err
+	}
+
+	result := CapacitySummary{
+		CapacitySummary: summary,
+		Pools:           make([]PoolCapacity, 0, len(pools)),
+	}
+
+	for _, pool := range pools {
+		capacity := PoolCapacity{
+			Name: pool.Name,
+			Type: pool.Type,
+			Size: pool.Size,
+			Used: pool.Used,
+		}
+		if pool.Used < pool.Size {
+			capacity.Free = pool.Size - pool.Used
+		}
+		result.Pools = append(result.Pools, capacity)
+	}
+
+	return result, nil
 }
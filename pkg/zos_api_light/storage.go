@@ -2,8 +2,28 @@ package zosapi
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 )
 
 func (g *ZosAPI) storagePoolsHandler(ctx context.Context, payload []byte) (interface{}, error) {
 	return g.storageStub.Metrics(ctx)
 }
+
+func (g *ZosAPI) storageReclaimOrphansHandler(ctx context.Context, payload []byte) (interface{}, error) {
+	// deleting volumes is only allowed for the farmer
+	if _, err := g.authorized(ctx, payload); err != nil {
+		return nil, err
+	}
+
+	var args struct {
+		DryRun bool `json:"dry_run"`
+	}
+	if len(payload) != 0 {
+		if err := json.Unmarshal(payload, &args); err != nil {
+			return nil, fmt.Errorf("failed to decode input, expecting {dry_run: bool}: %w", err)
+		}
+	}
+
+	return g.diagnosticsManager.ReclaimOrphanVolumes(ctx, args.DryRun)
+}
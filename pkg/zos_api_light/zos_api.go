@@ -12,6 +12,7 @@ import (
 	"github.com/threefoldtech/zosbase/pkg/capacity"
 	"github.com/threefoldtech/zosbase/pkg/diagnostics"
 	"github.com/threefoldtech/zosbase/pkg/environment"
+	"github.com/threefoldtech/zosbase/pkg/ratelimit"
 	"github.com/threefoldtech/zosbase/pkg/stubs"
 )
 
@@ -20,21 +21,40 @@ const (
 	cacheDefaultCleanup    = 24 * time.Hour
 )
 
+var (
+	defaultReadRate  = ratelimit.Rate{PerSecond: 10, Burst: 50}
+	defaultWriteRate = ratelimit.Rate{PerSecond: 1, Burst: 10}
+)
+
 type ZosAPI struct {
 	oracle                 *capacity.ResourceOracle
 	versionMonitorStub     *stubs.VersionMonitorStub
 	systemMonitorStub      *stubs.SystemMonitorStub
 	provisionStub          *stubs.ProvisionStub
 	networkerLightStub     *stubs.NetworkerLightStub
+	vmStub                 *stubs.VMModuleStub
 	statisticsStub         *stubs.StatisticsStub
 	storageStub            *stubs.StorageModuleStub
 	performanceMonitorStub *stubs.PerformanceMonitorStub
+	substrateGatewayStub   *stubs.SubstrateGatewayStub
 	diagnosticsManager     *diagnostics.DiagnosticsManager
 	farmerID               uint32
 	inMemCache             *cache.Cache
+	limits                 *ratelimit.Limits
+}
+
+// Option is a zos api option
+type Option func(*ZosAPI)
+
+// WithRateLimit sets the rate of the read and write requests allowed for
+// each caller twin
+func WithRateLimit(read, write ratelimit.Rate) Option {
+	return func(g *ZosAPI) {
+		g.limits = ratelimit.NewLimits(read, write)
+	}
 }
 
-func NewZosAPI(manager substrate.Manager, client zbus.Client, msgBrokerCon string) (ZosAPI, error) {
+func NewZosAPI(manager substrate.Manager, client zbus.Client, msgBrokerCon string, opts ...Option) (ZosAPI, error) {
 	sub, err := manager.Substrate()
 	if err != nil {
 		return ZosAPI{}, err
@@ -51,9 +71,11 @@ func NewZosAPI(manager substrate.Manager, client zbus.Client, msgBrokerCon strin
 		systemMonitorStub:      stubs.NewSystemMonitorStub(client),
 		provisionStub:          stubs.NewProvisionStub(client),
 		networkerLightStub:     stubs.NewNetworkerLightStub(client),
+		vmStub:                 stubs.NewVMModuleStub(client),
 		statisticsStub:         stubs.NewStatisticsStub(client),
 		storageStub:            storageModuleStub,
 		performanceMonitorStub: stubs.NewPerformanceMonitorStub(client),
+		substrateGatewayStub:   stubs.NewSubstrateGatewayStub(client),
 		diagnosticsManager:     diagnosticsManager,
 	}
 	exp := backoff.NewExponentialBackOff()
@@ -91,10 +113,14 @@ func NewZosAPI(manager substrate.Manager, client zbus.Client, msgBrokerCon strin
 	}
 	api.farmerID = uint32(farmer.ID)
 	api.inMemCache = cache.New(cacheDefaultExpiration, cacheDefaultCleanup)
+	api.limits = ratelimit.NewLimits(defaultReadRate, defaultWriteRate)
+	for _, opt := range opts {
+		opt(&api)
+	}
 	return api, nil
 }
 
-func NewZosAPIWithFarmerID(client zbus.Client, farmerID uint32, msgBrokerCon string) (ZosAPI, error) {
+func NewZosAPIWithFarmerID(client zbus.Client, farmerID uint32, msgBrokerCon string, opts ...Option) (ZosAPI, error) {
 	diagnosticsManager, err := diagnostics.NewDiagnosticsManager(msgBrokerCon, client)
 	if err != nil {
 		return ZosAPI{}, err
@@ -106,12 +132,18 @@ func NewZosAPIWithFarmerID(client zbus.Client, farmerID uint32, msgBrokerCon str
 		systemMonitorStub:      stubs.NewSystemMonitorStub(client),
 		provisionStub:          stubs.NewProvisionStub(client),
 		networkerLightStub:     stubs.NewNetworkerLightStub(client),
+		vmStub:                 stubs.NewVMModuleStub(client),
 		statisticsStub:         stubs.NewStatisticsStub(client),
 		storageStub:            storageModuleStub,
 		performanceMonitorStub: stubs.NewPerformanceMonitorStub(client),
+		substrateGatewayStub:   stubs.NewSubstrateGatewayStub(client),
 		diagnosticsManager:     diagnosticsManager,
 	}
 	api.farmerID = farmerID
 	api.inMemCache = cache.New(cacheDefaultExpiration, cacheDefaultCleanup)
+	api.limits = ratelimit.NewLimits(defaultReadRate, defaultWriteRate)
+	for _, opt := range opts {
+		opt(&api)
+	}
 	return api, nil
 }